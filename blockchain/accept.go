@@ -60,24 +60,48 @@ func (b *BlockChain) maybeAcceptBlock(block *btcutil.Block, flags BehaviorFlags)
 		return false, err
 	}
 
-	// Create a new block node for the block and add it to the node index. Even
-	// if the block ultimately gets connected to the main chain, it starts out
-	// on a side chain.
+	// Create a new block node for the block and add it to the node index
+	// unless its header is already known. Even if the block ultimately gets
+	// connected to the main chain, it starts out on a side chain.
 	blockHeader := &block.MsgBlock().Header
-	newNode := newBlockNode(blockHeader, prevNode)
-	newNode.status = statusDataStored
-
-	b.index.AddNode(newNode)
+	newNode := b.index.LookupNode(block.Hash())
+	if newNode == nil {
+		newNode = newBlockNode(blockHeader, prevNode)
+		newNode.status = statusDataStored
+		b.index.AddNode(newNode)
+		b.updateBestHeader(newNode)
+	} else {
+		b.index.SetStatusFlags(newNode, statusDataStored)
+	}
+	hadChainTxns := b.index.NodeChainTxns(newNode) != 0
+	b.index.ConnectChainTxns(newNode, len(block.Transactions()))
+	if !hadChainTxns && b.index.NodeChainTxns(newNode) != 0 {
+		if err := b.connectDescendantChainTxns(newNode); err != nil {
+			return false, err
+		}
+	}
 	err = b.index.flushToDB()
 	if err != nil {
 		return false, err
 	}
 
+	// The block can't be connected until the data for all of the blocks it
+	// builds on is available.  This happens when blocks are downloaded out
+	// of order, in which case it is connected once they are.
+	if !b.haveChainData(prevNode) {
+		log.Debugf("Stored block %v (height %d) until its ancestors are "+
+			"available", newNode.hash, blockHeight)
+		return false, nil
+	}
+
 	// Connect the passed block to the chain while respecting proper chain
 	// selection according to the chain with the most proof of work.  This
 	// also handles validation of the transaction scripts.
 	isMainChain, err := b.connectBestChain(newNode, block, flags)
 	if err != nil {
+		if _, ok := err.(RuleError); ok {
+			b.resetBestHeader()
+		}
 		return false, err
 	}
 
@@ -90,3 +114,40 @@ func (b *BlockChain) maybeAcceptBlock(block *btcutil.Block, flags BehaviorFlags)
 
 	return isMainChain, nil
 }
+
+// connectDescendantChainTxns sets the total number of transactions in the chain
+// for the stored descendants of the passed node that were stored before the
+// total was known for it.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) connectDescendantChainTxns(node *blockNode) error {
+	for _, tip := range b.index.ChainTips() {
+		if tip.height <= node.height || tip.Ancestor(node.height) != node {
+			continue
+		}
+
+		// Connect the blocks from the one after the passed node towards
+		// the tip until one is reached whose data is not stored or whose
+		// total is already known.
+		for height := node.height + 1; height <= tip.height; height++ {
+			n := tip.Ancestor(height)
+			if b.index.NodeChainTxns(n) != 0 ||
+				!b.index.NodeStatus(n).HaveData() {
+
+				break
+			}
+
+			var numTxns uint64
+			err := b.db.View(func(dbTx database.Tx) error {
+				var err error
+				numTxns, err = dbFetchBlockTxCount(dbTx, &n.hash)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			b.index.ConnectChainTxns(n, int(numTxns))
+		}
+	}
+	return nil
+}
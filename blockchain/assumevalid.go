@@ -0,0 +1,63 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// findAssumeValidNode returns the block node for the assumed valid block or nil
+// when there is no assumed valid block or it is not known yet.  The chain view
+// that ends with the node is also created the first time it is found so
+// checking whether a block is one of its ancestors is fast.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) findAssumeValidNode() *blockNode {
+	if b.assumeValid == nil {
+		return nil
+	}
+	if b.assumeValidNode == nil {
+		node := b.index.LookupNode(b.assumeValid)
+		if node == nil {
+			return nil
+		}
+
+		b.assumeValidNode = node
+		b.assumeValidChain = newChainView(node)
+	}
+	return b.assumeValidNode
+}
+
+// isAssumedValid returns whether or not the passed node is the assumed valid
+// block or one of its ancestors, in which case the scripts in the block don't
+// need to be validated.  The assumption no longer holds once the assumed valid
+// block is known to be invalid.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) isAssumedValid(node *blockNode) bool {
+	avNode := b.findAssumeValidNode()
+	if avNode == nil || b.index.NodeStatus(avNode).KnownInvalid() {
+		return false
+	}
+	return b.assumeValidChain.Contains(node)
+}
+
+// AssumeValid returns the hash of the assumed valid block along with whether or
+// not script validation is currently being skipped because the blocks that
+// follow the current best block are ancestors of it.  A nil hash is returned
+// when there is no assumed valid block.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) AssumeValid() (*chainhash.Hash, bool) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	if b.assumeValid == nil {
+		return nil, false
+	}
+	active := b.isAssumedValid(b.bestChain.Tip()) &&
+		b.bestChain.Tip() != b.assumeValidNode
+	return b.assumeValid, active
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// TestAssumeValid ensures only the assumed valid block and its ancestors are
+// treated as having valid scripts.
+func TestAssumeValid(t *testing.T) {
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a -> 4a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+		"blk_4A.dat.bz2",
+	}
+	var blocks []*btcutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v\n", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+	block2, block3, block4 := blocks[2], blocks[3], blocks[4]
+	block3A, block4A := blocks[5], blocks[6]
+
+	chain, teardownFunc, err := chainSetup("assumevalid",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	// The assumed valid block is not known until it is processed.
+	chain.assumeValid = block4A.Hash()
+	for i := 1; i < 5; i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+	if hash, active := chain.AssumeValid(); *hash != *block4A.Hash() || active {
+		t.Fatalf("unexpected assume valid state %v, %v", hash, active)
+	}
+	for i := 5; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+
+	// checkAssumed ensures whether or not the passed blocks are assumed
+	// to be valid is the expected one.
+	checkAssumed := func(desc string, want bool, blocks ...*btcutil.Block) {
+		t.Helper()
+		for _, block := range blocks {
+			node := chain.index.LookupNode(block.Hash())
+			if got := chain.isAssumedValid(node); got != want {
+				t.Fatalf("%s: block %v assumed valid %v, want %v",
+					desc, block.Hash(), got, want)
+			}
+		}
+	}
+	checkAssumed("side chain", true, block2, block3A, block4A)
+	checkAssumed("side chain", false, block3, block4)
+
+	// Scripts are validated again once the assumed valid block is known to
+	// be invalid.
+	if err := chain.InvalidateBlock(block4A.Hash()); err != nil {
+		t.Fatalf("InvalidateBlock: %v", err)
+	}
+	checkAssumed("invalid", false, block2, block3A, block4A)
+}
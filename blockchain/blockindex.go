@@ -81,6 +81,14 @@ type blockNode struct {
 	// this node.
 	workSum *big.Int
 
+	// chainTxns is the total number of transactions in the chain up to and
+	// including this node.  It is zero when it is not known yet because the
+	// data for this block or one of its ancestors is not available.  Like
+	// the status field, it may be written to and so should only be
+	// accessed using the concurrent-safe methods on blockIndex once the
+	// node has been added to the global index.
+	chainTxns uint64
+
 	// height is the position in the block chain.
 	height int32
 
@@ -232,6 +240,11 @@ type blockIndex struct {
 	sync.RWMutex
 	index map[chainhash.Hash]*blockNode
 	dirty map[*blockNode]struct{}
+
+	// chainTips houses the nodes in the index that do not have any
+	// children.  This includes the tip of the main chain along with the
+	// tips of all side chains.
+	chainTips map[*blockNode]struct{}
 }
 
 // newBlockIndex returns a new empty instance of a block index.  The index will
@@ -243,6 +256,7 @@ func newBlockIndex(db database.DB, chainParams *chaincfg.Params) *blockIndex {
 		chainParams: chainParams,
 		index:       make(map[chainhash.Hash]*blockNode),
 		dirty:       make(map[*blockNode]struct{}),
+		chainTips:   make(map[*blockNode]struct{}),
 	}
 }
 
@@ -279,11 +293,30 @@ func (bi *blockIndex) AddNode(node *blockNode) {
 }
 
 // addNode adds the provided node to the block index, but does not mark it as
-// dirty. This can be used while initializing the block index.
+// dirty. This can be used while initializing the block index.  The node
+// replaces its parent as a chain tip.
 //
 // This function is NOT safe for concurrent access.
 func (bi *blockIndex) addNode(node *blockNode) {
 	bi.index[node.hash] = node
+	if node.parent != nil {
+		delete(bi.chainTips, node.parent)
+	}
+	bi.chainTips[node] = struct{}{}
+}
+
+// ChainTips returns the nodes in the block index that do not have any
+// children.  The order of the returned nodes is not defined.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) ChainTips() []*blockNode {
+	bi.RLock()
+	tips := make([]*blockNode, 0, len(bi.chainTips))
+	for node := range bi.chainTips {
+		tips = append(tips, node)
+	}
+	bi.RUnlock()
+	return tips
 }
 
 // NodeStatus provides concurrent-safe access to the status field of a node.
@@ -319,6 +352,50 @@ func (bi *blockIndex) UnsetStatusFlags(node *blockNode, flags blockStatus) {
 	bi.Unlock()
 }
 
+// NodeChainTxns provides concurrent-safe access to the chainTxns field of a
+// node.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) NodeChainTxns(node *blockNode) uint64 {
+	bi.RLock()
+	chainTxns := node.chainTxns
+	bi.RUnlock()
+	return chainTxns
+}
+
+// SetChainTxns sets the total number of transactions in the chain up to and
+// including the provided block node.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) SetChainTxns(node *blockNode, chainTxns uint64) {
+	bi.Lock()
+	node.chainTxns = chainTxns
+	bi.dirty[node] = struct{}{}
+	bi.Unlock()
+}
+
+// ConnectChainTxns sets the total number of transactions in the chain up to and
+// including the provided block node from the number of transactions in the
+// block when it is not known yet and the total of its parent is.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) ConnectChainTxns(node *blockNode, numTxns int) {
+	bi.Lock()
+	defer bi.Unlock()
+	if node.chainTxns != 0 {
+		return
+	}
+	var parentTxns uint64
+	if node.parent != nil {
+		parentTxns = node.parent.chainTxns
+		if parentTxns == 0 {
+			return
+		}
+	}
+	node.chainTxns = parentTxns + uint64(numTxns)
+	bi.dirty[node] = struct{}{}
+}
+
 // flushToDB writes all dirty block nodes to the database. If all writes
 // succeed, this clears the dirty set.
 func (bi *blockIndex) flushToDB() error {
@@ -8,6 +8,7 @@ package blockchain
 import (
 	"container/list"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -96,11 +97,15 @@ type BlockChain struct {
 	checkpoints         []chaincfg.Checkpoint
 	checkpointsByHeight map[int32]*chaincfg.Checkpoint
 	db                  database.DB
+	interrupt           <-chan struct{}
+	fatalError          func(error)
 	chainParams         *chaincfg.Params
 	timeSource          MedianTimeSource
 	sigCache            *txscript.SigCache
 	indexManager        IndexManager
 	hashCache           *txscript.HashCache
+	pruneTarget         uint64
+	assumeValid         *chainhash.Hash
 
 	// The following fields are calculated based upon the provided chain
 	// parameters.  They are also set when the instance is created and
@@ -123,8 +128,35 @@ type BlockChain struct {
 	//
 	// bestChain tracks the current active chain by making use of an
 	// efficient chain view into the block index.
-	index     *blockIndex
-	bestChain *chainView
+	//
+	// bestHeader tracks the chain of headers with the most cumulative work
+	// which extends past the active chain when the headers of blocks are
+	// known before the blocks themselves.
+	index      *blockIndex
+	bestChain  *chainView
+	bestHeader *chainView
+
+	// utxoCache is a write-back cache of the utxo set that sits between the
+	// utxo views used to connect blocks and the database.  It has its own
+	// lock for lookups, but it is only modified with the chain lock held
+	// for writes.
+	utxoCache *utxoCache
+
+	// These fields track whether any blocks have been pruned and the
+	// height of the oldest main chain block whose data is still stored.
+	// They are protected by the chain lock.
+	pruned      bool
+	pruneHeight int32
+
+	// snapshot houses the state of a loaded utxo snapshot or nil when none
+	// was loaded.  It is protected by the chain lock.
+	snapshot *snapshotState
+
+	// These fields track the assumed valid block once it is known along
+	// with a view of the chain that ends with it.  They are protected by
+	// the chain lock.
+	assumeValidNode  *blockNode
+	assumeValidChain *chainView
 
 	// These fields are related to handling of orphan blocks.  They are
 	// protected by a combination of the chain lock and the orphan lock.
@@ -579,6 +611,11 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block,
 		}
 	}
 
+	// Track the total number of transactions in the chain up to and
+	// including the block when it was stored before the blocks it builds
+	// on were available.
+	b.index.ConnectChainTxns(node, len(block.Transactions()))
+
 	// Write any block status changes to DB before updating best state.
 	err := b.index.flushToDB()
 	if err != nil {
@@ -611,14 +648,6 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block,
 			return err
 		}
 
-		// Update the utxo set using the state of the utxo view.  This
-		// entails removing all of the utxos spent and adding the new
-		// ones created by the block.
-		err = dbPutUtxoView(dbTx, view)
-		if err != nil {
-			return err
-		}
-
 		// Update the transaction spend journal by adding a record for
 		// the block that contains all txos spent by it.
 		err = dbPutSpendJournalEntry(dbTx, block.Hash(), stxos)
@@ -642,8 +671,14 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block,
 		return err
 	}
 
+	// Update the utxo cache using the state of the utxo view.  This entails
+	// removing all of the utxos spent and adding the new ones created by
+	// the block.  The changes are written to the database when the cache
+	// is flushed.
+	b.utxoCache.commit(view)
+
 	// Prune fully spent entries and mark all entries in the view unmodified
-	// now that the modifications have been committed to the database.
+	// now that the modifications have been committed to the cache.
 	view.commit()
 
 	// This node is now the end of the best chain.
@@ -658,6 +693,29 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block,
 	b.stateSnapshot = state
 	b.stateLock.Unlock()
 
+	// Let the user know when the scripts of the blocks that follow are
+	// validated again.
+	if b.assumeValid != nil && node.hash == *b.assumeValid {
+		log.Infof("Reached the assumed valid block %v (height %d) -- "+
+			"validating all scripts from now on", node.hash,
+			node.height)
+	}
+
+	// Flush the utxo cache to the database if it has grown too large or
+	// enough time has passed since the last flush.
+	err = b.utxoCache.maybeFlush(&node.hash, FlushPeriodic)
+	if err != nil {
+		return err
+	}
+
+	// Delete the oldest blocks when pruning is enabled and the stored
+	// blocks exceed the prune target.
+	if b.pruneTarget != 0 {
+		if err := b.pruneBlocks(); err != nil {
+			return err
+		}
+	}
+
 	// Notify the caller that the block was connected to the main chain.
 	// The caller would typically want to react with actions such as
 	// updating wallets.
@@ -723,10 +781,20 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *btcutil.Block, view
 			return err
 		}
 
-		// Update the utxo set using the state of the utxo view.  This
-		// entails restoring all of the utxos spent and removing the new
-		// ones created by the block.
-		err = dbPutUtxoView(dbTx, view)
+		// Write the utxo cache followed by the state of the utxo view,
+		// which restores all of the utxos spent and removes the new
+		// ones created by the block, to the utxo set.  Unlike when
+		// connecting blocks, the utxos are always written out along
+		// with the rest of the state since the spend journal entry for
+		// the block is removed and would no longer be available to
+		// reconstruct the utxo set after an unclean shutdown.  The
+		// cache is left untouched should the transaction fail.
+		err = b.utxoCache.dbPutCachedEntries(dbTx, &prevNode.hash)
+		if err != nil {
+			return err
+		}
+		err = dbPutUtxoEntries(dbTx, b.utxoCache.bucketName,
+			view.entries)
 		if err != nil {
 			return err
 		}
@@ -761,6 +829,10 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *btcutil.Block, view
 		return err
 	}
 
+	// The utxo set in the database now reflects the cache along with the
+	// view, so reset it.
+	b.utxoCache.markFlushed(&prevNode.hash)
+
 	// Prune fully spent entries and mark all entries in the view unmodified
 	// now that the modifications have been committed to the database.
 	view.commit()
@@ -837,10 +909,34 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		}
 	}
 
+	// The base block of a loaded utxo snapshot and the blocks below it
+	// can't be disconnected since there are no spend journal entries for
+	// them.
+	if b.snapshot != nil && detachNodes.Len() != 0 {
+		lastDetachNode := detachNodes.Back().Value.(*blockNode)
+		if lastDetachNode.height <= b.snapshot.base.height {
+			return fmt.Errorf("unable to reorganize the chain to a "+
+				"fork at height %d before the utxo snapshot at "+
+				"height %d", lastDetachNode.parent.height,
+				b.snapshot.base.height)
+		}
+	}
+
 	// Track the old and new best chains heads.
 	oldBest := tip
 	newBest := tip
 
+	// Flush the utxo cache before disconnecting any blocks so the utxo set
+	// in the database is current.  This is required since resurrecting
+	// spent outputs with legacy spend journal entries looks up the
+	// containing transaction directly in the database.
+	if detachNodes.Len() != 0 {
+		err := b.utxoCache.maybeFlush(&tip.hash, FlushRequired)
+		if err != nil {
+			return err
+		}
+	}
+
 	// All of the blocks to detach and related spend journal entries needed
 	// to unspend transaction outputs in the blocks being disconnected must
 	// be loaded from the database during the reorg check phase below and
@@ -875,7 +971,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 
 		// Load all of the utxos referenced by the block that aren't
 		// already in the view.
-		err = view.fetchInputUtxos(b.db, block)
+		err = view.fetchInputUtxos(b.utxoCache, block)
 		if err != nil {
 			return err
 		}
@@ -895,7 +991,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		detachBlocks = append(detachBlocks, block)
 		detachSpentTxOuts = append(detachSpentTxOuts, stxos)
 
-		err = view.disconnectTransactions(b.db, block, stxos)
+		err = view.disconnectTransactions(b.utxoCache, block, stxos)
 		if err != nil {
 			return err
 		}
@@ -942,7 +1038,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		// checkConnectBlock gets skipped, we still need to update the UTXO
 		// view.
 		if b.index.NodeStatus(n).KnownValid() {
-			err = view.fetchInputUtxos(b.db, block)
+			err = view.fetchInputUtxos(b.utxoCache, block)
 			if err != nil {
 				return err
 			}
@@ -963,7 +1059,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		// In the case the block is determined to be invalid due to a
 		// rule violation, mark it as invalid and mark all of its
 		// descendants as having an invalid ancestor.
-		err = b.checkConnectBlock(n, block, view, nil)
+		err = b.checkConnectBlock(n, block, b.utxoCache, view, nil)
 		if err != nil {
 			if _, ok := err.(RuleError); ok {
 				b.index.SetStatusFlags(n, statusValidateFailed)
@@ -994,14 +1090,14 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 
 		// Load all of the utxos referenced by the block that aren't
 		// already in the view.
-		err := view.fetchInputUtxos(b.db, block)
+		err := view.fetchInputUtxos(b.utxoCache, block)
 		if err != nil {
 			return err
 		}
 
 		// Update the view to unspend all of the spent txos and remove
 		// the utxos created by the block.
-		err = view.disconnectTransactions(b.db, block,
+		err = view.disconnectTransactions(b.utxoCache, block,
 			detachSpentTxOuts[i])
 		if err != nil {
 			return err
@@ -1021,7 +1117,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 
 		// Load all of the utxos referenced by the block that aren't
 		// already in the view.
-		err := view.fetchInputUtxos(b.db, block)
+		err := view.fetchInputUtxos(b.utxoCache, block)
 		if err != nil {
 			return err
 		}
@@ -1099,7 +1195,8 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 		view.SetBestHash(parentHash)
 		stxos := make([]SpentTxOut, 0, countSpentOutputs(block))
 		if !fastAdd {
-			err := b.checkConnectBlock(node, block, view, &stxos)
+			err := b.checkConnectBlock(node, block, b.utxoCache,
+				view, &stxos)
 			if err == nil {
 				b.index.SetStatusFlags(node, statusValid)
 			} else if _, ok := err.(RuleError); ok {
@@ -1120,7 +1217,7 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 		// utxos, spend them, and add the new utxos being created by
 		// this block.
 		if fastAdd {
-			err := view.fetchInputUtxos(b.db, block)
+			err := view.fetchInputUtxos(b.utxoCache, block)
 			if err != nil {
 				return false, err
 			}
@@ -1254,6 +1351,118 @@ func (b *BlockChain) BestSnapshot() *BestState {
 	return snapshot
 }
 
+// ChainTipStatus describes the state of the branch of the block tree that
+// ends at a chain tip.
+type ChainTipStatus int
+
+const (
+	// ChainTipActive indicates the tip is the tip of the main chain.
+	ChainTipActive ChainTipStatus = iota
+
+	// ChainTipInvalid indicates the branch contains at least one block
+	// that is known to be invalid.
+	ChainTipInvalid
+
+	// ChainTipHeadersOnly indicates the data for at least one block in the
+	// branch is not available, so the branch has never been validated.
+	ChainTipHeadersOnly
+
+	// ChainTipValidHeaders indicates the data for all blocks in the branch
+	// is available, but the branch has not been fully validated.
+	ChainTipValidHeaders
+
+	// ChainTipValidFork indicates the branch has been fully validated, but
+	// is not part of the main chain.
+	ChainTipValidFork
+)
+
+// chainTipStatusStrings is a map of chain tip statuses back to their constant
+// names for pretty printing.
+var chainTipStatusStrings = map[ChainTipStatus]string{
+	ChainTipActive:       "active",
+	ChainTipInvalid:      "invalid",
+	ChainTipHeadersOnly:  "headers-only",
+	ChainTipValidHeaders: "valid-headers",
+	ChainTipValidFork:    "valid-fork",
+}
+
+// String returns the ChainTipStatus as a human-readable name.
+func (s ChainTipStatus) String() string {
+	if str, ok := chainTipStatusStrings[s]; ok {
+		return str
+	}
+	return fmt.Sprintf("Unknown ChainTipStatus (%d)", int(s))
+}
+
+// ChainTip houses information about the tip of a branch of the block tree.
+type ChainTip struct {
+	// Height is the height of the tip.
+	Height int32
+
+	// Hash is the hash of the tip.
+	Hash chainhash.Hash
+
+	// BranchLen is the number of blocks between the tip and the point the
+	// branch forks from the main chain.  It is zero for the main chain.
+	BranchLen int32
+
+	// Status is the state of the branch.
+	Status ChainTipStatus
+}
+
+// ChainTips returns information about all known chain tips in the block index,
+// including the tip of the main chain, ordered from the highest to the lowest
+// height.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ChainTips() []ChainTip {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	nodes := b.index.ChainTips()
+	tips := make([]ChainTip, 0, len(nodes))
+	for _, node := range nodes {
+		fork := b.bestChain.FindFork(node)
+		tip := ChainTip{
+			Height:    node.height,
+			Hash:      node.hash,
+			BranchLen: node.height - fork.height,
+		}
+
+		// The status of a side chain is determined by all of the blocks
+		// in the branch after the fork point since a block is not always
+		// marked invalid when one of its ancestors is.
+		var invalid bool
+		haveData := true
+		for n := node; n != nil && n != fork; n = n.parent {
+			status := b.index.NodeStatus(n)
+			invalid = invalid || status.KnownInvalid()
+			haveData = haveData && status.HaveData()
+		}
+		switch {
+		case node == b.bestChain.Tip():
+			tip.Status = ChainTipActive
+		case invalid:
+			tip.Status = ChainTipInvalid
+		case !haveData:
+			tip.Status = ChainTipHeadersOnly
+		case b.index.NodeStatus(node).KnownValid():
+			tip.Status = ChainTipValidFork
+		default:
+			tip.Status = ChainTipValidHeaders
+		}
+		tips = append(tips, tip)
+	}
+
+	sort.Slice(tips, func(i, j int) bool {
+		if tips[i].Height != tips[j].Height {
+			return tips[i].Height > tips[j].Height
+		}
+		return tips[i].BranchLen < tips[j].BranchLen
+	})
+	return tips
+}
+
 // HeaderByHash returns the block header identified by the given hash or an
 // error if it doesn't exist. Note that this will return headers from both the
 // main and side chains.
@@ -1267,6 +1476,72 @@ func (b *BlockChain) HeaderByHash(hash *chainhash.Hash) (wire.BlockHeader, error
 	return node.Header(), nil
 }
 
+// MedianTimeByHash returns the median time of the block identified by the given
+// hash as per CalcPastMedianTime or an error if it doesn't exist.  Note that
+// this works for blocks in both the main and side chains.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) MedianTimeByHash(hash *chainhash.Hash) (time.Time, error) {
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		err := fmt.Errorf("block %s is not known", hash)
+		return time.Time{}, err
+	}
+
+	return node.CalcPastMedianTime(), nil
+}
+
+// ChainTxnsByHash returns the total number of transactions in the chain up to
+// and including the block identified by the given hash or an error if it
+// doesn't exist.  The returned total is zero when it is not known because the
+// data for the block or one of its ancestors is not available.  Note that this
+// works for blocks in both the main and side chains.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ChainTxnsByHash(hash *chainhash.Hash) (uint64, error) {
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		err := fmt.Errorf("block %s is not known", hash)
+		return 0, err
+	}
+
+	return b.index.NodeChainTxns(node), nil
+}
+
+// BlockTxCountByHash returns the number of transactions in the block identified
+// by the given hash or an error if it doesn't exist.  The count is derived from
+// the chain transaction totals when they are known and loaded from the stored
+// block otherwise, so an error is also returned when neither is available.
+// Note that this works for blocks in both the main and side chains.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) BlockTxCountByHash(hash *chainhash.Hash) (uint64, error) {
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		err := fmt.Errorf("block %s is not known", hash)
+		return 0, err
+	}
+
+	chainTxns := b.index.NodeChainTxns(node)
+	if chainTxns != 0 {
+		if node.parent == nil {
+			return chainTxns, nil
+		}
+		parentTxns := b.index.NodeChainTxns(node.parent)
+		if parentTxns != 0 {
+			return chainTxns - parentTxns, nil
+		}
+	}
+
+	var numTxns uint64
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		numTxns, err = dbFetchBlockTxCount(dbTx, hash)
+		return err
+	})
+	return numTxns, err
+}
+
 // MainChainHasBlock returns whether or not the block with the given hash is in
 // the main chain.
 //
@@ -1622,7 +1897,8 @@ type IndexManager interface {
 	// manager to initialize itself and any indexes it is managing.  The
 	// channel parameter specifies a channel the caller can close to signal
 	// that the process should be interrupted.  It can be nil if that
-	// behavior is not desired.
+	// behavior is not desired.  Indexes that are behind the main chain
+	// may continue to be caught up in the background after it returns.
 	Init(*BlockChain, <-chan struct{}) error
 
 	// ConnectBlock is invoked when a new block has been connected to the
@@ -1636,6 +1912,12 @@ type IndexManager interface {
 	// this block is also returned so indexers can clean up the prior index
 	// state for this block.
 	DisconnectBlock(database.Tx, *btcutil.Block, []SpentTxOut) error
+
+	// OldestNeededHeight returns the height of the oldest main chain block
+	// the indexes still need in order to catch up with the main chain and
+	// whether or not they need any at all.  Blocks from that height on are
+	// not pruned.
+	OldestNeededHeight() (int32, bool)
 }
 
 // Config is a descriptor which specifies the blockchain instance configuration.
@@ -1653,6 +1935,14 @@ type Config struct {
 	// This field can be nil if the caller does not desire the behavior.
 	Interrupt <-chan struct{}
 
+	// FatalError is invoked from a background goroutine when the chain
+	// encounters an error it can't recover from, such as a loaded utxo
+	// snapshot that turns out to be invalid.  The caller is expected to
+	// shut down since the chain state can no longer be trusted.
+	//
+	// This field can be nil in which case such errors are only logged.
+	FatalError func(error)
+
 	// ChainParams identifies which chain parameters the chain is associated
 	// with.
 	//
@@ -1700,6 +1990,28 @@ type Config struct {
 	// This field can be nil if the caller is not interested in using a
 	// signature cache.
 	HashCache *txscript.HashCache
+
+	// UtxoCacheMaxSize defines the maximum number of bytes the utxo cache
+	// may use before it is flushed to the database.
+	//
+	// This field can be zero in which case the cache is flushed after every
+	// block, which matches writing the utxo set directly to the database.
+	UtxoCacheMaxSize uint64
+
+	// PruneTarget is the target size in bytes of the stored blocks.  When
+	// the stored blocks exceed it, the oldest ones are deleted while
+	// keeping at least the most recent MinBlocksToKeep blocks.
+	//
+	// This field can be zero in which case blocks are never pruned.
+	PruneTarget uint64
+
+	// AssumeValid is the hash of a block whose ancestors are assumed to
+	// have valid scripts.  Script validation is skipped for the block and
+	// its ancestors while all other validation is still performed.
+	//
+	// This field can be nil in which case the scripts of all blocks are
+	// validated.
+	AssumeValid *chainhash.Hash
 }
 
 // New returns a BlockChain instance using the provided configuration details.
@@ -1741,6 +2053,8 @@ func New(config *Config) (*BlockChain, error) {
 		checkpoints:         config.Checkpoints,
 		checkpointsByHeight: checkpointsByHeight,
 		db:                  config.DB,
+		interrupt:           config.Interrupt,
+		fatalError:          config.FatalError,
 		chainParams:         params,
 		timeSource:          config.TimeSource,
 		sigCache:            config.SigCache,
@@ -1750,7 +2064,11 @@ func New(config *Config) (*BlockChain, error) {
 		blocksPerRetarget:   int32(targetTimespan / targetTimePerBlock),
 		index:               newBlockIndex(config.DB, params),
 		hashCache:           config.HashCache,
+		pruneTarget:         config.PruneTarget,
+		assumeValid:         config.AssumeValid,
 		bestChain:           newChainView(nil),
+		bestHeader:          newChainView(nil),
+		utxoCache:           newUtxoCache(config.DB, utxoSetBucketName, utxoStateConsistencyKeyName, config.UtxoCacheMaxSize),
 		orphans:             make(map[chainhash.Hash]*orphanBlock),
 		prevOrphans:         make(map[chainhash.Hash][]*orphanBlock),
 		warningCaches:       newThresholdCaches(vbNumBits),
@@ -1783,6 +2101,27 @@ func New(config *Config) (*BlockChain, error) {
 		return nil, err
 	}
 
+	// Load the state of any previously pruned blocks.
+	if err := b.initPruneState(); err != nil {
+		return nil, err
+	}
+
+	// Load the state of a previously loaded utxo snapshot.
+	if err := b.initSnapshotState(); err != nil {
+		return nil, err
+	}
+
+	// Make sure the utxo set is consistent with the best chain, replaying
+	// any blocks that were connected since the last utxo cache flush in
+	// the case of an unclean shutdown.
+	if err := b.initConsistentState(config.Interrupt); err != nil {
+		return nil, err
+	}
+
+	// Resume validating the blocks below a loaded utxo snapshot in the
+	// background.
+	b.startSnapshotValidation()
+
 	// Initialize and catch up all of the currently active optional indexes
 	// as needed.
 	if config.IndexManager != nil {
@@ -1797,6 +2136,24 @@ func New(config *Config) (*BlockChain, error) {
 		return nil, err
 	}
 
+	// Find the best known header chain and connect any blocks on it that
+	// were downloaded before the last shutdown, but not connected yet.
+	b.chainLock.Lock()
+	b.resetBestHeader()
+	err := b.connectDownloadedBlocks(BFNone)
+	b.chainLock.Unlock()
+	if err != nil {
+		if _, ok := err.(RuleError); !ok {
+			return nil, err
+		}
+		log.Warnf("Unable to connect downloaded blocks: %v", err)
+	}
+
+	if b.assumeValid != nil {
+		log.Infof("Assuming ancestors of block %v have valid scripts",
+			b.assumeValid)
+	}
+
 	bestNode := b.bestChain.Tip()
 	log.Infof("Chain state (height %d, hash %v, totaltx %d, work %v)",
 		bestNode.height, bestNode.hash, b.stateSnapshot.TotalTxns,
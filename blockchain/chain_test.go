@@ -964,3 +964,68 @@ func TestIntervalBlockHashes(t *testing.T) {
 		}
 	}
 }
+
+// TestChainTips ensures the chain tips reported for the block index have the
+// expected heights, branch lengths, and statuses.
+func TestChainTips(t *testing.T) {
+	// Construct a synthetic block chain with a block index consisting of
+	// the following structure.
+	// 	genesis -> 1 -> 2 -> ... -> 15 -> 16  -> 17  -> 18
+	// 	                 \           \     \     \-> 18d (no data)
+	// 	                  \           \     \-> 16a -> 17a -> 18a (valid)
+	// 	                   \           \-> 11b -> 12b (unvalidated)
+	// 	                    \-> 6c (invalid) -> 7c
+	tip := tstTip
+	chain := newFakeChain(&chaincfg.MainNetParams)
+	branch0Nodes := chainedNodes(chain.bestChain.Genesis(), 18)
+	branch1Nodes := chainedNodes(branch0Nodes[14], 3)
+	branch2Nodes := chainedNodes(branch0Nodes[9], 2)
+	branch3Nodes := chainedNodes(branch0Nodes[4], 2)
+	branch4Nodes := chainedNodes(branch0Nodes[16], 1)
+	for _, nodes := range [][]*blockNode{branch0Nodes, branch1Nodes} {
+		for _, node := range nodes {
+			chain.index.SetStatusFlags(node, statusDataStored|statusValid)
+			chain.index.AddNode(node)
+		}
+	}
+	for _, node := range append(branch2Nodes, branch3Nodes...) {
+		chain.index.SetStatusFlags(node, statusDataStored)
+		chain.index.AddNode(node)
+	}
+	chain.index.SetStatusFlags(branch3Nodes[0], statusValidateFailed)
+	for _, node := range branch4Nodes {
+		chain.index.AddNode(node)
+	}
+	chain.bestChain.SetTip(tip(branch0Nodes))
+
+	want := []ChainTip{{
+		Height:    18,
+		Hash:      tip(branch0Nodes).hash,
+		BranchLen: 0,
+		Status:    ChainTipActive,
+	}, {
+		Height:    18,
+		Hash:      tip(branch4Nodes).hash,
+		BranchLen: 1,
+		Status:    ChainTipHeadersOnly,
+	}, {
+		Height:    18,
+		Hash:      tip(branch1Nodes).hash,
+		BranchLen: 3,
+		Status:    ChainTipValidFork,
+	}, {
+		Height:    12,
+		Hash:      tip(branch2Nodes).hash,
+		BranchLen: 2,
+		Status:    ChainTipValidHeaders,
+	}, {
+		Height:    7,
+		Hash:      tip(branch3Nodes).hash,
+		BranchLen: 2,
+		Status:    ChainTipInvalid,
+	}}
+	got := chain.ChainTips()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected chain tips -- got %+v, want %+v", got, want)
+	}
+}
@@ -67,6 +67,24 @@ var (
 	// unspent transaction output set.
 	utxoSetBucketName = []byte("utxosetv2")
 
+	// utxoStateConsistencyKeyName is the name of the db key used to store
+	// the hash of the block the utxo set in the database is consistent
+	// with.
+	utxoStateConsistencyKeyName = []byte("utxostateconsistency")
+
+	// snapshotUtxoSetBucketName is the name of the db bucket used to house
+	// the unspent transaction output set loaded from a utxo snapshot.
+	snapshotUtxoSetBucketName = []byte("utxosetsnapshot")
+
+	// snapshotStateKeyName is the name of the db key used to store the
+	// base block and validation status of a loaded utxo snapshot.
+	snapshotStateKeyName = []byte("utxosnapshotstate")
+
+	// bgUtxoStateKeyName is the name of the db key used to store the hash
+	// of the block the utxo set used to validate the blocks below a loaded
+	// utxo snapshot in the background is consistent with.
+	bgUtxoStateKeyName = []byte("bgutxostateconsistency")
+
 	// byteOrder is the preferred byte order used for serializing numeric
 	// fields for storage in the database.
 	byteOrder = binary.LittleEndian
@@ -692,17 +710,18 @@ func deserializeUtxoEntry(serialized []byte) (*UtxoEntry, error) {
 	return entry, nil
 }
 
-// dbFetchUtxoEntryByHash attempts to find and fetch a utxo for the given hash.
-// It uses a cursor and seek to try and do this as efficiently as possible.
+// dbFetchUtxoEntryByHash attempts to find and fetch a utxo for the given hash
+// from the utxo set bucket with the passed name.  It uses a cursor and seek to
+// try and do this as efficiently as possible.
 //
 // When there are no entries for the provided hash, nil will be returned for the
 // both the entry and the error.
-func dbFetchUtxoEntryByHash(dbTx database.Tx, hash *chainhash.Hash) (*UtxoEntry, error) {
+func dbFetchUtxoEntryByHash(dbTx database.Tx, bucketName []byte, hash *chainhash.Hash) (*UtxoEntry, error) {
 	// Attempt to find an entry by seeking for the hash along with a zero
 	// index.  Due to the fact the keys are serialized as <hash><index>,
 	// where the index uses an MSB encoding, if there are any entries for
 	// the hash at all, one will be found.
-	cursor := dbTx.Metadata().Bucket(utxoSetBucketName).Cursor()
+	cursor := dbTx.Metadata().Bucket(bucketName).Cursor()
 	key := outpointKey(wire.OutPoint{Hash: *hash, Index: 0})
 	ok := cursor.Seek(*key)
 	recycleOutpointKey(key)
@@ -725,15 +744,15 @@ func dbFetchUtxoEntryByHash(dbTx database.Tx, hash *chainhash.Hash) (*UtxoEntry,
 }
 
 // dbFetchUtxoEntry uses an existing database transaction to fetch the specified
-// transaction output from the utxo set.
+// transaction output from the utxo set housed in the passed bucket.
 //
 // When there is no entry for the provided output, nil will be returned for both
 // the entry and the error.
-func dbFetchUtxoEntry(dbTx database.Tx, outpoint wire.OutPoint) (*UtxoEntry, error) {
+func dbFetchUtxoEntry(dbTx database.Tx, bucketName []byte, outpoint wire.OutPoint) (*UtxoEntry, error) {
 	// Fetch the unspent transaction output information for the passed
 	// transaction output.  Return now when there is no entry.
 	key := outpointKey(outpoint)
-	utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+	utxoBucket := dbTx.Metadata().Bucket(bucketName)
 	serializedUtxo := utxoBucket.Get(*key)
 	recycleOutpointKey(key)
 	if serializedUtxo == nil {
@@ -766,13 +785,12 @@ func dbFetchUtxoEntry(dbTx database.Tx, outpoint wire.OutPoint) (*UtxoEntry, err
 	return entry, nil
 }
 
-// dbPutUtxoView uses an existing database transaction to update the utxo set
-// in the database based on the provided utxo view contents and state.  In
-// particular, only the entries that have been marked as modified are written
-// to the database.
-func dbPutUtxoView(dbTx database.Tx, view *UtxoViewpoint) error {
-	utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
-	for outpoint, entry := range view.entries {
+// dbPutUtxoEntries uses an existing database transaction to update the utxo
+// set housed in the passed bucket with the provided entries.  Only the entries
+// that have been marked as modified are written and spent entries are removed.
+func dbPutUtxoEntries(dbTx database.Tx, bucketName []byte, entries map[wire.OutPoint]*UtxoEntry) error {
+	utxoBucket := dbTx.Metadata().Bucket(bucketName)
+	for outpoint, entry := range entries {
 		// No need to update the database if the entry was not modified.
 		if entry == nil || !entry.isModified() {
 			continue
@@ -809,6 +827,49 @@ func dbPutUtxoView(dbTx database.Tx, view *UtxoViewpoint) error {
 	return nil
 }
 
+// -----------------------------------------------------------------------------
+// The utxo state consistency status is stored as the hash of the block the
+// utxo set in the database reflects.  Since the utxo cache only writes to the
+// database periodically, this may lag behind the best chain state in which case
+// the blocks after it are replayed on startup to bring the utxo set back in
+// line with the best chain.
+//
+// The serialized format is:
+//
+//   <block hash>
+//
+//   Field             Type             Size
+//   block hash        chainhash.Hash   chainhash.HashSize
+// -----------------------------------------------------------------------------
+
+// dbPutUtxoStateConsistency uses an existing database transaction to update
+// the utxo state consistency status stored under the passed key with the given
+// block hash.
+func dbPutUtxoStateConsistency(dbTx database.Tx, keyName []byte, hash *chainhash.Hash) error {
+	return dbTx.Metadata().Put(keyName, hash[:])
+}
+
+// dbFetchUtxoStateConsistency uses an existing database transaction to
+// retrieve the utxo state consistency status stored under the passed key from
+// the database.  It returns nil when there is no status stored, which is the
+// case for databases created before the utxo cache existed.
+func dbFetchUtxoStateConsistency(dbTx database.Tx, keyName []byte) (*chainhash.Hash, error) {
+	serialized := dbTx.Metadata().Get(keyName)
+	if serialized == nil {
+		return nil, nil
+	}
+	if len(serialized) != chainhash.HashSize {
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt utxo state consistency status",
+		}
+	}
+
+	var hash chainhash.Hash
+	copy(hash[:], serialized)
+	return &hash, nil
+}
+
 // -----------------------------------------------------------------------------
 // The block index consists of two buckets with an entry for every block in the
 // main chain.  One bucket is for the hash to height mapping and the other is
@@ -879,6 +940,18 @@ func dbFetchHeightByHash(dbTx database.Tx, hash *chainhash.Hash) (int32, error)
 	return int32(byteOrder.Uint32(serializedHeight)), nil
 }
 
+// DBMainChainHasBlock uses an existing database transaction to return whether
+// or not the block with the given hash is in the main chain as of the last
+// block connected or disconnected by a database transaction.
+//
+// Unlike MainChainHasBlock, the result is consistent with the passed database
+// transaction and no chain locks are acquired, so it is safe to call from
+// within a database transaction that runs concurrently with the chain.
+func DBMainChainHasBlock(dbTx database.Tx, hash *chainhash.Hash) bool {
+	_, err := dbFetchHeightByHash(dbTx, hash)
+	return err == nil
+}
+
 // dbFetchHashByHeight uses an existing database transaction to retrieve the
 // hash for the provided height from the index.
 func dbFetchHashByHeight(dbTx database.Tx, height int32) (*chainhash.Hash, error) {
@@ -1009,6 +1082,7 @@ func (b *BlockChain) createChainState() error {
 	header := &genesisBlock.MsgBlock().Header
 	node := newBlockNode(header, nil)
 	node.status = statusDataStored | statusValid
+	node.chainTxns = uint64(len(genesisBlock.MsgBlock().Transactions))
 	b.bestChain.SetTip(node)
 
 	// Add the new node to the index which is used for faster lookups.
@@ -1086,6 +1160,13 @@ func (b *BlockChain) createChainState() error {
 			return err
 		}
 
+		// Mark the empty utxo set as consistent with the genesis block.
+		err = dbPutUtxoStateConsistency(dbTx,
+			utxoStateConsistencyKeyName, &node.hash)
+		if err != nil {
+			return err
+		}
+
 		// Store the current best chain state into the database.
 		err = dbPutBestState(dbTx, b.stateSnapshot, node.workSum)
 		if err != nil {
@@ -1151,9 +1232,11 @@ func (b *BlockChain) initChainState() error {
 
 		var i int32
 		var lastNode *blockNode
+		var missingTxns []*blockNode
 		cursor := blockIndexBucket.Cursor()
 		for ok := cursor.First(); ok; ok = cursor.Next() {
-			header, status, err := deserializeBlockRow(cursor.Value())
+			header, status, chainTxns, err := deserializeBlockRow(
+				cursor.Value())
 			if err != nil {
 				return err
 			}
@@ -1187,8 +1270,15 @@ func (b *BlockChain) initChainState() error {
 			node := new(blockNode)
 			initBlockNode(node, header, parent)
 			node.status = status
+			node.chainTxns = chainTxns
 			b.index.addNode(node)
 
+			// Keep track of the blocks that were stored before the
+			// total number of transactions in the chain was tracked.
+			if chainTxns == 0 && status.HaveData() {
+				missingTxns = append(missingTxns, node)
+			}
+
 			lastNode = node
 			i++
 		}
@@ -1201,27 +1291,67 @@ func (b *BlockChain) initChainState() error {
 		}
 		b.bestChain.SetTip(tip)
 
-		// Load the raw block bytes for the best block.
-		blockBytes, err := dbTx.FetchBlock(&state.hash)
-		if err != nil {
-			return err
+		// Determine the total number of transactions in the chain for
+		// the blocks that were stored before it was tracked.  The total
+		// for the best block is known from the best chain state, so
+		// work backwards from it through the blocks that are still
+		// stored first.  That way the total is also known for the
+		// blocks of a pruned node whose ancestors are no longer stored.
+		// The remaining blocks are then handled in order of height.
+		if tip.chainTxns == 0 {
+			b.index.SetChainTxns(tip, state.totalTxns)
 		}
-		var block wire.MsgBlock
-		err = block.Deserialize(bytes.NewReader(blockBytes))
-		if err != nil {
-			return err
+		for node := tip; node.parent != nil && node.parent.chainTxns == 0 &&
+			node.status.HaveData(); node = node.parent {
+
+			numTxns, err := dbFetchBlockTxCount(dbTx, &node.hash)
+			if err != nil {
+				return err
+			}
+			b.index.SetChainTxns(node.parent, node.chainTxns-numTxns)
+		}
+		for _, node := range missingTxns {
+			if node.chainTxns != 0 {
+				continue
+			}
+			numTxns, err := dbFetchBlockTxCount(dbTx, &node.hash)
+			if err != nil {
+				return err
+			}
+			b.index.ConnectChainTxns(node, int(numTxns))
+		}
+
+		// Load the raw block bytes for the best block.  The data for
+		// the base block of a loaded utxo snapshot is not available
+		// until it is downloaded, in which case its size is unknown.
+		var blockSize, blockWeight, numTxns uint64
+		if tip.status.HaveData() {
+			blockBytes, err := dbTx.FetchBlock(&state.hash)
+			if err != nil {
+				return err
+			}
+			var block wire.MsgBlock
+			err = block.Deserialize(bytes.NewReader(blockBytes))
+			if err != nil {
+				return err
+			}
+
+			blockSize = uint64(len(blockBytes))
+			blockWeight = uint64(GetBlockWeight(btcutil.NewBlock(&block)))
+			numTxns = uint64(len(block.Transactions))
 		}
 
 		// As a final consistency check, we'll run through all the
 		// nodes which are ancestors of the current chain tip, and mark
 		// them as valid if they aren't already marked as such.  This
 		// is a safe assumption as all the block before the current tip
-		// are valid by definition.
+		// are valid by definition.  The exception are the blocks below
+		// a loaded utxo snapshot which have not been downloaded yet.
 		for iterNode := tip; iterNode != nil; iterNode = iterNode.parent {
 			// If this isn't already marked as valid in the index, then
 			// we'll mark it as valid now to ensure consistency once
 			// we're up and running.
-			if !iterNode.status.KnownValid() {
+			if !iterNode.status.KnownValid() && iterNode.status.HaveData() {
 				log.Infof("Block %v (height=%v) ancestor of "+
 					"chain tip not marked as valid, "+
 					"upgrading to valid for consistency",
@@ -1232,9 +1362,6 @@ func (b *BlockChain) initChainState() error {
 		}
 
 		// Initialize the state related to the best block.
-		blockSize := uint64(len(blockBytes))
-		blockWeight := uint64(GetBlockWeight(btcutil.NewBlock(&block)))
-		numTxns := uint64(len(block.Transactions))
 		b.stateSnapshot = newBestState(tip, blockSize, blockWeight,
 			numTxns, state.totalTxns, tip.CalcPastMedianTime())
 
@@ -1251,22 +1378,46 @@ func (b *BlockChain) initChainState() error {
 }
 
 // deserializeBlockRow parses a value in the block index bucket into a block
-// header and block status bitfield.
-func deserializeBlockRow(blockRow []byte) (*wire.BlockHeader, blockStatus, error) {
+// header, block status bitfield and the total number of transactions in the
+// chain up to and including the block.  The total is zero for entries that were
+// written before it was tracked.
+func deserializeBlockRow(blockRow []byte) (*wire.BlockHeader, blockStatus, uint64, error) {
 	buffer := bytes.NewReader(blockRow)
 
 	var header wire.BlockHeader
 	err := header.Deserialize(buffer)
 	if err != nil {
-		return nil, statusNone, err
+		return nil, statusNone, 0, err
 	}
 
 	statusByte, err := buffer.ReadByte()
 	if err != nil {
-		return nil, statusNone, err
+		return nil, statusNone, 0, err
+	}
+
+	var chainTxns uint64
+	if buffer.Len() >= 8 {
+		chainTxns = byteOrder.Uint64(blockRow[len(blockRow)-buffer.Len():])
 	}
 
-	return &header, blockStatus(statusByte), nil
+	return &header, blockStatus(statusByte), chainTxns, nil
+}
+
+// dbFetchBlockTxCount uses an existing database transaction to retrieve the
+// number of transactions in the block with the provided hash without loading
+// the entire block.
+func dbFetchBlockTxCount(dbTx database.Tx, hash *chainhash.Hash) (uint64, error) {
+	// The transaction count immediately follows the header and every block
+	// is large enough to contain a maximum size count.
+	region, err := dbTx.FetchBlockRegion(&database.BlockRegion{
+		Hash:   hash,
+		Offset: blockHdrSize,
+		Len:    wire.MaxVarIntPayload,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return wire.ReadVarInt(bytes.NewReader(region), 0)
 }
 
 // dbFetchHeaderByHash uses an existing database transaction to retrieve the
@@ -1317,11 +1468,12 @@ func dbFetchBlockByNode(dbTx database.Tx, node *blockNode) (*btcutil.Block, erro
 	return block, nil
 }
 
-// dbStoreBlockNode stores the block header and validation status to the block
-// index bucket. This overwrites the current entry if there exists one.
+// dbStoreBlockNode stores the block header, validation status and total number
+// of transactions in the chain up to and including the block to the block index
+// bucket. This overwrites the current entry if there exists one.
 func dbStoreBlockNode(dbTx database.Tx, node *blockNode) error {
 	// Serialize block data to be stored.
-	w := bytes.NewBuffer(make([]byte, 0, blockHdrSize+1))
+	w := bytes.NewBuffer(make([]byte, 0, blockHdrSize+9))
 	header := node.Header()
 	err := header.Serialize(w)
 	if err != nil {
@@ -1331,6 +1483,11 @@ func dbStoreBlockNode(dbTx database.Tx, node *blockNode) error {
 	if err != nil {
 		return err
 	}
+	var chainTxns [8]byte
+	byteOrder.PutUint64(chainTxns[:], node.chainTxns)
+	if _, err := w.Write(chainTxns[:]); err != nil {
+		return err
+	}
 	value := w.Bytes()
 
 	// Write block header data to block index bucket.
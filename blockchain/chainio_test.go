@@ -11,6 +11,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/database"
 	"github.com/btcsuite/btcd/wire"
 )
@@ -719,3 +720,53 @@ func TestBestChainStateDeserializeErrors(t *testing.T) {
 		}
 	}
 }
+
+// TestDeserializeBlockRow ensures block index entries are decoded as expected,
+// including entries that were written before the total number of transactions
+// in the chain was tracked.
+func TestDeserializeBlockRow(t *testing.T) {
+	t.Parallel()
+
+	header := chaincfg.MainNetParams.GenesisBlock.Header
+	var buf bytes.Buffer
+	if err := header.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	legacyRow := append(buf.Bytes(), byte(statusDataStored|statusValid))
+	row := append(append([]byte(nil), legacyRow...),
+		0x2a, 0, 0, 0, 0, 0, 0, 0)
+
+	tests := []struct {
+		name      string
+		row       []byte
+		chainTxns uint64
+	}{
+		{name: "legacy", row: legacyRow, chainTxns: 0},
+		{name: "chain txns", row: row, chainTxns: 42},
+	}
+	for _, test := range tests {
+		gotHeader, status, chainTxns, err := deserializeBlockRow(test.row)
+		if err != nil {
+			t.Errorf("deserializeBlockRow (%s): unexpected error: %v",
+				test.name, err)
+			continue
+		}
+		if gotHeader.BlockHash() != header.BlockHash() {
+			t.Errorf("deserializeBlockRow (%s): unexpected header %v",
+				test.name, gotHeader.BlockHash())
+		}
+		if status != statusDataStored|statusValid {
+			t.Errorf("deserializeBlockRow (%s): unexpected status %v",
+				test.name, status)
+		}
+		if chainTxns != test.chainTxns {
+			t.Errorf("deserializeBlockRow (%s): unexpected chain txns "+
+				"%d, want %d", test.name, chainTxns, test.chainTxns)
+		}
+	}
+
+	// A truncated header must be rejected.
+	if _, _, _, err := deserializeBlockRow(legacyRow[:40]); err == nil {
+		t.Error("deserializeBlockRow: expected error for truncated row")
+	}
+}
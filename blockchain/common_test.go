@@ -173,11 +173,12 @@ func chainSetup(dbName string, params *chaincfg.Params) (*BlockChain, func(), er
 
 	// Create the main chain instance.
 	chain, err := New(&Config{
-		DB:          db,
-		ChainParams: &paramsCopy,
-		Checkpoints: nil,
-		TimeSource:  NewMedianTime(),
-		SigCache:    txscript.NewSigCache(1000),
+		DB:               db,
+		ChainParams:      &paramsCopy,
+		Checkpoints:      nil,
+		TimeSource:       NewMedianTime(),
+		SigCache:         txscript.NewSigCache(1000),
+		UtxoCacheMaxSize: DefaultUtxoCacheMaxSize,
 	})
 	if err != nil {
 		teardown()
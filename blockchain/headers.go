@@ -0,0 +1,265 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// NeededBlock identifies a block on the best known header chain whose data has
+// not been downloaded yet.
+type NeededBlock struct {
+	Hash   chainhash.Hash
+	Height int32
+}
+
+// findBestHeader returns the node with the most cumulative work in the block
+// index that is not known to be invalid and does not have any ancestors that
+// are known to be invalid.  The data for the blocks is not required to be
+// available.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) findBestHeader() *blockNode {
+	best := b.bestChain.Tip()
+	for _, tip := range b.index.ChainTips() {
+		if tip.workSum.Cmp(best.workSum) <= 0 {
+			continue
+		}
+
+		// Find the highest node in the branch that does not have an
+		// invalid node before it.
+		fork := b.bestChain.FindFork(tip)
+		usable := tip
+		for n := tip; n != nil && n != fork; n = n.parent {
+			if b.index.NodeStatus(n).KnownInvalid() {
+				usable = n.parent
+			}
+		}
+		if usable != nil && usable.workSum.Cmp(best.workSum) > 0 {
+			best = usable
+		}
+	}
+	return best
+}
+
+// resetBestHeader sets the best header chain to the one with the most
+// cumulative work that is not known to be invalid.  It must be called whenever
+// blocks are marked invalid or have their invalid status cleared.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) resetBestHeader() {
+	b.bestHeader.SetTip(b.findBestHeader())
+}
+
+// updateBestHeader makes the passed node the tip of the best header chain when
+// it has more cumulative work than the current one.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) updateBestHeader(node *blockNode) {
+	if node.workSum.Cmp(b.bestHeader.Tip().workSum) > 0 {
+		b.bestHeader.SetTip(node)
+	}
+}
+
+// maybeAcceptBlockHeader potentially accepts the passed block header into the
+// block index and returns the block node for it.  The header must connect to a
+// known header that is not invalid and pass all of the checks that do not
+// require the block data.  Headers that are already known are not checked
+// again.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) maybeAcceptBlockHeader(header *wire.BlockHeader, flags BehaviorFlags) (*blockNode, error) {
+	hash := header.BlockHash()
+	if node := b.index.LookupNode(&hash); node != nil {
+		if b.index.NodeStatus(node).KnownInvalid() {
+			str := fmt.Sprintf("block %v is known to be invalid", hash)
+			return nil, ruleError(ErrInvalidAncestorBlock, str)
+		}
+		return node, nil
+	}
+
+	prevNode := b.index.LookupNode(&header.PrevBlock)
+	if prevNode == nil {
+		str := fmt.Sprintf("previous block %s is unknown",
+			header.PrevBlock)
+		return nil, ruleError(ErrPreviousBlockUnknown, str)
+	} else if b.index.NodeStatus(prevNode).KnownInvalid() {
+		str := fmt.Sprintf("previous block %s is known to be invalid",
+			header.PrevBlock)
+		return nil, ruleError(ErrInvalidAncestorBlock, str)
+	}
+
+	err := checkBlockHeaderSanity(header, b.chainParams.PowLimit,
+		b.timeSource, flags)
+	if err != nil {
+		return nil, err
+	}
+	err = b.checkBlockHeaderContext(header, prevNode, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add a node without any data for the header to the block index.
+	node := newBlockNode(header, prevNode)
+	b.index.AddNode(node)
+	b.updateBestHeader(node)
+	return node, nil
+}
+
+// ProcessBlockHeader validates the passed block header and adds it to the block
+// index when it connects to a known header, which makes it possible to learn
+// the chain with the most cumulative work before downloading the blocks.
+// Headers that are already known are ignored.
+//
+// The flags are passed to checkBlockHeaderSanity and checkBlockHeaderContext.
+// See their documentation for how the flags modify their behavior.
+//
+// The new block index entries are not written to the database right away, so
+// callers should call FlushBlockIndex after processing a batch of headers.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ProcessBlockHeader(header *wire.BlockHeader, flags BehaviorFlags) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	_, err := b.maybeAcceptBlockHeader(header, flags)
+	return err
+}
+
+// FlushBlockIndex writes all of the block index entries that were added or
+// modified since the last time the block index was flushed to the database.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) FlushBlockIndex() error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	return b.index.flushToDB()
+}
+
+// BestHeader returns the hash and height of the header with the most
+// cumulative work that is not known to be invalid.  This is the same as the
+// current best block when there are no headers with more work.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) BestHeader() (chainhash.Hash, int32) {
+	b.chainLock.RLock()
+	tip := b.bestHeader.Tip()
+	b.chainLock.RUnlock()
+	return tip.hash, tip.height
+}
+
+// BestHeaderLocator returns a block locator for the tip of the best header
+// chain.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) BestHeaderLocator() BlockLocator {
+	b.chainLock.RLock()
+	locator := b.bestHeader.BlockLocator(nil)
+	b.chainLock.RUnlock()
+	return locator
+}
+
+// NeededBlocks returns the blocks on the best header chain whose data is not
+// available yet in order of height.  Only blocks within window blocks after the
+// point where the best header chain forks from the main chain are considered.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) NeededBlocks(window int32) []NeededBlock {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	fork := b.bestHeader.FindFork(b.bestChain.Tip())
+	endHeight := fork.height + window
+	if tipHeight := b.bestHeader.Height(); endHeight > tipHeight {
+		endHeight = tipHeight
+	}
+
+	var needed []NeededBlock
+	for height := fork.height + 1; height <= endHeight; height++ {
+		node := b.bestHeader.NodeByHeight(height)
+		status := b.index.NodeStatus(node)
+		if status.KnownInvalid() {
+			break
+		}
+		if !status.HaveData() {
+			needed = append(needed, NeededBlock{
+				Hash:   node.hash,
+				Height: height,
+			})
+		}
+	}
+	return needed
+}
+
+// haveChainData returns whether the data for the passed node and all of its
+// ancestors that are not part of the main chain is available.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) haveChainData(node *blockNode) bool {
+	for n := node; n != nil && !b.bestChain.Contains(n); n = n.parent {
+		if !b.index.NodeStatus(n).HaveData() {
+			return false
+		}
+	}
+	return true
+}
+
+// connectDownloadedBlocks connects the blocks on the best header chain that
+// follow the current best block and were stored before the blocks they build
+// on were available, which is typically the case when blocks are downloaded
+// from multiple peers at once.
+//
+// The BFFastAdd flag is only passed along for blocks that are ancestors of the
+// latest checkpoint on the best header chain.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) connectDownloadedBlocks(flags BehaviorFlags) error {
+	checkpoint := b.LatestCheckpoint()
+	for {
+		node := b.bestHeader.Next(b.bestChain.Tip())
+		if node == nil {
+			return nil
+		}
+		status := b.index.NodeStatus(node)
+		if !status.HaveData() || status.KnownInvalid() {
+			return nil
+		}
+
+		var block *btcutil.Block
+		err := b.db.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = dbFetchBlockByNode(dbTx, node)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		blockFlags := flags &^ BFFastAdd
+		if flags&BFFastAdd == BFFastAdd && checkpoint != nil &&
+			node.height <= checkpoint.Height &&
+			b.bestHeader.Height() >= checkpoint.Height {
+
+			blockFlags |= BFFastAdd
+		}
+		_, err = b.connectBestChain(node, block, blockFlags)
+		if err != nil {
+			if _, ok := err.(RuleError); ok {
+				b.resetBestHeader()
+			}
+			return err
+		}
+
+		b.chainLock.Unlock()
+		b.sendNotification(NTBlockAccepted, block)
+		b.chainLock.Lock()
+	}
+}
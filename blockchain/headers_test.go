@@ -0,0 +1,295 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// TestHeadersFirst ensures block headers can be processed before their blocks,
+// that the best header chain is selected by work, and that blocks downloaded
+// out of order are connected once the blocks they build on are available.
+func TestHeadersFirst(t *testing.T) {
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a -> 4a -> 5a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+		"blk_4A.dat.bz2",
+		"blk_5A.dat.bz2",
+	}
+	var blocks []*btcutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v\n", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+	block1, block2, block3, block4 := blocks[1], blocks[2], blocks[3],
+		blocks[4]
+	block3A, block4A, block5A := blocks[5], blocks[6], blocks[7]
+
+	chain, teardownFunc, err := chainSetup("headersfirst",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	// A header that does not connect to a known header must be rejected.
+	orphanHeader := block5A.MsgBlock().Header
+	orphanHeader.PrevBlock = *block5A.Hash()
+	err = chain.ProcessBlockHeader(&orphanHeader, BFNone)
+	if !isRuleErrorCode(err, ErrPreviousBlockUnknown) {
+		t.Fatalf("ProcessBlockHeader: unexpected error %v", err)
+	}
+
+	for i := 1; i < len(blocks); i++ {
+		err := chain.ProcessBlockHeader(&blocks[i].MsgBlock().Header,
+			BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlockHeader fail on block %v: %v", i, err)
+		}
+	}
+
+	// The headers are only written to the database once the block index
+	// is flushed.
+	if got := len(chain.index.dirty); got != len(blocks)-1 {
+		t.Fatalf("unexpected number of unflushed headers %d", got)
+	}
+	if err := chain.FlushBlockIndex(); err != nil {
+		t.Fatalf("FlushBlockIndex: %v", err)
+	}
+	if got := len(chain.index.dirty); got != 0 {
+		t.Fatalf("unexpected number of unflushed headers %d after "+
+			"flush", got)
+	}
+
+	// checkBestHeader ensures the best header is the passed block.
+	checkBestHeader := func(desc string, block *btcutil.Block,
+		height int32) {
+
+		t.Helper()
+		hash, gotHeight := chain.BestHeader()
+		if hash != *block.Hash() || gotHeight != height {
+			t.Fatalf("%s: unexpected best header %v (height %d), "+
+				"want %v (height %d)", desc, hash, gotHeight,
+				block.Hash(), height)
+		}
+	}
+
+	// checkNeeded ensures the blocks needed within the passed window are
+	// the passed blocks.
+	checkNeeded := func(desc string, window int32, want ...*btcutil.Block) {
+		t.Helper()
+		var wantHashes, gotHashes []string
+		for _, block := range want {
+			wantHashes = append(wantHashes, block.Hash().String())
+		}
+		for _, needed := range chain.NeededBlocks(window) {
+			gotHashes = append(gotHashes, needed.Hash.String())
+		}
+		if !reflect.DeepEqual(gotHashes, wantHashes) {
+			t.Fatalf("%s: unexpected needed blocks %v, want %v",
+				desc, gotHashes, wantHashes)
+		}
+	}
+
+	// checkTip ensures the tip of the main chain is the passed block.
+	checkTip := func(desc string, block *btcutil.Block) {
+		t.Helper()
+		if got := chain.BestSnapshot().Hash; got != *block.Hash() {
+			t.Fatalf("%s: unexpected tip %v, want %v", desc, got,
+				block.Hash())
+		}
+	}
+
+	checkBestHeader("headers", block5A, 5)
+	checkNeeded("headers", 3, block1, block2, block3A)
+	if have, _ := chain.HaveBlock(block1.Hash()); have {
+		t.Fatal("HaveBlock: block with only a header is known")
+	}
+
+	// Blocks that are downloaded before their parents are stored and then
+	// connected once the parents are available.
+	for _, block := range []*btcutil.Block{block3A, block2, block1} {
+		isMainChain, isOrphan, err := chain.ProcessBlock(block, BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock of block %v: %v", block.Hash(), err)
+		}
+		if isOrphan {
+			t.Fatalf("ProcessBlock: block %v is an orphan",
+				block.Hash())
+		}
+		if isMainChain != (block == block1) {
+			t.Fatalf("ProcessBlock: block %v unexpected main chain "+
+				"status %v", block.Hash(), isMainChain)
+		}
+	}
+	checkTip("out of order", block3A)
+	checkNeeded("out of order", 10, block4A, block5A)
+
+	for _, block := range []*btcutil.Block{block5A, block4A, block3} {
+		if _, _, err := chain.ProcessBlock(block, BFNone); err != nil {
+			t.Fatalf("ProcessBlock of block %v: %v", block.Hash(), err)
+		}
+	}
+	checkTip("best header", block5A)
+	checkNeeded("best header", 10)
+
+	// Invalidating a block on the best header chain switches to the next
+	// best header chain, even when its blocks are not available yet.
+	if err := chain.InvalidateBlock(block4A.Hash()); err != nil {
+		t.Fatalf("InvalidateBlock: %v", err)
+	}
+	checkBestHeader("invalidated", block4, 4)
+	checkTip("invalidated", block3A)
+	checkNeeded("invalidated", 10, block4)
+}
+
+// TestChainTxns ensures the total number of transactions in the chain is only
+// tracked for blocks whose data and the data of all of their ancestors is
+// available, including blocks that are downloaded out of order.
+func TestChainTxns(t *testing.T) {
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a -> 4a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+		"blk_4A.dat.bz2",
+	}
+	var blocks []*btcutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v\n", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+
+	chain, teardownFunc, err := chainSetup("chaintxns",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	for i := 1; i < len(blocks); i++ {
+		err := chain.ProcessBlockHeader(&blocks[i].MsgBlock().Header,
+			BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlockHeader fail on block %v: %v", i, err)
+		}
+	}
+
+	// checkChainTxns ensures the total number of transactions in the chain
+	// up to the passed block is the passed value.
+	checkChainTxns := func(desc string, block *btcutil.Block, want uint64) {
+		t.Helper()
+		got, err := chain.ChainTxnsByHash(block.Hash())
+		if err != nil {
+			t.Fatalf("%s: ChainTxnsByHash: %v", desc, err)
+		}
+		if got != want {
+			t.Fatalf("%s: unexpected chain txns for block %v: got %d, "+
+				"want %d", desc, block.Hash(), got, want)
+		}
+	}
+
+	var wantTxns []uint64
+	var total uint64
+	for _, block := range blocks[:5] {
+		total += uint64(len(block.Transactions()))
+		wantTxns = append(wantTxns, total)
+	}
+	wantTxns3A := wantTxns[2] + uint64(len(blocks[5].Transactions()))
+	wantTxns4A := wantTxns3A + uint64(len(blocks[6].Transactions()))
+
+	checkChainTxns("genesis", blocks[0], wantTxns[0])
+	checkChainTxns("headers only", blocks[1], 0)
+
+	// The total is not known for a block that is stored before its parent
+	// and becomes known once the parent is connected.
+	if _, _, err := chain.ProcessBlock(blocks[2], BFNone); err != nil {
+		t.Fatalf("ProcessBlock of block 2: %v", err)
+	}
+	checkChainTxns("out of order", blocks[2], 0)
+
+	// The number of transactions in a block is loaded from the stored block
+	// when the totals are not known and derived from them otherwise.
+	checkBlockTxCount := func(desc string, block *btcutil.Block) {
+		t.Helper()
+		got, err := chain.BlockTxCountByHash(block.Hash())
+		if err != nil {
+			t.Fatalf("%s: BlockTxCountByHash: %v", desc, err)
+		}
+		if want := uint64(len(block.Transactions())); got != want {
+			t.Fatalf("%s: unexpected tx count for block %v: got %d, "+
+				"want %d", desc, block.Hash(), got, want)
+		}
+	}
+	checkBlockTxCount("out of order", blocks[2])
+	if _, err := chain.BlockTxCountByHash(blocks[1].Hash()); err == nil {
+		t.Fatal("BlockTxCountByHash: no error for headers only block")
+	}
+	if _, _, err := chain.ProcessBlock(blocks[1], BFNone); err != nil {
+		t.Fatalf("ProcessBlock of block 1: %v", err)
+	}
+	checkChainTxns("parent connected", blocks[1], wantTxns[1])
+	checkChainTxns("descendant connected", blocks[2], wantTxns[2])
+	checkBlockTxCount("genesis", blocks[0])
+	checkBlockTxCount("descendant connected", blocks[2])
+
+	// Blocks on side chains are tracked as well, including the ones that
+	// are stored before their parents.
+	for _, block := range []*btcutil.Block{blocks[3], blocks[4], blocks[6],
+		blocks[5]} {
+
+		if _, _, err := chain.ProcessBlock(block, BFNone); err != nil {
+			t.Fatalf("ProcessBlock of block %v: %v", block.Hash(), err)
+		}
+	}
+	checkChainTxns("main chain", blocks[4], wantTxns[4])
+	checkChainTxns("side chain", blocks[5], wantTxns3A)
+	checkChainTxns("side chain descendant", blocks[6], wantTxns4A)
+	if got := chain.BestSnapshot().TotalTxns; got != wantTxns[4] {
+		t.Fatalf("unexpected best state total txns %d, want %d", got,
+			wantTxns[4])
+	}
+
+	// The totals are determined when the chain is loaded for blocks that
+	// were stored before they were tracked, even when the data of some of
+	// them is no longer available.
+	for _, block := range blocks {
+		node := chain.index.LookupNode(block.Hash())
+		chain.index.SetChainTxns(node, 0)
+	}
+	chain.index.UnsetStatusFlags(chain.index.LookupNode(blocks[1].Hash()),
+		statusDataStored)
+	if err := chain.FlushBlockIndex(); err != nil {
+		t.Fatalf("FlushBlockIndex: %v", err)
+	}
+	chain, err = New(&Config{
+		DB:               chain.db,
+		ChainParams:      &chaincfg.MainNetParams,
+		TimeSource:       NewMedianTime(),
+		UtxoCacheMaxSize: DefaultUtxoCacheMaxSize,
+	})
+	if err != nil {
+		t.Fatalf("Failed to reload chain instance: %v", err)
+	}
+	for i, want := range wantTxns {
+		checkChainTxns("reloaded", blocks[i], want)
+	}
+	checkChainTxns("reloaded side chain", blocks[5], wantTxns3A)
+	checkChainTxns("reloaded side chain descendant", blocks[6], wantTxns4A)
+}
@@ -7,6 +7,7 @@ package indexers
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/btcsuite/btcd/blockchain"
@@ -633,6 +634,9 @@ var _ Indexer = (*AddrIndex)(nil)
 // Ensure the AddrIndex type implements the NeedsInputser interface.
 var _ NeedsInputser = (*AddrIndex)(nil)
 
+// Ensure the AddrIndex type implements the Checker interface.
+var _ Checker = (*AddrIndex)(nil)
+
 // NeedsInputs signals that the index requires the referenced inputs in order
 // to properly create the index.
 //
@@ -809,6 +813,68 @@ func (idx *AddrIndex) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
 	return nil
 }
 
+// CheckBlock verifies that every address involved in the transactions of the
+// passed block maps to each of the transactions that involve it.
+//
+// This is part of the Checker interface.
+func (idx *AddrIndex) CheckBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) ([]string, error) {
+
+	// The entries are keyed by the internal block ID that is maintained
+	// by the transaction index.
+	blockID, err := dbFetchBlockIDByHash(dbTx, block.Hash())
+	if err == errNoBlockIDEntry {
+		return []string{"missing block ID entry"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	txLocs, err := block.TxLoc()
+	if err != nil {
+		return nil, err
+	}
+
+	// Build all of the address to transaction mappings in a local map.
+	addrsToTxns := make(writeIndexData)
+	idx.indexBlock(addrsToTxns, block, stxos)
+
+	var discrepancies []string
+	bucket := dbTx.Metadata().Bucket(addrIndexKey)
+	for addrKey, txIdxs := range addrsToTxns {
+		// Collect all of the serialized entries stored for the address
+		// across all of its levels.
+		entries := make(map[[txEntrySize]byte]struct{})
+		for level := uint8(0); ; level++ {
+			levelKey := keyForLevel(addrKey, level)
+			levelData := bucket.Get(levelKey[:])
+			if levelData == nil {
+				break
+			}
+			for len(levelData) >= txEntrySize {
+				var entry [txEntrySize]byte
+				copy(entry[:], levelData)
+				entries[entry] = struct{}{}
+				levelData = levelData[txEntrySize:]
+			}
+		}
+
+		for _, txIdx := range txIdxs {
+			var entry [txEntrySize]byte
+			copy(entry[:], serializeAddrIndexEntry(blockID, txLocs[txIdx]))
+			if _, ok := entries[entry]; !ok {
+				discrepancies = append(discrepancies, fmt.Sprintf(
+					"address key %x does not map to "+
+						"transaction %v", addrKey,
+					block.Transactions()[txIdx].Hash()))
+			}
+		}
+	}
+
+	// Sort the discrepancies since the map iteration order is random.
+	sort.Strings(discrepancies)
+	return discrepancies, nil
+}
+
 // TxRegionsForAddress returns a slice of block regions which identify each
 // transaction that involves the passed address according to the specified
 // number to skip, number requested, and whether or not the results should be
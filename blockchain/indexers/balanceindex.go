@@ -0,0 +1,524 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// balanceIndexName is the human-readable name for the index.
+	balanceIndexName = "balance index"
+
+	// balanceKeySize is the size of the key of an output entry.  It
+	// consists of the 32 bytes script hash + 4 bytes block height + 32
+	// bytes transaction hash + 4 bytes output index.
+	balanceKeySize = chainhash.HashSize + 4 + chainhash.HashSize + 4
+
+	// balanceUnspentEntrySize is the size of the value of an output entry
+	// for an output that is not spent.  It consists of the 8 bytes amount.
+	balanceUnspentEntrySize = 8
+
+	// balanceSpentEntrySize is the size of the value of an output entry for
+	// an output that is spent.  It consists of the 8 bytes amount + 4 bytes
+	// spending block height + 32 bytes spending transaction hash.
+	balanceSpentEntrySize = 8 + 4 + chainhash.HashSize
+)
+
+var (
+	// balanceIndexKey is the key of the balance index and the db bucket
+	// used to house it.
+	balanceIndexKey = []byte("balanceidx")
+)
+
+// -----------------------------------------------------------------------------
+// The balance index records every output in the main chain that pays to a
+// public key script, keyed by the hash of the script as returned by ScriptHash,
+// along with its amount and, once it is spent, the height of the block and the
+// transaction that spend it.  In other words, every entry describes a credit to
+// the script and, when spent, the matching debit.  Entries are kept after their
+// output is spent so the balance, the received and sent totals and the unspent
+// outputs of a script can be determined as of any height of the main chain.
+//
+// The serialized key format is:
+//
+//   <script hash><block height><tx hash><output index>
+//
+//   Field           Type              Size
+//   script hash     chainhash.Hash    32 bytes
+//   block height    uint32 (BE)       4 bytes
+//   tx hash         chainhash.Hash    32 bytes
+//   output index    uint32            4 bytes
+//   -----
+//   Total: 72 bytes
+//
+// The block height is the height of the block that contains the transaction
+// that created the output.  It is big endian so the entries of a script hash
+// are iterated by the height of the block that created the outputs, which
+// allows queries as of a given height to stop at the first entry above it.
+//
+// The serialized value format is:
+//
+//   <amount>[<spending block height><spending tx hash>]
+//
+//   Field                  Type              Size
+//   amount                 uint64            8 bytes
+//   spending block height  uint32            4 bytes
+//   spending tx hash       chainhash.Hash    32 bytes
+//   -----
+//   Total: 8 bytes when unspent, 44 bytes when spent
+//
+// Provably unspendable outputs and the outputs of the genesis block are not
+// indexed since they can never be spent.
+// -----------------------------------------------------------------------------
+
+// ScriptOutput describes an output in the main chain that pays to a script
+// hash as of a given height.
+type ScriptOutput struct {
+	// OutPoint identifies the output.
+	OutPoint wire.OutPoint
+
+	// Height is the height of the block that contains the transaction
+	// that created the output.
+	Height int32
+
+	// Amount is the value of the output in satoshi.
+	Amount int64
+
+	// SpendHeight is the height of the block that contains the transaction
+	// that spends the output.  It is -1 when the output is not spent as of
+	// the height the output was fetched for.
+	SpendHeight int32
+
+	// SpendingTxHash is the hash of the transaction that spends the output.
+	// It is only set when the output is spent as of the height the output
+	// was fetched for.
+	SpendingTxHash chainhash.Hash
+}
+
+// ScriptBalance describes the balance of a script hash as of a given height.
+// All amounts are in satoshi.
+type ScriptBalance struct {
+	// Balance is the sum of the unspent outputs that pay to the script
+	// hash.
+	Balance int64
+
+	// Received is the sum of all outputs that pay to the script hash.
+	Received int64
+
+	// Sent is the sum of the outputs that pay to the script hash which
+	// are spent.
+	Sent int64
+
+	// NumUnspent is the number of unspent outputs that pay to the script
+	// hash.
+	NumUnspent int
+}
+
+// balanceKey returns the key of the output entry for the provided script hash,
+// height of the block that created the output and outpoint.
+func balanceKey(scriptHash *chainhash.Hash, height int32, outpoint *wire.OutPoint) []byte {
+	key := make([]byte, balanceKeySize)
+	copy(key, scriptHash[:])
+	binary.BigEndian.PutUint32(key[chainhash.HashSize:], uint32(height))
+	copy(key[chainhash.HashSize+4:], outpoint.Hash[:])
+	byteOrder.PutUint32(key[2*chainhash.HashSize+4:], outpoint.Index)
+	return key
+}
+
+// serializeBalanceEntry returns the value of an output entry for the provided
+// amount.  The passed spending transaction hash must be nil for an output that
+// is not spent.
+func serializeBalanceEntry(amount int64, spendHeight int32, spendingTxHash *chainhash.Hash) []byte {
+	if spendingTxHash == nil {
+		value := make([]byte, balanceUnspentEntrySize)
+		byteOrder.PutUint64(value, uint64(amount))
+		return value
+	}
+
+	value := make([]byte, balanceSpentEntrySize)
+	byteOrder.PutUint64(value, uint64(amount))
+	byteOrder.PutUint32(value[8:], uint32(spendHeight))
+	copy(value[12:], spendingTxHash[:])
+	return value
+}
+
+// deserializeBalanceEntry decodes the passed key and value of an output entry
+// into the output it describes.
+func deserializeBalanceEntry(key, value []byte) (*ScriptOutput, error) {
+	if len(key) != balanceKeySize || (len(value) != balanceUnspentEntrySize &&
+		len(value) != balanceSpentEntrySize) {
+
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt balance index entry "+
+				"for %x", key),
+		}
+	}
+
+	output := ScriptOutput{
+		Height:      int32(binary.BigEndian.Uint32(key[chainhash.HashSize:])),
+		Amount:      int64(byteOrder.Uint64(value)),
+		SpendHeight: -1,
+	}
+	copy(output.OutPoint.Hash[:], key[chainhash.HashSize+4:])
+	output.OutPoint.Index = byteOrder.Uint32(key[2*chainhash.HashSize+4:])
+	if len(value) == balanceSpentEntrySize {
+		output.SpendHeight = int32(byteOrder.Uint32(value[8:]))
+		copy(output.SpendingTxHash[:], value[12:])
+	}
+	return &output, nil
+}
+
+// dbUpdateBalanceIndex uses an existing database transaction to apply the
+// changes the passed block makes to the balance index.  When connect is false,
+// the changes are undone instead.
+//
+// The spent outputs must be in the order the inputs of the block that spend
+// them appear.
+func dbUpdateBalanceIndex(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut, connect bool) error {
+
+	bucket := dbTx.Metadata().Bucket(balanceIndexKey)
+	height := block.Height()
+	return forEachBlockTx(block, stxos, connect, func(txIdx int,
+		tx *btcutil.Tx, txStxos []blockchain.SpentTxOut) error {
+
+		// Mark the outputs spent by the transaction as spent by it, or
+		// as unspent again when undoing.
+		for i, txIn := range tx.MsgTx().TxIn[:len(txStxos)] {
+			stxo := &txStxos[i]
+			scriptHash := ScriptHash(stxo.PkScript)
+			key := balanceKey(&scriptHash, stxo.Height,
+				&txIn.PreviousOutPoint)
+			spendingTxHash := tx.Hash()
+			if !connect {
+				spendingTxHash = nil
+			}
+			err := bucket.Put(key, serializeBalanceEntry(stxo.Amount,
+				height, spendingTxHash))
+			if err != nil {
+				return err
+			}
+		}
+
+		// Add the outputs created by the transaction, or remove them
+		// when undoing.  The outputs of the genesis block are not
+		// spendable.
+		if height == 0 {
+			return nil
+		}
+		outpoint := wire.OutPoint{Hash: *tx.Hash()}
+		for i, txOut := range tx.MsgTx().TxOut {
+			if isUnspendableOutput(txOut.PkScript) {
+				continue
+			}
+			scriptHash := ScriptHash(txOut.PkScript)
+			outpoint.Index = uint32(i)
+			key := balanceKey(&scriptHash, height, &outpoint)
+			var err error
+			if connect {
+				err = bucket.Put(key, serializeBalanceEntry(
+					txOut.Value, 0, nil))
+			} else {
+				err = bucket.Delete(key)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// dbFetchScriptOutputs uses an existing database transaction to fetch the
+// outputs in the main chain that pay to the provided script hash as of the
+// provided height, ordered by the height of the block that created them.
+// Outputs that are spent above the height are reported as unspent.
+func dbFetchScriptOutputs(dbTx database.Tx, scriptHash *chainhash.Hash,
+	height int32) ([]ScriptOutput, error) {
+
+	cursor := dbTx.Metadata().Bucket(balanceIndexKey).Cursor()
+	var outputs []ScriptOutput
+	for ok := cursor.Seek(scriptHash[:]); ok; ok = cursor.Next() {
+		key := cursor.Key()
+		if !bytes.HasPrefix(key, scriptHash[:]) {
+			break
+		}
+
+		output, err := deserializeBalanceEntry(key, cursor.Value())
+		if err != nil {
+			return nil, err
+		}
+		if output.Height > height {
+			break
+		}
+		if output.SpendHeight > height {
+			output.SpendHeight = -1
+			output.SpendingTxHash = chainhash.Hash{}
+		}
+		outputs = append(outputs, *output)
+	}
+
+	return outputs, nil
+}
+
+// BalanceIndex implements an index that keeps track of the credits to and
+// debits from every public key script by its hash, which allows querying the
+// balance and the unspent outputs of a script as of any height of the main
+// chain.
+type BalanceIndex struct {
+	db database.DB
+}
+
+// Ensure the BalanceIndex type implements the Indexer interface.
+var _ Indexer = (*BalanceIndex)(nil)
+
+// Ensure the BalanceIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*BalanceIndex)(nil)
+
+// Ensure the BalanceIndex type implements the Checker interface.
+var _ Checker = (*BalanceIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *BalanceIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init initializes the balance index.
+//
+// This is part of the Indexer interface.
+func (idx *BalanceIndex) Init() error {
+	return nil // Nothing to do.
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *BalanceIndex) Key() []byte {
+	return balanceIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *BalanceIndex) Name() string {
+	return balanceIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs to be
+// created for the first time.  It creates the bucket for the balance index.
+//
+// This is part of the Indexer interface.
+func (idx *BalanceIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(balanceIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry for every output
+// created by the transactions in the block and marks the outputs they spend as
+// spent.
+//
+// This is part of the Indexer interface.
+func (idx *BalanceIndex) ConnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	return dbUpdateBalanceIndex(dbTx, block, stxos, true)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries for the
+// outputs created by the block and marks the outputs it spent as unspent.
+//
+// This is part of the Indexer interface.
+func (idx *BalanceIndex) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	return dbUpdateBalanceIndex(dbTx, block, stxos, false)
+}
+
+// CheckBlock returns a description of every entry for the outputs the passed
+// block creates or spends that is missing or disagrees with the block.
+//
+// This is part of the Checker interface.
+func (idx *BalanceIndex) CheckBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) ([]string, error) {
+
+	bucket := dbTx.Metadata().Bucket(balanceIndexKey)
+	height := block.Height()
+	var discrepancies []string
+	fetchOutput := func(pkScript []byte, height int32,
+		outpoint *wire.OutPoint) *ScriptOutput {
+
+		scriptHash := ScriptHash(pkScript)
+		key := balanceKey(&scriptHash, height, outpoint)
+		value := bucket.Get(key)
+		if value == nil {
+			discrepancies = append(discrepancies, fmt.Sprintf(
+				"missing entry for %v", outpoint))
+			return nil
+		}
+		output, err := deserializeBalanceEntry(key, value)
+		if err != nil {
+			discrepancies = append(discrepancies, err.Error())
+			return nil
+		}
+		return output
+	}
+
+	stxoIdx := 0
+	for txIdx, tx := range block.Transactions() {
+		// The outputs spent by the transaction must be marked as spent
+		// by it.
+		if txIdx != 0 {
+			for _, txIn := range tx.MsgTx().TxIn {
+				if stxoIdx >= len(stxos) {
+					return nil, AssertError(fmt.Sprintf(
+						"missing spent outputs for "+
+							"block %v", block.Hash()))
+				}
+				stxo := &stxos[stxoIdx]
+				stxoIdx++
+
+				outpoint := &txIn.PreviousOutPoint
+				output := fetchOutput(stxo.PkScript, stxo.Height,
+					outpoint)
+				if output == nil {
+					continue
+				}
+				if output.Amount != stxo.Amount ||
+					output.SpendHeight != height ||
+					output.SpendingTxHash != *tx.Hash() {
+
+					discrepancies = append(discrepancies,
+						fmt.Sprintf("%v is not recorded "+
+							"as spent by %v", outpoint,
+							tx.Hash()))
+				}
+			}
+		}
+
+		// The outputs created by the transaction must be recorded with
+		// their amount.  Whether they are spent later on is checked
+		// along with the block that spends them.
+		if height == 0 {
+			continue
+		}
+		outpoint := wire.OutPoint{Hash: *tx.Hash()}
+		for i, txOut := range tx.MsgTx().TxOut {
+			if isUnspendableOutput(txOut.PkScript) {
+				continue
+			}
+			outpoint.Index = uint32(i)
+			output := fetchOutput(txOut.PkScript, height, &outpoint)
+			if output != nil && output.Amount != txOut.Value {
+				discrepancies = append(discrepancies,
+					fmt.Sprintf("%v is recorded with amount "+
+						"%d instead of %d", outpoint,
+						output.Amount, txOut.Value))
+			}
+		}
+	}
+
+	return discrepancies, nil
+}
+
+// Outputs returns the outputs in the main chain that pay to the provided script
+// hash as of the provided height, ordered by the height of the block that
+// created them.  Outputs that are only spent above the height are reported as
+// unspent.  An error is returned when the height is above the tip of the index
+// since the outputs of the blocks above it are not known.
+//
+// This function is safe for concurrent access.
+func (idx *BalanceIndex) Outputs(scriptHash *chainhash.Hash, height int32) ([]ScriptOutput, error) {
+	var outputs []ScriptOutput
+	err := idx.db.View(func(dbTx database.Tx) error {
+		_, tipHeight, err := dbFetchIndexerTip(dbTx, balanceIndexKey)
+		if err != nil {
+			return err
+		}
+		if height > tipHeight {
+			return fmt.Errorf("the %s is only available up to height "+
+				"%d", balanceIndexName, tipHeight)
+		}
+
+		outputs, err = dbFetchScriptOutputs(dbTx, scriptHash, height)
+		return err
+	})
+	return outputs, err
+}
+
+// Unspent returns the outputs in the main chain that pay to the provided script
+// hash and are not spent as of the provided height, ordered by the height of
+// the block that created them.
+//
+// This function is safe for concurrent access.
+func (idx *BalanceIndex) Unspent(scriptHash *chainhash.Hash, height int32) ([]ScriptOutput, error) {
+	outputs, err := idx.Outputs(scriptHash, height)
+	if err != nil {
+		return nil, err
+	}
+
+	unspent := outputs[:0]
+	for _, output := range outputs {
+		if output.SpendHeight == -1 {
+			unspent = append(unspent, output)
+		}
+	}
+	return unspent, nil
+}
+
+// Balance returns the balance of the provided script hash along with the total
+// amounts it received and sent as of the provided height.
+//
+// This function is safe for concurrent access.
+func (idx *BalanceIndex) Balance(scriptHash *chainhash.Hash, height int32) (*ScriptBalance, error) {
+	outputs, err := idx.Outputs(scriptHash, height)
+	if err != nil {
+		return nil, err
+	}
+
+	var balance ScriptBalance
+	for _, output := range outputs {
+		balance.Received += output.Amount
+		if output.SpendHeight != -1 {
+			balance.Sent += output.Amount
+			continue
+		}
+		balance.Balance += output.Amount
+		balance.NumUnspent++
+	}
+	return &balance, nil
+}
+
+// NewBalanceIndex returns a new instance of an indexer that is used to create
+// a mapping of the hashes of all public key scripts in the blockchain to the
+// outputs that pay to them along with the transactions that spend them.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewBalanceIndex(db database.DB) *BalanceIndex {
+	return &BalanceIndex{db: db}
+}
+
+// DropBalanceIndex drops the balance index from the provided database if it
+// exists.
+func DropBalanceIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, balanceIndexKey, balanceIndexName, interrupt)
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestBalanceIndex ensures the balance index reports the balances and unspent
+// outputs of scripts as of any connected height and that disconnecting a block
+// restores the previous state.
+func TestBalanceIndex(t *testing.T) {
+	t.Parallel()
+
+	db := createTestDB(t)
+
+	idx := NewBalanceIndex(db)
+	err := db.Update(func(dbTx database.Tx) error {
+		_, err := dbTx.Metadata().CreateBucket(indexTipsBucketName)
+		if err != nil {
+			return err
+		}
+		return idx.Create(dbTx)
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	scriptA := []byte{0x51}
+	scriptB := []byte{0x52}
+	nullData := []byte{0x6a, 0x01, 0x01}
+	hashA := ScriptHash(scriptA)
+	hashB := ScriptHash(scriptB)
+
+	// Create a block at height 100 whose coinbase pays to script A and a
+	// block at height 101 that spends it to script B and spends that output
+	// again in the same block.
+	newCoinbase := func(height byte) *wire.MsgTx {
+		coinbase := wire.NewMsgTx(1)
+		coinbase.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{
+				Index: wire.MaxPrevOutIndex,
+			},
+			SignatureScript: []byte{0x01, height},
+		})
+		coinbase.AddTxOut(wire.NewTxOut(5000, scriptA))
+		return coinbase
+	}
+	coinbase1 := newCoinbase(100)
+	block1 := btcutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase1},
+	})
+	block1.SetHeight(100)
+
+	coinbase2 := newCoinbase(101)
+	coinbase2.AddTxOut(wire.NewTxOut(0, nullData))
+	coinbaseOut := wire.OutPoint{Hash: coinbase1.TxHash()}
+	tx1 := wire.NewMsgTx(1)
+	tx1.AddTxIn(wire.NewTxIn(&coinbaseOut, nil, nil))
+	tx1.AddTxOut(wire.NewTxOut(3000, scriptB))
+	tx1.AddTxOut(wire.NewTxOut(1900, scriptA))
+	tx1Out := wire.OutPoint{Hash: tx1.TxHash()}
+	tx2 := wire.NewMsgTx(1)
+	tx2.AddTxIn(wire.NewTxIn(&tx1Out, nil, nil))
+	tx2.AddTxOut(wire.NewTxOut(2900, scriptA))
+	block2 := btcutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase2, tx1, tx2},
+	})
+	block2.SetHeight(101)
+	stxos2 := []blockchain.SpentTxOut{
+		{Amount: 5000, PkScript: scriptA, Height: 100, IsCoinBase: true},
+		{Amount: 3000, PkScript: scriptB, Height: 101},
+	}
+
+	checkBalance := func(desc string, scriptHash *chainhash.Hash,
+		height int32, want ScriptBalance) {
+
+		t.Helper()
+		balance, err := idx.Balance(scriptHash, height)
+		if err != nil {
+			t.Fatalf("%s: Balance: %v", desc, err)
+		}
+		if *balance != want {
+			t.Fatalf("%s: unexpected balance of %v at height %d: "+
+				"got %+v, want %+v", desc, scriptHash, height,
+				*balance, want)
+		}
+	}
+	checkBlock := func(desc string, block *btcutil.Block,
+		stxos []blockchain.SpentTxOut, wantDiscrepancies int) {
+
+		t.Helper()
+		var discrepancies []string
+		err := db.View(func(dbTx database.Tx) error {
+			var err error
+			discrepancies, err = idx.CheckBlock(dbTx, block, stxos)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("%s: CheckBlock: %v", desc, err)
+		}
+		if len(discrepancies) != wantDiscrepancies {
+			t.Fatalf("%s: got discrepancies %v, want %d", desc,
+				discrepancies, wantDiscrepancies)
+		}
+	}
+
+	for _, block := range []*btcutil.Block{block1, block2} {
+		stxos := stxos2
+		if block == block1 {
+			stxos = nil
+		}
+		err = db.Update(func(dbTx database.Tx) error {
+			err := idx.ConnectBlock(dbTx, block, stxos)
+			if err != nil {
+				return err
+			}
+			return dbPutIndexerTip(dbTx, balanceIndexKey,
+				block.Hash(), block.Height())
+		})
+		if err != nil {
+			t.Fatalf("ConnectBlock: %v", err)
+		}
+	}
+	checkBlock("connected block 1", block1, nil, 0)
+	checkBlock("connected block 2", block2, stxos2, 0)
+
+	balanceA100 := ScriptBalance{Balance: 5000, Received: 5000,
+		NumUnspent: 1}
+	checkBalance("connect", &hashA, 99, ScriptBalance{})
+	checkBalance("connect", &hashA, 100, balanceA100)
+	checkBalance("connect", &hashA, 101, ScriptBalance{Balance: 9800,
+		Received: 14800, Sent: 5000, NumUnspent: 3})
+	checkBalance("connect", &hashB, 100, ScriptBalance{})
+	checkBalance("connect", &hashB, 101, ScriptBalance{Received: 3000,
+		Sent: 3000})
+
+	// The coinbase output is only reported as spent as of the height of the
+	// block that spends it.
+	wantOutputs := []ScriptOutput{{OutPoint: coinbaseOut, Height: 100,
+		Amount: 5000, SpendHeight: -1}}
+	outputs, err := idx.Outputs(&hashA, 100)
+	if err != nil {
+		t.Fatalf("Outputs: %v", err)
+	}
+	if !reflect.DeepEqual(outputs, wantOutputs) {
+		t.Fatalf("unexpected outputs at height 100: got %+v, want %+v",
+			outputs, wantOutputs)
+	}
+	outputs, err = idx.Outputs(&hashB, 101)
+	if err != nil {
+		t.Fatalf("Outputs: %v", err)
+	}
+	wantOutputs = []ScriptOutput{{OutPoint: tx1Out, Height: 101,
+		Amount: 3000, SpendHeight: 101, SpendingTxHash: tx2.TxHash()}}
+	if !reflect.DeepEqual(outputs, wantOutputs) {
+		t.Fatalf("unexpected outputs at height 101: got %+v, want %+v",
+			outputs, wantOutputs)
+	}
+	unspent, err := idx.Unspent(&hashA, 101)
+	if err != nil {
+		t.Fatalf("Unspent: %v", err)
+	}
+	if len(unspent) != 3 {
+		t.Fatalf("got %d unspent outputs at height 101, want 3",
+			len(unspent))
+	}
+
+	err = db.Update(func(dbTx database.Tx) error {
+		err := idx.DisconnectBlock(dbTx, block2, stxos2)
+		if err != nil {
+			return err
+		}
+		return dbPutIndexerTip(dbTx, balanceIndexKey, block1.Hash(),
+			block1.Height())
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: %v", err)
+	}
+	checkBalance("disconnect", &hashA, 100, balanceA100)
+	checkBalance("disconnect", &hashB, 100, ScriptBalance{})
+	outputs, err = idx.Outputs(&hashB, 100)
+	if err != nil {
+		t.Fatalf("Outputs: %v", err)
+	}
+	if len(outputs) != 0 {
+		t.Fatalf("unexpected outputs after disconnect: %+v", outputs)
+	}
+
+	// Heights above the tip of the index must be rejected.
+	if _, err := idx.Balance(&hashA, 101); err == nil {
+		t.Fatal("Balance: no error for height above the tip")
+	}
+	checkBlock("connected block 1", block1, nil, 0)
+	checkBlock("disconnected block 2", block2, stxos2, 6)
+
+	// Missing spent outputs must be detected.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block2, stxos2[:1])
+	})
+	if _, ok := err.(AssertError); !ok {
+		t.Fatalf("ConnectBlock: unexpected error %v", err)
+	}
+}
@@ -5,7 +5,9 @@
 package indexers
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/chaincfg"
@@ -20,6 +22,10 @@ import (
 const (
 	// cfIndexName is the human-readable name for the index.
 	cfIndexName = "committed filter index"
+
+	// cfVerifyBatchSize is the number of blocks whose filters are verified
+	// in a single database transaction by VerifyFilterHeaders.
+	cfVerifyBatchSize = 2000
 )
 
 // Committed filters come in one flavor currently: basic. They are generated
@@ -86,6 +92,9 @@ var _ Indexer = (*CfIndex)(nil)
 // Ensure the CfIndex type implements the NeedsInputser interface.
 var _ NeedsInputser = (*CfIndex)(nil)
 
+// Ensure the CfIndex type implements the Checker interface.
+var _ Checker = (*CfIndex)(nil)
+
 // NeedsInputs signals that the index requires the referenced inputs in order
 // to properly create the index.
 //
@@ -255,6 +264,85 @@ func (idx *CfIndex) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
 	return nil
 }
 
+// CheckBlock verifies that the stored filter of the passed block matches the
+// filter derived from the block and the outputs it spends, and that the stored
+// filter hash and header are consistent with it and the stored header of the
+// previous block.
+//
+// This is part of the Checker interface.
+func (idx *CfIndex) CheckBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) ([]string, error) {
+
+	prevScripts := make([][]byte, len(stxos))
+	for i, stxo := range stxos {
+		prevScripts[i] = stxo.PkScript
+	}
+	f, err := builder.BuildBasicFilter(block.MsgBlock(), prevScripts)
+	if err != nil {
+		return nil, err
+	}
+	filterBytes, err := f.NBytes()
+	if err != nil {
+		return nil, err
+	}
+	filterHash, err := builder.GetFilterHash(f)
+	if err != nil {
+		return nil, err
+	}
+
+	// The header commits to the header of the previous block, which is
+	// all zeros for the genesis block.
+	filterType := wire.GCSFilterRegular
+	fkey := cfIndexKeys[filterType]
+	hkey := cfHeaderKeys[filterType]
+	hashkey := cfHashKeys[filterType]
+	var discrepancies []string
+	var prevHeader chainhash.Hash
+	ph := &block.MsgBlock().Header.PrevBlock
+	if !ph.IsEqual(&zeroHash) {
+		pfh, err := dbFetchFilterIdxEntry(dbTx, hkey, ph)
+		if err != nil {
+			return nil, err
+		}
+		if len(pfh) != chainhash.HashSize {
+			discrepancies = append(discrepancies, "missing filter "+
+				"header of the previous block")
+		}
+		copy(prevHeader[:], pfh)
+	}
+	header, err := builder.MakeHeaderForFilter(f, prevHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	h := block.Hash()
+	checks := []struct {
+		key      []byte
+		expected []byte
+		desc     string
+	}{
+		{fkey, filterBytes, "filter"},
+		{hashkey, filterHash[:], "filter hash"},
+		{hkey, header[:], "filter header"},
+	}
+	for _, check := range checks {
+		stored, err := dbFetchFilterIdxEntry(dbTx, check.key, h)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case stored == nil:
+			discrepancies = append(discrepancies, "missing "+
+				check.desc)
+		case !bytes.Equal(stored, check.expected):
+			discrepancies = append(discrepancies, check.desc+
+				" mismatch")
+		}
+	}
+
+	return discrepancies, nil
+}
+
 // entryByBlockHash fetches a filter index entry of a particular type
 // (eg. filter, filter header, etc) for a filter type and block hash.
 func (idx *CfIndex) entryByBlockHash(filterTypeKeys [][]byte,
@@ -340,6 +428,129 @@ func (idx *CfIndex) FilterHashesByBlockHashes(blockHashes []*chainhash.Hash,
 	return idx.entriesByBlockHashes(cfHashKeys, filterType, blockHashes)
 }
 
+// FilterHeaderMismatchError identifies the first block whose stored filter,
+// filter hash or filter header is not consistent with the filter header chain
+// recomputed from the stored filters.
+type FilterHeaderMismatchError struct {
+	Height      int32
+	Hash        chainhash.Hash
+	Description string
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e FilterHeaderMismatchError) Error() string {
+	return fmt.Sprintf("%s for block %v (height %d)", e.Description,
+		e.Hash, e.Height)
+}
+
+// VerifyFilterHeaders recomputes the filter header chain of the passed filter
+// type from the stored filters of all main chain blocks that have been indexed
+// so far and compares it to the stored filter hashes and headers.  A
+// FilterHeaderMismatchError that identifies the first inconsistent block is
+// returned when they do not match.
+func (idx *CfIndex) VerifyFilterHeaders(chain *blockchain.BlockChain,
+	filterType wire.FilterType, interrupt <-chan struct{}) error {
+
+	if uint8(filterType) > maxFilterType {
+		return errors.New("unsupported filter type")
+	}
+	fkey := cfIndexKeys[filterType]
+	hkey := cfHeaderKeys[filterType]
+	hashkey := cfHashKeys[filterType]
+
+	// Only the blocks up to the current tip of the index can be verified
+	// since it might still be catching up with the main chain.
+	var bestHeight int32
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		_, bestHeight, err = dbFetchIndexerTip(dbTx, idx.Key())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var prevHeader chainhash.Hash
+	for startHeight := int32(0); startHeight <= bestHeight; {
+		if interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+
+		endHeight := startHeight + cfVerifyBatchSize - 1
+		if endHeight > bestHeight {
+			endHeight = bestHeight
+		}
+		err := idx.db.View(func(dbTx database.Tx) error {
+			for height := startHeight; height <= endHeight; height++ {
+				hash, err := chain.BlockHashByHeight(height)
+				if err != nil {
+					return err
+				}
+				mismatch := func(description string) error {
+					return FilterHeaderMismatchError{
+						Height:      height,
+						Hash:        *hash,
+						Description: description,
+					}
+				}
+
+				filterBytes, err := dbFetchFilterIdxEntry(dbTx,
+					fkey, hash)
+				if err != nil {
+					return err
+				}
+				if len(filterBytes) == 0 {
+					return mismatch("missing filter")
+				}
+				f, err := gcs.FromNBytes(builder.DefaultP,
+					builder.DefaultM, filterBytes)
+				if err != nil {
+					return mismatch(fmt.Sprintf("invalid "+
+						"filter: %v", err))
+				}
+
+				filterHash, err := builder.GetFilterHash(f)
+				if err != nil {
+					return err
+				}
+				storedHash, err := dbFetchFilterIdxEntry(dbTx,
+					hashkey, hash)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(filterHash[:], storedHash) {
+					return mismatch("filter hash mismatch")
+				}
+
+				header, err := builder.MakeHeaderForFilter(f,
+					prevHeader)
+				if err != nil {
+					return err
+				}
+				storedHeader, err := dbFetchFilterIdxEntry(dbTx,
+					hkey, hash)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(header[:], storedHeader) {
+					return mismatch("filter header mismatch")
+				}
+				prevHeader = header
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Verified committed filter headers up to height %d",
+			endHeight)
+		startHeight = endHeight + 1
+	}
+
+	return nil
+}
+
 // NewCfIndex returns a new instance of an indexer that is used to create a
 // mapping of the hashes of all blocks in the blockchain to their respective
 // committed filters.
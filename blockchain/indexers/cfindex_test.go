@@ -0,0 +1,103 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestVerifyFilterHeaders ensures verifying the filter headers of the committed
+// filter index detects missing filters and headers that do not match the
+// filters.
+func TestVerifyFilterHeaders(t *testing.T) {
+	t.Parallel()
+
+	db := createTestDB(t)
+	params := &chaincfg.RegressionNetParams
+	idx := NewCfIndex(db, params)
+	m := NewManager(db, []Indexer{idx})
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:           db,
+		ChainParams:  params,
+		TimeSource:   blockchain.NewMedianTime(),
+		IndexManager: m,
+	})
+	if err != nil {
+		t.Fatalf("unable to create chain: %v", err)
+	}
+	if err := m.WaitForCatchUp(); err != nil {
+		t.Fatalf("WaitForCatchUp: %v", err)
+	}
+
+	// verify ensures verifying the filter headers fails with a mismatch
+	// with the passed description or succeeds when it is empty.
+	verify := func(desc, want string) {
+		t.Helper()
+		err := idx.VerifyFilterHeaders(chain, wire.GCSFilterRegular, nil)
+		if want == "" {
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", desc, err)
+			}
+			return
+		}
+		mismatch, ok := err.(FilterHeaderMismatchError)
+		if !ok {
+			t.Fatalf("%s: unexpected error %v, want a mismatch", desc,
+				err)
+		}
+		if mismatch.Description != want || mismatch.Height != 0 ||
+			mismatch.Hash != *params.GenesisHash {
+
+			t.Fatalf("%s: unexpected mismatch %v, want %q for the "+
+				"genesis block", desc, mismatch, want)
+		}
+	}
+
+	// update applies the passed function to the filter index entries of
+	// the genesis block.
+	update := func(f func(dbTx database.Tx) error) {
+		t.Helper()
+		if err := db.Update(f); err != nil {
+			t.Fatalf("unable to update filter index: %v", err)
+		}
+	}
+
+	verify("indexed", "")
+	err = idx.VerifyFilterHeaders(chain, wire.FilterType(1), nil)
+	if err == nil {
+		t.Fatal("unexpected success verifying unsupported filter type")
+	}
+
+	// A stored header that does not commit to the filter is detected.
+	headerKey := cfHeaderKeys[wire.GCSFilterRegular]
+	header, err := idx.FilterHeaderByBlockHash(params.GenesisHash,
+		wire.GCSFilterRegular)
+	if err != nil {
+		t.Fatalf("FilterHeaderByBlockHash: %v", err)
+	}
+	update(func(dbTx database.Tx) error {
+		return dbStoreFilterIdxEntry(dbTx, headerKey, params.GenesisHash,
+			chainhash.DoubleHashB(header))
+	})
+	verify("bad header", "filter header mismatch")
+	update(func(dbTx database.Tx) error {
+		return dbStoreFilterIdxEntry(dbTx, headerKey, params.GenesisHash,
+			header)
+	})
+	verify("restored header", "")
+
+	// A missing filter is detected.
+	update(func(dbTx database.Tx) error {
+		return dbDeleteFilterIdxEntry(dbTx,
+			cfIndexKeys[wire.GCSFilterRegular], params.GenesisHash)
+	})
+	verify("missing filter", "missing filter")
+}
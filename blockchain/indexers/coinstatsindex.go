@@ -0,0 +1,446 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// coinStatsIndexName is the human-readable name for the index.
+	coinStatsIndexName = "coinstats index"
+
+	// coinStatsEntrySize is the size of a serialized coin stats entry.
+	coinStatsEntrySize = 4 + 8 + 8 + 8 + chainhash.HashSize
+)
+
+var (
+	// coinStatsIndexKey is the key of the coinstats index and the db
+	// bucket used to house it.
+	coinStatsIndexKey = []byte("coinstatsidx")
+
+	// coinStatsByHashBucketName is the name of the db bucket used to house
+	// the block hash -> coin stats index.
+	coinStatsByHashBucketName = []byte("coinstatsbyhashidx")
+
+	// coinStatsMuHashKey is the key in the coinstats index bucket used to
+	// house the rolling hash state of the utxo set as of the index tip.
+	coinStatsMuHashKey = []byte("muhashstate")
+)
+
+// -----------------------------------------------------------------------------
+// The coinstats index consists of an entry for every block in the main chain
+// that describes the utxo set as of that block along with the rolling hash
+// state of the utxo set as of the current index tip.  The state is needed to
+// update the rolling hash as blocks are connected and disconnected, while the
+// entries allow the statistics to be looked up for any block.
+//
+// The utxo set tracked by the index matches the one used by Bitcoin Core for
+// its own coinstats index, so the hashes can be compared against it.  That is
+// to say outputs which are provably unspendable because their script starts
+// with OP_RETURN or exceeds the maximum script size, the outputs of the genesis
+// block and the outputs of the duplicate coinbase transactions in the blocks
+// that violate BIP0030 are not part of it.
+//
+// Each utxo is added to the rolling hash serialized as follows:
+//
+//   Field           Type              Size
+//   txid            chainhash.Hash    32 bytes
+//   output index    uint32            4 bytes
+//   code            uint32            4 bytes (height << 1 | coinbase flag)
+//   amount          int64             8 bytes
+//   script length   CompactSize       variable
+//   script          []byte            variable
+//
+// The serialized format for keys and values in the block hash to coin stats
+// bucket is:
+//
+//   <hash> = <height><txouts><bogosize><total amount><muhash>
+//
+//   Field           Type              Size
+//   hash            chainhash.Hash    32 bytes
+//   height          uint32            4 bytes
+//   txouts          uint64            8 bytes
+//   bogosize        uint64            8 bytes
+//   total amount    int64             8 bytes
+//   muhash          chainhash.Hash    32 bytes
+//   -----
+//   Total: 92 bytes
+//
+// The rolling hash state is stored as the serialized MuHash3072 state under
+// the muhashstate key in the coinstats index bucket.
+// -----------------------------------------------------------------------------
+
+// CoinStats describes the utxo set as of a block in the main chain.
+type CoinStats struct {
+	// Height is the height of the block.
+	Height int32
+
+	// TxOuts is the number of unspent transaction outputs.
+	TxOuts uint64
+
+	// BogoSize is a database independent metric for the size of the utxo
+	// set which is calculated the same way as it is by Bitcoin Core.
+	BogoSize uint64
+
+	// TotalAmount is the total amount of all unspent outputs in satoshi.
+	TotalAmount int64
+
+	// MuHash is the MuHash3072 hash of the utxo set.
+	MuHash chainhash.Hash
+}
+
+// serializeCoinStats returns the coin stats serialized according to the format
+// described above.
+func serializeCoinStats(stats *CoinStats) []byte {
+	serialized := make([]byte, coinStatsEntrySize)
+	byteOrder.PutUint32(serialized, uint32(stats.Height))
+	byteOrder.PutUint64(serialized[4:], stats.TxOuts)
+	byteOrder.PutUint64(serialized[12:], stats.BogoSize)
+	byteOrder.PutUint64(serialized[20:], uint64(stats.TotalAmount))
+	copy(serialized[28:], stats.MuHash[:])
+	return serialized
+}
+
+// deserializeCoinStats decodes coin stats that were serialized according to
+// the format described above.
+func deserializeCoinStats(serialized []byte) (*CoinStats, error) {
+	if len(serialized) != coinStatsEntrySize {
+		return nil, errDeserialize("unexpected coin stats entry size")
+	}
+
+	stats := &CoinStats{
+		Height:      int32(byteOrder.Uint32(serialized)),
+		TxOuts:      byteOrder.Uint64(serialized[4:]),
+		BogoSize:    byteOrder.Uint64(serialized[12:]),
+		TotalAmount: int64(byteOrder.Uint64(serialized[20:])),
+	}
+	copy(stats.MuHash[:], serialized[28:])
+	return stats, nil
+}
+
+// dbFetchCoinStats uses an existing database transaction to retrieve the coin
+// stats for the provided block hash.  When there is no entry for the hash, nil
+// will be returned for both the stats and the error.
+func dbFetchCoinStats(dbTx database.Tx, hash *chainhash.Hash) (*CoinStats, error) {
+	bucket := dbTx.Metadata().Bucket(coinStatsIndexKey).
+		Bucket(coinStatsByHashBucketName)
+	serialized := bucket.Get(hash[:])
+	if serialized == nil {
+		return nil, nil
+	}
+
+	stats, err := deserializeCoinStats(serialized)
+	if err != nil {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt coinstats index "+
+				"entry for %s: %v", hash, err),
+		}
+	}
+	return stats, nil
+}
+
+// dbFetchMuHashState uses an existing database transaction to retrieve the
+// rolling hash state of the utxo set as of the index tip.  The state for the
+// empty set is returned when there is no stored state.
+func dbFetchMuHashState(dbTx database.Tx) (*MuHash3072, error) {
+	bucket := dbTx.Metadata().Bucket(coinStatsIndexKey)
+	serialized := bucket.Get(coinStatsMuHashKey)
+	if serialized == nil {
+		return NewMuHash3072(), nil
+	}
+
+	muHash, err := DeserializeMuHash3072(serialized)
+	if err != nil {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt coinstats index "+
+				"state: %v", err),
+		}
+	}
+	return muHash, nil
+}
+
+// serializeMuHashUtxo returns the utxo serialized in the format it is added to
+// the rolling hash as described above.
+func serializeMuHashUtxo(outpoint *wire.OutPoint, height int32,
+	isCoinBase bool, amount int64, pkScript []byte) []byte {
+
+	var buf bytes.Buffer
+	buf.Grow(chainhash.HashSize + 16 +
+		wire.VarIntSerializeSize(uint64(len(pkScript))) + len(pkScript))
+
+	code := uint32(height) << 1
+	if isCoinBase {
+		code |= 1
+	}
+
+	var scratch [8]byte
+	buf.Write(outpoint.Hash[:])
+	binary.LittleEndian.PutUint32(scratch[:], outpoint.Index)
+	buf.Write(scratch[:4])
+	binary.LittleEndian.PutUint32(scratch[:], code)
+	buf.Write(scratch[:4])
+	binary.LittleEndian.PutUint64(scratch[:], uint64(amount))
+	buf.Write(scratch[:])
+	_ = wire.WriteVarInt(&buf, 0, uint64(len(pkScript)))
+	buf.Write(pkScript)
+	return buf.Bytes()
+}
+
+// bogoSize returns the size Bitcoin Core uses to approximate the space an
+// unspent output with the passed script occupies in the utxo set.
+func bogoSize(pkScript []byte) uint64 {
+	return chainhash.HashSize + 4 + 4 + 8 + 2 + uint64(len(pkScript))
+}
+
+// isUnspendableOutput returns whether the passed script is provably
+// unspendable.  Unlike txscript.IsUnspendable, scripts which fail to parse are
+// not considered unspendable in order to track the same utxo set as Bitcoin
+// Core.
+func isUnspendableOutput(pkScript []byte) bool {
+	return (len(pkScript) > 0 && pkScript[0] == txscript.OP_RETURN) ||
+		len(pkScript) > txscript.MaxScriptSize
+}
+
+// CoinStatsIndex implements an index of the utxo set statistics as of each
+// block in the main chain.  It maintains a rolling MuHash3072 hash of the utxo
+// set which is updated incrementally as blocks are connected and disconnected
+// so the hash of the utxo set as of any block is available without scanning
+// it.
+type CoinStatsIndex struct {
+	db database.DB
+}
+
+// Ensure the CoinStatsIndex type implements the Indexer interface.
+var _ Indexer = (*CoinStatsIndex)(nil)
+
+// Ensure the CoinStatsIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*CoinStatsIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *CoinStatsIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init initializes the coinstats index.
+//
+// This is part of the Indexer interface.
+func (idx *CoinStatsIndex) Init() error {
+	return nil // Nothing to do.
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *CoinStatsIndex) Key() []byte {
+	return coinStatsIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *CoinStatsIndex) Name() string {
+	return coinStatsIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs to be
+// created for the first time.  It creates the bucket for the index and the
+// nested bucket for the coin stats of each block.
+//
+// This is part of the Indexer interface.
+func (idx *CoinStatsIndex) Create(dbTx database.Tx) error {
+	bucket, err := dbTx.Metadata().CreateBucket(coinStatsIndexKey)
+	if err != nil {
+		return err
+	}
+	_, err = bucket.CreateBucket(coinStatsByHashBucketName)
+	return err
+}
+
+// applyBlock updates the rolling hash and the statistics of the utxo set for
+// the outputs created and spent by the passed block.  The outputs created by
+// the block are added and the spent outputs are removed when connect is true
+// and the reverse is done otherwise.
+func applyBlock(muHash *MuHash3072, stats *CoinStats, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut, connect bool) error {
+
+	// addUtxo adds the utxo to the set when adding is true and removes it
+	// otherwise.
+	addUtxo := func(adding bool, outpoint *wire.OutPoint, height int32,
+		isCoinBase bool, amount int64, pkScript []byte) {
+
+		serialized := serializeMuHashUtxo(outpoint, height, isCoinBase,
+			amount, pkScript)
+		if adding {
+			muHash.Add(serialized)
+			stats.TxOuts++
+			stats.BogoSize += bogoSize(pkScript)
+			stats.TotalAmount += amount
+		} else {
+			muHash.Remove(serialized)
+			stats.TxOuts--
+			stats.BogoSize -= bogoSize(pkScript)
+			stats.TotalAmount -= amount
+		}
+	}
+
+	// The outputs of the genesis block are not spendable.
+	height := block.Height()
+	if height == 0 {
+		return nil
+	}
+
+	stxoIdx := 0
+	for txIdx, tx := range block.Transactions() {
+		isCoinBase := txIdx == 0
+		if !isCoinBase {
+			for _, txIn := range tx.MsgTx().TxIn {
+				if stxoIdx >= len(stxos) {
+					return AssertError(fmt.Sprintf("missing "+
+						"spent outputs for block %v",
+						block.Hash()))
+				}
+				stxo := &stxos[stxoIdx]
+				stxoIdx++
+
+				addUtxo(!connect, &txIn.PreviousOutPoint,
+					stxo.Height, stxo.IsCoinBase, stxo.Amount,
+					stxo.PkScript)
+			}
+		}
+
+		if isCoinBase && blockchain.IsBIP0030Block(block.Hash(), height) {
+			continue
+		}
+		outpoint := wire.OutPoint{Hash: *tx.Hash()}
+		for i, txOut := range tx.MsgTx().TxOut {
+			if isUnspendableOutput(txOut.PkScript) {
+				continue
+			}
+			outpoint.Index = uint32(i)
+			addUtxo(connect, &outpoint, height, isCoinBase,
+				txOut.Value, txOut.PkScript)
+		}
+	}
+
+	return nil
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds the outputs created by the
+// block to the rolling hash of the utxo set, removes the outputs it spends and
+// stores the resulting statistics for the block.
+//
+// This is part of the Indexer interface.
+func (idx *CoinStatsIndex) ConnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	muHash, err := dbFetchMuHashState(dbTx)
+	if err != nil {
+		return err
+	}
+
+	// The statistics start out as those of the previous block.
+	stats := &CoinStats{}
+	if block.Height() > 0 {
+		prevHash := &block.MsgBlock().Header.PrevBlock
+		stats, err = dbFetchCoinStats(dbTx, prevHash)
+		if err != nil {
+			return err
+		}
+		if stats == nil {
+			return AssertError(fmt.Sprintf("missing coin stats for "+
+				"previous block %v", prevHash))
+		}
+	}
+
+	if err := applyBlock(muHash, stats, block, stxos, true); err != nil {
+		return err
+	}
+	stats.Height = block.Height()
+	stats.MuHash = muHash.Finalize()
+
+	bucket := dbTx.Metadata().Bucket(coinStatsIndexKey)
+	if err := bucket.Put(coinStatsMuHashKey, muHash.Serialize()); err != nil {
+		return err
+	}
+	return bucket.Bucket(coinStatsByHashBucketName).Put(block.Hash()[:],
+		serializeCoinStats(stats))
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer reverts the changes the
+// block made to the rolling hash of the utxo set and removes the statistics
+// for the block.
+//
+// This is part of the Indexer interface.
+func (idx *CoinStatsIndex) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	muHash, err := dbFetchMuHashState(dbTx)
+	if err != nil {
+		return err
+	}
+
+	// The statistics are only needed to satisfy applyBlock since the ones
+	// for the previous block are still stored.
+	var stats CoinStats
+	if err := applyBlock(muHash, &stats, block, stxos, false); err != nil {
+		return err
+	}
+
+	bucket := dbTx.Metadata().Bucket(coinStatsIndexKey)
+	if err := bucket.Put(coinStatsMuHashKey, muHash.Serialize()); err != nil {
+		return err
+	}
+	return bucket.Bucket(coinStatsByHashBucketName).Delete(block.Hash()[:])
+}
+
+// CoinStats returns the statistics of the utxo set as of the provided block
+// hash.  When there is no entry for the provided hash, which is the case for
+// blocks that are not in the main chain or have not been indexed yet, nil will
+// be returned for both the stats and the error.
+//
+// This function is safe for concurrent access.
+func (idx *CoinStatsIndex) CoinStats(hash *chainhash.Hash) (*CoinStats, error) {
+	var stats *CoinStats
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		stats, err = dbFetchCoinStats(dbTx, hash)
+		return err
+	})
+	return stats, err
+}
+
+// NewCoinStatsIndex returns a new instance of an indexer that is used to
+// maintain the statistics and a rolling MuHash3072 hash of the utxo set as of
+// each block in the main chain.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewCoinStatsIndex(db database.DB) *CoinStatsIndex {
+	return &CoinStatsIndex{db: db}
+}
+
+// DropCoinStatsIndex drops the coinstats index from the provided database if it
+// exists.
+func DropCoinStatsIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, coinStatsIndexKey, coinStatsIndexName, interrupt)
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestCoinStatsApplyBlock ensures connecting a block updates the rolling hash
+// and statistics of the utxo set to match those of the resulting set and that
+// disconnecting it again restores them.
+func TestCoinStatsApplyBlock(t *testing.T) {
+	t.Parallel()
+
+	p2pkh := []byte{
+		txscript.OP_DUP, txscript.OP_HASH160, txscript.OP_DATA_20,
+		1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG,
+	}
+	nullData := []byte{txscript.OP_RETURN, txscript.OP_DATA_1, 1}
+
+	// The spent output was created by a coinbase transaction at height 1.
+	spentOutPoint := wire.OutPoint{Index: 1}
+	spentOutPoint.Hash[0] = 1
+	stxo := blockchain.SpentTxOut{
+		Amount:     5000,
+		PkScript:   p2pkh,
+		Height:     1,
+		IsCoinBase: true,
+	}
+
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		SignatureScript:  []byte{txscript.OP_DATA_1, 5},
+	})
+	coinbase.AddTxOut(wire.NewTxOut(1000, p2pkh))
+	coinbase.AddTxOut(wire.NewTxOut(0, nullData))
+	spend := wire.NewMsgTx(1)
+	spend.AddTxIn(wire.NewTxIn(&spentOutPoint, nil, nil))
+	spend.AddTxOut(wire.NewTxOut(4000, p2pkh))
+
+	block := btcutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, spend},
+	})
+	block.SetHeight(5)
+	stxos := []blockchain.SpentTxOut{stxo}
+
+	// Create the utxo set before the block and the expected one after it.
+	initialMuHash := NewMuHash3072()
+	initialMuHash.Add(serializeMuHashUtxo(&spentOutPoint, stxo.Height,
+		stxo.IsCoinBase, stxo.Amount, stxo.PkScript))
+	initialStats := CoinStats{
+		TxOuts:      1,
+		BogoSize:    bogoSize(p2pkh),
+		TotalAmount: 5000,
+	}
+	initialHash := initialMuHash.Finalize()
+
+	wantMuHash := NewMuHash3072()
+	wantMuHash.Add(serializeMuHashUtxo(
+		&wire.OutPoint{Hash: coinbase.TxHash()}, 5, true, 1000, p2pkh))
+	wantMuHash.Add(serializeMuHashUtxo(
+		&wire.OutPoint{Hash: spend.TxHash()}, 5, false, 4000, p2pkh))
+	wantStats := CoinStats{
+		TxOuts:      2,
+		BogoSize:    2 * bogoSize(p2pkh),
+		TotalAmount: 5000,
+		MuHash:      wantMuHash.Finalize(),
+	}
+
+	muHash := initialMuHash
+	stats := initialStats
+	if err := applyBlock(muHash, &stats, block, stxos, true); err != nil {
+		t.Fatalf("applyBlock connect: %v", err)
+	}
+	stats.MuHash = muHash.Finalize()
+	if !reflect.DeepEqual(stats, wantStats) {
+		t.Fatalf("unexpected stats after connect %+v, want %+v", stats,
+			wantStats)
+	}
+
+	if err := applyBlock(muHash, &stats, block, stxos, false); err != nil {
+		t.Fatalf("applyBlock disconnect: %v", err)
+	}
+	stats.MuHash = initialStats.MuHash
+	if !reflect.DeepEqual(stats, initialStats) {
+		t.Fatalf("unexpected stats after disconnect %+v, want %+v",
+			stats, initialStats)
+	}
+	if got := muHash.Finalize(); got != initialHash {
+		t.Fatalf("unexpected hash after disconnect %v, want %v", got,
+			initialHash)
+	}
+
+	// Missing spent outputs must be detected.
+	err := applyBlock(muHash, &stats, block, nil, true)
+	if _, ok := err.(AssertError); !ok {
+		t.Fatalf("applyBlock: unexpected error %v", err)
+	}
+
+	// The stats must survive a serialization round trip.
+	gotStats, err := deserializeCoinStats(serializeCoinStats(&wantStats))
+	if err != nil {
+		t.Fatalf("deserializeCoinStats: %v", err)
+	}
+	if !reflect.DeepEqual(*gotStats, wantStats) {
+		t.Fatalf("unexpected deserialized stats %+v, want %+v",
+			*gotStats, wantStats)
+	}
+}
@@ -10,6 +10,7 @@ package indexers
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/database"
@@ -32,6 +33,17 @@ type NeedsInputser interface {
 	NeedsInputs() bool
 }
 
+// Checker provides a generic interface for an indexer to verify the entries it
+// holds for a block against the entries derived from the block itself.
+type Checker interface {
+	// CheckBlock verifies the index entries of the passed block, which
+	// must be a main chain block that is connected to the index, and
+	// returns a description of each discrepancy found.  The set of outputs
+	// spent within the block is also passed in so indexers can access the
+	// previous output scripts if required.
+	CheckBlock(database.Tx, *btcutil.Block, []blockchain.SpentTxOut) ([]string, error)
+}
+
 // Indexer provides a generic interface for an indexer that is managed by an
 // index manager such as the Manager type provided by this package.
 type Indexer interface {
@@ -89,6 +101,13 @@ func isDeserializeErr(err error) bool {
 	return ok
 }
 
+// isCorruptionErr returns whether or not the passed error is a database error
+// that identifies corrupt data.
+func isCorruptionErr(err error) bool {
+	dbErr, ok := err.(database.Error)
+	return ok && dbErr.ErrorCode == database.ErrCorruption
+}
+
 // internalBucket is an abstraction over a database bucket.  It is used to make
 // the code easier to test since it allows mock objects in the tests to only
 // implement these functions instead of everything a database.Bucket supports.
@@ -110,3 +129,48 @@ func interruptRequested(interrupted <-chan struct{}) bool {
 
 	return false
 }
+
+// forEachBlockTx invokes the passed function with every transaction of the
+// passed block along with the outputs spent by its inputs.  The transactions
+// are visited in block order when connect is true and in reverse order
+// otherwise, so an output that is created and spent in the same block is
+// created before it is spent when connecting and the spend is undone before
+// the output is removed when disconnecting.  The spent outputs of the coinbase
+// are empty.
+//
+// The spent outputs must be in the order the inputs of the block that spend
+// them appear.
+func forEachBlockTx(block *btcutil.Block, stxos []blockchain.SpentTxOut,
+	connect bool, fn func(txIdx int, tx *btcutil.Tx,
+		txStxos []blockchain.SpentTxOut) error) error {
+
+	// Find the spent outputs of each transaction up front so the
+	// transactions can be visited in reverse order below.
+	txns := block.Transactions()
+	txStxos := make([][]blockchain.SpentTxOut, len(txns))
+	stxoIdx := 0
+	for txIdx, tx := range txns[1:] {
+		numIn := len(tx.MsgTx().TxIn)
+		if stxoIdx+numIn > len(stxos) {
+			return AssertError(fmt.Sprintf("missing spent outputs "+
+				"for block %v", block.Hash()))
+		}
+		txStxos[txIdx+1] = stxos[stxoIdx : stxoIdx+numIn]
+		stxoIdx += numIn
+	}
+
+	if connect {
+		for txIdx, tx := range txns {
+			if err := fn(txIdx, tx, txStxos[txIdx]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for txIdx := len(txns) - 1; txIdx >= 0; txIdx-- {
+		if err := fn(txIdx, txns[txIdx], txStxos[txIdx]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/database"
+	_ "github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// createTestDB creates a new database in a temporary directory for the passed
+// test.  The database is closed and removed once the test finishes.
+func createTestDB(t *testing.T) database.DB {
+	t.Helper()
+
+	dbPath := filepath.Join(t.TempDir(), "db")
+	db, err := database.Create("ffldb", dbPath, wire.MainNet)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
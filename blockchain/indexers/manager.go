@@ -7,6 +7,8 @@ package indexers
 import (
 	"bytes"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -64,6 +66,25 @@ func dbFetchIndexerTip(dbTx database.Tx, idxKey []byte) (*chainhash.Hash, int32,
 	return &hash, height, nil
 }
 
+// IndexTip returns the hash and height of the current tip of the passed index.
+// The height is -1 when the index does not have any entries yet, and a nil
+// hash along with a height of -1 is returned when the index does not exist.
+func IndexTip(db database.DB, indexer Indexer) (*chainhash.Hash, int32, error) {
+	var hash *chainhash.Hash
+	height := int32(-1)
+	err := db.View(func(dbTx database.Tx) error {
+		indexesBucket := dbTx.Metadata().Bucket(indexTipsBucketName)
+		if indexesBucket == nil || indexesBucket.Get(indexer.Key()) == nil {
+			return nil
+		}
+
+		var err error
+		hash, height, err = dbFetchIndexerTip(dbTx, indexer.Key())
+		return err
+	})
+	return hash, height, err
+}
+
 // dbIndexConnectBlock adds all of the index entries associated with the
 // given block using the provided indexer and updates the tip of the indexer
 // accordingly.  An error will be returned if the current tip for the indexer is
@@ -126,12 +147,58 @@ func dbIndexDisconnectBlock(dbTx database.Tx, indexer Indexer, block *btcutil.Bl
 	return dbPutIndexerTip(dbTx, idxKey, prevHash, block.Height()-1)
 }
 
+// catchUpRetryInterval is the amount of time the background catch-up waits
+// before trying again when the next block it needs is not yet available in the
+// main chain.  This only happens briefly while the chain is in the middle of
+// connecting or disconnecting a block.
+const catchUpRetryInterval = time.Second
+
+// indexState houses the sync state of an index managed by the index manager.
+type indexState struct {
+	// synced indicates whether or not the index has caught up with the
+	// main chain and is being updated as blocks are connected and
+	// disconnected.
+	synced bool
+
+	// height is the height of the current tip of the index.
+	height int32
+}
+
+// IndexInfo describes the sync state of an index managed by the index manager.
+type IndexInfo struct {
+	Name   string
+	Synced bool
+	Height int32
+}
+
 // Manager defines an index manager that manages multiple optional indexes and
 // implements the blockchain.IndexManager interface so it can be seamlessly
 // plugged into normal chain processing.
+//
+// Indexes that are behind the main chain when the manager is initialized are
+// caught up in the background while the chain continues to process new blocks.
+// Each of them starts being updated along with the chain as soon as it reaches
+// the tip.
 type Manager struct {
 	db             database.DB
 	enabledIndexes []Indexer
+
+	// The following fields are protected by mtx.  The chain tip is the
+	// hash of the block at the tip of the main chain as of the last
+	// database transaction that connected or disconnected a block.  The
+	// mutex is always acquired within a database transaction, so it must
+	// never be held while starting one.
+	mtx      sync.Mutex
+	states   []indexState
+	chainTip chainhash.Hash
+
+	// catchUpErr is the error that stopped catching up the indexes in the
+	// background, if any.  It must only be accessed once the background
+	// catch-up has finished.
+	catchUpErr error
+
+	quit chan struct{}
+	wg   sync.WaitGroup
 }
 
 // Ensure the Manager type implements the blockchain.IndexManager interface.
@@ -225,12 +292,116 @@ func (m *Manager) maybeCreateIndexes(dbTx database.Tx) error {
 	return nil
 }
 
+// rollbackOrphanedTip disconnects blocks from the index at the passed position
+// until its tip is a block in the main chain.  Whether or not the tip is in the
+// main chain is determined within the same database transaction that
+// disconnects the block, so it is safe to call while the chain is processing
+// blocks.
+func (m *Manager) rollbackOrphanedTip(chain *blockchain.BlockChain, i int,
+	interrupt <-chan struct{}) error {
+
+	indexer := m.enabledIndexes[i]
+	initialHeight := int32(-1)
+	var height int32
+	for {
+		// Fetch the current tip for the index and stop once it is in the
+		// main chain or the index does not have any entries yet.
+		var hash *chainhash.Hash
+		var orphaned bool
+		err := m.db.View(func(dbTx database.Tx) error {
+			var err error
+			hash, height, err = dbFetchIndexerTip(dbTx, indexer.Key())
+			if err != nil {
+				return err
+			}
+			orphaned = height != -1 &&
+				!blockchain.DBMainChainHasBlock(dbTx, hash)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if initialHeight == -1 {
+			initialHeight = height
+		}
+		if !orphaned {
+			break
+		}
+
+		// At this point the index tip is orphaned, so load the orphaned
+		// block from the database directly and disconnect it from the
+		// index.  The block has to be loaded directly since it is no
+		// longer in the main chain and thus the chain.BlockByHash
+		// function would error.
+		var block *btcutil.Block
+		err = m.db.View(func(dbTx database.Tx) error {
+			blockBytes, err := dbTx.FetchBlock(hash)
+			if err != nil {
+				return err
+			}
+			block, err = btcutil.NewBlockFromBytes(blockBytes)
+			if err != nil {
+				return err
+			}
+			block.SetHeight(height)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		// We'll also grab the set of outputs spent by this block so we
+		// can remove them from the index.
+		spentTxos, err := chain.FetchSpendJournal(block)
+		if err != nil {
+			return err
+		}
+
+		// With the block and stxo set for that block retrieved, we can
+		// now update the index itself unless its tip changed in the
+		// meantime.
+		err = m.db.Update(func(dbTx database.Tx) error {
+			m.mtx.Lock()
+			defer m.mtx.Unlock()
+
+			tipHash, _, err := dbFetchIndexerTip(dbTx, indexer.Key())
+			if err != nil || !tipHash.IsEqual(hash) {
+				return err
+			}
+
+			// Remove all of the index entries associated with the
+			// block and update the indexer tip.
+			err = dbIndexDisconnectBlock(dbTx, indexer, block, spentTxos)
+			if err != nil {
+				return err
+			}
+			m.states[i].height = height - 1
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+	}
+
+	if initialHeight != height {
+		log.Infof("Removed %d orphaned blocks from %s (heights %d to %d)",
+			initialHeight-height, indexer.Name(), height+1,
+			initialHeight)
+	}
+	return nil
+}
+
 // Init initializes the enabled indexes.  This is called during chain
-// initialization and primarily consists of catching up all indexes to the
-// current best chain tip.  This is necessary since each index can be disabled
-// and re-enabled at any time and attempting to catch-up indexes at the same
-// time new blocks are being downloaded would lead to an overall longer time to
-// catch up due to the I/O contention.
+// initialization and primarily consists of rolling back indexes whose tip is
+// no longer in the main chain and starting a background goroutine that catches
+// up all indexes that are behind the current best chain tip.  This is
+// necessary since each index can be disabled and re-enabled at any time.
+// Catching up in the background lets the node keep following the chain in the
+// meantime, and the indexes report that they are still syncing until done.
 //
 // This is part of the blockchain.IndexManager interface.
 func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{}) error {
@@ -275,100 +446,21 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 	// reorganized while the index is disabled.  This has to be done in
 	// reverse order because later indexes can depend on earlier ones.
 	for i := len(m.enabledIndexes); i > 0; i-- {
-		indexer := m.enabledIndexes[i-1]
-
-		// Fetch the current tip for the index.
-		var height int32
-		var hash *chainhash.Hash
-		err := m.db.View(func(dbTx database.Tx) error {
-			idxKey := indexer.Key()
-			hash, height, err = dbFetchIndexerTip(dbTx, idxKey)
-			return err
-		})
-		if err != nil {
+		if err := m.rollbackOrphanedTip(chain, i-1, interrupt); err != nil {
 			return err
 		}
-
-		// Nothing to do if the index does not have any entries yet.
-		if height == -1 {
-			continue
-		}
-
-		// Loop until the tip is a block that exists in the main chain.
-		initialHeight := height
-		for !chain.MainChainHasBlock(hash) {
-			// At this point the index tip is orphaned, so load the
-			// orphaned block from the database directly and
-			// disconnect it from the index.  The block has to be
-			// loaded directly since it is no longer in the main
-			// chain and thus the chain.BlockByHash function would
-			// error.
-			var block *btcutil.Block
-			err := m.db.View(func(dbTx database.Tx) error {
-				blockBytes, err := dbTx.FetchBlock(hash)
-				if err != nil {
-					return err
-				}
-				block, err = btcutil.NewBlockFromBytes(blockBytes)
-				if err != nil {
-					return err
-				}
-				block.SetHeight(height)
-				return err
-			})
-			if err != nil {
-				return err
-			}
-
-			// We'll also grab the set of outputs spent by this
-			// block so we can remove them from the index.
-			spentTxos, err := chain.FetchSpendJournal(block)
-			if err != nil {
-				return err
-			}
-
-			// With the block and stxo set for that block retrieved,
-			// we can now update the index itself.
-			err = m.db.Update(func(dbTx database.Tx) error {
-				// Remove all of the index entries associated
-				// with the block and update the indexer tip.
-				err = dbIndexDisconnectBlock(
-					dbTx, indexer, block, spentTxos,
-				)
-				if err != nil {
-					return err
-				}
-
-				// Update the tip to the previous block.
-				hash = &block.MsgBlock().Header.PrevBlock
-				height--
-
-				return nil
-			})
-			if err != nil {
-				return err
-			}
-
-			if interruptRequested(interrupt) {
-				return errInterruptRequested
-			}
-		}
-
-		if initialHeight != height {
-			log.Infof("Removed %d orphaned blocks from %s "+
-				"(heights %d to %d)", initialHeight-height,
-				indexer.Name(), height+1, initialHeight)
-		}
 	}
 
 	// Fetch the current tip heights for each index along with tracking the
 	// lowest one so the catchup code only needs to start at the earliest
-	// block and is able to skip connecting the block for the indexes that
-	// don't need it.
-	bestHeight := chain.BestSnapshot().Height
-	lowestHeight := bestHeight
-	indexerHeights := make([]int32, len(m.enabledIndexes))
+	// block.
+	best := chain.BestSnapshot()
+	lowestHeight := best.Height
 	err = m.db.View(func(dbTx database.Tx) error {
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
+
+		m.chainTip = best.Hash
 		for i, indexer := range m.enabledIndexes {
 			idxKey := indexer.Key()
 			hash, height, err := dbFetchIndexerTip(dbTx, idxKey)
@@ -378,7 +470,10 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 
 			log.Debugf("Current %s tip (height %d, hash %v)",
 				indexer.Name(), height, hash)
-			indexerHeights[i] = height
+			m.states[i] = indexState{
+				synced: height == best.Height,
+				height: height,
+			}
 			if height < lowestHeight {
 				lowestHeight = height
 			}
@@ -390,70 +485,271 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 	}
 
 	// Nothing to index if all of the indexes are caught up.
-	if lowestHeight == bestHeight {
+	if lowestHeight == best.Height {
 		return nil
 	}
 
-	// Create a progress logger for the indexing process below.
-	progressLogger := newBlockProgressLogger("Indexed", log)
+	// The indexes can't be caught up when the blocks they need have been
+	// pruned.
+	pruneHeight, pruned := chain.PruneHeight()
+	if pruned && lowestHeight+1 < pruneHeight {
+		return fmt.Errorf("unable to catch up indexes from height %d "+
+			"since blocks prior to height %d have been pruned -- "+
+			"drop the indexes that are behind or disable them",
+			lowestHeight, pruneHeight)
+	}
 
 	// At this point, one or more indexes are behind the current best chain
-	// tip and need to be caught up, so log the details and loop through
-	// each block that needs to be indexed.
-	log.Infof("Catching up indexes from height %d to %d", lowestHeight,
-		bestHeight)
-	for height := lowestHeight + 1; height <= bestHeight; height++ {
-		// Load the block for the height since it is required to index
-		// it.
-		block, err := chain.BlockByHeight(height)
+	// tip and need to be caught up, so log the details and start catching
+	// them up in the background.
+	log.Infof("Catching up indexes from height %d to %d in the background",
+		lowestHeight, best.Height)
+	m.wg.Add(1)
+	go m.catchUpHandler(chain, interrupt)
+	return nil
+}
+
+// catchUpHandler connects the blocks of the main chain to the indexes that are
+// behind it one block at a time until all of them have caught up, the manager
+// is stopped, or the passed interrupt channel is closed.
+//
+// It must be run as a goroutine.
+func (m *Manager) catchUpHandler(chain *blockchain.BlockChain,
+	interrupt <-chan struct{}) {
+
+	defer m.wg.Done()
+
+	progressLogger := newBlockProgressLogger("Indexed", log)
+	for {
+		done, err := m.catchUpBlock(chain, progressLogger)
 		if err != nil {
-			return err
+			log.Errorf("Unable to catch up indexes: %v", err)
+			m.catchUpErr = err
+			return
+		}
+		if done {
+			log.Infof("Indexes caught up to height %d",
+				chain.BestSnapshot().Height)
+			return
 		}
 
-		if interruptRequested(interrupt) {
-			return errInterruptRequested
+		select {
+		case <-m.quit:
+			return
+		case <-interrupt:
+			m.catchUpErr = errInterruptRequested
+			return
+		default:
+		}
+	}
+}
+
+// catchUpBlock connects the block after the lowest tip of the indexes that
+// are still catching up to each of them whose tip it extends.  It returns true
+// once all of the indexes have caught up.
+func (m *Manager) catchUpBlock(chain *blockchain.BlockChain,
+	progressLogger *blockProgressLogger) (bool, error) {
+
+	// Find the lowest tip of the indexes that are still catching up.
+	m.mtx.Lock()
+	lowestHeight := int32(-1)
+	var needsInputs, catchingUp bool
+	for i, indexer := range m.enabledIndexes {
+		state := &m.states[i]
+		if state.synced {
+			continue
+		}
+		if !catchingUp || state.height < lowestHeight {
+			lowestHeight = state.height
+		}
+		catchingUp = true
+		needsInputs = needsInputs || indexNeedsInputs(indexer)
+	}
+	m.mtx.Unlock()
+	if !catchingUp {
+		return true, nil
+	}
+
+	// Load the next block along with the outputs it spends when needed.
+	// The block is not available for a moment when the indexes are at the
+	// tip of the main chain while the chain is connecting the next block,
+	// in which case that block is handed to the indexes directly.
+	height := lowestHeight + 1
+	block, err := chain.BlockByHeight(height)
+	if err != nil {
+		if height > chain.BestSnapshot().Height {
+			m.waitRetry()
+			return false, nil
+		}
+		return false, err
+	}
+	var spentTxos []blockchain.SpentTxOut
+	if needsInputs {
+		spentTxos, err = chain.FetchSpendJournal(block)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// Connect the block to each of the indexes that are still catching up
+	// and whose tip is the parent of the block.  The state is updated
+	// within the same database transaction so the chain can't connect or
+	// disconnect a block in between.
+	var connected bool
+	err = m.db.Update(func(dbTx database.Tx) error {
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
+
+		// The chain might have been reorganized since the block was
+		// loaded, in which case it must not be connected.
+		if !blockchain.DBMainChainHasBlock(dbTx, block.Hash()) {
+			return nil
 		}
 
-		// Connect the block for all indexes that need it.
-		var spentTxos []blockchain.SpentTxOut
+		prevHash := &block.MsgBlock().Header.PrevBlock
 		for i, indexer := range m.enabledIndexes {
-			// Skip indexes that don't need to be updated with this
-			// block.
-			if indexerHeights[i] >= height {
+			state := &m.states[i]
+			if state.synced {
 				continue
 			}
-
-			// When the index requires all of the referenced txouts
-			// and they haven't been loaded yet, they need to be
-			// retrieved from the spend journal.
-			if spentTxos == nil && indexNeedsInputs(indexer) {
-				spentTxos, err = chain.FetchSpendJournal(block)
-				if err != nil {
-					return err
-				}
+			tipHash, _, err := dbFetchIndexerTip(dbTx, indexer.Key())
+			if err != nil {
+				return err
+			}
+			if !tipHash.IsEqual(prevHash) {
+				continue
 			}
 
-			err := m.db.Update(func(dbTx database.Tx) error {
-				return dbIndexConnectBlock(
-					dbTx, indexer, block, spentTxos,
-				)
-			})
+			err = dbIndexConnectBlock(dbTx, indexer, block, spentTxos)
 			if err != nil {
 				return err
 			}
-			indexerHeights[i] = height
+			state.height = height
+			connected = true
+
+			// The index is caught up once it reaches the tip
+			// of the main chain.
+			if block.Hash().IsEqual(&m.chainTip) {
+				state.synced = true
+				log.Infof("Caught up %s to height %d",
+					indexer.Name(), height)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
 
-		// Log indexing progress.
-		progressLogger.LogBlockHeight(block)
+	// The block does not extend any of the indexes when the main chain was
+	// reorganized after it was loaded.  The tip of an index that is still
+	// catching up might not be in the main chain anymore either when the
+	// reorganization disconnected it, so roll those back before trying
+	// again once the chain has settled.
+	if !connected {
+		for i := len(m.enabledIndexes); i > 0; i-- {
+			m.mtx.Lock()
+			synced := m.states[i-1].synced
+			m.mtx.Unlock()
+			if synced {
+				continue
+			}
+			err := m.rollbackOrphanedTip(chain, i-1, m.quit)
+			if err != nil && err != errInterruptRequested {
+				return false, err
+			}
+		}
+		m.waitRetry()
+		return false, nil
+	}
 
-		if interruptRequested(interrupt) {
-			return errInterruptRequested
+	progressLogger.LogBlockHeight(block)
+	return false, nil
+}
+
+// waitRetry waits for the catch-up retry interval to pass or the manager to be
+// stopped, whichever comes first.
+func (m *Manager) waitRetry() {
+	select {
+	case <-m.quit:
+	case <-time.After(catchUpRetryInterval):
+	}
+}
+
+// WaitForCatchUp blocks until the indexes that were behind the main chain when
+// the manager was initialized have caught up with it and returns the error that
+// stopped catching them up early, if any.  It is intended for offline tools
+// that update the indexes while the chain is not processing any blocks, since
+// the indexes would otherwise never stop catching up with a growing chain.
+func (m *Manager) WaitForCatchUp() error {
+	m.wg.Wait()
+	return m.catchUpErr
+}
+
+// Stop stops catching up the indexes in the background and waits for it to
+// finish.  It must only be called once.
+func (m *Manager) Stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
+
+// OldestNeededHeight returns the height of the oldest main chain block the
+// indexes that are still catching up need next and whether or not any of them
+// are still catching up.
+//
+// This is part of the blockchain.IndexManager interface.
+func (m *Manager) OldestNeededHeight() (int32, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	var lowestHeight int32
+	var catchingUp bool
+	for _, state := range m.states {
+		if state.synced {
+			continue
 		}
+		if !catchingUp || state.height < lowestHeight {
+			lowestHeight = state.height
+		}
+		catchingUp = true
 	}
+	return lowestHeight + 1, catchingUp
+}
 
-	log.Infof("Indexes caught up to height %d", bestHeight)
-	return nil
+// SyncState returns whether or not the passed index has caught up with the
+// main chain along with the height of its current tip.  An index that is not
+// managed by the index manager is reported as not synced with a height of -1.
+//
+// This function is safe for concurrent access.
+func (m *Manager) SyncState(indexer Indexer) (bool, int32) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for i, enabled := range m.enabledIndexes {
+		if enabled == indexer {
+			return m.states[i].synced, m.states[i].height
+		}
+	}
+	return false, -1
+}
+
+// IndexInfo returns the sync state of each of the indexes managed by the index
+// manager in the order they were enabled.
+//
+// This function is safe for concurrent access.
+func (m *Manager) IndexInfo() []IndexInfo {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	infos := make([]IndexInfo, 0, len(m.enabledIndexes))
+	for i, indexer := range m.enabledIndexes {
+		infos = append(infos, IndexInfo{
+			Name:   indexer.Name(),
+			Synced: m.states[i].synced,
+			Height: m.states[i].height,
+		})
+	}
+	return infos
 }
 
 // indexNeedsInputs returns whether or not the index needs access to the txouts
@@ -496,19 +792,40 @@ func dbFetchTx(dbTx database.Tx, hash *chainhash.Hash) (*wire.MsgTx, error) {
 
 // ConnectBlock must be invoked when a block is extending the main chain.  It
 // keeps track of the state of each index it is managing, performs some sanity
-// checks, and invokes each indexer.
+// checks, and invokes each indexer.  Indexes that are still catching up in the
+// background are skipped unless the block extends their tip, in which case
+// they have caught up and are updated along with the chain from then on.
 //
 // This is part of the blockchain.IndexManager interface.
 func (m *Manager) ConnectBlock(dbTx database.Tx, block *btcutil.Block,
 	stxos []blockchain.SpentTxOut) error {
 
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	// Call each of the currently active optional indexes with the block
 	// being connected so they can update accordingly.
-	for _, index := range m.enabledIndexes {
+	m.chainTip = *block.Hash()
+	for i, index := range m.enabledIndexes {
+		state := &m.states[i]
+		if !state.synced {
+			tipHash, _, err := dbFetchIndexerTip(dbTx, index.Key())
+			if err != nil {
+				return err
+			}
+			if !tipHash.IsEqual(&block.MsgBlock().Header.PrevBlock) {
+				continue
+			}
+			state.synced = true
+			log.Infof("Caught up %s to height %d", index.Name(),
+				block.Height())
+		}
+
 		err := dbIndexConnectBlock(dbTx, index, block, stxos)
 		if err != nil {
 			return err
 		}
+		state.height = block.Height()
 	}
 	return nil
 }
@@ -516,19 +833,36 @@ func (m *Manager) ConnectBlock(dbTx database.Tx, block *btcutil.Block,
 // DisconnectBlock must be invoked when a block is being disconnected from the
 // end of the main chain.  It keeps track of the state of each index it is
 // managing, performs some sanity checks, and invokes each indexer to remove
-// the index entries associated with the block.
+// the index entries associated with the block.  Indexes that are still
+// catching up in the background are skipped unless the block is their tip.
 //
 // This is part of the blockchain.IndexManager interface.
 func (m *Manager) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
 	stxo []blockchain.SpentTxOut) error {
 
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	// Call each of the currently active optional indexes with the block
 	// being disconnected so they can update accordingly.
-	for _, index := range m.enabledIndexes {
+	m.chainTip = block.MsgBlock().Header.PrevBlock
+	for i, index := range m.enabledIndexes {
+		state := &m.states[i]
+		if !state.synced {
+			tipHash, _, err := dbFetchIndexerTip(dbTx, index.Key())
+			if err != nil {
+				return err
+			}
+			if !tipHash.IsEqual(block.Hash()) {
+				continue
+			}
+		}
+
 		err := dbIndexDisconnectBlock(dbTx, index, block, stxo)
 		if err != nil {
 			return err
 		}
+		state.height = block.Height() - 1
 	}
 	return nil
 }
@@ -538,9 +872,15 @@ func (m *Manager) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
 // The manager returned satisfies the blockchain.IndexManager interface and thus
 // cleanly plugs into the normal blockchain processing path.
 func NewManager(db database.DB, enabledIndexes []Indexer) *Manager {
+	states := make([]indexState, len(enabledIndexes))
+	for i := range states {
+		states[i].height = -1
+	}
 	return &Manager{
 		db:             db,
 		enabledIndexes: enabledIndexes,
+		states:         states,
+		quit:           make(chan struct{}),
 	}
 }
 
@@ -0,0 +1,178 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testIndexer is an indexer that records the heights of the blocks connected
+// to it.
+type testIndexer struct {
+	name    string
+	heights []int32
+}
+
+func (idx *testIndexer) Key() []byte                   { return []byte(idx.name) }
+func (idx *testIndexer) Name() string                  { return idx.name }
+func (idx *testIndexer) Create(dbTx database.Tx) error { return nil }
+func (idx *testIndexer) Init() error                   { return nil }
+
+func (idx *testIndexer) ConnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	idx.heights = append(idx.heights, block.Height())
+	return nil
+}
+
+func (idx *testIndexer) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	idx.heights = idx.heights[:len(idx.heights)-1]
+	return nil
+}
+
+// TestManagerCatchUpHandover ensures the index manager only updates indexes
+// that are still catching up with the main chain once the connected block
+// extends their tip and that it keeps track of their sync state.
+func TestManagerCatchUpHandover(t *testing.T) {
+	t.Parallel()
+
+	db := createTestDB(t)
+
+	// Create a chain of blocks where each block builds on the previous one.
+	blocks := make([]*btcutil.Block, 4)
+	var prevHash chainhash.Hash
+	for i := range blocks {
+		blocks[i] = btcutil.NewBlock(&wire.MsgBlock{
+			Header: wire.BlockHeader{PrevBlock: prevHash, Nonce: uint32(i)},
+		})
+		blocks[i].SetHeight(int32(i))
+		prevHash = *blocks[i].Hash()
+	}
+
+	// Create a manager with an index that is synced to the second block and
+	// one that is still at the first block.
+	synced := &testIndexer{name: "synced", heights: []int32{0, 1}}
+	behind := &testIndexer{name: "behind", heights: []int32{0}}
+	m := NewManager(db, []Indexer{synced, behind})
+	err := db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		_, err := meta.CreateBucket(indexTipsBucketName)
+		if err != nil {
+			return err
+		}
+		err = dbPutIndexerTip(dbTx, synced.Key(), blocks[1].Hash(), 1)
+		if err != nil {
+			return err
+		}
+		return dbPutIndexerTip(dbTx, behind.Key(), blocks[0].Hash(), 0)
+	})
+	if err != nil {
+		t.Fatalf("unable to create index tips: %v", err)
+	}
+	m.states[0] = indexState{synced: true, height: 1}
+	m.states[1] = indexState{height: 0}
+	m.chainTip = *blocks[1].Hash()
+
+	connect := func(block *btcutil.Block) {
+		t.Helper()
+		err := db.Update(func(dbTx database.Tx) error {
+			return m.ConnectBlock(dbTx, block, nil)
+		})
+		if err != nil {
+			t.Fatalf("ConnectBlock: %v", err)
+		}
+	}
+	disconnect := func(block *btcutil.Block) {
+		t.Helper()
+		err := db.Update(func(dbTx database.Tx) error {
+			return m.DisconnectBlock(dbTx, block, nil)
+		})
+		if err != nil {
+			t.Fatalf("DisconnectBlock: %v", err)
+		}
+	}
+	checkState := func(desc string, want []IndexInfo, wantSynced,
+		wantBehind []int32) {
+
+		t.Helper()
+		if got := m.IndexInfo(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: unexpected index info %+v, want %+v",
+				desc, got, want)
+		}
+		if !reflect.DeepEqual(synced.heights, wantSynced) ||
+			!reflect.DeepEqual(behind.heights, wantBehind) {
+
+			t.Fatalf("%s: unexpected connected blocks %v and %v, "+
+				"want %v and %v", desc, synced.heights,
+				behind.heights, wantSynced, wantBehind)
+		}
+	}
+
+	// Blocks after the tip of the index that is behind are still needed.
+	height, needed := m.OldestNeededHeight()
+	if !needed || height != 1 {
+		t.Fatalf("unexpected oldest needed height %d (needed %v)",
+			height, needed)
+	}
+
+	// The index that is behind must be skipped since the block does not
+	// extend its tip.
+	connect(blocks[2])
+	checkState("connect behind", []IndexInfo{
+		{Name: "synced", Synced: true, Height: 2},
+		{Name: "behind", Synced: false, Height: 0},
+	}, []int32{0, 1, 2}, []int32{0})
+
+	// Disconnecting a block that is not the tip of the index that is
+	// behind must skip it as well.
+	disconnect(blocks[2])
+	checkState("disconnect behind", []IndexInfo{
+		{Name: "synced", Synced: true, Height: 1},
+		{Name: "behind", Synced: false, Height: 0},
+	}, []int32{0, 1}, []int32{0})
+
+	// Catch up the index that is behind and ensure it is handed over once
+	// the connected block extends its tip.
+	err = db.Update(func(dbTx database.Tx) error {
+		return dbIndexConnectBlock(dbTx, behind, blocks[1], nil)
+	})
+	if err != nil {
+		t.Fatalf("unable to catch up index: %v", err)
+	}
+	m.states[1].height = 1
+	connect(blocks[2])
+	connect(blocks[3])
+	checkState("handover", []IndexInfo{
+		{Name: "synced", Synced: true, Height: 3},
+		{Name: "behind", Synced: true, Height: 3},
+	}, []int32{0, 1, 2, 3}, []int32{0, 1, 2, 3})
+
+	if _, needed := m.OldestNeededHeight(); needed {
+		t.Fatal("unexpected oldest needed height after handover")
+	}
+
+	var isSynced bool
+	isSynced, height = m.SyncState(behind)
+	if !isSynced || height != 3 {
+		t.Fatalf("unexpected sync state %v at height %d", isSynced,
+			height)
+	}
+
+	// Indexes that are not managed are reported as not synced.
+	isSynced, height = m.SyncState(&testIndexer{name: "unknown"})
+	if isSynced || height != -1 {
+		t.Fatalf("unexpected sync state %v at height %d for unknown "+
+			"index", isSynced, height)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"golang.org/x/crypto/chacha20"
+)
+
+const (
+	// muHashElementSize is the size in bytes of the 3072-bit numbers used
+	// by MuHash3072.
+	muHashElementSize = 384
+
+	// MuHashStateSize is the size in bytes of a serialized MuHash3072
+	// state.
+	MuHashStateSize = muHashElementSize * 2
+)
+
+// muHashPrime is the prime 2^3072 - 1103717 that defines the multiplicative
+// group used by MuHash3072.
+var muHashPrime = func() *big.Int {
+	p := new(big.Int).Lsh(big.NewInt(1), 3072)
+	return p.Sub(p, big.NewInt(1103717))
+}()
+
+// MuHash3072 is a rolling hash of a set of byte strings that is independent of
+// the order in which the elements are added.  Elements can be added and
+// removed in any order, which makes it suitable for maintaining a commitment to
+// the utxo set that is updated incrementally as blocks are connected and
+// disconnected.
+//
+// It follows the construction used by Bitcoin Core, so the final hashes can be
+// compared against those reported by it.  Each element is hashed with SHA256,
+// expanded to a 3072-bit number with ChaCha20 and then multiplied into the
+// numerator when it is added or into the denominator when it is removed.
+type MuHash3072 struct {
+	numerator   big.Int
+	denominator big.Int
+}
+
+// NewMuHash3072 returns a MuHash3072 for the empty set.
+func NewMuHash3072() *MuHash3072 {
+	var m MuHash3072
+	m.numerator.SetInt64(1)
+	m.denominator.SetInt64(1)
+	return &m
+}
+
+// muHashElement returns the 3072-bit number that represents the passed data.
+func muHashElement(data []byte) *big.Int {
+	key := sha256.Sum256(data)
+	var nonce [chacha20.NonceSize]byte
+	cipher, err := chacha20.NewUnauthenticatedCipher(key[:], nonce[:])
+	if err != nil {
+		// The key and nonce sizes are always valid.
+		panic(err)
+	}
+	var stream [muHashElementSize]byte
+	cipher.XORKeyStream(stream[:], stream[:])
+
+	return new(big.Int).SetBytes(reverseBytes(stream[:]))
+}
+
+// Add adds the passed data to the set.
+func (m *MuHash3072) Add(data []byte) {
+	m.numerator.Mul(&m.numerator, muHashElement(data))
+	m.numerator.Mod(&m.numerator, muHashPrime)
+}
+
+// Remove removes the passed data from the set.
+func (m *MuHash3072) Remove(data []byte) {
+	m.denominator.Mul(&m.denominator, muHashElement(data))
+	m.denominator.Mod(&m.denominator, muHashPrime)
+}
+
+// normalize combines the numerator and denominator into the numerator and
+// resets the denominator to one.
+func (m *MuHash3072) normalize() {
+	inverse := new(big.Int).ModInverse(&m.denominator, muHashPrime)
+	m.numerator.Mul(&m.numerator, inverse)
+	m.numerator.Mod(&m.numerator, muHashPrime)
+	m.denominator.SetInt64(1)
+}
+
+// Finalize returns the 256-bit hash of the set.  The returned hash is in the
+// same byte order as Bitcoin Core uses, so its string form is identical.
+func (m *MuHash3072) Finalize() chainhash.Hash {
+	m.normalize()
+	var data [muHashElementSize]byte
+	putMuHashElement(data[:], &m.numerator)
+	return chainhash.Hash(sha256.Sum256(data[:]))
+}
+
+// Serialize returns the serialized state of the hash so it can be restored
+// with DeserializeMuHash3072.
+func (m *MuHash3072) Serialize() []byte {
+	serialized := make([]byte, MuHashStateSize)
+	putMuHashElement(serialized, &m.numerator)
+	putMuHashElement(serialized[muHashElementSize:], &m.denominator)
+	return serialized
+}
+
+// DeserializeMuHash3072 restores a MuHash3072 from a state that was serialized
+// with Serialize.
+func DeserializeMuHash3072(serialized []byte) (*MuHash3072, error) {
+	if len(serialized) != MuHashStateSize {
+		return nil, errDeserialize("unexpected muhash state size")
+	}
+
+	var m MuHash3072
+	m.numerator.SetBytes(reverseBytes(serialized[:muHashElementSize]))
+	m.denominator.SetBytes(reverseBytes(serialized[muHashElementSize:]))
+	return &m, nil
+}
+
+// putMuHashElement serializes the passed number as a little-endian 3072-bit
+// number into the target, which must be at least muHashElementSize bytes.
+func putMuHashElement(target []byte, n *big.Int) {
+	var be [muHashElementSize]byte
+	n.FillBytes(be[:])
+	for i := 0; i < muHashElementSize; i++ {
+		target[i] = be[muHashElementSize-1-i]
+	}
+}
+
+// reverseBytes returns a copy of the passed bytes in reverse order.
+func reverseBytes(b []byte) []byte {
+	reversed := make([]byte, len(b))
+	for i := range b {
+		reversed[i] = b[len(b)-1-i]
+	}
+	return reversed
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// muHashTestElement returns the 32-byte test element used by the Bitcoin Core
+// MuHash3072 tests for the passed value.
+func muHashTestElement(i byte) []byte {
+	var data [32]byte
+	data[0] = i
+	return data[:]
+}
+
+// TestMuHash3072 ensures the MuHash3072 implementation matches the Bitcoin Core
+// test vector and that the hash does not depend on the order in which elements
+// are added and removed.
+func TestMuHash3072(t *testing.T) {
+	t.Parallel()
+
+	// Bitcoin Core: MuHash3072(0) * MuHash3072(1) / MuHash3072(2).
+	want, err := chainhash.NewHashFromStr("10d312b100cbd32ada024a6646e40d3" +
+		"482fcff103668d2625f10002a607d5863")
+	if err != nil {
+		t.Fatalf("NewHashFromStr: %v", err)
+	}
+	m := NewMuHash3072()
+	m.Add(muHashTestElement(0))
+	m.Add(muHashTestElement(1))
+	m.Remove(muHashTestElement(2))
+	if got := m.Finalize(); got != *want {
+		t.Fatalf("unexpected hash %v, want %v", got, want)
+	}
+
+	// Removing an element before it is added and adding elements in a
+	// different order must result in the same hash.
+	m2 := NewMuHash3072()
+	m2.Remove(muHashTestElement(2))
+	m2.Add(muHashTestElement(1))
+	m2.Add(muHashTestElement(3))
+	m2.Add(muHashTestElement(0))
+	m2.Remove(muHashTestElement(3))
+	if got := m2.Finalize(); got != *want {
+		t.Fatalf("unexpected hash after reordering %v, want %v", got,
+			want)
+	}
+
+	// The empty set must hash the same regardless of the elements that
+	// were added and removed again.
+	empty := NewMuHash3072().Finalize()
+	m3 := NewMuHash3072()
+	m3.Add(muHashTestElement(5))
+	m3.Remove(muHashTestElement(5))
+	if got := m3.Finalize(); got != empty {
+		t.Fatalf("unexpected empty set hash %v, want %v", got, empty)
+	}
+
+	// The state must survive a serialization round trip.
+	m4 := NewMuHash3072()
+	m4.Add(muHashTestElement(0))
+	m4.Remove(muHashTestElement(2))
+	m4, err = DeserializeMuHash3072(m4.Serialize())
+	if err != nil {
+		t.Fatalf("DeserializeMuHash3072: %v", err)
+	}
+	m4.Add(muHashTestElement(1))
+	if got := m4.Finalize(); got != *want {
+		t.Fatalf("unexpected hash after round trip %v, want %v", got,
+			want)
+	}
+	if _, err := DeserializeMuHash3072(nil); err == nil {
+		t.Fatal("DeserializeMuHash3072: expected error for empty state")
+	}
+}
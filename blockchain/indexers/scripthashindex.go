@@ -0,0 +1,663 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// scriptHashIndexName is the human-readable name for the index.
+	scriptHashIndexName = "script hash index"
+
+	// scriptHashHistoryPrefix is the prefix of the keys of the history
+	// entries in the script hash index.
+	scriptHashHistoryPrefix = 'h'
+
+	// scriptHashUtxoPrefix is the prefix of the keys of the unspent output
+	// entries in the script hash index.
+	scriptHashUtxoPrefix = 'u'
+
+	// scriptHashHistoryKeySize is the size of the key of a history entry.
+	// It consists of the 1 byte prefix + 32 bytes script hash + 4 bytes
+	// block height + 4 bytes transaction position.
+	scriptHashHistoryKeySize = 1 + chainhash.HashSize + 4 + 4
+
+	// scriptHashUtxoKeySize is the size of the key of an unspent output
+	// entry.  It consists of the 1 byte prefix + 32 bytes script hash + 32
+	// bytes transaction hash + 4 bytes output index.
+	scriptHashUtxoKeySize = 1 + chainhash.HashSize + chainhash.HashSize + 4
+
+	// scriptHashUtxoEntrySize is the size of the value of an unspent output
+	// entry.  It consists of the 4 bytes block height + 8 bytes amount.
+	scriptHashUtxoEntrySize = 4 + 8
+)
+
+var (
+	// scriptHashIndexKey is the key of the script hash index and the db
+	// bucket used to house it.
+	scriptHashIndexKey = []byte("scripthashidx")
+)
+
+// -----------------------------------------------------------------------------
+// The script hash index maps the single SHA256 hash of every public key script
+// referenced in the main chain, which is what the Electrum protocol calls a
+// script hash, to the transactions that involve it and to its currently unspent
+// outputs.  Unlike the address index, it covers every script, including those
+// that do not encode a standard address.
+//
+// Both kinds of entries are housed in the same bucket and are distinguished by
+// a one byte prefix.  Since the script hash follows the prefix, all entries for
+// a given script hash and kind are adjacent and can be iterated with a cursor.
+//
+// The serialized key format for a history entry is:
+//
+//   'h'<script hash><block height><tx position> = <tx hash>
+//
+//   Field           Type              Size
+//   prefix          byte              1 byte
+//   script hash     chainhash.Hash    32 bytes
+//   block height    uint32 (BE)       4 bytes
+//   tx position     uint32 (BE)       4 bytes
+//   tx hash         chainhash.Hash    32 bytes
+//   -----
+//   Total: 73 bytes
+//
+// The height and position are big endian so the history entries of a script
+// hash are iterated in the order the transactions appear in the chain.  A
+// transaction that involves the same script hash more than once only has a
+// single entry.
+//
+// The serialized key format for an unspent output entry is:
+//
+//   'u'<script hash><tx hash><output index> = <block height><amount>
+//
+//   Field           Type              Size
+//   prefix          byte              1 byte
+//   script hash     chainhash.Hash    32 bytes
+//   tx hash         chainhash.Hash    32 bytes
+//   output index    uint32            4 bytes
+//   block height    uint32            4 bytes
+//   amount          uint64            8 bytes
+//   -----
+//   Total: 81 bytes
+// -----------------------------------------------------------------------------
+
+// ScriptHash returns the script hash the index uses for the provided public key
+// script.  Its string representation is the byte-reversed hex encoding used by
+// the Electrum protocol.
+func ScriptHash(pkScript []byte) chainhash.Hash {
+	return chainhash.HashH(pkScript)
+}
+
+// ScriptHashTx describes a transaction in the main chain that involves a script
+// hash.
+type ScriptHashTx struct {
+	// TxHash is the hash of the transaction.
+	TxHash chainhash.Hash
+
+	// Height is the height of the block that contains the transaction.
+	Height int32
+}
+
+// ScriptHashUtxo describes an unspent output that pays to a script hash.
+type ScriptHashUtxo struct {
+	// OutPoint identifies the output.
+	OutPoint wire.OutPoint
+
+	// Height is the height of the block that contains the transaction
+	// that created the output.  It is zero for unconfirmed outputs.
+	Height int32
+
+	// Amount is the value of the output in satoshi.
+	Amount int64
+}
+
+// UnconfirmedScriptHashTx describes a transaction in the memory pool that
+// involves a script hash.
+type UnconfirmedScriptHashTx struct {
+	// TxHash is the hash of the transaction.
+	TxHash chainhash.Hash
+
+	// Fee is the fee paid by the transaction in satoshi.
+	Fee int64
+
+	// HasUnconfirmedInputs is set when the transaction spends an output
+	// of another transaction that is still in the memory pool.
+	HasUnconfirmedInputs bool
+
+	// Received is the sum of the outputs of the transaction that pay to
+	// the script hash.
+	Received int64
+
+	// Sent is the sum of the outputs paying to the script hash that are
+	// spent by the transaction.
+	Sent int64
+}
+
+// scriptHashHistoryKey returns the key of the history entry for the provided
+// script hash, block height and position of the transaction in the block.
+func scriptHashHistoryKey(scriptHash *chainhash.Hash, height int32, txIdx int) []byte {
+	key := make([]byte, scriptHashHistoryKeySize)
+	key[0] = scriptHashHistoryPrefix
+	copy(key[1:], scriptHash[:])
+	binary.BigEndian.PutUint32(key[1+chainhash.HashSize:], uint32(height))
+	binary.BigEndian.PutUint32(key[1+chainhash.HashSize+4:], uint32(txIdx))
+	return key
+}
+
+// scriptHashUtxoKey returns the key of the unspent output entry for the
+// provided script hash and outpoint.
+func scriptHashUtxoKey(scriptHash *chainhash.Hash, outpoint *wire.OutPoint) []byte {
+	key := make([]byte, scriptHashUtxoKeySize)
+	key[0] = scriptHashUtxoPrefix
+	copy(key[1:], scriptHash[:])
+	copy(key[1+chainhash.HashSize:], outpoint.Hash[:])
+	byteOrder.PutUint32(key[1+2*chainhash.HashSize:], outpoint.Index)
+	return key
+}
+
+// serializeScriptHashUtxo returns the value of an unspent output entry for the
+// provided block height and amount.
+func serializeScriptHashUtxo(height int32, amount int64) []byte {
+	value := make([]byte, scriptHashUtxoEntrySize)
+	byteOrder.PutUint32(value, uint32(height))
+	byteOrder.PutUint64(value[4:], uint64(amount))
+	return value
+}
+
+// scriptHashPrefix returns the key prefix shared by all entries of the provided
+// kind for the provided script hash.
+func scriptHashPrefix(kind byte, scriptHash *chainhash.Hash) []byte {
+	prefix := make([]byte, 1+chainhash.HashSize)
+	prefix[0] = kind
+	copy(prefix[1:], scriptHash[:])
+	return prefix
+}
+
+// dbUpdateScriptHashIndex uses an existing database transaction to apply the
+// changes the passed block makes to the script hash index.  When connect is
+// false, the changes are undone instead.
+//
+// The spent outputs must be in the order the inputs of the block that spend
+// them appear.
+func dbUpdateScriptHashIndex(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut, connect bool) error {
+
+	bucket := dbTx.Metadata().Bucket(scriptHashIndexKey)
+	height := block.Height()
+	return forEachBlockTx(block, stxos, connect, func(txIdx int,
+		tx *btcutil.Tx, txStxos []blockchain.SpentTxOut) error {
+
+		// Add or remove a history entry for every script hash the
+		// transaction involves.  Entries for script hashes involved more
+		// than once are simply overwritten or removed again.
+		updateHistory := func(scriptHash *chainhash.Hash) error {
+			key := scriptHashHistoryKey(scriptHash, height, txIdx)
+			if connect {
+				return bucket.Put(key, tx.Hash()[:])
+			}
+			return bucket.Delete(key)
+		}
+
+		// Remove the outputs spent by the transaction from the unspent
+		// outputs of their script hash, or restore them when undoing.
+		for i, txIn := range tx.MsgTx().TxIn[:len(txStxos)] {
+			stxo := &txStxos[i]
+			scriptHash := ScriptHash(stxo.PkScript)
+			if err := updateHistory(&scriptHash); err != nil {
+				return err
+			}
+
+			key := scriptHashUtxoKey(&scriptHash, &txIn.PreviousOutPoint)
+			var err error
+			if connect {
+				err = bucket.Delete(key)
+			} else {
+				err = bucket.Put(key, serializeScriptHashUtxo(
+					stxo.Height, stxo.Amount))
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		// Add the outputs created by the transaction to the unspent
+		// outputs of their script hash, or remove them when undoing.
+		// The outputs of the genesis block are not spendable.
+		outpoint := wire.OutPoint{Hash: *tx.Hash()}
+		for i, txOut := range tx.MsgTx().TxOut {
+			scriptHash := ScriptHash(txOut.PkScript)
+			if err := updateHistory(&scriptHash); err != nil {
+				return err
+			}
+
+			if height == 0 || isUnspendableOutput(txOut.PkScript) {
+				continue
+			}
+			outpoint.Index = uint32(i)
+			key := scriptHashUtxoKey(&scriptHash, &outpoint)
+			var err error
+			if connect {
+				err = bucket.Put(key, serializeScriptHashUtxo(height,
+					txOut.Value))
+			} else {
+				err = bucket.Delete(key)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// dbFetchScriptHashHistory uses an existing database transaction to fetch the
+// transactions in the main chain that involve the provided script hash in the
+// order they appear in the chain.
+func dbFetchScriptHashHistory(dbTx database.Tx, scriptHash *chainhash.Hash) ([]ScriptHashTx, error) {
+	prefix := scriptHashPrefix(scriptHashHistoryPrefix, scriptHash)
+	cursor := dbTx.Metadata().Bucket(scriptHashIndexKey).Cursor()
+
+	var history []ScriptHashTx
+	for ok := cursor.Seek(prefix); ok; ok = cursor.Next() {
+		key := cursor.Key()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+
+		value := cursor.Value()
+		if len(key) != scriptHashHistoryKeySize ||
+			len(value) != chainhash.HashSize {
+
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt script hash "+
+					"history entry for %v", scriptHash),
+			}
+		}
+
+		var entry ScriptHashTx
+		copy(entry.TxHash[:], value)
+		entry.Height = int32(binary.BigEndian.Uint32(key[len(prefix):]))
+		history = append(history, entry)
+	}
+
+	return history, nil
+}
+
+// dbFetchScriptHashUtxos uses an existing database transaction to fetch the
+// unspent outputs in the main chain that pay to the provided script hash.
+func dbFetchScriptHashUtxos(dbTx database.Tx, scriptHash *chainhash.Hash) ([]ScriptHashUtxo, error) {
+	prefix := scriptHashPrefix(scriptHashUtxoPrefix, scriptHash)
+	cursor := dbTx.Metadata().Bucket(scriptHashIndexKey).Cursor()
+
+	var utxos []ScriptHashUtxo
+	for ok := cursor.Seek(prefix); ok; ok = cursor.Next() {
+		key := cursor.Key()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+
+		value := cursor.Value()
+		if len(key) != scriptHashUtxoKeySize ||
+			len(value) != scriptHashUtxoEntrySize {
+
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt script hash "+
+					"unspent output entry for %v", scriptHash),
+			}
+		}
+
+		var utxo ScriptHashUtxo
+		copy(utxo.OutPoint.Hash[:], key[len(prefix):])
+		utxo.OutPoint.Index = byteOrder.Uint32(
+			key[len(prefix)+chainhash.HashSize:])
+		utxo.Height = int32(byteOrder.Uint32(value))
+		utxo.Amount = int64(byteOrder.Uint64(value[4:]))
+		utxos = append(utxos, utxo)
+	}
+
+	return utxos, nil
+}
+
+// unconfirmedSpend describes a previous output spent by a transaction in the
+// memory pool.
+type unconfirmedSpend struct {
+	outpoint   wire.OutPoint
+	scriptHash chainhash.Hash
+	amount     int64
+}
+
+// unconfirmedScriptHashTx houses the information the script hash index keeps
+// about a transaction in the memory pool.
+type unconfirmedScriptHashTx struct {
+	tx           *btcutil.Tx
+	fee          int64
+	spends       []unconfirmedSpend
+	outputHashes []chainhash.Hash
+}
+
+// ScriptHashIndex implements a script hash index as used by the Electrum
+// protocol.  That is to say, it supports querying the history and the unspent
+// outputs of any public key script by its hash, both for the main chain and
+// for the memory pool.
+type ScriptHashIndex struct {
+	// The following fields are set when the instance is created and can't
+	// be changed afterwards, so there is no need to protect them with a
+	// separate mutex.
+	db database.DB
+
+	// The following fields are used to track the transactions that have
+	// not been included into a block yet.  They are protected by the
+	// unconfirmedLock field.
+	//
+	// The txnsByScriptHash field keeps the hashes of the transactions that
+	// involve each script hash.  The unconfirmedTxns field holds the
+	// details of each transaction and the spentOutputs field maps every
+	// output spent by one of them to the hash of the spending transaction.
+	unconfirmedLock  sync.RWMutex
+	txnsByScriptHash map[chainhash.Hash]map[chainhash.Hash]struct{}
+	unconfirmedTxns  map[chainhash.Hash]*unconfirmedScriptHashTx
+	spentOutputs     map[wire.OutPoint]chainhash.Hash
+}
+
+// Ensure the ScriptHashIndex type implements the Indexer interface.
+var _ Indexer = (*ScriptHashIndex)(nil)
+
+// Ensure the ScriptHashIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*ScriptHashIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *ScriptHashIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init initializes the script hash index.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptHashIndex) Init() error {
+	return nil // Nothing to do.
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptHashIndex) Key() []byte {
+	return scriptHashIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptHashIndex) Name() string {
+	return scriptHashIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs to be
+// created for the first time.  It creates the bucket for the script hash index.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptHashIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(scriptHashIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds a history entry for every
+// script hash the transactions in the block involve and updates the unspent
+// outputs of the script hashes accordingly.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptHashIndex) ConnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	return dbUpdateScriptHashIndex(dbTx, block, stxos, true)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the history entries
+// added for the block and restores the unspent outputs it spent.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptHashIndex) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	return dbUpdateScriptHashIndex(dbTx, block, stxos, false)
+}
+
+// History returns the transactions in the main chain that involve the provided
+// script hash in the order they appear in the chain.
+//
+// This function is safe for concurrent access.
+func (idx *ScriptHashIndex) History(scriptHash *chainhash.Hash) ([]ScriptHashTx, error) {
+	var history []ScriptHashTx
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		history, err = dbFetchScriptHashHistory(dbTx, scriptHash)
+		return err
+	})
+	return history, err
+}
+
+// Unspent returns the outputs in the main chain that pay to the provided script
+// hash and are not spent in the main chain.  Outputs that are spent by
+// transactions in the memory pool are included.
+//
+// This function is safe for concurrent access.
+func (idx *ScriptHashIndex) Unspent(scriptHash *chainhash.Hash) ([]ScriptHashUtxo, error) {
+	var utxos []ScriptHashUtxo
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		utxos, err = dbFetchScriptHashUtxos(dbTx, scriptHash)
+		return err
+	})
+	return utxos, err
+}
+
+// AddUnconfirmedTx adds all script hashes related to the transaction to the
+// unconfirmed (memory-only) script hash index.
+//
+// NOTE: This transaction MUST have already been validated by the memory pool
+// before calling this function with it and have all of the inputs available in
+// the provided utxo view.  Failure to do so could result in some or all script
+// hashes not being indexed.
+//
+// This function is safe for concurrent access.
+func (idx *ScriptHashIndex) AddUnconfirmedTx(tx *btcutil.Tx, utxoView *blockchain.UtxoViewpoint) {
+	entry := &unconfirmedScriptHashTx{tx: tx}
+	for _, txIn := range tx.MsgTx().TxIn {
+		utxo := utxoView.LookupEntry(txIn.PreviousOutPoint)
+		if utxo == nil {
+			// Ignore missing entries.  This should never happen
+			// in practice since the function comments specifically
+			// call out all inputs must be available.
+			continue
+		}
+		entry.fee += utxo.Amount()
+		entry.spends = append(entry.spends, unconfirmedSpend{
+			outpoint:   txIn.PreviousOutPoint,
+			scriptHash: ScriptHash(utxo.PkScript()),
+			amount:     utxo.Amount(),
+		})
+	}
+	entry.outputHashes = make([]chainhash.Hash, len(tx.MsgTx().TxOut))
+	for i, txOut := range tx.MsgTx().TxOut {
+		entry.fee -= txOut.Value
+		entry.outputHashes[i] = ScriptHash(txOut.PkScript)
+	}
+
+	idx.unconfirmedLock.Lock()
+	defer idx.unconfirmedLock.Unlock()
+
+	txHash := *tx.Hash()
+	addScriptHash := func(scriptHash chainhash.Hash) {
+		txns := idx.txnsByScriptHash[scriptHash]
+		if txns == nil {
+			txns = make(map[chainhash.Hash]struct{})
+			idx.txnsByScriptHash[scriptHash] = txns
+		}
+		txns[txHash] = struct{}{}
+	}
+	for _, spend := range entry.spends {
+		addScriptHash(spend.scriptHash)
+		idx.spentOutputs[spend.outpoint] = txHash
+	}
+	for _, scriptHash := range entry.outputHashes {
+		addScriptHash(scriptHash)
+	}
+	idx.unconfirmedTxns[txHash] = entry
+}
+
+// RemoveUnconfirmedTx removes the passed transaction from the unconfirmed
+// (memory-only) script hash index.
+//
+// This function is safe for concurrent access.
+func (idx *ScriptHashIndex) RemoveUnconfirmedTx(hash *chainhash.Hash) {
+	idx.unconfirmedLock.Lock()
+	defer idx.unconfirmedLock.Unlock()
+
+	entry, ok := idx.unconfirmedTxns[*hash]
+	if !ok {
+		return
+	}
+
+	// Remove all script hash references to the transaction and remove the
+	// entry for the script hash altogether if it no longer references any
+	// transactions.
+	removeScriptHash := func(scriptHash chainhash.Hash) {
+		delete(idx.txnsByScriptHash[scriptHash], *hash)
+		if len(idx.txnsByScriptHash[scriptHash]) == 0 {
+			delete(idx.txnsByScriptHash, scriptHash)
+		}
+	}
+	for _, spend := range entry.spends {
+		removeScriptHash(spend.scriptHash)
+		if idx.spentOutputs[spend.outpoint] == *hash {
+			delete(idx.spentOutputs, spend.outpoint)
+		}
+	}
+	for _, scriptHash := range entry.outputHashes {
+		removeScriptHash(scriptHash)
+	}
+	delete(idx.unconfirmedTxns, *hash)
+}
+
+// UnconfirmedTxns returns the transactions currently in the unconfirmed
+// (memory-only) script hash index that involve the provided script hash,
+// ordered by their hash.
+//
+// This function is safe for concurrent access.
+func (idx *ScriptHashIndex) UnconfirmedTxns(scriptHash *chainhash.Hash) []UnconfirmedScriptHashTx {
+	idx.unconfirmedLock.RLock()
+	defer idx.unconfirmedLock.RUnlock()
+
+	txns := make([]UnconfirmedScriptHashTx, 0,
+		len(idx.txnsByScriptHash[*scriptHash]))
+	for txHash := range idx.txnsByScriptHash[*scriptHash] {
+		entry := idx.unconfirmedTxns[txHash]
+		tx := UnconfirmedScriptHashTx{TxHash: txHash, Fee: entry.fee}
+		for _, spend := range entry.spends {
+			if _, ok := idx.unconfirmedTxns[spend.outpoint.Hash]; ok {
+				tx.HasUnconfirmedInputs = true
+			}
+			if spend.scriptHash == *scriptHash {
+				tx.Sent += spend.amount
+			}
+		}
+		for i, outputHash := range entry.outputHashes {
+			if outputHash == *scriptHash {
+				tx.Received += entry.tx.MsgTx().TxOut[i].Value
+			}
+		}
+		txns = append(txns, tx)
+	}
+	sort.Slice(txns, func(i, j int) bool {
+		return bytes.Compare(txns[i].TxHash[:], txns[j].TxHash[:]) < 0
+	})
+
+	return txns
+}
+
+// UnconfirmedUnspent returns the outputs created by transactions in the memory
+// pool that pay to the provided script hash and are not spent by another
+// transaction in the memory pool.
+//
+// This function is safe for concurrent access.
+func (idx *ScriptHashIndex) UnconfirmedUnspent(scriptHash *chainhash.Hash) []ScriptHashUtxo {
+	idx.unconfirmedLock.RLock()
+	defer idx.unconfirmedLock.RUnlock()
+
+	var utxos []ScriptHashUtxo
+	for txHash := range idx.txnsByScriptHash[*scriptHash] {
+		entry := idx.unconfirmedTxns[txHash]
+		for i, outputHash := range entry.outputHashes {
+			txOut := entry.tx.MsgTx().TxOut[i]
+			if outputHash != *scriptHash ||
+				isUnspendableOutput(txOut.PkScript) {
+
+				continue
+			}
+			outpoint := wire.OutPoint{Hash: txHash, Index: uint32(i)}
+			if _, ok := idx.spentOutputs[outpoint]; ok {
+				continue
+			}
+			utxos = append(utxos, ScriptHashUtxo{
+				OutPoint: outpoint,
+				Amount:   txOut.Value,
+			})
+		}
+	}
+
+	return utxos
+}
+
+// IsSpentUnconfirmed returns whether the provided output is spent by a
+// transaction in the unconfirmed (memory-only) script hash index.
+//
+// This function is safe for concurrent access.
+func (idx *ScriptHashIndex) IsSpentUnconfirmed(outpoint *wire.OutPoint) bool {
+	idx.unconfirmedLock.RLock()
+	_, ok := idx.spentOutputs[*outpoint]
+	idx.unconfirmedLock.RUnlock()
+	return ok
+}
+
+// NewScriptHashIndex returns a new instance of an indexer that is used to
+// create a mapping of the hashes of all public key scripts in the blockchain to
+// the respective transactions that involve them and their unspent outputs.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewScriptHashIndex(db database.DB) *ScriptHashIndex {
+	return &ScriptHashIndex{
+		db:               db,
+		txnsByScriptHash: make(map[chainhash.Hash]map[chainhash.Hash]struct{}),
+		unconfirmedTxns:  make(map[chainhash.Hash]*unconfirmedScriptHashTx),
+		spentOutputs:     make(map[wire.OutPoint]chainhash.Hash),
+	}
+}
+
+// DropScriptHashIndex drops the script hash index from the provided database if
+// it exists.
+func DropScriptHashIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, scriptHashIndexKey, scriptHashIndexName, interrupt)
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestScriptHashIndex ensures connecting a block adds the history and updates
+// the unspent outputs of every script hash it involves and that disconnecting
+// it again restores the previous state.
+func TestScriptHashIndex(t *testing.T) {
+	t.Parallel()
+
+	db := createTestDB(t)
+
+	idx := NewScriptHashIndex(db)
+	err := db.Update(func(dbTx database.Tx) error {
+		return idx.Create(dbTx)
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	scriptA := []byte{0x51}
+	scriptB := []byte{0x52}
+	hashA := ScriptHash(scriptA)
+	hashB := ScriptHash(scriptB)
+
+	// Add an unspent output paying to script B at height 1.
+	prevOut := wire.OutPoint{Index: 3}
+	prevOut.Hash[0] = 1
+	err = db.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(scriptHashIndexKey)
+		return bucket.Put(scriptHashUtxoKey(&hashB, &prevOut),
+			serializeScriptHashUtxo(1, 5000))
+	})
+	if err != nil {
+		t.Fatalf("unable to add unspent output: %v", err)
+	}
+	initialUtxos := []ScriptHashUtxo{{OutPoint: prevOut, Height: 1,
+		Amount: 5000}}
+
+	// Create a block that spends it and also spends one of the outputs of
+	// a transaction in the same block.
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		SignatureScript:  []byte{0x51, 0x51},
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000, scriptA))
+	tx1 := wire.NewMsgTx(1)
+	tx1.AddTxIn(wire.NewTxIn(&prevOut, nil, nil))
+	tx1.AddTxOut(wire.NewTxOut(3000, scriptA))
+	tx1.AddTxOut(wire.NewTxOut(1000, scriptB))
+	tx2 := wire.NewMsgTx(1)
+	tx2.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: tx1.TxHash(), Index: 1},
+		nil, nil))
+	tx2.AddTxOut(wire.NewTxOut(900, scriptA))
+	block := btcutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, tx1, tx2},
+	})
+	block.SetHeight(100)
+	stxos := []blockchain.SpentTxOut{
+		{Amount: 5000, PkScript: scriptB, Height: 1},
+		{Amount: 1000, PkScript: scriptB, Height: 100},
+	}
+
+	checkState := func(desc string, wantHistoryA, wantHistoryB []ScriptHashTx,
+		wantUtxosA, wantUtxosB []ScriptHashUtxo) {
+
+		t.Helper()
+		for _, test := range []struct {
+			scriptHash  *chainhash.Hash
+			wantHistory []ScriptHashTx
+			wantUtxos   []ScriptHashUtxo
+		}{
+			{&hashA, wantHistoryA, wantUtxosA},
+			{&hashB, wantHistoryB, wantUtxosB},
+		} {
+			history, err := idx.History(test.scriptHash)
+			if err != nil {
+				t.Fatalf("History: %v", err)
+			}
+			if !reflect.DeepEqual(history, test.wantHistory) {
+				t.Fatalf("%s: unexpected history of %v: got %v, "+
+					"want %v", desc, test.scriptHash, history,
+					test.wantHistory)
+			}
+			utxos, err := idx.Unspent(test.scriptHash)
+			if err != nil {
+				t.Fatalf("Unspent: %v", err)
+			}
+			if !reflect.DeepEqual(utxos, test.wantUtxos) {
+				t.Fatalf("%s: unexpected unspent outputs of %v: "+
+					"got %v, want %v", desc, test.scriptHash,
+					utxos, test.wantUtxos)
+			}
+		}
+	}
+
+	// The unspent outputs are ordered by their outpoint.
+	utxosA := []ScriptHashUtxo{
+		{OutPoint: wire.OutPoint{Hash: coinbase.TxHash()}, Height: 100,
+			Amount: 5000},
+		{OutPoint: wire.OutPoint{Hash: tx1.TxHash()}, Height: 100,
+			Amount: 3000},
+		{OutPoint: wire.OutPoint{Hash: tx2.TxHash()}, Height: 100,
+			Amount: 900},
+	}
+	sort.Slice(utxosA, func(i, j int) bool {
+		return bytes.Compare(utxosA[i].OutPoint.Hash[:],
+			utxosA[j].OutPoint.Hash[:]) < 0
+	})
+
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block, stxos)
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: %v", err)
+	}
+	checkState("connect",
+		[]ScriptHashTx{
+			{TxHash: coinbase.TxHash(), Height: 100},
+			{TxHash: tx1.TxHash(), Height: 100},
+			{TxHash: tx2.TxHash(), Height: 100},
+		},
+		[]ScriptHashTx{
+			{TxHash: tx1.TxHash(), Height: 100},
+			{TxHash: tx2.TxHash(), Height: 100},
+		},
+		utxosA, nil)
+
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, block, stxos)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: %v", err)
+	}
+	checkState("disconnect", nil, nil, nil, initialUtxos)
+
+	// Missing spent outputs must be detected.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block, stxos[:1])
+	})
+	if _, ok := err.(AssertError); !ok {
+		t.Fatalf("ConnectBlock: unexpected error %v", err)
+	}
+}
+
+// TestScriptHashIndexUnconfirmed ensures the unconfirmed script hash index
+// tracks the transactions in the memory pool and the outputs they create and
+// spend.
+func TestScriptHashIndexUnconfirmed(t *testing.T) {
+	t.Parallel()
+
+	scriptA := []byte{0x51}
+	scriptB := []byte{0x52}
+	hashA := ScriptHash(scriptA)
+	hashB := ScriptHash(scriptB)
+
+	// Create a confirmed output paying to script A, a transaction that
+	// spends it and a transaction that spends the change of the first
+	// one.
+	funding := wire.NewMsgTx(1)
+	funding.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+	funding.AddTxOut(wire.NewTxOut(10000, scriptA))
+	confirmedOut := wire.OutPoint{Hash: funding.TxHash()}
+	tx1 := wire.NewMsgTx(1)
+	tx1.AddTxIn(wire.NewTxIn(&confirmedOut, nil, nil))
+	tx1.AddTxOut(wire.NewTxOut(4000, scriptB))
+	tx1.AddTxOut(wire.NewTxOut(5000, scriptA))
+	tx2 := wire.NewMsgTx(1)
+	tx2.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: tx1.TxHash(), Index: 1},
+		nil, nil))
+	tx2.AddTxOut(wire.NewTxOut(4500, scriptB))
+
+	view := blockchain.NewUtxoViewpoint()
+	view.AddTxOuts(btcutil.NewTx(funding), 10)
+	view.AddTxOuts(btcutil.NewTx(tx1), 0x7fffffff)
+
+	idx := NewScriptHashIndex(nil)
+	idx.AddUnconfirmedTx(btcutil.NewTx(tx1), view)
+	idx.AddUnconfirmedTx(btcutil.NewTx(tx2), view)
+
+	wantTxnsA := []UnconfirmedScriptHashTx{
+		{TxHash: tx1.TxHash(), Fee: 1000, Received: 5000, Sent: 10000},
+		{TxHash: tx2.TxHash(), Fee: 500, HasUnconfirmedInputs: true,
+			Sent: 5000},
+	}
+	sort.Slice(wantTxnsA, func(i, j int) bool {
+		return bytes.Compare(wantTxnsA[i].TxHash[:],
+			wantTxnsA[j].TxHash[:]) < 0
+	})
+	if txns := idx.UnconfirmedTxns(&hashA); !reflect.DeepEqual(txns, wantTxnsA) {
+		t.Fatalf("unexpected unconfirmed transactions %+v, want %+v",
+			txns, wantTxnsA)
+	}
+	if utxos := idx.UnconfirmedUnspent(&hashA); len(utxos) != 0 {
+		t.Fatalf("unexpected unconfirmed unspent outputs %v", utxos)
+	}
+	if !idx.IsSpentUnconfirmed(&confirmedOut) {
+		t.Fatalf("confirmed output %v is not spent", confirmedOut)
+	}
+
+	// Removing the second transaction must make the change of the first
+	// one unspent again.
+	tx2Hash := tx2.TxHash()
+	idx.RemoveUnconfirmedTx(&tx2Hash)
+	wantUtxosA := []ScriptHashUtxo{{OutPoint: wire.OutPoint{
+		Hash: tx1.TxHash(), Index: 1}, Amount: 5000}}
+	if utxos := idx.UnconfirmedUnspent(&hashA); !reflect.DeepEqual(utxos, wantUtxosA) {
+		t.Fatalf("unexpected unconfirmed unspent outputs %v, want %v",
+			utxos, wantUtxosA)
+	}
+
+	// Removing the first transaction must remove all traces.
+	tx1Hash := tx1.TxHash()
+	idx.RemoveUnconfirmedTx(&tx1Hash)
+	if txns := idx.UnconfirmedTxns(&hashB); len(txns) != 0 {
+		t.Fatalf("unexpected unconfirmed transactions %+v", txns)
+	}
+	if idx.IsSpentUnconfirmed(&confirmedOut) {
+		t.Fatalf("confirmed output %v is still spent", confirmedOut)
+	}
+	if len(idx.txnsByScriptHash) != 0 || len(idx.unconfirmedTxns) != 0 ||
+		len(idx.spentOutputs) != 0 {
+
+		t.Fatal("unconfirmed index is not empty")
+	}
+}
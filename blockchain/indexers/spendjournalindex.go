@@ -0,0 +1,336 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// spendJournalIndexName is the human-readable name for the index.
+	spendJournalIndexName = "spend journal index"
+
+	// SpendJournalRetention is the number of blocks the spent outputs of a
+	// disconnected block are retained for.  The entry of a block is removed
+	// once a block that is this many blocks higher than the disconnected
+	// block is connected to the main chain.  Consumers that fall further
+	// behind than this while the block they processed is reorganized out
+	// of the main chain have to rebuild their index.
+	SpendJournalRetention = 2016
+)
+
+var (
+	// spendJournalIndexKey is the key of the spend journal index and the
+	// db bucket used to house it.
+	spendJournalIndexKey = []byte("spendjournalidx")
+
+	// spendJournalHeightsBucketName is the name of the bucket nested in the
+	// index bucket which orders the entries by the height of their block
+	// so expired entries can be found efficiently.
+	spendJournalHeightsBucketName = []byte("heights")
+)
+
+// -----------------------------------------------------------------------------
+// The spend journal index retains the outputs spent by blocks that have been
+// disconnected from the main chain.  The chain removes the spend journal entry
+// of a block as soon as it is disconnected, which makes it impossible for
+// consumers that learn about the disconnect later on to undo the block without
+// fetching the spent outputs from elsewhere.  The entry of a block is removed
+// again once the block is connected to the main chain since the spend journal
+// of the chain covers it at that point, or when it is more than
+// SpendJournalRetention blocks below the connected block.
+//
+// The serialized format for the keys and values in the index bucket is:
+//
+//   <block hash> = <block height><num stxos><stxo 1>...<stxo n>
+//
+//   Field          Type              Size
+//   block hash     chainhash.Hash    32 bytes
+//   block height   uint32            4 bytes
+//   num stxos      VarInt            variable
+//   stxos          []serializedStxo  variable
+//
+// The serialized format of each spent output is:
+//
+//   <header code><amount><pk script>
+//
+//   Field          Type     Size
+//   header code    VarInt   variable (height shifted left by one and the
+//                                     coinbase flag in the lowest bit)
+//   amount         VarInt   variable
+//   pk script      VarBytes variable
+//
+// The serialized format for the keys in the nested heights bucket, which all
+// have empty values, is:
+//
+//   <block height><block hash>
+//
+//   Field          Type              Size
+//   block height   uint32            4 bytes (big endian)
+//   block hash     chainhash.Hash    32 bytes
+// -----------------------------------------------------------------------------
+
+// spendJournalHeightKey returns the key of the provided block in the nested
+// heights bucket.
+func spendJournalHeightKey(height int32, hash *chainhash.Hash) []byte {
+	key := make([]byte, 4+chainhash.HashSize)
+	binary.BigEndian.PutUint32(key, uint32(height))
+	copy(key[4:], hash[:])
+	return key
+}
+
+// serializeSpendJournalIndexEntry returns the serialized spend journal index
+// entry for the provided block height and spent outputs.
+func serializeSpendJournalIndexEntry(height int32, stxos []blockchain.SpentTxOut) []byte {
+	var buf bytes.Buffer
+	var serializedHeight [4]byte
+	byteOrder.PutUint32(serializedHeight[:], uint32(height))
+	buf.Write(serializedHeight[:])
+
+	// Writing to a bytes.Buffer never fails, so the errors are ignored.
+	_ = wire.WriteVarInt(&buf, 0, uint64(len(stxos)))
+	for i := range stxos {
+		stxo := &stxos[i]
+		headerCode := uint64(stxo.Height) << 1
+		if stxo.IsCoinBase {
+			headerCode |= 0x01
+		}
+		_ = wire.WriteVarInt(&buf, 0, headerCode)
+		_ = wire.WriteVarInt(&buf, 0, uint64(stxo.Amount))
+		_ = wire.WriteVarBytes(&buf, 0, stxo.PkScript)
+	}
+
+	return buf.Bytes()
+}
+
+// deserializeSpendJournalIndexEntry decodes the block height and spent outputs
+// from the provided serialized spend journal index entry.
+func deserializeSpendJournalIndexEntry(serialized []byte) (int32, []blockchain.SpentTxOut, error) {
+	if len(serialized) < 4 {
+		return 0, nil, fmt.Errorf("unexpected end of data")
+	}
+	height := int32(byteOrder.Uint32(serialized[:4]))
+
+	r := bytes.NewReader(serialized[4:])
+	numStxos, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Each spent output takes at least three bytes, so ensure the count
+	// is sane before allocating.
+	if numStxos > uint64(r.Len()/3) {
+		return 0, nil, fmt.Errorf("too many spent outputs: %d", numStxos)
+	}
+	stxos := make([]blockchain.SpentTxOut, numStxos)
+	for i := range stxos {
+		headerCode, err := wire.ReadVarInt(r, 0)
+		if err != nil {
+			return 0, nil, err
+		}
+		amount, err := wire.ReadVarInt(r, 0)
+		if err != nil {
+			return 0, nil, err
+		}
+		pkScript, err := wire.ReadVarBytes(r, 0, wire.MaxMessagePayload,
+			"pkscript")
+		if err != nil {
+			return 0, nil, err
+		}
+
+		stxos[i] = blockchain.SpentTxOut{
+			Amount:     int64(amount),
+			PkScript:   pkScript,
+			Height:     int32(headerCode >> 1),
+			IsCoinBase: headerCode&0x01 != 0,
+		}
+	}
+
+	return height, stxos, nil
+}
+
+// dbFetchDisconnectedBlockStxos uses an existing database transaction to fetch
+// the height and spent outputs retained for the provided disconnected block.
+// When there is no entry for the block, -1 and nil will be returned for the
+// height and spent outputs along with a nil error.
+func dbFetchDisconnectedBlockStxos(dbTx database.Tx, hash *chainhash.Hash) (int32, []blockchain.SpentTxOut, error) {
+	bucket := dbTx.Metadata().Bucket(spendJournalIndexKey)
+	serialized := bucket.Get(hash[:])
+	if serialized == nil {
+		return -1, nil, nil
+	}
+
+	height, stxos, err := deserializeSpendJournalIndexEntry(serialized)
+	if err != nil {
+		return 0, nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt spend journal index "+
+				"entry for %v: %v", hash, err),
+		}
+	}
+	return height, stxos, nil
+}
+
+// SpendJournalIndex implements an index that retains the outputs spent by
+// blocks once they are disconnected from the main chain.  That is to say, it
+// allows consumers to undo the effects of a disconnected block at any later
+// point in time.
+type SpendJournalIndex struct {
+	db database.DB
+}
+
+// Ensure the SpendJournalIndex type implements the Indexer interface.
+var _ Indexer = (*SpendJournalIndex)(nil)
+
+// Init initializes the spend journal index.  It creates the nested heights
+// bucket for indexes that were created without it.
+//
+// This is part of the Indexer interface.
+func (idx *SpendJournalIndex) Init() error {
+	return idx.db.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(spendJournalIndexKey)
+		_, err := bucket.CreateBucketIfNotExists(
+			spendJournalHeightsBucketName)
+		return err
+	})
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *SpendJournalIndex) Key() []byte {
+	return spendJournalIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *SpendJournalIndex) Name() string {
+	return spendJournalIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs to be
+// created for the first time.  It creates the bucket for the spend journal
+// index along with the nested bucket that orders its entries by height.
+//
+// This is part of the Indexer interface.
+func (idx *SpendJournalIndex) Create(dbTx database.Tx) error {
+	bucket, err := dbTx.Metadata().CreateBucket(spendJournalIndexKey)
+	if err != nil {
+		return err
+	}
+	_, err = bucket.CreateBucket(spendJournalHeightsBucketName)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer removes the spent outputs that
+// were retained for the block if it was disconnected before since the spend
+// journal of the chain covers it again.  It also removes the spent outputs of
+// all blocks that are more than SpendJournalRetention blocks below it.
+//
+// This is part of the Indexer interface.
+func (idx *SpendJournalIndex) ConnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	bucket := dbTx.Metadata().Bucket(spendJournalIndexKey)
+	heights := bucket.Bucket(spendJournalHeightsBucketName)
+	err := bucket.Delete(block.Hash()[:])
+	if err != nil {
+		return err
+	}
+	heightKey := spendJournalHeightKey(block.Height(), block.Hash())
+	if err := heights.Delete(heightKey); err != nil {
+		return err
+	}
+
+	// Collect the keys of the expired entries first since the bucket must
+	// not be modified while iterating it with a cursor.
+	expireHeight := block.Height() - SpendJournalRetention
+	var expired [][]byte
+	cursor := heights.Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		key := cursor.Key()
+		if int32(binary.BigEndian.Uint32(key)) >= expireHeight {
+			break
+		}
+		expired = append(expired, append([]byte(nil), key...))
+	}
+	for _, key := range expired {
+		if err := bucket.Delete(key[4:]); err != nil {
+			return err
+		}
+		if err := heights.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer retains the height of the
+// block along with the outputs it spent until the main chain is
+// SpendJournalRetention blocks past it.
+//
+// This is part of the Indexer interface.
+func (idx *SpendJournalIndex) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	serialized := serializeSpendJournalIndexEntry(block.Height(), stxos)
+	bucket := dbTx.Metadata().Bucket(spendJournalIndexKey)
+	err := bucket.Put(block.Hash()[:], serialized)
+	if err != nil {
+		return err
+	}
+	heightKey := spendJournalHeightKey(block.Height(), block.Hash())
+	heights := bucket.Bucket(spendJournalHeightsBucketName)
+	return heights.Put(heightKey, nil)
+}
+
+// DisconnectedBlock returns the height and the spent outputs of the provided
+// block which has been disconnected from the main chain.  The spent outputs
+// are in the order they are spent by the block, which is the same order the
+// chain uses for its spend journal.  When no outputs have been retained for
+// the block, either because it was never disconnected, because it has been
+// connected to the main chain again or because the main chain is more than
+// SpendJournalRetention blocks past it, -1 and nil will be returned for the
+// height and spent outputs along with a nil error.
+//
+// This function is safe for concurrent access.
+func (idx *SpendJournalIndex) DisconnectedBlock(hash *chainhash.Hash) (int32, []blockchain.SpentTxOut, error) {
+	var height int32
+	var stxos []blockchain.SpentTxOut
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		height, stxos, err = dbFetchDisconnectedBlockStxos(dbTx, hash)
+		return err
+	})
+	return height, stxos, err
+}
+
+// NewSpendJournalIndex returns a new instance of an indexer that is used to
+// retain the outputs spent by blocks that are disconnected from the main
+// chain.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewSpendJournalIndex(db database.DB) *SpendJournalIndex {
+	return &SpendJournalIndex{db: db}
+}
+
+// DropSpendJournalIndex drops the spend journal index from the provided
+// database if it exists.
+func DropSpendJournalIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, spendJournalIndexKey, spendJournalIndexName, interrupt)
+}
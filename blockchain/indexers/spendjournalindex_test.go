@@ -0,0 +1,124 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestSpendJournalIndex ensures disconnecting a block retains the outputs it
+// spent and that connecting it again or expiring it removes them.
+func TestSpendJournalIndex(t *testing.T) {
+	t.Parallel()
+
+	db := createTestDB(t)
+
+	idx := NewSpendJournalIndex(db)
+	err := db.Update(func(dbTx database.Tx) error {
+		return idx.Create(dbTx)
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	block := btcutil.NewBlock(&wire.MsgBlock{
+		Header: wire.BlockHeader{Nonce: 1},
+	})
+	block.SetHeight(200)
+	stxos := []blockchain.SpentTxOut{
+		{Amount: 5000000000, PkScript: []byte{0x51}, Height: 100,
+			IsCoinBase: true},
+		{Amount: 1000, PkScript: []byte{0x00, 0x14, 0x01, 0x02},
+			Height: 199},
+		{Amount: 0, PkScript: nil, Height: 0},
+	}
+
+	checkEntry := func(desc string, wantHeight int32,
+		wantStxos []blockchain.SpentTxOut) {
+
+		t.Helper()
+		height, got, err := idx.DisconnectedBlock(block.Hash())
+		if err != nil {
+			t.Fatalf("%s: DisconnectedBlock: %v", desc, err)
+		}
+		if height != wantHeight || !reflect.DeepEqual(got, wantStxos) {
+			t.Fatalf("%s: unexpected entry at height %d: %+v, want "+
+				"%+v at height %d", desc, height, got, wantStxos,
+				wantHeight)
+		}
+	}
+
+	checkEntry("initial", -1, nil)
+
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, block, stxos)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: %v", err)
+	}
+	wantStxos := append([]blockchain.SpentTxOut(nil), stxos...)
+	wantStxos[2].PkScript = []byte{}
+	checkEntry("disconnect", 200, wantStxos)
+
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block, stxos)
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: %v", err)
+	}
+	checkEntry("reconnect", -1, nil)
+
+	// The entry must be retained until the main chain is more than the
+	// retention depth past the block.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, block, stxos)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: %v", err)
+	}
+	connectOther := func(height int32) {
+		t.Helper()
+		other := btcutil.NewBlock(&wire.MsgBlock{
+			Header: wire.BlockHeader{Nonce: uint32(height)},
+		})
+		other.SetHeight(height)
+		err := db.Update(func(dbTx database.Tx) error {
+			return idx.ConnectBlock(dbTx, other, nil)
+		})
+		if err != nil {
+			t.Fatalf("ConnectBlock: %v", err)
+		}
+	}
+	connectOther(200 + SpendJournalRetention)
+	checkEntry("retained", 200, wantStxos)
+	connectOther(200 + SpendJournalRetention + 1)
+	checkEntry("expired", -1, nil)
+	err = db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(spendJournalIndexKey)
+		heights := bucket.Bucket(spendJournalHeightsBucketName)
+		if heights.Cursor().First() {
+			t.Fatal("expired entry left in heights bucket")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: %v", err)
+	}
+
+	// Truncated entries must be detected.
+	serialized := serializeSpendJournalIndexEntry(200, stxos)
+	for i := 0; i < len(serialized); i++ {
+		_, _, err := deserializeSpendJournalIndexEntry(serialized[:i])
+		if err == nil {
+			t.Fatalf("no error for entry truncated to %d bytes", i)
+		}
+	}
+}
@@ -5,6 +5,7 @@
 package indexers
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 
@@ -289,6 +290,9 @@ type TxIndex struct {
 // Ensure the TxIndex type implements the Indexer interface.
 var _ Indexer = (*TxIndex)(nil)
 
+// Ensure the TxIndex type implements the Checker interface.
+var _ Checker = (*TxIndex)(nil)
+
 // Init initializes the hash-based transaction index.  In particular, it finds
 // the highest used block ID and stores it for later use when connecting or
 // disconnecting blocks.
@@ -430,6 +434,80 @@ func (idx *TxIndex) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
 	return nil
 }
 
+// CheckBlock verifies that the passed block has an internal block ID and that
+// every transaction in the block maps to its location within the block.  A
+// transaction that maps to a location in another block is only reported when
+// that location does not hold the same transaction since transactions that
+// were historically duplicated map to their most recent occurrence.
+//
+// This is part of the Checker interface.
+func (idx *TxIndex) CheckBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) ([]string, error) {
+
+	// The transaction entries can't be resolved without the block ID.
+	blockID, err := dbFetchBlockIDByHash(dbTx, block.Hash())
+	if err == errNoBlockIDEntry {
+		return []string{"missing block ID entry"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var discrepancies []string
+	hash, err := dbFetchBlockHashByID(dbTx, blockID)
+	if err != nil && err != errNoBlockIDEntry {
+		return nil, err
+	}
+	if hash == nil || !hash.IsEqual(block.Hash()) {
+		discrepancies = append(discrepancies, fmt.Sprintf("block ID %d "+
+			"does not map back to the block", blockID))
+	}
+
+	txLocs, err := block.TxLoc()
+	if err != nil {
+		return nil, err
+	}
+	for i, tx := range block.Transactions() {
+		region, err := dbFetchTxIndexEntry(dbTx, tx.Hash())
+		if isCorruptionErr(err) {
+			discrepancies = append(discrepancies, err.Error())
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if region == nil {
+			discrepancies = append(discrepancies, fmt.Sprintf(
+				"missing entry for transaction %v", tx.Hash()))
+			continue
+		}
+		if region.Hash.IsEqual(block.Hash()) &&
+			region.Offset == uint32(txLocs[i].TxStart) &&
+			region.Len == uint32(txLocs[i].TxLen) {
+
+			continue
+		}
+
+		// Load the transaction from the location the entry refers to
+		// in order to tell a duplicated transaction apart from an
+		// incorrect entry.
+		var msgTx wire.MsgTx
+		txBytes, err := dbTx.FetchBlockRegion(region)
+		if err == nil {
+			err = msgTx.Deserialize(bytes.NewReader(txBytes))
+		}
+		if err != nil || msgTx.TxHash() != *tx.Hash() {
+			discrepancies = append(discrepancies, fmt.Sprintf(
+				"transaction %v maps to block %v at offset %d "+
+					"with length %d instead of offset %d with "+
+					"length %d", tx.Hash(), region.Hash,
+				region.Offset, region.Len, txLocs[i].TxStart,
+				txLocs[i].TxLen))
+		}
+	}
+
+	return discrepancies, nil
+}
+
 // TxBlockRegion returns the block region for the provided transaction hash
 // from the transaction index.  The block region can in turn be used to load the
 // raw transaction bytes.  When there is no entry for the provided hash, nil
@@ -0,0 +1,98 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestTxIndexCheckBlock ensures checking a block that is connected to the
+// transaction index reports missing and incorrect entries.
+func TestTxIndexCheckBlock(t *testing.T) {
+	t.Parallel()
+
+	db := createTestDB(t)
+
+	idx := NewTxIndex(db)
+	err := db.Update(func(dbTx database.Tx) error {
+		return idx.Create(dbTx)
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := idx.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	// Create a block with a coinbase and another transaction.
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		SignatureScript:  []byte{0x51, 0x51},
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000, []byte{0x51}))
+	spend := wire.NewMsgTx(1)
+	spend.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil, nil))
+	spend.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	block := btcutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, spend},
+	})
+	block.SetHeight(1)
+
+	checkBlock := func(desc string, want int) {
+		t.Helper()
+		var discrepancies []string
+		err := db.View(func(dbTx database.Tx) error {
+			var err error
+			discrepancies, err = idx.CheckBlock(dbTx, block, nil)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("%s: CheckBlock: %v", desc, err)
+		}
+		if len(discrepancies) != want {
+			t.Fatalf("%s: unexpected discrepancies %q, want %d", desc,
+				discrepancies, want)
+		}
+	}
+
+	// The block has no block ID before it is connected.
+	checkBlock("not connected", 1)
+
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block, nil)
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: %v", err)
+	}
+	checkBlock("connected", 0)
+
+	// Remove the entry of the coinbase and point the entry of the other
+	// transaction to the wrong location.
+	err = db.Update(func(dbTx database.Tx) error {
+		coinbaseHash := coinbase.TxHash()
+		err := dbRemoveTxIndexEntry(dbTx, &coinbaseHash)
+		if err != nil {
+			return err
+		}
+		blockID, err := dbFetchBlockIDByHash(dbTx, block.Hash())
+		if err != nil {
+			return err
+		}
+		serialized := make([]byte, txEntrySize)
+		putTxIndexEntry(serialized, blockID, wire.TxLoc{TxStart: 1,
+			TxLen: 10})
+		spendHash := spend.TxHash()
+		return dbPutTxIndexEntry(dbTx, &spendHash, serialized)
+	})
+	if err != nil {
+		t.Fatalf("unable to modify entries: %v", err)
+	}
+	checkBlock("modified", 2)
+}
@@ -0,0 +1,285 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// txoSpenderIndexName is the human-readable name for the index.
+	txoSpenderIndexName = "transaction output spender index"
+
+	// txoSpenderKeySize is the size of a serialized outpoint used as the
+	// key of an entry in the spender index.
+	txoSpenderKeySize = chainhash.HashSize + 4
+
+	// txoSpenderEntrySize is the size of a serialized spender index entry.
+	txoSpenderEntrySize = chainhash.HashSize + 4
+)
+
+var (
+	// txoSpenderIndexKey is the key of the transaction output spender
+	// index and the db bucket used to house it.
+	txoSpenderIndexKey = []byte("txospenderidx")
+)
+
+// -----------------------------------------------------------------------------
+// The transaction output spender index consists of an entry for every output
+// that is spent by a transaction in the main chain.  It maps the outpoint of
+// the spent output to the hash of the transaction that spends it and the height
+// of the block that contains that transaction.
+//
+// The serialized format for the keys and values in the spender index bucket is:
+//
+//   <outpoint hash><outpoint index> = <spending tx hash><block height>
+//
+//   Field              Type              Size
+//   outpoint hash      chainhash.Hash    32 bytes
+//   outpoint index     uint32            4 bytes
+//   spending tx hash   chainhash.Hash    32 bytes
+//   block height       uint32            4 bytes
+//   -----
+//   Total: 72 bytes
+// -----------------------------------------------------------------------------
+
+// TxoSpender describes the transaction in the main chain that spends an output.
+type TxoSpender struct {
+	// SpendingTxHash is the hash of the transaction that spends the output.
+	SpendingTxHash chainhash.Hash
+
+	// BlockHeight is the height of the block that contains the spending
+	// transaction.
+	BlockHeight int32
+}
+
+// txoSpenderKey returns the key of the spender index entry for the provided
+// outpoint.
+func txoSpenderKey(outpoint *wire.OutPoint) []byte {
+	key := make([]byte, txoSpenderKeySize)
+	copy(key, outpoint.Hash[:])
+	byteOrder.PutUint32(key[chainhash.HashSize:], outpoint.Index)
+	return key
+}
+
+// dbAddTxoSpenderEntries uses an existing database transaction to add a spender
+// index entry for every output spent by the passed block.
+func dbAddTxoSpenderEntries(dbTx database.Tx, block *btcutil.Block) error {
+	// As an optimization, allocate a single slice big enough to hold all
+	// of the serialized entries for the block and serialize them directly
+	// into the slice like the transaction index does.
+	var numSpent int
+	for _, tx := range block.Transactions()[1:] {
+		numSpent += len(tx.MsgTx().TxIn)
+	}
+	serializedValues := make([]byte, numSpent*txoSpenderEntrySize)
+
+	spenderIndex := dbTx.Metadata().Bucket(txoSpenderIndexKey)
+	offset := 0
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			endOffset := offset + txoSpenderEntrySize
+			value := serializedValues[offset:endOffset:endOffset]
+			copy(value, tx.Hash()[:])
+			byteOrder.PutUint32(value[chainhash.HashSize:],
+				uint32(block.Height()))
+
+			key := txoSpenderKey(&txIn.PreviousOutPoint)
+			if err := spenderIndex.Put(key, value); err != nil {
+				return err
+			}
+			offset = endOffset
+		}
+	}
+
+	return nil
+}
+
+// dbRemoveTxoSpenderEntries uses an existing database transaction to remove the
+// spender index entry for every output spent by the passed block.
+func dbRemoveTxoSpenderEntries(dbTx database.Tx, block *btcutil.Block) error {
+	spenderIndex := dbTx.Metadata().Bucket(txoSpenderIndexKey)
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			key := txoSpenderKey(&txIn.PreviousOutPoint)
+			if err := spenderIndex.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// dbFetchTxoSpender uses an existing database transaction to fetch the spender
+// of the provided outpoint from the spender index.  When there is no entry for
+// the provided outpoint, nil will be returned for both the spender and the
+// error.
+func dbFetchTxoSpender(dbTx database.Tx, outpoint *wire.OutPoint) (*TxoSpender, error) {
+	spenderIndex := dbTx.Metadata().Bucket(txoSpenderIndexKey)
+	serializedData := spenderIndex.Get(txoSpenderKey(outpoint))
+	if len(serializedData) == 0 {
+		return nil, nil
+	}
+
+	// Ensure the serialized data has enough bytes to properly deserialize.
+	if len(serializedData) < txoSpenderEntrySize {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt transaction output "+
+				"spender index entry for %v", outpoint),
+		}
+	}
+
+	var spender TxoSpender
+	copy(spender.SpendingTxHash[:], serializedData[:chainhash.HashSize])
+	spender.BlockHeight = int32(byteOrder.Uint32(
+		serializedData[chainhash.HashSize:]))
+	return &spender, nil
+}
+
+// TxoSpenderIndex implements a transaction output spender index.  That is to
+// say, it supports querying which transaction in the main chain spends a given
+// output.
+type TxoSpenderIndex struct {
+	db database.DB
+}
+
+// Ensure the TxoSpenderIndex type implements the Indexer interface.
+var _ Indexer = (*TxoSpenderIndex)(nil)
+
+// Ensure the TxoSpenderIndex type implements the Checker interface.
+var _ Checker = (*TxoSpenderIndex)(nil)
+
+// Init initializes the transaction output spender index.
+//
+// This is part of the Indexer interface.
+func (idx *TxoSpenderIndex) Init() error {
+	return nil // Nothing to do.
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *TxoSpenderIndex) Key() []byte {
+	return txoSpenderIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *TxoSpenderIndex) Name() string {
+	return txoSpenderIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs to be
+// created for the first time.  It creates the bucket for the spender index.
+//
+// This is part of the Indexer interface.
+func (idx *TxoSpenderIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(txoSpenderIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an outpoint-to-spender
+// mapping for every output spent by the passed block.
+//
+// This is part of the Indexer interface.
+func (idx *TxoSpenderIndex) ConnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	return dbAddTxoSpenderEntries(dbTx, block)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the
+// outpoint-to-spender mapping for every output spent by the passed block.
+//
+// This is part of the Indexer interface.
+func (idx *TxoSpenderIndex) DisconnectBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	return dbRemoveTxoSpenderEntries(dbTx, block)
+}
+
+// CheckBlock verifies that every output spent by the passed block maps to the
+// transaction in the block that spends it.
+//
+// This is part of the Checker interface.
+func (idx *TxoSpenderIndex) CheckBlock(dbTx database.Tx, block *btcutil.Block,
+	stxos []blockchain.SpentTxOut) ([]string, error) {
+
+	var discrepancies []string
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			outpoint := &txIn.PreviousOutPoint
+			spender, err := dbFetchTxoSpender(dbTx, outpoint)
+			if isCorruptionErr(err) {
+				discrepancies = append(discrepancies,
+					err.Error())
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case spender == nil:
+				discrepancies = append(discrepancies,
+					fmt.Sprintf("missing entry for %v",
+						outpoint))
+			case spender.SpendingTxHash != *tx.Hash() ||
+				spender.BlockHeight != block.Height():
+
+				discrepancies = append(discrepancies,
+					fmt.Sprintf("%v maps to transaction %v "+
+						"at height %d instead of %v",
+						outpoint, spender.SpendingTxHash,
+						spender.BlockHeight, tx.Hash()))
+			}
+		}
+	}
+
+	return discrepancies, nil
+}
+
+// Spender returns the transaction in the main chain that spends the provided
+// outpoint along with the height of the block that contains it.  When the
+// output is not spent in the main chain, nil will be returned for both the
+// spender and the error.
+//
+// This function is safe for concurrent access.
+func (idx *TxoSpenderIndex) Spender(outpoint *wire.OutPoint) (*TxoSpender, error) {
+	var spender *TxoSpender
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		spender, err = dbFetchTxoSpender(dbTx, outpoint)
+		return err
+	})
+	return spender, err
+}
+
+// NewTxoSpenderIndex returns a new instance of an indexer that is used to
+// create a mapping of every output spent in the main chain to the transaction
+// that spends it.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewTxoSpenderIndex(db database.DB) *TxoSpenderIndex {
+	return &TxoSpenderIndex{db: db}
+}
+
+// DropTxoSpenderIndex drops the transaction output spender index from the
+// provided database if it exists.
+func DropTxoSpenderIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, txoSpenderIndexKey, txoSpenderIndexName, interrupt)
+}
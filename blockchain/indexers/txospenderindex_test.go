@@ -0,0 +1,122 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestTxoSpenderIndex ensures connecting a block adds an entry for every output
+// it spends and that disconnecting it again removes them.
+func TestTxoSpenderIndex(t *testing.T) {
+	t.Parallel()
+
+	db := createTestDB(t)
+
+	idx := NewTxoSpenderIndex(db)
+	err := db.Update(func(dbTx database.Tx) error {
+		return idx.Create(dbTx)
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Create a block with a coinbase and a transaction that spends two
+	// outputs.
+	var prevHash chainhash.Hash
+	prevHash[0] = 1
+	spent := []wire.OutPoint{{Hash: prevHash, Index: 0},
+		{Hash: prevHash, Index: 7}}
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		SignatureScript:  []byte{0x51, 0x51},
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000, []byte{0x51}))
+	spend := wire.NewMsgTx(1)
+	for i := range spent {
+		spend.AddTxIn(wire.NewTxIn(&spent[i], nil, nil))
+	}
+	spend.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	block := btcutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, spend},
+	})
+	block.SetHeight(100)
+
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block, nil)
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: %v", err)
+	}
+	want := &TxoSpender{SpendingTxHash: spend.TxHash(), BlockHeight: 100}
+	for i := range spent {
+		spender, err := idx.Spender(&spent[i])
+		if err != nil {
+			t.Fatalf("Spender: %v", err)
+		}
+		if !reflect.DeepEqual(spender, want) {
+			t.Fatalf("unexpected spender of %v: got %+v, want %+v",
+				spent[i], spender, want)
+		}
+	}
+
+	checkBlock := func(desc string, want int) {
+		t.Helper()
+		var discrepancies []string
+		err := db.View(func(dbTx database.Tx) error {
+			var err error
+			discrepancies, err = idx.CheckBlock(dbTx, block, nil)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("%s: CheckBlock: %v", desc, err)
+		}
+		if len(discrepancies) != want {
+			t.Fatalf("%s: unexpected discrepancies %q, want %d", desc,
+				discrepancies, want)
+		}
+	}
+	checkBlock("connected", 0)
+
+	// Outputs that are not spent and the outputs of the coinbase must not
+	// have an entry.
+	unspent := []wire.OutPoint{{Hash: prevHash, Index: 1},
+		coinbase.TxIn[0].PreviousOutPoint}
+	for i := range unspent {
+		spender, err := idx.Spender(&unspent[i])
+		if err != nil {
+			t.Fatalf("Spender: %v", err)
+		}
+		if spender != nil {
+			t.Fatalf("unexpected spender of %v: %+v", unspent[i],
+				spender)
+		}
+	}
+
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, block, nil)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: %v", err)
+	}
+	for i := range spent {
+		spender, err := idx.Spender(&spent[i])
+		if err != nil {
+			t.Fatalf("Spender: %v", err)
+		}
+		if spender != nil {
+			t.Fatalf("unexpected spender of %v after disconnect: %+v",
+				spent[i], spender)
+		}
+	}
+	checkBlock("disconnected", len(spent))
+}
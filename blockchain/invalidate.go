@@ -0,0 +1,229 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"container/list"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// descendants returns all nodes in the block index that have the passed node
+// as an ancestor.  The passed node itself is not included.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) descendants(node *blockNode) []*blockNode {
+	// Every descendant is on the path from some chain tip back to the
+	// passed node, so walk back from each tip and collect the nodes on
+	// the paths that lead to it.  Paths that share nodes with paths that
+	// were already walked stop early to avoid duplicates.
+	seen := make(map[*blockNode]struct{})
+	var descendants []*blockNode
+	for _, tip := range b.index.ChainTips() {
+		if tip.height <= node.height || tip.Ancestor(node.height) != node {
+			continue
+		}
+		for n := tip; n != node; n = n.parent {
+			if _, ok := seen[n]; ok {
+				break
+			}
+			seen[n] = struct{}{}
+			descendants = append(descendants, n)
+		}
+	}
+	return descendants
+}
+
+// bestCandidateTip returns the node with the most cumulative work that could
+// become the tip of the main chain.  A node is only a candidate when none of
+// the blocks between it and the main chain are known to be invalid and the
+// data for all of them is available.  The current tip is returned when there
+// is no better candidate.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) bestCandidateTip() *blockNode {
+	candidate := b.bestChain.Tip()
+	for _, tip := range b.index.ChainTips() {
+		// Find the highest node in the branch that does not have an
+		// unusable node before it.
+		fork := b.bestChain.FindFork(tip)
+		usable := tip
+		for n := tip; n != nil && n != fork; n = n.parent {
+			status := b.index.NodeStatus(n)
+			if status.KnownInvalid() || !status.HaveData() {
+				usable = n.parent
+			}
+		}
+		if usable != nil && usable.workSum.Cmp(candidate.workSum) > 0 {
+			candidate = usable
+		}
+	}
+	return candidate
+}
+
+// activateBestChain reorganizes the chain to the candidate tip with the most
+// cumulative work.  Candidates that turn out to violate the rules while
+// reorganizing are marked invalid and the next best candidate is tried.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) activateBestChain() error {
+	for {
+		candidate := b.bestCandidateTip()
+		if candidate == b.bestChain.Tip() {
+			return nil
+		}
+
+		detachNodes, attachNodes := b.getReorganizeNodes(candidate)
+		err := b.reorganizeChain(detachNodes, attachNodes)
+		if writeErr := b.index.flushToDB(); writeErr != nil {
+			log.Warnf("Error flushing block index changes to disk: %v",
+				writeErr)
+		}
+		if err != nil {
+			if _, ok := err.(RuleError); ok {
+				continue
+			}
+			return err
+		}
+	}
+}
+
+// InvalidateBlock marks the block with the passed hash and all of its
+// descendants as invalid.  When the block is part of the main chain, it is
+// disconnected along with all blocks after it and the chain is reorganized to
+// the remaining valid tip with the most cumulative work.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) InvalidateBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		return fmt.Errorf("block %s is not known", hash)
+	}
+	if node.parent == nil {
+		return fmt.Errorf("the genesis block %s can't be invalidated",
+			hash)
+	}
+
+	// Disconnect the block and every block after it when it is part of
+	// the main chain.
+	if b.bestChain.Contains(node) {
+		detachNodes := list.New()
+		for n := b.bestChain.Tip(); n != node.parent; n = n.parent {
+			detachNodes.PushBack(n)
+		}
+		err := b.reorganizeChain(detachNodes, list.New())
+		if err != nil {
+			return err
+		}
+	}
+
+	// Mark the block as invalid and all of its descendants as having an
+	// invalid ancestor and persist the changes.
+	b.index.SetStatusFlags(node, statusValidateFailed)
+	for _, n := range b.descendants(node) {
+		b.index.SetStatusFlags(n, statusInvalidAncestor)
+	}
+	if err := b.index.flushToDB(); err != nil {
+		return err
+	}
+	log.Infof("Invalidated block %v (height %d)", hash, node.height)
+	b.resetBestHeader()
+
+	// Switch to the best remaining chain.
+	return b.activateBestChain()
+}
+
+// ReconsiderBlock removes the invalid status from the block with the passed
+// hash along with all of its ancestors and descendants that were marked
+// invalid, such as is the case when it was previously invalidated with
+// InvalidateBlock.  The chain is then reorganized to the valid tip with the
+// most cumulative work, which may include the reconsidered block.
+//
+// Blocks that are still invalid will be marked as such again when the chain
+// attempts to connect them.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ReconsiderBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		return fmt.Errorf("block %s is not known", hash)
+	}
+
+	// Clear the invalid status flags and persist the changes.
+	const invalidFlags = statusValidateFailed | statusInvalidAncestor
+	for n := node; n != nil; n = n.parent {
+		if b.index.NodeStatus(n).KnownInvalid() {
+			b.index.UnsetStatusFlags(n, invalidFlags)
+		}
+	}
+	for _, n := range b.descendants(node) {
+		if b.index.NodeStatus(n).KnownInvalid() {
+			b.index.UnsetStatusFlags(n, invalidFlags)
+		}
+	}
+	if err := b.index.flushToDB(); err != nil {
+		return err
+	}
+	log.Infof("Reconsidered block %v (height %d)", hash, node.height)
+	b.resetBestHeader()
+
+	// Switch to the best chain now that the blocks may be considered.
+	return b.activateBestChain()
+}
+
+// PreciousBlock treats the block with the passed hash as if it were received
+// before any other block with the same cumulative work.  When the block is not
+// part of the main chain and has at least as much cumulative work as the
+// current tip, the chain is reorganized so the block becomes the new tip.
+// Otherwise, it has no effect.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) PreciousBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		return fmt.Errorf("block %s is not known", hash)
+	}
+
+	// The block can't be made the tip without the data of the block and
+	// the ancestors that would be connected along with it, which is the
+	// case when only its header is known or it was pruned.
+	forkNode := b.bestChain.FindFork(node)
+	for n := node; n != nil && n != forkNode; n = n.parent {
+		if !b.index.NodeStatus(n).HaveData() {
+			return fmt.Errorf("block %s data not available", n.hash)
+		}
+	}
+
+	// Nothing to do when the block is already in the main chain or it has
+	// less work than the current tip.
+	if b.bestChain.Contains(node) ||
+		node.workSum.Cmp(b.bestChain.Tip().workSum) < 0 {
+
+		return nil
+	}
+
+	// Reorganize to the block.
+	detachNodes, attachNodes := b.getReorganizeNodes(node)
+	if attachNodes.Len() == 0 {
+		return fmt.Errorf("block %s can't be made the tip since it or "+
+			"one of its ancestors is invalid", hash)
+	}
+	err := b.reorganizeChain(detachNodes, attachNodes)
+	if writeErr := b.index.flushToDB(); writeErr != nil {
+		log.Warnf("Error flushing block index changes to disk: %v",
+			writeErr)
+	}
+	return err
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// TestInvalidateReconsiderBlock ensures manually invalidating, reconsidering,
+// and preferring blocks reorganizes the chain and updates the block index as
+// expected.
+func TestInvalidateReconsiderBlock(t *testing.T) {
+	// Load up blocks such that there is a side chain with the same amount
+	// of work as the main chain.
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a -> 4a -> 5a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+		"blk_4A.dat.bz2",
+		"blk_5A.dat.bz2",
+	}
+	var blocks []*btcutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v\n", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+	block3, block4 := blocks[3], blocks[4]
+	block3A, block4A, block5A := blocks[5], blocks[6], blocks[7]
+
+	chain, teardownFunc, err := chainSetup("invalidateblock",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// Since we're not dealing with the real block chain, set the coinbase
+	// maturity to 1.
+	chain.TstSetCoinbaseMaturity(1)
+
+	for i := 1; i < len(blocks)-1; i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+
+	// checkTip ensures the tip of the main chain is the passed block.
+	checkTip := func(desc string, block *btcutil.Block) {
+		t.Helper()
+		if got := chain.BestSnapshot().Hash; got != *block.Hash() {
+			t.Fatalf("%s: unexpected tip %v, want %v", desc, got,
+				block.Hash())
+		}
+	}
+
+	// checkInvalid ensures the invalid state of the passed blocks in the
+	// block index is the expected one.
+	checkInvalid := func(desc string, want bool, blocks ...*btcutil.Block) {
+		t.Helper()
+		for _, block := range blocks {
+			node := chain.index.LookupNode(block.Hash())
+			got := chain.index.NodeStatus(node).KnownInvalid()
+			if got != want {
+				t.Fatalf("%s: block %v invalid %v, want %v", desc,
+					block.Hash(), got, want)
+			}
+		}
+	}
+
+	// Prefer the side chain and then the original chain again.
+	checkTip("initial", block4)
+	if err := chain.PreciousBlock(block4A.Hash()); err != nil {
+		t.Fatalf("PreciousBlock: %v", err)
+	}
+	checkTip("precious 4a", block4A)
+	if err := chain.PreciousBlock(block4.Hash()); err != nil {
+		t.Fatalf("PreciousBlock: %v", err)
+	}
+	checkTip("precious 4", block4)
+
+	// Invalidating a block in the main chain must reorganize to the side
+	// chain and mark the block and its descendants invalid.
+	if err := chain.InvalidateBlock(block3.Hash()); err != nil {
+		t.Fatalf("InvalidateBlock: %v", err)
+	}
+	checkTip("invalidate 3", block4A)
+	checkInvalid("invalidate 3", true, block3, block4)
+	checkInvalid("invalidate 3", false, block3A, block4A)
+	for _, tip := range chain.ChainTips() {
+		if tip.Hash == *block4.Hash() && tip.Status != ChainTipInvalid {
+			t.Fatalf("unexpected chain tip status %v", tip.Status)
+		}
+	}
+
+	// Reconsidering the block must clear the invalid state without a
+	// reorganize since both chains have the same work.
+	if err := chain.ReconsiderBlock(block3.Hash()); err != nil {
+		t.Fatalf("ReconsiderBlock: %v", err)
+	}
+	checkTip("reconsider 3", block4A)
+	checkInvalid("reconsider 3", false, block3, block4)
+
+	// Invalidating a block in the side chain that is now the main chain
+	// must switch back to the original chain.
+	if err := chain.InvalidateBlock(block3A.Hash()); err != nil {
+		t.Fatalf("InvalidateBlock: %v", err)
+	}
+	checkTip("invalidate 3a", block4)
+	checkInvalid("invalidate 3a", true, block3A, block4A)
+
+	// The invalid state must be persisted to the database.
+	paramsCopy := chaincfg.MainNetParams
+	reloaded, err := New(&Config{
+		DB:               chain.db,
+		ChainParams:      &paramsCopy,
+		TimeSource:       NewMedianTime(),
+		UtxoCacheMaxSize: DefaultUtxoCacheMaxSize,
+	})
+	if err != nil {
+		t.Fatalf("Failed to reload chain instance: %v", err)
+	}
+	for _, hash := range []*chainhash.Hash{block3A.Hash(), block4A.Hash()} {
+		node := reloaded.index.LookupNode(hash)
+		if !reloaded.index.NodeStatus(node).KnownInvalid() {
+			t.Fatalf("invalid state of block %v not persisted", hash)
+		}
+	}
+
+	// Blocks extending the invalid chain must be rejected until it is
+	// reconsidered.
+	_, _, err = chain.ProcessBlock(block5A, BFNone)
+	if !isRuleErrorCode(err, ErrInvalidAncestorBlock) {
+		t.Fatalf("ProcessBlock of block 5a: unexpected error %v", err)
+	}
+	if err := chain.ReconsiderBlock(block4A.Hash()); err != nil {
+		t.Fatalf("ReconsiderBlock: %v", err)
+	}
+	checkInvalid("reconsider 4a", false, block3A, block4A)
+	if _, _, err := chain.ProcessBlock(block5A, BFNone); err != nil {
+		t.Fatalf("ProcessBlock of block 5a: %v", err)
+	}
+	checkTip("process 5a", block5A)
+
+	// A block whose data is not available, such as when it was pruned,
+	// can't be preferred.
+	node4 := chain.index.LookupNode(block4.Hash())
+	chain.index.UnsetStatusFlags(node4, statusDataStored)
+	if err := chain.PreciousBlock(block4.Hash()); err == nil {
+		t.Fatal("PreciousBlock of a block without data did not fail")
+	}
+	checkTip("precious 4 without data", block5A)
+
+	// Invalidating the genesis block is not allowed.
+	genesisHash := chaincfg.MainNetParams.GenesisHash
+	if err := chain.InvalidateBlock(genesisHash); err == nil {
+		t.Fatal("InvalidateBlock of the genesis block did not fail")
+	}
+}
+
+// isRuleErrorCode returns whether or not the passed error is a RuleError with
+// the passed error code.
+func isRuleErrorCode(err error, code ErrorCode) bool {
+	ruleErr, ok := err.(RuleError)
+	return ok && ruleErr.ErrorCode == code
+}
@@ -39,8 +39,13 @@ const (
 // This function is safe for concurrent access.
 func (b *BlockChain) blockExists(hash *chainhash.Hash) (bool, error) {
 	// Check block index first (could be main chain or side chain blocks).
-	if b.index.HaveBlock(hash) {
-		return true, nil
+	// Blocks that only have their header in the index don't exist yet
+	// unless they are known to be invalid, while main chain blocks whose
+	// data was pruned still do.
+	if node := b.index.LookupNode(hash); node != nil {
+		status := b.index.NodeStatus(node)
+		return status.HaveData() || status.KnownValid() ||
+			status.KnownInvalid() || b.bestChain.Contains(node), nil
 	}
 
 	// Check in the database.
@@ -148,6 +153,16 @@ func (b *BlockChain) ProcessBlock(block *btcutil.Block, flags BehaviorFlags) (bo
 	blockHash := block.Hash()
 	log.Tracef("Processing block %v", blockHash)
 
+	// Blocks below a loaded utxo snapshot only have their headers in the
+	// block index, so they are stored and validated in the background.
+	if node := b.index.LookupNode(blockHash); node != nil && b.isSnapshotBlock(node) {
+		err := b.processSnapshotBlock(node, block, flags)
+		if err != nil {
+			return false, false, err
+		}
+		return true, false, nil
+	}
+
 	// The block must not already exist in the main chain or side chains.
 	exists, err := b.blockExists(blockHash)
 	if err != nil {
@@ -210,11 +225,15 @@ func (b *BlockChain) ProcessBlock(block *btcutil.Block, flags BehaviorFlags) (bo
 		}
 	}
 
-	// Handle orphan blocks.
+	// Handle orphan blocks.  Blocks that build on a block whose header is
+	// known are not orphans even when its data is not available yet.
 	prevHash := &blockHeader.PrevBlock
-	prevHashExists, err := b.blockExists(prevHash)
-	if err != nil {
-		return false, false, err
+	prevHashExists := b.index.HaveBlock(prevHash)
+	if !prevHashExists {
+		prevHashExists, err = b.blockExists(prevHash)
+		if err != nil {
+			return false, false, err
+		}
 	}
 	if !prevHashExists {
 		log.Infof("Adding orphan block %v with parent %v", blockHash, prevHash)
@@ -238,6 +257,13 @@ func (b *BlockChain) ProcessBlock(block *btcutil.Block, flags BehaviorFlags) (bo
 		return false, false, err
 	}
 
+	// Connect the blocks that were downloaded before this one and build on
+	// it.
+	err = b.connectDownloadedBlocks(flags)
+	if err != nil {
+		return false, false, err
+	}
+
 	log.Debugf("Accepted block %v", blockHash)
 
 	return isMainChain, false, nil
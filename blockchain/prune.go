@@ -0,0 +1,176 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+)
+
+const (
+	// MinBlocksToKeep is the minimum number of the most recent main chain
+	// blocks that are never pruned.  It matches the number of blocks nodes
+	// signalling NODE_NETWORK_LIMITED are required to serve (BIP0159) and
+	// doubles as the depth of the reorganizations a pruned node is able to
+	// handle since disconnecting a block requires its data.
+	MinBlocksToKeep = 288
+)
+
+// initPruneState loads whether or not blocks have been pruned from the
+// database and, when they have, determines the height of the oldest main
+// chain block whose data is still stored.
+//
+// This function MUST be called after the chain state is initialized.
+func (b *BlockChain) initPruneState() error {
+	var pruned bool
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		pruned, err = dbTx.BeenPruned()
+		return err
+	})
+	if err != nil || !pruned {
+		return err
+	}
+
+	b.pruned = true
+	b.pruneHeight = b.oldestStoredHeight(0)
+	log.Infof("Block data is pruned prior to height %d", b.pruneHeight)
+	return nil
+}
+
+// oldestStoredHeight returns the height of the oldest main chain block at or
+// after the passed height that still has its data stored.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) oldestStoredHeight(height int32) int32 {
+	tip := b.bestChain.Tip()
+	for ; height < tip.height; height++ {
+		node := b.bestChain.NodeByHeight(height)
+		if b.index.NodeStatus(node).HaveData() {
+			break
+		}
+	}
+	return height
+}
+
+// pruneBlocks deletes the oldest blocks from the database when the stored
+// blocks exceed the prune target.  The most recent MinBlocksToKeep blocks of
+// the main chain are never deleted and neither are the blocks the optional
+// indexes still need to catch up with the main chain.
+//
+// The blocks connected since the last flush of the utxo cache are required to
+// recover the utxo set after an unclean shutdown, so the cache is flushed as a
+// part of the same database transaction when any of them are deleted.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) pruneBlocks() error {
+	tip := b.bestChain.Tip()
+	keepHeight := tip.height - MinBlocksToKeep + 1
+	if keepHeight <= 0 {
+		return nil
+	}
+	keep := make([]chainhash.Hash, 0, MinBlocksToKeep)
+	for node := tip; node != nil && node.height >= keepHeight; node = node.parent {
+		keep = append(keep, node.hash)
+	}
+	if b.indexManager != nil {
+		height, needed := b.indexManager.OldestNeededHeight()
+		if needed && height < keepHeight {
+			keep = append(keep, b.bestChain.NodeByHeight(height).hash)
+		}
+	}
+
+	// The height of the block the utxo set in the database is consistent
+	// with determines which of the blocks are required for recovery.
+	flushHeight := tip.height
+	if flushNode := b.index.LookupNode(&b.utxoCache.lastFlushHash); flushNode != nil {
+		flushHeight = flushNode.height
+	}
+
+	var pruned []*blockNode
+	var flushed bool
+	err := b.db.Update(func(dbTx database.Tx) error {
+		prunedHashes, err := dbTx.PruneBlocks(b.pruneTarget, keep)
+		if err != nil || len(prunedHashes) == 0 {
+			return err
+		}
+
+		// The spend journal entries are only useful for disconnecting
+		// blocks, which is no longer possible without their data.
+		for i := range prunedHashes {
+			hash := &prunedHashes[i]
+			err := dbRemoveSpendJournalEntry(dbTx, hash)
+			if err != nil {
+				return err
+			}
+
+			node := b.index.LookupNode(hash)
+			if node == nil {
+				continue
+			}
+			pruned = append(pruned, node)
+			if node.height > flushHeight && !flushed {
+				err := b.utxoCache.dbPutCachedEntries(dbTx, &tip.hash)
+				if err != nil {
+					return err
+				}
+				flushed = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if flushed {
+		b.utxoCache.markFlushed(&tip.hash)
+	}
+	if len(pruned) == 0 {
+		return nil
+	}
+
+	// Mark the data for the pruned blocks as no longer available and
+	// write the change to the database immediately so the block index
+	// does not claim to have data that was deleted.
+	for _, node := range pruned {
+		b.index.UnsetStatusFlags(node, statusDataStored)
+	}
+	if err := b.index.flushToDB(); err != nil {
+		return err
+	}
+
+	b.pruned = true
+	b.pruneHeight = b.oldestStoredHeight(b.pruneHeight)
+	log.Debugf("Pruned %d blocks, block data is now available from height "+
+		"%d", len(pruned), b.pruneHeight)
+	return nil
+}
+
+// PruneHeight returns the height of the oldest main chain block whose data is
+// still stored along with whether or not any blocks have ever been pruned.
+// The height is only meaningful when blocks have been pruned.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) PruneHeight() (int32, bool) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	return b.pruneHeight, b.pruned
+}
+
+// BlockPruned returns whether or not the block with the passed hash is known,
+// but its data is no longer available because it was pruned.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) BlockPruned(hash *chainhash.Hash) bool {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	if !b.pruned {
+		return false
+	}
+	node := b.index.LookupNode(hash)
+	return node != nil && !b.index.NodeStatus(node).HaveData()
+}
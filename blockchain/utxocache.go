@@ -0,0 +1,471 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// DefaultUtxoCacheMaxSize is the default maximum number of bytes the
+	// utxo cache is allowed to use before it is flushed to the database.
+	DefaultUtxoCacheMaxSize = 250 * 1024 * 1024
+
+	// utxoFlushPeriodicInterval is the interval at which a periodic flush
+	// of the utxo cache is performed even when it has not reached its
+	// maximum size.  This bounds the amount of work that has to be redone
+	// after an unclean shutdown.
+	utxoFlushPeriodicInterval = 5 * time.Minute
+
+	// utxoEntryBaseSize is the approximate number of bytes a cached utxo
+	// entry consumes excluding its public key script.  It accounts for the
+	// UtxoEntry struct itself (40 bytes), the outpoint used as the map key
+	// (36 bytes), the pointer stored as the map value (8 bytes) and the
+	// amortized overhead of the map buckets.
+	utxoEntryBaseSize = 40 + 36 + 8 + 20
+)
+
+// FlushMode is used to indicate the different urgency types for a flush of
+// the utxo cache.
+type FlushMode uint8
+
+const (
+	// FlushRequired is the flush mode that means a flush must be performed
+	// regardless of the cache state.  For example right before shutting
+	// down.
+	FlushRequired FlushMode = iota
+
+	// FlushPeriodic is the flush mode that means a flush can be performed
+	// when it would be almost needed.  This is used to periodically signal
+	// when no I/O heavy operations are expected soon, so there is time to
+	// flush.
+	FlushPeriodic
+
+	// FlushIfNeeded is the flush mode that means a flush must be performed
+	// only if the cache is exceeding a safety threshold very close to its
+	// maximum size.  This is used mainly to flush the cache in between
+	// processing blocks.
+	FlushIfNeeded
+)
+
+// String returns the FlushMode as a human-readable name.
+func (m FlushMode) String() string {
+	switch m {
+	case FlushRequired:
+		return "FlushRequired"
+	case FlushPeriodic:
+		return "FlushPeriodic"
+	case FlushIfNeeded:
+		return "FlushIfNeeded"
+	}
+
+	return fmt.Sprintf("Unknown FlushMode (%d)", uint8(m))
+}
+
+// entryMemoryUsage returns the approximate number of bytes the passed entry
+// consumes while it is held in the utxo cache.
+func entryMemoryUsage(entry *UtxoEntry) uint64 {
+	return utxoEntryBaseSize + uint64(len(entry.pkScript))
+}
+
+// utxoCache is a write-back cache that sits between the utxo views used while
+// validating and connecting blocks and the utxo set stored in the database.
+//
+// Entries are loaded into the cache when they are fetched and all
+// modifications made by connected blocks are applied to the cache instead of
+// being written to the database directly.  The modified entries are then
+// written to the database in a single transaction when the cache is flushed,
+// which happens when it grows beyond its maximum size, periodically, and on
+// shutdown.
+//
+// Every flush also records the hash of the block the utxo set in the database
+// is consistent with, so after an unclean shutdown the blocks connected since
+// the last flush can be replayed to bring the utxo set back up to date.
+type utxoCache struct {
+	db                  database.DB
+	maxTotalMemoryUsage uint64
+
+	// bucketName is the name of the database bucket that houses the utxo
+	// set the cache writes back to and stateKeyName is the name of the key
+	// that records the block that utxo set is consistent with.
+	bucketName   []byte
+	stateKeyName []byte
+
+	// mtx protects the fields below.  It is required in addition to the
+	// chain lock since lookups may happen concurrently under the read lock
+	// and they populate the cache.
+	//
+	// cachedEntries houses the cached entries keyed by their outpoint.
+	// Entries that are spent, but still need to be removed from the
+	// database, are kept with the spent flag set until the next flush.
+	//
+	// totalEntryMemory is the approximate memory used by cachedEntries.
+	mtx              sync.Mutex
+	cachedEntries    map[wire.OutPoint]*UtxoEntry
+	totalEntryMemory uint64
+
+	// lastFlushHash is the hash of the block the utxo set in the database
+	// is consistent with and lastFlushTime is when that flush happened.
+	// They are only accessed with the chain lock held for writes.
+	lastFlushHash chainhash.Hash
+	lastFlushTime time.Time
+}
+
+// newUtxoCache returns a new utxo cache backed by the utxo set in the passed
+// database bucket which is flushed once it grows larger than
+// maxTotalMemoryUsage bytes.  The block the utxo set is consistent with is
+// recorded under the passed key.
+func newUtxoCache(db database.DB, bucketName, stateKeyName []byte, maxTotalMemoryUsage uint64) *utxoCache {
+	return &utxoCache{
+		db:                  db,
+		maxTotalMemoryUsage: maxTotalMemoryUsage,
+		bucketName:          bucketName,
+		stateKeyName:        stateKeyName,
+		cachedEntries:       make(map[wire.OutPoint]*UtxoEntry),
+		lastFlushTime:       time.Now(),
+	}
+}
+
+// totalMemoryUsage returns the approximate number of bytes used by the cache.
+func (c *utxoCache) totalMemoryUsage() uint64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.totalEntryMemory
+}
+
+// addEntry adds the passed entry to the cache, replacing any existing entry
+// for the outpoint, and updates the memory usage accordingly.
+//
+// This function MUST be called with the cache lock held.
+func (c *utxoCache) addEntry(outpoint wire.OutPoint, entry *UtxoEntry) {
+	if cached, ok := c.cachedEntries[outpoint]; ok {
+		c.totalEntryMemory -= entryMemoryUsage(cached)
+	}
+	c.cachedEntries[outpoint] = entry
+	c.totalEntryMemory += entryMemoryUsage(entry)
+}
+
+// removeEntry removes the entry for the passed outpoint from the cache and
+// updates the memory usage accordingly.
+//
+// This function MUST be called with the cache lock held.
+func (c *utxoCache) removeEntry(outpoint wire.OutPoint) {
+	if cached, ok := c.cachedEntries[outpoint]; ok {
+		c.totalEntryMemory -= entryMemoryUsage(cached)
+		delete(c.cachedEntries, outpoint)
+	}
+}
+
+// fetchEntries returns the unspent entries for the passed outpoints in the
+// same order they were requested.  Outputs that are spent or otherwise don't
+// exist result in a nil entry.  Entries that are not already cached are loaded
+// from the database and added to the cache.
+//
+// The returned entries are copies which the caller is free to modify without
+// affecting the cache.
+//
+// This function is safe for concurrent access.
+func (c *utxoCache) fetchEntries(outpoints []wire.OutPoint) ([]*UtxoEntry, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	entries := make([]*UtxoEntry, len(outpoints))
+	var missing []int
+	for i, outpoint := range outpoints {
+		cached, ok := c.cachedEntries[outpoint]
+		if !ok {
+			missing = append(missing, i)
+			continue
+		}
+		if !cached.IsSpent() {
+			entries[i] = cached.viewCopy()
+		}
+	}
+
+	// Nothing more to do when every entry was found in the cache.
+	if len(missing) == 0 {
+		return entries, nil
+	}
+
+	// Load the entries that are not cached from the database.  Missing
+	// entries are not cached since there is nothing to save by doing so.
+	err := c.db.View(func(dbTx database.Tx) error {
+		for _, i := range missing {
+			entry, err := dbFetchUtxoEntry(dbTx, c.bucketName,
+				outpoints[i])
+			if err != nil {
+				return err
+			}
+			if entry == nil {
+				continue
+			}
+
+			c.addEntry(outpoints[i], entry)
+			entries[i] = entry.viewCopy()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// commit applies all of the modified entries in the passed view to the cache.
+// The view is not modified.
+//
+// Spent entries that are known not to exist in the database are removed from
+// the cache outright while the others are kept as spent so they are removed
+// from the database on the next flush.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (c *utxoCache) commit(view *UtxoViewpoint) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for outpoint, entry := range view.entries {
+		// Only modified entries need to be applied.
+		if entry == nil || !entry.isModified() {
+			continue
+		}
+
+		// The entry is fresh when it is fresh in the cache or, when it
+		// is not cached yet, when it is fresh in the view.
+		cached := c.cachedEntries[outpoint]
+		fresh := entry.isFresh()
+		if cached != nil {
+			fresh = cached.isFresh()
+		}
+
+		if entry.IsSpent() {
+			// There is nothing to remove from the database when the
+			// output was never written to it.
+			if fresh {
+				c.removeEntry(outpoint)
+				continue
+			}
+
+			// Keep a spent marker without the script so the output
+			// is removed from the database on the next flush.
+			c.addEntry(outpoint, &UtxoEntry{
+				amount:      entry.amount,
+				blockHeight: entry.blockHeight,
+				packedFlags: tfSpent | tfModified,
+			})
+			continue
+		}
+
+		cachedEntry := entry.Clone()
+		cachedEntry.packedFlags |= tfModified
+		cachedEntry.packedFlags &^= tfFresh
+		if fresh {
+			cachedEntry.packedFlags |= tfFresh
+		}
+		c.addEntry(outpoint, cachedEntry)
+	}
+}
+
+// dbPutCachedEntries uses an existing database transaction to write all of the
+// modified entries in the cache to the database and mark the utxo set as
+// consistent with the passed block hash.  The cache itself is not modified so
+// the caller must invoke markFlushed once the transaction has been committed.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (c *utxoCache) dbPutCachedEntries(dbTx database.Tx, bestHash *chainhash.Hash) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	err := dbPutUtxoEntries(dbTx, c.bucketName, c.cachedEntries)
+	if err != nil {
+		return err
+	}
+
+	return dbPutUtxoStateConsistency(dbTx, c.stateKeyName, bestHash)
+}
+
+// markFlushed clears the cache after its contents have been written to the
+// database and records the block the database is now consistent with.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (c *utxoCache) markFlushed(bestHash *chainhash.Hash) {
+	c.mtx.Lock()
+	c.cachedEntries = make(map[wire.OutPoint]*UtxoEntry)
+	c.totalEntryMemory = 0
+	c.mtx.Unlock()
+
+	c.lastFlushHash = *bestHash
+	c.lastFlushTime = time.Now()
+}
+
+// flush writes all of the modified entries in the cache to the database,
+// marks the utxo set as consistent with the passed block hash and then empties
+// the cache.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (c *utxoCache) flush(bestHash *chainhash.Hash) error {
+	log.Debugf("Flushing utxo cache of ~%d MiB to disk",
+		c.totalMemoryUsage()/(1024*1024))
+
+	err := c.db.Update(func(dbTx database.Tx) error {
+		return c.dbPutCachedEntries(dbTx, bestHash)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.markFlushed(bestHash)
+	return nil
+}
+
+// maybeFlush flushes the cache when required by the passed flush mode.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (c *utxoCache) maybeFlush(bestHash *chainhash.Hash, mode FlushMode) error {
+	// Nothing to flush if the database already reflects the best block.
+	if c.lastFlushHash == *bestHash {
+		return nil
+	}
+
+	switch mode {
+	case FlushRequired:
+		return c.flush(bestHash)
+
+	case FlushPeriodic:
+		if time.Since(c.lastFlushTime) >= utxoFlushPeriodicInterval {
+			return c.flush(bestHash)
+		}
+		fallthrough
+
+	case FlushIfNeeded:
+		if c.totalMemoryUsage() >= c.maxTotalMemoryUsage {
+			return c.flush(bestHash)
+		}
+	}
+
+	return nil
+}
+
+// initConsistentState brings the utxo set in line with the best chain after
+// loading the chain state.  When the node was not shut down cleanly, the
+// database may only reflect the utxo set as of an earlier block, in which case
+// all of the blocks connected after it are replayed into the cache.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) initConsistentState(interrupt <-chan struct{}) error {
+	var statusHash *chainhash.Hash
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		statusHash, err = dbFetchUtxoStateConsistency(dbTx,
+			b.utxoCache.stateKeyName)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Databases created before the utxo cache existed always wrote the
+	// utxo set along with each block, so they are consistent with the best
+	// chain by definition.  Record that so future loads can rely on it.
+	tip := b.bestChain.Tip()
+	if statusHash == nil {
+		err := b.db.Update(func(dbTx database.Tx) error {
+			return dbPutUtxoStateConsistency(dbTx,
+				b.utxoCache.stateKeyName, &tip.hash)
+		})
+		if err != nil {
+			return err
+		}
+
+		b.utxoCache.lastFlushHash = tip.hash
+		return nil
+	}
+
+	b.utxoCache.lastFlushHash = *statusHash
+	if *statusHash == tip.hash {
+		return nil
+	}
+
+	// The utxo set is only ever flushed for blocks in the main chain and
+	// all disconnects are flushed immediately, so the block it is
+	// consistent with must be an ancestor of the current tip.
+	statusNode := b.index.LookupNode(statusHash)
+	if statusNode == nil || !b.bestChain.Contains(statusNode) {
+		return AssertError(fmt.Sprintf("utxo set is consistent with "+
+			"block %v which is not in the main chain", statusHash))
+	}
+
+	log.Infof("Reconstructing utxo set from height %d to %d after an "+
+		"unclean shutdown", statusNode.height+1, tip.height)
+
+	for node := b.bestChain.Next(statusNode); node != nil; node = b.bestChain.Next(node) {
+		var block *btcutil.Block
+		err := b.db.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = dbFetchBlockByNode(dbTx, node)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		view := NewUtxoViewpoint()
+		err = view.fetchInputUtxos(b.utxoCache, block)
+		if err != nil {
+			return err
+		}
+		err = view.connectTransactions(block, nil)
+		if err != nil {
+			return err
+		}
+		b.utxoCache.commit(view)
+
+		// Flush when the cache fills up and when an interrupt is
+		// requested so the progress made so far is not lost.
+		if interruptRequested(interrupt) {
+			if err := b.utxoCache.flush(&node.hash); err != nil {
+				return err
+			}
+			return errInterruptRequested
+		}
+		err = b.utxoCache.maybeFlush(&node.hash, FlushIfNeeded)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Utxo set reconstruction complete")
+	return b.utxoCache.flush(&tip.hash)
+}
+
+// FlushUtxoCache flushes the utxo cache to the database according to the
+// passed flush mode.  It should be called with FlushRequired before shutting
+// down so the node does not need to replay any blocks on the next start.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) FlushUtxoCache(mode FlushMode) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	// Also flush the utxo set used to validate the blocks below a loaded
+	// utxo snapshot in the background.
+	if s := b.snapshot; s != nil && s.bgCache != nil {
+		err := s.bgCache.maybeFlush(&s.bgTip.hash, mode)
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.utxoCache.maybeFlush(&b.bestChain.Tip().hash, mode)
+}
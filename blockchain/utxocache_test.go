@@ -0,0 +1,255 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// dbUtxoEntry is a test helper that fetches the utxo entry for the passed
+// outpoint directly from the database bypassing the utxo cache.
+func dbUtxoEntry(t *testing.T, db database.DB, outpoint wire.OutPoint) *UtxoEntry {
+	t.Helper()
+
+	var entry *UtxoEntry
+	err := db.View(func(dbTx database.Tx) error {
+		var err error
+		entry, err = dbFetchUtxoEntry(dbTx, utxoSetBucketName, outpoint)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unable to fetch utxo entry %v: %v", outpoint, err)
+	}
+	return entry
+}
+
+// dbUtxoStateHash is a test helper that returns the hash of the block the utxo
+// set in the database is consistent with.
+func dbUtxoStateHash(t *testing.T, db database.DB) *chainhash.Hash {
+	t.Helper()
+
+	var hash *chainhash.Hash
+	err := db.View(func(dbTx database.Tx) error {
+		var err error
+		hash, err = dbFetchUtxoStateConsistency(dbTx,
+			utxoStateConsistencyKeyName)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unable to fetch utxo state consistency: %v", err)
+	}
+	return hash
+}
+
+// TestUtxoCacheCommit ensures committing views to the utxo cache tracks fresh
+// and spent entries properly and that flushing writes them to the database.
+func TestUtxoCacheCommit(t *testing.T) {
+	chain, teardownFunc, err := chainSetup("utxocachecommit",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	cache := chain.utxoCache
+	txOut := &wire.TxOut{Value: 1000, PkScript: []byte{txscript.OP_TRUE}}
+	fresh := wire.OutPoint{Hash: chainhash.Hash{0x01}, Index: 0}
+	stored := wire.OutPoint{Hash: chainhash.Hash{0x02}, Index: 0}
+
+	// Create two new outputs and ensure they are cached as fresh and
+	// modified without touching the database.
+	view := NewUtxoViewpoint()
+	view.addTxOut(fresh, txOut, false, 1)
+	view.addTxOut(stored, txOut, false, 1)
+	cache.commit(view)
+	view.commit()
+	for _, outpoint := range []wire.OutPoint{fresh, stored} {
+		entry := cache.cachedEntries[outpoint]
+		if entry == nil || !entry.isFresh() || !entry.isModified() {
+			t.Fatalf("expected fresh modified cache entry for %v",
+				outpoint)
+		}
+		if dbUtxoEntry(t, chain.db, outpoint) != nil {
+			t.Fatalf("unexpected database entry for %v", outpoint)
+		}
+	}
+	if cache.totalMemoryUsage() != 2*entryMemoryUsage(txOutEntry(txOut)) {
+		t.Fatalf("unexpected cache memory usage %d",
+			cache.totalMemoryUsage())
+	}
+
+	// Flush only the second output to the database by spending the first
+	// one before the flush.  The fresh output must simply be forgotten.
+	view.LookupEntry(fresh).Spend()
+	cache.commit(view)
+	view.commit()
+	if _, ok := cache.cachedEntries[fresh]; ok {
+		t.Fatalf("spent fresh entry %v was not removed", fresh)
+	}
+
+	flushHash := chainhash.Hash{0x03}
+	if err := cache.flush(&flushHash); err != nil {
+		t.Fatalf("unable to flush cache: %v", err)
+	}
+	if len(cache.cachedEntries) != 0 || cache.totalMemoryUsage() != 0 {
+		t.Fatalf("cache not empty after flush")
+	}
+	if got := dbUtxoStateHash(t, chain.db); got == nil || *got != flushHash {
+		t.Fatalf("unexpected utxo state hash %v, want %v", got,
+			flushHash)
+	}
+	if dbUtxoEntry(t, chain.db, fresh) != nil {
+		t.Fatalf("unexpected database entry for %v", fresh)
+	}
+	if dbUtxoEntry(t, chain.db, stored) == nil {
+		t.Fatalf("missing database entry for %v", stored)
+	}
+
+	// Spend the stored output through a new view.  Since it exists in the
+	// database, the cache must keep a spent marker until the next flush
+	// and report the output as missing in the meantime.
+	view = NewUtxoViewpoint()
+	if err := view.fetchUtxos(cache, []wire.OutPoint{stored}); err != nil {
+		t.Fatalf("unable to fetch utxos: %v", err)
+	}
+	entry := view.LookupEntry(stored)
+	if entry == nil || entry.isModified() || entry.isFresh() {
+		t.Fatalf("unexpected fetched entry for %v: %v", stored, entry)
+	}
+	entry.Spend()
+	cache.commit(view)
+	cached := cache.cachedEntries[stored]
+	if cached == nil || !cached.IsSpent() || cached.isFresh() {
+		t.Fatalf("expected spent marker in cache for %v", stored)
+	}
+	entries, err := cache.fetchEntries([]wire.OutPoint{stored})
+	if err != nil {
+		t.Fatalf("unable to fetch entries: %v", err)
+	}
+	if entries[0] != nil {
+		t.Fatalf("spent entry %v returned from cache", stored)
+	}
+
+	if err := cache.flush(&flushHash); err != nil {
+		t.Fatalf("unable to flush cache: %v", err)
+	}
+	if dbUtxoEntry(t, chain.db, stored) != nil {
+		t.Fatalf("spent entry %v not removed from database", stored)
+	}
+}
+
+// txOutEntry returns a utxo entry for the passed txout for use in computing
+// expected memory usage.
+func txOutEntry(txOut *wire.TxOut) *UtxoEntry {
+	return NewUtxoEntry(txOut, 0, false)
+}
+
+// TestUtxoCacheRecovery ensures the utxo set is reconstructed from the blocks
+// connected since the last flush when the cache is lost without a flush, such
+// as is the case with an unclean shutdown.
+func TestUtxoCacheRecovery(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v\n", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("utxocacherecovery",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// Since we're not dealing with the real block chain, set the coinbase
+	// maturity to 1.
+	chain.TstSetCoinbaseMaturity(1)
+
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+
+	// Determine the expected state of every output created by the blocks
+	// from the point of view of the cache.
+	var outpoints []wire.OutPoint
+	for _, block := range blocks[1:] {
+		for _, tx := range block.Transactions() {
+			for txOutIdx := range tx.MsgTx().TxOut {
+				outpoints = append(outpoints, wire.OutPoint{
+					Hash:  *tx.Hash(),
+					Index: uint32(txOutIdx),
+				})
+			}
+		}
+	}
+	expected := make(map[wire.OutPoint]*UtxoEntry)
+	for _, outpoint := range outpoints {
+		entry, err := chain.FetchUtxoEntry(outpoint)
+		if err != nil {
+			t.Fatalf("unable to fetch utxo entry: %v", err)
+		}
+		expected[outpoint] = entry
+	}
+
+	// None of the blocks should have been written to the utxo set yet
+	// since the cache is nowhere near full.
+	genesisHash := chaincfg.MainNetParams.GenesisHash
+	if got := dbUtxoStateHash(t, chain.db); *got != *genesisHash {
+		t.Fatalf("unexpected utxo state hash %v, want %v", got,
+			genesisHash)
+	}
+	for _, outpoint := range outpoints {
+		if dbUtxoEntry(t, chain.db, outpoint) != nil {
+			t.Fatalf("unexpected database entry for %v", outpoint)
+		}
+	}
+
+	// Load a new chain instance from the same database without flushing
+	// the cache of the original one and ensure the utxo set is brought up
+	// to date with the best chain.
+	paramsCopy := chaincfg.MainNetParams
+	reloaded, err := New(&Config{
+		DB:               chain.db,
+		ChainParams:      &paramsCopy,
+		TimeSource:       NewMedianTime(),
+		UtxoCacheMaxSize: DefaultUtxoCacheMaxSize,
+	})
+	if err != nil {
+		t.Fatalf("Failed to reload chain instance: %v", err)
+	}
+	tipHash := reloaded.BestSnapshot().Hash
+	if tipHash != *blocks[len(blocks)-1].Hash() {
+		t.Fatalf("unexpected best hash %v", tipHash)
+	}
+	if got := dbUtxoStateHash(t, reloaded.db); *got != tipHash {
+		t.Fatalf("unexpected utxo state hash %v, want %v", got,
+			tipHash)
+	}
+	for _, outpoint := range outpoints {
+		want := expected[outpoint]
+		got := dbUtxoEntry(t, reloaded.db, outpoint)
+		switch {
+		case want == nil && got == nil:
+			continue
+		case want == nil || got == nil:
+			t.Fatalf("mismatched database entry for %v: got %v, "+
+				"want %v", outpoint, got, want)
+		case got.Amount() != want.Amount() ||
+			got.BlockHeight() != want.BlockHeight() ||
+			got.IsCoinBase() != want.IsCoinBase():
+
+			t.Fatalf("mismatched database entry for %v: got %v, "+
+				"want %v", outpoint, got, want)
+		}
+	}
+}
@@ -0,0 +1,1153 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// -----------------------------------------------------------------------------
+// A utxo snapshot contains the utxo set as of a specific block, known as the
+// base block, along with the headers of the chain up to that block.  Loading a
+// snapshot whose utxo set hash is pinned in the chain parameters allows the
+// node to sync from the base block onwards right away while the blocks below
+// it are downloaded and validated in the background.
+//
+// The serialized format is:
+//
+//   <magic><version><network><base hash><base height><headers>
+//   <chain tx count><num coins><coins><utxo set hash>
+//
+//   Field             Type              Size
+//   magic             [5]byte           5
+//   version           uint16            2
+//   network           uint32            4
+//   base hash         chainhash.Hash    chainhash.HashSize
+//   base height       uint32            4
+//   headers           []wire.BlockHeader 80 * base height
+//   chain tx count    uint64            8
+//   num coins         uint64            8
+//   coins             []coin            variable
+//   utxo set hash     chainhash.Hash    chainhash.HashSize
+//
+// The headers are those of the blocks at heights 1 through the base height.
+// All integers are encoded in little endian.
+//
+// Each coin is serialized as:
+//
+//   <txid><VLQ output index><VLQ entry size><entry>
+//
+// The outpoint is encoded the same way as the keys of the utxo set bucket and
+// the entry is a utxo entry encoded as described by serializeUtxoEntry.  The
+// coins are ordered by their serialized outpoint.
+//
+// The utxo set hash is calculated the same way as the hash_serialized_3 value of
+// Bitcoin Core so the hashes pinned in the chain parameters can be taken from
+// it.  It is the double sha256 of all of the coins in the same order, each
+// serialized as:
+//
+//   <txid><output index><height and coinbase><amount><script len><script>
+//
+//   Field                Type              Size
+//   txid                 chainhash.Hash    chainhash.HashSize
+//   output index         uint32            4
+//   height and coinbase  uint32            4
+//   amount               int64             8
+//   script len           CompactSize       variable
+//   script               []byte            script len
+//
+// The height and coinbase field is the height of the block containing the coin
+// shifted left one bit with the lowest bit set when it is a coinbase output.
+// -----------------------------------------------------------------------------
+
+const (
+	// utxoSnapshotVersion is the current version of the utxo snapshot
+	// format.
+	utxoSnapshotVersion = 2
+
+	// utxoSnapshotHeaderSize is the size of the fixed fields that start a
+	// utxo snapshot.
+	utxoSnapshotHeaderSize = 5 + 2 + 4 + chainhash.HashSize + 4
+
+	// maxSnapshotEntrySize is the maximum allowed size of a serialized utxo
+	// entry in a utxo snapshot.
+	maxSnapshotEntrySize = wire.MaxBlockPayload
+
+	// snapshotBatchSize is the maximum number of coins written to the
+	// database in a single transaction while loading a utxo snapshot and
+	// the maximum number of entries removed from a utxo set bucket in a
+	// single transaction when it is cleared.
+	snapshotBatchSize = 200000
+)
+
+// utxoSnapshotMagic is the magic that identifies a utxo snapshot.
+var utxoSnapshotMagic = [5]byte{'u', 't', 'x', 'o', 0xff}
+
+// UtxoSnapshotInfo describes a utxo snapshot that was dumped or loaded.
+type UtxoSnapshotInfo struct {
+	// BaseHash and BaseHeight identify the block the utxo set in the
+	// snapshot is for.
+	BaseHash   chainhash.Hash
+	BaseHeight int32
+
+	// NumCoins is the number of unspent outputs in the snapshot.
+	NumCoins uint64
+
+	// ChainTxCount is the total number of transactions in the chain up to
+	// and including the base block.
+	ChainTxCount uint64
+
+	// UtxoSetHash is the hash of the utxo set in the snapshot.
+	UtxoSetHash chainhash.Hash
+}
+
+// ErrUtxoSnapshotInvalid is returned when the blocks below a loaded utxo
+// snapshot are invalid or did not result in the same utxo set as the snapshot.
+// The chain state built on top of the snapshot can't be trusted in that case,
+// so the chain must be resynced without the snapshot.
+var ErrUtxoSnapshotInvalid = errors.New("the blocks below the utxo snapshot " +
+	"did not validate")
+
+// snapshotStatus describes the validation status of the blocks below a loaded
+// utxo snapshot.
+type snapshotStatus byte
+
+const (
+	// snapshotValidating indicates the blocks below the snapshot are still
+	// being validated in the background.
+	snapshotValidating snapshotStatus = iota
+
+	// snapshotValidated indicates the blocks below the snapshot have been
+	// validated and resulted in the same utxo set as the snapshot.
+	snapshotValidated
+
+	// snapshotInvalid indicates the blocks below the snapshot are either
+	// invalid or did not result in the same utxo set as the snapshot.
+	snapshotInvalid
+)
+
+// snapshotState houses the state of a loaded utxo snapshot.
+type snapshotState struct {
+	// base is the block the loaded utxo set was for.
+	base *blockNode
+
+	// status is the validation status of the blocks below the base.
+	status snapshotStatus
+
+	// bgTip is the most recent block below the base that was validated in
+	// the background and bgCache is the utxo cache used to do so.  They
+	// are only set while the status is snapshotValidating.
+	bgTip   *blockNode
+	bgCache *utxoCache
+
+	// signal wakes up the goroutine validating the blocks below the base
+	// when more of them are stored, quit is closed to stop it and done is
+	// closed once it exits.
+	signal chan struct{}
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+// dbPutSnapshotState uses an existing database transaction to store the base
+// block and validation status of a loaded utxo snapshot.
+func dbPutSnapshotState(dbTx database.Tx, baseHash *chainhash.Hash, status snapshotStatus) error {
+	serialized := make([]byte, chainhash.HashSize+1)
+	copy(serialized, baseHash[:])
+	serialized[chainhash.HashSize] = byte(status)
+	return dbTx.Metadata().Put(snapshotStateKeyName, serialized)
+}
+
+// dbFetchSnapshotState uses an existing database transaction to fetch the base
+// block and validation status of a loaded utxo snapshot.  A nil hash is
+// returned when no snapshot was loaded.
+func dbFetchSnapshotState(dbTx database.Tx) (*chainhash.Hash, snapshotStatus, error) {
+	serialized := dbTx.Metadata().Get(snapshotStateKeyName)
+	if serialized == nil {
+		return nil, 0, nil
+	}
+	if len(serialized) != chainhash.HashSize+1 {
+		return nil, 0, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt utxo snapshot state",
+		}
+	}
+
+	var baseHash chainhash.Hash
+	copy(baseHash[:], serialized)
+	return &baseHash, snapshotStatus(serialized[chainhash.HashSize]), nil
+}
+
+// readVLQ reads a variable length quantity encoded as described by putVLQ
+// from the passed reader.
+func readVLQ(r io.ByteReader) (uint64, error) {
+	var n uint64
+	for i := 0; ; i++ {
+		// A uint64 can't take more than 10 bytes to encode.
+		if i == 10 {
+			return 0, errDeserialize("variable length quantity " +
+				"overflows uint64")
+		}
+
+		val, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n = (n << 7) | uint64(val&0x7f)
+		if val&0x80 != 0x80 {
+			break
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// appendVLQ appends the passed number encoded as a variable length quantity to
+// the passed slice.
+func appendVLQ(target []byte, n uint64) []byte {
+	var buf [10]byte
+	size := putVLQ(buf[:], n)
+	return append(target, buf[:size]...)
+}
+
+// utxoSetHasher calculates the hash of a utxo set as described by the utxo
+// snapshot format.
+type utxoSetHasher struct {
+	hasher hash.Hash
+	buf    bytes.Buffer
+}
+
+// newUtxoSetHasher returns a new utxo set hasher for an empty utxo set.
+func newUtxoSetHasher() *utxoSetHasher {
+	return &utxoSetHasher{hasher: sha256.New()}
+}
+
+// add adds the passed utxo to the hash.  The utxos must be added in the order
+// of their serialized outpoints.
+func (h *utxoSetHasher) add(outpoint wire.OutPoint, entry *UtxoEntry) {
+	heightAndCoinbase := uint32(entry.BlockHeight()) << 1
+	if entry.IsCoinBase() {
+		heightAndCoinbase |= 1
+	}
+
+	var buf [chainhash.HashSize + 16]byte
+	copy(buf[:], outpoint.Hash[:])
+	byteOrder.PutUint32(buf[chainhash.HashSize:], outpoint.Index)
+	byteOrder.PutUint32(buf[chainhash.HashSize+4:], heightAndCoinbase)
+	byteOrder.PutUint64(buf[chainhash.HashSize+8:], uint64(entry.Amount()))
+
+	h.buf.Reset()
+	h.buf.Write(buf[:])
+	_ = wire.WriteVarBytes(&h.buf, 0, entry.PkScript())
+	h.hasher.Write(h.buf.Bytes())
+}
+
+// sum returns the hash of all of the utxos added so far.
+func (h *utxoSetHasher) sum() chainhash.Hash {
+	return chainhash.HashH(h.hasher.Sum(nil))
+}
+
+// decodeOutpointKey decodes the passed key of a utxo set bucket back into the
+// outpoint it is for.
+func decodeOutpointKey(key []byte) (wire.OutPoint, error) {
+	var outpoint wire.OutPoint
+	if len(key) <= chainhash.HashSize {
+		return outpoint, errDeserialize("unexpected end of data")
+	}
+	copy(outpoint.Hash[:], key[:chainhash.HashSize])
+	index, bytesRead := deserializeVLQ(key[chainhash.HashSize:])
+	if bytesRead == 0 || index > uint64(^uint32(0)) {
+		return outpoint, errDeserialize("invalid output index")
+	}
+	outpoint.Index = uint32(index)
+	return outpoint, nil
+}
+
+// writeUtxoSetRecords writes all of the utxos in the passed utxo set bucket to
+// the passed writer as they are serialized in a utxo snapshot.  It returns the
+// number of utxos written along with the utxo set hash.  errInterruptRequested
+// is returned when the passed channel is closed before all of them are written.
+func writeUtxoSetRecords(w io.Writer, bucket database.Bucket,
+	interrupt <-chan struct{}) (uint64, chainhash.Hash, error) {
+
+	hasher := newUtxoSetHasher()
+	var numCoins uint64
+	var record []byte
+	cursor := bucket.Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		if interruptRequested(interrupt) {
+			return 0, chainhash.Hash{}, errInterruptRequested
+		}
+
+		outpoint, err := decodeOutpointKey(cursor.Key())
+		if err != nil {
+			return 0, chainhash.Hash{}, err
+		}
+		entry, err := deserializeUtxoEntry(cursor.Value())
+		if err != nil {
+			return 0, chainhash.Hash{}, err
+		}
+		hasher.add(outpoint, entry)
+
+		record = append(record[:0], cursor.Key()...)
+		record = appendVLQ(record, uint64(len(cursor.Value())))
+		record = append(record, cursor.Value()...)
+		if _, err := w.Write(record); err != nil {
+			return 0, chainhash.Hash{}, err
+		}
+		numCoins++
+	}
+
+	return numCoins, hasher.sum(), nil
+}
+
+// readUtxoSetRecord reads a single utxo as it is serialized in a utxo snapshot
+// from the passed reader.  It returns the outpoint and key of the utxo in the
+// utxo set bucket along with the serialized and deserialized entry.
+func readUtxoSetRecord(r *bufio.Reader) (wire.OutPoint, []byte, []byte, *UtxoEntry, error) {
+	var outpoint wire.OutPoint
+	if _, err := io.ReadFull(r, outpoint.Hash[:]); err != nil {
+		return outpoint, nil, nil, nil, err
+	}
+	index, err := readVLQ(r)
+	if err != nil {
+		return outpoint, nil, nil, nil, err
+	}
+	if index > uint64(^uint32(0)) {
+		return outpoint, nil, nil, nil, errDeserialize("output index " +
+			"overflows uint32")
+	}
+	outpoint.Index = uint32(index)
+	entrySize, err := readVLQ(r)
+	if err != nil {
+		return outpoint, nil, nil, nil, err
+	}
+	if entrySize > maxSnapshotEntrySize {
+		return outpoint, nil, nil, nil, errDeserialize(fmt.Sprintf(
+			"utxo entry size %d exceeds the max of %d", entrySize,
+			maxSnapshotEntrySize))
+	}
+
+	key := *outpointKey(outpoint)
+	serialized := make([]byte, entrySize)
+	if _, err := io.ReadFull(r, serialized); err != nil {
+		return outpoint, nil, nil, nil, err
+	}
+
+	// Ensure the entry is valid before adding it to the utxo set.
+	entry, err := deserializeUtxoEntry(serialized)
+	if err != nil {
+		return outpoint, nil, nil, nil, err
+	}
+
+	return outpoint, key, serialized, entry, nil
+}
+
+// clearUtxoBucket removes all of the entries from the utxo set bucket with the
+// passed name in batches so the database transactions don't grow too large.
+func clearUtxoBucket(db database.DB, bucketName []byte) error {
+	for {
+		var numDeleted int
+		err := db.Update(func(dbTx database.Tx) error {
+			bucket := dbTx.Metadata().Bucket(bucketName)
+			if bucket == nil {
+				return nil
+			}
+
+			cursor := bucket.Cursor()
+			for ok := cursor.First(); ok && numDeleted < snapshotBatchSize; ok = cursor.Next() {
+				if err := bucket.Delete(cursor.Key()); err != nil {
+					return err
+				}
+				numDeleted++
+			}
+			return nil
+		})
+		if err != nil || numDeleted == 0 {
+			return err
+		}
+	}
+}
+
+// findAssumeUtxo returns the utxo snapshot pinned in the chain parameters for
+// the passed base block hash or nil when there is none.
+func (b *BlockChain) findAssumeUtxo(hash *chainhash.Hash) *chaincfg.AssumeUtxo {
+	for i := range b.chainParams.AssumeUtxo {
+		assumeUtxo := &b.chainParams.AssumeUtxo[i]
+		if assumeUtxo.Hash.IsEqual(hash) {
+			return assumeUtxo
+		}
+	}
+	return nil
+}
+
+// DumpUtxoSnapshot writes a snapshot of the utxo set as of the current best
+// block to the passed writer.  The chain may continue to be extended while the
+// snapshot is written.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) DumpUtxoSnapshot(w io.Writer) (*UtxoSnapshotInfo, error) {
+	// Flush the utxo cache so the database reflects the best block and
+	// capture the state needed to write the snapshot.  The read-only
+	// database transaction provides a consistent view of the utxo set
+	// without the need to hold the chain lock while it is written.
+	b.chainLock.Lock()
+	tip := b.bestChain.Tip()
+	err := b.utxoCache.maybeFlush(&tip.hash, FlushRequired)
+	if err != nil {
+		b.chainLock.Unlock()
+		return nil, err
+	}
+	dbTx, err := b.db.Begin(false)
+	if err != nil {
+		b.chainLock.Unlock()
+		return nil, err
+	}
+	defer dbTx.Rollback()
+	nodes := make([]*blockNode, tip.height)
+	for node := tip; node.parent != nil; node = node.parent {
+		nodes[node.height-1] = node
+	}
+	chainTxCount := b.BestSnapshot().TotalTxns
+	bucketName := b.utxoCache.bucketName
+	b.chainLock.Unlock()
+
+	bucket := dbTx.Metadata().Bucket(bucketName)
+	var numCoins uint64
+	cursor := bucket.Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		numCoins++
+	}
+
+	bw := bufio.NewWriter(w)
+	var header [utxoSnapshotHeaderSize]byte
+	copy(header[:], utxoSnapshotMagic[:])
+	byteOrder.PutUint16(header[5:], utxoSnapshotVersion)
+	byteOrder.PutUint32(header[7:], uint32(b.chainParams.Net))
+	copy(header[11:], tip.hash[:])
+	byteOrder.PutUint32(header[11+chainhash.HashSize:], uint32(tip.height))
+	if _, err := bw.Write(header[:]); err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		header := node.Header()
+		if err := header.Serialize(bw); err != nil {
+			return nil, err
+		}
+	}
+	var counts [16]byte
+	byteOrder.PutUint64(counts[:], chainTxCount)
+	byteOrder.PutUint64(counts[8:], numCoins)
+	if _, err := bw.Write(counts[:]); err != nil {
+		return nil, err
+	}
+	written, utxoSetHash, err := writeUtxoSetRecords(bw, bucket,
+		b.interrupt)
+	if err != nil {
+		return nil, err
+	}
+	if written != numCoins {
+		return nil, AssertError(fmt.Sprintf("wrote %d coins to utxo "+
+			"snapshot instead of the expected %d", written,
+			numCoins))
+	}
+	if _, err := bw.Write(utxoSetHash[:]); err != nil {
+		return nil, err
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Wrote utxo snapshot with %d coins at height %d (%v)",
+		numCoins, tip.height, tip.hash)
+
+	return &UtxoSnapshotInfo{
+		BaseHash:     tip.hash,
+		BaseHeight:   tip.height,
+		NumCoins:     numCoins,
+		ChainTxCount: chainTxCount,
+		UtxoSetHash:  utxoSetHash,
+	}, nil
+}
+
+// LoadUtxoSnapshot loads the utxo snapshot read from the passed reader and
+// makes its base block the tip of the main chain so the node is able to sync
+// the blocks after it right away.  The blocks below the base are then
+// validated in the background as they are processed by ProcessBlock, which
+// only happens for blocks returned by SnapshotBlocksNeeded.
+//
+// Only snapshots whose base block and utxo set hash are pinned in the chain
+// parameters can be loaded and the chain must not have advanced beyond the
+// base block yet.  Loading a snapshot is not supported when optional indexes
+// or pruning are enabled.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) LoadUtxoSnapshot(r io.Reader) (*UtxoSnapshotInfo, error) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	if b.snapshot != nil {
+		return nil, errors.New("a utxo snapshot has already been loaded")
+	}
+	if b.indexManager != nil {
+		return nil, errors.New("utxo snapshots can't be loaded when " +
+			"optional indexes are enabled")
+	}
+	if b.pruneTarget != 0 || b.pruned {
+		return nil, errors.New("utxo snapshots can't be loaded when " +
+			"pruning is enabled")
+	}
+
+	br := bufio.NewReader(r)
+	var header [utxoSnapshotHeaderSize]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header[:5], utxoSnapshotMagic[:]) {
+		return nil, errors.New("not a utxo snapshot")
+	}
+	version := byteOrder.Uint16(header[5:])
+	if version != utxoSnapshotVersion {
+		return nil, fmt.Errorf("unsupported utxo snapshot version %d",
+			version)
+	}
+	net := wire.BitcoinNet(byteOrder.Uint32(header[7:]))
+	if net != b.chainParams.Net {
+		return nil, fmt.Errorf("utxo snapshot is for network %v "+
+			"instead of %v", net, b.chainParams.Net)
+	}
+	var baseHash chainhash.Hash
+	copy(baseHash[:], header[11:])
+	baseHeight := int32(byteOrder.Uint32(header[11+chainhash.HashSize:]))
+
+	// Only snapshots that are pinned in the chain parameters are allowed.
+	assumeUtxo := b.findAssumeUtxo(&baseHash)
+	if assumeUtxo == nil {
+		return nil, fmt.Errorf("utxo snapshot base block %v is not a "+
+			"known snapshot block", baseHash)
+	}
+	if assumeUtxo.Height != baseHeight {
+		return nil, fmt.Errorf("utxo snapshot base block %v has height "+
+			"%d instead of %d", baseHash, baseHeight,
+			assumeUtxo.Height)
+	}
+	tip := b.bestChain.Tip()
+	if tip.height >= baseHeight {
+		return nil, fmt.Errorf("the chain is already at height %d "+
+			"which is not below the utxo snapshot height %d",
+			tip.height, baseHeight)
+	}
+
+	// Read the headers and connect them to the block index.  Headers that
+	// are already known must link the same way and not be invalid while
+	// new ones must pass the usual header checks.
+	prevNode := b.bestChain.Genesis()
+	var newNodes []*blockNode
+	for height := int32(1); height <= baseHeight; height++ {
+		var header wire.BlockHeader
+		if err := header.Deserialize(br); err != nil {
+			return nil, err
+		}
+		if header.PrevBlock != prevNode.hash {
+			return nil, fmt.Errorf("utxo snapshot header at height "+
+				"%d does not connect to the previous header",
+				height)
+		}
+
+		hash := header.BlockHash()
+		if node := b.index.LookupNode(&hash); node != nil {
+			if b.index.NodeStatus(node).KnownInvalid() {
+				return nil, fmt.Errorf("utxo snapshot contains "+
+					"invalid block %v", hash)
+			}
+			prevNode = node
+			continue
+		}
+
+		err := checkBlockHeaderSanity(&header, b.chainParams.PowLimit,
+			b.timeSource, BFNone)
+		if err != nil {
+			return nil, err
+		}
+		err = b.checkBlockHeaderContext(&header, prevNode, BFNone)
+		if err != nil {
+			return nil, err
+		}
+		node := newBlockNode(&header, prevNode)
+		newNodes = append(newNodes, node)
+		prevNode = node
+	}
+	base := prevNode
+	if base.hash != baseHash {
+		return nil, fmt.Errorf("utxo snapshot headers end at block %v "+
+			"instead of the base block %v", base.hash, baseHash)
+	}
+	if base.Ancestor(tip.height) != tip {
+		return nil, fmt.Errorf("the best chain does not lead to utxo "+
+			"snapshot base block %v", baseHash)
+	}
+
+	var counts [16]byte
+	if _, err := io.ReadFull(br, counts[:]); err != nil {
+		return nil, err
+	}
+	chainTxCount := byteOrder.Uint64(counts[:])
+	numCoins := byteOrder.Uint64(counts[8:])
+	if chainTxCount != assumeUtxo.ChainTxCount {
+		return nil, fmt.Errorf("utxo snapshot has a chain transaction "+
+			"count of %d instead of %d", chainTxCount,
+			assumeUtxo.ChainTxCount)
+	}
+
+	log.Infof("Loading utxo snapshot with %d coins at height %d (%v)",
+		numCoins, baseHeight, baseHash)
+
+	// Write the coins to a separate utxo set bucket in batches while
+	// calculating the hash of the utxo set.  The bucket is cleared first
+	// in case a previous attempt to load a snapshot failed.
+	err := clearUtxoBucket(b.db, snapshotUtxoSetBucketName)
+	if err != nil {
+		return nil, err
+	}
+	hasher := newUtxoSetHasher()
+	var prevKey []byte
+	var numLoaded uint64
+	for numLoaded < numCoins {
+		err := b.db.Update(func(dbTx database.Tx) error {
+			meta := dbTx.Metadata()
+			bucket, err := meta.CreateBucketIfNotExists(
+				snapshotUtxoSetBucketName)
+			if err != nil {
+				return err
+			}
+
+			for i := 0; i < snapshotBatchSize && numLoaded < numCoins; i++ {
+				outpoint, key, serialized, entry, err :=
+					readUtxoSetRecord(br)
+				if err != nil {
+					return err
+				}
+				if bytes.Compare(key, prevKey) <= 0 {
+					return errDeserialize("utxo snapshot " +
+						"coins are not sorted")
+				}
+				if err := bucket.Put(key, serialized); err != nil {
+					return err
+				}
+
+				hasher.add(outpoint, entry)
+				prevKey = key
+				numLoaded++
+			}
+			return nil
+		})
+		if err != nil {
+			if clearErr := clearUtxoBucket(b.db, snapshotUtxoSetBucketName); clearErr != nil {
+				log.Warnf("Unable to remove partially loaded utxo "+
+					"snapshot: %v", clearErr)
+			}
+			return nil, err
+		}
+		log.Infof("Loaded %d of %d utxo snapshot coins", numLoaded,
+			numCoins)
+	}
+
+	// Ensure the utxo set matches both the hash in the snapshot and the
+	// one pinned in the chain parameters.
+	var footerHash chainhash.Hash
+	_, err = io.ReadFull(br, footerHash[:])
+	utxoSetHash := hasher.sum()
+	if err == nil && (footerHash != utxoSetHash ||
+		!assumeUtxo.UtxoSetHash.IsEqual(&utxoSetHash)) {
+
+		err = fmt.Errorf("utxo snapshot hash %v does not match the "+
+			"expected hash %v", utxoSetHash, assumeUtxo.UtxoSetHash)
+	}
+	if err != nil {
+		if clearErr := clearUtxoBucket(b.db, snapshotUtxoSetBucketName); clearErr != nil {
+			log.Warnf("Unable to remove invalid utxo snapshot: %v",
+				clearErr)
+		}
+		return nil, err
+	}
+
+	// Add the new headers to the block index and make the base block the
+	// tip of the main chain.  The existing utxo set is kept to validate
+	// the blocks below the base block in the background.
+	if err := b.utxoCache.maybeFlush(&tip.hash, FlushRequired); err != nil {
+		return nil, err
+	}
+	for _, node := range newNodes {
+		b.index.AddNode(node)
+	}
+	b.index.SetChainTxns(base, chainTxCount)
+	b.updateBestHeader(base)
+	if err := b.index.flushToDB(); err != nil {
+		return nil, err
+	}
+	state := newBestState(base, 0, 0, 0, chainTxCount,
+		base.CalcPastMedianTime())
+	err = b.db.Update(func(dbTx database.Tx) error {
+		for node := base; node != tip; node = node.parent {
+			err := dbPutBlockIndex(dbTx, &node.hash, node.height)
+			if err != nil {
+				return err
+			}
+		}
+		err := dbPutUtxoStateConsistency(dbTx, bgUtxoStateKeyName,
+			&tip.hash)
+		if err != nil {
+			return err
+		}
+		err = dbPutUtxoStateConsistency(dbTx,
+			utxoStateConsistencyKeyName, &base.hash)
+		if err != nil {
+			return err
+		}
+		err = dbPutSnapshotState(dbTx, &base.hash, snapshotValidating)
+		if err != nil {
+			return err
+		}
+		return dbPutBestState(dbTx, state, base.workSum)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	maxCacheSize := b.utxoCache.maxTotalMemoryUsage
+	bgCache := newUtxoCache(b.db, utxoSetBucketName, bgUtxoStateKeyName,
+		maxCacheSize)
+	bgCache.lastFlushHash = tip.hash
+	b.utxoCache = newUtxoCache(b.db, snapshotUtxoSetBucketName,
+		utxoStateConsistencyKeyName, maxCacheSize)
+	b.utxoCache.lastFlushHash = base.hash
+	b.snapshot = &snapshotState{
+		base:    base,
+		status:  snapshotValidating,
+		bgTip:   tip,
+		bgCache: bgCache,
+	}
+	b.startSnapshotValidation()
+	b.bestChain.SetTip(base)
+	b.stateLock.Lock()
+	b.stateSnapshot = state
+	b.stateLock.Unlock()
+
+	// The checkpoint state depends on the tip of the main chain, so make
+	// sure it is recalculated.
+	b.checkpointNode = nil
+	b.nextCheckpoint = nil
+
+	log.Infof("Loaded utxo snapshot at height %d (%v).  The blocks below "+
+		"it will be validated in the background", baseHeight, baseHash)
+
+	return &UtxoSnapshotInfo{
+		BaseHash:     baseHash,
+		BaseHeight:   baseHeight,
+		NumCoins:     numCoins,
+		ChainTxCount: chainTxCount,
+		UtxoSetHash:  utxoSetHash,
+	}, nil
+}
+
+// initSnapshotState loads the state of a previously loaded utxo snapshot from
+// the database and, when the blocks below it are still being validated, sets
+// up the utxo cache used to do so.
+//
+// This function MUST be called after the chain state is initialized and before
+// the utxo set is made consistent with the best chain.
+func (b *BlockChain) initSnapshotState() error {
+	var baseHash, bgHash *chainhash.Hash
+	var status snapshotStatus
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		baseHash, status, err = dbFetchSnapshotState(dbTx)
+		if err != nil || baseHash == nil || status != snapshotValidating {
+			return err
+		}
+
+		bgHash, err = dbFetchUtxoStateConsistency(dbTx,
+			bgUtxoStateKeyName)
+		return err
+	})
+	if err != nil || baseHash == nil {
+		return err
+	}
+
+	base := b.index.LookupNode(baseHash)
+	if base == nil || !b.bestChain.Contains(base) {
+		return AssertError(fmt.Sprintf("utxo snapshot base block %v "+
+			"is not in the main chain", baseHash))
+	}
+	maxCacheSize := b.utxoCache.maxTotalMemoryUsage
+	b.utxoCache = newUtxoCache(b.db, snapshotUtxoSetBucketName,
+		utxoStateConsistencyKeyName, maxCacheSize)
+	b.snapshot = &snapshotState{base: base, status: status}
+
+	switch status {
+	case snapshotValidated:
+		// The background utxo set is removed once the snapshot is
+		// validated, but that might have been interrupted.
+		return clearUtxoBucket(b.db, utxoSetBucketName)
+
+	case snapshotInvalid:
+		log.Errorf("The blocks below the utxo snapshot at height %d "+
+			"did not validate.  The chain must be resynced without "+
+			"the snapshot", base.height)
+		return ErrUtxoSnapshotInvalid
+	}
+
+	if b.indexManager != nil || b.pruneTarget != 0 {
+		return errors.New("optional indexes and pruning can't be " +
+			"enabled until the blocks below the utxo snapshot are " +
+			"validated")
+	}
+	if bgHash == nil {
+		return AssertError("missing background utxo set state")
+	}
+	bgTip := b.index.LookupNode(bgHash)
+	if bgTip == nil || base.Ancestor(bgTip.height) != bgTip {
+		return AssertError(fmt.Sprintf("background utxo set is "+
+			"consistent with block %v which is not below the utxo "+
+			"snapshot", bgHash))
+	}
+	b.snapshot.bgTip = bgTip
+	b.snapshot.bgCache = newUtxoCache(b.db, utxoSetBucketName,
+		bgUtxoStateKeyName, maxCacheSize)
+	b.snapshot.bgCache.lastFlushHash = *bgHash
+
+	log.Infof("Validating the blocks below the utxo snapshot at height %d "+
+		"in the background (height %d)", base.height, bgTip.height)
+	return nil
+}
+
+// SnapshotBlocksNeeded returns the hashes of the blocks below a loaded utxo
+// snapshot that are needed to continue validating them in the background.
+// Only the blocks within the passed number of heights after the last validated
+// block are returned.  Nil is returned when there is no snapshot being
+// validated.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) SnapshotBlocksNeeded(window int32) []chainhash.Hash {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	s := b.snapshot
+	if s == nil || s.bgCache == nil {
+		return nil
+	}
+
+	endHeight := s.bgTip.height + window
+	if endHeight > s.base.height {
+		endHeight = s.base.height
+	}
+	var hashes []chainhash.Hash
+	for height := s.bgTip.height + 1; height <= endHeight; height++ {
+		node := b.bestChain.NodeByHeight(height)
+		if !b.index.NodeStatus(node).HaveData() {
+			hashes = append(hashes, node.hash)
+		}
+	}
+	return hashes
+}
+
+// isSnapshotBlock returns whether or not the passed node is a block below a
+// loaded utxo snapshot that still needs to be stored and validated in the
+// background.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) isSnapshotBlock(node *blockNode) bool {
+	s := b.snapshot
+	return s != nil && s.bgCache != nil && node.height > s.bgTip.height &&
+		node.height <= s.base.height &&
+		!b.index.NodeStatus(node).HaveData() &&
+		b.bestChain.Contains(node)
+}
+
+// processSnapshotBlock stores the passed block below a loaded utxo snapshot
+// after making sure it matches its header and then notifies the background
+// validation goroutine about it.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) processSnapshotBlock(node *blockNode, block *btcutil.Block, flags BehaviorFlags) error {
+	err := checkBlockSanity(block, b.chainParams.PowLimit, b.timeSource,
+		flags)
+	if err != nil {
+		return err
+	}
+	if err := b.checkBlockContext(block, node.parent, flags); err != nil {
+		return err
+	}
+
+	err = b.db.Update(func(dbTx database.Tx) error {
+		return dbStoreBlock(dbTx, block)
+	})
+	if err != nil {
+		return err
+	}
+	b.index.SetStatusFlags(node, statusDataStored)
+	if err := b.index.flushToDB(); err != nil {
+		return err
+	}
+
+	select {
+	case b.snapshot.signal <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// startSnapshotValidation starts the goroutine that validates the blocks below
+// a loaded utxo snapshot in the background when they still need to be
+// validated.  It checks for blocks that are already stored right away.
+//
+// This function MUST be called with the chain state lock held (for writes) or
+// before the chain is used concurrently.
+func (b *BlockChain) startSnapshotValidation() {
+	s := b.snapshot
+	if s == nil || s.bgCache == nil {
+		return
+	}
+
+	s.signal = make(chan struct{}, 1)
+	s.quit = make(chan struct{})
+	s.done = make(chan struct{})
+	s.signal <- struct{}{}
+	go b.snapshotValidationHandler(s)
+}
+
+// StopSnapshotValidation stops validating the blocks below a loaded utxo
+// snapshot in the background and waits for it to finish.  No more blocks are
+// connected to the background utxo set afterwards, so it must be called before
+// flushing the utxo cache on shutdown.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) StopSnapshotValidation() {
+	b.chainLock.Lock()
+	s := b.snapshot
+	if s == nil || s.done == nil {
+		b.chainLock.Unlock()
+		return
+	}
+	if !interruptRequested(s.quit) {
+		close(s.quit)
+	}
+	b.chainLock.Unlock()
+
+	<-s.done
+}
+
+// snapshotValidationStopped returns whether or not validating the blocks below
+// the passed utxo snapshot was stopped or an interrupt was requested.
+func (b *BlockChain) snapshotValidationStopped(s *snapshotState) bool {
+	return interruptRequested(s.quit) || interruptRequested(b.interrupt)
+}
+
+// snapshotValidationHandler validates the blocks below a loaded utxo snapshot
+// as they are stored until the base block is reached or an interrupt is
+// requested.  The chain lock is only held while connecting a single block so
+// validating them does not hold up processing new blocks.  Errors, including
+// finding the snapshot invalid, are reported as fatal errors.
+//
+// This must be run as a goroutine.
+func (b *BlockChain) snapshotValidationHandler(s *snapshotState) {
+	defer close(s.done)
+
+	for {
+		select {
+		case <-s.signal:
+		case <-s.quit:
+			return
+		case <-b.interrupt:
+			return
+		}
+
+		finished, err := b.validateSnapshotBlocks(s)
+		if err != nil {
+			if err != ErrUtxoSnapshotInvalid {
+				log.Errorf("Unable to validate the blocks below "+
+					"the utxo snapshot: %v", err)
+			}
+			if b.fatalError != nil {
+				b.fatalError(err)
+			}
+			return
+		}
+		if finished {
+			return
+		}
+	}
+}
+
+// validateSnapshotBlocks connects the stored blocks below a loaded utxo
+// snapshot that follow the last validated one to the background utxo set.
+// Once the base block is reached, the resulting utxo set is compared to the
+// one from the snapshot.  It returns whether or not the validation finished,
+// either because the snapshot was validated or because it was found invalid.
+//
+// This function MUST NOT be called with the chain state lock held.
+func (b *BlockChain) validateSnapshotBlocks(s *snapshotState) (bool, error) {
+	for {
+		if b.snapshotValidationStopped(s) {
+			return false, nil
+		}
+
+		b.chainLock.Lock()
+		connected, err := b.validateNextSnapshotBlock(s)
+		atBase := s.bgCache != nil && s.bgTip == s.base
+		failed := s.bgCache == nil
+		b.chainLock.Unlock()
+		if err != nil || failed {
+			return failed, err
+		}
+		if atBase {
+			break
+		}
+		if !connected {
+			return false, nil
+		}
+	}
+
+	// All of the blocks below the snapshot are valid, so make sure the
+	// resulting utxo set is the same as the one in the snapshot.  Nothing
+	// else modifies the background utxo set, so it is hashed without
+	// holding the chain lock.  Hashing it is stopped along with the
+	// validation and starts over the next time the chain is loaded.
+	b.chainLock.Lock()
+	err := s.bgCache.flush(&s.base.hash)
+	b.chainLock.Unlock()
+	if err != nil {
+		return false, err
+	}
+	var utxoSetHash chainhash.Hash
+	err = b.db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+		var err error
+		_, utxoSetHash, err = writeUtxoSetRecords(io.Discard, bucket,
+			s.quit)
+		return err
+	})
+	if err == errInterruptRequested {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	if assumeUtxo := b.findAssumeUtxo(&s.base.hash); assumeUtxo == nil ||
+		!assumeUtxo.UtxoSetHash.IsEqual(&utxoSetHash) {
+
+		return true, b.failSnapshot()
+	}
+
+	err = b.db.Update(func(dbTx database.Tx) error {
+		err := dbTx.Metadata().Delete(bgUtxoStateKeyName)
+		if err != nil {
+			return err
+		}
+		return dbPutSnapshotState(dbTx, &s.base.hash, snapshotValidated)
+	})
+	if err != nil {
+		return false, err
+	}
+	s.status = snapshotValidated
+	s.bgTip = nil
+	s.bgCache = nil
+
+	// The background utxo set is no longer needed.
+	if err := clearUtxoBucket(b.db, utxoSetBucketName); err != nil {
+		return true, err
+	}
+
+	log.Infof("Validated the blocks below the utxo snapshot at height %d",
+		s.base.height)
+	return true, nil
+}
+
+// validateNextSnapshotBlock connects the block below a loaded utxo snapshot
+// that follows the last validated one to the background utxo set when it is
+// stored.  It returns whether or not a block was connected.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) validateNextSnapshotBlock(s *snapshotState) (bool, error) {
+	if s.bgCache == nil || s.bgTip == s.base {
+		return false, nil
+	}
+	node := b.bestChain.NodeByHeight(s.bgTip.height + 1)
+	if !b.index.NodeStatus(node).HaveData() {
+		return false, nil
+	}
+
+	var block *btcutil.Block
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		block, err = dbFetchBlockByNode(dbTx, node)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	view := NewUtxoViewpoint()
+	view.SetBestHash(&s.bgTip.hash)
+	err = b.checkConnectBlock(node, block, s.bgCache, view, nil)
+	if err != nil {
+		if _, ok := err.(RuleError); !ok {
+			return false, err
+		}
+
+		log.Errorf("Block %v below the utxo snapshot is invalid: %v",
+			node.hash, err)
+		b.index.SetStatusFlags(node, statusValidateFailed)
+		if err := b.index.flushToDB(); err != nil {
+			return false, err
+		}
+		return false, b.failSnapshot()
+	}
+	s.bgCache.commit(view)
+	s.bgTip = node
+	b.index.SetStatusFlags(node, statusValid)
+	b.index.ConnectChainTxns(node, len(block.Transactions()))
+
+	return true, s.bgCache.maybeFlush(&node.hash, FlushIfNeeded)
+}
+
+// failSnapshot marks the loaded utxo snapshot as invalid and stops validating
+// the blocks below it.  It returns ErrUtxoSnapshotInvalid once the status is
+// stored so the caller shuts down and the chain refuses to load afterwards.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) failSnapshot() error {
+	s := b.snapshot
+	err := b.db.Update(func(dbTx database.Tx) error {
+		return dbPutSnapshotState(dbTx, &s.base.hash, snapshotInvalid)
+	})
+	if err != nil {
+		return err
+	}
+	s.status = snapshotInvalid
+	s.bgTip = nil
+	s.bgCache = nil
+
+	log.Errorf("The blocks below the utxo snapshot at height %d did not "+
+		"validate.  The chain must be resynced without the snapshot",
+		s.base.height)
+	return ErrUtxoSnapshotInvalid
+}
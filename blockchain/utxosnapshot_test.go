@@ -0,0 +1,253 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// dumpTestUtxoSnapshot returns the test blocks along with a utxo snapshot of a
+// chain made up of them and the snapshot info.
+func dumpTestUtxoSnapshot(t *testing.T) ([]*btcutil.Block, []byte,
+	*UtxoSnapshotInfo) {
+
+	t.Helper()
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v\n", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("dumputxosnapshot",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+
+	var snapshot bytes.Buffer
+	info, err := chain.DumpUtxoSnapshot(&snapshot)
+	if err != nil {
+		t.Fatalf("DumpUtxoSnapshot: %v", err)
+	}
+	best := chain.BestSnapshot()
+	if info.BaseHash != best.Hash || info.BaseHeight != best.Height ||
+		info.ChainTxCount != best.TotalTxns || info.NumCoins != 5 {
+
+		t.Fatalf("unexpected snapshot info %+v", info)
+	}
+	return blocks, snapshot.Bytes(), info
+}
+
+// TestUtxoSnapshot ensures a utxo snapshot dumped from one chain can be loaded
+// into another one and that the blocks below it are validated in the
+// background.
+func TestUtxoSnapshot(t *testing.T) {
+	blocks, snapshotBytes, info := dumpTestUtxoSnapshot(t)
+	snapshot := bytes.NewBuffer(snapshotBytes)
+
+	// Loading a snapshot that is not pinned in the chain parameters or
+	// whose utxo set hash does not match must fail.
+	params := chaincfg.MainNetParams
+	badChain, teardownBad, err := chainSetup("loadbadutxosnapshot", &params)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	_, err = badChain.LoadUtxoSnapshot(bytes.NewReader(snapshot.Bytes()))
+	if err == nil {
+		teardownBad()
+		t.Fatal("LoadUtxoSnapshot of unknown snapshot did not fail")
+	}
+	params.AssumeUtxo = []chaincfg.AssumeUtxo{{
+		Height:       info.BaseHeight,
+		Hash:         &info.BaseHash,
+		UtxoSetHash:  &chainhash.Hash{},
+		ChainTxCount: info.ChainTxCount,
+	}}
+	badChain.chainParams.AssumeUtxo = params.AssumeUtxo
+	_, err = badChain.LoadUtxoSnapshot(bytes.NewReader(snapshot.Bytes()))
+	badHeight := badChain.BestSnapshot().Height
+	teardownBad()
+	if err == nil {
+		t.Fatal("LoadUtxoSnapshot with bad utxo set hash did not fail")
+	}
+	if badHeight != 0 {
+		t.Fatalf("unexpected height %d after failed load", badHeight)
+	}
+
+	// Load the snapshot into a fresh chain.
+	params.AssumeUtxo[0].UtxoSetHash = &info.UtxoSetHash
+	loaded, teardownLoaded, err := chainSetup("loadutxosnapshot", &params)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownLoaded()
+	loaded.TstSetCoinbaseMaturity(1)
+	if _, err := loaded.LoadUtxoSnapshot(snapshot); err != nil {
+		t.Fatalf("LoadUtxoSnapshot: %v", err)
+	}
+	if got := loaded.BestSnapshot(); got.Hash != info.BaseHash ||
+		got.TotalTxns != info.ChainTxCount {
+
+		t.Fatalf("unexpected best state %+v after load", got)
+	}
+	coinbase := wire.OutPoint{Hash: *blocks[4].Transactions()[0].Hash()}
+	entry, err := loaded.FetchUtxoEntry(coinbase)
+	if err != nil || entry == nil {
+		t.Fatalf("FetchUtxoEntry: unexpected entry %v, err %v", entry,
+			err)
+	}
+	needed := loaded.SnapshotBlocksNeeded(2)
+	if len(needed) != 2 || needed[0] != *blocks[1].Hash() {
+		t.Fatalf("unexpected blocks needed %v", needed)
+	}
+
+	// Stopping the background validation must wait for it to exit.
+	loaded.StopSnapshotValidation()
+	select {
+	case <-loaded.snapshot.done:
+	default:
+		t.Fatal("background validation still running after stop")
+	}
+	loaded.StopSnapshotValidation()
+
+	// The snapshot state must be restored when the chain is reloaded.
+	reloaded, err := New(&Config{
+		DB:               loaded.db,
+		ChainParams:      &params,
+		TimeSource:       NewMedianTime(),
+		UtxoCacheMaxSize: DefaultUtxoCacheMaxSize,
+	})
+	if err != nil {
+		t.Fatalf("Failed to reload chain instance: %v", err)
+	}
+	reloaded.TstSetCoinbaseMaturity(1)
+	if got := reloaded.BestSnapshot().Hash; got != info.BaseHash {
+		t.Fatalf("unexpected tip %v after reload", got)
+	}
+	if got := len(reloaded.SnapshotBlocksNeeded(100)); got != 4 {
+		t.Fatalf("unexpected number of blocks needed %d", got)
+	}
+
+	// Processing the blocks below the snapshot must validate them in the
+	// background and discard the background utxo set.
+	for _, block := range []*btcutil.Block{blocks[2], blocks[1], blocks[4],
+		blocks[3]} {
+
+		_, _, err := reloaded.ProcessBlock(block, BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock of block %v: %v", block.Hash(), err)
+		}
+	}
+	select {
+	case <-reloaded.snapshot.done:
+	case <-time.After(time.Minute):
+		t.Fatal("timeout waiting for the background validation")
+	}
+	reloaded.chainLock.RLock()
+	status := reloaded.snapshot.status
+	reloaded.chainLock.RUnlock()
+	if status != snapshotValidated {
+		t.Fatalf("unexpected snapshot status %v", status)
+	}
+	if needed := reloaded.SnapshotBlocksNeeded(100); needed != nil {
+		t.Fatalf("unexpected blocks needed %v", needed)
+	}
+	err = reloaded.db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+		if bucket.Cursor().First() {
+			t.Fatal("background utxo set was not removed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: %v", err)
+	}
+}
+
+// TestUtxoSnapshotInvalid ensures a utxo snapshot whose utxo set turns out not
+// to match the one resulting from the blocks below it is a fatal error and that
+// the chain refuses to load afterwards.
+func TestUtxoSnapshotInvalid(t *testing.T) {
+	blocks, snapshot, info := dumpTestUtxoSnapshot(t)
+
+	params := chaincfg.MainNetParams
+	params.AssumeUtxo = []chaincfg.AssumeUtxo{{
+		Height:       info.BaseHeight,
+		Hash:         &info.BaseHash,
+		UtxoSetHash:  &info.UtxoSetHash,
+		ChainTxCount: info.ChainTxCount,
+	}}
+	loaded, teardownFunc, err := chainSetup("invalidutxosnapshot", &params)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	_, err = loaded.LoadUtxoSnapshot(bytes.NewReader(snapshot))
+	if err != nil {
+		t.Fatalf("LoadUtxoSnapshot: %v", err)
+	}
+	loaded.StopSnapshotValidation()
+
+	// Reload the chain with a pinned utxo set hash that does not match the
+	// one resulting from the blocks below the snapshot.
+	badParams := params
+	badParams.AssumeUtxo = []chaincfg.AssumeUtxo{params.AssumeUtxo[0]}
+	badParams.AssumeUtxo[0].UtxoSetHash = &chainhash.Hash{0x01}
+	fatalErrors := make(chan error, 1)
+	reloaded, err := New(&Config{
+		DB:               loaded.db,
+		ChainParams:      &badParams,
+		TimeSource:       NewMedianTime(),
+		UtxoCacheMaxSize: DefaultUtxoCacheMaxSize,
+		FatalError: func(err error) {
+			fatalErrors <- err
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to reload chain instance: %v", err)
+	}
+	reloaded.TstSetCoinbaseMaturity(1)
+	for _, block := range blocks[1:] {
+		_, _, err := reloaded.ProcessBlock(block, BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock of block %v: %v", block.Hash(), err)
+		}
+	}
+	select {
+	case err := <-fatalErrors:
+		if err != ErrUtxoSnapshotInvalid {
+			t.Fatalf("unexpected fatal error: %v", err)
+		}
+	case <-time.After(time.Minute):
+		t.Fatal("timeout waiting for the background validation")
+	}
+
+	// The chain must refuse to load with the invalid snapshot.
+	_, err = New(&Config{
+		DB:               loaded.db,
+		ChainParams:      &badParams,
+		TimeSource:       NewMedianTime(),
+		UtxoCacheMaxSize: DefaultUtxoCacheMaxSize,
+	})
+	if err != ErrUtxoSnapshotInvalid {
+		t.Fatalf("unexpected error loading chain with invalid "+
+			"snapshot: %v", err)
+	}
+}
@@ -28,6 +28,11 @@ const (
 	// tfModified indicates that a txout has been modified since it was
 	// loaded.
 	tfModified
+
+	// tfFresh indicates that a txout is known not to exist in the database.
+	// Fresh outputs that are spent before the utxo cache is flushed can
+	// simply be forgotten instead of being deleted from the database.
+	tfFresh
 )
 
 // UtxoEntry houses details about an individual transaction output in a utxo
@@ -58,6 +63,12 @@ func (entry *UtxoEntry) isModified() bool {
 	return entry.packedFlags&tfModified == tfModified
 }
 
+// isFresh returns whether or not the output is known not to exist in the
+// database.
+func (entry *UtxoEntry) isFresh() bool {
+	return entry.packedFlags&tfFresh == tfFresh
+}
+
 // IsCoinBase returns whether or not the output was contained in a coinbase
 // transaction.
 func (entry *UtxoEntry) IsCoinBase() bool {
@@ -111,6 +122,15 @@ func (entry *UtxoEntry) Clone() *UtxoEntry {
 	}
 }
 
+// viewCopy returns a shallow copy of the utxo entry that is suitable for
+// handing out from the utxo cache.  The flags that are specific to the cache
+// are cleared so the copy starts out unmodified.
+func (entry *UtxoEntry) viewCopy() *UtxoEntry {
+	entryCopy := entry.Clone()
+	entryCopy.packedFlags &^= tfModified | tfFresh
+	return entryCopy
+}
+
 // NewUtxoEntry returns a new UtxoEntry built from the arguments.
 func NewUtxoEntry(
 	txOut *wire.TxOut, blockHeight int32, isCoinbase bool) *UtxoEntry {
@@ -190,16 +210,22 @@ func (view *UtxoViewpoint) addTxOut(outpoint wire.OutPoint, txOut *wire.TxOut, i
 	// possible (although extremely unlikely) that the existing entry is
 	// being replaced by a different transaction with the same hash.  This
 	// is allowed so long as the previous transaction is fully spent.
+	//
+	// Outputs that are not already in the view are marked fresh since they
+	// are being created by the transaction and therefore can't exist in the
+	// database yet.
+	var packedFlags txoFlags
 	entry := view.LookupEntry(outpoint)
 	if entry == nil {
 		entry = new(UtxoEntry)
 		view.entries[outpoint] = entry
+		packedFlags |= tfFresh
 	}
 
 	entry.amount = txOut.Value
 	entry.pkScript = txOut.PkScript
 	entry.blockHeight = blockHeight
-	entry.packedFlags = tfModified
+	entry.packedFlags = packedFlags | tfModified
 	if isCoinBase {
 		entry.packedFlags |= tfCoinBase
 	}
@@ -313,8 +339,9 @@ func (view *UtxoViewpoint) connectTransactions(block *btcutil.Block, stxos *[]Sp
 
 // fetchEntryByHash attempts to find any available utxo for the given hash by
 // searching the entire set of possible outputs for the given hash.  It checks
-// the view first and then falls back to the database if needed.
-func (view *UtxoViewpoint) fetchEntryByHash(db database.DB, hash *chainhash.Hash) (*UtxoEntry, error) {
+// the view first and then falls back to the utxo set the passed cache writes
+// back to if needed.
+func (view *UtxoViewpoint) fetchEntryByHash(utxos *utxoCache, hash *chainhash.Hash) (*UtxoEntry, error) {
 	// First attempt to find a utxo with the provided hash in the view.
 	prevOut := wire.OutPoint{Hash: *hash}
 	for idx := uint32(0); idx < MaxOutputsPerBlock; idx++ {
@@ -329,9 +356,10 @@ func (view *UtxoViewpoint) fetchEntryByHash(db database.DB, hash *chainhash.Hash
 	// often by the case since only specifically referenced utxos are loaded
 	// into the view.
 	var entry *UtxoEntry
-	err := db.View(func(dbTx database.Tx) error {
+	err := utxos.db.View(func(dbTx database.Tx) error {
 		var err error
-		entry, err = dbFetchUtxoEntryByHash(dbTx, hash)
+		entry, err = dbFetchUtxoEntryByHash(dbTx, utxos.bucketName,
+			hash)
 		return err
 	})
 	return entry, err
@@ -341,7 +369,7 @@ func (view *UtxoViewpoint) fetchEntryByHash(db database.DB, hash *chainhash.Hash
 // created by the passed block, restoring all utxos the transactions spent by
 // using the provided spent txo information, and setting the best hash for the
 // view to the block before the passed block.
-func (view *UtxoViewpoint) disconnectTransactions(db database.DB, block *btcutil.Block, stxos []SpentTxOut) error {
+func (view *UtxoViewpoint) disconnectTransactions(utxos *utxoCache, block *btcutil.Block, stxos []SpentTxOut) error {
 	// Sanity check the correct number of stxos are provided.
 	if len(stxos) != countSpentOutputs(block) {
 		return AssertError("disconnectTransactions called with bad " +
@@ -439,7 +467,7 @@ func (view *UtxoViewpoint) disconnectTransactions(db database.DB, block *btcutil
 			// only ever run with the new v2 format, this code path
 			// will never run.
 			if stxo.Height == 0 {
-				utxo, err := view.fetchEntryByHash(db, txHash)
+				utxo, err := view.fetchEntryByHash(utxos, txHash)
 				if err != nil {
 					return err
 				}
@@ -484,7 +512,9 @@ func (view *UtxoViewpoint) Entries() map[wire.OutPoint]*UtxoEntry {
 }
 
 // commit prunes all entries marked modified that are now fully spent and marks
-// all entries as unmodified.
+// all entries as unmodified.  The fresh flag is cleared as well since the
+// entries have been handed off to the utxo cache at this point and it is the
+// cache that tracks whether or not they exist in the database.
 func (view *UtxoViewpoint) commit() {
 	for outpoint, entry := range view.entries {
 		if entry == nil || (entry.isModified() && entry.IsSpent()) {
@@ -492,18 +522,19 @@ func (view *UtxoViewpoint) commit() {
 			continue
 		}
 
-		entry.packedFlags ^= tfModified
+		entry.packedFlags &^= tfModified | tfFresh
 	}
 }
 
 // fetchUtxosMain fetches unspent transaction output data about the provided
 // set of outpoints from the point of view of the end of the main chain at the
-// time of the call.
+// time of the call.  The utxo cache is consulted first and the database is
+// only accessed for outputs that are not cached.
 //
 // Upon completion of this function, the view will contain an entry for each
 // requested outpoint.  Spent outputs, or those which otherwise don't exist,
 // will result in a nil entry in the view.
-func (view *UtxoViewpoint) fetchUtxosMain(db database.DB, outpoints []wire.OutPoint) error {
+func (view *UtxoViewpoint) fetchUtxosMain(cache *utxoCache, outpoints []wire.OutPoint) error {
 	// Nothing to do if there are no requested outputs.
 	if len(outpoints) == 0 {
 		return nil
@@ -516,24 +547,21 @@ func (view *UtxoViewpoint) fetchUtxosMain(db database.DB, outpoints []wire.OutPo
 	// will result in nil entries in the view.  This is intentionally done
 	// so other code can use the presence of an entry in the store as a way
 	// to unnecessarily avoid attempting to reload it from the database.
-	return db.View(func(dbTx database.Tx) error {
-		for i := range outpoints {
-			entry, err := dbFetchUtxoEntry(dbTx, outpoints[i])
-			if err != nil {
-				return err
-			}
-
-			view.entries[outpoints[i]] = entry
-		}
+	entries, err := cache.fetchEntries(outpoints)
+	if err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		view.entries[outpoints[i]] = entry
+	}
 
-		return nil
-	})
+	return nil
 }
 
 // fetchUtxos loads the unspent transaction outputs for the provided set of
-// outputs into the view from the database as needed unless they already exist
+// outputs into the view from the utxo cache as needed unless they already exist
 // in the view in which case they are ignored.
-func (view *UtxoViewpoint) fetchUtxos(db database.DB, outpoints []wire.OutPoint) error {
+func (view *UtxoViewpoint) fetchUtxos(cache *utxoCache, outpoints []wire.OutPoint) error {
 	// Nothing to do if there are no requested outputs.
 	if len(outpoints) == 0 {
 		return nil
@@ -550,16 +578,16 @@ func (view *UtxoViewpoint) fetchUtxos(db database.DB, outpoints []wire.OutPoint)
 		needed = append(needed, outpoints[i])
 	}
 
-	// Request the input utxos from the database.
-	return view.fetchUtxosMain(db, needed)
+	// Request the input utxos from the cache.
+	return view.fetchUtxosMain(cache, needed)
 }
 
 // fetchInputUtxos loads the unspent transaction outputs for the inputs
 // referenced by the transactions in the given block into the view from the
-// database as needed.  In particular, referenced entries that are earlier in
+// utxo cache as needed.  In particular, referenced entries that are earlier in
 // the block are added to the view and entries that are already in the view are
 // not modified.
-func (view *UtxoViewpoint) fetchInputUtxos(db database.DB, block *btcutil.Block) error {
+func (view *UtxoViewpoint) fetchInputUtxos(cache *utxoCache, block *btcutil.Block) error {
 	// Build a map of in-flight transactions because some of the inputs in
 	// this block could be referencing other transactions earlier in this
 	// block which are not yet in the chain.
@@ -605,8 +633,8 @@ func (view *UtxoViewpoint) fetchInputUtxos(db database.DB, block *btcutil.Block)
 		}
 	}
 
-	// Request the input utxos from the database.
-	return view.fetchUtxosMain(db, needed)
+	// Request the input utxos from the cache.
+	return view.fetchUtxosMain(cache, needed)
 }
 
 // NewUtxoViewpoint returns a new empty unspent transaction output view.
@@ -646,7 +674,7 @@ func (b *BlockChain) FetchUtxoView(tx *btcutil.Tx) (*UtxoViewpoint, error) {
 	// chain.
 	view := NewUtxoViewpoint()
 	b.chainLock.RLock()
-	err := view.fetchUtxosMain(b.db, needed)
+	err := view.fetchUtxosMain(b.utxoCache, needed)
 	b.chainLock.RUnlock()
 	return view, err
 }
@@ -665,15 +693,10 @@ func (b *BlockChain) FetchUtxoEntry(outpoint wire.OutPoint) (*UtxoEntry, error)
 	b.chainLock.RLock()
 	defer b.chainLock.RUnlock()
 
-	var entry *UtxoEntry
-	err := b.db.View(func(dbTx database.Tx) error {
-		var err error
-		entry, err = dbFetchUtxoEntry(dbTx, outpoint)
-		return err
-	})
+	entries, err := b.utxoCache.fetchEntries([]wire.OutPoint{outpoint})
 	if err != nil {
 		return nil, err
 	}
 
-	return entry, nil
+	return entries[0], nil
 }
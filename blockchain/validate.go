@@ -170,11 +170,20 @@ func IsFinalizedTransaction(tx *btcutil.Tx, blockHeight int32, blockTime time.Ti
 // two blocks that violate the BIP0030 rule which prevents transactions from
 // overwriting old ones.
 func isBIP0030Node(node *blockNode) bool {
-	if node.height == 91842 && node.hash.IsEqual(block91842Hash) {
+	return IsBIP0030Block(&node.hash, node.height)
+}
+
+// IsBIP0030Block returns whether or not the block with the passed hash and
+// height is one of the two blocks that violate the BIP0030 rule which prevents
+// transactions from overwriting old ones.  The coinbase transactions of these
+// blocks duplicate earlier ones, so their outputs replace the unspent outputs
+// of the earlier transactions instead of adding new ones.
+func IsBIP0030Block(hash *chainhash.Hash, height int32) bool {
+	if height == 91842 && hash.IsEqual(block91842Hash) {
 		return true
 	}
 
-	if node.height == 91880 && node.hash.IsEqual(block91880Hash) {
+	if height == 91880 && hash.IsEqual(block91880Hash) {
 		return true
 	}
 
@@ -685,15 +694,22 @@ func (b *BlockChain) checkBlockHeaderContext(header *wire.BlockHeader, prevNode
 	// chain before it.  This prevents storage of new, otherwise valid,
 	// blocks which build off of old blocks that are likely at a much easier
 	// difficulty and therefore could be used to waste cache and disk space.
+	//
+	// Blocks that are already part of the main chain, such as those below
+	// a loaded utxo snapshot that are validated in the background, don't
+	// fork it.
 	checkpointNode, err := b.findPreviousCheckpoint()
 	if err != nil {
 		return err
 	}
 	if checkpointNode != nil && blockHeight < checkpointNode.height {
-		str := fmt.Sprintf("block at height %d forks the main chain "+
-			"before the previous checkpoint at height %d",
-			blockHeight, checkpointNode.height)
-		return ruleError(ErrForkTooOld, str)
+		node := b.index.LookupNode(&blockHash)
+		if node == nil || !b.bestChain.Contains(node) {
+			str := fmt.Sprintf("block at height %d forks the main "+
+				"chain before the previous checkpoint at "+
+				"height %d", blockHeight, checkpointNode.height)
+			return ruleError(ErrForkTooOld, str)
+		}
 	}
 
 	// Reject outdated block versions once a majority of the network
@@ -828,8 +844,10 @@ func (b *BlockChain) checkBlockContext(block *btcutil.Block, prevNode *blockNode
 // https://github.com/bitcoin/bips/blob/master/bip-0030.mediawiki and
 // http://r6.ca/blog/20120206T005236Z.html.
 //
+// The utxos not already in the view are loaded from the passed utxo cache.
+//
 // This function MUST be called with the chain state lock held (for reads).
-func (b *BlockChain) checkBIP0030(node *blockNode, block *btcutil.Block, view *UtxoViewpoint) error {
+func (b *BlockChain) checkBIP0030(node *blockNode, block *btcutil.Block, cache *utxoCache, view *UtxoViewpoint) error {
 	// Fetch utxos for all of the transaction ouputs in this block.
 	// Typically, there will not be any utxos for any of the outputs.
 	fetch := make([]wire.OutPoint, 0, len(block.Transactions()))
@@ -840,7 +858,7 @@ func (b *BlockChain) checkBIP0030(node *blockNode, block *btcutil.Block, view *U
 			fetch = append(fetch, prevOut)
 		}
 	}
-	err := view.fetchUtxos(b.db, fetch)
+	err := view.fetchUtxos(cache, fetch)
 	if err != nil {
 		return err
 	}
@@ -984,8 +1002,11 @@ func CheckTransactionInputs(tx *btcutil.Tx, txHeight int32, utxoView *UtxoViewpo
 // connects to the end of the current main chain and then calls this function
 // with that node.
 //
+// The utxos referenced by the block that are not already in the view are loaded
+// from the passed utxo cache.
+//
 // This function MUST be called with the chain state lock held (for writes).
-func (b *BlockChain) checkConnectBlock(node *blockNode, block *btcutil.Block, view *UtxoViewpoint, stxos *[]SpentTxOut) error {
+func (b *BlockChain) checkConnectBlock(node *blockNode, block *btcutil.Block, cache *utxoCache, view *UtxoViewpoint, stxos *[]SpentTxOut) error {
 	// If the side chain blocks end up in the database, a call to
 	// CheckBlockSanity should be done here in case a previous version
 	// allowed a block that is no longer valid.  However, since the
@@ -1024,7 +1045,7 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *btcutil.Block, vi
 	// BIP0030 check is expensive since it involves a ton of cache misses in
 	// the utxoset.
 	if !isBIP0030Node(node) && (node.height < b.chainParams.BIP0034Height) {
-		err := b.checkBIP0030(node, block, view)
+		err := b.checkBIP0030(node, block, cache, view)
 		if err != nil {
 			return err
 		}
@@ -1035,7 +1056,7 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *btcutil.Block, vi
 	//
 	// These utxo entries are needed for verification of things such as
 	// transaction inputs, counting pay-to-script-hashes, and scripts.
-	err := view.fetchInputUtxos(b.db, block)
+	err := view.fetchInputUtxos(cache, block)
 	if err != nil {
 		return err
 	}
@@ -1152,6 +1173,12 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *btcutil.Block, vi
 		runScripts = false
 	}
 
+	// Likewise, don't run scripts for the assumed valid block and its
+	// ancestors.  All of the other checks still apply to them.
+	if runScripts && b.isAssumedValid(node) {
+		runScripts = false
+	}
+
 	// Blocks created after the BIP0016 activation time need to have the
 	// pay-to-script-hash checks enabled.
 	var scriptFlags txscript.ScriptFlags
@@ -1286,5 +1313,5 @@ func (b *BlockChain) CheckConnectBlockTemplate(block *btcutil.Block) error {
 	view := NewUtxoViewpoint()
 	view.SetBestHash(&tip.hash)
 	newNode := newBlockNode(&header, tip)
-	return b.checkConnectBlock(newNode, block, view, nil)
+	return b.checkConnectBlock(newNode, block, b.utxoCache, view, nil)
 }
@@ -156,6 +156,46 @@ func btcdMain(serverChan chan<- *server) error {
 
 		return nil
 	}
+	if cfg.DropCoinStatsIndex {
+		if err := indexers.DropCoinStatsIndex(db, interrupt); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropTxoSpenderIndex {
+		if err := indexers.DropTxoSpenderIndex(db, interrupt); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropBalanceIndex {
+		if err := indexers.DropBalanceIndex(db, interrupt); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropScriptHashIndex {
+		if err := indexers.DropScriptHashIndex(db, interrupt); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropSpendJournal {
+		if err := indexers.DropSpendJournalIndex(db, interrupt); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
 
 	// The config file is already created if it did not exist and the log
 	// file has already been opened by now so we only need to allow
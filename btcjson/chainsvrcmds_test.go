@@ -265,6 +265,19 @@ func TestChainSvrCmds(t *testing.T) {
 				Range:      &btcjson.DescriptorRange{Value: []int{0, 2}},
 			},
 		},
+		{
+			name: "dumptxoutset",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("dumptxoutset", "utxo.dat")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDumpTxOutSetCmd("utxo.dat")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"dumptxoutset","params":["utxo.dat"],"id":1}`,
+			unmarshalled: &btcjson.DumpTxOutSetCmd{
+				Path: "utxo.dat",
+			},
+		},
 		{
 			name: "getaddednodeinfo",
 			newCmd: func() (interface{}, error) {
@@ -290,6 +303,62 @@ func TestChainSvrCmds(t *testing.T) {
 				Node: btcjson.String("127.0.0.1"),
 			},
 		},
+		{
+			name: "getaddressbalance",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressbalance", "1Address")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressBalanceCmd("1Address", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressbalance","params":["1Address"],"id":1}`,
+			unmarshalled: &btcjson.GetAddressBalanceCmd{
+				Address: "1Address",
+				Height:  nil,
+			},
+		},
+		{
+			name: "getaddressbalance optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressbalance", "1Address", 100)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressBalanceCmd("1Address", btcjson.Int32(100))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressbalance","params":["1Address",100],"id":1}`,
+			unmarshalled: &btcjson.GetAddressBalanceCmd{
+				Address: "1Address",
+				Height:  btcjson.Int32(100),
+			},
+		},
+		{
+			name: "getaddressutxos",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressutxos", "1Address")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressUtxosCmd("1Address", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressutxos","params":["1Address"],"id":1}`,
+			unmarshalled: &btcjson.GetAddressUtxosCmd{
+				Address: "1Address",
+				Height:  nil,
+			},
+		},
+		{
+			name: "getaddressutxos optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressutxos", "1Address", 100)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressUtxosCmd("1Address", btcjson.Int32(100))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressutxos","params":["1Address",100],"id":1}`,
+			unmarshalled: &btcjson.GetAddressUtxosCmd{
+				Address: "1Address",
+				Height:  btcjson.Int32(100),
+			},
+		},
 		{
 			name: "getbestblockhash",
 			newCmd: func() (interface{}, error) {
@@ -688,6 +757,30 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"gethashespersec","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetHashesPerSecCmd{},
 		},
+		{
+			name: "getindexinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getindexinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetIndexInfoCmd(nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getindexinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetIndexInfoCmd{},
+		},
+		{
+			name: "getindexinfo optional index name",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getindexinfo", btcjson.String("transaction index"))
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetIndexInfoCmd(btcjson.String("transaction index"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getindexinfo","params":["transaction index"],"id":1}`,
+			unmarshalled: &btcjson.GetIndexInfoCmd{
+				IndexName: btcjson.String("transaction index"),
+			},
+		},
 		{
 			name: "getinfo",
 			newCmd: func() (interface{}, error) {
@@ -699,6 +792,62 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getinfo","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetInfoCmd{},
 		},
+		{
+			name: "getmempoolancestors",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getmempoolancestors", "txhash")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetMempoolAncestorsCmd("txhash", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmempoolancestors","params":["txhash"],"id":1}`,
+			unmarshalled: &btcjson.GetMempoolAncestorsCmd{
+				TxID:    "txhash",
+				Verbose: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "getmempoolancestors optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getmempoolancestors", "txhash", true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetMempoolAncestorsCmd("txhash", btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmempoolancestors","params":["txhash",true],"id":1}`,
+			unmarshalled: &btcjson.GetMempoolAncestorsCmd{
+				TxID:    "txhash",
+				Verbose: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "getmempooldescendants",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getmempooldescendants", "txhash")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetMempoolDescendantsCmd("txhash", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmempooldescendants","params":["txhash"],"id":1}`,
+			unmarshalled: &btcjson.GetMempoolDescendantsCmd{
+				TxID:    "txhash",
+				Verbose: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "getmempooldescendants optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getmempooldescendants", "txhash", true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetMempoolDescendantsCmd("txhash", btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmempooldescendants","params":["txhash",true],"id":1}`,
+			unmarshalled: &btcjson.GetMempoolDescendantsCmd{
+				TxID:    "txhash",
+				Verbose: btcjson.Bool(true),
+			},
+		},
 		{
 			name: "getmempoolentry",
 			newCmd: func() (interface{}, error) {
@@ -955,10 +1104,61 @@ func TestChainSvrCmds(t *testing.T) {
 				return btcjson.NewCmd("gettxoutsetinfo")
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewGetTxOutSetInfoCmd()
+				return btcjson.NewGetTxOutSetInfoCmd(nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"gettxoutsetinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetTxOutSetInfoCmd{
+				HashType: btcjson.String("muhash"),
+			},
+		},
+		{
+			name: "gettxoutsetinfo height",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("gettxoutsetinfo", "muhash", btcjson.HashOrHeight{Value: 123})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetTxOutSetInfoCmd(btcjson.String("muhash"),
+					&btcjson.HashOrHeight{Value: 123})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"gettxoutsetinfo","params":["muhash",123],"id":1}`,
+			unmarshalled: &btcjson.GetTxOutSetInfoCmd{
+				HashType:     btcjson.String("muhash"),
+				HashOrHeight: &btcjson.HashOrHeight{Value: 123},
+			},
+		},
+		{
+			name: "gettxoutsetinfo hash",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("gettxoutsetinfo", "none", btcjson.HashOrHeight{Value: "deadbeef"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetTxOutSetInfoCmd(btcjson.String("none"),
+					&btcjson.HashOrHeight{Value: "deadbeef"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"gettxoutsetinfo","params":["none","deadbeef"],"id":1}`,
+			unmarshalled: &btcjson.GetTxOutSetInfoCmd{
+				HashType:     btcjson.String("none"),
+				HashOrHeight: &btcjson.HashOrHeight{Value: "deadbeef"},
+			},
+		},
+		{
+			name: "gettxspendingprevout",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("gettxspendingprevout",
+					`[{"txid":"123","vout":1}]`)
+			},
+			staticCmd: func() interface{} {
+				outputs := []btcjson.GetTxSpendingPrevOutCmdOutput{
+					{Txid: "123", Vout: 1},
+				}
+				return btcjson.NewGetTxSpendingPrevOutCmd(outputs)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"gettxspendingprevout","params":[[{"txid":"123","vout":1}]],"id":1}`,
+			unmarshalled: &btcjson.GetTxSpendingPrevOutCmd{
+				Outputs: []btcjson.GetTxSpendingPrevOutCmdOutput{
+					{Txid: "123", Vout: 1},
+				},
 			},
-			marshalled:   `{"jsonrpc":"1.0","method":"gettxoutsetinfo","params":[],"id":1}`,
-			unmarshalled: &btcjson.GetTxOutSetInfoCmd{},
 		},
 		{
 			name: "getwork",
@@ -1025,6 +1225,19 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "loadtxoutset",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("loadtxoutset", "utxo.dat")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewLoadTxOutSetCmd("utxo.dat")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"loadtxoutset","params":["utxo.dat"],"id":1}`,
+			unmarshalled: &btcjson.LoadTxOutSetCmd{
+				Path: "utxo.dat",
+			},
+		},
 		{
 			name: "ping",
 			newCmd: func() (interface{}, error) {
@@ -1062,6 +1275,17 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "savemempool",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("savemempool")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSaveMempoolCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"savemempool","params":[],"id":1}`,
+			unmarshalled: &btcjson.SaveMempoolCmd{},
+		},
 		{
 			name: "searchrawtransactions",
 			newCmd: func() (interface{}, error) {
@@ -1355,6 +1579,47 @@ func TestChainSvrCmds(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "submitpackage",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("submitpackage", []string{"1122", "3344"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSubmitPackageCmd([]string{"1122", "3344"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"submitpackage","params":[["1122","3344"]],"id":1}`,
+			unmarshalled: &btcjson.SubmitPackageCmd{
+				RawTxns: []string{"1122", "3344"},
+			},
+		},
+		{
+			name: "testmempoolaccept",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("testmempoolaccept", []string{"1122", "3344"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewTestMempoolAcceptCmd([]string{"1122", "3344"}, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"testmempoolaccept","params":[["1122","3344"]],"id":1}`,
+			unmarshalled: &btcjson.TestMempoolAcceptCmd{
+				RawTxns:    []string{"1122", "3344"},
+				MaxFeeRate: btcjson.Float64(0.10),
+			},
+		},
+		{
+			name: "testmempoolaccept optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("testmempoolaccept", []string{"1122"}, 0.5)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewTestMempoolAcceptCmd([]string{"1122"}, btcjson.Float64(0.5))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"testmempoolaccept","params":[["1122"],0.5],"id":1}`,
+			unmarshalled: &btcjson.TestMempoolAcceptCmd{
+				RawTxns:    []string{"1122"},
+				MaxFeeRate: btcjson.Float64(0.5),
+			},
+		},
 		{
 			name: "uptime",
 			newCmd: func() (interface{}, error) {
@@ -35,34 +35,37 @@ type GetBlockHeaderVerboseResult struct {
 
 // GetBlockStatsResult models the data from the getblockstats command.
 type GetBlockStatsResult struct {
-	AverageFee         int64   `json:"avgfee"`
-	AverageFeeRate     int64   `json:"avgfeerate"`
-	AverageTxSize      int64   `json:"avgtxsize"`
-	FeeratePercentiles []int64 `json:"feerate_percentiles"`
-	Hash               string  `json:"blockhash"`
-	Height             int64   `json:"height"`
-	Ins                int64   `json:"ins"`
-	MaxFee             int64   `json:"maxfee"`
-	MaxFeeRate         int64   `json:"maxfeerate"`
-	MaxTxSize          int64   `json:"maxtxsize"`
-	MedianFee          int64   `json:"medianfee"`
-	MedianTime         int64   `json:"mediantime"`
-	MedianTxSize       int64   `json:"mediantxsize"`
-	MinFee             int64   `json:"minfee"`
-	MinFeeRate         int64   `json:"minfeerate"`
-	MinTxSize          int64   `json:"mintxsize"`
-	Outs               int64   `json:"outs"`
-	SegWitTotalSize    int64   `json:"swtotal_size"`
-	SegWitTotalWeight  int64   `json:"swtotal_weight"`
-	SegWitTxs          int64   `json:"swtxs"`
-	Subsidy            int64   `json:"subsidy"`
-	Time               int64   `json:"time"`
-	TotalOut           int64   `json:"total_out"`
-	TotalSize          int64   `json:"total_size"`
-	TotalWeight        int64   `json:"total_weight"`
-	Txs                int64   `json:"txs"`
-	UTXOIncrease       int64   `json:"utxo_increase"`
-	UTXOSizeIncrease   int64   `json:"utxo_size_inc"`
+	AverageFee             int64   `json:"avgfee"`
+	AverageFeeRate         int64   `json:"avgfeerate"`
+	AverageTxSize          int64   `json:"avgtxsize"`
+	FeeratePercentiles     []int64 `json:"feerate_percentiles"`
+	Hash                   string  `json:"blockhash"`
+	Height                 int64   `json:"height"`
+	Ins                    int64   `json:"ins"`
+	MaxFee                 int64   `json:"maxfee"`
+	MaxFeeRate             int64   `json:"maxfeerate"`
+	MaxTxSize              int64   `json:"maxtxsize"`
+	MedianFee              int64   `json:"medianfee"`
+	MedianTime             int64   `json:"mediantime"`
+	MedianTxSize           int64   `json:"mediantxsize"`
+	MinFee                 int64   `json:"minfee"`
+	MinFeeRate             int64   `json:"minfeerate"`
+	MinTxSize              int64   `json:"mintxsize"`
+	Outs                   int64   `json:"outs"`
+	SegWitTotalSize        int64   `json:"swtotal_size"`
+	SegWitTotalWeight      int64   `json:"swtotal_weight"`
+	SegWitTxs              int64   `json:"swtxs"`
+	Subsidy                int64   `json:"subsidy"`
+	Time                   int64   `json:"time"`
+	TotalFee               int64   `json:"totalfee"`
+	TotalOut               int64   `json:"total_out"`
+	TotalSize              int64   `json:"total_size"`
+	TotalWeight            int64   `json:"total_weight"`
+	Txs                    int64   `json:"txs"`
+	UTXOIncrease           int64   `json:"utxo_increase"`
+	UTXOSizeIncrease       int64   `json:"utxo_size_inc"`
+	UTXOIncreaseActual     int64   `json:"utxo_increase_actual"`
+	UTXOSizeIncreaseActual int64   `json:"utxo_size_inc_actual"`
 }
 
 // GetBlockVerboseResult models the data from the getblock command when the
@@ -70,6 +73,8 @@ type GetBlockStatsResult struct {
 // hex-encoded string. When the verbose flag is set to 1, getblock returns an object
 // whose tx field is an array of transaction hashes. When the verbose flag is set to 2,
 // getblock returns an object whose tx field is an array of raw transactions.
+// When the verbose flag is set to 3, the raw transactions additionally include
+// their fees and the outputs spent by their inputs.
 // Use GetBlockVerboseTxResult to unmarshal data received from passing verbose=2 to getblock.
 type GetBlockVerboseResult struct {
 	Hash          string        `json:"hash"`
@@ -82,7 +87,7 @@ type GetBlockVerboseResult struct {
 	VersionHex    string        `json:"versionHex"`
 	MerkleRoot    string        `json:"merkleroot"`
 	Tx            []string      `json:"tx,omitempty"`
-	RawTx         []TxRawResult `json:"rawtx,omitempty"` // Note: this field is always empty when verbose != 2.
+	RawTx         []TxRawResult `json:"rawtx,omitempty"` // Note: this field is always empty when verbose < 2.
 	Time          int64         `json:"time"`
 	Nonce         uint32        `json:"nonce"`
 	Bits          string        `json:"bits"`
@@ -120,13 +125,20 @@ type GetBlockVerboseTxResult struct {
 // GetChainTxStatsResult models the data from the getchaintxstats command.
 type GetChainTxStatsResult struct {
 	Time                   int64   `json:"time"`
-	TxCount                int64   `json:"txcount"`
+	TxCount                int64   `json:"txcount,omitempty"`
 	WindowFinalBlockHash   string  `json:"window_final_block_hash"`
 	WindowFinalBlockHeight int32   `json:"window_final_block_height"`
 	WindowBlockCount       int32   `json:"window_block_count"`
-	WindowTxCount          int32   `json:"window_tx_count"`
+	WindowTxCount          int32   `json:"window_tx_count,omitempty"`
 	WindowInterval         int32   `json:"window_interval"`
-	TxRate                 float64 `json:"txrate"`
+	TxRate                 float64 `json:"txrate,omitempty"`
+}
+
+// GetIndexInfoResult models the data of an index returned by the getindexinfo
+// command.
+type GetIndexInfoResult struct {
+	Synced          bool  `json:"synced"`
+	BestBlockHeight int32 `json:"best_block_height"`
 }
 
 // CreateMultiSigResult models the data returned from the createmultisig
@@ -159,6 +171,35 @@ type GetAddedNodeInfoResult struct {
 	Addresses *[]GetAddedNodeInfoResultAddr `json:"addresses,omitempty"`
 }
 
+// GetAddressBalanceResult models the data from the getaddressbalance command.
+type GetAddressBalanceResult struct {
+	Address   string  `json:"address"`
+	Height    int32   `json:"height"`
+	BlockHash string  `json:"blockhash"`
+	Balance   float64 `json:"balance"`
+	Received  float64 `json:"received"`
+	Sent      float64 `json:"sent"`
+	UtxoCount int     `json:"utxocount"`
+}
+
+// GetAddressUtxosResultUtxo models the data of an unspent output returned by
+// the getaddressutxos command.
+type GetAddressUtxosResultUtxo struct {
+	Txid         string  `json:"txid"`
+	Vout         uint32  `json:"vout"`
+	Amount       float64 `json:"amount"`
+	Height       int32   `json:"height"`
+	ScriptPubKey string  `json:"scriptPubKey"`
+}
+
+// GetAddressUtxosResult models the data from the getaddressutxos command.
+type GetAddressUtxosResult struct {
+	Address   string                      `json:"address"`
+	Height    int32                       `json:"height"`
+	BlockHash string                      `json:"blockhash"`
+	Utxos     []GetAddressUtxosResultUtxo `json:"utxos"`
+}
+
 // SoftForkDescription describes the current state of a soft-fork which was
 // deployed using a super-majority block signalling.
 type SoftForkDescription struct {
@@ -228,10 +269,38 @@ type GetBlockChainInfoResult struct {
 	PruneHeight          int32   `json:"pruneheight,omitempty"`
 	ChainWork            string  `json:"chainwork,omitempty"`
 	SizeOnDisk           int64   `json:"size_on_disk,omitempty"`
+	AssumeValid          string  `json:"assumevalid,omitempty"`
+	AssumeValidActive    bool    `json:"assumevalidactive,omitempty"`
 	*SoftForks
 	*UnifiedSoftForks
 }
 
+// DumpTxOutSetResult models the data returned from the dumptxoutset command.
+type DumpTxOutSetResult struct {
+	CoinsWritten uint64 `json:"coins_written"`
+	BaseHash     string `json:"base_hash"`
+	BaseHeight   int32  `json:"base_height"`
+	Path         string `json:"path"`
+	TxOutSetHash string `json:"txoutset_hash"`
+	NChainTx     uint64 `json:"nchaintx"`
+}
+
+// LoadTxOutSetResult models the data returned from the loadtxoutset command.
+type LoadTxOutSetResult struct {
+	CoinsLoaded uint64 `json:"coins_loaded"`
+	TipHash     string `json:"tip_hash"`
+	BaseHeight  int32  `json:"base_height"`
+	Path        string `json:"path"`
+}
+
+// GetChainTipsResult models the data returned from the getchaintips command.
+type GetChainTipsResult struct {
+	Height    int32  `json:"height"`
+	Hash      string `json:"hash"`
+	BranchLen int32  `json:"branchlen"`
+	Status    string `json:"status"`
+}
+
 // GetBlockFilterResult models the data returned from the getblockfilter
 // command.
 type GetBlockFilterResult struct {
@@ -312,29 +381,82 @@ type MempoolFees struct {
 // GetMempoolEntryResult models the data returned from the getmempoolentry
 // command.
 type GetMempoolEntryResult struct {
-	VSize           int32       `json:"vsize"`
-	Size            int32       `json:"size"`
-	Weight          int64       `json:"weight"`
-	Fee             float64     `json:"fee"`
-	ModifiedFee     float64     `json:"modifiedfee"`
-	Time            int64       `json:"time"`
-	Height          int64       `json:"height"`
-	DescendantCount int64       `json:"descendantcount"`
-	DescendantSize  int64       `json:"descendantsize"`
-	DescendantFees  float64     `json:"descendantfees"`
-	AncestorCount   int64       `json:"ancestorcount"`
-	AncestorSize    int64       `json:"ancestorsize"`
-	AncestorFees    float64     `json:"ancestorfees"`
-	WTxId           string      `json:"wtxid"`
-	Fees            MempoolFees `json:"fees"`
-	Depends         []string    `json:"depends"`
+	VSize             int32       `json:"vsize"`
+	Size              int32       `json:"size"`
+	Weight            int64       `json:"weight"`
+	Fee               float64     `json:"fee"`
+	ModifiedFee       float64     `json:"modifiedfee"`
+	Time              int64       `json:"time"`
+	Height            int64       `json:"height"`
+	DescendantCount   int64       `json:"descendantcount"`
+	DescendantSize    int64       `json:"descendantsize"`
+	DescendantFees    float64     `json:"descendantfees"`
+	AncestorCount     int64       `json:"ancestorcount"`
+	AncestorSize      int64       `json:"ancestorsize"`
+	AncestorFees      float64     `json:"ancestorfees"`
+	WTxId             string      `json:"wtxid"`
+	Fees              MempoolFees `json:"fees"`
+	Depends           []string    `json:"depends"`
+	SpentBy           []string    `json:"spentby"`
+	BIP125Replaceable bool        `json:"bip125-replaceable"`
 }
 
 // GetMempoolInfoResult models the data returned from the getmempoolinfo
 // command.
 type GetMempoolInfoResult struct {
-	Size  int64 `json:"size"`
-	Bytes int64 `json:"bytes"`
+	Size          int64   `json:"size"`
+	Bytes         int64   `json:"bytes"`
+	Usage         int64   `json:"usage"`
+	MaxMempool    int64   `json:"maxmempool"`
+	MempoolMinFee float64 `json:"mempoolminfee"`
+	MinRelayTxFee float64 `json:"minrelaytxfee"`
+}
+
+// SaveMempoolResult models the data returned from the savemempool command.
+type SaveMempoolResult struct {
+	Filename string `json:"filename"`
+}
+
+// SubmitPackageFees models the fees of a transaction returned from the
+// submitpackage command.
+type SubmitPackageFees struct {
+	Base              float64  `json:"base"`
+	EffectiveFeeRate  float64  `json:"effective-feerate,omitempty"`
+	EffectiveIncludes []string `json:"effective-includes,omitempty"`
+}
+
+// SubmitPackageTxResult models the data returned from the submitpackage command
+// for each transaction.
+type SubmitPackageTxResult struct {
+	Txid  string             `json:"txid"`
+	Vsize int64              `json:"vsize,omitempty"`
+	Fees  *SubmitPackageFees `json:"fees,omitempty"`
+	Error string             `json:"error,omitempty"`
+}
+
+// SubmitPackageResult models the data returned from the submitpackage command.
+type SubmitPackageResult struct {
+	PackageMsg           string                           `json:"package_msg"`
+	TxResults            map[string]SubmitPackageTxResult `json:"tx-results"`
+	ReplacedTransactions []string                         `json:"replaced-transactions,omitempty"`
+}
+
+// TestMempoolAcceptFees models the fees of a transaction returned from the
+// testmempoolaccept command.
+type TestMempoolAcceptFees struct {
+	Base float64 `json:"base"`
+}
+
+// TestMempoolAcceptResult models the data returned from the testmempoolaccept
+// command for each transaction.
+type TestMempoolAcceptResult struct {
+	Txid         string                 `json:"txid"`
+	Wtxid        string                 `json:"wtxid"`
+	Allowed      bool                   `json:"allowed"`
+	Vsize        int64                  `json:"vsize,omitempty"`
+	Fees         *TestMempoolAcceptFees `json:"fees,omitempty"`
+	RejectCode   uint8                  `json:"reject-code,omitempty"`
+	RejectReason string                 `json:"reject-reason,omitempty"`
 }
 
 // NetworksResult models the networks data from the getnetworkinfo command.
@@ -443,6 +565,17 @@ type GetTxOutResult struct {
 	Coinbase      bool               `json:"coinbase"`
 }
 
+// GetTxSpendingPrevOutResult models the data of an output returned by the
+// gettxspendingprevout command.  The spending transaction and block hash are
+// omitted when the output is not spent and the block hash is omitted when it is
+// only spent by a transaction in the mempool.
+type GetTxSpendingPrevOutResult struct {
+	Txid         string `json:"txid"`
+	Vout         uint32 `json:"vout"`
+	SpendingTxid string `json:"spendingtxid,omitempty"`
+	BlockHash    string `json:"blockhash,omitempty"`
+}
+
 // GetTxOutSetInfoResult models the data from the gettxoutsetinfo command.
 type GetTxOutSetInfoResult struct {
 	Height         int64          `json:"height"`
@@ -451,10 +584,48 @@ type GetTxOutSetInfoResult struct {
 	TxOuts         int64          `json:"txouts"`
 	BogoSize       int64          `json:"bogosize"`
 	HashSerialized chainhash.Hash `json:"hash_serialized_2"`
+	MuHash         chainhash.Hash `json:"muhash"`
 	DiskSize       int64          `json:"disk_size"`
 	TotalAmount    btcutil.Amount `json:"total_amount"`
 }
 
+// MarshalJSON marshals the result of the gettxoutsetinfo JSON-RPC call.  The
+// hashes of the utxo set as well as the number of transactions and the disk
+// size are omitted when they are not set since not all of them are available
+// for every hash type.
+func (g GetTxOutSetInfoResult) MarshalJSON() ([]byte, error) {
+	// hashString returns the string form of the passed hash or an empty
+	// string when it is not set.
+	hashString := func(hash *chainhash.Hash) string {
+		if *hash == (chainhash.Hash{}) {
+			return ""
+		}
+		return hash.String()
+	}
+
+	return json.Marshal(&struct {
+		Height         int64   `json:"height"`
+		BestBlock      string  `json:"bestblock"`
+		Transactions   int64   `json:"transactions,omitempty"`
+		TxOuts         int64   `json:"txouts"`
+		BogoSize       int64   `json:"bogosize"`
+		HashSerialized string  `json:"hash_serialized_2,omitempty"`
+		MuHash         string  `json:"muhash,omitempty"`
+		DiskSize       int64   `json:"disk_size,omitempty"`
+		TotalAmount    float64 `json:"total_amount"`
+	}{
+		Height:         g.Height,
+		BestBlock:      g.BestBlock.String(),
+		Transactions:   g.Transactions,
+		TxOuts:         g.TxOuts,
+		BogoSize:       g.BogoSize,
+		HashSerialized: hashString(&g.HashSerialized),
+		MuHash:         hashString(&g.MuHash),
+		DiskSize:       g.DiskSize,
+		TotalAmount:    g.TotalAmount.ToBTC(),
+	})
+}
+
 // UnmarshalJSON unmarshals the result of the gettxoutsetinfo JSON-RPC call
 func (g *GetTxOutSetInfoResult) UnmarshalJSON(data []byte) error {
 	// Step 1: Create type aliases of the original struct.
@@ -465,6 +636,7 @@ func (g *GetTxOutSetInfoResult) UnmarshalJSON(data []byte) error {
 	aux := &struct {
 		BestBlock      string  `json:"bestblock"`
 		HashSerialized string  `json:"hash_serialized_2"`
+		MuHash         string  `json:"muhash"`
 		TotalAmount    float64 `json:"total_amount"`
 		*Alias
 	}{
@@ -491,6 +663,13 @@ func (g *GetTxOutSetInfoResult) UnmarshalJSON(data []byte) error {
 
 	g.HashSerialized = *serializedHash
 
+	muHash, err := chainhash.NewHashFromStr(aux.MuHash)
+	if err != nil {
+		return err
+	}
+
+	g.MuHash = *muHash
+
 	amount, err := btcutil.NewAmount(aux.TotalAmount)
 	if err != nil {
 		return err
@@ -520,12 +699,13 @@ type ScriptSig struct {
 // getrawtransaction, decoderawtransaction, and searchrawtransaction use the
 // same structure.
 type Vin struct {
-	Coinbase  string     `json:"coinbase"`
-	Txid      string     `json:"txid"`
-	Vout      uint32     `json:"vout"`
-	ScriptSig *ScriptSig `json:"scriptSig"`
-	Sequence  uint32     `json:"sequence"`
-	Witness   []string   `json:"txinwitness"`
+	Coinbase  string          `json:"coinbase"`
+	Txid      string          `json:"txid"`
+	Vout      uint32          `json:"vout"`
+	ScriptSig *ScriptSig      `json:"scriptSig"`
+	Sequence  uint32          `json:"sequence"`
+	Witness   []string        `json:"txinwitness"`
+	PrevOut   *PrevOutVerbose `json:"prevout,omitempty"`
 }
 
 // IsCoinBase returns a bool to show if a Vin is a Coinbase one or not.
@@ -556,35 +736,48 @@ func (v *Vin) MarshalJSON() ([]byte, error) {
 
 	if v.HasWitness() {
 		txStruct := struct {
-			Txid      string     `json:"txid"`
-			Vout      uint32     `json:"vout"`
-			ScriptSig *ScriptSig `json:"scriptSig"`
-			Witness   []string   `json:"txinwitness"`
-			Sequence  uint32     `json:"sequence"`
+			Txid      string          `json:"txid"`
+			Vout      uint32          `json:"vout"`
+			ScriptSig *ScriptSig      `json:"scriptSig"`
+			Witness   []string        `json:"txinwitness"`
+			PrevOut   *PrevOutVerbose `json:"prevout,omitempty"`
+			Sequence  uint32          `json:"sequence"`
 		}{
 			Txid:      v.Txid,
 			Vout:      v.Vout,
 			ScriptSig: v.ScriptSig,
 			Witness:   v.Witness,
+			PrevOut:   v.PrevOut,
 			Sequence:  v.Sequence,
 		}
 		return json.Marshal(txStruct)
 	}
 
 	txStruct := struct {
-		Txid      string     `json:"txid"`
-		Vout      uint32     `json:"vout"`
-		ScriptSig *ScriptSig `json:"scriptSig"`
-		Sequence  uint32     `json:"sequence"`
+		Txid      string          `json:"txid"`
+		Vout      uint32          `json:"vout"`
+		ScriptSig *ScriptSig      `json:"scriptSig"`
+		PrevOut   *PrevOutVerbose `json:"prevout,omitempty"`
+		Sequence  uint32          `json:"sequence"`
 	}{
 		Txid:      v.Txid,
 		Vout:      v.Vout,
 		ScriptSig: v.ScriptSig,
+		PrevOut:   v.PrevOut,
 		Sequence:  v.Sequence,
 	}
 	return json.Marshal(txStruct)
 }
 
+// PrevOutVerbose models the output spent by an input as returned by getblock
+// when the verbosity is set to 3.
+type PrevOutVerbose struct {
+	Generated    bool               `json:"generated"`
+	Height       int32              `json:"height"`
+	Value        float64            `json:"value"`
+	ScriptPubKey ScriptPubKeyResult `json:"scriptPubKey"`
+}
+
 // PrevOut represents previous output for an input Vin.
 type PrevOut struct {
 	Addresses []string `json:"addresses,omitempty"`
@@ -709,20 +902,21 @@ type InfoChainResult struct {
 
 // TxRawResult models the data from the getrawtransaction command.
 type TxRawResult struct {
-	Hex           string `json:"hex"`
-	Txid          string `json:"txid"`
-	Hash          string `json:"hash,omitempty"`
-	Size          int32  `json:"size,omitempty"`
-	Vsize         int32  `json:"vsize,omitempty"`
-	Weight        int32  `json:"weight,omitempty"`
-	Version       uint32 `json:"version"`
-	LockTime      uint32 `json:"locktime"`
-	Vin           []Vin  `json:"vin"`
-	Vout          []Vout `json:"vout"`
-	BlockHash     string `json:"blockhash,omitempty"`
-	Confirmations uint64 `json:"confirmations,omitempty"`
-	Time          int64  `json:"time,omitempty"`
-	Blocktime     int64  `json:"blocktime,omitempty"`
+	Hex           string   `json:"hex"`
+	Txid          string   `json:"txid"`
+	Hash          string   `json:"hash,omitempty"`
+	Size          int32    `json:"size,omitempty"`
+	Vsize         int32    `json:"vsize,omitempty"`
+	Weight        int32    `json:"weight,omitempty"`
+	Version       uint32   `json:"version"`
+	LockTime      uint32   `json:"locktime"`
+	Vin           []Vin    `json:"vin"`
+	Vout          []Vout   `json:"vout"`
+	Fee           *float64 `json:"fee,omitempty"`
+	BlockHash     string   `json:"blockhash,omitempty"`
+	Confirmations uint64   `json:"confirmations,omitempty"`
+	Time          int64    `json:"time,omitempty"`
+	Blocktime     int64    `json:"blocktime,omitempty"`
 }
 
 // SearchRawTransactionsResult models the data from the searchrawtransaction
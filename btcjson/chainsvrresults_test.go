@@ -48,6 +48,30 @@ func TestChainSvrCustomResults(t *testing.T) {
 			},
 			expected: `{"txid":"123","vout":1,"scriptSig":{"asm":"0","hex":"00"},"sequence":4294967295}`,
 		},
+		{
+			name: "custom vin marshal with prevout",
+			result: &btcjson.Vin{
+				Txid: "123",
+				Vout: 1,
+				ScriptSig: &btcjson.ScriptSig{
+					Asm: "",
+					Hex: "",
+				},
+				Witness: []string{"01"},
+				PrevOut: &btcjson.PrevOutVerbose{
+					Generated: true,
+					Height:    100,
+					Value:     50,
+					ScriptPubKey: btcjson.ScriptPubKeyResult{
+						Asm:  "OP_TRUE",
+						Hex:  "51",
+						Type: "nonstandard",
+					},
+				},
+				Sequence: 4294967295,
+			},
+			expected: `{"txid":"123","vout":1,"scriptSig":{"asm":"","hex":""},"txinwitness":["01"],"prevout":{"generated":true,"height":100,"value":50,"scriptPubKey":{"asm":"OP_TRUE","hex":"51","type":"nonstandard"}},"sequence":4294967295}`,
+		},
 		{
 			name: "custom vinprevout marshal with coinbase",
 			result: &btcjson.VinPrevOut{
@@ -148,6 +172,39 @@ func TestGetTxOutSetInfoResult(t *testing.T) {
 						panic(err)
 					}
 
+					return a
+				}(),
+			},
+		},
+		{
+			name:   "GetTxOutSetInfoResult - muhash",
+			result: `{"height":123,"bestblock":"000000000000005f94116250e2407310463c0a7cf950f1af9ebe935b1c0687ab","txouts":1,"bogosize":1,"muhash":"10d312b100cbd32ada024a6646e40d3482fcff103668d2625f10002a607d5863","total_amount":0.2}`,
+			want: btcjson.GetTxOutSetInfoResult{
+				Height: 123,
+				BestBlock: func() chainhash.Hash {
+					h, err := chainhash.NewHashFromStr("000000000000005f94116250e2407310463c0a7cf950f1af9ebe935b1c0687ab")
+					if err != nil {
+						panic(err)
+					}
+
+					return *h
+				}(),
+				TxOuts:   1,
+				BogoSize: 1,
+				MuHash: func() chainhash.Hash {
+					h, err := chainhash.NewHashFromStr("10d312b100cbd32ada024a6646e40d3482fcff103668d2625f10002a607d5863")
+					if err != nil {
+						panic(err)
+					}
+
+					return *h
+				}(),
+				TotalAmount: func() btcutil.Amount {
+					a, err := btcutil.NewAmount(0.2)
+					if err != nil {
+						panic(err)
+					}
+
 					return a
 				}(),
 			},
@@ -170,6 +227,19 @@ func TestGetTxOutSetInfoResult(t *testing.T) {
 				spew.Sdump(test.want))
 			continue
 		}
+
+		marshalled, err := json.Marshal(&test.want)
+		if err != nil {
+			t.Errorf("Test #%d (%s) unexpected marshal error: %v",
+				i, test.name, err)
+			continue
+		}
+		if string(marshalled) != test.result {
+			t.Errorf("Test #%d (%s) unexpected marshalled data - "+
+				"got %s, want %s", i, test.name, marshalled,
+				test.result)
+			continue
+		}
 	}
 }
 
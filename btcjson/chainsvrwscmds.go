@@ -221,18 +221,72 @@ func NewRescanBlocksCmd(blockHashes []string) *RescanBlocksCmd {
 	return &RescanBlocksCmd{BlockHashes: blockHashes}
 }
 
+// NotifyIndexEventsCmd defines the notifyindexevents JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+type NotifyIndexEventsCmd struct {
+	// Height is the height of the last block the client has processed or
+	// -1 to start from the genesis block.
+	Height int32
+
+	// Hash is the hash of the last block the client has processed.  When
+	// it is not provided, the block at Height in the main chain is used.
+	Hash *string
+}
+
+// NewNotifyIndexEventsCmd returns a new instance which can be used to issue a
+// notifyindexevents JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewNotifyIndexEventsCmd(height int32, hash *string) *NotifyIndexEventsCmd {
+	return &NotifyIndexEventsCmd{
+		Height: height,
+		Hash:   hash,
+	}
+}
+
+// AckIndexEventsCmd defines the ackindexevents JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+type AckIndexEventsCmd struct {
+	// Height is the height of the chain the client is left with after
+	// processing the acknowledged events.
+	Height int32
+}
+
+// NewAckIndexEventsCmd returns a new instance which can be used to issue an
+// ackindexevents JSON-RPC command.
+func NewAckIndexEventsCmd(height int32) *AckIndexEventsCmd {
+	return &AckIndexEventsCmd{Height: height}
+}
+
+// StopNotifyIndexEventsCmd defines the stopnotifyindexevents JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+type StopNotifyIndexEventsCmd struct{}
+
+// NewStopNotifyIndexEventsCmd returns a new instance which can be used to
+// issue a stopnotifyindexevents JSON-RPC command.
+func NewStopNotifyIndexEventsCmd() *StopNotifyIndexEventsCmd {
+	return &StopNotifyIndexEventsCmd{}
+}
+
 func init() {
 	// The commands in this file are only usable by websockets.
 	flags := UFWebsocketOnly
 
+	MustRegisterCmd("ackindexevents", (*AckIndexEventsCmd)(nil), flags)
 	MustRegisterCmd("authenticate", (*AuthenticateCmd)(nil), flags)
 	MustRegisterCmd("loadtxfilter", (*LoadTxFilterCmd)(nil), flags)
 	MustRegisterCmd("notifyblocks", (*NotifyBlocksCmd)(nil), flags)
+	MustRegisterCmd("notifyindexevents", (*NotifyIndexEventsCmd)(nil), flags)
 	MustRegisterCmd("notifynewtransactions", (*NotifyNewTransactionsCmd)(nil), flags)
 	MustRegisterCmd("notifyreceived", (*NotifyReceivedCmd)(nil), flags)
 	MustRegisterCmd("notifyspent", (*NotifySpentCmd)(nil), flags)
 	MustRegisterCmd("session", (*SessionCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyblocks", (*StopNotifyBlocksCmd)(nil), flags)
+	MustRegisterCmd("stopnotifyindexevents", (*StopNotifyIndexEventsCmd)(nil), flags)
 	MustRegisterCmd("stopnotifynewtransactions", (*StopNotifyNewTransactionsCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyspent", (*StopNotifySpentCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyreceived", (*StopNotifyReceivedCmd)(nil), flags)
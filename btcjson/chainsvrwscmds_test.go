@@ -227,6 +227,58 @@ func TestChainSvrWsCmds(t *testing.T) {
 				BlockHashes: []string{"0000000000000000000000000000000000000000000000000000000000000123"},
 			},
 		},
+		{
+			name: "notifyindexevents",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifyindexevents", -1)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyIndexEventsCmd(-1, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"notifyindexevents","params":[-1],"id":1}`,
+			unmarshalled: &btcjson.NotifyIndexEventsCmd{
+				Height: -1,
+			},
+		},
+		{
+			name: "notifyindexevents optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifyindexevents", 100, "0000000000000000000000000000000000000000000000000000000000000123")
+			},
+			staticCmd: func() interface{} {
+				hash := "0000000000000000000000000000000000000000000000000000000000000123"
+				return btcjson.NewNotifyIndexEventsCmd(100, &hash)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"notifyindexevents","params":[100,"0000000000000000000000000000000000000000000000000000000000000123"],"id":1}`,
+			unmarshalled: &btcjson.NotifyIndexEventsCmd{
+				Height: 100,
+				Hash:   btcjson.String("0000000000000000000000000000000000000000000000000000000000000123"),
+			},
+		},
+		{
+			name: "ackindexevents",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("ackindexevents", 100)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAckIndexEventsCmd(100)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"ackindexevents","params":[100],"id":1}`,
+			unmarshalled: &btcjson.AckIndexEventsCmd{
+				Height: 100,
+			},
+		},
+		{
+			name: "stopnotifyindexevents",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopnotifyindexevents")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyIndexEventsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifyindexevents","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyIndexEventsCmd{},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -31,6 +31,16 @@ const (
 	// disconnected.
 	FilteredBlockDisconnectedNtfnMethod = "filteredblockdisconnected"
 
+	// IndexBlockConnectedNtfnMethod is the method used for notifications
+	// from the chain server that stream a block along with the outputs it
+	// spends to a client that extends its index with the block.
+	IndexBlockConnectedNtfnMethod = "indexblockconnected"
+
+	// IndexBlockDisconnectedNtfnMethod is the method used for notifications
+	// from the chain server that stream a block along with the outputs it
+	// spends to a client that removes the block from its index.
+	IndexBlockDisconnectedNtfnMethod = "indexblockdisconnected"
+
 	// RecvTxNtfnMethod is the legacy, deprecated method used for
 	// notifications from the chain server that a transaction which pays to
 	// a registered address has been processed.
@@ -153,6 +163,51 @@ func NewFilteredBlockDisconnectedNtfn(height int32, header string) *FilteredBloc
 	}
 }
 
+// IndexSpentOutput describes an output spent by a block streamed to an
+// external indexer.
+type IndexSpentOutput struct {
+	Amount     int64  `json:"amount"`
+	PkScript   string `json:"pkscript"`
+	Height     int32  `json:"height"`
+	IsCoinBase bool   `json:"coinbase"`
+}
+
+// IndexBlockConnectedNtfn defines the indexblockconnected JSON-RPC
+// notification.
+type IndexBlockConnectedNtfn struct {
+	Height       int32
+	Block        string
+	SpentOutputs []IndexSpentOutput
+}
+
+// NewIndexBlockConnectedNtfn returns a new instance which can be used to issue
+// an indexblockconnected JSON-RPC notification.
+func NewIndexBlockConnectedNtfn(height int32, block string, spentOutputs []IndexSpentOutput) *IndexBlockConnectedNtfn {
+	return &IndexBlockConnectedNtfn{
+		Height:       height,
+		Block:        block,
+		SpentOutputs: spentOutputs,
+	}
+}
+
+// IndexBlockDisconnectedNtfn defines the indexblockdisconnected JSON-RPC
+// notification.
+type IndexBlockDisconnectedNtfn struct {
+	Height       int32
+	Block        string
+	SpentOutputs []IndexSpentOutput
+}
+
+// NewIndexBlockDisconnectedNtfn returns a new instance which can be used to
+// issue an indexblockdisconnected JSON-RPC notification.
+func NewIndexBlockDisconnectedNtfn(height int32, block string, spentOutputs []IndexSpentOutput) *IndexBlockDisconnectedNtfn {
+	return &IndexBlockDisconnectedNtfn{
+		Height:       height,
+		Block:        block,
+		SpentOutputs: spentOutputs,
+	}
+}
+
 // BlockDetails describes details of a tx in a block.
 type BlockDetails struct {
 	Height int32  `json:"height"`
@@ -294,6 +349,8 @@ func init() {
 	MustRegisterCmd(BlockDisconnectedNtfnMethod, (*BlockDisconnectedNtfn)(nil), flags)
 	MustRegisterCmd(FilteredBlockConnectedNtfnMethod, (*FilteredBlockConnectedNtfn)(nil), flags)
 	MustRegisterCmd(FilteredBlockDisconnectedNtfnMethod, (*FilteredBlockDisconnectedNtfn)(nil), flags)
+	MustRegisterCmd(IndexBlockConnectedNtfnMethod, (*IndexBlockConnectedNtfn)(nil), flags)
+	MustRegisterCmd(IndexBlockDisconnectedNtfnMethod, (*IndexBlockDisconnectedNtfn)(nil), flags)
 	MustRegisterCmd(RecvTxNtfnMethod, (*RecvTxNtfn)(nil), flags)
 	MustRegisterCmd(RedeemingTxNtfnMethod, (*RedeemingTxNtfn)(nil), flags)
 	MustRegisterCmd(RescanFinishedNtfnMethod, (*RescanFinishedNtfn)(nil), flags)
@@ -88,6 +88,41 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				Header: "header",
 			},
 		},
+		{
+			name: "indexblockconnected",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("indexblockconnected", 100000, "block", `[{"amount":5000,"pkscript":"51","height":99000,"coinbase":true}]`)
+			},
+			staticNtfn: func() interface{} {
+				spentOutputs := []btcjson.IndexSpentOutput{
+					{Amount: 5000, PkScript: "51", Height: 99000, IsCoinBase: true},
+				}
+				return btcjson.NewIndexBlockConnectedNtfn(100000, "block", spentOutputs)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"indexblockconnected","params":[100000,"block",[{"amount":5000,"pkscript":"51","height":99000,"coinbase":true}]],"id":null}`,
+			unmarshalled: &btcjson.IndexBlockConnectedNtfn{
+				Height: 100000,
+				Block:  "block",
+				SpentOutputs: []btcjson.IndexSpentOutput{
+					{Amount: 5000, PkScript: "51", Height: 99000, IsCoinBase: true},
+				},
+			},
+		},
+		{
+			name: "indexblockdisconnected",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("indexblockdisconnected", 100000, "block", `[]`)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewIndexBlockDisconnectedNtfn(100000, "block", []btcjson.IndexSpentOutput{})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"indexblockdisconnected","params":[100000,"block",[]],"id":null}`,
+			unmarshalled: &btcjson.IndexBlockDisconnectedNtfn{
+				Height:       100000,
+				Block:        "block",
+				SpentOutputs: []btcjson.IndexSpentOutput{},
+			},
+		},
 		{
 			name: "recvtx",
 			newNtfn: func() (interface{}, error) {
@@ -173,6 +173,9 @@ const (
 	ErrRPCOutOfRange        RPCErrorCode = -1
 	ErrRPCNoTxInfo          RPCErrorCode = -5
 	ErrRPCNoCFIndex         RPCErrorCode = -5
+	ErrRPCNoCoinStatsIndex  RPCErrorCode = -5
+	ErrRPCNoTxoSpenderIndex RPCErrorCode = -5
+	ErrRPCIndexSyncing      RPCErrorCode = -5
 	ErrRPCNoNewestBlockInfo RPCErrorCode = -5
 	ErrRPCInvalidTxVout     RPCErrorCode = -5
 	ErrRPCRawTxString       RPCErrorCode = -32602
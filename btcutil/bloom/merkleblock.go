@@ -5,12 +5,20 @@
 package bloom
 
 import (
+	"errors"
+
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcd/btcutil"
 )
 
+// maxMerkleBlockTxns is the maximum number of transactions a merkle block can
+// commit to.  It is based on the maximum block weight and the weight of the
+// smallest possible transaction.
+const maxMerkleBlockTxns = blockchain.MaxBlockWeight /
+	(blockchain.WitnessScaleFactor * 60)
+
 // merkleBlock is used to house intermediate information needed to generate a
 // wire.MsgMerkleBlock according to a filter.
 type merkleBlock struct {
@@ -80,6 +88,30 @@ func (m *merkleBlock) traverseAndBuild(height, pos uint32) {
 // NewMerkleBlock returns a new *wire.MsgMerkleBlock and an array of the matched
 // transaction index numbers based on the passed block and filter.
 func NewMerkleBlock(block *btcutil.Block, filter *Filter) (*wire.MsgMerkleBlock, []uint32) {
+	return newMerkleBlock(block, filter.MatchTxAndUpdate)
+}
+
+// NewMerkleBlockWithTxHashes returns a new *wire.MsgMerkleBlock that proves
+// the inclusion of the transactions with the passed hashes in the passed block
+// and an array of their index numbers.  Hashes of transactions that are not
+// part of the block are ignored.
+func NewMerkleBlockWithTxHashes(block *btcutil.Block,
+	txHashes []*chainhash.Hash) (*wire.MsgMerkleBlock, []uint32) {
+
+	txHashSet := make(map[chainhash.Hash]struct{}, len(txHashes))
+	for _, txHash := range txHashes {
+		txHashSet[*txHash] = struct{}{}
+	}
+	return newMerkleBlock(block, func(tx *btcutil.Tx) bool {
+		_, ok := txHashSet[*tx.Hash()]
+		return ok
+	})
+}
+
+// newMerkleBlock returns a new *wire.MsgMerkleBlock and an array of the matched
+// transaction index numbers based on the passed block and the function that
+// determines whether or not a transaction matches.
+func newMerkleBlock(block *btcutil.Block, matchTx func(*btcutil.Tx) bool) (*wire.MsgMerkleBlock, []uint32) {
 	numTx := uint32(len(block.Transactions()))
 	mBlock := merkleBlock{
 		numTx:       numTx,
@@ -87,10 +119,10 @@ func NewMerkleBlock(block *btcutil.Block, filter *Filter) (*wire.MsgMerkleBlock,
 		matchedBits: make([]byte, 0, numTx),
 	}
 
-	// Find and keep track of any transactions that match the filter.
+	// Find and keep track of any transactions that match.
 	var matchedIndices []uint32
 	for txIndex, tx := range block.Transactions() {
-		if filter.MatchTxAndUpdate(tx) {
+		if matchTx(tx) {
 			mBlock.matchedBits = append(mBlock.matchedBits, 0x01)
 			matchedIndices = append(matchedIndices, uint32(txIndex))
 		} else {
@@ -123,3 +155,120 @@ func NewMerkleBlock(block *btcutil.Block, filter *Filter) (*wire.MsgMerkleBlock,
 	}
 	return &msgMerkleBlock, matchedIndices
 }
+
+// partialMerkleTree is used to house intermediate information needed to
+// extract the matched transactions from a wire.MsgMerkleBlock.
+type partialMerkleTree struct {
+	merkleBlock
+
+	bitsUsed       int
+	hashesUsed     int
+	matchedHashes  []*chainhash.Hash
+	matchedIndices []uint32
+	err            error
+}
+
+// traverseAndExtract recalculates the hash of a sub-tree given a depth-first
+// height and node position using the flag bits and hashes of the partial
+// merkle tree while it records the matched transactions it encounters.  The
+// err field is set when the partial merkle tree is malformed.
+func (m *partialMerkleTree) traverseAndExtract(height, pos uint32) *chainhash.Hash {
+	if m.bitsUsed >= len(m.bits) {
+		m.err = errors.New("merkle block has too few flag bits")
+		return &chainhash.Hash{}
+	}
+	isParent := m.bits[m.bitsUsed]
+	m.bitsUsed++
+
+	// When the node is a leaf node or not a parent of a matched node, its
+	// hash is the next one of the merkle block.
+	if height == 0 || isParent == 0x00 {
+		if m.hashesUsed >= len(m.finalHashes) {
+			m.err = errors.New("merkle block has too few hashes")
+			return &chainhash.Hash{}
+		}
+		hash := m.finalHashes[m.hashesUsed]
+		m.hashesUsed++
+		if height == 0 && isParent != 0x00 {
+			m.matchedHashes = append(m.matchedHashes, hash)
+			m.matchedIndices = append(m.matchedIndices, pos)
+		}
+		return hash
+	}
+
+	// At this point, the node is an internal node and it is the parent of
+	// an included leaf node, so calculate its hash from its children.
+	left := m.traverseAndExtract(height-1, pos*2)
+	right := left
+	if pos*2+1 < m.calcTreeWidth(height-1) {
+		right = m.traverseAndExtract(height-1, pos*2+1)
+
+		// Identical children would allow the same merkle root to be
+		// produced by a different set of transactions (CVE-2012-2459).
+		if m.err == nil && left.IsEqual(right) {
+			m.err = errors.New("merkle block has identical sibling " +
+				"hashes")
+		}
+	}
+	return blockchain.HashMerkleBranches(left, right)
+}
+
+// ExtractMatches validates the partial merkle tree of the passed merkle block
+// and returns the merkle root it commits to along with the hashes of the
+// matched transactions and their index numbers in the block.
+//
+// NOTE: The returned merkle root must be compared to the one in the header of
+// the merkle block, and the header must be validated against the block chain,
+// before the matched transactions can be considered to be part of a block.
+func ExtractMatches(mBlock *wire.MsgMerkleBlock) (*chainhash.Hash, []*chainhash.Hash, []uint32, error) {
+	// Ensure the number of transactions, hashes and flag bits is sane
+	// before traversing the tree.
+	numTx := mBlock.Transactions
+	switch {
+	case numTx == 0:
+		return nil, nil, nil, errors.New("merkle block has no " +
+			"transactions")
+	case numTx > maxMerkleBlockTxns:
+		return nil, nil, nil, errors.New("merkle block has too many " +
+			"transactions")
+	case uint32(len(mBlock.Hashes)) > numTx:
+		return nil, nil, nil, errors.New("merkle block has more hashes " +
+			"than transactions")
+	case len(mBlock.Flags)*8 < len(mBlock.Hashes):
+		return nil, nil, nil, errors.New("merkle block has fewer flag " +
+			"bits than hashes")
+	}
+
+	tree := partialMerkleTree{
+		merkleBlock: merkleBlock{
+			numTx:       numTx,
+			finalHashes: mBlock.Hashes,
+			bits:        make([]byte, len(mBlock.Flags)*8),
+		},
+	}
+	for i := range tree.bits {
+		tree.bits[i] = (mBlock.Flags[i/8] >> (i % 8)) & 0x01
+	}
+
+	// Calculate the number of merkle branches (height) in the tree.
+	height := uint32(0)
+	for tree.calcTreeWidth(height) > 1 {
+		height++
+	}
+
+	// Traverse the partial merkle tree and ensure all of it was used.
+	root := tree.traverseAndExtract(height, 0)
+	if tree.err != nil {
+		return nil, nil, nil, tree.err
+	}
+	if (tree.bitsUsed+7)/8 != len(mBlock.Flags) {
+		return nil, nil, nil, errors.New("merkle block has unused " +
+			"flag bits")
+	}
+	if tree.hashesUsed != len(mBlock.Hashes) {
+		return nil, nil, nil, errors.New("merkle block has unused " +
+			"hashes")
+	}
+
+	return root, tree.matchedHashes, tree.matchedIndices, nil
+}
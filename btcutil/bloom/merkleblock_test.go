@@ -7,8 +7,10 @@ package bloom_test
 import (
 	"bytes"
 	"encoding/hex"
+	"reflect"
 	"testing"
 
+	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcd/btcutil"
@@ -72,3 +74,136 @@ func TestMerkleBlock3(t *testing.T) {
 		return
 	}
 }
+
+// TestMerkleBlockWithTxHashes ensures merkle blocks created for a set of
+// transaction hashes commit to the merkle root of the block and that the
+// matched transactions can be extracted from them again.
+func TestMerkleBlockWithTxHashes(t *testing.T) {
+	for numTx := 1; numTx <= 9; numTx++ {
+		msgBlock := wire.MsgBlock{}
+		for i := 0; i < numTx; i++ {
+			tx := wire.NewMsgTx(1)
+			tx.LockTime = uint32(i)
+			msgBlock.AddTransaction(tx)
+		}
+		blk := btcutil.NewBlock(&msgBlock)
+		merkles := blockchain.BuildMerkleTreeStore(blk.Transactions(),
+			false)
+		msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
+
+		// Prove every subset of the transactions.
+		for subset := 0; subset < 1<<numTx; subset++ {
+			var txHashes []*chainhash.Hash
+			var wantIndices []uint32
+			for i, tx := range blk.Transactions() {
+				if subset&(1<<i) != 0 {
+					txHashes = append(txHashes, tx.Hash())
+					wantIndices = append(wantIndices, uint32(i))
+				}
+			}
+
+			mBlock, indices := bloom.NewMerkleBlockWithTxHashes(blk,
+				txHashes)
+			if !reflect.DeepEqual(indices, wantIndices) {
+				t.Fatalf("%d txns, subset %b: unexpected indices "+
+					"%v, want %v", numTx, subset, indices,
+					wantIndices)
+			}
+
+			root, matches, indices, err := bloom.ExtractMatches(mBlock)
+			if err != nil {
+				t.Fatalf("%d txns, subset %b: ExtractMatches: %v",
+					numTx, subset, err)
+			}
+			if !root.IsEqual(&msgBlock.Header.MerkleRoot) {
+				t.Fatalf("%d txns, subset %b: unexpected merkle "+
+					"root %v, want %v", numTx, subset, root,
+					msgBlock.Header.MerkleRoot)
+			}
+			if !reflect.DeepEqual(matches, txHashes) ||
+				!reflect.DeepEqual(indices, wantIndices) {
+
+				t.Fatalf("%d txns, subset %b: unexpected matches "+
+					"%v at %v, want %v at %v", numTx, subset,
+					matches, indices, txHashes, wantIndices)
+			}
+		}
+	}
+}
+
+// TestExtractMatchesMalformed ensures malformed partial merkle trees are
+// rejected.
+func TestExtractMatchesMalformed(t *testing.T) {
+	msgBlock := wire.MsgBlock{}
+	for i := 0; i < 5; i++ {
+		tx := wire.NewMsgTx(1)
+		tx.LockTime = uint32(i)
+		msgBlock.AddTransaction(tx)
+	}
+	blk := btcutil.NewBlock(&msgBlock)
+	txHash := blk.Transactions()[3].Hash()
+	valid, _ := bloom.NewMerkleBlockWithTxHashes(blk,
+		[]*chainhash.Hash{txHash})
+
+	tests := []struct {
+		name   string
+		modify func(mBlock *wire.MsgMerkleBlock)
+	}{{
+		name: "no transactions",
+		modify: func(mBlock *wire.MsgMerkleBlock) {
+			mBlock.Transactions = 0
+		},
+	}, {
+		name: "too many transactions",
+		modify: func(mBlock *wire.MsgMerkleBlock) {
+			mBlock.Transactions = 1 << 20
+		},
+	}, {
+		name: "more hashes than transactions",
+		modify: func(mBlock *wire.MsgMerkleBlock) {
+			mBlock.Transactions = 2
+		},
+	}, {
+		name: "missing hash",
+		modify: func(mBlock *wire.MsgMerkleBlock) {
+			mBlock.Hashes = mBlock.Hashes[:len(mBlock.Hashes)-1]
+		},
+	}, {
+		name: "unused hash",
+		modify: func(mBlock *wire.MsgMerkleBlock) {
+			mBlock.Hashes = append(mBlock.Hashes, txHash)
+		},
+	}, {
+		name: "unused flag byte",
+		modify: func(mBlock *wire.MsgMerkleBlock) {
+			mBlock.Flags = append(mBlock.Flags, 0x00)
+		},
+	}, {
+		name: "missing flag bits",
+		modify: func(mBlock *wire.MsgMerkleBlock) {
+			mBlock.Flags = nil
+			mBlock.Hashes = nil
+		},
+	}}
+
+	for _, test := range tests {
+		mBlock := *valid
+		mBlock.Hashes = append([]*chainhash.Hash(nil), valid.Hashes...)
+		mBlock.Flags = append([]byte(nil), valid.Flags...)
+		test.modify(&mBlock)
+		if _, _, _, err := bloom.ExtractMatches(&mBlock); err == nil {
+			t.Errorf("%s: no error for malformed merkle block",
+				test.name)
+		}
+	}
+
+	// Duplicating the last transaction of a level produces the same
+	// merkle root, so identical siblings must be rejected.
+	msgBlock.AddTransaction(msgBlock.Transactions[4])
+	blk = btcutil.NewBlock(&msgBlock)
+	mBlock, _ := bloom.NewMerkleBlockWithTxHashes(blk,
+		[]*chainhash.Hash{blk.Transactions()[5].Hash()})
+	if _, _, _, err := bloom.ExtractMatches(mBlock); err == nil {
+		t.Error("no error for merkle block with identical siblings")
+	}
+}
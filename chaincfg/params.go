@@ -77,6 +77,28 @@ type Checkpoint struct {
 	Hash   *chainhash.Hash
 }
 
+// AssumeUtxo identifies the utxo set as of a known good block in the block
+// chain.  A utxo set snapshot for the block is only loaded when its hash matches
+// the one committed to here.  This allows a node to start validating new blocks
+// on top of the snapshot right away while the blocks before it are validated
+// in the background.
+type AssumeUtxo struct {
+	// Height is the height of the block the snapshot was taken at.
+	Height int32
+
+	// Hash is the hash of the block the snapshot was taken at.
+	Hash *chainhash.Hash
+
+	// UtxoSetHash is the hash of the serialized utxo set as of the block.
+	// It is calculated the same way as the hash_serialized_3 value
+	// reported by Bitcoin Core.
+	UtxoSetHash *chainhash.Hash
+
+	// ChainTxCount is the total number of transactions in the chain up to
+	// and including the block.
+	ChainTxCount uint64
+}
+
 // DNSSeed identifies a DNS seed.
 type DNSSeed struct {
 	// Host defines the hostname of the seed.
@@ -230,6 +252,16 @@ type Params struct {
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints []Checkpoint
 
+	// AssumeUtxo holds the utxo set snapshots that may be loaded ordered
+	// from oldest to newest.
+	AssumeUtxo []AssumeUtxo
+
+	// AssumeValid is the hash of a block whose ancestors are assumed to
+	// have valid scripts, so script validation is skipped for them while
+	// all other validation is still performed.  It is nil when the scripts
+	// of all blocks must be validated.
+	AssumeValid *chainhash.Hash
+
 	// These fields are related to voting on consensus rule changes as
 	// defined by BIP0009.
 	//
@@ -337,6 +369,27 @@ var MainNetParams = Params{
 		{751565, newHashFromStr("00000000000000000009c97098b5295f7e5f183ac811fb5d1534040adb93cabd")},
 	},
 
+	// The scripts of all blocks up to the most recent checkpoint are
+	// assumed to be valid.
+	AssumeValid: newHashFromStr("00000000000000000009c97098b5295f7e5f183ac811fb5d1534040adb93cabd"),
+
+	// Utxo set snapshots that may be loaded, which are the same ones
+	// Bitcoin Core allows.
+	AssumeUtxo: []AssumeUtxo{
+		{
+			Height:       840000,
+			Hash:         newHashFromStr("0000000000000000000320283a032748cef8227873ff4872689bf23f1cda83a5"),
+			UtxoSetHash:  newHashFromStr("a2a5521b1b5ab65f67818e5e8eccabb7171a517f9e2382208f77687310768f96"),
+			ChainTxCount: 991032194,
+		},
+		{
+			Height:       880000,
+			Hash:         newHashFromStr("000000000000000000010b17283c3c400507969a9c2afd1dcf2082ec5cca2880"),
+			UtxoSetHash:  newHashFromStr("dbd190983eaf433ef7c15f78a278ae42c00ef52e0fd2a54953782175fbadcea9"),
+			ChainTxCount: 1145604538,
+		},
+	},
+
 	// Consensus rule change deployments.
 	//
 	// The miner confirmation window is defined as:
@@ -581,6 +634,10 @@ var TestNet3Params = Params{
 		{2344474, newHashFromStr("0000000000000004877fa2d36316398528de4f347df2f8a96f76613a298ce060")},
 	},
 
+	// The scripts of all blocks up to the most recent checkpoint are
+	// assumed to be valid.
+	AssumeValid: newHashFromStr("0000000000000004877fa2d36316398528de4f347df2f8a96f76613a298ce060"),
+
 	// Consensus rule change deployments.
 	//
 	// The miner confirmation window is defined as:
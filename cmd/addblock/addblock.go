@@ -99,6 +99,12 @@ func realMain() error {
 		return err
 	}
 
+	// Stop catching up the indexes in the background before the database
+	// is closed.  The remaining blocks are indexed on the next start.
+	if importer.indexManager != nil {
+		defer importer.indexManager.Stop()
+	}
+
 	// Perform the import asynchronously.  This allows blocks to be
 	// processed and read in parallel.  The results channel returned from
 	// Import contains the statistics about the import including an error
@@ -33,6 +33,7 @@ type importResults struct {
 type blockImporter struct {
 	db                database.DB
 	chain             *blockchain.BlockChain
+	indexManager      *indexers.Manager
 	r                 io.ReadSeeker
 	processQueue      chan []byte
 	doneChan          chan bool
@@ -326,8 +327,10 @@ func newBlockImporter(db database.DB, r io.ReadSeeker) (*blockImporter, error) {
 
 	// Create an index manager if any of the optional indexes are enabled.
 	var indexManager blockchain.IndexManager
+	var manager *indexers.Manager
 	if len(indexes) > 0 {
-		indexManager = indexers.NewManager(db, indexes)
+		manager = indexers.NewManager(db, indexes)
+		indexManager = manager
 	}
 
 	chain, err := blockchain.New(&blockchain.Config{
@@ -348,6 +351,7 @@ func newBlockImporter(db database.DB, r io.ReadSeeker) (*blockImporter, error) {
 		errChan:      make(chan error),
 		quit:         make(chan struct{}),
 		chain:        chain,
+		indexManager: manager,
 		lastLogTime:  time.Now(),
 	}, nil
 }
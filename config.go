@@ -48,6 +48,9 @@ const (
 	defaultMaxRPCClients         = 10
 	defaultMaxRPCWebsockets      = 25
 	defaultMaxRPCConcurrentReqs  = 20
+	defaultMaxElectrumClients    = 100
+	defaultElectrumPort          = "50001"
+	defaultElectrumTLSPort       = "50002"
 	defaultDbType                = "ffldb"
 	defaultFreeTxRelayLimit      = 15.0
 	defaultTrickleInterval       = peer.DefaultTrickleInterval
@@ -63,6 +66,8 @@ const (
 	defaultMaxOrphanTransactions = 100
 	defaultMaxOrphanTxSize       = 100000
 	defaultSigCacheMaxSize       = 100000
+	defaultUtxoCacheMaxSizeMiB   = 250
+	pruneMinSizeMiB              = 1536
 	sampleConfigFilename         = "sample-btcd.conf"
 	defaultTxIndex               = false
 	defaultAddrIndex             = false
@@ -100,6 +105,8 @@ type config struct {
 	AddrIndex            bool          `long:"addrindex" description:"Maintain a full address-based transaction index which makes the searchrawtransactions RPC available"`
 	AgentBlacklist       []string      `long:"agentblacklist" description:"A comma separated list of user-agent substrings which will cause btcd to reject any peers whose user-agent contains any of the blacklisted substrings."`
 	AgentWhitelist       []string      `long:"agentwhitelist" description:"A comma separated list of user-agent substrings which will cause btcd to require all peers' user-agents to contain one of the whitelisted substrings. The blacklist is applied before the blacklist, and an empty whitelist will allow all agents that do not fail the blacklist."`
+	AssumeValid          string        `long:"assumevalid" description:"Skip script validation for the ancestors of the block with this hash -- Use 0 to validate all scripts (default: built-in value for the active network)"`
+	BalanceIndex         bool          `long:"balanceindex" description:"Maintain an index of the outputs paying to every script along with the transactions that spend them which makes the getaddressbalance and getaddressutxos RPCs available"`
 	BanDuration          time.Duration `long:"banduration" description:"How long to ban misbehaving peers.  Valid time units are {s, m, h}.  Minimum 1 second"`
 	BanThreshold         uint32        `long:"banthreshold" description:"Maximum allowed ban score before disconnecting and banning misbehaving peers."`
 	BlockMaxSize         uint32        `long:"blockmaxsize" description:"Maximum block size in bytes to be used when creating a block"`
@@ -108,6 +115,7 @@ type config struct {
 	BlockMinWeight       uint32        `long:"blockminweight" description:"Mininum block weight to be used when creating a block"`
 	BlockPrioritySize    uint32        `long:"blockprioritysize" description:"Size in bytes for high-priority/low-fee transactions when creating a block"`
 	BlocksOnly           bool          `long:"blocksonly" description:"Do not accept transactions from remote peers."`
+	CoinStatsIndex       bool          `long:"coinstatsindex" description:"Maintain an index of the utxo set statistics and its MuHash for every block which makes the gettxoutsetinfo RPC available"`
 	ConfigFile           string        `short:"C" long:"configfile" description:"Path to configuration file"`
 	ConnectPeers         []string      `long:"connect" description:"Connect only to the specified peers at startup"`
 	CPUProfile           string        `long:"cpuprofile" description:"Write CPU profile to the specified file"`
@@ -116,13 +124,26 @@ type config struct {
 	DbType               string        `long:"dbtype" description:"Database backend to use for the Block Chain"`
 	DebugLevel           string        `short:"d" long:"debuglevel" description:"Logging level for all subsystems {trace, debug, info, warn, error, critical} -- You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems -- Use show to list available subsystems"`
 	DropAddrIndex        bool          `long:"dropaddrindex" description:"Deletes the address-based transaction index from the database on start up and then exits."`
+	DropBalanceIndex     bool          `long:"dropbalanceindex" description:"Deletes the balance index from the database on start up and then exits."`
 	DropCfIndex          bool          `long:"dropcfindex" description:"Deletes the index used for committed filtering (CF) support from the database on start up and then exits."`
+	DropCoinStatsIndex   bool          `long:"dropcoinstatsindex" description:"Deletes the utxo set statistics index from the database on start up and then exits."`
+	DropScriptHashIndex  bool          `long:"dropscripthashindex" description:"Deletes the script hash index from the database on start up and then exits."`
+	DropSpendJournal     bool          `long:"dropspendjournalindex" description:"Deletes the spend journal index from the database on start up and then exits."`
 	DropTxIndex          bool          `long:"droptxindex" description:"Deletes the hash-based transaction index from the database on start up and then exits."`
+	DropTxoSpenderIndex  bool          `long:"droptxospenderindex" description:"Deletes the transaction output spender index from the database on start up and then exits."`
+	ElectrumListeners    []string      `long:"electrumlisten" description:"Add an interface/port to listen for Electrum protocol connections over TCP (default port: 50001) -- Enables the script hash index"`
+	ElectrumMaxClients   int           `long:"electrummaxclients" description:"Max number of Electrum protocol clients"`
+	ElectrumTLSListeners []string      `long:"electrumtlslisten" description:"Add an interface/port to listen for Electrum protocol connections over TLS using the RPC certificate and key (default port: 50002) -- Enables the script hash index"`
 	ExternalIPs          []string      `long:"externalip" description:"Add an ip to the list of local addresses we claim to listen on to peers"`
 	Generate             bool          `long:"generate" description:"Generate (mine) bitcoins using the CPU"`
+	LimitAncestorCount   int           `long:"limitancestorcount" description:"Do not accept transactions that would have more than this many unconfirmed ancestors in the mempool, including themselves"`
+	LimitAncestorSize    int           `long:"limitancestorsize" description:"Do not accept transactions whose virtual size along with their unconfirmed ancestors in the mempool would exceed this many kilobytes"`
+	LimitDescendantCount int           `long:"limitdescendantcount" description:"Do not accept transactions that would give a transaction in the mempool more than this many descendants, including itself"`
+	LimitDescendantSize  int           `long:"limitdescendantsize" description:"Do not accept transactions that would make the virtual size of a transaction in the mempool along with its descendants exceed this many kilobytes"`
 	FreeTxRelayLimit     float64       `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
 	Listeners            []string      `long:"listen" description:"Add an interface/port to listen for connections (default all interfaces port: 8333, testnet: 18333)"`
 	LogDir               string        `long:"logdir" description:"Directory to log output."`
+	MaxMempool           int           `long:"maxmempool" description:"Keep the transaction memory pool below this many megabytes by evicting the transactions paying the lowest fee rates"`
 	MaxOrphanTxs         int           `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
 	MaxPeers             int           `long:"maxpeers" description:"Max number of inbound and outbound peers"`
 	MiningAddrs          []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
@@ -134,6 +155,7 @@ type config struct {
 	DisableListen        bool          `long:"nolisten" description:"Disable listening for incoming connections -- NOTE: Listening is automatically disabled if the --connect or --proxy options are used without also specifying listen interfaces via --listen"`
 	NoOnion              bool          `long:"noonion" description:"Disable connecting to tor hidden services"`
 	NoPeerBloomFilters   bool          `long:"nopeerbloomfilters" description:"Disable bloom filtering support"`
+	NoPersistMempool     bool          `long:"nopersistmempool" description:"Do not save the mempool on shutdown and load it again on startup"`
 	NoRelayPriority      bool          `long:"norelaypriority" description:"Do not require free or low-fee transactions to have high priority for relaying"`
 	NoWinService         bool          `long:"nowinservice" description:"Do not start as a background service on Windows -- NOTE: This flag only works on the command line, not in the config file"`
 	DisableRPC           bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass or rpclimituser/rpclimitpass is specified"`
@@ -146,6 +168,7 @@ type config struct {
 	Proxy                string        `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	ProxyPass            string        `long:"proxypass" default-mask:"-" description:"Password for proxy server"`
 	ProxyUser            string        `long:"proxyuser" description:"Username for proxy server"`
+	Prune                uint64        `long:"prune" description:"Delete old blocks to keep the stored blocks under the specified size in MiB -- Must be at least 1536 MiB and is not compatible with --txindex or --addrindex"`
 	RegressionTest       bool          `long:"regtest" description:"Use the regression test network"`
 	RejectNonStd         bool          `long:"rejectnonstd" description:"Reject non-standard transactions regardless of the default settings for the active network."`
 	RejectReplacement    bool          `long:"rejectreplacement" description:"Reject transactions that attempt to replace existing transactions within the mempool through the Replace-By-Fee (RBF) signaling policy."`
@@ -161,6 +184,8 @@ type config struct {
 	RPCQuirks            bool          `long:"rpcquirks" description:"Mirror some JSON-RPC quirks of Bitcoin Core -- NOTE: Discouraged unless interoperability issues need to be worked around"`
 	RPCPass              string        `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
 	RPCUser              string        `short:"u" long:"rpcuser" description:"Username for RPC connections"`
+	ScriptHashIndex      bool          `long:"scripthashindex" description:"Maintain an index of the history and unspent outputs of every script by its hash which is used by the Electrum protocol server"`
+	SpendJournalIndex    bool          `long:"spendjournalindex" description:"Retain the outputs spent by blocks that are disconnected from the main chain for 2016 blocks which is required to stream block events to external indexers via the notifyindexevents websocket command"`
 	SigCacheMaxSize      uint          `long:"sigcachemaxsize" description:"The maximum number of entries in the signature verification cache"`
 	SimNet               bool          `long:"simnet" description:"Use the simulation test network"`
 	SigNet               bool          `long:"signet" description:"Use the signet test network"`
@@ -170,14 +195,18 @@ type config struct {
 	TorIsolation         bool          `long:"torisolation" description:"Enable Tor stream isolation by randomizing user credentials for each connection."`
 	TrickleInterval      time.Duration `long:"trickleinterval" description:"Minimum time between attempts to send new inventory to a connected peer"`
 	TxIndex              bool          `long:"txindex" description:"Maintain a full hash-based transaction index which makes all transactions available via the getrawtransaction RPC"`
+	TxoSpenderIndex      bool          `long:"txospenderindex" description:"Maintain an index of the transaction that spends every output in the main chain which makes confirmed spends available via the gettxspendingprevout RPC"`
 	UserAgentComments    []string      `long:"uacomment" description:"Comment to add to the user agent -- See BIP 14 for more information."`
+	UtxoCacheMaxSizeMiB  uint          `long:"utxocachemaxsize" description:"The maximum size in MiB of the UTXO cache"`
 	Upnp                 bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
+	VerifyCfIndex        bool          `long:"verifycfindex" description:"Recompute the committed filter header chain from the stored filters on start up and report the first block that does not match"`
 	ShowVersion          bool          `short:"V" long:"version" description:"Display version information and exit"`
 	Whitelists           []string      `long:"whitelist" description:"Add an IP network or IP that will not be banned. (eg. 192.168.1.0/24 or ::1)"`
 	lookup               func(string) ([]net.IP, error)
 	oniondial            func(string, string, time.Duration) (net.Conn, error)
 	dial                 func(string, string, time.Duration) (net.Conn, error)
 	addCheckpoints       []chaincfg.Checkpoint
+	assumeValid          *chainhash.Hash
 	miningAddrs          []btcutil.Address
 	minRelayTxFee        btcutil.Amount
 	whitelists           []*net.IPNet
@@ -420,6 +449,7 @@ func loadConfig() (*config, []string, error) {
 		BanDuration:          defaultBanDuration,
 		BanThreshold:         defaultBanThreshold,
 		RPCMaxClients:        defaultMaxRPCClients,
+		ElectrumMaxClients:   defaultMaxElectrumClients,
 		RPCMaxWebsockets:     defaultMaxRPCWebsockets,
 		RPCMaxConcurrentReqs: defaultMaxRPCConcurrentReqs,
 		DataDir:              defaultDataDir,
@@ -435,8 +465,14 @@ func loadConfig() (*config, []string, error) {
 		BlockMinWeight:       defaultBlockMinWeight,
 		BlockMaxWeight:       defaultBlockMaxWeight,
 		BlockPrioritySize:    mempool.DefaultBlockPrioritySize,
+		MaxMempool:           mempool.DefaultMaxPoolSize / 1000000,
 		MaxOrphanTxs:         defaultMaxOrphanTransactions,
+		LimitAncestorCount:   mempool.DefaultMaxAncestorCount,
+		LimitAncestorSize:    mempool.DefaultMaxAncestorSize / 1000,
+		LimitDescendantCount: mempool.DefaultMaxDescendantCount,
+		LimitDescendantSize:  mempool.DefaultMaxDescendantSize / 1000,
 		SigCacheMaxSize:      defaultSigCacheMaxSize,
+		UtxoCacheMaxSizeMiB:  defaultUtxoCacheMaxSizeMiB,
 		Generate:             defaultGenerate,
 		TxIndex:              defaultTxIndex,
 		AddrIndex:            defaultAddrIndex,
@@ -859,6 +895,36 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// The ancestor and descendant limits must allow at least a single
+	// transaction.
+	if cfg.LimitAncestorCount < 1 || cfg.LimitAncestorSize < 1 ||
+		cfg.LimitDescendantCount < 1 || cfg.LimitDescendantSize < 1 {
+
+		str := "%s: The limitancestorcount, limitancestorsize, " +
+			"limitdescendantcount and limitdescendantsize options " +
+			"must be greater than 0 -- parsed [%d, %d, %d, %d]"
+		err := fmt.Errorf(str, funcName, cfg.LimitAncestorCount,
+			cfg.LimitAncestorSize, cfg.LimitDescendantCount,
+			cfg.LimitDescendantSize)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The mempool must be able to hold a transaction along with as many
+	// descendants as allowed.  The estimated memory usage of transactions
+	// exceeds their virtual size, so this conservatively requires at least
+	// 40 times the descendant size limit.
+	minMaxMempool := (cfg.LimitDescendantSize*40 + 999) / 1000
+	if cfg.MaxMempool < minMaxMempool {
+		str := "%s: The maxmempool option may not be less than %d " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, minMaxMempool, cfg.MaxMempool)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Limit the block priority and minimum block sizes to max block size.
 	cfg.BlockPrioritySize = minUint32(cfg.BlockPrioritySize, cfg.BlockMaxSize)
 	cfg.BlockMinSize = minUint32(cfg.BlockMinSize, cfg.BlockMaxSize)
@@ -925,6 +991,111 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// --txospenderindex and --droptxospenderindex do not mix.
+	if cfg.TxoSpenderIndex && cfg.DropTxoSpenderIndex {
+		err := fmt.Errorf("%s: the --txospenderindex and "+
+			"--droptxospenderindex options may not be activated at "+
+			"the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --balanceindex and --dropbalanceindex do not mix.
+	if cfg.BalanceIndex && cfg.DropBalanceIndex {
+		err := fmt.Errorf("%s: the --balanceindex and "+
+			"--dropbalanceindex options may not be activated at "+
+			"the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --scripthashindex and --dropscripthashindex do not mix.
+	if cfg.ScriptHashIndex && cfg.DropScriptHashIndex {
+		err := fmt.Errorf("%s: the --scripthashindex and "+
+			"--dropscripthashindex options may not be activated at "+
+			"the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --spendjournalindex and --dropspendjournalindex do not mix.
+	if cfg.SpendJournalIndex && cfg.DropSpendJournal {
+		err := fmt.Errorf("%s: the --spendjournalindex and "+
+			"--dropspendjournalindex options may not be activated at "+
+			"the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The Electrum server relies on the script hash index, so its
+	// listeners and --dropscripthashindex do not mix.
+	electrumEnabled := len(cfg.ElectrumListeners) > 0 ||
+		len(cfg.ElectrumTLSListeners) > 0
+	if electrumEnabled && cfg.DropScriptHashIndex {
+		err := fmt.Errorf("%s: the --electrumlisten, "+
+			"--electrumtlslisten and --dropscripthashindex options "+
+			"may not be activated at the same time because the "+
+			"Electrum server relies on the script hash index",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --verifycfindex and --nocfilters do not mix.
+	if cfg.VerifyCfIndex && cfg.NoCFilters {
+		err := fmt.Errorf("%s: the --verifycfindex and --nocfilters "+
+			"options may not be activated at the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --coinstatsindex and --dropcoinstatsindex do not mix.
+	if cfg.CoinStatsIndex && cfg.DropCoinStatsIndex {
+		err := fmt.Errorf("%s: the --coinstatsindex and "+
+			"--dropcoinstatsindex options may not be activated at "+
+			"the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --prune must be large enough to hold more than the most recent
+	// blocks that are always kept.
+	if cfg.Prune != 0 && cfg.Prune < pruneMinSizeMiB {
+		err := fmt.Errorf("%s: the --prune option must be at least "+
+			"%d MiB -- parsed [%d]", funcName, pruneMinSizeMiB,
+			cfg.Prune)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --prune and --txindex do not mix.
+	if cfg.Prune != 0 && cfg.TxIndex {
+		err := fmt.Errorf("%s: the --prune and --txindex options may "+
+			"not be activated at the same time because the "+
+			"transaction index requires all blocks", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --prune and --addrindex do not mix.
+	if cfg.Prune != 0 && cfg.AddrIndex {
+		err := fmt.Errorf("%s: the --prune and --addrindex options may "+
+			"not be activated at the same time because the "+
+			"address index requires all blocks", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Check mining addresses are valid and saved parsed versions.
 	cfg.miningAddrs = make([]btcutil.Address, 0, len(cfg.MiningAddrs))
 	for _, strAddr := range cfg.MiningAddrs {
@@ -967,6 +1138,13 @@ func loadConfig() (*config, []string, error) {
 	cfg.RPCListeners = normalizeAddresses(cfg.RPCListeners,
 		activeNetParams.rpcPort)
 
+	// Add default ports to all Electrum listener addresses if needed and
+	// remove duplicate addresses.
+	cfg.ElectrumListeners = normalizeAddresses(cfg.ElectrumListeners,
+		defaultElectrumPort)
+	cfg.ElectrumTLSListeners = normalizeAddresses(cfg.ElectrumTLSListeners,
+		defaultElectrumTLSPort)
+
 	// Only allow TLS to be disabled if the RPC is bound to localhost
 	// addresses.
 	if !cfg.DisableRPC && cfg.DisableTLS {
@@ -1023,6 +1201,24 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Parse the assumed valid block.  The default for the active network is
+	// used when none is specified and 0 disables it.
+	cfg.assumeValid = activeNetParams.AssumeValid
+	if cfg.AssumeValid != "" {
+		cfg.assumeValid = nil
+		if cfg.AssumeValid != "0" {
+			hash, err := chainhash.NewHashFromStr(cfg.AssumeValid)
+			if err != nil {
+				str := "%s: Error parsing assumevalid hash: %v"
+				err := fmt.Errorf(str, funcName, err)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
+			}
+			cfg.assumeValid = hash
+		}
+	}
+
 	// Tor stream isolation requires either proxy or onion proxy to be set.
 	if cfg.TorIsolation && cfg.Proxy == "" && cfg.OnionProxy == "" {
 		str := "%s: Tor stream isolation requires either proxy or " +
@@ -0,0 +1,199 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/blockchain/indexers"
+	"github.com/btcsuite/btcd/database"
+)
+
+// checkIndexCmd defines the configuration options for the checkindex command.
+type checkIndexCmd struct {
+	Sample int32 `long:"sample" description:"Only check the given number of randomly selected blocks instead of every block"`
+}
+
+var (
+	// checkIndexCfg defines the configuration options for the command.
+	checkIndexCfg = checkIndexCmd{
+		Sample: 0,
+	}
+)
+
+// heightsToCheck returns the heights of the blocks between the passed start and
+// end heights, inclusive, that are to be checked in ascending order.  All of
+// them are returned when the number of blocks to sample is zero or exceeds the
+// number of blocks.
+func heightsToCheck(startHeight, endHeight, sample int32) []int32 {
+	numBlocks := endHeight - startHeight + 1
+	if sample == 0 || sample >= numBlocks {
+		heights := make([]int32, 0, numBlocks)
+		for height := startHeight; height <= endHeight; height++ {
+			heights = append(heights, height)
+		}
+		return heights
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	selected := make(map[int32]struct{}, sample)
+	heights := make([]int32, 0, sample)
+	for int32(len(heights)) < sample {
+		height := startHeight + rng.Int31n(numBlocks)
+		if _, ok := selected[height]; ok {
+			continue
+		}
+		selected[height] = struct{}{}
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool {
+		return heights[i] < heights[j]
+	})
+	return heights
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *checkIndexCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	// Ensure expected arguments.
+	name, err := parseIndexName(args)
+	if err != nil {
+		return err
+	}
+	if cmd.Sample < 0 {
+		return errors.New("the number of blocks to sample may not be " +
+			"negative")
+	}
+
+	// Load the block database.
+	db, err := loadBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Only indexes that know how to verify their entries can be checked.
+	indexer := newIndexer(db, name)
+	checker, ok := indexer.(indexers.Checker)
+	if !ok {
+		return fmt.Errorf("checking the %s is not supported",
+			indexer.Name())
+	}
+	needsInputs := false
+	if nis, ok := indexer.(indexers.NeedsInputser); ok {
+		needsInputs = nis.NeedsInputs()
+	}
+
+	tipHash, tipHeight, err := indexers.IndexTip(db, indexer)
+	if err != nil {
+		return err
+	}
+	if tipHash == nil {
+		return fmt.Errorf("the %s does not exist", indexer.Name())
+	}
+
+	interrupt := interruptListener()
+	chain, err := loadChain(db, interrupt)
+	if err != nil {
+		return err
+	}
+
+	// The entries of an index whose tip is no longer in the main chain
+	// can't be checked against it.
+	if tipHeight == -1 {
+		log.Infof("The %s does not have any entries yet", indexer.Name())
+		return nil
+	}
+	if !chain.MainChainHasBlock(tipHash) {
+		return fmt.Errorf("the tip of the %s (hash %v, height %d) is "+
+			"not in the main chain -- start btcd with the index "+
+			"enabled to roll it back or rebuild it", indexer.Name(),
+			tipHash, tipHeight)
+	}
+
+	// Only the blocks that have not been pruned can be checked.
+	startHeight := int32(0)
+	if pruneHeight, pruned := chain.PruneHeight(); pruned {
+		startHeight = pruneHeight
+	}
+	if startHeight > tipHeight {
+		log.Infof("All blocks connected to the %s have been pruned",
+			indexer.Name())
+		return nil
+	}
+	heights := heightsToCheck(startHeight, tipHeight, cmd.Sample)
+
+	log.Infof("Checking %d blocks of the %s between heights %d and %d",
+		len(heights), indexer.Name(), startHeight, tipHeight)
+	var numChecked, numDiscrepancies int
+	lastLogTime := time.Now()
+	for _, height := range heights {
+		if interruptRequested(interrupt) {
+			return fmt.Errorf("interrupted after checking %d blocks "+
+				"with %d discrepancies", numChecked,
+				numDiscrepancies)
+		}
+
+		// Load the block along with the outputs it spends when the
+		// index needs them.
+		block, err := chain.BlockByHeight(height)
+		if err != nil {
+			return err
+		}
+		var stxos []blockchain.SpentTxOut
+		if needsInputs {
+			stxos, err = chain.FetchSpendJournal(block)
+			if err != nil {
+				return err
+			}
+		}
+
+		var discrepancies []string
+		err = db.View(func(dbTx database.Tx) error {
+			var err error
+			discrepancies, err = checker.CheckBlock(dbTx, block,
+				stxos)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, discrepancy := range discrepancies {
+			log.Warnf("Block %v (height %d): %s", block.Hash(),
+				height, discrepancy)
+		}
+		numDiscrepancies += len(discrepancies)
+		numChecked++
+
+		if time.Since(lastLogTime) >= 10*time.Second {
+			log.Infof("Checked %d of %d blocks (height %d)",
+				numChecked, len(heights), height)
+			lastLogTime = time.Now()
+		}
+	}
+
+	log.Infof("Checked %d blocks of the %s and found %d discrepancies",
+		numChecked, indexer.Name(), numDiscrepancies)
+	if numDiscrepancies > 0 {
+		return fmt.Errorf("the %s is inconsistent with the main chain "+
+			"-- rebuild it with the rebuildindex command",
+			indexer.Name())
+	}
+	return nil
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *checkIndexCmd) Usage() string {
+	return "<index-name>"
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/blockchain/indexers"
+	"github.com/btcsuite/btcd/database"
+)
+
+// indexNames houses the names of the optional indexes the index commands
+// support.  They are the same names btcd uses for the options that enable the
+// indexes.
+var indexNames = []string{
+	"txindex",
+	"addrindex",
+	"cfindex",
+	"coinstatsindex",
+	"txospenderindex",
+	"scripthashindex",
+	"spendjournalindex",
+	"balanceindex",
+}
+
+// parseIndexName returns the name of the index the passed command arguments
+// refer to after ensuring it is supported.
+func parseIndexName(args []string) (string, error) {
+	if len(args) < 1 {
+		return "", errors.New("required index name parameter not " +
+			"specified")
+	}
+
+	for _, name := range indexNames {
+		if args[0] == name {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown index %q -- supported indexes %v",
+		args[0], indexNames)
+}
+
+// newIndexer returns a new instance of the index with the passed name that is
+// backed by the passed database.
+func newIndexer(db database.DB, name string) indexers.Indexer {
+	switch name {
+	case "txindex":
+		return indexers.NewTxIndex(db)
+	case "addrindex":
+		return indexers.NewAddrIndex(db, activeNetParams)
+	case "cfindex":
+		return indexers.NewCfIndex(db, activeNetParams)
+	case "coinstatsindex":
+		return indexers.NewCoinStatsIndex(db)
+	case "txospenderindex":
+		return indexers.NewTxoSpenderIndex(db)
+	case "scripthashindex":
+		return indexers.NewScriptHashIndex(db)
+	case "spendjournalindex":
+		return indexers.NewSpendJournalIndex(db)
+	case "balanceindex":
+		return indexers.NewBalanceIndex(db)
+	}
+
+	panic(fmt.Sprintf("unknown index %q", name))
+}
+
+// dropIndex drops the index with the passed name from the passed database.
+func dropIndex(db database.DB, name string, interrupt <-chan struct{}) error {
+	switch name {
+	case "txindex":
+		return indexers.DropTxIndex(db, interrupt)
+	case "addrindex":
+		return indexers.DropAddrIndex(db, interrupt)
+	case "cfindex":
+		return indexers.DropCfIndex(db, interrupt)
+	case "coinstatsindex":
+		return indexers.DropCoinStatsIndex(db, interrupt)
+	case "txospenderindex":
+		return indexers.DropTxoSpenderIndex(db, interrupt)
+	case "scripthashindex":
+		return indexers.DropScriptHashIndex(db, interrupt)
+	case "spendjournalindex":
+		return indexers.DropSpendJournalIndex(db, interrupt)
+	case "balanceindex":
+		return indexers.DropBalanceIndex(db, interrupt)
+	}
+
+	return fmt.Errorf("unknown index %q", name)
+}
+
+// loadChain returns a block chain instance backed by the passed database which
+// is used to look up the blocks of the main chain and the outputs they spend.
+// No index manager is attached to it since the index commands take care of
+// updating the indexes themselves.
+func loadChain(db database.DB, interrupt <-chan struct{}) (*blockchain.BlockChain, error) {
+	log.Info("Loading block chain")
+	return blockchain.New(&blockchain.Config{
+		DB:          db,
+		Interrupt:   interrupt,
+		ChainParams: activeNetParams,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+}
+
+// interruptListener returns a channel that is closed when a SIGINT (Ctrl+C) is
+// received.
+func interruptListener() <-chan struct{} {
+	interrupt := make(chan struct{})
+	addInterruptHandler(func() {
+		close(interrupt)
+	})
+	return interrupt
+}
+
+// interruptRequested returns true when the passed channel has been closed.
+func interruptRequested(interrupt <-chan struct{}) bool {
+	select {
+	case <-interrupt:
+		return true
+	default:
+	}
+
+	return false
+}
@@ -9,6 +9,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/blockchain/indexers"
 	"github.com/btcsuite/btcd/database"
 	"github.com/btcsuite/btclog"
 	flags "github.com/jessevdk/go-flags"
@@ -66,6 +68,8 @@ func realMain() error {
 	dbLog := backendLogger.Logger("BCDB")
 	dbLog.SetLevel(btclog.LevelDebug)
 	database.UseLogger(dbLog)
+	blockchain.UseLogger(backendLogger.Logger("CHAN"))
+	indexers.UseLogger(backendLogger.Logger("INDX"))
 
 	// Setup the parser options and commands.
 	appName := filepath.Base(os.Args[0])
@@ -88,6 +92,21 @@ func realMain() error {
 	parser.AddCommand("fetchblockregion",
 		"Fetch the specified block region from the database", "",
 		&blockRegionCfg)
+	parser.AddCommand("checkindex",
+		"Verify an optional index against the main chain",
+		"Verify the entries of an optional index for every block, "+
+			"or a random sample of blocks, connected to it "+
+			"against the entries derived from the blocks of the "+
+			"main chain and report any discrepancies.  Supported "+
+			"indexes: "+strings.Join(indexNames, ", "),
+		&checkIndexCfg)
+	parser.AddCommand("rebuildindex",
+		"Drop an optional index and rebuild it from the main chain",
+		"Drop an optional index and rebuild it from the blocks of "+
+			"the main chain.  The address index is rebuilt along "+
+			"with the transaction index when it exists since it "+
+			"depends on it.  Supported indexes: "+
+			strings.Join(indexNames, ", "), &rebuildIndexCfg)
 
 	// Parse command line and invoke the Execute function for the specified
 	// command.
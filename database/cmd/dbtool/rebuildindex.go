@@ -0,0 +1,118 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/blockchain/indexers"
+)
+
+// rebuildIndexCmd defines the configuration options for the rebuildindex
+// command.
+type rebuildIndexCmd struct{}
+
+var (
+	// rebuildIndexCfg defines the configuration options for the command.
+	rebuildIndexCfg = rebuildIndexCmd{}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *rebuildIndexCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	// Ensure expected arguments.
+	name, err := parseIndexName(args)
+	if err != nil {
+		return err
+	}
+
+	// Load the block database.
+	db, err := loadBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	interrupt := interruptListener()
+	chain, err := loadChain(db, interrupt)
+	if err != nil {
+		return err
+	}
+
+	// The index is rebuilt from every block of the main chain, so none of
+	// them may have been pruned.
+	if _, pruned := chain.PruneHeight(); pruned {
+		return fmt.Errorf("unable to rebuild the index since blocks " +
+			"have been pruned")
+	}
+
+	// The address index refers to blocks by the internal IDs maintained by
+	// the transaction index.  Dropping the transaction index also drops the
+	// address index, so it is rebuilt as well when it exists.  Conversely,
+	// the transaction index is caught up along with the address index when
+	// only the latter is rebuilt.
+	indexer := newIndexer(db, name)
+	indexes := []indexers.Indexer{indexer}
+	switch name {
+	case "txindex":
+		addrIndex := newIndexer(db, "addrindex")
+		tipHash, _, err := indexers.IndexTip(db, addrIndex)
+		if err != nil {
+			return err
+		}
+		if tipHash != nil {
+			log.Infof("Rebuilding the %s along with the %s since it "+
+				"depends on it", addrIndex.Name(), indexer.Name())
+			indexes = append(indexes, addrIndex)
+		}
+
+	case "addrindex":
+		txIndex := newIndexer(db, "txindex")
+		tipHash, _, err := indexers.IndexTip(db, txIndex)
+		if err != nil {
+			return err
+		}
+		if tipHash == nil {
+			return fmt.Errorf("the %s requires the %s", indexer.Name(),
+				txIndex.Name())
+		}
+		indexes = []indexers.Indexer{txIndex, indexer}
+	}
+
+	// Drop the index and build it again from scratch by catching it up
+	// with the main chain.
+	startTime := time.Now()
+	if err := dropIndex(db, name, interrupt); err != nil {
+		return err
+	}
+	indexManager := indexers.NewManager(db, indexes)
+	if err := indexManager.Init(chain, interrupt); err != nil {
+		return err
+	}
+	if err := indexManager.WaitForCatchUp(); err != nil {
+		return err
+	}
+
+	// Write out any changes the chain made to the utxo set while ensuring
+	// it is consistent with the best chain.
+	if err := chain.FlushUtxoCache(blockchain.FlushRequired); err != nil {
+		return err
+	}
+
+	log.Infof("Rebuilt the %s up to height %d in %v", indexer.Name(),
+		chain.BestSnapshot().Height, time.Since(startTime))
+	return nil
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *rebuildIndexCmd) Usage() string {
+	return "<index-name>"
+}
@@ -168,6 +168,18 @@ type blockStore struct {
 	// new blocks are written to.
 	writeCursor *writeCursor
 
+	// firstFileNum is the number of the oldest flat file that still exists
+	// on disk.  It is only ever non-zero once the block files have been
+	// pruned.  It is only accessed during write transactions, so it is
+	// protected by the database write lock.
+	firstFileNum uint32
+
+	// fileSizes caches the sizes of the flat files prior to the current
+	// write file, which no longer change once a newer file is written, so
+	// they are only read from disk the first time they are needed.  Like
+	// firstFileNum, it is protected by the database write lock.
+	fileSizes map[uint32]uint64
+
 	// These functions are set to openFile, openWriteFile, and deleteFile by
 	// default, but are exposed here to allow the whitebox tests to replace
 	// them when working with mock files.
@@ -307,6 +319,63 @@ func (s *blockStore) deleteFile(fileNum uint32) error {
 	return nil
 }
 
+// removeFile closes the passed flat file number when it is open for reads and
+// removes it from disk.  It is used when pruning old block files.
+//
+// NOTE: This function MUST only be called during a write transaction and the
+// passed file MUST NOT be the current write file.
+func (s *blockStore) removeFile(fileNum uint32) error {
+	s.obfMutex.Lock()
+	if obf, ok := s.openBlockFiles[fileNum]; ok {
+		s.lruMutex.Lock()
+		s.openBlocksLRU.Remove(s.fileNumToLRUElem[fileNum])
+		delete(s.fileNumToLRUElem, fileNum)
+		s.lruMutex.Unlock()
+
+		// Close the file under the write lock for the file in case any
+		// readers are currently reading from it.
+		obf.Lock()
+		_ = obf.file.Close()
+		obf.Unlock()
+
+		delete(s.openBlockFiles, fileNum)
+	}
+	s.obfMutex.Unlock()
+	delete(s.fileSizes, fileNum)
+
+	return s.deleteFileFunc(fileNum)
+}
+
+// fileSize returns the size in bytes of the passed flat file number.  The size
+// of the current write file is the write cursor offset.
+//
+// NOTE: This function MUST only be called during a write transaction.
+func (s *blockStore) fileSize(fileNum uint32) (uint64, error) {
+	wc := s.writeCursor
+	wc.RLock()
+	curFileNum, curOffset := wc.curFileNum, wc.curOffset
+	wc.RUnlock()
+	if fileNum == curFileNum {
+		return uint64(curOffset), nil
+	}
+	if size, ok := s.fileSizes[fileNum]; ok {
+		return size, nil
+	}
+
+	st, err := os.Stat(blockFilePath(s.basePath, fileNum))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	size := uint64(st.Size())
+	if fileNum < curFileNum {
+		s.fileSizes[fileNum] = size
+	}
+	return size, nil
+}
+
 // blockFile attempts to return an existing file handle for the passed flat file
 // number if it is already open as well as marking it as most recently used.  It
 // will also open the file when it's not already open subject to the rules
@@ -663,6 +732,14 @@ func (s *blockStore) handleRollback(oldBlockFileNum, oldBlockOffset uint32) {
 	log.Debugf("ROLLBACK: Rolling back to file %d, offset %d",
 		oldBlockFileNum, oldBlockOffset)
 
+	// The files from the rollback file onwards are truncated or deleted,
+	// so their cached sizes no longer apply.
+	for fileNum := range s.fileSizes {
+		if fileNum >= oldBlockFileNum {
+			delete(s.fileSizes, fileNum)
+		}
+	}
+
 	// Close the current write file if it needs to be deleted.  Then delete
 	// all files that are newer than the provided rollback file while
 	// also moving the write cursor file backwards accordingly.
@@ -713,14 +790,38 @@ func (s *blockStore) handleRollback(oldBlockFileNum, oldBlockOffset uint32) {
 }
 
 // scanBlockFiles searches the database directory for all flat block files to
-// find the end of the most recent file.  This position is considered the
-// current write cursor which is also stored in the metadata.  Thus, it is used
-// to detect unexpected shutdowns in the middle of writes so the block files
-// can be reconciled.
-func scanBlockFiles(dbPath string) (int, uint32) {
+// find the oldest file and the end of the most recent file.  The end position
+// is considered the current write cursor which is also stored in the metadata.
+// Thus, it is used to detect unexpected shutdowns in the middle of writes so
+// the block files can be reconciled.
+//
+// The oldest file is only ever something other than the first file when the
+// block files have been pruned.
+func scanBlockFiles(dbPath string) (int, int, uint32) {
+	// Find the oldest block file by looking for the lowest numbered file
+	// matching the block filename template in the database directory.
+	firstFile := -1
+	entries, _ := os.ReadDir(dbPath)
+	for _, entry := range entries {
+		var fileNum uint32
+		_, err := fmt.Sscanf(entry.Name(), blockFilenameTemplate, &fileNum)
+		if err != nil || entry.Name() != fmt.Sprintf(blockFilenameTemplate,
+			fileNum) {
+
+			continue
+		}
+		if firstFile == -1 || int(fileNum) < firstFile {
+			firstFile = int(fileNum)
+		}
+	}
+	if firstFile == -1 {
+		log.Tracef("Scan found no block files")
+		return -1, -1, 0
+	}
+
 	lastFile := -1
 	fileLen := uint32(0)
-	for i := 0; ; i++ {
+	for i := firstFile; ; i++ {
 		filePath := blockFilePath(dbPath, uint32(i))
 		st, err := os.Stat(filePath)
 		if err != nil {
@@ -731,9 +832,9 @@ func scanBlockFiles(dbPath string) (int, uint32) {
 		fileLen = uint32(st.Size())
 	}
 
-	log.Tracef("Scan found latest block file #%d with length %d", lastFile,
-		fileLen)
-	return lastFile, fileLen
+	log.Tracef("Scan found block files #%d through #%d with length %d",
+		firstFile, lastFile, fileLen)
+	return firstFile, lastFile, fileLen
 }
 
 // newBlockStore returns a new block store with the current block file number
@@ -742,8 +843,9 @@ func newBlockStore(basePath string, network wire.BitcoinNet) *blockStore {
 	// Look for the end of the latest block to file to determine what the
 	// write cursor position is from the viewpoing of the block files on
 	// disk.
-	fileNum, fileOff := scanBlockFiles(basePath)
+	firstFileNum, fileNum, fileOff := scanBlockFiles(basePath)
 	if fileNum == -1 {
+		firstFileNum = 0
 		fileNum = 0
 		fileOff = 0
 	}
@@ -761,6 +863,8 @@ func newBlockStore(basePath string, network wire.BitcoinNet) *blockStore {
 			curFileNum: uint32(fileNum),
 			curOffset:  fileOff,
 		},
+		firstFileNum: uint32(firstFileNum),
+		fileSizes:    make(map[uint32]uint64),
 	}
 	store.openFileFunc = store.openFile
 	store.openWriteFileFunc = store.openWriteFile
@@ -74,6 +74,10 @@ var (
 	// writeLocKeyName is the key used to store the current write file
 	// location.
 	writeLocKeyName = []byte("ffldb-writeloc")
+
+	// beenPrunedKeyName is the key used to record that block files have
+	// been pruned from the database.
+	beenPrunedKeyName = []byte("ffldb-beenpruned")
 )
 
 // Common error strings.
@@ -966,6 +970,9 @@ type transaction struct {
 	pendingBlocks    map[chainhash.Hash]int
 	pendingBlockData []pendingBlock
 
+	// Block files that need to be deleted on commit due to pruning.
+	pendingPruneFiles []uint32
+
 	// Keys that need to be stored or deleted on commit.
 	pendingKeys   *treap.Mutable
 	pendingRemove *treap.Mutable
@@ -1583,6 +1590,138 @@ func (tx *transaction) FetchBlockRegions(regions []database.BlockRegion) ([][]by
 	return blockRegions, nil
 }
 
+// PruneBlocks deletes the oldest flat block files until the total size of the
+// block files on disk is at or below the passed target size in bytes.  The
+// file which houses the oldest of the passed blocks to keep and every file
+// after it, along with the current write file, are never deleted.  It returns
+// the hashes of all blocks that were removed from the database.
+//
+// The block files are not actually deleted until the transaction is committed.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) PruneBlocks(targetSize uint64, keep []chainhash.Hash) ([]chainhash.Hash, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	// Ensure the transaction is writable.
+	if !tx.writable {
+		str := "prune blocks requires a writable database transaction"
+		return nil, makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Determine the total size of the block files and the sizes of the
+	// files that are candidates for deletion.  The current write file is
+	// never a candidate.
+	store := tx.db.store
+	store.writeCursor.RLock()
+	keepFileNum := store.writeCursor.curFileNum
+	store.writeCursor.RUnlock()
+	firstFileNum := store.firstFileNum
+	if n := len(tx.pendingPruneFiles); n > 0 {
+		firstFileNum = tx.pendingPruneFiles[n-1] + 1
+	}
+	var totalSize uint64
+	fileSizes := make([]uint64, 0, keepFileNum-firstFileNum)
+	for fileNum := firstFileNum; fileNum <= keepFileNum; fileNum++ {
+		size, err := store.fileSize(fileNum)
+		if err != nil {
+			return nil, err
+		}
+		totalSize += size
+		if fileNum < keepFileNum {
+			fileSizes = append(fileSizes, size)
+		}
+	}
+	if totalSize <= targetSize {
+		return nil, nil
+	}
+
+	// Never delete the file that houses the oldest block to keep or any
+	// of the files after it.
+	for i := range keep {
+		blockRow := tx.blockIdxBucket.Get(keep[i][:])
+		if blockRow == nil {
+			continue
+		}
+		location := deserializeBlockLoc(blockRow)
+		if location.blockFileNum < keepFileNum {
+			keepFileNum = location.blockFileNum
+		}
+	}
+
+	// Choose the oldest files to delete until the target size is reached.
+	var pruneFiles []uint32
+	for i, size := range fileSizes {
+		fileNum := firstFileNum + uint32(i)
+		if totalSize <= targetSize || fileNum >= keepFileNum {
+			break
+		}
+		pruneFiles = append(pruneFiles, fileNum)
+		totalSize -= size
+	}
+	if len(pruneFiles) == 0 {
+		return nil, nil
+	}
+
+	// Remove all blocks housed in the files to delete from the block
+	// index.
+	lastPruneFile := pruneFiles[len(pruneFiles)-1]
+	var prunedHashes []chainhash.Hash
+	err := tx.blockIdxBucket.ForEach(func(k, v []byte) error {
+		location := deserializeBlockLoc(v)
+		if location.blockFileNum <= lastPruneFile {
+			var hash chainhash.Hash
+			copy(hash[:], k)
+			prunedHashes = append(prunedHashes, hash)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range prunedHashes {
+		if err := tx.blockIdxBucket.Delete(prunedHashes[i][:]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Record that the database has been pruned and queue the files to be
+	// deleted on commit.
+	if err := tx.metaBucket.Put(beenPrunedKeyName, []byte{1}); err != nil {
+		return nil, err
+	}
+	tx.pendingPruneFiles = append(tx.pendingPruneFiles, pruneFiles...)
+
+	log.Debugf("Pruning %d blocks from block files %d through %d",
+		len(prunedHashes), pruneFiles[0], lastPruneFile)
+	return prunedHashes, nil
+}
+
+// BeenPruned returns whether or not any block files have ever been pruned from
+// the database.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) BeenPruned() (bool, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return false, err
+	}
+
+	if len(tx.pendingPruneFiles) > 0 {
+		return true, nil
+	}
+	return tx.metaBucket.Get(beenPrunedKeyName) != nil, nil
+}
+
 // close marks the transaction closed then releases any pending data, the
 // underlying snapshot, the transaction read lock, and the write lock when the
 // transaction is writable.
@@ -1592,6 +1731,7 @@ func (tx *transaction) close() {
 	// Clear pending blocks that would have been written on commit.
 	tx.pendingBlocks = nil
 	tx.pendingBlockData = nil
+	tx.pendingPruneFiles = nil
 
 	// Clear pending keys that would have been written or deleted on commit.
 	tx.pendingKeys = nil
@@ -1666,7 +1806,30 @@ func (tx *transaction) writePendingAndCommit() error {
 
 	// Atomically update the database cache.  The cache automatically
 	// handles flushing to the underlying persistent storage database.
-	return tx.db.cache.commitTx(tx)
+	if err := tx.db.cache.commitTx(tx); err != nil {
+		return err
+	}
+
+	// Nothing more to do when no block files are being pruned.
+	if len(tx.pendingPruneFiles) == 0 {
+		return nil
+	}
+
+	// Flush the cache so the removal of the pruned blocks from the block
+	// index is persisted before the files are deleted.  Otherwise, an
+	// unclean shutdown could leave the block index referencing files that
+	// no longer exist.
+	if err := tx.db.cache.flush(); err != nil {
+		return err
+	}
+	for _, fileNum := range tx.pendingPruneFiles {
+		log.Debugf("Deleting pruned block file %d", fileNum)
+		if err := tx.db.store.removeFile(fileNum); err != nil {
+			return err
+		}
+		tx.db.store.firstFileNum = fileNum + 1
+	}
+	return nil
 }
 
 // Commit commits all changes that have been made to the root metadata bucket
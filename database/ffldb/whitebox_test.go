@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcd/btcutil"
@@ -704,3 +705,156 @@ func TestFailureScenarios(t *testing.T) {
 	// Test various corruption scenarios.
 	testCorruption(tc)
 }
+
+// TestPruneBlocks ensures pruning deletes the oldest block files and their
+// blocks from the block index, never deletes blocks that must be kept, and
+// that the pruned state survives reopening the database.
+func TestPruneBlocks(t *testing.T) {
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-pruneblocks")
+	_ = os.RemoveAll(dbPath)
+	idb, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer func() {
+		idb.Close()
+	}()
+
+	// Change the maximum file size to a small value to force multiple flat
+	// files with the test data set.
+	store := idb.(*db).store
+	store.maxBlockFileSize = 2048 // 2KiB
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("loadBlocks: Unexpected error: %v", err)
+	}
+	err = idb.Update(func(tx database.Tx) error {
+		for _, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to store blocks: %v", err)
+	}
+	lastFileNum := store.writeCursor.curFileNum
+
+	// Pruning to a target size larger than the block files must not
+	// delete anything.
+	err = idb.Update(func(tx database.Tx) error {
+		pruned, err := tx.PruneBlocks(1<<30, nil)
+		if err != nil {
+			return err
+		}
+		if len(pruned) != 0 {
+			return fmt.Errorf("pruned %d blocks under target size",
+				len(pruned))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("PruneBlocks: %v", err)
+	}
+
+	// The sizes of all files prior to the current write file must be
+	// cached now so they are not read from disk again.
+	for fileNum := uint32(0); fileNum < lastFileNum; fileNum++ {
+		st, err := os.Stat(blockFilePath(dbPath, fileNum))
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+		size, ok := store.fileSizes[fileNum]
+		if !ok || size != uint64(st.Size()) {
+			t.Fatalf("unexpected cached size for file %d: got %d "+
+				"(cached %v), want %d", fileNum, size, ok,
+				st.Size())
+		}
+	}
+	if _, ok := store.fileSizes[lastFileNum]; ok {
+		t.Fatalf("size of current write file %d is cached", lastFileNum)
+	}
+
+	// Prune everything possible while keeping the final 100 blocks and
+	// ensure only the oldest blocks were removed.
+	keepFrom := len(blocks) - 100
+	keep := make([]chainhash.Hash, 0, 100)
+	for _, block := range blocks[keepFrom:] {
+		keep = append(keep, *block.Hash())
+	}
+	var pruned []chainhash.Hash
+	err = idb.Update(func(tx database.Tx) error {
+		var err error
+		pruned, err = tx.PruneBlocks(0, keep)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("PruneBlocks: %v", err)
+	}
+	if len(pruned) == 0 || len(pruned) > keepFrom {
+		t.Fatalf("unexpected number of pruned blocks %d", len(pruned))
+	}
+	if !fileExists(blockFilePath(dbPath, lastFileNum)) {
+		t.Fatalf("current write file %d was deleted", lastFileNum)
+	}
+	if fileExists(blockFilePath(dbPath, 0)) {
+		t.Fatal("oldest block file was not deleted")
+	}
+
+	// checkPruned ensures the expected blocks are available.
+	checkPruned := func(idb database.DB) {
+		t.Helper()
+		err := idb.View(func(tx database.Tx) error {
+			beenPruned, err := tx.BeenPruned()
+			if err != nil {
+				return err
+			}
+			if !beenPruned {
+				return fmt.Errorf("database not marked pruned")
+			}
+			for i, block := range blocks {
+				has, err := tx.HasBlock(block.Hash())
+				if err != nil {
+					return err
+				}
+				if want := i >= len(pruned); has != want {
+					return fmt.Errorf("HasBlock #%d: got %v, "+
+						"want %v", i, has, want)
+				}
+				if !has {
+					continue
+				}
+				if _, err := tx.FetchBlock(block.Hash()); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected pruned state: %v", err)
+		}
+	}
+	checkPruned(idb)
+
+	// Reopen the database and ensure the pruned state is loaded and new
+	// blocks may be stored.
+	idb.Close()
+	idb, err = database.Open(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to reopen test database: %v", err)
+	}
+	checkPruned(idb)
+	if got := idb.(*db).store.firstFileNum; got == 0 {
+		t.Fatal("oldest block file number not loaded")
+	}
+	err = idb.Update(func(tx database.Tx) error {
+		return tx.StoreBlock(blocks[0])
+	})
+	if err != nil {
+		t.Fatalf("Failed to store pruned block again: %v", err)
+	}
+}
@@ -389,6 +389,31 @@ type Tx interface {
 	// implementations.
 	FetchBlockRegions(regions []BlockRegion) ([][]byte, error)
 
+	// PruneBlocks deletes the oldest stored blocks until the total size of
+	// the block storage is at or below the provided target size in bytes.
+	// Blocks stored after the oldest of the provided blocks to keep are
+	// never deleted.  It returns the hashes of all blocks that were
+	// deleted.  When the block storage is already under the target size,
+	// no blocks are deleted and the returned hashes are nil.
+	//
+	// Depending on the backend implementation, blocks may be deleted in
+	// large groups, so it is possible for the storage to end up well below
+	// the target size.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrTxNotWritable if attempted against a read-only transaction
+	//   - ErrTxClosed if the transaction has already been closed
+	PruneBlocks(targetSize uint64, keep []chainhash.Hash) ([]chainhash.Hash, error)
+
+	// BeenPruned returns whether or not any blocks have ever been pruned
+	// from the block storage.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrTxClosed if the transaction has already been closed
+	BeenPruned() (bool, error)
+
 	// ******************************************************************
 	// Methods related to both atomic metadata storage and block storage.
 	// ******************************************************************
@@ -27,6 +27,14 @@ Application Options:
       --addrindex             Maintain a full address-based transaction index
                               which makes the searchrawtransactions RPC
                               available
+      --assumevalid=          Skip script validation for the ancestors of the
+                              block with this hash -- Use 0 to validate all
+                              scripts (default: built-in value for the active
+                              network)
+      --balanceindex          Maintain an index of the outputs paying to every
+                              script along with the transactions that spend
+                              them which makes the getaddressbalance and
+                              getaddressutxos RPCs available
       --banduration=          How long to ban misbehaving peers.  Valid time
                               units are {s, m, h}.  Minimum 1 second (default:
                               24h0m0s)
@@ -44,6 +52,9 @@ Application Options:
                               transactions when creating a block (default:
                               50000)
       --blocksonly            Do not accept transactions from remote peers.
+      --coinstatsindex        Maintain an index of the utxo set statistics and
+                              its MuHash for every block which makes the
+                              gettxoutsetinfo RPC available
   -C, --configfile=           Path to configuration file
       --connect=              Connect only to the specified peers at startup
       --cpuprofile=           Write CPU profile to the specified file
@@ -59,14 +70,47 @@ Application Options:
                               info)
       --dropaddrindex         Deletes the address-based transaction index from
                               the database on start up and then exits.
+      --dropbalanceindex      Deletes the balance index from the database on
+                              start up and then exits.
       --dropcfindex           Deletes the index used for committed filtering
                               (CF) support from the database on start up and
                               then exits.
+      --dropcoinstatsindex    Deletes the utxo set statistics index from the
+                              database on start up and then exits.
+      --dropscripthashindex   Deletes the script hash index from the database
+                              on start up and then exits.
+      --dropspendjournalindex Deletes the spend journal index from the database
+                              on start up and then exits.
       --droptxindex           Deletes the hash-based transaction index from the
                               database on start up and then exits.
+      --droptxospenderindex   Deletes the transaction output spender index from
+                              the database on start up and then exits.
+      --electrumlisten=       Add an interface/port to listen for Electrum
+                              protocol connections over TCP (default port:
+                              50001) -- Enables the script hash index
+      --electrummaxclients=   Max number of Electrum protocol clients (default:
+                              100)
+      --electrumtlslisten=    Add an interface/port to listen for Electrum
+                              protocol connections over TLS using the RPC
+                              certificate and key (default port: 50002) --
+                              Enables the script hash index
       --externalip=           Add an ip to the list of local addresses we claim
                               to listen on to peers
       --generate              Generate (mine) bitcoins using the CPU
+      --limitancestorcount=   Do not accept transactions that would have more
+                              than this many unconfirmed ancestors in the
+                              mempool, including themselves (default: 25)
+      --limitancestorsize=    Do not accept transactions whose virtual size
+                              along with their unconfirmed ancestors in the
+                              mempool would exceed this many kilobytes
+                              (default: 101)
+      --limitdescendantcount= Do not accept transactions that would give a
+                              transaction in the mempool more than this many
+                              descendants, including itself (default: 25)
+      --limitdescendantsize=  Do not accept transactions that would make the
+                              virtual size of a transaction in the mempool
+                              along with its descendants exceed this many
+                              kilobytes (default: 101)
       --limitfreerelay=       Limit relay of transactions with no transaction
                               fee to the given amount in thousands of bytes per
                               minute (default: 15)
@@ -74,6 +118,9 @@ Application Options:
                               (default all interfaces port: 8333, testnet:
                               18333, signet: 38333)
       --logdir=               Directory to log output
+      --maxmempool=           Keep the transaction memory pool below this many
+                              megabytes by evicting the transactions paying the
+                              lowest fee rates (default: 300)
       --maxorphantx=          Max number of orphan transactions to keep in
                               memory (default: 100)
       --maxpeers=             Max number of inbound and outbound peers
@@ -95,6 +142,8 @@ Application Options:
                               also specifying listen interfaces via --listen
       --noonion               Disable connecting to tor hidden services
       --nopeerbloomfilters    Disable bloom filtering support
+      --nopersistmempool      Do not save the mempool on shutdown and load it
+                              again on startup
       --norelaypriority       Do not require free or low-fee transactions to
                               have high priority for relaying
       --norpc                 Disable built-in RPC server -- NOTE: The RPC
@@ -113,6 +162,10 @@ Application Options:
       --proxy=                Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)
       --proxypass=            Password for proxy server
       --proxyuser=            Username for proxy server
+      --prune=                Delete old blocks to keep the stored blocks under
+                              the specified size in MiB -- Must be at least
+                              1536 MiB and is not compatible with --txindex or
+                              --addrindex
       --regtest               Use the regression test network
       --rejectnonstd          Reject non-standard transactions regardless of
                               the default settings for the active network.
@@ -135,9 +188,17 @@ Application Options:
                               need to be worked around
   -P, --rpcpass=              Password for RPC connections
   -u, --rpcuser=              Username for RPC connections
+      --scripthashindex       Maintain an index of the history and unspent
+                              outputs of every script by its hash which is
+                              used by the Electrum protocol server
       --sigcachemaxsize=      The maximum number of entries in the signature
                               verification cache (default: 100000)
       --simnet                Use the simulation test network
+      --spendjournalindex     Retain the outputs spent by blocks that are
+                              disconnected from the main chain for 2016
+                              blocks which is required to stream block events
+                              to external indexers via the notifyindexevents
+                              websocket command
       --testnet               Use the test network
       --torisolation          Enable Tor stream isolation by randomizing user
                               credentials for each connection.
@@ -146,9 +207,18 @@ Application Options:
       --txindex               Maintain a full hash-based transaction index
                               which makes all transactions available via the
                               getrawtransaction RPC
+      --txospenderindex       Maintain an index of the transaction that spends
+                              every output in the main chain which makes
+                              confirmed spends available via the
+                              gettxspendingprevout RPC
       --uacomment=            Comment to add to the user agent -- See BIP 14
                               for more information.
       --upnp                  Use UPnP to map our listening port outside of NAT
+      --utxocachemaxsize=     The maximum size in MiB of the UTXO cache
+                              (default: 250)
+      --verifycfindex         Recompute the committed filter header chain from
+                              the stored filters on start up and report the
+                              first block that does not match
   -V, --version               Display version information and exit
       --whitelist=            Add an IP network or IP that will not be banned.
                               (eg. 192.168.1.0/24 or ::1)
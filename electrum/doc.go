@@ -0,0 +1,32 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package electrum implements a server for the Electrum protocol which is used by
+lightweight wallets to query the history, balance and unspent outputs of their
+scripts and to broadcast transactions.
+
+The server speaks newline-delimited JSON-RPC 2.0 over plain TCP or TLS
+connections and is backed by the script hash index of the indexers package,
+including its tracking of the transactions in the memory pool.  The following
+methods are supported:
+
+	server.version
+	server.ping
+	blockchain.headers.subscribe
+	blockchain.scripthash.get_balance
+	blockchain.scripthash.get_history
+	blockchain.scripthash.listunspent
+	blockchain.scripthash.subscribe
+	blockchain.scripthash.unsubscribe
+	transaction.broadcast
+
+Clients that subscribed to a script hash are notified whenever its status, as
+defined by the protocol, changes due to a block being connected to or
+disconnected from the main chain or due to a change of the memory pool.  Since
+the status is recomputed from the index, notifications remain correct across
+chain reorganizations.  Clients that subscribed to headers are notified of
+every new tip of the main chain.
+*/
+package electrum
@@ -0,0 +1,1033 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package electrum
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/blockchain/indexers"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// ProtocolVersion is the version of the Electrum protocol implemented
+	// by the server.
+	ProtocolVersion = "1.4"
+
+	// maxRequestSize is the maximum size of a single line sent by a
+	// client.  It is large enough to broadcast any standard transaction.
+	maxRequestSize = 1024 * 1024
+
+	// idleTimeout is the duration after which a client that has not sent
+	// any request is disconnected.  Clients are expected to send
+	// server.ping requests to keep the connection alive.
+	idleTimeout = 10 * time.Minute
+
+	// writeTimeout is the maximum duration allowed for writing a response
+	// or notification to a client.
+	writeTimeout = 30 * time.Second
+)
+
+// Error codes returned to clients.  The codes below zero are the standard
+// JSON-RPC 2.0 codes while the others are those used by the reference Electrum
+// server implementation.
+const (
+	errCodeParse          = -32700
+	errCodeInvalidRequest = -32600
+	errCodeMethodNotFound = -32601
+	errCodeInvalidParams  = -32602
+	errCodeBadRequest     = 1
+	errCodeDaemonError    = 2
+)
+
+// rpcError is an error returned to a client in response to a request.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error satisfies the error interface.
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Message)
+}
+
+// request is a JSON-RPC request or notification sent by a client.
+type request struct {
+	JSONRPC string            `json:"jsonrpc"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+	ID      json.RawMessage   `json:"id"`
+}
+
+// response is a JSON-RPC response to a client request.  Exactly one of the
+// result and the error is set.
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// notification is a JSON-RPC notification sent to a client.
+type notification struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// headerResult models the result of the blockchain.headers.subscribe method
+// and the parameter of its notifications.
+type headerResult struct {
+	Hex    string `json:"hex"`
+	Height int32  `json:"height"`
+}
+
+// balanceResult models the result of the blockchain.scripthash.get_balance
+// method.
+type balanceResult struct {
+	Confirmed   int64 `json:"confirmed"`
+	Unconfirmed int64 `json:"unconfirmed"`
+}
+
+// historyEntry models an entry of the result of the
+// blockchain.scripthash.get_history method.  The height is zero for
+// transactions in the memory pool and -1 for those that also spend an output of
+// another transaction in the memory pool.  The fee is only set for
+// transactions in the memory pool.
+type historyEntry struct {
+	TxHash string `json:"tx_hash"`
+	Height int32  `json:"height"`
+	Fee    *int64 `json:"fee,omitempty"`
+}
+
+// unspentEntry models an entry of the result of the
+// blockchain.scripthash.listunspent method.  The height is zero for outputs
+// created by transactions in the memory pool.
+type unspentEntry struct {
+	TxPos  uint32 `json:"tx_pos"`
+	Value  int64  `json:"value"`
+	TxHash string `json:"tx_hash"`
+	Height int32  `json:"height"`
+}
+
+// Config is a descriptor containing the Electrum server configuration.
+type Config struct {
+	// Listeners defines a slice of listeners for which the server will
+	// receive new connections.  TLS listeners must already be wrapped.
+	Listeners []net.Listener
+
+	// MaxClients is the maximum number of clients that may be connected at
+	// the same time.
+	MaxClients int
+
+	// ServerVersion is the software version reported to clients in
+	// response to the server.version method.
+	ServerVersion string
+
+	// Chain is the main chain the server reports headers for.
+	Chain *blockchain.BlockChain
+
+	// ScriptHashIndex is the index used to look up the history, balance and
+	// unspent outputs of script hashes.  It must also be used by the memory
+	// pool to track unconfirmed transactions.
+	ScriptHashIndex *indexers.ScriptHashIndex
+
+	// IndexManager is the manager of the script hash index.  Requests that
+	// depend on the index are rejected while it is still catching up with
+	// the main chain.  It may be nil when the index is always up to date.
+	IndexManager *indexers.Manager
+
+	// SubmitTx submits a transaction broadcast by a client to the memory
+	// pool and relays it to the network.
+	SubmitTx func(tx *btcutil.Tx) error
+}
+
+// Server provides an Electrum protocol server to lightweight wallets.
+type Server struct {
+	started  int32
+	shutdown int32
+	cfg      Config
+
+	clientsMtx sync.Mutex
+	clients    map[*client]struct{}
+
+	// blocksChanged is set when the main chain changed since the
+	// confirmed history cache was last cleared.  It must be accessed
+	// atomically.
+	blocksChanged int32
+
+	// historyCache caches the confirmed history of subscribed script
+	// hashes between notification passes.  It is only accessed by the
+	// notification handler.
+	historyCache map[chainhash.Hash][]indexers.ScriptHashTx
+
+	notify chan struct{}
+	wg     sync.WaitGroup
+	quit   chan struct{}
+}
+
+// client houses the state of a connected client.
+type client struct {
+	server *Server
+	conn   net.Conn
+	addr   string
+
+	sendMtx sync.Mutex
+
+	// The following fields hold the subscriptions of the client.  The
+	// statuses map holds the last status sent to the client for each
+	// subscribed script hash, where nil represents an empty history, and
+	// tip holds the hash of the last header sent to the client or nil if
+	// it did not subscribe to headers.  They are protected by subsMtx.
+	subsMtx  sync.Mutex
+	statuses map[chainhash.Hash]*string
+	tip      *chainhash.Hash
+}
+
+// handler is the type of the functions that handle a client request.
+type handler func(*client, []json.RawMessage) (interface{}, error)
+
+// handlers maps the supported methods to their handlers.  It is set in init to
+// avoid an initialization loop.
+var handlers map[string]handler
+
+func init() {
+	handlers = map[string]handler{
+		"server.version":                    handleServerVersion,
+		"server.ping":                       handlePing,
+		"blockchain.headers.subscribe":      handleHeadersSubscribe,
+		"blockchain.scripthash.get_balance": handleGetBalance,
+		"blockchain.scripthash.get_history": handleGetHistory,
+		"blockchain.scripthash.listunspent": handleListUnspent,
+		"blockchain.scripthash.subscribe":   handleSubscribe,
+		"blockchain.scripthash.unsubscribe": handleUnsubscribe,
+		"transaction.broadcast":             handleBroadcast,
+	}
+}
+
+// invalidParams returns an error that indicates the parameters of a request
+// are invalid.
+func invalidParams(format string, args ...interface{}) error {
+	return &rpcError{
+		Code:    errCodeInvalidParams,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// parseStringParam parses the parameter at the provided index as a string.
+func parseStringParam(params []json.RawMessage, idx int) (string, error) {
+	if idx >= len(params) {
+		return "", invalidParams("missing parameter %d", idx)
+	}
+	var s string
+	if err := json.Unmarshal(params[idx], &s); err != nil {
+		return "", invalidParams("parameter %d is not a string", idx)
+	}
+	return s, nil
+}
+
+// parseScriptHashParam parses the first parameter as a script hash in the
+// byte-reversed hex encoding used by the protocol.
+func parseScriptHashParam(params []json.RawMessage) (*chainhash.Hash, error) {
+	s, err := parseStringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(s) != chainhash.MaxHashStringSize {
+		return nil, invalidParams("invalid script hash %q", s)
+	}
+	scriptHash, err := chainhash.NewHashFromStr(s)
+	if err != nil {
+		return nil, invalidParams("invalid script hash %q", s)
+	}
+	return scriptHash, nil
+}
+
+// checkIndexSynced returns an error when the script hash index is still
+// catching up with the main chain.
+func (s *Server) checkIndexSynced() error {
+	if s.cfg.IndexManager == nil {
+		return nil
+	}
+	synced, height := s.cfg.IndexManager.SyncState(s.cfg.ScriptHashIndex)
+	if synced {
+		return nil
+	}
+	return &rpcError{
+		Code: errCodeDaemonError,
+		Message: fmt.Sprintf("the %s is still syncing, at height %d",
+			s.cfg.ScriptHashIndex.Name(), height),
+	}
+}
+
+// parseVersion parses a protocol version string such as "1.4.2" into its
+// numeric components.
+func parseVersion(s string) ([]int, error) {
+	parts := strings.Split(s, ".")
+	version := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid protocol version %q", s)
+		}
+		version[i] = n
+	}
+	return version, nil
+}
+
+// compareVersions returns -1, 0 or 1 depending on whether protocol version a
+// is lower than, equal to or higher than protocol version b.  Missing
+// components are treated as zero.
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// handleServerVersion implements the server.version method.  The optional
+// second parameter is either a single protocol version or a [min, max] pair
+// that must include the version implemented by the server.
+func handleServerVersion(c *client, params []json.RawMessage) (interface{}, error) {
+	minVersion, maxVersion := ProtocolVersion, ProtocolVersion
+	if len(params) > 1 {
+		var pair []string
+		if err := json.Unmarshal(params[1], &minVersion); err == nil {
+			maxVersion = minVersion
+		} else if err := json.Unmarshal(params[1], &pair); err == nil &&
+			len(pair) == 2 {
+
+			minVersion, maxVersion = pair[0], pair[1]
+		} else {
+			return nil, invalidParams("invalid protocol version")
+		}
+	}
+
+	ours, _ := parseVersion(ProtocolVersion)
+	min, err := parseVersion(minVersion)
+	if err != nil {
+		return nil, invalidParams("%v", err)
+	}
+	max, err := parseVersion(maxVersion)
+	if err != nil {
+		return nil, invalidParams("%v", err)
+	}
+	if compareVersions(min, ours) > 0 || compareVersions(max, ours) < 0 {
+		return nil, &rpcError{
+			Code: errCodeBadRequest,
+			Message: fmt.Sprintf("unsupported protocol version: "+
+				"%s", ProtocolVersion),
+		}
+	}
+
+	return []string{c.server.cfg.ServerVersion, ProtocolVersion}, nil
+}
+
+// handlePing implements the server.ping method.
+func handlePing(c *client, params []json.RawMessage) (interface{}, error) {
+	return nil, nil
+}
+
+// tipHeader returns the hash of the current tip of the main chain along with
+// the result used to report its header to clients.
+func (s *Server) tipHeader() (*chainhash.Hash, *headerResult, error) {
+	best := s.cfg.Chain.BestSnapshot()
+	header, err := s.cfg.Chain.HeaderByHash(&best.Hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	var buf bytes.Buffer
+	if err := header.Serialize(&buf); err != nil {
+		return nil, nil, err
+	}
+	return &best.Hash, &headerResult{
+		Hex:    hex.EncodeToString(buf.Bytes()),
+		Height: best.Height,
+	}, nil
+}
+
+// handleHeadersSubscribe implements the blockchain.headers.subscribe method.
+func handleHeadersSubscribe(c *client, params []json.RawMessage) (interface{}, error) {
+	hash, result, err := c.server.tipHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	c.subsMtx.Lock()
+	c.tip = hash
+	c.subsMtx.Unlock()
+
+	return result, nil
+}
+
+// handleGetBalance implements the blockchain.scripthash.get_balance method.
+// The confirmed balance is the sum of the unspent outputs in the main chain,
+// while the unconfirmed balance is the change of the balance caused by the
+// transactions in the memory pool.
+func handleGetBalance(c *client, params []json.RawMessage) (interface{}, error) {
+	scriptHash, err := parseScriptHashParam(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.server.checkIndexSynced(); err != nil {
+		return nil, err
+	}
+
+	idx := c.server.cfg.ScriptHashIndex
+	utxos, err := idx.Unspent(scriptHash)
+	if err != nil {
+		return nil, err
+	}
+	var result balanceResult
+	for _, utxo := range utxos {
+		result.Confirmed += utxo.Amount
+	}
+	for _, tx := range idx.UnconfirmedTxns(scriptHash) {
+		result.Unconfirmed += tx.Received - tx.Sent
+	}
+
+	return &result, nil
+}
+
+// mergeHistory returns the history of a script hash as reported to clients
+// given its confirmed history and its transactions in the memory pool.
+// Transactions in the memory pool that are also in the confirmed history are
+// skipped since the memory pool is updated after the index when a block is
+// connected.
+func mergeHistory(confirmed []indexers.ScriptHashTx,
+	unconfirmed []indexers.UnconfirmedScriptHashTx) []historyEntry {
+
+	history := make([]historyEntry, 0, len(confirmed)+len(unconfirmed))
+	seen := make(map[chainhash.Hash]struct{}, len(confirmed))
+	for _, tx := range confirmed {
+		seen[tx.TxHash] = struct{}{}
+		history = append(history, historyEntry{
+			TxHash: tx.TxHash.String(),
+			Height: tx.Height,
+		})
+	}
+	for _, tx := range unconfirmed {
+		if _, ok := seen[tx.TxHash]; ok {
+			continue
+		}
+		entry := historyEntry{TxHash: tx.TxHash.String()}
+		if tx.HasUnconfirmedInputs {
+			entry.Height = -1
+		}
+		fee := tx.Fee
+		entry.Fee = &fee
+		history = append(history, entry)
+	}
+
+	return history
+}
+
+// scriptHashStatus returns the status of a script hash with the provided
+// history as defined by the protocol.  That is the hex encoded SHA256 hash of
+// the concatenation of "tx_hash:height:" for every entry, or nil when the
+// history is empty.
+func scriptHashStatus(history []historyEntry) *string {
+	if len(history) == 0 {
+		return nil
+	}
+	hasher := sha256.New()
+	for _, entry := range history {
+		fmt.Fprintf(hasher, "%s:%d:", entry.TxHash, entry.Height)
+	}
+	status := hex.EncodeToString(hasher.Sum(nil))
+	return &status
+}
+
+// handleGetHistory implements the blockchain.scripthash.get_history method.
+func handleGetHistory(c *client, params []json.RawMessage) (interface{}, error) {
+	scriptHash, err := parseScriptHashParam(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.server.checkIndexSynced(); err != nil {
+		return nil, err
+	}
+
+	idx := c.server.cfg.ScriptHashIndex
+	confirmed, err := idx.History(scriptHash)
+	if err != nil {
+		return nil, err
+	}
+	return mergeHistory(confirmed, idx.UnconfirmedTxns(scriptHash)), nil
+}
+
+// handleListUnspent implements the blockchain.scripthash.listunspent method.
+// Outputs spent by transactions in the memory pool are excluded while outputs
+// created by them are included.
+func handleListUnspent(c *client, params []json.RawMessage) (interface{}, error) {
+	scriptHash, err := parseScriptHashParam(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.server.checkIndexSynced(); err != nil {
+		return nil, err
+	}
+
+	idx := c.server.cfg.ScriptHashIndex
+	utxos, err := idx.Unspent(scriptHash)
+	if err != nil {
+		return nil, err
+	}
+	confirmed := make(map[wire.OutPoint]struct{}, len(utxos))
+	result := make([]unspentEntry, 0, len(utxos))
+	for _, utxo := range utxos {
+		confirmed[utxo.OutPoint] = struct{}{}
+		if idx.IsSpentUnconfirmed(&utxo.OutPoint) {
+			continue
+		}
+		result = append(result, unspentEntry{
+			TxPos:  utxo.OutPoint.Index,
+			Value:  utxo.Amount,
+			TxHash: utxo.OutPoint.Hash.String(),
+			Height: utxo.Height,
+		})
+	}
+	for _, utxo := range idx.UnconfirmedUnspent(scriptHash) {
+		if _, ok := confirmed[utxo.OutPoint]; ok {
+			continue
+		}
+		result = append(result, unspentEntry{
+			TxPos:  utxo.OutPoint.Index,
+			Value:  utxo.Amount,
+			TxHash: utxo.OutPoint.Hash.String(),
+		})
+	}
+
+	// Order the outputs by height with the unconfirmed ones last.
+	sort.SliceStable(result, func(i, j int) bool {
+		hi, hj := result[i].Height, result[j].Height
+		if hi == 0 || hj == 0 {
+			return hi != 0 && hj == 0
+		}
+		return hi < hj
+	})
+
+	return result, nil
+}
+
+// status returns the current status of the provided script hash.
+func (s *Server) status(scriptHash *chainhash.Hash) (*string, error) {
+	idx := s.cfg.ScriptHashIndex
+	confirmed, err := idx.History(scriptHash)
+	if err != nil {
+		return nil, err
+	}
+	history := mergeHistory(confirmed, idx.UnconfirmedTxns(scriptHash))
+	return scriptHashStatus(history), nil
+}
+
+// handleSubscribe implements the blockchain.scripthash.subscribe method.
+func handleSubscribe(c *client, params []json.RawMessage) (interface{}, error) {
+	scriptHash, err := parseScriptHashParam(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.server.checkIndexSynced(); err != nil {
+		return nil, err
+	}
+	status, err := c.server.status(scriptHash)
+	if err != nil {
+		return nil, err
+	}
+
+	c.subsMtx.Lock()
+	c.statuses[*scriptHash] = status
+	c.subsMtx.Unlock()
+
+	return status, nil
+}
+
+// handleUnsubscribe implements the blockchain.scripthash.unsubscribe method.
+// It returns whether the client was subscribed to the script hash.
+func handleUnsubscribe(c *client, params []json.RawMessage) (interface{}, error) {
+	scriptHash, err := parseScriptHashParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	c.subsMtx.Lock()
+	_, ok := c.statuses[*scriptHash]
+	delete(c.statuses, *scriptHash)
+	c.subsMtx.Unlock()
+
+	return ok, nil
+}
+
+// handleBroadcast implements the transaction.broadcast method.
+func handleBroadcast(c *client, params []json.RawMessage) (interface{}, error) {
+	rawTx, err := parseStringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	serializedTx, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, invalidParams("transaction is not valid hex")
+	}
+	var msgTx wire.MsgTx
+	if err := msgTx.Deserialize(bytes.NewReader(serializedTx)); err != nil {
+		return nil, invalidParams("transaction decode failed: %v", err)
+	}
+
+	tx := btcutil.NewTx(&msgTx)
+	if err := c.server.cfg.SubmitTx(tx); err != nil {
+		log.Debugf("Rejected transaction %v from %s: %v", tx.Hash(),
+			c.addr, err)
+		return nil, &rpcError{
+			Code: errCodeBadRequest,
+			Message: fmt.Sprintf("the transaction was rejected by "+
+				"network rules.\n\n%v\n[%s]", err, rawTx),
+		}
+	}
+
+	return tx.Hash().String(), nil
+}
+
+// handleRequest processes a single request and returns the response to send
+// to the client, or nil when the request is a notification.
+func (c *client) handleRequest(req *request) *response {
+	var result interface{}
+	var err error
+	if req.Method == "" {
+		err = &rpcError{
+			Code:    errCodeInvalidRequest,
+			Message: "missing method",
+		}
+	} else if handler, ok := handlers[req.Method]; ok {
+		result, err = handler(c, req.Params)
+	} else {
+		err = &rpcError{
+			Code:    errCodeMethodNotFound,
+			Message: fmt.Sprintf("unknown method %q", req.Method),
+		}
+	}
+
+	// Requests without an id are notifications and do not get a response.
+	if len(req.ID) == 0 {
+		return nil
+	}
+
+	resp := &response{JSONRPC: "2.0", ID: req.ID}
+	if err != nil {
+		if rpcErr, ok := err.(*rpcError); ok {
+			resp.Error = rpcErr
+		} else {
+			log.Errorf("Failed to handle %s request from %s: %v",
+				req.Method, c.addr, err)
+			resp.Error = &rpcError{
+				Code:    errCodeDaemonError,
+				Message: err.Error(),
+			}
+		}
+		return resp
+	}
+	resp.Result, err = json.Marshal(result)
+	if err != nil {
+		resp.Result = nil
+		resp.Error = &rpcError{
+			Code:    errCodeDaemonError,
+			Message: err.Error(),
+		}
+	}
+	return resp
+}
+
+// handleLine processes a single line sent by the client, which is either a
+// request or a batch of requests, and returns the serialized response to send
+// back, if any.
+func (c *client) handleLine(line []byte) []byte {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil
+	}
+
+	var reply interface{}
+	if line[0] == '[' {
+		var reqs []request
+		if err := json.Unmarshal(line, &reqs); err != nil {
+			reply = parseErrorResponse(err)
+		} else if len(reqs) == 0 {
+			reply = &response{
+				JSONRPC: "2.0",
+				Error: &rpcError{
+					Code:    errCodeInvalidRequest,
+					Message: "empty batch",
+				},
+				ID: json.RawMessage("null"),
+			}
+		} else {
+			resps := make([]*response, 0, len(reqs))
+			for i := range reqs {
+				if resp := c.handleRequest(&reqs[i]); resp != nil {
+					resps = append(resps, resp)
+				}
+			}
+			if len(resps) == 0 {
+				return nil
+			}
+			reply = resps
+		}
+	} else {
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			reply = parseErrorResponse(err)
+		} else {
+			resp := c.handleRequest(&req)
+			if resp == nil {
+				return nil
+			}
+			reply = resp
+		}
+	}
+
+	serialized, err := json.Marshal(reply)
+	if err != nil {
+		log.Errorf("Failed to marshal reply to %s: %v", c.addr, err)
+		return nil
+	}
+	return serialized
+}
+
+// parseErrorResponse returns the response to a line that could not be parsed.
+func parseErrorResponse(err error) *response {
+	return &response{
+		JSONRPC: "2.0",
+		Error: &rpcError{
+			Code:    errCodeParse,
+			Message: fmt.Sprintf("invalid JSON: %v", err),
+		},
+		ID: json.RawMessage("null"),
+	}
+}
+
+// send writes the provided serialized message followed by a newline to the
+// client.  The connection is closed when the write fails.
+//
+// This function is safe for concurrent access.
+func (c *client) send(msg []byte) {
+	c.sendMtx.Lock()
+	defer c.sendMtx.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := c.conn.Write(append(msg, '\n')); err != nil {
+		log.Debugf("Failed to write to %s: %v", c.addr, err)
+		c.conn.Close()
+	}
+}
+
+// sendNotification sends a notification for the provided method and
+// parameters to the client.
+func (c *client) sendNotification(method string, params ...interface{}) {
+	msg, err := json.Marshal(&notification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		log.Errorf("Failed to marshal %s notification: %v", method, err)
+		return
+	}
+	c.send(msg)
+}
+
+// inHandler reads and handles the requests of the client until the connection
+// is closed, the client is idle for too long or it sends an oversized line.
+//
+// It must be run as a goroutine.
+func (c *client) inHandler() {
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxRequestSize)
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		if !scanner.Scan() {
+			break
+		}
+		if reply := c.handleLine(scanner.Bytes()); reply != nil {
+			c.send(reply)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Debugf("Disconnecting %s: %v", c.addr, err)
+	}
+
+	c.conn.Close()
+	c.server.removeClient(c)
+	c.server.wg.Done()
+}
+
+// addClient registers a new connection as a client and starts handling its
+// requests.  The connection is refused when the maximum number of clients is
+// already connected.
+func (s *Server) addClient(conn net.Conn) {
+	c := &client{
+		server:   s,
+		conn:     conn,
+		addr:     conn.RemoteAddr().String(),
+		statuses: make(map[chainhash.Hash]*string),
+	}
+
+	s.clientsMtx.Lock()
+	if len(s.clients) >= s.cfg.MaxClients {
+		s.clientsMtx.Unlock()
+		log.Infof("Max Electrum clients exceeded [%d] - disconnecting "+
+			"client %s", s.cfg.MaxClients, c.addr)
+		conn.Close()
+		return
+	}
+	s.clients[c] = struct{}{}
+	s.wg.Add(1)
+	s.clientsMtx.Unlock()
+
+	log.Debugf("New Electrum client %s", c.addr)
+	go c.inHandler()
+}
+
+// removeClient unregisters a disconnected client.
+func (s *Server) removeClient(c *client) {
+	s.clientsMtx.Lock()
+	delete(s.clients, c)
+	s.clientsMtx.Unlock()
+
+	log.Debugf("Electrum client %s disconnected", c.addr)
+}
+
+// listenHandler accepts new connections on the provided listener until it is
+// closed.
+//
+// It must be run as a goroutine.
+func (s *Server) listenHandler(listener net.Listener) {
+	log.Infof("Electrum server listening on %s", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if atomic.LoadInt32(&s.shutdown) == 0 {
+				log.Errorf("Can't accept connection: %v", err)
+			}
+			break
+		}
+		s.addClient(conn)
+	}
+	log.Tracef("Electrum listener done for %s", listener.Addr())
+	s.wg.Done()
+}
+
+// sendNotifications notifies every client of the changes of the main chain tip
+// and of the statuses of the script hashes it subscribed to since the last
+// notification it was sent.
+func (s *Server) sendNotifications() {
+	// The confirmed history of every script hash may have changed when
+	// blocks were connected or disconnected.  Otherwise, only the cached
+	// history of the script hashes that are still subscribed is kept.
+	cache := s.historyCache
+	if atomic.SwapInt32(&s.blocksChanged, 0) != 0 {
+		cache = nil
+	}
+	s.historyCache = make(map[chainhash.Hash][]indexers.ScriptHashTx)
+
+	tipHash, tipResult, err := s.tipHeader()
+	if err != nil {
+		log.Errorf("Unable to fetch tip header: %v", err)
+		return
+	}
+
+	s.clientsMtx.Lock()
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.clientsMtx.Unlock()
+
+	idx := s.cfg.ScriptHashIndex
+	statuses := make(map[chainhash.Hash]*string)
+	for _, c := range clients {
+		c.subsMtx.Lock()
+		notifyTip := c.tip != nil && *c.tip != *tipHash
+		if notifyTip {
+			c.tip = tipHash
+		}
+		type statusChange struct {
+			scriptHash chainhash.Hash
+			status     *string
+		}
+		var changes []statusChange
+		for scriptHash, lastStatus := range c.statuses {
+			status, ok := statuses[scriptHash]
+			if !ok {
+				confirmed, cached := cache[scriptHash]
+				if !cached {
+					confirmed, err = idx.History(&scriptHash)
+					if err != nil {
+						log.Errorf("Unable to fetch "+
+							"history of %v: %v",
+							scriptHash, err)
+						continue
+					}
+				}
+				s.historyCache[scriptHash] = confirmed
+				status = scriptHashStatus(mergeHistory(confirmed,
+					idx.UnconfirmedTxns(&scriptHash)))
+				statuses[scriptHash] = status
+			}
+
+			changed := (status == nil) != (lastStatus == nil) ||
+				(status != nil && *status != *lastStatus)
+			if changed {
+				c.statuses[scriptHash] = status
+				changes = append(changes, statusChange{
+					scriptHash, status,
+				})
+			}
+		}
+		c.subsMtx.Unlock()
+
+		if notifyTip {
+			c.sendNotification("blockchain.headers.subscribe",
+				tipResult)
+		}
+		for _, change := range changes {
+			c.sendNotification("blockchain.scripthash.subscribe",
+				change.scriptHash.String(), change.status)
+		}
+	}
+}
+
+// notificationHandler sends notifications to the clients whenever the main
+// chain or the memory pool changed.  Changes that happen while notifications
+// are being sent are coalesced into a single subsequent pass.
+//
+// It must be run as a goroutine.
+func (s *Server) notificationHandler() {
+out:
+	for {
+		select {
+		case <-s.notify:
+			s.sendNotifications()
+
+		case <-s.quit:
+			break out
+		}
+	}
+	s.wg.Done()
+}
+
+// signalNotify requests a notification pass without blocking.
+func (s *Server) signalNotify() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// handleBlockchainNotification handles notifications from blockchain.  It
+// requests a notification pass for the clients when blocks are connected to or
+// disconnected from the main chain.
+func (s *Server) handleBlockchainNotification(notification *blockchain.Notification) {
+	switch notification.Type {
+	case blockchain.NTBlockConnected, blockchain.NTBlockDisconnected:
+		atomic.StoreInt32(&s.blocksChanged, 1)
+		s.signalNotify()
+	}
+}
+
+// NotifyMempoolChanged requests that clients be notified of the changes of the
+// statuses of their script hashes caused by transactions that were added to
+// the memory pool.  This function should be called whenever new transactions
+// are added to the memory pool.
+//
+// This function is safe for concurrent access.
+func (s *Server) NotifyMempoolChanged() {
+	s.signalNotify()
+}
+
+// Start begins accepting connections on the configured listeners and sending
+// notifications to the connected clients.
+func (s *Server) Start() {
+	if atomic.AddInt32(&s.started, 1) != 1 {
+		return
+	}
+
+	log.Trace("Starting Electrum server")
+	for _, listener := range s.cfg.Listeners {
+		s.wg.Add(1)
+		go s.listenHandler(listener)
+	}
+
+	s.wg.Add(1)
+	go s.notificationHandler()
+}
+
+// Stop stops accepting connections, disconnects all clients and waits for
+// all of the server goroutines to finish.
+func (s *Server) Stop() {
+	if atomic.AddInt32(&s.shutdown, 1) != 1 {
+		log.Infof("Electrum server is already in the process of " +
+			"shutting down")
+		return
+	}
+
+	log.Warnf("Electrum server shutting down")
+	for _, listener := range s.cfg.Listeners {
+		listener.Close()
+	}
+	close(s.quit)
+
+	s.clientsMtx.Lock()
+	for c := range s.clients {
+		c.conn.Close()
+	}
+	s.clientsMtx.Unlock()
+
+	s.wg.Wait()
+	log.Infof("Electrum server shutdown complete")
+}
+
+// New returns a new Electrum server with the provided configuration.  The
+// server subscribes to the notifications of the configured chain, so it must
+// be created after any subscriber that updates the memory pool in response to
+// them in order for the notifications to reflect the updated memory pool.
+func New(cfg *Config) *Server {
+	s := &Server{
+		cfg:          *cfg,
+		clients:      make(map[*client]struct{}),
+		historyCache: make(map[chainhash.Hash][]indexers.ScriptHashTx),
+		notify:       make(chan struct{}, 1),
+		quit:         make(chan struct{}),
+	}
+	if cfg.Chain != nil {
+		cfg.Chain.Subscribe(s.handleBlockchainNotification)
+	}
+	return s
+}
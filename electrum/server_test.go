@@ -0,0 +1,131 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package electrum
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain/indexers"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// TestHandleLine ensures requests, batches and malformed lines are answered as
+// required by JSON-RPC 2.0.
+func TestHandleLine(t *testing.T) {
+	t.Parallel()
+
+	c := &client{
+		server: New(&Config{ServerVersion: "btcd test"}),
+		addr:   "test",
+	}
+
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{{
+		name: "server.version",
+		line: `{"jsonrpc":"2.0","method":"server.version","params":["wallet","1.4"],"id":0}`,
+		want: `{"jsonrpc":"2.0","result":["btcd test","1.4"],"id":0}`,
+	}, {
+		name: "server.version with version range",
+		line: `{"jsonrpc":"2.0","method":"server.version","params":["wallet",["1.2","1.4.2"]],"id":"a"}`,
+		want: `{"jsonrpc":"2.0","result":["btcd test","1.4"],"id":"a"}`,
+	}, {
+		name: "server.version with unsupported version",
+		line: `{"jsonrpc":"2.0","method":"server.version","params":["wallet","1.5"],"id":1}`,
+		want: `{"jsonrpc":"2.0","error":{"code":1,"message":"unsupported protocol version: 1.4"},"id":1}`,
+	}, {
+		name: "server.ping",
+		line: `{"jsonrpc":"2.0","method":"server.ping","id":2}`,
+		want: `{"jsonrpc":"2.0","result":null,"id":2}`,
+	}, {
+		name: "notification",
+		line: `{"jsonrpc":"2.0","method":"server.ping"}`,
+		want: "",
+	}, {
+		name: "unknown method",
+		line: `{"jsonrpc":"2.0","method":"foo","id":3}`,
+		want: `{"jsonrpc":"2.0","error":{"code":-32601,"message":"unknown method \"foo\""},"id":3}`,
+	}, {
+		name: "invalid script hash",
+		line: `{"jsonrpc":"2.0","method":"blockchain.scripthash.get_history","params":["00"],"id":4}`,
+		want: `{"jsonrpc":"2.0","error":{"code":-32602,"message":"invalid script hash \"00\""},"id":4}`,
+	}, {
+		name: "batch",
+		line: `[{"jsonrpc":"2.0","method":"server.ping","id":5},{"jsonrpc":"2.0","method":"server.ping"},{"jsonrpc":"2.0","method":"foo","id":6}]`,
+		want: `[{"jsonrpc":"2.0","result":null,"id":5},{"jsonrpc":"2.0","error":{"code":-32601,"message":"unknown method \"foo\""},"id":6}]`,
+	}, {
+		name: "empty line",
+		line: " \r",
+		want: "",
+	}, {
+		name: "invalid JSON",
+		line: `{"jsonrpc":`,
+		want: `{"jsonrpc":"2.0","error":{"code":-32700,"message":"invalid JSON: unexpected end of JSON input"},"id":null}`,
+	}}
+
+	for _, test := range tests {
+		got := string(c.handleLine([]byte(test.line)))
+		if got != test.want {
+			t.Errorf("%s: unexpected reply\ngot:  %s\nwant: %s",
+				test.name, got, test.want)
+		}
+	}
+}
+
+// TestScriptHashStatus ensures the history reported to clients and the status
+// derived from it are calculated as defined by the protocol.
+func TestScriptHashStatus(t *testing.T) {
+	t.Parallel()
+
+	hash := func(b byte) chainhash.Hash {
+		var h chainhash.Hash
+		h[0] = b
+		return h
+	}
+	confirmed := []indexers.ScriptHashTx{
+		{TxHash: hash(1), Height: 100},
+		{TxHash: hash(2), Height: 105},
+	}
+
+	// The third transaction is in the memory pool and spends an output
+	// of the fourth one, while the second one is still in the memory pool
+	// since it was just confirmed.
+	unconfirmed := []indexers.UnconfirmedScriptHashTx{
+		{TxHash: hash(2), Fee: 300},
+		{TxHash: hash(3), Fee: 200, HasUnconfirmedInputs: true},
+		{TxHash: hash(4), Fee: 100},
+	}
+	fee3, fee4 := int64(200), int64(100)
+	wantHistory := []historyEntry{
+		{TxHash: hash(1).String(), Height: 100},
+		{TxHash: hash(2).String(), Height: 105},
+		{TxHash: hash(3).String(), Height: -1, Fee: &fee3},
+		{TxHash: hash(4).String(), Height: 0, Fee: &fee4},
+	}
+	history := mergeHistory(confirmed, unconfirmed)
+	if !reflect.DeepEqual(history, wantHistory) {
+		t.Fatalf("unexpected history %+v, want %+v", history,
+			wantHistory)
+	}
+
+	preimage := hash(1).String() + ":100:" + hash(2).String() + ":105:" +
+		hash(3).String() + ":-1:" + hash(4).String() + ":0:"
+	digest := sha256.Sum256([]byte(preimage))
+	wantStatus := hex.EncodeToString(digest[:])
+	status := scriptHashStatus(history)
+	if status == nil || *status != wantStatus {
+		t.Fatalf("unexpected status %v, want %s", status, wantStatus)
+	}
+
+	// An empty history has no status.
+	if status := scriptHashStatus(mergeHistory(nil, nil)); status != nil {
+		t.Fatalf("unexpected status %s for empty history", *status)
+	}
+}
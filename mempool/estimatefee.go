@@ -11,745 +11,852 @@ import (
 	"fmt"
 	"io"
 	"math"
-	"math/rand"
 	"sort"
-	"strings"
 	"sync"
 
-	"github.com/btcsuite/btcd/chaincfg/chainhash"
-	"github.com/btcsuite/btcd/mining"
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 )
 
-// TODO incorporate Alex Morcos' modifications to Gavin's initial model
-// https://lists.linuxfoundation.org/pipermail/bitcoin-dev/2014-October/006824.html
-
 const (
-	// estimateFeeDepth is the maximum number of blocks before a transaction
-	// is confirmed that we want to track.
-	estimateFeeDepth = 25
-
-	// estimateFeeBinSize is the number of txs stored in each bin.
-	estimateFeeBinSize = 100
-
-	// estimateFeeMaxReplacements is the max number of replacements that
-	// can be made by the txs found in a given block.
-	estimateFeeMaxReplacements = 10
-
-	// DefaultEstimateFeeMaxRollback is the default number of rollbacks
-	// allowed by the fee estimator for orphaned blocks.
-	DefaultEstimateFeeMaxRollback = 2
-
-	// DefaultEstimateFeeMinRegisteredBlocks is the default minimum
-	// number of blocks which must be observed by the fee estimator before
-	// it will provide fee estimations.
-	DefaultEstimateFeeMinRegisteredBlocks = 3
+	// minBucketFeeRate and maxBucketFeeRate are the lowest and highest
+	// upper bounds in satoshi per kB of the fee rate buckets transactions
+	// are tracked in.  The last bucket holds all transactions paying more
+	// than maxBucketFeeRate.
+	minBucketFeeRate = 1000
+	maxBucketFeeRate = 1e7
+
+	// feeBucketSpacing is the ratio between the upper bounds of adjacent
+	// fee rate buckets.
+	feeBucketSpacing = 1.05
+
+	// The following parameters define the short, medium and long horizons
+	// confirmation times are tracked over.  Each of them tracks whether
+	// transactions are confirmed within a number of periods of a number of
+	// blocks each, and its moving averages decay by a factor every block.
+	shortDecay    = 0.962
+	shortScale    = 1
+	shortPeriods  = 12
+	mediumDecay   = 0.9952
+	mediumScale   = 2
+	mediumPeriods = 24
+	longDecay     = 0.99931
+	longScale     = 24
+	longPeriods   = 42
+
+	// MaxFeeEstimateTarget is the highest confirmation target in blocks
+	// fee rates can be estimated for.
+	MaxFeeEstimateTarget = longScale * longPeriods
+
+	// halfSuccessPct, successPct and doubleSuccessPct are the fractions of
+	// transactions in a range of fee rate buckets which must have been
+	// confirmed within half of the confirmation target, the target itself
+	// and twice the target respectively for the range to be sufficient.
+	halfSuccessPct   = 0.6
+	successPct       = 0.85
+	doubleSuccessPct = 0.95
+
+	// sufficientFeeTxs and sufficientTxsShort are the average numbers of
+	// transactions per block that must have been confirmed in a range of
+	// fee rate buckets for it to be evaluated over the medium and long
+	// horizons, and over the short horizon respectively.
+	sufficientFeeTxs   = 0.1
+	sufficientTxsShort = 0.5
+
+	// oldestEstimateHistory is the maximum number of blocks since a saved
+	// state of the fee estimator was recorded for the span of blocks it
+	// covers to be taken into account after it is restored.
+	oldestEstimateHistory = 6 * 1008
+
+	// estimateFeeSaveVersion is the version of the format the state of the
+	// fee estimator is saved in.  A saved state with a different version
+	// is not upgraded, fee estimation just starts over instead.
+	estimateFeeSaveVersion = 2
 
 	bytePerKb = 1000
 
 	btcPerSatoshi = 1e-8
 )
 
+// The indexes of the horizons confirmation times are tracked over.
+const (
+	shortHorizon = iota
+	mediumHorizon
+	longHorizon
+	numHorizons
+)
+
 var (
 	// EstimateFeeDatabaseKey is the key that we use to
 	// store the fee estimator in the database.
 	EstimateFeeDatabaseKey = []byte("estimatefee")
 )
 
-// SatoshiPerByte is number with units of satoshis per byte.
-type SatoshiPerByte float64
-
 // BtcPerKilobyte is number with units of bitcoins per kilobyte.
 type BtcPerKilobyte float64
 
-// ToBtcPerKb returns a float value that represents the given
-// SatoshiPerByte converted to satoshis per kb.
-func (rate SatoshiPerByte) ToBtcPerKb() BtcPerKilobyte {
-	// If our rate is the error value, return that.
-	if rate == SatoshiPerByte(-1.0) {
-		return -1.0
+// feeBuckets returns the upper bounds in satoshi per kB of the fee rate buckets
+// transactions are tracked in, in ascending order.
+func feeBuckets() []float64 {
+	var buckets []float64
+	for bound := float64(minBucketFeeRate); bound <= maxBucketFeeRate; bound *= feeBucketSpacing {
+		buckets = append(buckets, bound)
 	}
+	return append(buckets, math.Inf(1))
+}
 
-	return BtcPerKilobyte(float64(rate) * bytePerKb * btcPerSatoshi)
+// txConfirmStats tracks how many blocks it took transactions in each fee rate
+// bucket to be confirmed over a horizon.  The numbers of transactions are kept
+// as moving averages which decay every block so recent blocks weigh the most.
+type txConfirmStats struct {
+	// buckets holds the upper bounds of the fee rate buckets.
+	buckets []float64
+
+	// decay is the factor the moving averages decay by every block.
+	decay float64
+
+	// scale is the number of blocks per period.
+	scale int32
+
+	// confAvg holds the average number of transactions in each bucket that
+	// were confirmed within each number of periods, starting at one, while
+	// failAvg holds those that left the pool without being confirmed after
+	// at least that many periods.
+	confAvg [][]float64
+	failAvg [][]float64
+
+	// txCtAvg holds the average number of transactions in each bucket that
+	// were confirmed, and feeRateAvg holds their fee rates in total.
+	txCtAvg    []float64
+	feeRateAvg []float64
+
+	// unconfTxs holds the number of transactions in each bucket that are
+	// still unconfirmed for every height they entered the pool at, indexed
+	// by the height modulo the maximum number of blocks tracked.
+	// oldUnconfTxs holds those that entered the pool longer ago.
+	unconfTxs    [][]int
+	oldUnconfTxs []int
 }
 
-// Fee returns the fee for a transaction of a given size for
-// the given fee rate.
-func (rate SatoshiPerByte) Fee(size uint32) btcutil.Amount {
-	// If our rate is the error value, return that.
-	if rate == SatoshiPerByte(-1) {
-		return btcutil.Amount(-1)
+// newTxConfirmStats returns statistics for the passed buckets over a horizon
+// with the passed decay and number of periods of scale blocks.
+func newTxConfirmStats(buckets []float64, decay float64, scale,
+	periods int32) *txConfirmStats {
+
+	newMatrix := func(rows int32) [][]float64 {
+		matrix := make([][]float64, rows)
+		for i := range matrix {
+			matrix[i] = make([]float64, len(buckets))
+		}
+		return matrix
+	}
+	unconfTxs := make([][]int, scale*periods)
+	for i := range unconfTxs {
+		unconfTxs[i] = make([]int, len(buckets))
+	}
+	return &txConfirmStats{
+		buckets:      buckets,
+		decay:        decay,
+		scale:        scale,
+		confAvg:      newMatrix(periods),
+		failAvg:      newMatrix(periods),
+		txCtAvg:      make([]float64, len(buckets)),
+		feeRateAvg:   make([]float64, len(buckets)),
+		unconfTxs:    unconfTxs,
+		oldUnconfTxs: make([]int, len(buckets)),
 	}
+}
 
-	return btcutil.Amount(float64(rate) * float64(size))
+// maxConfirms returns the highest number of blocks confirmations are tracked
+// for.
+func (s *txConfirmStats) maxConfirms() int32 {
+	return s.scale * int32(len(s.confAvg))
 }
 
-// NewSatoshiPerByte creates a SatoshiPerByte from an Amount and a
-// size in bytes.
-func NewSatoshiPerByte(fee btcutil.Amount, size uint32) SatoshiPerByte {
-	return SatoshiPerByte(float64(fee) / float64(size))
+// newTx tracks an unconfirmed transaction in the passed bucket that entered the
+// pool at the passed height.
+func (s *txConfirmStats) newTx(height int32, bucket int) {
+	s.unconfTxs[height%s.maxConfirms()][bucket]++
 }
 
-// observedTransaction represents an observed transaction and some
-// additional data required for the fee estimation algorithm.
-type observedTransaction struct {
-	// A transaction hash.
-	hash chainhash.Hash
+// removeTx stops tracking an unconfirmed transaction in the passed bucket that
+// entered the pool at the passed height.  When it is not removed since it was
+// confirmed, it counts as a failure to be confirmed for every full period it
+// spent in the pool.
+func (s *txConfirmStats) removeTx(entryHeight, bestHeight int32, bucket int,
+	inBlock bool) {
 
-	// The fee per byte of the transaction in satoshis.
-	feeRate SatoshiPerByte
+	blocksAgo := bestHeight - entryHeight
+	if blocksAgo < 0 {
+		return
+	}
+	if blocksAgo >= s.maxConfirms() {
+		if s.oldUnconfTxs[bucket] > 0 {
+			s.oldUnconfTxs[bucket]--
+		}
+	} else {
+		unconfTxs := s.unconfTxs[entryHeight%s.maxConfirms()]
+		if unconfTxs[bucket] > 0 {
+			unconfTxs[bucket]--
+		}
+	}
 
-	// The block height when it was observed.
-	observed int32
+	if inBlock {
+		return
+	}
+	periodsAgo := blocksAgo / s.scale
+	for i := int32(0); i < periodsAgo && i < int32(len(s.failAvg)); i++ {
+		s.failAvg[i][bucket]++
+	}
+}
 
-	// The height of the block in which it was mined.
-	// If the transaction has not yet been mined, it is zero.
-	mined int32
+// clearCurrent moves the unconfirmed transactions that entered the pool too
+// long ago to be tracked by height as of the passed height to the old ones.
+func (s *txConfirmStats) clearCurrent(height int32) {
+	unconfTxs := s.unconfTxs[height%s.maxConfirms()]
+	for i := range unconfTxs {
+		s.oldUnconfTxs[i] += unconfTxs[i]
+		unconfTxs[i] = 0
+	}
 }
 
-func (o *observedTransaction) Serialize(w io.Writer) {
-	binary.Write(w, binary.BigEndian, o.hash)
-	binary.Write(w, binary.BigEndian, o.feeRate)
-	binary.Write(w, binary.BigEndian, o.observed)
-	binary.Write(w, binary.BigEndian, o.mined)
+// updateMovingAverages decays all of the moving averages by one block.
+func (s *txConfirmStats) updateMovingAverages() {
+	for i := range s.buckets {
+		for j := range s.confAvg {
+			s.confAvg[j][i] *= s.decay
+			s.failAvg[j][i] *= s.decay
+		}
+		s.txCtAvg[i] *= s.decay
+		s.feeRateAvg[i] *= s.decay
+	}
 }
 
-func deserializeObservedTransaction(r io.Reader) (*observedTransaction, error) {
-	ot := observedTransaction{}
+// record adds a transaction with the passed fee rate in satoshi per kB that was
+// confirmed in the passed number of blocks, starting at one.
+func (s *txConfirmStats) record(blocksToConfirm int32, feeRate float64) {
+	if blocksToConfirm < 1 {
+		return
+	}
+	periodsToConfirm := (blocksToConfirm + s.scale - 1) / s.scale
+	bucket := sort.SearchFloat64s(s.buckets, feeRate)
+	for i := periodsToConfirm; i <= int32(len(s.confAvg)); i++ {
+		s.confAvg[i-1][bucket]++
+	}
+	s.txCtAvg[bucket]++
+	s.feeRateAvg[bucket] += feeRate
+}
 
-	// The first 32 bytes should be a hash.
-	binary.Read(r, binary.BigEndian, &ot.hash)
+// estimateMedianVal returns the lowest fee rate in satoshi per kB for which at
+// least the passed fraction of transactions were confirmed within the passed
+// number of blocks as of the passed height, or -1 when there is not enough
+// data.
+//
+// Starting with the highest fee rates, buckets are combined into ranges until
+// enough transactions per block were confirmed in them, which is the passed
+// value scaled by the decay.  The fee rate reported is the average fee rate of
+// the bucket with the median transaction of the last range that succeeded.
+func (s *txConfirmStats) estimateMedianVal(confTarget int32,
+	sufficientTxVal, successBreakPoint float64, height int32) float64 {
+
+	periodTarget := (confTarget + s.scale - 1) / s.scale
+	maxBucket := len(s.buckets) - 1
+
+	var (
+		nConf, totalNum, failNum      float64
+		extraNum                      int
+		curNearBucket, curFarBucket   = maxBucket, maxBucket
+		bestNearBucket, bestFarBucket = maxBucket, maxBucket
+		foundAnswer                   bool
+		newBucketRange                = true
+	)
+	for bucket := maxBucket; bucket >= 0; bucket-- {
+		if newBucketRange {
+			curNearBucket = bucket
+			newBucketRange = false
+		}
+		curFarBucket = bucket
+		nConf += s.confAvg[periodTarget-1][bucket]
+		totalNum += s.txCtAvg[bucket]
+		failNum += s.failAvg[periodTarget-1][bucket]
+
+		// Transactions still in the pool after the target count
+		// against the success rate as well.
+		for confs := confTarget; confs < s.maxConfirms(); confs++ {
+			if confs > height {
+				break
+			}
+			index := (height - confs) % s.maxConfirms()
+			extraNum += s.unconfTxs[index][bucket]
+		}
+		extraNum += s.oldUnconfTxs[bucket]
 
-	// The next 8 are SatoshiPerByte
-	binary.Read(r, binary.BigEndian, &ot.feeRate)
+		// Only test the success rate once enough transactions were
+		// confirmed in the range.
+		if totalNum < sufficientTxVal/(1-s.decay) {
+			continue
+		}
+		curPct := nConf / (totalNum + failNum + float64(extraNum))
+		if curPct < successBreakPoint {
+			continue
+		}
 
-	// And next there are two uint32's.
-	binary.Read(r, binary.BigEndian, &ot.observed)
-	binary.Read(r, binary.BigEndian, &ot.mined)
+		// Remember the range and start a new one.
+		foundAnswer = true
+		bestNearBucket = curNearBucket
+		bestFarBucket = curFarBucket
+		nConf, totalNum, failNum, extraNum = 0, 0, 0, 0
+		newBucketRange = true
+	}
+	if !foundAnswer {
+		return -1
+	}
 
-	return &ot, nil
+	// The fee rates of individual transactions are not stored, so the
+	// average fee rate of the bucket holding the median transaction of the
+	// range is reported instead.
+	var txSum float64
+	for i := bestFarBucket; i <= bestNearBucket; i++ {
+		txSum += s.txCtAvg[i]
+	}
+	if txSum == 0 {
+		return -1
+	}
+	txSum /= 2
+	for i := bestFarBucket; i <= bestNearBucket; i++ {
+		if s.txCtAvg[i] < txSum {
+			txSum -= s.txCtAvg[i]
+			continue
+		}
+		return s.feeRateAvg[i] / s.txCtAvg[i]
+	}
+	return -1
 }
 
-// registeredBlock has the hash of a block and the list of transactions
-// it mined which had been previously observed by the FeeEstimator. It
-// is used if Rollback is called to reverse the effect of registering
-// a block.
-type registeredBlock struct {
-	hash         chainhash.Hash
-	transactions []*observedTransaction
-}
+// trackedTx describes an unconfirmed transaction tracked by the fee estimator.
+type trackedTx struct {
+	// height is the height of the main chain when the transaction entered
+	// the pool.
+	height int32
 
-func (rb *registeredBlock) serialize(w io.Writer, txs map[*observedTransaction]uint32) {
-	binary.Write(w, binary.BigEndian, rb.hash)
+	// bucket is the index of the fee rate bucket of the transaction.
+	bucket int
 
-	binary.Write(w, binary.BigEndian, uint32(len(rb.transactions)))
-	for _, o := range rb.transactions {
-		binary.Write(w, binary.BigEndian, txs[o])
-	}
+	// feeRate is the fee rate of the transaction in satoshi per kB.
+	feeRate float64
 }
 
-// FeeEstimator manages the data necessary to create
-// fee estimations. It is safe for concurrent access.
+// FeeEstimator estimates the fee rates transactions must pay to be confirmed
+// within a number of blocks.  It tracks how many blocks it takes transactions
+// that enter the memory pool to be confirmed in exponentially spaced fee rate
+// buckets over a short, medium and long horizon, weighing recent blocks the
+// most.  It is safe for concurrent access.
 type FeeEstimator struct {
-	maxRollback uint32
-	binSize     int32
+	mtx sync.Mutex
 
-	// The maximum number of replacements that can be made in a single
-	// bin per block. Default is estimateFeeMaxReplacements
-	maxReplacements int32
+	// stats holds the confirmation statistics for each horizon.
+	stats [numHorizons]*txConfirmStats
 
-	// The minimum number of blocks that can be registered with the fee
-	// estimator before it will provide answers.
-	minRegisteredBlocks uint32
+	// tracked holds the unconfirmed transactions that are tracked.
+	tracked map[chainhash.Hash]trackedTx
 
-	// The last known height.
-	lastKnownHeight int32
+	// bestHeight is the height of the last block that was registered.
+	bestHeight int32
 
-	// The number of blocks that have been registered.
-	numBlocksRegistered uint32
+	// firstRecordedHeight is the height of the first block registered in
+	// this session that confirmed tracked transactions, or zero if none
+	// did yet.
+	firstRecordedHeight int32
 
-	mtx      sync.RWMutex
-	observed map[chainhash.Hash]*observedTransaction
-	bin      [estimateFeeDepth][]*observedTransaction
-
-	// The cached estimates.
-	cached []SatoshiPerByte
-
-	// Transactions that have been removed from the bins. This allows us to
-	// revert in case of an orphaned block.
-	dropped []*registeredBlock
+	// historicalFirst and historicalBest delimit the span of blocks the
+	// restored state of the fee estimator covers, or are zero if there is
+	// no such state.
+	historicalFirst int32
+	historicalBest  int32
 }
 
-// NewFeeEstimator creates a FeeEstimator for which at most maxRollback blocks
-// can be unregistered and which returns an error unless minRegisteredBlocks
-// have been registered with it.
-func NewFeeEstimator(maxRollback, minRegisteredBlocks uint32) *FeeEstimator {
+// NewFeeEstimator returns a new fee estimator that has not tracked any
+// transactions yet.
+func NewFeeEstimator() *FeeEstimator {
+	buckets := feeBuckets()
 	return &FeeEstimator{
-		maxRollback:         maxRollback,
-		minRegisteredBlocks: minRegisteredBlocks,
-		lastKnownHeight:     mining.UnminedHeight,
-		binSize:             estimateFeeBinSize,
-		maxReplacements:     estimateFeeMaxReplacements,
-		observed:            make(map[chainhash.Hash]*observedTransaction),
-		dropped:             make([]*registeredBlock, 0, maxRollback),
+		stats: [numHorizons]*txConfirmStats{
+			shortHorizon: newTxConfirmStats(buckets, shortDecay,
+				shortScale, shortPeriods),
+			mediumHorizon: newTxConfirmStats(buckets, mediumDecay,
+				mediumScale, mediumPeriods),
+			longHorizon: newTxConfirmStats(buckets, longDecay,
+				longScale, longPeriods),
+		},
+		tracked: make(map[chainhash.Hash]trackedTx),
 	}
 }
 
-// ObserveTransaction is called when a new transaction is observed in the mempool.
+// ObserveTransaction is called when a new transaction enters the mempool.  It
+// is only tracked when it entered the pool as of the last block that was
+// registered.
 func (ef *FeeEstimator) ObserveTransaction(t *TxDesc) {
 	ef.mtx.Lock()
 	defer ef.mtx.Unlock()
 
-	// If we haven't seen a block yet we don't know when this one arrived,
-	// so we ignore it.
-	if ef.lastKnownHeight == mining.UnminedHeight {
+	hash := *t.Tx.Hash()
+	if _, ok := ef.tracked[hash]; ok {
 		return
 	}
 
-	hash := *t.Tx.Hash()
-	if _, ok := ef.observed[hash]; !ok {
-		size := uint32(GetTxVirtualSize(t.Tx))
-
-		ef.observed[hash] = &observedTransaction{
-			hash:     hash,
-			feeRate:  NewSatoshiPerByte(btcutil.Amount(t.Fee), size),
-			observed: t.Height,
-			mined:    mining.UnminedHeight,
-		}
+	// Transactions that entered the pool as of a different height are
+	// ignored since it is not known how long they have been waiting for.
+	if t.Height != ef.bestHeight {
+		return
 	}
-}
 
-// RegisterBlock informs the fee estimator of a new block to take into account.
-func (ef *FeeEstimator) RegisterBlock(block *btcutil.Block) error {
-	ef.mtx.Lock()
-	defer ef.mtx.Unlock()
-
-	// The previous sorted list is invalid, so delete it.
-	ef.cached = nil
-
-	height := block.Height()
-	if height != ef.lastKnownHeight+1 && ef.lastKnownHeight != mining.UnminedHeight {
-		return fmt.Errorf("intermediate block not recorded; current height is %d; new height is %d",
-			ef.lastKnownHeight, height)
+	feeRate := float64(t.Fee) * bytePerKb /
+		float64(GetTxVirtualSize(t.Tx))
+	bucket := sort.SearchFloat64s(ef.stats[shortHorizon].buckets, feeRate)
+	for _, stats := range ef.stats {
+		stats.newTx(t.Height, bucket)
 	}
-
-	// Update the last known height.
-	ef.lastKnownHeight = height
-	ef.numBlocksRegistered++
-
-	// Randomly order txs in block.
-	transactions := make(map[*btcutil.Tx]struct{})
-	for _, t := range block.Transactions() {
-		transactions[t] = struct{}{}
+	ef.tracked[hash] = trackedTx{
+		height:  t.Height,
+		bucket:  bucket,
+		feeRate: feeRate,
 	}
+}
 
-	// Count the number of replacements we make per bin so that we don't
-	// replace too many.
-	var replacementCounts [estimateFeeDepth]int
+// removeTx stops tracking the transaction with the passed hash, if it is
+// tracked, and returns its state.
+//
+// This function MUST be called with the fee estimator lock held.
+func (ef *FeeEstimator) removeTx(hash *chainhash.Hash,
+	inBlock bool) (trackedTx, bool) {
 
-	// Keep track of which txs were dropped in case of an orphan block.
-	dropped := &registeredBlock{
-		hash:         *block.Hash(),
-		transactions: make([]*observedTransaction, 0, 100),
+	tx, ok := ef.tracked[*hash]
+	if !ok {
+		return tx, false
 	}
+	for _, stats := range ef.stats {
+		stats.removeTx(tx.height, ef.bestHeight, tx.bucket, inBlock)
+	}
+	delete(ef.tracked, *hash)
+	return tx, true
+}
 
-	// Go through the txs in the block.
-	for t := range transactions {
-		hash := *t.Hash()
-
-		// Have we observed this tx in the mempool?
-		o, ok := ef.observed[hash]
-		if !ok {
-			continue
-		}
+// RemoveTransaction is called when a transaction leaves the mempool without
+// being confirmed, such as when it is replaced or evicted.  It counts as a
+// failure to be confirmed in time when it was tracked.
+func (ef *FeeEstimator) RemoveTransaction(hash *chainhash.Hash) {
+	ef.mtx.Lock()
+	ef.removeTx(hash, false)
+	ef.mtx.Unlock()
+}
 
-		// Put the observed tx in the oppropriate bin.
-		blocksToConfirm := height - o.observed - 1
+// RegisterBlock informs the fee estimator of a new block connected to the main
+// chain.  It must be called before the transactions it confirms are removed
+// from the mempool so they are not counted as failures to be confirmed.  The
+// confirmations of blocks that don't extend the highest block registered so
+// far, such as during reorganizations, are not recorded, but the transactions
+// they confirm are no longer tracked either way.
+func (ef *FeeEstimator) RegisterBlock(block *btcutil.Block) {
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
 
-		// This shouldn't happen if the fee estimator works correctly,
-		// but return an error if it does.
-		if o.mined != mining.UnminedHeight {
-			log.Error("Estimate fee: transaction ", hash.String(), " has already been mined")
-			return errors.New("Transaction has already been mined")
+	height := block.Height()
+	if height <= ef.bestHeight {
+		var untracked int
+		for _, tx := range block.Transactions() {
+			if _, ok := ef.removeTx(tx.Hash(), true); ok {
+				untracked++
+			}
 		}
+		log.Debugf("Fee estimator registered block %d below the best "+
+			"height %d without recording %d confirmed transactions",
+			height, ef.bestHeight, untracked)
+		return
+	}
 
-		// This shouldn't happen but check just in case to avoid
-		// an out-of-bounds array index later.
-		//
-		// Also check that blocksToConfirm is not negative as this causes
-		// the node to crash on reorgs.  A tx that was observed at height X
-		// might be included in heights less than X because of chain reorgs.
-		// Refer to github.com/btcsuite/btcd/issues/1660 for more information.
-		//
-		// TODO(kcalvinalvin) a better design that doesn't just skip over the
-		// transaction would result in a more accurate fee estimator.  Properly
-		// implement this later.
-		if blocksToConfirm >= estimateFeeDepth || blocksToConfirm < 0 {
-			continue
-		}
+	// The best height must be updated along with clearing the current
+	// unconfirmed transactions so removing the confirmed ones accounts for
+	// the age of the transactions properly.
+	ef.bestHeight = height
+	for _, stats := range ef.stats {
+		stats.clearCurrent(height)
+		stats.updateMovingAverages()
+	}
 
-		// Make sure we do not replace too many transactions per min.
-		if replacementCounts[blocksToConfirm] == int(ef.maxReplacements) {
+	var counted int
+	for _, tx := range block.Transactions() {
+		tracked, ok := ef.removeTx(tx.Hash(), true)
+		if !ok {
 			continue
 		}
-
-		o.mined = height
-
-		replacementCounts[blocksToConfirm]++
-
-		bin := ef.bin[blocksToConfirm]
-
-		// Remove a random element and replace it with this new tx.
-		if len(bin) == int(ef.binSize) {
-			// Don't drop transactions we have just added from this same block.
-			l := int(ef.binSize) - replacementCounts[blocksToConfirm]
-			drop := rand.Intn(l)
-			dropped.transactions = append(dropped.transactions, bin[drop])
-
-			bin[drop] = bin[l-1]
-			bin[l-1] = o
-		} else {
-			bin = append(bin, o)
+		blocksToConfirm := height - tracked.height
+		if blocksToConfirm <= 0 {
+			continue
 		}
-		ef.bin[blocksToConfirm] = bin
-	}
-
-	// Go through the mempool for txs that have been in too long.
-	for hash, o := range ef.observed {
-		if o.mined == mining.UnminedHeight && height-o.observed >= estimateFeeDepth {
-			delete(ef.observed, hash)
+		for _, stats := range ef.stats {
+			stats.record(blocksToConfirm, tracked.feeRate)
 		}
+		counted++
 	}
 
-	// Add dropped list to history.
-	if ef.maxRollback == 0 {
-		return nil
+	if ef.firstRecordedHeight == 0 && counted > 0 {
+		ef.firstRecordedHeight = height
 	}
 
-	if uint32(len(ef.dropped)) == ef.maxRollback {
-		ef.dropped = append(ef.dropped[1:], dropped)
-	} else {
-		ef.dropped = append(ef.dropped, dropped)
-	}
-
-	return nil
+	log.Debugf("Fee estimator registered block %d confirming %d of %d "+
+		"tracked transactions", height, counted, counted+len(ef.tracked))
 }
 
-// LastKnownHeight returns the height of the last block which was registered.
-func (ef *FeeEstimator) LastKnownHeight() int32 {
-	ef.mtx.Lock()
-	defer ef.mtx.Unlock()
-
-	return ef.lastKnownHeight
+// blockSpan returns the number of blocks registered in this session since the
+// first one that confirmed tracked transactions.
+//
+// This function MUST be called with the fee estimator lock held.
+func (ef *FeeEstimator) blockSpan() int32 {
+	if ef.firstRecordedHeight == 0 {
+		return 0
+	}
+	return ef.bestHeight - ef.firstRecordedHeight
 }
 
-// Rollback unregisters a recently registered block from the FeeEstimator.
-// This can be used to reverse the effect of an orphaned block on the fee
-// estimator. The maximum number of rollbacks allowed is given by
-// maxRollbacks.
+// historicalBlockSpan returns the number of blocks covered by the restored
+// state of the fee estimator, which is zero when it was recorded too long ago.
 //
-// Note: not everything can be rolled back because some transactions are
-// deleted if they have been observed too long ago. That means the result
-// of Rollback won't always be exactly the same as if the last block had not
-// happened, but it should be close enough.
-func (ef *FeeEstimator) Rollback(hash *chainhash.Hash) error {
-	ef.mtx.Lock()
-	defer ef.mtx.Unlock()
-
-	// Find this block in the stack of recent registered blocks.
-	var n int
-	for n = 1; n <= len(ef.dropped); n++ {
-		if ef.dropped[len(ef.dropped)-n].hash.IsEqual(hash) {
-			break
-		}
+// This function MUST be called with the fee estimator lock held.
+func (ef *FeeEstimator) historicalBlockSpan() int32 {
+	if ef.historicalFirst == 0 {
+		return 0
 	}
-
-	if n > len(ef.dropped) {
-		return errors.New("no such block was recently registered")
+	if ef.bestHeight-ef.historicalBest > oldestEstimateHistory {
+		return 0
 	}
+	return ef.historicalBest - ef.historicalFirst
+}
 
-	for i := 0; i < n; i++ {
-		ef.rollback()
+// maxUsableEstimate returns the highest confirmation target that can be
+// estimated given the number of blocks observed, which is half of them.
+//
+// This function MUST be called with the fee estimator lock held.
+func (ef *FeeEstimator) maxUsableEstimate() int32 {
+	span := ef.blockSpan()
+	if historicalSpan := ef.historicalBlockSpan(); historicalSpan > span {
+		span = historicalSpan
 	}
-
-	return nil
+	if span/2 < MaxFeeEstimateTarget {
+		return span / 2
+	}
+	return MaxFeeEstimateTarget
 }
 
-// rollback rolls back the effect of the last block in the stack
-// of registered blocks.
-func (ef *FeeEstimator) rollback() {
-	// The previous sorted list is invalid, so delete it.
-	ef.cached = nil
-
-	// pop the last list of dropped txs from the stack.
-	last := len(ef.dropped) - 1
-	if last == -1 {
-		// Cannot really happen because the exported calling function
-		// only rolls back a block already known to be in the list
-		// of dropped transactions.
-		return
+// sufficientTxs returns the number of transactions per block required for the
+// passed horizon to evaluate a range of fee rate buckets.
+func sufficientTxs(horizon int) float64 {
+	if horizon == shortHorizon {
+		return sufficientTxsShort
 	}
+	return sufficientFeeTxs
+}
 
-	dropped := ef.dropped[last]
-
-	// where we are in each bin as we replace txs?
-	var replacementCounters [estimateFeeDepth]int
-
-	// Go through the txs in the dropped block.
-	for _, o := range dropped.transactions {
-		// Which bin was this tx in?
-		blocksToConfirm := o.mined - o.observed - 1
-
-		bin := ef.bin[blocksToConfirm]
-
-		var counter = replacementCounters[blocksToConfirm]
-
-		// Continue to go through that bin where we left off.
-		for {
-			if counter >= len(bin) {
-				// Panic, as we have entered an unrecoverable invalid state.
-				panic(errors.New("illegal state: cannot rollback dropped transaction"))
-			}
-
-			prev := bin[counter]
-
-			if prev.mined == ef.lastKnownHeight {
-				prev.mined = mining.UnminedHeight
-
-				bin[counter] = o
-
-				counter++
-				break
-			}
+// estimateCombinedFee returns the fee rate estimate in satoshi per kB for the
+// passed confirmation target and success threshold from the shortest horizon
+// that tracks the target, or -1 when there is not enough data.  When
+// checkShorter is set, the estimates of shorter horizons for the highest target
+// they track are used instead when they are lower since their data is more
+// recent.
+//
+// This function MUST be called with the fee estimator lock held.
+func (ef *FeeEstimator) estimateCombinedFee(confTarget int32,
+	successThreshold float64, checkShorter bool) float64 {
 
-			counter++
-		}
+	horizon := shortHorizon
+	for horizon < longHorizon &&
+		confTarget > ef.stats[horizon].maxConfirms() {
 
-		replacementCounters[blocksToConfirm] = counter
+		horizon++
 	}
+	stats := ef.stats[horizon]
+	if confTarget < 1 || confTarget > stats.maxConfirms() {
+		return -1
+	}
+	estimate := stats.estimateMedianVal(confTarget, sufficientTxs(horizon),
+		successThreshold, ef.bestHeight)
 
-	// Continue going through bins to find other txs to remove
-	// which did not replace any other when they were entered.
-	for i, j := range replacementCounters {
-		for {
-			l := len(ef.bin[i])
-			if j >= l {
-				break
-			}
-
-			prev := ef.bin[i][j]
-
-			if prev.mined == ef.lastKnownHeight {
-				prev.mined = mining.UnminedHeight
-
-				newBin := append(ef.bin[i][0:j], ef.bin[i][j+1:l]...)
-				// TODO This line should prevent an unintentional memory
-				// leak but it causes a panic when it is uncommented.
-				// ef.bin[i][j] = nil
-				ef.bin[i] = newBin
-
-				continue
-			}
+	if !checkShorter {
+		return estimate
+	}
+	for shorter := horizon - 1; shorter >= shortHorizon; shorter-- {
+		stats := ef.stats[shorter]
+		shorterEstimate := stats.estimateMedianVal(stats.maxConfirms(),
+			sufficientTxs(shorter), successThreshold, ef.bestHeight)
+		if shorterEstimate > 0 &&
+			(estimate == -1 || shorterEstimate < estimate) {
 
-			j++
+			estimate = shorterEstimate
 		}
 	}
-
-	ef.dropped = ef.dropped[0:last]
-
-	// The number of blocks the fee estimator has seen is decrimented.
-	ef.numBlocksRegistered--
-	ef.lastKnownHeight--
+	return estimate
 }
 
-// estimateFeeSet is a set of txs that can that is sorted
-// by the fee per kb rate.
-type estimateFeeSet struct {
-	feeRate []SatoshiPerByte
-	bin     [estimateFeeDepth]uint32
-}
-
-func (b *estimateFeeSet) Len() int { return len(b.feeRate) }
-
-func (b *estimateFeeSet) Less(i, j int) bool {
-	return b.feeRate[i] > b.feeRate[j]
-}
-
-func (b *estimateFeeSet) Swap(i, j int) {
-	b.feeRate[i], b.feeRate[j] = b.feeRate[j], b.feeRate[i]
+// estimateConservativeFee returns the highest fee rate estimate in satoshi per
+// kB of the medium and long horizons for the passed target, which is twice the
+// requested confirmation target, or -1 when there is not enough data.  This
+// ensures the estimate is not lower than fee rates required over longer time
+// frames.
+//
+// This function MUST be called with the fee estimator lock held.
+func (ef *FeeEstimator) estimateConservativeFee(doubleTarget int32) float64 {
+	estimate := -1.0
+	if doubleTarget <= ef.stats[shortHorizon].maxConfirms() {
+		estimate = ef.stats[mediumHorizon].estimateMedianVal(
+			doubleTarget, sufficientFeeTxs, doubleSuccessPct,
+			ef.bestHeight)
+	}
+	if doubleTarget <= ef.stats[mediumHorizon].maxConfirms() {
+		longEstimate := ef.stats[longHorizon].estimateMedianVal(
+			doubleTarget, sufficientFeeTxs, doubleSuccessPct,
+			ef.bestHeight)
+		if longEstimate > estimate {
+			estimate = longEstimate
+		}
+	}
+	return estimate
 }
 
-// estimateFee returns the estimated fee for a transaction
-// to confirm in confirmations blocks from now, given
-// the data set we have collected.
-func (b *estimateFeeSet) estimateFee(confirmations int) SatoshiPerByte {
-	if confirmations <= 0 {
-		return SatoshiPerByte(math.Inf(1))
-	}
+// EstimateSmartFee estimates the fee rate a transaction must pay to be
+// confirmed within the passed number of blocks, which must be between 1 and
+// MaxFeeEstimateTarget.  It returns the estimate along with the confirmation
+// target it is actually for, which is lower than the requested one when not
+// enough blocks have been observed to estimate it, and at least 2.  The fee
+// rate is -1 when there is not enough data.
+//
+// The estimate is the highest fee rate required for transactions to be
+// confirmed within half of the target, the target itself and twice the target
+// with increasing certainty.  In conservative mode, the fee rates required over
+// the longer horizons for twice the target are taken into account as well,
+// which makes the estimate less responsive to short-term drops in fee rates.
+func (ef *FeeEstimator) EstimateSmartFee(confTarget uint32,
+	conservative bool) (BtcPerKilobyte, uint32, error) {
 
-	if confirmations > estimateFeeDepth {
-		return 0
+	if confTarget < 1 || confTarget > MaxFeeEstimateTarget {
+		return -1, 0, fmt.Errorf("confirmation target must be between "+
+			"1 and %d", MaxFeeEstimateTarget)
 	}
 
-	// We don't have any transactions!
-	if len(b.feeRate) == 0 {
-		return 0
-	}
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
 
-	var min, max int = 0, 0
-	for i := 0; i < confirmations-1; i++ {
-		min += int(b.bin[i])
+	// Transactions can't reasonably be estimated to be confirmed in the
+	// next block, so a target of one is treated as two.
+	target := int32(confTarget)
+	if target == 1 {
+		target = 2
 	}
-
-	max = min + int(b.bin[confirmations-1]) - 1
-	if max < min {
-		max = min
+	if maxUsable := ef.maxUsableEstimate(); target > maxUsable {
+		target = maxUsable
 	}
-	feeIndex := (min + max) / 2
-	if feeIndex >= len(b.feeRate) {
-		feeIndex = len(b.feeRate) - 1
+	if target <= 1 {
+		return -1, uint32(target), nil
 	}
 
-	return b.feeRate[feeIndex]
-}
-
-// newEstimateFeeSet creates a temporary data structure that
-// can be used to find all fee estimates.
-func (ef *FeeEstimator) newEstimateFeeSet() *estimateFeeSet {
-	set := &estimateFeeSet{}
-
-	capacity := 0
-	for i, b := range ef.bin {
-		l := len(b)
-		set.bin[i] = uint32(l)
-		capacity += l
+	median := ef.estimateCombinedFee(target/2, halfSuccessPct, true)
+	actual := ef.estimateCombinedFee(target, successPct, true)
+	if actual > median {
+		median = actual
 	}
-
-	set.feeRate = make([]SatoshiPerByte, capacity)
-
-	i := 0
-	for _, b := range ef.bin {
-		for _, o := range b {
-			set.feeRate[i] = o.feeRate
-			i++
+	double := ef.estimateCombinedFee(target*2, doubleSuccessPct,
+		!conservative)
+	if double > median {
+		median = double
+	}
+	if conservative || median == -1 {
+		consEstimate := ef.estimateConservativeFee(target * 2)
+		if consEstimate > median {
+			median = consEstimate
 		}
 	}
-
-	sort.Sort(set)
-
-	return set
-}
-
-// estimates returns the set of all fee estimates from 1 to estimateFeeDepth
-// confirmations from now.
-func (ef *FeeEstimator) estimates() []SatoshiPerByte {
-	set := ef.newEstimateFeeSet()
-
-	estimates := make([]SatoshiPerByte, estimateFeeDepth)
-	for i := 0; i < estimateFeeDepth; i++ {
-		estimates[i] = set.estimateFee(i + 1)
+	if median < 0 {
+		return -1, uint32(target), nil
 	}
 
-	return estimates
+	return BtcPerKilobyte(math.Round(median) * btcPerSatoshi),
+		uint32(target), nil
 }
 
-// EstimateFee estimates the fee per byte to have a tx confirmed a given
-// number of blocks from now.
+// EstimateFee estimates the fee rate a transaction must pay to be confirmed
+// within the passed number of blocks over the medium horizon with a high
+// certainty.  The fee rate is -1 when there is not enough data.
+//
+// Deprecated: Use EstimateSmartFee instead, which takes both shorter and longer
+// horizons into account.
 func (ef *FeeEstimator) EstimateFee(numBlocks uint32) (BtcPerKilobyte, error) {
-	ef.mtx.Lock()
-	defer ef.mtx.Unlock()
-
-	// If the number of registered blocks is below the minimum, return
-	// an error.
-	if ef.numBlocksRegistered < ef.minRegisteredBlocks {
-		return -1, errors.New("not enough blocks have been observed")
-	}
-
+	stats := ef.stats[mediumHorizon]
 	if numBlocks == 0 {
 		return -1, errors.New("cannot confirm transaction in zero blocks")
 	}
-
-	if numBlocks > estimateFeeDepth {
-		return -1, fmt.Errorf(
-			"can only estimate fees for up to %d blocks from now",
-			estimateFeeDepth)
-	}
-
-	// If there are no cached results, generate them.
-	if ef.cached == nil {
-		ef.cached = ef.estimates()
+	if numBlocks > uint32(stats.maxConfirms()) {
+		return -1, fmt.Errorf("can only estimate fees for up to %d "+
+			"blocks from now", stats.maxConfirms())
 	}
 
-	return ef.cached[int(numBlocks)-1].ToBtcPerKb(), nil
-}
-
-// In case the format for the serialized version of the FeeEstimator changes,
-// we use a version number. If the version number changes, it does not make
-// sense to try to upgrade a previous version to a new version. Instead, just
-// start fee estimation over.
-const estimateFeeSaveVersion = 1
-
-func deserializeRegisteredBlock(r io.Reader, txs map[uint32]*observedTransaction) (*registeredBlock, error) {
-	var lenTransactions uint32
-
-	rb := &registeredBlock{}
-	binary.Read(r, binary.BigEndian, &rb.hash)
-	binary.Read(r, binary.BigEndian, &lenTransactions)
-
-	rb.transactions = make([]*observedTransaction, lenTransactions)
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
 
-	for i := uint32(0); i < lenTransactions; i++ {
-		var index uint32
-		binary.Read(r, binary.BigEndian, &index)
-		rb.transactions[i] = txs[index]
+	if numBlocks == 1 {
+		return -1, nil
 	}
-
-	return rb, nil
+	feeRate := stats.estimateMedianVal(int32(numBlocks), sufficientFeeTxs,
+		doubleSuccessPct, ef.bestHeight)
+	if feeRate < 0 {
+		return -1, nil
+	}
+	return BtcPerKilobyte(math.Round(feeRate) * btcPerSatoshi), nil
 }
 
+// -----------------------------------------------------------------------------
+// The state of the fee estimator is saved in the following format, with all
+// integers and floating point numbers encoded in big endian:
+//
+//   <version><first height><last height><bucket count><buckets><horizon>...
+//
+//   Field          Type         Size
+//   version        uint32       4 bytes
+//   first height   int32        4 bytes
+//   last height    int32        4 bytes
+//   bucket count   uint32       4 bytes
+//   buckets        []float64    8 bytes per bucket
+//   horizon        see below    variable (one for each horizon)
+//
+// The first and last heights delimit the span of blocks the state covers.
+// Each horizon is encoded as follows:
+//
+//   <period count><tx count averages><fee rate averages>
+//   <confirmed averages>...<failed averages>...
+//
+//   Field                Type         Size
+//   period count         uint32       4 bytes
+//   tx count averages    []float64    8 bytes per bucket
+//   fee rate averages    []float64    8 bytes per bucket
+//   confirmed averages   []float64    8 bytes per bucket (one for each period)
+//   failed averages      []float64    8 bytes per bucket (one for each period)
+//
+// The unconfirmed transactions tracked are not saved since it is not known how
+// long the transactions in the mempool were waiting for once they are loaded
+// after a restart.
+// -----------------------------------------------------------------------------
+
 // FeeEstimatorState represents a saved FeeEstimator that can be
 // restored with data from an earlier session of the program.
 type FeeEstimatorState []byte
 
-// observedTxSet is a set of txs that can that is sorted
-// by hash. It exists for serialization purposes so that
-// a serialized state always comes out the same.
-type observedTxSet []*observedTransaction
-
-func (q observedTxSet) Len() int { return len(q) }
-
-func (q observedTxSet) Less(i, j int) bool {
-	return strings.Compare(q[i].hash.String(), q[j].hash.String()) < 0
-}
-
-func (q observedTxSet) Swap(i, j int) {
-	q[i], q[j] = q[j], q[i]
-}
-
 // Save records the current state of the FeeEstimator to a []byte that
 // can be restored later.
 func (ef *FeeEstimator) Save() FeeEstimatorState {
 	ef.mtx.Lock()
 	defer ef.mtx.Unlock()
 
-	// TODO figure out what the capacity should be.
-	w := bytes.NewBuffer(make([]byte, 0))
-
-	binary.Write(w, binary.BigEndian, uint32(estimateFeeSaveVersion))
-
-	// Insert basic parameters.
-	binary.Write(w, binary.BigEndian, &ef.maxRollback)
-	binary.Write(w, binary.BigEndian, &ef.binSize)
-	binary.Write(w, binary.BigEndian, &ef.maxReplacements)
-	binary.Write(w, binary.BigEndian, &ef.minRegisteredBlocks)
-	binary.Write(w, binary.BigEndian, &ef.lastKnownHeight)
-	binary.Write(w, binary.BigEndian, &ef.numBlocksRegistered)
-
-	// Put all the observed transactions in a sorted list.
-	var txCount uint32
-	ots := make([]*observedTransaction, len(ef.observed))
-	for hash := range ef.observed {
-		ots[txCount] = ef.observed[hash]
-		txCount++
+	// Record the span of blocks observed in this session unless the
+	// restored state covers a significantly longer span.
+	first, last := ef.historicalFirst, ef.historicalBest
+	if ef.blockSpan() > ef.historicalBlockSpan()/2 {
+		first, last = ef.firstRecordedHeight, ef.bestHeight
+	}
+
+	// Writing to a buffer can't fail.
+	var w bytes.Buffer
+	buckets := ef.stats[shortHorizon].buckets
+	binary.Write(&w, binary.BigEndian, uint32(estimateFeeSaveVersion))
+	binary.Write(&w, binary.BigEndian, first)
+	binary.Write(&w, binary.BigEndian, last)
+	binary.Write(&w, binary.BigEndian, uint32(len(buckets)))
+	binary.Write(&w, binary.BigEndian, buckets)
+	for _, stats := range ef.stats {
+		binary.Write(&w, binary.BigEndian, uint32(len(stats.confAvg)))
+		binary.Write(&w, binary.BigEndian, stats.txCtAvg)
+		binary.Write(&w, binary.BigEndian, stats.feeRateAvg)
+		for _, confAvg := range stats.confAvg {
+			binary.Write(&w, binary.BigEndian, confAvg)
+		}
+		for _, failAvg := range stats.failAvg {
+			binary.Write(&w, binary.BigEndian, failAvg)
+		}
 	}
 
-	sort.Sort(observedTxSet(ots))
+	return FeeEstimatorState(w.Bytes())
+}
 
-	txCount = 0
-	observed := make(map[*observedTransaction]uint32)
-	binary.Write(w, binary.BigEndian, uint32(len(ef.observed)))
-	for _, ot := range ots {
-		ot.Serialize(w)
-		observed[ot] = txCount
-		txCount++
+// readStats reads the moving averages of the passed horizon statistics written
+// by Save from the passed reader.
+func readStats(r io.Reader, stats *txConfirmStats) error {
+	var numPeriods uint32
+	if err := binary.Read(r, binary.BigEndian, &numPeriods); err != nil {
+		return err
 	}
-
-	// Save all the right bins.
-	for _, list := range ef.bin {
-
-		binary.Write(w, binary.BigEndian, uint32(len(list)))
-
-		for _, o := range list {
-			binary.Write(w, binary.BigEndian, observed[o])
-		}
+	if numPeriods != uint32(len(stats.confAvg)) {
+		return fmt.Errorf("unexpected number of periods %d, expected "+
+			"%d", numPeriods, len(stats.confAvg))
 	}
 
-	// Dropped transactions.
-	binary.Write(w, binary.BigEndian, uint32(len(ef.dropped)))
-	for _, registered := range ef.dropped {
-		registered.serialize(w, observed)
+	averages := [][]float64{stats.txCtAvg, stats.feeRateAvg}
+	averages = append(averages, stats.confAvg...)
+	averages = append(averages, stats.failAvg...)
+	for _, avg := range averages {
+		if err := binary.Read(r, binary.BigEndian, avg); err != nil {
+			return err
+		}
 	}
-
-	// Commit the tx and return.
-	return FeeEstimatorState(w.Bytes())
+	return nil
 }
 
 // RestoreFeeEstimator takes a FeeEstimatorState that was previously
 // returned by Save and restores it to a FeeEstimator
 func RestoreFeeEstimator(data FeeEstimatorState) (*FeeEstimator, error) {
-	r := bytes.NewReader([]byte(data))
+	r := bytes.NewReader(data)
 
-	// Check version
 	var version uint32
-	err := binary.Read(r, binary.BigEndian, &version)
-	if err != nil {
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
 		return nil, err
 	}
 	if version != estimateFeeSaveVersion {
-		return nil, fmt.Errorf("Incorrect version: expected %d found %d", estimateFeeSaveVersion, version)
-	}
-
-	ef := &FeeEstimator{
-		observed: make(map[chainhash.Hash]*observedTransaction),
+		return nil, fmt.Errorf("incorrect version: expected %d found %d",
+			estimateFeeSaveVersion, version)
 	}
 
-	// Read basic parameters.
-	binary.Read(r, binary.BigEndian, &ef.maxRollback)
-	binary.Read(r, binary.BigEndian, &ef.binSize)
-	binary.Read(r, binary.BigEndian, &ef.maxReplacements)
-	binary.Read(r, binary.BigEndian, &ef.minRegisteredBlocks)
-	binary.Read(r, binary.BigEndian, &ef.lastKnownHeight)
-	binary.Read(r, binary.BigEndian, &ef.numBlocksRegistered)
-
-	// Read transactions.
-	var numObserved uint32
-	observed := make(map[uint32]*observedTransaction)
-	binary.Read(r, binary.BigEndian, &numObserved)
-	for i := uint32(0); i < numObserved; i++ {
-		ot, err := deserializeObservedTransaction(r)
-		if err != nil {
+	// The best height is left unset so the next block is registered
+	// regardless of the height the state was saved at.
+	ef := NewFeeEstimator()
+	heights := []*int32{&ef.historicalFirst, &ef.historicalBest}
+	for _, height := range heights {
+		if err := binary.Read(r, binary.BigEndian, height); err != nil {
 			return nil, err
 		}
-		observed[i] = ot
-		ef.observed[ot.hash] = ot
-	}
-
-	// Read bins.
-	for i := 0; i < estimateFeeDepth; i++ {
-		var numTransactions uint32
-		binary.Read(r, binary.BigEndian, &numTransactions)
-		bin := make([]*observedTransaction, numTransactions)
-		for j := uint32(0); j < numTransactions; j++ {
-			var index uint32
-			binary.Read(r, binary.BigEndian, &index)
-
-			var exists bool
-			bin[j], exists = observed[index]
-			if !exists {
-				return nil, fmt.Errorf("Invalid transaction reference %d", index)
-			}
+	}
+	if ef.historicalFirst > ef.historicalBest {
+		return nil, fmt.Errorf("invalid span of blocks from %d to %d",
+			ef.historicalFirst, ef.historicalBest)
+	}
+
+	// The buckets must match the current ones.
+	buckets := ef.stats[shortHorizon].buckets
+	var numBuckets uint32
+	if err := binary.Read(r, binary.BigEndian, &numBuckets); err != nil {
+		return nil, err
+	}
+	if numBuckets != uint32(len(buckets)) {
+		return nil, fmt.Errorf("unexpected number of buckets %d, "+
+			"expected %d", numBuckets, len(buckets))
+	}
+	savedBuckets := make([]float64, numBuckets)
+	if err := binary.Read(r, binary.BigEndian, savedBuckets); err != nil {
+		return nil, err
+	}
+	for i, bucket := range savedBuckets {
+		if bucket != buckets[i] {
+			return nil, fmt.Errorf("unexpected bucket %d: %v, "+
+				"expected %v", i, bucket, buckets[i])
 		}
-		ef.bin[i] = bin
 	}
 
-	// Read dropped transactions.
-	var numDropped uint32
-	binary.Read(r, binary.BigEndian, &numDropped)
-	ef.dropped = make([]*registeredBlock, numDropped)
-	for i := uint32(0); i < numDropped; i++ {
-		var err error
-		ef.dropped[int(i)], err = deserializeRegisteredBlock(r, observed)
-		if err != nil {
+	for _, stats := range ef.stats {
+		if err := readStats(r, stats); err != nil {
 			return nil, err
 		}
 	}
@@ -6,36 +6,14 @@ package mempool
 
 import (
 	"bytes"
-	"math/rand"
+	"math"
 	"testing"
 
-	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/mining"
 	"github.com/btcsuite/btcd/wire"
-	"github.com/btcsuite/btcd/btcutil"
 )
 
-// newTestFeeEstimator creates a feeEstimator with some different parameters
-// for testing purposes.
-func newTestFeeEstimator(binSize, maxReplacements, maxRollback uint32) *FeeEstimator {
-	return &FeeEstimator{
-		maxRollback:         maxRollback,
-		lastKnownHeight:     0,
-		binSize:             int32(binSize),
-		minRegisteredBlocks: 0,
-		maxReplacements:     int32(maxReplacements),
-		observed:            make(map[chainhash.Hash]*observedTransaction),
-		dropped:             make([]*registeredBlock, 0, maxRollback),
-	}
-}
-
-// lastBlock is a linked list of the block hashes which have been
-// processed by the test FeeEstimator.
-type lastBlock struct {
-	hash *chainhash.Hash
-	prev *lastBlock
-}
-
 // estimateFeeTester interacts with the FeeEstimator to keep track
 // of its expected state.
 type estimateFeeTester struct {
@@ -43,382 +21,431 @@ type estimateFeeTester struct {
 	t       *testing.T
 	version int32
 	height  int32
-	last    *lastBlock
+
+	// pending holds the observed transactions that are not confirmed yet
+	// along with the height they are to be confirmed at.
+	pending map[*TxDesc]int32
+}
+
+// newEstimateFeeTester returns a tester for a new fee estimator.
+func newEstimateFeeTester(t *testing.T) *estimateFeeTester {
+	return &estimateFeeTester{
+		ef:      NewFeeEstimator(),
+		t:       t,
+		pending: make(map[*TxDesc]int32),
+	}
 }
 
-func (eft *estimateFeeTester) testTx(fee btcutil.Amount) *TxDesc {
+// testTx returns a new transaction with the passed fee rate in satoshi per kB
+// that entered the pool at the current height.
+func (eft *estimateFeeTester) testTx(feeRate int64) *TxDesc {
 	eft.version++
+	tx := btcutil.NewTx(&wire.MsgTx{
+		Version: eft.version,
+	})
 	return &TxDesc{
 		TxDesc: mining.TxDesc{
-			Tx: btcutil.NewTx(&wire.MsgTx{
-				Version: eft.version,
-			}),
+			Tx:     tx,
 			Height: eft.height,
-			Fee:    int64(fee),
+			Fee:    feeRate * GetTxVirtualSize(tx) / 1000,
 		},
-		StartingPriority: 0,
 	}
 }
 
-func expectedFeePerKilobyte(t *TxDesc) BtcPerKilobyte {
-	size := float64(t.TxDesc.Tx.MsgTx().SerializeSize())
-	fee := float64(t.TxDesc.Fee)
+// observe creates and observes the passed number of transactions with the
+// passed fee rate in satoshi per kB, which are confirmed after the passed
+// number of blocks.
+func (eft *estimateFeeTester) observe(count int, feeRate int64,
+	blocksToConfirm int32) {
 
-	return SatoshiPerByte(fee / size).ToBtcPerKb()
+	for i := 0; i < count; i++ {
+		txD := eft.testTx(feeRate)
+		eft.ef.ObserveTransaction(txD)
+		eft.pending[txD] = eft.height + blocksToConfirm
+	}
 }
 
-func (eft *estimateFeeTester) newBlock(txs []*wire.MsgTx) {
+// newBlock registers a new block confirming the pending transactions that are
+// due.
+func (eft *estimateFeeTester) newBlock() {
 	eft.height++
 
+	var txs []*wire.MsgTx
+	for txD, height := range eft.pending {
+		if height == eft.height {
+			txs = append(txs, txD.Tx.MsgTx())
+			delete(eft.pending, txD)
+		}
+	}
 	block := btcutil.NewBlock(&wire.MsgBlock{
 		Transactions: txs,
 	})
 	block.SetHeight(eft.height)
 
-	eft.last = &lastBlock{block.Hash(), eft.last}
-
 	eft.ef.RegisterBlock(block)
 }
 
-func (eft *estimateFeeTester) rollback() {
-	if eft.last == nil {
-		return
-	}
+// round observes transactions paying a high fee rate which are confirmed in the
+// next block and transactions paying a low fee rate which are confirmed after
+// ten blocks, and then registers a new block.
+func (eft *estimateFeeTester) round() {
+	eft.observe(5, 50000, 1)
+	eft.observe(5, 2000, 10)
+	eft.newBlock()
+}
 
-	err := eft.ef.Rollback(eft.last.hash)
+// estimateSmartFee returns the estimate of the fee estimator in satoshi per kB
+// along with the target it is for.
+func (eft *estimateFeeTester) estimateSmartFee(confTarget uint32,
+	conservative bool) (float64, uint32) {
 
+	eft.t.Helper()
+
+	feeRate, blocks, err := eft.ef.EstimateSmartFee(confTarget, conservative)
 	if err != nil {
-		eft.t.Errorf("Could not rollback: %v", err)
+		eft.t.Fatalf("EstimateSmartFee(%d): unexpected error: %v",
+			confTarget, err)
 	}
-
-	eft.height--
-	eft.last = eft.last.prev
+	if feeRate < 0 {
+		return -1, blocks
+	}
+	return math.Round(float64(feeRate) / btcPerSatoshi), blocks
 }
 
-// TestEstimateFee tests basic functionality in the FeeEstimator.
-func TestEstimateFee(t *testing.T) {
-	ef := newTestFeeEstimator(5, 3, 1)
-	eft := estimateFeeTester{ef: ef, t: t}
-
-	// Try with no txs and get zero for all queries.
-	expected := BtcPerKilobyte(0.0)
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
-
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f when estimator is empty; got %f", expected, estimated)
-		}
+// TestFeeBuckets ensures the fee rate buckets are exponentially spaced and
+// fee rates are tracked in the expected bucket.
+func TestFeeBuckets(t *testing.T) {
+	buckets := feeBuckets()
+	if buckets[0] != minBucketFeeRate {
+		t.Fatalf("unexpected first bucket: got %v, want %v", buckets[0],
+			minBucketFeeRate)
 	}
-
-	// Now insert a tx.
-	tx := eft.testTx(1000000)
-	ef.ObserveTransaction(tx)
-
-	// Expected should still be zero because this is still in the mempool.
-	expected = BtcPerKilobyte(0.0)
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
-
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f when estimator has one tx in mempool; got %f", expected, estimated)
-		}
+	if !math.IsInf(buckets[len(buckets)-1], 1) {
+		t.Fatalf("unexpected last bucket: got %v, want +Inf",
+			buckets[len(buckets)-1])
 	}
-
-	// Change minRegisteredBlocks to make sure that works. Error return
-	// value expected.
-	ef.minRegisteredBlocks = 1
-	expected = BtcPerKilobyte(-1.0)
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
-
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f before any blocks have been registered; got %f", expected, estimated)
+	for i := 1; i < len(buckets)-1; i++ {
+		ratio := buckets[i] / buckets[i-1]
+		if math.Abs(ratio-feeBucketSpacing) > 1e-9 {
+			t.Fatalf("unexpected spacing between buckets %d and %d: "+
+				"got %v, want %v", i-1, i, ratio, feeBucketSpacing)
 		}
 	}
+	if buckets[len(buckets)-2] > maxBucketFeeRate {
+		t.Fatalf("unexpected highest bounded bucket %v",
+			buckets[len(buckets)-2])
+	}
 
-	// Record a block with the new tx.
-	eft.newBlock([]*wire.MsgTx{tx.Tx.MsgTx()})
-	expected = expectedFeePerKilobyte(tx)
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
-
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f when one tx is binned; got %f", expected, estimated)
+	// Observe transactions with a fee rate below the lowest bucket, one
+	// between buckets and one above the highest bucket.
+	eft := newEstimateFeeTester(t)
+	tests := []struct {
+		feeRate int64
+		bucket  int
+	}{
+		{feeRate: 500, bucket: 0},
+		{feeRate: 1000, bucket: 0},
+		{feeRate: 1100, bucket: 2},
+		{feeRate: 2e7, bucket: len(buckets) - 1},
+	}
+	for _, test := range tests {
+		txD := eft.testTx(test.feeRate)
+		eft.ef.ObserveTransaction(txD)
+		tracked, ok := eft.ef.tracked[*txD.Tx.Hash()]
+		if !ok {
+			t.Fatalf("transaction with fee rate %d is not tracked",
+				test.feeRate)
+		}
+		if tracked.bucket != test.bucket {
+			t.Fatalf("unexpected bucket for fee rate %d: got %d, "+
+				"want %d", test.feeRate, tracked.bucket,
+				test.bucket)
 		}
 	}
+}
 
-	// Roll back the last block; this was an orphan block.
-	ef.minRegisteredBlocks = 0
-	eft.rollback()
-	expected = BtcPerKilobyte(0.0)
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
+// TestEstimateSmartFee tests basic functionality in the FeeEstimator.
+func TestEstimateSmartFee(t *testing.T) {
+	eft := newEstimateFeeTester(t)
 
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f after rolling back block; got %f", expected, estimated)
+	// Targets out of range are rejected.
+	for _, confTarget := range []uint32{0, MaxFeeEstimateTarget + 1} {
+		_, _, err := eft.ef.EstimateSmartFee(confTarget, false)
+		if err == nil {
+			t.Fatalf("EstimateSmartFee(%d): unexpected success",
+				confTarget)
 		}
 	}
 
-	// Record an empty block and then a block with the new tx.
-	// This test was made because of a bug that only appeared when there
-	// were no transactions in the first bin.
-	eft.newBlock([]*wire.MsgTx{})
-	eft.newBlock([]*wire.MsgTx{tx.Tx.MsgTx()})
-	expected = expectedFeePerKilobyte(tx)
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
-
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f when one tx is binned; got %f", expected, estimated)
+	// There is not enough data to estimate anything at first.
+	for _, confTarget := range []uint32{1, 2, 10, MaxFeeEstimateTarget} {
+		feeRate, _ := eft.estimateSmartFee(confTarget, false)
+		if feeRate != -1 {
+			t.Fatalf("EstimateSmartFee(%d): unexpected estimate %v "+
+				"for empty estimator", confTarget, feeRate)
 		}
 	}
 
-	// Create some more transactions.
-	txA := eft.testTx(500000)
-	txB := eft.testTx(2000000)
-	txC := eft.testTx(4000000)
-	ef.ObserveTransaction(txA)
-	ef.ObserveTransaction(txB)
-	ef.ObserveTransaction(txC)
-
-	// Record 7 empty blocks.
-	for i := 0; i < 7; i++ {
-		eft.newBlock([]*wire.MsgTx{})
+	// Transactions that entered the pool as of another height than the
+	// last block registered are ignored.
+	eft.height = 5
+	eft.observe(1, 50000, 1)
+	if len(eft.ef.tracked) != 0 {
+		t.Fatalf("transaction of unexpected height is tracked")
 	}
+	eft.height = 0
+	eft.pending = make(map[*TxDesc]int32)
 
-	// Mine the first tx.
-	eft.newBlock([]*wire.MsgTx{txA.Tx.MsgTx()})
+	for i := 0; i < 200; i++ {
+		eft.round()
+	}
 
-	// Now the estimated amount should depend on the value
-	// of the argument to estimate fee.
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
-		if i > 2 {
-			expected = expectedFeePerKilobyte(txA)
-		} else {
-			expected = expectedFeePerKilobyte(tx)
-		}
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f on round %d; got %f", expected, i, estimated)
+	// The target is capped at half the number of blocks observed since
+	// the first one confirming tracked transactions, and one is treated
+	// as two.
+	tests := []struct {
+		name         string
+		confTarget   uint32
+		conservative bool
+		feeRate      float64
+		blocks       uint32
+	}{{
+		name:       "next block",
+		confTarget: 1,
+		feeRate:    50000,
+		blocks:     2,
+	}, {
+		name:       "short target",
+		confTarget: 5,
+		feeRate:    50000,
+		blocks:     5,
+	}, {
+		name:       "long target",
+		confTarget: 40,
+		feeRate:    2000,
+		blocks:     40,
+	}, {
+		name:         "long target conservative",
+		confTarget:   40,
+		conservative: true,
+		feeRate:      2000,
+		blocks:       40,
+	}, {
+		name:       "capped target",
+		confTarget: MaxFeeEstimateTarget,
+		feeRate:    2000,
+		blocks:     99,
+	}}
+	for _, test := range tests {
+		feeRate, blocks := eft.estimateSmartFee(test.confTarget,
+			test.conservative)
+		if feeRate != test.feeRate || blocks != test.blocks {
+			t.Errorf("%s: unexpected estimate: got %v for %d "+
+				"blocks, want %v for %d blocks", test.name,
+				feeRate, blocks, test.feeRate, test.blocks)
 		}
 	}
 
-	// Record 5 more empty blocks.
-	for i := 0; i < 5; i++ {
-		eft.newBlock([]*wire.MsgTx{})
+	// The legacy estimates are based on the medium horizon.
+	feeRate, err := eft.ef.EstimateFee(20)
+	if err != nil {
+		t.Fatalf("EstimateFee: unexpected error: %v", err)
 	}
-
-	// Mine the next tx.
-	eft.newBlock([]*wire.MsgTx{txB.Tx.MsgTx()})
-
-	// Now the estimated amount should depend on the value
-	// of the argument to estimate fee.
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
-		if i <= 2 {
-			expected = expectedFeePerKilobyte(txB)
-		} else if i <= 8 {
-			expected = expectedFeePerKilobyte(tx)
-		} else {
-			expected = expectedFeePerKilobyte(txA)
-		}
-
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f on round %d; got %f", expected, i, estimated)
-		}
+	if want := BtcPerKilobyte(2000 * btcPerSatoshi); feeRate != want {
+		t.Fatalf("EstimateFee: unexpected estimate: got %v, want %v",
+			feeRate, want)
 	}
-
-	// Record 9 more empty blocks.
-	for i := 0; i < 10; i++ {
-		eft.newBlock([]*wire.MsgTx{})
-	}
-
-	// Mine txC.
-	eft.newBlock([]*wire.MsgTx{txC.Tx.MsgTx()})
-
-	// This should have no effect on the outcome because too
-	// many blocks have been mined for txC to be recorded.
-	for i := uint32(1); i <= estimateFeeDepth; i++ {
-		estimated, _ := ef.EstimateFee(i)
-		if i <= 2 {
-			expected = expectedFeePerKilobyte(txC)
-		} else if i <= 8 {
-			expected = expectedFeePerKilobyte(txB)
-		} else if i <= 8+6 {
-			expected = expectedFeePerKilobyte(tx)
-		} else {
-			expected = expectedFeePerKilobyte(txA)
-		}
-
-		if estimated != expected {
-			t.Errorf("Estimate fee error: expected %f on round %d; got %f", expected, i, estimated)
-		}
+	if _, err := eft.ef.EstimateFee(mediumScale*mediumPeriods + 1); err == nil {
+		t.Fatalf("EstimateFee: unexpected success beyond the medium " +
+			"horizon")
 	}
 }
 
-func (eft *estimateFeeTester) estimates() [estimateFeeDepth]BtcPerKilobyte {
-
-	// Generate estimates
-	var estimates [estimateFeeDepth]BtcPerKilobyte
-	for i := 0; i < estimateFeeDepth; i++ {
-		estimates[i], _ = eft.ef.EstimateFee(uint32(i + 1))
+// TestEstimateSmartFeeModes ensures the conservative mode does not respond to
+// a recent drop in fee rates as quickly as the economical mode does.
+func TestEstimateSmartFeeModes(t *testing.T) {
+	eft := newEstimateFeeTester(t)
+
+	// Transactions paying a moderate fee rate were only confirmed after
+	// thirty blocks for a long time.
+	for i := 0; i < 300; i++ {
+		eft.observe(5, 50000, 1)
+		eft.observe(5, 15000, 30)
+		eft.newBlock()
 	}
 
-	// Check that all estimated fee results go in descending order.
-	for i := 1; i < estimateFeeDepth; i++ {
-		if estimates[i] > estimates[i-1] {
-			eft.t.Error("Estimates not in descending order; got ",
-				estimates[i], " for estimate ", i, " and ", estimates[i-1], " for ", (i - 1))
-			panic("invalid state.")
-		}
+	// The same transactions are all confirmed in the next block recently.
+	for i := 0; i < 150; i++ {
+		eft.observe(5, 50000, 1)
+		eft.observe(5, 15000, 1)
+		eft.newBlock()
 	}
 
-	return estimates
+	economical, _ := eft.estimateSmartFee(6, false)
+	conservative, _ := eft.estimateSmartFee(6, true)
+	if economical != 15000 {
+		t.Fatalf("unexpected economical estimate: got %v, want %v",
+			economical, 15000)
+	}
+	if conservative != 50000 {
+		t.Fatalf("unexpected conservative estimate: got %v, want %v",
+			conservative, 50000)
+	}
 }
 
-func (eft *estimateFeeTester) round(txHistory [][]*TxDesc,
-	estimateHistory [][estimateFeeDepth]BtcPerKilobyte,
-	txPerRound, txPerBlock uint32) ([][]*TxDesc, [][estimateFeeDepth]BtcPerKilobyte) {
-
-	// generate new txs.
-	var newTxs []*TxDesc
-	for i := uint32(0); i < txPerRound; i++ {
-		newTx := eft.testTx(btcutil.Amount(rand.Intn(1000000)))
-		eft.ef.ObserveTransaction(newTx)
-		newTxs = append(newTxs, newTx)
+// TestEstimateFeeFailures ensures transactions that leave the pool without
+// being confirmed and those that remain unconfirmed count against the fee rates
+// they pay.
+func TestEstimateFeeFailures(t *testing.T) {
+	eft := newEstimateFeeTester(t)
+	for i := 0; i < 100; i++ {
+		eft.round()
 	}
-
-	// Generate mempool.
-	mempool := make(map[*observedTransaction]*TxDesc)
-	for _, h := range txHistory {
-		for _, t := range h {
-			if o, exists := eft.ef.observed[*t.Tx.Hash()]; exists && o.mined == mining.UnminedHeight {
-				mempool[o] = t
-			}
-		}
+	if feeRate, _ := eft.estimateSmartFee(20, false); feeRate != 2000 {
+		t.Fatalf("unexpected estimate: got %v, want %v", feeRate, 2000)
 	}
 
-	// generate new block, with no duplicates.
-	i := uint32(0)
-	newBlockList := make([]*wire.MsgTx, 0, txPerBlock)
-	for _, t := range mempool {
-		newBlockList = append(newBlockList, t.TxDesc.Tx.MsgTx())
-		i++
+	// Transactions paying the low fee rate are no longer confirmed but
+	// evicted after waiting for a while instead.
+	for i := 0; i < 100; i++ {
+		eft.observe(5, 50000, 1)
+		for j := 0; j < 5; j++ {
+			txD := eft.testTx(2000)
+			eft.ef.ObserveTransaction(txD)
+			eft.pending[txD] = -1
+		}
+		for txD := range eft.pending {
+			if eft.pending[txD] == -1 &&
+				eft.height-txD.Height >= 10 {
 
-		if i == txPerBlock {
-			break
+				eft.ef.RemoveTransaction(txD.Tx.Hash())
+				delete(eft.pending, txD)
+			}
 		}
+		eft.newBlock()
+	}
+	if feeRate, _ := eft.estimateSmartFee(20, false); feeRate != 50000 {
+		t.Fatalf("unexpected estimate after failures: got %v, want %v",
+			feeRate, 50000)
 	}
-
-	// Register a new block.
-	eft.newBlock(newBlockList)
-
-	// return results.
-	estimates := eft.estimates()
-
-	// Return results
-	return append(txHistory, newTxs), append(estimateHistory, estimates)
 }
 
-// TestEstimateFeeRollback tests the rollback function, which undoes the
-// effect of a adding a new block.
-func TestEstimateFeeRollback(t *testing.T) {
-	txPerRound := uint32(7)
-	txPerBlock := uint32(5)
-	binSize := uint32(6)
-	maxReplacements := uint32(4)
-	stepsBack := 2
-	rounds := 30
-
-	eft := estimateFeeTester{ef: newTestFeeEstimator(binSize, maxReplacements, uint32(stepsBack)), t: t}
-	var txHistory [][]*TxDesc
-	estimateHistory := [][estimateFeeDepth]BtcPerKilobyte{eft.estimates()}
-
-	for round := 0; round < rounds; round++ {
-		// Go forward a few rounds.
-		for step := 0; step <= stepsBack; step++ {
-			txHistory, estimateHistory =
-				eft.round(txHistory, estimateHistory, txPerRound, txPerBlock)
-		}
+// TestRegisterBlockReorg ensures transactions confirmed by a block that does
+// not extend the highest registered block are no longer tracked without being
+// counted as failures or recorded as confirmed.
+func TestRegisterBlockReorg(t *testing.T) {
+	eft := newEstimateFeeTester(t)
+	for i := 0; i < 10; i++ {
+		eft.round()
+	}
+
+	// Observe a transaction and let it wait long enough to be counted as a
+	// failure when it is removed without being confirmed.
+	txD := eft.testTx(10000)
+	eft.ef.ObserveTransaction(txD)
+	for i := 0; i < 10; i++ {
+		eft.round()
+	}
 
-		// Now go back.
-		for step := 0; step < stepsBack; step++ {
-			eft.rollback()
-
-			// After rolling back, we should have the same estimated
-			// fees as before.
-			expected := estimateHistory[len(estimateHistory)-step-2]
-			estimates := eft.estimates()
-
-			// Ensure that these are both the same.
-			for i := 0; i < estimateFeeDepth; i++ {
-				if expected[i] != estimates[i] {
-					t.Errorf("Rollback value mismatch. Expected %f, got %f. ",
-						expected[i], estimates[i])
-					return
+	// sumStats returns the totals of the confirmed and failed averages.
+	sumStats := func() (float64, float64) {
+		var confirmed, failed float64
+		for _, stats := range eft.ef.stats {
+			for i := range stats.confAvg {
+				for _, avg := range stats.confAvg[i] {
+					confirmed += avg
+				}
+			}
+			for i := range stats.failAvg {
+				for _, avg := range stats.failAvg[i] {
+					failed += avg
 				}
 			}
 		}
-
-		// Erase history.
-		txHistory = txHistory[0 : len(txHistory)-stepsBack]
-		estimateHistory = estimateHistory[0 : len(estimateHistory)-stepsBack]
+		return confirmed, failed
 	}
-}
+	confirmed, failed := sumStats()
 
-func (eft *estimateFeeTester) checkSaveAndRestore(
-	previousEstimates [estimateFeeDepth]BtcPerKilobyte) {
-
-	// Get the save state.
-	save := eft.ef.Save()
+	// Register a block replacing the current tip that confirms the
+	// transaction as happens during a reorganization.
+	block := btcutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{txD.Tx.MsgTx()},
+	})
+	block.SetHeight(eft.height)
+	eft.ef.RegisterBlock(block)
 
-	// Save and restore database.
-	var err error
-	eft.ef, err = RestoreFeeEstimator(save)
-	if err != nil {
-		eft.t.Fatalf("Could not restore database: %s", err)
+	if _, ok := eft.ef.tracked[*txD.Tx.Hash()]; ok {
+		t.Fatal("transaction still tracked after being confirmed")
 	}
-
-	// Save again and check that it matches the previous one.
-	redo := eft.ef.Save()
-	if !bytes.Equal(save, redo) {
-		eft.t.Fatalf("Restored states do not match: %v %v", save, redo)
+	eft.ef.RemoveTransaction(txD.Tx.Hash())
+	gotConfirmed, gotFailed := sumStats()
+	if gotConfirmed != confirmed || gotFailed != failed {
+		t.Fatalf("unexpected stats after reorganization: got %v "+
+			"confirmed and %v failed, want %v and %v", gotConfirmed,
+			gotFailed, confirmed, failed)
 	}
-
-	// Check that the results match.
-	newEstimates := eft.estimates()
-
-	for i, prev := range previousEstimates {
-		if prev != newEstimates[i] {
-			eft.t.Error("Mismatch in estimate ", i, " after restore; got ", newEstimates[i], " but expected ", prev)
-		}
+	if eft.ef.bestHeight != eft.height {
+		t.Fatalf("unexpected best height %d, want %d",
+			eft.ef.bestHeight, eft.height)
 	}
 }
 
-// TestSave tests saving and restoring to a []byte.
+// TestDatabase ensures the state of the fee estimator can be saved and
+// restored.
 func TestDatabase(t *testing.T) {
+	eft := newEstimateFeeTester(t)
+	for i := 0; i < 150; i++ {
+		eft.round()
+	}
 
-	txPerRound := uint32(7)
-	txPerBlock := uint32(5)
-	binSize := uint32(6)
-	maxReplacements := uint32(4)
-	rounds := 8
-
-	eft := estimateFeeTester{ef: newTestFeeEstimator(binSize, maxReplacements, uint32(rounds)+1), t: t}
-	var txHistory [][]*TxDesc
-	estimateHistory := [][estimateFeeDepth]BtcPerKilobyte{eft.estimates()}
+	// Confirm the remaining transactions so the estimates don't depend on
+	// unconfirmed transactions, which are not saved.
+	for len(eft.pending) > 0 {
+		eft.newBlock()
+	}
 
-	for round := 0; round < rounds; round++ {
-		eft.checkSaveAndRestore(estimateHistory[len(estimateHistory)-1])
+	saved := eft.ef.Save()
+	restored, err := RestoreFeeEstimator(saved)
+	if err != nil {
+		t.Fatalf("RestoreFeeEstimator: unexpected error: %v", err)
+	}
+	if !bytes.Equal(saved, restored.Save()) {
+		t.Fatalf("restored fee estimator does not save the same state")
+	}
+	for _, conservative := range []bool{false, true} {
+		for confTarget := uint32(1); confTarget <= 100; confTarget++ {
+			feeRate, blocks, _ := eft.ef.EstimateSmartFee(confTarget,
+				conservative)
+			gotFeeRate, gotBlocks, _ := restored.EstimateSmartFee(
+				confTarget, conservative)
+			if gotFeeRate != feeRate || gotBlocks != blocks {
+				t.Fatalf("EstimateSmartFee(%d, %v): unexpected "+
+					"restored estimate: got %v for %d blocks, "+
+					"want %v for %d blocks", confTarget,
+					conservative, gotFeeRate, gotBlocks,
+					feeRate, blocks)
+			}
+		}
+	}
 
-		// Go forward one step.
-		txHistory, estimateHistory =
-			eft.round(txHistory, estimateHistory, txPerRound, txPerBlock)
+	// The restored fee estimator registers the next block regardless of
+	// the height the state was saved at.
+	eft.ef = restored
+	eft.round()
+	if restored.bestHeight != eft.height {
+		t.Fatalf("unexpected best height: got %d, want %d",
+			restored.bestHeight, eft.height)
 	}
 
-	// Reverse the process and try again.
-	for round := 1; round <= rounds; round++ {
-		eft.rollback()
-		eft.checkSaveAndRestore(estimateHistory[len(estimateHistory)-round-1])
+	// Saved states with another version or corrupt data are rejected.
+	badVersion := append(FeeEstimatorState{}, saved...)
+	badVersion[3]++
+	if _, err := RestoreFeeEstimator(badVersion); err == nil {
+		t.Fatalf("RestoreFeeEstimator: unexpected success with " +
+			"another version")
+	}
+	if _, err := RestoreFeeEstimator(saved[:len(saved)-1]); err == nil {
+		t.Fatalf("RestoreFeeEstimator: unexpected success with " +
+			"truncated data")
 	}
 }
@@ -0,0 +1,90 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"container/heap"
+)
+
+// evictionQueue orders the transactions in the pool by the order they are
+// evicted in when the pool is full.  That is to say, by ascending descendant
+// score with ties broken by evicting the most recently added transaction
+// first.  It implements heap.Interface, so the transaction to evict next is
+// always the first one.
+//
+// The position of every transaction in the queue is tracked in its descriptor,
+// so the queue must be fixed up through update whenever the descendant score
+// or the time a transaction was added changes.
+type evictionQueue []*TxDesc
+
+// Ensure the evictionQueue type implements the heap.Interface interface.
+var _ heap.Interface = (*evictionQueue)(nil)
+
+// Len returns the number of transactions in the queue.  It is part of the
+// heap.Interface implementation.
+func (q evictionQueue) Len() int {
+	return len(q)
+}
+
+// Less returns whether the transaction at index i is evicted before the one at
+// index j.  It is part of the heap.Interface implementation.
+func (q evictionQueue) Less(i, j int) bool {
+	scoreI, scoreJ := descendantScore(q[i]), descendantScore(q[j])
+	if scoreI == scoreJ {
+		return q[i].Added.After(q[j].Added)
+	}
+	return scoreI < scoreJ
+}
+
+// Swap swaps the transactions at the passed indices in the queue.  It is part
+// of the heap.Interface implementation.
+func (q evictionQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].evictionIdx = i
+	q[j].evictionIdx = j
+}
+
+// Push pushes the passed transaction onto the queue.  It is part of the
+// heap.Interface implementation.
+func (q *evictionQueue) Push(x interface{}) {
+	txD := x.(*TxDesc)
+	txD.evictionIdx = len(*q)
+	*q = append(*q, txD)
+}
+
+// Pop removes the last transaction from the queue.  It is part of the
+// heap.Interface implementation.
+func (q *evictionQueue) Pop() interface{} {
+	n := len(*q)
+	txD := (*q)[n-1]
+	(*q)[n-1] = nil
+	*q = (*q)[:n-1]
+	txD.evictionIdx = -1
+	return txD
+}
+
+// add adds the passed transaction to the queue.
+func (q *evictionQueue) add(txD *TxDesc) {
+	heap.Push(q, txD)
+}
+
+// remove removes the passed transaction from the queue.
+func (q *evictionQueue) remove(txD *TxDesc) {
+	heap.Remove(q, txD.evictionIdx)
+}
+
+// update moves the passed transaction to its position in the queue after its
+// descendant score or the time it was added changed.
+func (q *evictionQueue) update(txD *TxDesc) {
+	heap.Fix(q, txD.evictionIdx)
+}
+
+// next returns the transaction to evict next or nil when the queue is empty.
+func (q evictionQueue) next() *TxDesc {
+	if len(q) == 0 {
+		return nil
+	}
+	return q[0]
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/mining"
+)
+
+// TestEvictionQueue ensures the eviction queue keeps transactions ordered by
+// ascending descendant score, with the most recently added one first among
+// equal scores, as their scores change and transactions are removed.
+func TestEvictionQueue(t *testing.T) {
+	t.Parallel()
+
+	rng := rand.New(rand.NewSource(1))
+	now := time.Now()
+	newDesc := func() *TxDesc {
+		txD := &TxDesc{TxDesc: mining.TxDesc{
+			Added:    now.Add(time.Duration(rng.Intn(10)) * time.Second),
+			FeePerKB: int64(rng.Intn(5)) * 1000,
+		}}
+		txD.descendants = chainStats{
+			count: 1,
+			size:  int64(rng.Intn(900) + 100),
+			fees:  int64(rng.Intn(5000)),
+		}
+		return txD
+	}
+
+	var q evictionQueue
+	var descs []*TxDesc
+	for i := 0; i < 200; i++ {
+		txD := newDesc()
+		q.add(txD)
+		descs = append(descs, txD)
+	}
+
+	// Change the descendant score of some of the transactions along with
+	// the time some of them were added and remove others.
+	for i := 0; i < 100; i++ {
+		idx := rng.Intn(len(descs))
+		txD := descs[idx]
+		switch rng.Intn(3) {
+		case 0:
+			txD.descendants.add(int64(rng.Intn(500)+1),
+				int64(rng.Intn(5000)))
+			q.update(txD)
+		case 1:
+			txD.Added = txD.Added.Add(-time.Second)
+			q.update(txD)
+		case 2:
+			q.remove(txD)
+			descs = append(descs[:idx], descs[idx+1:]...)
+		}
+	}
+	if q.Len() != len(descs) {
+		t.Fatalf("unexpected queue length %d, want %d", q.Len(),
+			len(descs))
+	}
+
+	// Ensure the transactions are evicted in order.
+	var prev *TxDesc
+	for q.Len() > 0 {
+		txD := q.next()
+		q.remove(txD)
+		if txD.evictionIdx != -1 {
+			t.Fatalf("unexpected position %d of removed transaction",
+				txD.evictionIdx)
+		}
+		if prev != nil {
+			prevScore, score := descendantScore(prev), descendantScore(txD)
+			if score < prevScore || (score == prevScore &&
+				txD.Added.After(prev.Added)) {
+
+				t.Fatalf("transaction with score %d added at %v "+
+					"evicted after one with score %d added "+
+					"at %v", score, txD.Added, prevScore,
+					prev.Added)
+			}
+		}
+		prev = txD
+	}
+	if q.next() != nil {
+		t.Fatal("unexpected transaction in empty queue")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"container/list"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -48,6 +49,25 @@ const (
 	// can be evicted from the mempool when accepting a transaction
 	// replacement.
 	MaxReplacementEvictions = 100
+
+	// txDescOverhead, txInOverhead and txOutOverhead are the estimated
+	// amounts of memory in bytes used by a transaction in the pool, each
+	// of its inputs and each of its outputs respectively in addition to
+	// its serialized size.  They account for the descriptor, the decoded
+	// transaction and the entries in the maps of the pool.
+	txDescOverhead = 400
+	txInOverhead   = 150
+	txOutOverhead  = 50
+
+	// rollingMinFeeHalfLife is the amount of time it takes the minimum fee
+	// rate of the pool to halve once a block has been connected after it
+	// was raised.  It halves more quickly while the pool is less than half
+	// full.
+	rollingMinFeeHalfLife = time.Hour * 12
+
+	// rollingMinFeeUpdateInterval is the minimum amount of time in between
+	// updates of the decaying minimum fee rate of the pool.
+	rollingMinFeeUpdateInterval = time.Second * 10
 )
 
 // Tag represents an identifier to use for tagging orphan transactions.  The
@@ -100,8 +120,14 @@ type Config struct {
 	// This can be nil if the address index is not enabled.
 	AddrIndex *indexers.AddrIndex
 
-	// FeeEstimatator provides a feeEstimator. If it is not nil, the mempool
-	// records all new transactions it observes into the feeEstimator.
+	// ScriptHashIndex defines the optional script hash index instance to
+	// use for indexing the unconfirmed transactions in the memory pool.
+	// This can be nil if the script hash index is not enabled.
+	ScriptHashIndex *indexers.ScriptHashIndex
+
+	// FeeEstimator provides a feeEstimator. If it is not nil, the mempool
+	// records the new transactions it observes and the transactions that
+	// leave it into the feeEstimator.
 	FeeEstimator *FeeEstimator
 }
 
@@ -148,6 +174,54 @@ type Policy struct {
 	// transactions using the Replace-By-Fee (RBF) signaling policy into
 	// the mempool.
 	RejectReplacement bool
+
+	// MaxAncestorCount is the maximum number of transactions a transaction
+	// may form along with its unconfirmed ancestors in the pool.  Zero
+	// means DefaultMaxAncestorCount.
+	MaxAncestorCount int
+
+	// MaxAncestorSize is the maximum virtual size in bytes a transaction
+	// may have along with its unconfirmed ancestors in the pool.  Zero
+	// means DefaultMaxAncestorSize.
+	MaxAncestorSize int
+
+	// MaxDescendantCount is the maximum number of transactions a
+	// transaction in the pool may form along with its descendants in the
+	// pool.  Zero means DefaultMaxDescendantCount.
+	MaxDescendantCount int
+
+	// MaxDescendantSize is the maximum virtual size in bytes a transaction
+	// in the pool may have along with its descendants in the pool.  Zero
+	// means DefaultMaxDescendantSize.
+	MaxDescendantSize int
+
+	// MaxPoolSize is the maximum estimated amount of memory in bytes the
+	// transactions in the pool may use.  The transactions with the lowest
+	// fee rates are evicted when it is exceeded.  Zero means
+	// DefaultMaxPoolSize.
+	MaxPoolSize int64
+}
+
+// chainStats describes a transaction in the pool along with either all of its
+// unconfirmed ancestors or all of its descendants in the pool.
+type chainStats struct {
+	count int64
+	size  int64
+	fees  int64
+}
+
+// add includes a transaction with the passed virtual size and fee.
+func (s *chainStats) add(vsize, fee int64) {
+	s.count++
+	s.size += vsize
+	s.fees += fee
+}
+
+// remove excludes a transaction with the passed virtual size and fee.
+func (s *chainStats) remove(vsize, fee int64) {
+	s.count--
+	s.size -= vsize
+	s.fees -= fee
 }
 
 // TxDesc is a descriptor containing a transaction in the mempool along with
@@ -158,6 +232,18 @@ type TxDesc struct {
 	// StartingPriority is the priority of the transaction when it was added
 	// to the pool.
 	StartingPriority float64
+
+	// ancestors and descendants describe the transaction along with its
+	// unconfirmed ancestors and its descendants in the pool respectively.
+	// They are kept up to date as transactions are added to and removed
+	// from the pool, so they must only be accessed with the mempool lock
+	// held.
+	ancestors   chainStats
+	descendants chainStats
+
+	// evictionIdx is the position of the transaction in the eviction
+	// queue of the pool.
+	evictionIdx int
 }
 
 // orphanTx is normal transaction that references an ancestor transaction
@@ -190,6 +276,26 @@ type TxPool struct {
 	// the scan will only run when an orphan is added to the pool as opposed
 	// to on an unconditional timer.
 	nextExpireScan time.Time
+
+	// usage is the estimated amount of memory in bytes used by the
+	// transactions in the pool.
+	usage int64
+
+	// evictionQueue orders the transactions in the pool by the order they
+	// are evicted in when the pool is full.
+	evictionQueue evictionQueue
+
+	// rollingMinFee is the minimum fee rate in satoshi per kB transactions
+	// must pay to be accepted into the pool.  It is raised above the fee
+	// rate of the transactions evicted when the pool is full and decays
+	// once a block has been connected since then.  rollingMinFeeHeight is
+	// the best height when it was last raised, rollingMinFeeDecaying
+	// indicates a block has been connected since then, and
+	// rollingMinFeeUpdated is the time it last decayed.
+	rollingMinFee         float64
+	rollingMinFeeHeight   int32
+	rollingMinFeeDecaying bool
+	rollingMinFeeUpdated  time.Time
 }
 
 // Ensure the TxPool type implements the mining.TxSource interface.
@@ -480,17 +586,44 @@ func (mp *TxPool) removeTransaction(tx *btcutil.Tx, removeRedeemers bool) {
 
 	// Remove the transaction if needed.
 	if txDesc, exists := mp.pool[*txHash]; exists {
-		// Remove unconfirmed address index entries associated with the
-		// transaction if enabled.
+		// Remove unconfirmed address and script hash index entries
+		// associated with the transaction if enabled.
 		if mp.cfg.AddrIndex != nil {
 			mp.cfg.AddrIndex.RemoveUnconfirmedTx(txHash)
 		}
+		if mp.cfg.ScriptHashIndex != nil {
+			mp.cfg.ScriptHashIndex.RemoveUnconfirmedTx(txHash)
+		}
+
+		// Exclude the transaction from the cached state of the
+		// transactions it is related to while it is still reachable
+		// from them.
+		vsize := GetTxVirtualSize(tx)
+		for hash := range mp.txAncestors(tx, nil) {
+			desc := mp.pool[hash]
+			desc.descendants.remove(vsize, txDesc.Fee)
+			mp.evictionQueue.update(desc)
+		}
+		for hash := range mp.txDescendants(tx, nil) {
+			mp.pool[hash].ancestors.remove(vsize, txDesc.Fee)
+		}
+		mp.evictionQueue.remove(txDesc)
 
 		// Mark the referenced outpoints as unspent by the pool.
 		for _, txIn := range txDesc.Tx.MsgTx().TxIn {
 			delete(mp.outpoints, txIn.PreviousOutPoint)
 		}
 		delete(mp.pool, *txHash)
+
+		// Transactions removed from the pool are no longer tracked
+		// for fee estimation and count as failures to be confirmed in
+		// time.  Confirmed transactions are not affected as long as
+		// the block they are in is registered with the fee estimator
+		// before they are removed, which untracks them already.
+		if mp.cfg.FeeEstimator != nil {
+			mp.cfg.FeeEstimator.RemoveTransaction(txHash)
+		}
+		mp.usage -= txMemoryUsage(tx)
 		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
 	}
 }
@@ -551,22 +684,271 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *btcutil
 	for _, txIn := range tx.MsgTx().TxIn {
 		mp.outpoints[txIn.PreviousOutPoint] = tx
 	}
+	mp.addChainStats(txD)
+	mp.usage += txMemoryUsage(tx)
 	atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
 
-	// Add unconfirmed address index entries associated with the transaction
-	// if enabled.
+	// Add unconfirmed address and script hash index entries associated
+	// with the transaction if enabled.
 	if mp.cfg.AddrIndex != nil {
 		mp.cfg.AddrIndex.AddUnconfirmedTx(tx, utxoView)
 	}
-
-	// Record this tx for fee estimation if enabled.
-	if mp.cfg.FeeEstimator != nil {
-		mp.cfg.FeeEstimator.ObserveTransaction(txD)
+	if mp.cfg.ScriptHashIndex != nil {
+		mp.cfg.ScriptHashIndex.AddUnconfirmedTx(tx, utxoView)
 	}
 
 	return txD
 }
 
+// calcChainStats returns the state of the passed transaction in the pool along
+// with the passed transactions in the pool it is related to.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) calcChainStats(txD *TxDesc,
+	related map[chainhash.Hash]*btcutil.Tx) chainStats {
+
+	var stats chainStats
+	stats.add(GetTxVirtualSize(txD.Tx), txD.Fee)
+	for hash := range related {
+		desc := mp.pool[hash]
+		stats.add(GetTxVirtualSize(desc.Tx), desc.Fee)
+	}
+	return stats
+}
+
+// addChainStats initializes the cached ancestor and descendant state of the
+// passed transaction, which was just added to the pool, and includes it in the
+// state of the transactions in the pool it is related to.  It also adds the
+// transaction to the eviction queue and moves the transactions whose
+// descendant score changed to their new position in it.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) addChainStats(txD *TxDesc) {
+	ancestors := mp.txAncestors(txD.Tx, nil)
+	descendants := mp.txDescendants(txD.Tx, nil)
+	txD.ancestors = mp.calcChainStats(txD, ancestors)
+	txD.descendants = mp.calcChainStats(txD, descendants)
+
+	// A new transaction usually has no descendants, so it only needs to be
+	// included in the descendant state of its ancestors.
+	mp.evictionQueue.add(txD)
+	if len(descendants) == 0 {
+		vsize := GetTxVirtualSize(txD.Tx)
+		for hash := range ancestors {
+			desc := mp.pool[hash]
+			desc.descendants.add(vsize, txD.Fee)
+			mp.evictionQueue.update(desc)
+		}
+		return
+	}
+
+	// Transactions added back to the pool from disconnected blocks may
+	// already have descendants in the pool, which may share ancestors with
+	// it.  Recalculate the state of all related transactions in that case
+	// so no transaction is counted twice.
+	for hash := range ancestors {
+		desc := mp.pool[hash]
+		desc.descendants = mp.calcChainStats(desc,
+			mp.txDescendants(desc.Tx, nil))
+		mp.evictionQueue.update(desc)
+	}
+	for hash := range descendants {
+		desc := mp.pool[hash]
+		desc.ancestors = mp.calcChainStats(desc,
+			mp.txAncestors(desc.Tx, nil))
+	}
+}
+
+// checkChainLimits ensures adding the passed transactions, which are either a
+// single transaction or a package, with the given virtual size in total to the
+// pool does not exceed the ancestor and descendant limits of the policy.  A
+// package is conservatively treated as a single transaction that has the
+// ancestors of all of its transactions.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) checkChainLimits(txns []*btcutil.Tx, vsize int64) error {
+	ancestors := make(map[chainhash.Hash]*btcutil.Tx)
+	for _, tx := range txns {
+		for hash, ancestor := range mp.txAncestors(tx, nil) {
+			ancestors[hash] = ancestor
+		}
+	}
+
+	policy := &mp.cfg.Policy
+	count := len(ancestors) + len(txns)
+	if count > policy.MaxAncestorCount {
+		str := fmt.Sprintf("%s has too many unconfirmed ancestors: "+
+			"max is %d, has %d", describeTxns(txns),
+			policy.MaxAncestorCount, count)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+	size := vsize
+	for hash := range ancestors {
+		size += GetTxVirtualSize(mp.pool[hash].Tx)
+	}
+	if size > int64(policy.MaxAncestorSize) {
+		str := fmt.Sprintf("%s exceeds the ancestor size limit: max "+
+			"is %d, has %d", describeTxns(txns),
+			policy.MaxAncestorSize, size)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+
+	for hash := range ancestors {
+		desc := mp.pool[hash]
+		count := desc.descendants.count + int64(len(txns))
+		if count > int64(policy.MaxDescendantCount) {
+			str := fmt.Sprintf("%s would give transaction %v too "+
+				"many descendants: max is %d, has %d",
+				describeTxns(txns), hash,
+				policy.MaxDescendantCount, count)
+			return txRuleError(wire.RejectNonstandard, str)
+		}
+		size := desc.descendants.size + vsize
+		if size > int64(policy.MaxDescendantSize) {
+			str := fmt.Sprintf("%s would exceed the descendant "+
+				"size limit of transaction %v: max is %d, has "+
+				"%d", describeTxns(txns), hash,
+				policy.MaxDescendantSize, size)
+			return txRuleError(wire.RejectNonstandard, str)
+		}
+	}
+
+	return nil
+}
+
+// describeTxns returns a description of the passed transactions, which are
+// either a single transaction or a package, for use in error messages.  A
+// package is described by its last transaction, which is its child.
+func describeTxns(txns []*btcutil.Tx) string {
+	if len(txns) > 1 {
+		return fmt.Sprintf("package with child %v",
+			txns[len(txns)-1].Hash())
+	}
+	return fmt.Sprintf("transaction %v", txns[0].Hash())
+}
+
+// txMemoryUsage returns the estimated amount of memory in bytes used by the
+// passed transaction while it is in the pool.
+func txMemoryUsage(tx *btcutil.Tx) int64 {
+	msgTx := tx.MsgTx()
+	return int64(msgTx.SerializeSize()) + txDescOverhead +
+		int64(len(msgTx.TxIn))*txInOverhead +
+		int64(len(msgTx.TxOut))*txOutOverhead
+}
+
+// minFeeRate returns the minimum fee rate in satoshi per kB transactions must
+// pay to be accepted into the pool in addition to the minimum relay fee.  It is
+// zero unless transactions were evicted from the pool since it was full.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) minFeeRate() int64 {
+	if mp.rollingMinFee == 0 {
+		return 0
+	}
+
+	// The minimum fee rate only starts to decay once a block has been
+	// connected since it was last raised, so the transactions that caused
+	// the pool to fill up have a chance to be mined first.
+	now := time.Now()
+	if !mp.rollingMinFeeDecaying {
+		if mp.cfg.BestHeight() == mp.rollingMinFeeHeight {
+			return int64(math.Round(mp.rollingMinFee))
+		}
+		mp.rollingMinFeeDecaying = true
+		mp.rollingMinFeeUpdated = now
+	}
+
+	incrementalFee := int64(mp.cfg.Policy.MinRelayTxFee)
+	elapsed := now.Sub(mp.rollingMinFeeUpdated)
+	if elapsed > rollingMinFeeUpdateInterval {
+		halfLife := rollingMinFeeHalfLife
+		switch {
+		case mp.usage < mp.cfg.Policy.MaxPoolSize/4:
+			halfLife /= 4
+		case mp.usage < mp.cfg.Policy.MaxPoolSize/2:
+			halfLife /= 2
+		}
+		mp.rollingMinFee /= math.Pow(2, elapsed.Seconds()/
+			halfLife.Seconds())
+		mp.rollingMinFeeUpdated = now
+
+		if mp.rollingMinFee < float64(incrementalFee)/2 {
+			mp.rollingMinFee = 0
+			return 0
+		}
+	}
+
+	feeRate := int64(math.Round(mp.rollingMinFee))
+	if feeRate < incrementalFee {
+		return incrementalFee
+	}
+	return feeRate
+}
+
+// checkPoolMinFee ensures the passed transactions, which are either a single
+// transaction or a package with the passed fee and virtual size in total, pay
+// the minimum fee rate of the pool.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) checkPoolMinFee(txns []*btcutil.Tx, fee, vsize int64) error {
+	minFee := mp.minFeeRate() * vsize / 1000
+	if fee < minFee {
+		str := fmt.Sprintf("%v has %d fees which is under the mempool "+
+			"minimum fee of %d", describeTxns(txns), fee, minFee)
+		return txRuleError(wire.RejectInsufficientFee, str)
+	}
+	return nil
+}
+
+// descendantScore returns the fee rate in satoshi per kB the passed transaction
+// is ranked by for eviction when the pool is full, which is the higher of its
+// own fee rate and that of it along with its descendants in the pool.  This
+// ensures descendants paying lower fee rates are evicted on their own first.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func descendantScore(txD *TxDesc) int64 {
+	score := txD.descendants.fees * 1000 / txD.descendants.size
+	if txD.FeePerKB > score {
+		return txD.FeePerKB
+	}
+	return score
+}
+
+// trimToSize evicts the transactions with the lowest descendant scores along
+// with their descendants until the estimated memory usage of the pool no
+// longer exceeds the maximum.  Ties are broken by evicting the most recently
+// added transaction.  The minimum fee rate of the pool is raised above the fee
+// rate of every evicted package so transactions paying less are not accepted
+// in their place.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) trimToSize() {
+	for mp.usage > mp.cfg.Policy.MaxPoolSize && len(mp.pool) > 0 {
+		worst := mp.evictionQueue.next()
+		stats := worst.descendants
+		feeRate := stats.fees * 1000 / stats.size
+		minFeeRate := feeRate + int64(mp.cfg.Policy.MinRelayTxFee)
+		if float64(minFeeRate) > mp.rollingMinFee {
+			mp.rollingMinFee = float64(minFeeRate)
+			mp.rollingMinFeeHeight = mp.cfg.BestHeight()
+			mp.rollingMinFeeDecaying = false
+		}
+
+		log.Debugf("Evicting transaction %v along with %d descendants "+
+			"(fee_rate=%v sat/kb) since the pool is full",
+			worst.Tx.Hash(), stats.count-1, feeRate)
+		mp.removeTransaction(worst.Tx, true)
+	}
+}
+
+// poolFullError returns the error for the passed transaction having been
+// evicted right after it was added since the pool is full.
+func poolFullError(tx *btcutil.Tx) error {
+	str := fmt.Sprintf("transaction %v was evicted since the mempool is "+
+		"full", tx.Hash())
+	return txRuleError(wire.RejectInsufficientFee, str)
+}
+
 // checkPoolDoubleSpend checks whether or not the passed transaction is
 // attempting to spend coins already spent by other transactions in the pool.
 // If it does, we'll check whether each of those transactions are signaling for
@@ -825,35 +1207,45 @@ func (mp *TxPool) FetchTransaction(txHash *chainhash.Hash) (*btcutil.Tx, error)
 	return nil, fmt.Errorf("transaction is not in the pool")
 }
 
-// validateReplacement determines whether a transaction is deemed as a valid
-// replacement of all of its conflicts according to the RBF policy. If it is
-// valid, no error is returned. Otherwise, an error is returned indicating what
-// went wrong.
+// validateReplacement determines whether the passed transactions, which are
+// either a single transaction or a package, are deemed as a valid replacement
+// of all of their conflicts according to the RBF policy given the fee they pay
+// and their virtual size in total. If they are valid, the conflicts are
+// returned. Otherwise, an error is returned indicating what went wrong.
 //
 // This function MUST be called with the mempool lock held (for reads).
-func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
-	txFee int64) (map[chainhash.Hash]*btcutil.Tx, error) {
+func (mp *TxPool) validateReplacement(txns []*btcutil.Tx, fee,
+	vsize int64) (map[chainhash.Hash]*btcutil.Tx, error) {
+
+	replacement := describeTxns(txns)
 
 	// First, we'll make sure the set of conflicting transactions doesn't
 	// exceed the maximum allowed.
-	conflicts := mp.txConflicts(tx)
+	conflicts := make(map[chainhash.Hash]*btcutil.Tx)
+	for _, tx := range txns {
+		for hash, conflict := range mp.txConflicts(tx) {
+			conflicts[hash] = conflict
+		}
+	}
 	if len(conflicts) > MaxReplacementEvictions {
-		str := fmt.Sprintf("replacement transaction %v evicts more "+
-			"transactions than permitted: max is %v, evicts %v",
-			tx.Hash(), MaxReplacementEvictions, len(conflicts))
+		str := fmt.Sprintf("replacement %s evicts more transactions "+
+			"than permitted: max is %v, evicts %v", replacement,
+			MaxReplacementEvictions, len(conflicts))
 		return nil, txRuleError(wire.RejectNonstandard, str)
 	}
 
 	// The set of conflicts (transactions we'll replace) and ancestors
 	// should not overlap, otherwise the replacement would be spending an
 	// output that no longer exists.
-	for ancestorHash := range mp.txAncestors(tx, nil) {
-		if _, ok := conflicts[ancestorHash]; !ok {
-			continue
+	for _, tx := range txns {
+		for ancestorHash := range mp.txAncestors(tx, nil) {
+			if _, ok := conflicts[ancestorHash]; !ok {
+				continue
+			}
+			str := fmt.Sprintf("replacement %s spends parent "+
+				"transaction %v", replacement, ancestorHash)
+			return nil, txRuleError(wire.RejectInvalid, str)
 		}
-		str := fmt.Sprintf("replacement transaction %v spends parent "+
-			"transaction %v", tx.Hash(), ancestorHash)
-		return nil, txRuleError(wire.RejectInvalid, str)
 	}
 
 	// The replacement should have a higher fee rate than each of the
@@ -865,17 +1257,15 @@ func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
 	// block. Requiring that the fee rate always be increased is also an
 	// easy-to-reason about way to prevent DoS attacks via replacements.
 	var (
-		txSize           = GetTxVirtualSize(tx)
-		txFeeRate        = txFee * 1000 / txSize
+		feeRate          = fee * 1000 / vsize
 		conflictsFee     int64
 		conflictsParents = make(map[chainhash.Hash]struct{})
 	)
 	for hash, conflict := range conflicts {
-		if txFeeRate <= mp.pool[hash].FeePerKB {
-			str := fmt.Sprintf("replacement transaction %v has an "+
-				"insufficient fee rate: needs more than %v, "+
-				"has %v", tx.Hash(), mp.pool[hash].FeePerKB,
-				txFeeRate)
+		if feeRate <= mp.pool[hash].FeePerKB {
+			str := fmt.Sprintf("replacement %s has an insufficient "+
+				"fee rate: needs more than %v, has %v",
+				replacement, mp.pool[hash].FeePerKB, feeRate)
 			return nil, txRuleError(wire.RejectInsufficientFee, str)
 		}
 
@@ -891,41 +1281,94 @@ func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
 	// It should also have an absolute fee greater than all of the
 	// transactions it intends to replace and pay for its own bandwidth,
 	// which is determined by our minimum relay fee.
-	minFee := calcMinRequiredTxRelayFee(txSize, mp.cfg.Policy.MinRelayTxFee)
-	if txFee < conflictsFee+minFee {
-		str := fmt.Sprintf("replacement transaction %v has an "+
-			"insufficient absolute fee: needs %v, has %v",
-			tx.Hash(), conflictsFee+minFee, txFee)
+	minFee := calcMinRequiredTxRelayFee(vsize, mp.cfg.Policy.MinRelayTxFee)
+	if fee < conflictsFee+minFee {
+		str := fmt.Sprintf("replacement %s has an insufficient "+
+			"absolute fee: needs %v, has %v", replacement,
+			conflictsFee+minFee, fee)
 		return nil, txRuleError(wire.RejectInsufficientFee, str)
 	}
 
 	// Finally, it should not spend any new unconfirmed outputs, other than
 	// the ones already included in the parents of the conflicting
 	// transactions it'll replace.
-	for _, txIn := range tx.MsgTx().TxIn {
-		if _, ok := conflictsParents[txIn.PreviousOutPoint.Hash]; ok {
-			continue
-		}
-		// Confirmed outputs are valid to spend in the replacement.
-		if _, ok := mp.pool[txIn.PreviousOutPoint.Hash]; !ok {
-			continue
+	for _, tx := range txns {
+		for _, txIn := range tx.MsgTx().TxIn {
+			hash := txIn.PreviousOutPoint.Hash
+			if _, ok := conflictsParents[hash]; ok {
+				continue
+			}
+			// Confirmed outputs and those of the other
+			// transactions of a package are valid to spend in the
+			// replacement.
+			if _, ok := mp.pool[hash]; !ok {
+				continue
+			}
+			str := fmt.Sprintf("replacement %s spends new "+
+				"unconfirmed input %v not found in conflicting "+
+				"transactions", replacement,
+				txIn.PreviousOutPoint)
+			return nil, txRuleError(wire.RejectInvalid, str)
 		}
-		str := fmt.Sprintf("replacement transaction spends new "+
-			"unconfirmed input %v not found in conflicting "+
-			"transactions", txIn.PreviousOutPoint)
-		return nil, txRuleError(wire.RejectInvalid, str)
 	}
 
 	return conflicts, nil
 }
 
-// maybeAcceptTransaction is the internal function which implements the public
-// MaybeAcceptTransaction.  See the comment for MaybeAcceptTransaction for
-// more details.
+// acceptResult houses the outcome of checking whether a transaction can be
+// accepted into the pool along with the details needed to add it.
+type acceptResult struct {
+	// missingParents holds the hashes of the transactions the transaction
+	// spends outputs of which are unknown or fully spent.  None of the
+	// other fields are set when it is not empty.
+	missingParents []*chainhash.Hash
+
+	// utxoView provides the outputs spent by the transaction.
+	utxoView *blockchain.UtxoViewpoint
+
+	// isReplacement indicates the transaction spends outputs that are
+	// already spent by transactions in the pool.
+	isReplacement bool
+
+	// conflicts are the transactions in the pool the transaction replaces
+	// along with their descendants.  It is not set when the checks related
+	// to fees are deferred.
+	conflicts map[chainhash.Hash]*btcutil.Tx
+
+	// bestHeight is the height of the main chain the checks were performed
+	// against.
+	bestHeight int32
+
+	// fee and vsize are the fee paid by the transaction and its virtual
+	// size.
+	fee   int64
+	vsize int64
+}
+
+// packageContext describes the package of transactions a transaction is
+// checked along with.
+type packageContext struct {
+	// txns are the transactions of the package that precede the one being
+	// checked and are not in the pool.  Their outputs may be spent by it.
+	txns map[chainhash.Hash]*btcutil.Tx
+
+	// deferFees indicates the checks related to fees, including those of
+	// replacements, are performed for the package as a whole instead.
+	deferFees bool
+}
+
+// checkMempoolAcceptance performs all of the checks that determine whether the
+// passed transaction may be accepted into the pool without modifying it.
+//
+// The package context is nil unless the transaction is checked along with
+// other transactions that are not in the pool, such as a chain of them.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejectDupOrphans bool) ([]*chainhash.Hash, *TxDesc, error) {
+func (mp *TxPool) checkMempoolAcceptance(tx *btcutil.Tx, isNew, rateLimit,
+	rejectDupOrphans bool, pkg *packageContext) (*acceptResult, error) {
+
 	txHash := tx.Hash()
+	checkFees := pkg == nil || !pkg.deferFees
 
 	// If a transaction has witness data, and segwit isn't active yet, If
 	// segwit isn't active yet, then we won't accept it into the mempool as
@@ -933,7 +1376,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 	if tx.MsgTx().HasWitness() {
 		segwitActive, err := mp.cfg.IsDeploymentActive(chaincfg.DeploymentSegwit)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 
 		if !segwitActive {
@@ -945,7 +1388,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 			}
 			str := fmt.Sprintf("transaction %v has witness data, "+
 				"but segwit isn't active yet%s", txHash, simnetHint)
-			return nil, nil, txRuleError(wire.RejectNonstandard, str)
+			return nil, txRuleError(wire.RejectNonstandard, str)
 		}
 	}
 
@@ -957,7 +1400,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		mp.isOrphanInPool(txHash)) {
 
 		str := fmt.Sprintf("already have transaction %v", txHash)
-		return nil, nil, txRuleError(wire.RejectDuplicate, str)
+		return nil, txRuleError(wire.RejectDuplicate, str)
 	}
 
 	// Perform preliminary sanity checks on the transaction.  This makes
@@ -966,16 +1409,16 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 	err := blockchain.CheckTransactionSanity(tx)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
-			return nil, nil, chainRuleError(cerr)
+			return nil, chainRuleError(cerr)
 		}
-		return nil, nil, err
+		return nil, err
 	}
 
 	// A standalone transaction must not be a coinbase transaction.
 	if blockchain.IsCoinBase(tx) {
 		str := fmt.Sprintf("transaction %v is an individual coinbase",
 			txHash)
-		return nil, nil, txRuleError(wire.RejectInvalid, str)
+		return nil, txRuleError(wire.RejectInvalid, str)
 	}
 
 	// Get the current height of the main chain.  A standalone transaction
@@ -1002,7 +1445,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 			}
 			str := fmt.Sprintf("transaction %v is not standard: %v",
 				txHash, err)
-			return nil, nil, txRuleError(rejectCode, str)
+			return nil, txRuleError(rejectCode, str)
 		}
 	}
 
@@ -1017,7 +1460,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 	// spend data and prevents double spends.
 	isReplacement, err := mp.checkPoolDoubleSpend(tx)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	// Fetch all of the unspent transaction outputs referenced by the inputs
@@ -1027,9 +1470,24 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 	utxoView, err := mp.fetchInputUtxos(tx)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
-			return nil, nil, chainRuleError(cerr)
+			return nil, chainRuleError(cerr)
+		}
+		return nil, err
+	}
+
+	// Populate any inputs that are still missing from the transactions
+	// that are being checked along with this one.
+	for _, txIn := range tx.MsgTx().TxIn {
+		prevOut := &txIn.PreviousOutPoint
+		entry := utxoView.LookupEntry(*prevOut)
+		if pkg == nil || (entry != nil && !entry.IsSpent()) {
+			continue
+		}
+
+		if pkgTx, exists := pkg.txns[prevOut.Hash]; exists {
+			utxoView.AddTxOut(pkgTx, prevOut.Index,
+				mining.UnminedHeight)
 		}
-		return nil, nil, err
 	}
 
 	// Don't allow the transaction if it exists in the main chain and is
@@ -1039,7 +1497,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		prevOut.Index = uint32(txOutIdx)
 		entry := utxoView.LookupEntry(prevOut)
 		if entry != nil && !entry.IsSpent() {
-			return nil, nil, txRuleError(wire.RejectDuplicate,
+			return nil, txRuleError(wire.RejectDuplicate,
 				"transaction already exists")
 		}
 		utxoView.RemoveEntry(prevOut)
@@ -1061,7 +1519,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		}
 	}
 	if len(missingParents) > 0 {
-		return missingParents, nil, nil
+		return &acceptResult{missingParents: missingParents}, nil
 	}
 
 	// Don't allow the transaction into the mempool unless its sequence
@@ -1070,13 +1528,13 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 	sequenceLock, err := mp.cfg.CalcSequenceLock(tx, utxoView)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
-			return nil, nil, chainRuleError(cerr)
+			return nil, chainRuleError(cerr)
 		}
-		return nil, nil, err
+		return nil, err
 	}
 	if !blockchain.SequenceLockActive(sequenceLock, nextBlockHeight,
 		medianTimePast) {
-		return nil, nil, txRuleError(wire.RejectNonstandard,
+		return nil, txRuleError(wire.RejectNonstandard,
 			"transaction's sequence locks on inputs not met")
 	}
 
@@ -1088,9 +1546,9 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		utxoView, mp.cfg.ChainParams)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
-			return nil, nil, chainRuleError(cerr)
+			return nil, chainRuleError(cerr)
 		}
-		return nil, nil, err
+		return nil, err
 	}
 
 	// Don't allow transactions with non-standard inputs if the network
@@ -1107,7 +1565,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 			}
 			str := fmt.Sprintf("transaction %v has a non-standard "+
 				"input: %v", txHash, err)
-			return nil, nil, txRuleError(rejectCode, str)
+			return nil, txRuleError(rejectCode, str)
 		}
 	}
 
@@ -1124,14 +1582,14 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 	sigOpCost, err := blockchain.GetSigOpCost(tx, false, utxoView, true, true)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
-			return nil, nil, chainRuleError(cerr)
+			return nil, chainRuleError(cerr)
 		}
-		return nil, nil, err
+		return nil, err
 	}
 	if sigOpCost > mp.cfg.Policy.MaxSigOpCostPerTx {
 		str := fmt.Sprintf("transaction %v sigop cost is too high: %d > %d",
 			txHash, sigOpCost, mp.cfg.Policy.MaxSigOpCostPerTx)
-		return nil, nil, txRuleError(wire.RejectNonstandard, str)
+		return nil, txRuleError(wire.RejectNonstandard, str)
 	}
 
 	// Don't allow transactions with fees too low to get into a mined block.
@@ -1148,25 +1606,41 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 	serializedSize := GetTxVirtualSize(tx)
 	minFee := calcMinRequiredTxRelayFee(serializedSize,
 		mp.cfg.Policy.MinRelayTxFee)
-	if serializedSize >= (DefaultBlockPrioritySize-1000) && txFee < minFee {
+	if checkFees && serializedSize >= (DefaultBlockPrioritySize-1000) &&
+		txFee < minFee {
+
 		str := fmt.Sprintf("transaction %v has %d fees which is under "+
 			"the required amount of %d", txHash, txFee,
 			minFee)
-		return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
+		return nil, txRuleError(wire.RejectInsufficientFee, str)
 	}
 
 	// Require that free transactions have sufficient priority to be mined
 	// in the next block.  Transactions which are being added back to the
 	// memory pool from blocks that have been disconnected during a reorg
 	// are exempted.
-	if isNew && !mp.cfg.Policy.DisableRelayPriority && txFee < minFee {
+	if checkFees && isNew && !mp.cfg.Policy.DisableRelayPriority &&
+		txFee < minFee {
+
 		currentPriority := mining.CalcPriority(tx.MsgTx(), utxoView,
 			nextBlockHeight)
 		if currentPriority <= mining.MinHighPriority {
 			str := fmt.Sprintf("transaction %v has insufficient "+
 				"priority (%g <= %g)", txHash,
 				currentPriority, mining.MinHighPriority)
-			return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
+			return nil, txRuleError(wire.RejectInsufficientFee, str)
+		}
+	}
+
+	// Don't allow transactions paying less than the minimum fee rate of
+	// the pool, which is raised when transactions are evicted since it is
+	// full.  Transactions which are being added back to the memory pool
+	// from blocks that have been disconnected during a reorg are exempted.
+	if checkFees && isNew {
+		err := mp.checkPoolMinFee([]*btcutil.Tx{tx}, txFee,
+			serializedSize)
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -1184,7 +1658,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		if mp.pennyTotal >= mp.cfg.Policy.FreeTxRelayLimit*10*1000 {
 			str := fmt.Sprintf("transaction %v has been rejected "+
 				"by the rate limiter due to low fees", txHash)
-			return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
+			return nil, txRuleError(wire.RejectInsufficientFee, str)
 		}
 		oldTotal := mp.pennyTotal
 
@@ -1194,13 +1668,25 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 			mp.cfg.Policy.FreeTxRelayLimit*10*1000)
 	}
 
+	// Don't allow the transaction to create chains of unconfirmed
+	// transactions in the pool that exceed the limits of the policy.  When
+	// the checks related to fees are deferred, the limits are checked for
+	// the package as a whole instead since its transactions are related.
+	if checkFees {
+		err := mp.checkChainLimits([]*btcutil.Tx{tx}, serializedSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// If the transaction has any conflicts, and we've made it this far, then
 	// we're processing a potential replacement.
 	var conflicts map[chainhash.Hash]*btcutil.Tx
-	if isReplacement {
-		conflicts, err = mp.validateReplacement(tx, txFee)
+	if isReplacement && checkFees {
+		conflicts, err = mp.validateReplacement([]*btcutil.Tx{tx},
+			txFee, serializedSize)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 	}
 
@@ -1211,33 +1697,94 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		mp.cfg.HashCache)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
-			return nil, nil, chainRuleError(cerr)
+			return nil, chainRuleError(cerr)
 		}
+		return nil, err
+	}
+
+	return &acceptResult{
+		utxoView:      utxoView,
+		isReplacement: isReplacement,
+		conflicts:     conflicts,
+		bestHeight:    bestHeight,
+		fee:           txFee,
+		vsize:         serializedSize,
+	}, nil
+}
+
+// maybeAcceptTransaction is the internal function which implements the public
+// MaybeAcceptTransaction.  See the comment for MaybeAcceptTransaction for
+// more details.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejectDupOrphans bool) ([]*chainhash.Hash, *TxDesc, error) {
+	result, err := mp.checkMempoolAcceptance(tx, isNew, rateLimit,
+		rejectDupOrphans, nil)
+	if err != nil {
 		return nil, nil, err
 	}
+	if len(result.missingParents) > 0 {
+		return result.missingParents, nil, nil
+	}
 
 	// Now that we've deemed the transaction as valid, we can add it to the
-	// mempool. If it ended up replacing any transactions, we'll remove them
-	// first.
-	for _, conflict := range conflicts {
-		log.Debugf("Replacing transaction %v (fee_rate=%v sat/kb) "+
-			"with %v (fee_rate=%v sat/kb)\n", conflict.Hash(),
-			mp.pool[*conflict.Hash()].FeePerKB, tx.Hash(),
-			txFee*1000/serializedSize)
+	// mempool.
+	mp.removeConflicts(result.conflicts, tx, result.fee*1000/result.vsize)
+	txD := mp.addTransaction(result.utxoView, tx, result.bestHeight,
+		result.fee)
+
+	// Record the transaction for fee estimation if enabled.  Transactions
+	// added back from disconnected blocks are excluded since it is not
+	// known how long they have been waiting for, as are those spending
+	// other transactions in the pool since their fee rate alone does not
+	// determine when they are confirmed.
+	if mp.cfg.FeeEstimator != nil && isNew && txD.ancestors.count == 1 {
+		mp.cfg.FeeEstimator.ObserveTransaction(txD)
+	}
 
-		// The conflict set should already include the descendants for
-		// each one, so we don't need to remove the redeemers within
-		// this call as they'll be removed eventually.
-		mp.removeTransaction(conflict, false)
+	// Evict transactions if the pool is full now, which may include the
+	// transaction itself when it pays less than the others.  Transactions
+	// added back from disconnected blocks are not evicted one by one since
+	// the ones added later may pay for them, so the caller trims the pool
+	// once all of them are added instead.
+	if isNew {
+		mp.trimToSize()
+		if !mp.isTransactionInPool(tx.Hash()) {
+			return nil, nil, poolFullError(tx)
+		}
 	}
-	txD := mp.addTransaction(utxoView, tx, bestHeight, txFee)
 
-	log.Debugf("Accepted transaction %v (pool size: %v)", txHash,
+	log.Debugf("Accepted transaction %v (pool size: %v)", tx.Hash(),
 		len(mp.pool))
 
 	return nil, txD, nil
 }
 
+// removeConflicts removes the passed conflicts, which are the transactions in
+// the pool that are replaced by the passed transaction with the given fee rate,
+// or the package with it as its child.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) removeConflicts(conflicts map[chainhash.Hash]*btcutil.Tx,
+	tx *btcutil.Tx, feeRate int64) {
+
+	for _, conflict := range conflicts {
+		// The conflict set already includes the descendants of each
+		// one, but removing them along with it keeps the cached state
+		// of their ancestors accurate regardless of the order they are
+		// removed in.  The ones removed this way are skipped.
+		txD, ok := mp.pool[*conflict.Hash()]
+		if !ok {
+			continue
+		}
+		log.Debugf("Replacing transaction %v (fee_rate=%v sat/kb) "+
+			"with %v (fee_rate=%v sat/kb)\n", conflict.Hash(),
+			txD.FeePerKB, tx.Hash(), feeRate)
+
+		mp.removeTransaction(conflict, true)
+	}
+}
+
 // MaybeAcceptTransaction is the main workhorse for handling insertion of new
 // free-standing transactions into a memory pool.  It includes functionality
 // such as rejecting duplicate transactions, ensuring transactions follow all
@@ -1248,6 +1795,10 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 // parent is returned.  Use ProcessTransaction instead if new orphans should
 // be added to the orphan pool.
 //
+// The pool is not trimmed to its maximum size when isNew is false, so callers
+// adding back the transactions of a disconnected block must call TrimToSize
+// once all of them are added.
+//
 // This function is safe for concurrent access.
 func (mp *TxPool) MaybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit bool) ([]*chainhash.Hash, *TxDesc, error) {
 	// Protect concurrent access.
@@ -1258,6 +1809,104 @@ func (mp *TxPool) MaybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit bool)
 	return hashes, txD, err
 }
 
+// TestAcceptResult describes whether a transaction passed to TestAccept would
+// be accepted into the pool.
+type TestAcceptResult struct {
+	// Tx is the transaction that was tested.
+	Tx *btcutil.Tx
+
+	// Err is the reason the transaction would be rejected, or nil when it
+	// would be accepted.  It is typically a RuleError.
+	Err error
+
+	// Fee and VSize are the fee paid by the transaction and its virtual
+	// size.  They are only set when it would be accepted.
+	Fee   int64
+	VSize int64
+}
+
+// TestAccept checks whether the passed transactions would be accepted into the
+// pool if they were processed in the given order without adding them.  Each
+// transaction may spend the outputs of the transactions before it that would
+// be accepted, which allows testing a chain of transactions that depend on one
+// another.
+//
+// Transactions with a fee rate above the passed maximum in satoshi per kB are
+// rejected.  A maximum of zero disables the check.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) TestAccept(txns []*btcutil.Tx,
+	maxFeeRate btcutil.Amount) []*TestAcceptResult {
+
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	results := make([]*TestAcceptResult, 0, len(txns))
+	accepted := make(map[chainhash.Hash]*btcutil.Tx, len(txns))
+	spentBy := make(map[wire.OutPoint]*btcutil.Tx)
+	for _, tx := range txns {
+		res := &TestAcceptResult{Tx: tx}
+		results = append(results, res)
+
+		if _, ok := accepted[*tx.Hash()]; ok {
+			str := fmt.Sprintf("already have transaction %v",
+				tx.Hash())
+			res.Err = txRuleError(wire.RejectDuplicate, str)
+			continue
+		}
+
+		// Reject transactions that spend the same outputs as a previous
+		// one that would be accepted since only one of them can be.
+		for _, txIn := range tx.MsgTx().TxIn {
+			prevTx, ok := spentBy[txIn.PreviousOutPoint]
+			if !ok {
+				continue
+			}
+			str := fmt.Sprintf("transaction %v spends output %v "+
+				"which is already spent by transaction %v",
+				tx.Hash(), txIn.PreviousOutPoint, prevTx.Hash())
+			res.Err = txRuleError(wire.RejectDuplicate, str)
+			break
+		}
+		if res.Err != nil {
+			continue
+		}
+
+		pkg := &packageContext{txns: accepted}
+		result, err := mp.checkMempoolAcceptance(tx, true, false, true,
+			pkg)
+		if err != nil {
+			res.Err = err
+			continue
+		}
+
+		if len(result.missingParents) > 0 {
+			res.Err = orphanError(tx, result.missingParents)
+			continue
+		}
+
+		if maxFeeRate > 0 {
+			maxFee := result.vsize * int64(maxFeeRate) / 1000
+			if result.fee > maxFee {
+				str := fmt.Sprintf("transaction %v has %d fees "+
+					"which is above the maximum of %d",
+					tx.Hash(), result.fee, maxFee)
+				res.Err = txRuleError(wire.RejectNonstandard, str)
+				continue
+			}
+		}
+
+		res.Fee = result.fee
+		res.VSize = result.vsize
+		accepted[*tx.Hash()] = tx
+		for _, txIn := range tx.MsgTx().TxIn {
+			spentBy[txIn.PreviousOutPoint] = tx
+		}
+	}
+
+	return results
+}
+
 // processOrphans is the internal function which implements the public
 // ProcessOrphans.  See the comment for ProcessOrphans for more details.
 //
@@ -1359,6 +2008,22 @@ func (mp *TxPool) ProcessOrphans(acceptedTx *btcutil.Tx) []*TxDesc {
 	return acceptedTxns
 }
 
+// orphanError returns the error for the passed transaction being rejected since
+// it is an orphan with the passed missing parents.
+func orphanError(tx *btcutil.Tx, missingParents []*chainhash.Hash) error {
+	// Only use the first missing parent transaction in the error message.
+	//
+	// NOTE: RejectDuplicate is really not an accurate reject code here, but
+	// it matches the reference implementation and there isn't a better
+	// choice due to the limited number of reject codes.  Missing inputs is
+	// assumed to mean they are already spent which is not really always the
+	// case.
+	str := fmt.Sprintf("orphan transaction %v references outputs of "+
+		"unknown or fully-spent transaction %v", tx.Hash(),
+		missingParents[0])
+	return txRuleError(wire.RejectDuplicate, str)
+}
+
 // ProcessTransaction is the main workhorse for handling insertion of new
 // free-standing transactions into the memory pool.  It includes functionality
 // such as rejecting duplicate transactions, ensuring transactions follow all
@@ -1403,19 +2068,7 @@ func (mp *TxPool) ProcessTransaction(tx *btcutil.Tx, allowOrphan, rateLimit bool
 	// The transaction is an orphan (has inputs missing).  Reject
 	// it if the flag to allow orphans is not set.
 	if !allowOrphan {
-		// Only use the first missing parent transaction in
-		// the error message.
-		//
-		// NOTE: RejectDuplicate is really not an accurate
-		// reject code here, but it matches the reference
-		// implementation and there isn't a better choice due
-		// to the limited number of reject codes.  Missing
-		// inputs is assumed to mean they are already spent
-		// which is not really always the case.
-		str := fmt.Sprintf("orphan transaction %v references "+
-			"outputs of unknown or fully-spent "+
-			"transaction %v", tx.Hash(), missingParents[0])
-		return nil, txRuleError(wire.RejectDuplicate, str)
+		return nil, orphanError(tx, missingParents)
 	}
 
 	// Potentially add the orphan transaction to the orphan pool.
@@ -1435,6 +2088,49 @@ func (mp *TxPool) Count() int {
 	return count
 }
 
+// TrimToSize evicts the transactions with the lowest descendant scores until
+// the pool no longer exceeds its maximum size.  It must be called after adding
+// back the transactions of a disconnected block since they don't cause the
+// pool to be trimmed when they are added.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) TrimToSize() {
+	mp.mtx.Lock()
+	mp.trimToSize()
+	mp.mtx.Unlock()
+}
+
+// Usage returns the estimated amount of memory in bytes used by the
+// transactions in the main pool.  It does not include the orphan pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Usage() int64 {
+	mp.mtx.RLock()
+	usage := mp.usage
+	mp.mtx.RUnlock()
+
+	return usage
+}
+
+// MinFeeRate returns the minimum fee rate in satoshi per kB transactions must
+// pay to be accepted into the main pool.  It is the minimum relay fee unless
+// the pool was full recently, in which case it is raised above the fee rate of
+// the transactions that were evicted and gradually decays again.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MinFeeRate() int64 {
+	// The minimum fee rate of the pool decays as a side effect.
+	mp.mtx.Lock()
+	feeRate := mp.minFeeRate()
+	mp.mtx.Unlock()
+
+	minRelayFee := int64(mp.cfg.Policy.MinRelayTxFee)
+	if feeRate < minRelayFee {
+		return minRelayFee
+	}
+	return feeRate
+}
+
 // TxHashes returns a slice of hashes for all the transactions in the memory
 // pool.
 //
@@ -1537,6 +2233,129 @@ func (mp *TxPool) RawMempoolVerbose() map[string]*btcjson.GetRawMempoolVerboseRe
 	return result
 }
 
+// mempoolEntry returns the passed transaction descriptor as a fully populated
+// btcjson result.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) mempoolEntry(desc *TxDesc) *btcjson.GetMempoolEntryResult {
+	tx := desc.Tx
+	fee := btcutil.Amount(desc.Fee).ToBTC()
+	entry := &btcjson.GetMempoolEntryResult{
+		VSize:           int32(GetTxVirtualSize(tx)),
+		Size:            int32(tx.MsgTx().SerializeSize()),
+		Weight:          blockchain.GetTransactionWeight(tx),
+		Fee:             fee,
+		ModifiedFee:     fee,
+		Time:            desc.Added.Unix(),
+		Height:          int64(desc.Height),
+		DescendantCount: desc.descendants.count,
+		DescendantSize:  desc.descendants.size,
+		DescendantFees:  float64(desc.descendants.fees),
+		AncestorCount:   desc.ancestors.count,
+		AncestorSize:    desc.ancestors.size,
+		AncestorFees:    float64(desc.ancestors.fees),
+		WTxId:           tx.WitnessHash().String(),
+		Fees: btcjson.MempoolFees{
+			Base:     fee,
+			Modified: fee,
+			Ancestor: btcutil.Amount(desc.ancestors.fees).ToBTC(),
+			Descendant: btcutil.Amount(
+				desc.descendants.fees).ToBTC(),
+		},
+		Depends:           make([]string, 0),
+		SpentBy:           make([]string, 0),
+		BIP125Replaceable: mp.signalsReplacement(tx, nil),
+	}
+
+	parents := make(map[chainhash.Hash]struct{})
+	for _, txIn := range tx.MsgTx().TxIn {
+		hash := txIn.PreviousOutPoint.Hash
+		if _, ok := parents[hash]; ok || !mp.haveTransaction(&hash) {
+			continue
+		}
+		parents[hash] = struct{}{}
+		entry.Depends = append(entry.Depends, hash.String())
+	}
+	children := make(map[chainhash.Hash]struct{})
+	op := wire.OutPoint{Hash: *tx.Hash()}
+	for i := range tx.MsgTx().TxOut {
+		op.Index = uint32(i)
+		child, ok := mp.outpoints[op]
+		if !ok {
+			continue
+		}
+		if _, ok := children[*child.Hash()]; ok {
+			continue
+		}
+		children[*child.Hash()] = struct{}{}
+		entry.SpentBy = append(entry.SpentBy, child.Hash().String())
+	}
+	sort.Strings(entry.Depends)
+	sort.Strings(entry.SpentBy)
+
+	return entry
+}
+
+// MempoolEntry returns the transaction with the passed hash in the pool as a
+// fully populated btcjson result, including the state of its unconfirmed
+// ancestors and its descendants in the pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MempoolEntry(hash *chainhash.Hash) (*btcjson.GetMempoolEntryResult, error) {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	desc, ok := mp.pool[*hash]
+	if !ok {
+		return nil, fmt.Errorf("transaction is not in the pool")
+	}
+	return mp.mempoolEntry(desc), nil
+}
+
+// MempoolAncestors returns all of the unconfirmed ancestors in the pool of the
+// transaction with the passed hash as fully populated btcjson results keyed by
+// their hashes.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MempoolAncestors(hash *chainhash.Hash) (map[string]*btcjson.GetMempoolEntryResult, error) {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	desc, ok := mp.pool[*hash]
+	if !ok {
+		return nil, fmt.Errorf("transaction is not in the pool")
+	}
+	return mp.mempoolEntries(mp.txAncestors(desc.Tx, nil)), nil
+}
+
+// MempoolDescendants returns all of the descendants in the pool of the
+// transaction with the passed hash as fully populated btcjson results keyed by
+// their hashes.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MempoolDescendants(hash *chainhash.Hash) (map[string]*btcjson.GetMempoolEntryResult, error) {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	desc, ok := mp.pool[*hash]
+	if !ok {
+		return nil, fmt.Errorf("transaction is not in the pool")
+	}
+	return mp.mempoolEntries(mp.txDescendants(desc.Tx, nil)), nil
+}
+
+// mempoolEntries returns the passed transactions in the pool as fully populated
+// btcjson results keyed by their hashes.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) mempoolEntries(txns map[chainhash.Hash]*btcutil.Tx) map[string]*btcjson.GetMempoolEntryResult {
+	entries := make(map[string]*btcjson.GetMempoolEntryResult, len(txns))
+	for hash := range txns {
+		entries[hash.String()] = mp.mempoolEntry(mp.pool[hash])
+	}
+	return entries
+}
+
 // LastUpdated returns the last time a transaction was added to or removed from
 // the main pool.  It does not include the orphan pool.
 //
@@ -1548,8 +2367,27 @@ func (mp *TxPool) LastUpdated() time.Time {
 // New returns a new memory pool for validating and storing standalone
 // transactions until they are mined into a block.
 func New(cfg *Config) *TxPool {
+	// Use the default chain and size limits for the ones that are not set.
+	poolCfg := *cfg
+	policy := &poolCfg.Policy
+	if policy.MaxAncestorCount == 0 {
+		policy.MaxAncestorCount = DefaultMaxAncestorCount
+	}
+	if policy.MaxAncestorSize == 0 {
+		policy.MaxAncestorSize = DefaultMaxAncestorSize
+	}
+	if policy.MaxDescendantCount == 0 {
+		policy.MaxDescendantCount = DefaultMaxDescendantCount
+	}
+	if policy.MaxDescendantSize == 0 {
+		policy.MaxDescendantSize = DefaultMaxDescendantSize
+	}
+	if policy.MaxPoolSize == 0 {
+		policy.MaxPoolSize = DefaultMaxPoolSize
+	}
+
 	return &TxPool{
-		cfg:            *cfg,
+		cfg:            poolCfg,
 		pool:           make(map[chainhash.Hash]*TxDesc),
 		orphans:        make(map[chainhash.Hash]*orphanTx),
 		orphansByPrev:  make(map[wire.OutPoint]map[chainhash.Hash]*btcutil.Tx),
@@ -1452,6 +1452,381 @@ func TestAncestorsDescendants(t *testing.T) {
 	}
 }
 
+// TestChainStats ensures the cached ancestor and descendant state of the
+// transactions in the pool is kept up to date as transactions are added and
+// removed, including when a transaction is added back to the pool after its
+// descendants.
+func TestChainStats(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+
+	// stats returns the state of the passed transactions as a whole.
+	stats := func(txns ...*btcutil.Tx) chainStats {
+		var s chainStats
+		for _, tx := range txns {
+			s.add(GetTxVirtualSize(tx),
+				harness.txPool.pool[*tx.Hash()].Fee)
+		}
+		return s
+	}
+	checkStats := func(desc string, tx *btcutil.Tx, ancestors,
+		descendants chainStats) {
+
+		t.Helper()
+		txD := harness.txPool.pool[*tx.Hash()]
+		if txD.ancestors != ancestors {
+			t.Fatalf("%s: unexpected ancestor state: got %+v, "+
+				"want %+v", desc, txD.ancestors, ancestors)
+		}
+		if txD.descendants != descendants {
+			t.Fatalf("%s: unexpected descendant state: got %+v, "+
+				"want %+v", desc, txD.descendants, descendants)
+		}
+	}
+
+	// Create a chain where B spends A and C spends B, along with D that
+	// spends A as well.
+	coinbase := ctx.addCoinbaseTx(2)
+	a := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 2, 1000, false, false)
+	b := ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(a, 0)}, 1,
+		2000, false, false)
+	c := ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(b, 0)}, 1,
+		3000, false, false)
+	d := ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(a, 1)}, 1,
+		4000, false, false)
+	checkStats("add", a, stats(a), stats(a, b, c, d))
+	checkStats("add", b, stats(a, b), stats(b, c))
+	checkStats("add", c, stats(a, b, c), stats(c))
+	checkStats("add", d, stats(a, d), stats(d))
+
+	entry, err := harness.txPool.MempoolEntry(b.Hash())
+	if err != nil {
+		t.Fatalf("MempoolEntry: %v", err)
+	}
+	if entry.AncestorCount != 2 || entry.DescendantCount != 2 ||
+		entry.Fees.Ancestor != 0.00003 ||
+		!reflect.DeepEqual(entry.Depends, []string{a.Hash().String()}) ||
+		!reflect.DeepEqual(entry.SpentBy, []string{c.Hash().String()}) {
+
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+	descendants, err := harness.txPool.MempoolDescendants(a.Hash())
+	if err != nil {
+		t.Fatalf("MempoolDescendants: %v", err)
+	}
+	if len(descendants) != 3 || descendants[c.Hash().String()] == nil {
+		t.Fatalf("unexpected descendants: %v", descendants)
+	}
+	ancestors, err := harness.txPool.MempoolAncestors(a.Hash())
+	if err != nil {
+		t.Fatalf("MempoolAncestors: %v", err)
+	}
+	if len(ancestors) != 0 {
+		t.Fatalf("unexpected ancestors: %v", ancestors)
+	}
+	if _, err := harness.txPool.MempoolEntry(coinbase.Hash()); err == nil {
+		t.Fatal("MempoolEntry: no error for transaction not in pool")
+	}
+
+	// Removing B along with its redeemers must update A, while removing A
+	// without them, as when it is mined, must update D.
+	harness.txPool.RemoveTransaction(b, true)
+	checkStats("remove redeemers", a, stats(a), stats(a, d))
+	harness.txPool.RemoveTransaction(a, false)
+	harness.chain.utxos.AddTxOuts(a, harness.chain.BestHeight()+1)
+	checkStats("remove mined", d, stats(d), stats(d))
+
+	// Create E that spends both D and the confirmed A, then add A back to
+	// the pool as if the block it was mined in was disconnected.
+	e := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(a, 0), txOutToSpendableOut(d, 0),
+	}, 1, 5000, false, false)
+	checkStats("spend confirmed", d, stats(d), stats(d, e))
+	harness.chain.utxos.RemoveEntry(wire.OutPoint{Hash: *a.Hash()})
+	harness.chain.utxos.RemoveEntry(wire.OutPoint{Hash: *a.Hash(),
+		Index: 1})
+	_, _, err = harness.txPool.MaybeAcceptTransaction(a, false, false)
+	if err != nil {
+		t.Fatalf("MaybeAcceptTransaction: %v", err)
+	}
+	checkStats("reorg", a, stats(a), stats(a, d, e))
+	checkStats("reorg", d, stats(a, d), stats(d, e))
+	checkStats("reorg", e, stats(a, d, e), stats(e))
+}
+
+// TestChainLimits ensures transactions and packages are rejected when they
+// would exceed the ancestor and descendant limits.
+func TestChainLimits(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	policy := &harness.txPool.cfg.Policy
+	policy.MaxAncestorCount = 3
+	policy.MaxDescendantCount = 3
+
+	// Create a chain where B spends A and C spends B.
+	coinbase := ctx.addCoinbaseTx(1)
+	a := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 2, 1000, false, false)
+	b := ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(a, 0)}, 1,
+		1000, false, false)
+	c := ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(b, 0)}, 1,
+		1000, false, false)
+
+	createTx := func(input *btcutil.Tx, index uint32) *btcutil.Tx {
+		t.Helper()
+		tx, err := harness.CreateSignedTx([]spendableOutput{
+			txOutToSpendableOut(input, index),
+		}, 1, 1000, false)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		return tx
+	}
+	checkRejected := func(desc string, wantErr string,
+		txns ...*btcutil.Tx) {
+
+		t.Helper()
+		var err error
+		if len(txns) == 1 {
+			_, err = harness.txPool.ProcessTransaction(txns[0],
+				false, false, 0)
+		} else {
+			_, err = harness.txPool.ProcessPackage(txns)
+		}
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Fatalf("%s: unexpected error %v, want %q", desc, err,
+				wantErr)
+		}
+		if code, _ := extractRejectCode(err); code != wire.RejectNonstandard {
+			t.Fatalf("%s: unexpected reject code %v", desc, code)
+		}
+	}
+
+	// Spending C would give it too many ancestors, while spending A again
+	// would give A too many descendants.
+	checkRejected("ancestor count", "too many unconfirmed ancestors",
+		createTx(c, 0))
+	d := createTx(a, 1)
+	checkRejected("descendant count", "too many descendants", d)
+
+	// Packages are treated as a single transaction with the ancestors of
+	// all of them, so a package spending A along with its child is
+	// rejected once A may have another descendant.
+	policy.MaxDescendantCount = 4
+	harness.txPool.cfg.Policy.DisableRelayPriority = false
+	freeD, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(a, 1),
+	}, 1, 0, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	checkRejected("package descendant count", "too many descendants",
+		freeD, createTx(freeD, 0))
+
+	// The sizes are limited as well.
+	policy.MaxAncestorSize = int(GetTxVirtualSize(a) +
+		GetTxVirtualSize(d) - 1)
+	checkRejected("ancestor size", "exceeds the ancestor size limit", d)
+	policy.MaxAncestorSize = DefaultMaxAncestorSize
+	policy.MaxDescendantSize = int(harness.txPool.pool[*a.Hash()].
+		descendants.size + GetTxVirtualSize(d) - 1)
+	checkRejected("descendant size", "exceed the descendant size limit", d)
+
+	policy.MaxDescendantSize = DefaultMaxDescendantSize
+	_, err = harness.txPool.ProcessTransaction(d, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+}
+
+// TestLimitPoolSize ensures the transactions with the lowest descendant scores
+// are evicted once the pool exceeds its maximum size, and that the minimum fee
+// rate of the pool is raised accordingly and only decays once a block has been
+// connected.
+func TestLimitPoolSize(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	pool := harness.txPool
+
+	// Add a parent paying a low fee along with a child paying a high fee,
+	// and a transaction paying a medium fee, then limit the pool to them
+	// while allowing for signatures of different sizes.
+	coinbase := ctx.addCoinbaseTx(4)
+	parent := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 1, 100, false, false)
+	child := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0),
+	}, 1, 5000, false, false)
+	medium := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 1),
+	}, 1, 2000, false, false)
+	pool.cfg.Policy.MaxPoolSize = pool.Usage() + 10
+	if feeRate := pool.MinFeeRate(); feeRate != 1000 {
+		t.Fatalf("unexpected minimum fee rate %d, want 1000", feeRate)
+	}
+
+	processTx := func(desc string, index uint32, fee btcutil.Amount,
+		wantErr string) *btcutil.Tx {
+
+		t.Helper()
+		tx, err := harness.CreateSignedTx([]spendableOutput{
+			txOutToSpendableOut(coinbase, index),
+		}, 1, fee, false)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		_, err = pool.ProcessTransaction(tx, false, false, 0)
+		if wantErr == "" && err != nil {
+			t.Fatalf("%s: ProcessTransaction: %v", desc, err)
+		}
+		if wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), wantErr) {
+				t.Fatalf("%s: unexpected error %v, want %q",
+					desc, err, wantErr)
+			}
+			code, _ := extractRejectCode(err)
+			if code != wire.RejectInsufficientFee {
+				t.Fatalf("%s: unexpected reject code %v", desc,
+					code)
+			}
+		}
+		testPoolMembership(ctx, tx, false, wantErr == "")
+		return tx
+	}
+
+	// A transaction paying a lower fee rate than all of the others is
+	// evicted right away, which raises the minimum fee rate above its own.
+	low := processTx("low fee", 2, 500, "evicted since the mempool is full")
+	wantFeeRate := 500*1000/GetTxVirtualSize(low) + 1000
+	if feeRate := pool.MinFeeRate(); feeRate != wantFeeRate {
+		t.Fatalf("unexpected minimum fee rate %d, want %d", feeRate,
+			wantFeeRate)
+	}
+
+	// A transaction paying a high fee rate evicts the one paying a medium
+	// fee rate instead of the parent, whose child pays for it.
+	processTx("high fee", 3, 10000, "")
+	testPoolMembership(ctx, medium, false, false)
+	testPoolMembership(ctx, parent, false, true)
+	testPoolMembership(ctx, child, false, true)
+	wantFeeRate = 2000*1000/GetTxVirtualSize(medium) + 1000
+	if feeRate := pool.MinFeeRate(); feeRate != wantFeeRate {
+		t.Fatalf("unexpected minimum fee rate %d, want %d", feeRate,
+			wantFeeRate)
+	}
+	if pool.Usage() > pool.cfg.Policy.MaxPoolSize {
+		t.Fatalf("pool uses %d bytes, more than the maximum of %d",
+			pool.Usage(), pool.cfg.Policy.MaxPoolSize)
+	}
+
+	// The evicted transaction is no longer accepted since it pays less
+	// than the minimum fee rate of the pool.
+	processTx("evicted", 1, 2000, "under the mempool minimum fee")
+
+	// The minimum fee rate doesn't decay until a block is connected, after
+	// which it halves every half-life while the pool is at least half full,
+	// and eventually drops back to the minimum relay fee.
+	pool.rollingMinFeeUpdated = time.Now().Add(-rollingMinFeeHalfLife)
+	if feeRate := pool.MinFeeRate(); feeRate != wantFeeRate {
+		t.Fatalf("minimum fee rate %d decayed before a block was "+
+			"connected, want %d", feeRate, wantFeeRate)
+	}
+	harness.chain.SetHeight(harness.chain.BestHeight() + 1)
+	pool.MinFeeRate()
+	pool.rollingMinFeeUpdated = time.Now().Add(-rollingMinFeeHalfLife)
+	feeRate := pool.MinFeeRate()
+	if diff := feeRate - wantFeeRate/2; diff < -1 || diff > 1 {
+		t.Fatalf("unexpected decayed minimum fee rate %d, want %d",
+			feeRate, wantFeeRate/2)
+	}
+	pool.rollingMinFeeUpdated = time.Now().Add(-rollingMinFeeHalfLife * 10)
+	if feeRate := pool.MinFeeRate(); feeRate != 1000 {
+		t.Fatalf("unexpected minimum fee rate %d, want 1000", feeRate)
+	}
+}
+
+// TestTrimToSize ensures transactions added back from disconnected blocks don't
+// cause the pool to be trimmed until TrimToSize is called, so a parent paying a
+// low fee is not evicted before its child paying for it is added.
+func TestTrimToSize(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	pool := harness.txPool
+
+	// Fill the pool with two transactions paying a medium fee while
+	// allowing for signatures of different sizes.
+	coinbase := ctx.addCoinbaseTx(3)
+	medium1 := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 1, 2000, false, false)
+	medium2 := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 1),
+	}, 1, 2000, false, false)
+	pool.cfg.Policy.MaxPoolSize = pool.Usage() + 10
+
+	// Add back a parent paying a low fee followed by its child paying a
+	// high fee as if they were in a disconnected block.
+	parent, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 2),
+	}, 1, 100, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	child, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0),
+	}, 1, 5000, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	for _, tx := range []*btcutil.Tx{parent, child} {
+		_, _, err := pool.MaybeAcceptTransaction(tx, false, false)
+		if err != nil {
+			t.Fatalf("MaybeAcceptTransaction: %v", err)
+		}
+		testPoolMembership(ctx, tx, false, true)
+	}
+	if pool.Usage() <= pool.cfg.Policy.MaxPoolSize {
+		t.Fatalf("pool uses %d bytes, not more than the maximum of %d",
+			pool.Usage(), pool.cfg.Policy.MaxPoolSize)
+	}
+
+	// Trimming the pool evicts the transactions paying a medium fee rate
+	// instead of the parent, whose child pays for it.
+	pool.TrimToSize()
+	testPoolMembership(ctx, medium1, false, false)
+	testPoolMembership(ctx, medium2, false, false)
+	testPoolMembership(ctx, parent, false, true)
+	testPoolMembership(ctx, child, false, true)
+	if pool.Usage() > pool.cfg.Policy.MaxPoolSize {
+		t.Fatalf("pool uses %d bytes, more than the maximum of %d",
+			pool.Usage(), pool.cfg.Policy.MaxPoolSize)
+	}
+}
+
 // TestRBF tests the different cases required for a transaction to properly
 // replace its conflicts given that they all signal replacement.
 func TestRBF(t *testing.T) {
@@ -1531,6 +1906,8 @@ func TestRBF(t *testing.T) {
 			name: "exceeds maximum conflicts",
 			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
 				const numDescendants = 100
+				ctx.harness.txPool.cfg.Policy.MaxDescendantCount =
+					numDescendants + 1
 				coinbaseOuts := make(
 					[]spendableOutput, numDescendants,
 				)
@@ -1851,3 +2228,95 @@ func TestRBF(t *testing.T) {
 		}
 	}
 }
+
+// TestTestAccept ensures testing whether a chain of transactions would be
+// accepted reports the outcome for each of them without modifying the pool.
+func TestTestAccept(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	chainedTxns, err := harness.CreateTxChain(outputs[0], 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	conflict, err := harness.CreateSignedTx(outputs[:1], 1, 1000, false)
+	if err != nil {
+		t.Fatalf("unable to create conflicting transaction: %v", err)
+	}
+	feeRate := 1000 * 1000 / GetTxVirtualSize(conflict)
+
+	testAccept := func(desc string, txns []*btcutil.Tx,
+		maxFeeRate btcutil.Amount, wantAccepted ...bool) {
+
+		t.Helper()
+		results := harness.txPool.TestAccept(txns, maxFeeRate)
+		if len(results) != len(txns) {
+			t.Fatalf("%s: got %d results, want %d", desc,
+				len(results), len(txns))
+		}
+		for i, res := range results {
+			if res.Tx != txns[i] {
+				t.Fatalf("%s: result %d is for the wrong "+
+					"transaction", desc, i)
+			}
+			if accepted := res.Err == nil; accepted != wantAccepted[i] {
+				t.Fatalf("%s: transaction %d accepted %v, want "+
+					"%v (err %v)", desc, i, accepted,
+					wantAccepted[i], res.Err)
+			}
+			if res.Err == nil {
+				if res.VSize != GetTxVirtualSize(txns[i]) {
+					t.Fatalf("%s: transaction %d has vsize "+
+						"%d, want %d", desc, i, res.VSize,
+						GetTxVirtualSize(txns[i]))
+				}
+				continue
+			}
+			if _, ok := res.Err.(RuleError); !ok {
+				t.Fatalf("%s: transaction %d has unexpected "+
+					"error type %T", desc, i, res.Err)
+			}
+		}
+	}
+
+	// A chain of transactions is accepted as a whole, but not without
+	// its parents.
+	testAccept("chain", chainedTxns, 0, true, true, true)
+	testAccept("chain without parent", chainedTxns[1:], 0, false, false)
+	testAccept("chain with gap", []*btcutil.Tx{chainedTxns[0],
+		chainedTxns[2]}, 0, true, false)
+
+	// Transactions spending the same outputs as previous ones or that were
+	// already tested are rejected.
+	testAccept("double spend", []*btcutil.Tx{chainedTxns[0], conflict},
+		0, true, false)
+	testAccept("duplicate", []*btcutil.Tx{chainedTxns[0],
+		chainedTxns[0]}, 0, true, false)
+
+	// Transactions paying more than the maximum fee rate are rejected.
+	testAccept("fee rate below maximum", []*btcutil.Tx{conflict},
+		btcutil.Amount(feeRate*2), true)
+	testAccept("fee rate above maximum", []*btcutil.Tx{conflict},
+		btcutil.Amount(feeRate/2), false)
+
+	// Nothing must have been added to the pool.
+	for _, tx := range append(chainedTxns, conflict) {
+		testPoolMembership(tc, tx, false, false)
+	}
+
+	// Transactions in the pool are rejected, but their outputs may be
+	// spent.
+	_, err = harness.txPool.ProcessTransaction(chainedTxns[0], false,
+		false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	testAccept("chain with parent in pool", chainedTxns, 0, false, true,
+		true)
+	testPoolMembership(tc, chainedTxns[1], false, false)
+}
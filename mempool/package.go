@@ -0,0 +1,325 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// MaxPackageCount is the maximum number of transactions a package may consist
+// of.
+const MaxPackageCount = 25
+
+// PackageTxResult describes the outcome of processing a transaction of a
+// package.
+type PackageTxResult struct {
+	// Tx is the transaction of the package.
+	Tx *btcutil.Tx
+
+	// Desc is the descriptor of the transaction in the pool.  It is nil
+	// when the transaction was not accepted.
+	Desc *TxDesc
+
+	// AlreadyInPool indicates the transaction was already in the pool
+	// before the package was processed.
+	AlreadyInPool bool
+
+	// EffectiveFeeRate is the fee rate in satoshi per kB the transaction
+	// was accepted with.  It is the fee rate of the transaction itself when
+	// it was accepted on its own, and that of the transactions listed in
+	// EffectiveIncludes as a whole when it was accepted along with them.
+	EffectiveFeeRate  int64
+	EffectiveIncludes []*btcutil.Tx
+
+	// Err is the reason the transaction was not accepted, or nil when it
+	// was accepted or already in the pool.
+	Err error
+}
+
+// PackageResult describes the outcome of processing a package.
+type PackageResult struct {
+	// TxResults holds the outcome for each transaction of the package in
+	// the order they were passed.
+	TxResults []*PackageTxResult
+
+	// Accepted holds the descriptors of all of the transactions that were
+	// added to the pool in the order they were added.  This includes
+	// orphans that were accepted as a result of the package.
+	Accepted []*TxDesc
+
+	// Replaced holds the transactions that were removed from the pool
+	// since transactions of the package replaced them.
+	Replaced []*btcutil.Tx
+}
+
+// CheckPackage ensures the passed transactions form a package that may be
+// processed by ProcessPackage.  A package consists of at most MaxPackageCount
+// distinct transactions, the last of which is the child, while the others are
+// its parents.  The transactions must be sorted such that every transaction
+// follows the ones it spends and none of them may spend the same output.
+func CheckPackage(txns []*btcutil.Tx) error {
+	if len(txns) == 0 || len(txns) > MaxPackageCount {
+		str := fmt.Sprintf("package must consist of between 1 and %d "+
+			"transactions, has %d", MaxPackageCount, len(txns))
+		return txRuleError(wire.RejectInvalid, str)
+	}
+
+	positions := make(map[chainhash.Hash]int, len(txns))
+	for i, tx := range txns {
+		if _, ok := positions[*tx.Hash()]; ok {
+			str := fmt.Sprintf("package contains transaction %v "+
+				"more than once", tx.Hash())
+			return txRuleError(wire.RejectInvalid, str)
+		}
+		positions[*tx.Hash()] = i
+	}
+
+	spent := make(map[wire.OutPoint]struct{})
+	for i, tx := range txns {
+		for _, txIn := range tx.MsgTx().TxIn {
+			prevOut := txIn.PreviousOutPoint
+			if pos, ok := positions[prevOut.Hash]; ok && pos >= i {
+				str := fmt.Sprintf("package is not sorted: "+
+					"transaction %v spends transaction %v "+
+					"which follows it", tx.Hash(), prevOut.Hash)
+				return txRuleError(wire.RejectInvalid, str)
+			}
+			if _, ok := spent[prevOut]; ok {
+				str := fmt.Sprintf("package spends output %v "+
+					"more than once", prevOut)
+				return txRuleError(wire.RejectInvalid, str)
+			}
+			spent[prevOut] = struct{}{}
+		}
+	}
+
+	// Every transaction but the child must be one of its parents.
+	child := txns[len(txns)-1]
+	parents := make(map[chainhash.Hash]struct{})
+	for _, txIn := range child.MsgTx().TxIn {
+		parents[txIn.PreviousOutPoint.Hash] = struct{}{}
+	}
+	for _, tx := range txns[:len(txns)-1] {
+		if _, ok := parents[*tx.Hash()]; !ok {
+			str := fmt.Sprintf("package transaction %v is not a "+
+				"parent of child %v", tx.Hash(), child.Hash())
+			return txRuleError(wire.RejectInvalid, str)
+		}
+	}
+
+	return nil
+}
+
+// isReconsiderable returns whether the passed error for a transaction that is
+// checked on its own may no longer apply when it is checked as part of a
+// package, which is the case when it does not pay enough fees.
+func isReconsiderable(err error) bool {
+	code, found := extractRejectCode(err)
+	return found && code == wire.RejectInsufficientFee
+}
+
+// acceptPackage is the internal function which implements the public
+// ProcessPackage.  See the comment for ProcessPackage for more details.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) acceptPackage(txns []*btcutil.Tx) (*PackageResult, error) {
+	result := &PackageResult{
+		TxResults: make([]*PackageTxResult, 0, len(txns)),
+	}
+
+	// Attempt to accept each transaction on its own first so transactions
+	// that pay for themselves are judged independently of the others.  The
+	// transactions that don't pay enough fees, along with those spending
+	// them, are evaluated as a package afterwards.
+	var deferred []*PackageTxResult
+	var rejectErr error
+	for _, tx := range txns {
+		res := &PackageTxResult{Tx: tx}
+		result.TxResults = append(result.TxResults, res)
+		if txD, ok := mp.pool[*tx.Hash()]; ok {
+			res.Desc = txD
+			res.AlreadyInPool = true
+			continue
+		}
+		if rejectErr != nil {
+			res.Err = rejectErr
+			continue
+		}
+
+		accept, err := mp.checkMempoolAcceptance(tx, true, false, true,
+			nil)
+		switch {
+		case err != nil && !isReconsiderable(err):
+			res.Err = err
+			rejectErr = err
+			continue
+
+		case err != nil || len(accept.missingParents) > 0:
+			deferred = append(deferred, res)
+			continue
+		}
+
+		feeRate := accept.fee * 1000 / accept.vsize
+		for _, conflict := range accept.conflicts {
+			result.Replaced = append(result.Replaced, conflict)
+		}
+		mp.removeConflicts(accept.conflicts, tx, feeRate)
+		res.Desc = mp.addTransaction(accept.utxoView, tx,
+			accept.bestHeight, accept.fee)
+		res.EffectiveFeeRate = feeRate
+		res.EffectiveIncludes = []*btcutil.Tx{tx}
+		result.Accepted = append(result.Accepted, res.Desc)
+	}
+	if rejectErr == nil && len(deferred) > 0 {
+		rejectErr = mp.acceptDeferred(deferred, result)
+	}
+	for _, res := range deferred {
+		if res.Desc == nil {
+			res.Err = rejectErr
+		}
+	}
+
+	// Evict transactions if the pool is full now, which may include
+	// transactions of the package when they pay less than the others.
+	mp.trimToSize()
+	accepted := result.Accepted[:0]
+	for _, txD := range result.Accepted {
+		if mp.isTransactionInPool(txD.Tx.Hash()) {
+			accepted = append(accepted, txD)
+		}
+	}
+	result.Accepted = accepted
+	for _, res := range result.TxResults {
+		if res.Desc == nil || mp.isTransactionInPool(res.Tx.Hash()) {
+			continue
+		}
+		res.Desc = nil
+		res.Err = poolFullError(res.Tx)
+		if rejectErr == nil {
+			rejectErr = res.Err
+		}
+	}
+
+	// Accept any orphans that are no longer orphans now that transactions
+	// of the package were accepted.
+	var orphans []*TxDesc
+	for _, txD := range result.Accepted {
+		orphans = append(orphans, mp.processOrphans(txD.Tx)...)
+	}
+	result.Accepted = append(result.Accepted, orphans...)
+
+	return result, rejectErr
+}
+
+// acceptDeferred evaluates the passed transactions of a package which could not
+// be accepted on their own as a package and adds them to the pool when they pay
+// the minimum relay fee in total, including when replacing conflicts.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) acceptDeferred(deferred []*PackageTxResult,
+	result *PackageResult) error {
+
+	pkg := &packageContext{
+		txns:      make(map[chainhash.Hash]*btcutil.Tx, len(deferred)),
+		deferFees: true,
+	}
+	var (
+		txns          = make([]*btcutil.Tx, 0, len(deferred))
+		accepts       = make([]*acceptResult, 0, len(deferred))
+		fee, vsize    int64
+		isReplacement bool
+	)
+	for _, res := range deferred {
+		tx := res.Tx
+		accept, err := mp.checkMempoolAcceptance(tx, true, false, true,
+			pkg)
+		if err != nil {
+			return err
+		}
+		if len(accept.missingParents) > 0 {
+			return orphanError(tx, accept.missingParents)
+		}
+
+		pkg.txns[*tx.Hash()] = tx
+		txns = append(txns, tx)
+		accepts = append(accepts, accept)
+		fee += accept.fee
+		vsize += accept.vsize
+		isReplacement = isReplacement || accept.isReplacement
+	}
+
+	child := txns[len(txns)-1]
+	minFee := calcMinRequiredTxRelayFee(vsize, mp.cfg.Policy.MinRelayTxFee)
+	if fee < minFee {
+		str := fmt.Sprintf("package with child %v has %d fees which "+
+			"is under the required amount of %d", child.Hash(), fee,
+			minFee)
+		return txRuleError(wire.RejectInsufficientFee, str)
+	}
+	if err := mp.checkPoolMinFee(txns, fee, vsize); err != nil {
+		return err
+	}
+	if err := mp.checkChainLimits(txns, vsize); err != nil {
+		return err
+	}
+	var conflicts map[chainhash.Hash]*btcutil.Tx
+	if isReplacement {
+		var err error
+		conflicts, err = mp.validateReplacement(txns, fee, vsize)
+		if err != nil {
+			return err
+		}
+	}
+
+	feeRate := fee * 1000 / vsize
+	for _, conflict := range conflicts {
+		result.Replaced = append(result.Replaced, conflict)
+	}
+	mp.removeConflicts(conflicts, child, feeRate)
+	for i, res := range deferred {
+		accept := accepts[i]
+		res.Desc = mp.addTransaction(accept.utxoView, res.Tx,
+			accept.bestHeight, accept.fee)
+		res.EffectiveFeeRate = feeRate
+		res.EffectiveIncludes = txns
+		result.Accepted = append(result.Accepted, res.Desc)
+	}
+
+	return nil
+}
+
+// ProcessPackage is the main workhorse for handling insertion of a package of
+// transactions into the memory pool.  A package consists of a child and its
+// parents as described by CheckPackage.  It allows a child to pay for parents
+// that do not pay enough fees on their own, which is also known as Child Pays
+// For Parent (CPFP).
+//
+// Each transaction that is not in the pool yet is first processed on its own.
+// The transactions that are rejected since they don't pay enough fees, along
+// with the transactions spending them, are then accepted as a whole when they
+// pay the minimum relay fee in total.  Replacing transactions in the pool is
+// judged by the fee and size of these transactions in total as well.
+//
+// An error is returned when the transactions do not form a valid package, or
+// when any of them is rejected.  In the latter case, the transactions that were
+// accepted on their own remain in the pool and the outcome for each of them is
+// returned along with the error.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) ProcessPackage(txns []*btcutil.Tx) (*PackageResult, error) {
+	if err := CheckPackage(txns); err != nil {
+		return nil, err
+	}
+
+	// Protect concurrent access.
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	return mp.acceptPackage(txns)
+}
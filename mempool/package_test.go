@@ -0,0 +1,238 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestCheckPackage ensures packages that are not a child along with its sorted
+// parents are rejected.
+func TestCheckPackage(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	parent, err := harness.CreateSignedTx(outputs, 2, 0, false)
+	if err != nil {
+		t.Fatalf("unable to create parent: %v", err)
+	}
+	child, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0),
+	}, 1, 0, false)
+	if err != nil {
+		t.Fatalf("unable to create child: %v", err)
+	}
+	doubleSpend, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0),
+		txOutToSpendableOut(child, 0),
+	}, 1, 0, false)
+	if err != nil {
+		t.Fatalf("unable to create double spend: %v", err)
+	}
+
+	tooMany := make([]*btcutil.Tx, MaxPackageCount+1)
+	for i := range tooMany {
+		tooMany[i] = parent
+	}
+	tests := []struct {
+		name  string
+		txns  []*btcutil.Tx
+		valid bool
+	}{
+		{name: "single transaction", txns: []*btcutil.Tx{child},
+			valid: true},
+		{name: "parent and child", txns: []*btcutil.Tx{parent, child},
+			valid: true},
+		{name: "empty", txns: nil},
+		{name: "too many transactions", txns: tooMany},
+		{name: "duplicate", txns: []*btcutil.Tx{parent, parent}},
+		{name: "unsorted", txns: []*btcutil.Tx{child, parent}},
+		{name: "not a parent", txns: []*btcutil.Tx{parent, child,
+			doubleSpend}},
+		{name: "double spend", txns: []*btcutil.Tx{child, doubleSpend}},
+	}
+	for _, test := range tests {
+		err := CheckPackage(test.txns)
+		if test.valid && err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if !test.valid {
+			if _, ok := err.(RuleError); !ok {
+				t.Fatalf("%s: unexpected error: %v", test.name,
+					err)
+			}
+		}
+	}
+}
+
+// TestProcessPackage ensures a child can pay for parents that don't pay enough
+// fees on their own, including when they replace transactions in the pool,
+// while parents can't pay for children.
+func TestProcessPackage(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	// Add a transaction to the pool whose outputs don't have any priority
+	// since they are unconfirmed.  Transactions spending them without
+	// paying the minimum relay fee are then rejected once priority is
+	// required.
+	coinbase := tc.addCoinbaseTx(1)
+	grandparent, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 4, 10000, true)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(grandparent, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	harness.txPool.cfg.Policy.DisableRelayPriority = false
+
+	createTx := func(inputs []*btcutil.Tx, fee btcutil.Amount,
+		signalsReplacement bool) *btcutil.Tx {
+
+		t.Helper()
+		var outputs []spendableOutput
+		for _, tx := range inputs {
+			outputs = append(outputs, txOutToSpendableOut(tx, 0))
+		}
+		tx, err := harness.CreateSignedTx(outputs, 1, fee,
+			signalsReplacement)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		return tx
+	}
+	spendGrandparent := func(index uint32, fee btcutil.Amount,
+		signalsReplacement bool) *btcutil.Tx {
+
+		t.Helper()
+		tx, err := harness.CreateSignedTx([]spendableOutput{
+			txOutToSpendableOut(grandparent, index),
+		}, 1, fee, signalsReplacement)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		return tx
+	}
+	processPackage := func(desc string, txns []*btcutil.Tx,
+		wantCode wire.RejectCode, wantAccepted ...bool) *PackageResult {
+
+		t.Helper()
+		result, err := harness.txPool.ProcessPackage(txns)
+		if wantCode == 0 && err != nil {
+			t.Fatalf("%s: ProcessPackage: %v", desc, err)
+		}
+		if wantCode != 0 {
+			code, found := extractRejectCode(err)
+			if !found || code != wantCode {
+				t.Fatalf("%s: ProcessPackage: unexpected error "+
+					"%v, want code %v", desc, err, wantCode)
+			}
+		}
+		for i, res := range result.TxResults {
+			accepted := res.Desc != nil
+			if accepted != wantAccepted[i] || accepted != (res.Err == nil) {
+				t.Fatalf("%s: transaction %d accepted %v, want "+
+					"%v (err %v)", desc, i, accepted,
+					wantAccepted[i], res.Err)
+			}
+			testPoolMembership(tc, res.Tx, false, wantAccepted[i])
+		}
+		return result
+	}
+
+	// A parent without fees can't be accepted on its own, nor along with a
+	// child that doesn't pay enough fees for both of them.
+	parent := spendGrandparent(0, 0, false)
+	_, err = harness.txPool.ProcessTransaction(parent, false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectInsufficientFee {
+		t.Fatalf("ProcessTransaction: unexpected error %v", err)
+	}
+	cheapChild := createTx([]*btcutil.Tx{parent}, 100, false)
+	processPackage("cheap child", []*btcutil.Tx{parent, cheapChild},
+		wire.RejectInsufficientFee, false, false)
+
+	// It is accepted when the child pays enough for both of them.
+	child := createTx([]*btcutil.Tx{parent}, 1000, false)
+	result := processPackage("child pays for parent", []*btcutil.Tx{parent,
+		child}, 0, true, true)
+	wantFeeRate := int64(1000 * 1000 / (GetTxVirtualSize(parent) +
+		GetTxVirtualSize(child)))
+	for i, res := range result.TxResults {
+		if res.EffectiveFeeRate != wantFeeRate ||
+			len(res.EffectiveIncludes) != 2 {
+
+			t.Fatalf("transaction %d has effective fee rate %d "+
+				"including %d transactions, want %d including 2",
+				i, res.EffectiveFeeRate,
+				len(res.EffectiveIncludes), wantFeeRate)
+		}
+	}
+	if len(result.Accepted) != 2 {
+		t.Fatalf("got %d accepted transactions, want 2",
+			len(result.Accepted))
+	}
+
+	// Transactions already in the pool are skipped.
+	result = processPackage("already in pool", []*btcutil.Tx{parent,
+		child}, 0, true, true)
+	if !result.TxResults[0].AlreadyInPool || len(result.Accepted) != 0 {
+		t.Fatalf("unexpected result for package in pool: %+v", result)
+	}
+
+	// A parent paying for itself is accepted on its own, but it can't pay
+	// for a child that doesn't.
+	richParent := spendGrandparent(1, 2000, false)
+	freeChild := createTx([]*btcutil.Tx{richParent}, 0, false)
+	result = processPackage("parent pays for child", []*btcutil.Tx{
+		richParent, freeChild}, wire.RejectInsufficientFee, true, false)
+	if len(result.TxResults[0].EffectiveIncludes) != 1 {
+		t.Fatalf("parent accepted along with %d transactions, want 1",
+			len(result.TxResults[0].EffectiveIncludes))
+	}
+
+	// A replacement parent that doesn't pay more than the transaction it
+	// replaces is accepted when its child pays enough for both of them.
+	replaced := spendGrandparent(2, 1000, true)
+	_, err = harness.txPool.ProcessTransaction(replaced, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	replacement := spendGrandparent(2, 1000, false)
+	_, err = harness.txPool.ProcessTransaction(replacement, false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectInsufficientFee {
+		t.Fatalf("ProcessTransaction: unexpected error %v", err)
+	}
+	replacementChild := createTx([]*btcutil.Tx{replacement}, 2000, false)
+	result = processPackage("package replacement", []*btcutil.Tx{
+		replacement, replacementChild}, 0, true, true)
+	if len(result.Replaced) != 1 || result.Replaced[0] != replaced {
+		t.Fatalf("unexpected replaced transactions %v", result.Replaced)
+	}
+	testPoolMembership(tc, replaced, false, false)
+
+	// Transactions following one that is rejected for reasons other than
+	// fees are rejected as well.
+	invalid := spendGrandparent(3, 0, false)
+	invalid.MsgTx().TxIn[0].SignatureScript = nil
+	invalid = btcutil.NewTx(invalid.MsgTx())
+	invalidChild := createTx([]*btcutil.Tx{invalid}, 5000, false)
+	processPackage("invalid parent", []*btcutil.Tx{invalid, invalidChild},
+		wire.RejectInvalid, false, false)
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// mempoolDumpVersion is the version of the format the transactions in
+	// the pool are persisted with.
+	mempoolDumpVersion = 1
+
+	// DefaultMempoolExpiry is the default maximum amount of time a
+	// persisted transaction may have spent in the pool to still be loaded
+	// again.
+	DefaultMempoolExpiry = time.Hour * 24 * 14
+)
+
+// ErrLoadInterrupted is returned by Load when it is interrupted before all of
+// the transactions have been processed.
+var ErrLoadInterrupted = errors.New("loading the mempool was interrupted")
+
+// -----------------------------------------------------------------------------
+// The transactions in the pool are persisted in the following format:
+//
+//   <version><count>[<tx><entry time><fee delta>,...]
+//
+//   Field        Type         Size
+//   version      uint64       8 bytes
+//   count        uint64       8 bytes
+//   tx           wire.MsgTx   variable (serialized with witness data)
+//   entry time   int64        8 bytes (unix seconds)
+//   fee delta    int64        8 bytes
+//
+// The transactions are written so every transaction is preceded by the
+// transactions in the pool it spends, which allows loading them again in order
+// without involving the orphan pool.
+//
+// The fee delta is the amount the fee of a transaction is modified by for the
+// purposes of mining.  The pool does not support prioritising transactions yet,
+// so it is always written as zero and ignored when loading.  It is part of the
+// format so prioritised transactions can be persisted without a new version.
+// -----------------------------------------------------------------------------
+
+// LoadStats describes the outcome of loading persisted transactions into the
+// pool.
+type LoadStats struct {
+	// Accepted is the number of transactions that were added to the pool.
+	Accepted int
+
+	// Failed is the number of transactions that were rejected, typically
+	// because they conflict with the current main chain or violate the
+	// current policy.
+	Failed int
+
+	// Expired is the number of transactions that were skipped since they
+	// had been in the pool for longer than the expiry.
+	Expired int
+
+	// AlreadyKnown is the number of transactions that were skipped since
+	// they were already in the pool.
+	AlreadyKnown int
+}
+
+// dumpOrder returns the transactions in the pool ordered by the time they were
+// added such that every transaction is preceded by the transactions in the
+// pool it spends.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) dumpOrder() []*TxDesc {
+	descs := make([]*TxDesc, 0, len(mp.pool))
+	for _, desc := range mp.pool {
+		descs = append(descs, desc)
+	}
+	sort.Slice(descs, func(i, j int) bool {
+		if !descs[i].Added.Equal(descs[j].Added) {
+			return descs[i].Added.Before(descs[j].Added)
+		}
+		return bytes.Compare(descs[i].Tx.Hash()[:],
+			descs[j].Tx.Hash()[:]) < 0
+	})
+
+	ordered := make([]*TxDesc, 0, len(descs))
+	visited := make(map[chainhash.Hash]struct{}, len(descs))
+	var visit func(desc *TxDesc)
+	visit = func(desc *TxDesc) {
+		if _, ok := visited[*desc.Tx.Hash()]; ok {
+			return
+		}
+		visited[*desc.Tx.Hash()] = struct{}{}
+		for _, txIn := range desc.Tx.MsgTx().TxIn {
+			parent, ok := mp.pool[txIn.PreviousOutPoint.Hash]
+			if ok {
+				visit(parent)
+			}
+		}
+		ordered = append(ordered, desc)
+	}
+	for _, desc := range descs {
+		visit(desc)
+	}
+
+	return ordered
+}
+
+// Dump writes all of the transactions in the pool along with the time they
+// were added to the passed writer so they can be loaded again with Load, for
+// example after a restart.  It returns the number of transactions written.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Dump(w io.Writer) (int, error) {
+	mp.mtx.RLock()
+	descs := mp.dumpOrder()
+	mp.mtx.RUnlock()
+
+	err := binary.Write(w, binary.LittleEndian, uint64(mempoolDumpVersion))
+	if err != nil {
+		return 0, err
+	}
+	err = binary.Write(w, binary.LittleEndian, uint64(len(descs)))
+	if err != nil {
+		return 0, err
+	}
+	for _, desc := range descs {
+		if err := desc.Tx.MsgTx().Serialize(w); err != nil {
+			return 0, err
+		}
+		err := binary.Write(w, binary.LittleEndian, desc.Added.Unix())
+		if err != nil {
+			return 0, err
+		}
+		err = binary.Write(w, binary.LittleEndian, int64(0))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(descs), nil
+}
+
+// Load reads transactions written by Dump from the passed reader and processes
+// them as if they were just received while retaining the time they were
+// originally added to the pool.  Transactions that have been in the pool for
+// longer than the passed expiry are skipped, and those that are no longer valid
+// are dropped.
+//
+// ErrLoadInterrupted is returned along with the statistics up to that point
+// when the passed channel is closed before all transactions are processed.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Load(r io.Reader, expiry time.Duration,
+	interrupt <-chan struct{}) (*LoadStats, error) {
+
+	var version, count uint64
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, err
+	}
+	if version != mempoolDumpVersion {
+		return nil, fmt.Errorf("unsupported mempool dump version %d",
+			version)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, err
+	}
+
+	var stats LoadStats
+	now := time.Now()
+	for i := uint64(0); i < count; i++ {
+		select {
+		case <-interrupt:
+			return &stats, ErrLoadInterrupted
+		default:
+		}
+
+		var msgTx wire.MsgTx
+		if err := msgTx.Deserialize(r); err != nil {
+			return &stats, err
+		}
+		var addedUnix, feeDelta int64
+		err := binary.Read(r, binary.LittleEndian, &addedUnix)
+		if err != nil {
+			return &stats, err
+		}
+		err = binary.Read(r, binary.LittleEndian, &feeDelta)
+		if err != nil {
+			return &stats, err
+		}
+
+		tx := btcutil.NewTx(&msgTx)
+		added := time.Unix(addedUnix, 0)
+		if now.Sub(added) > expiry {
+			stats.Expired++
+			continue
+		}
+		if mp.HaveTransaction(tx.Hash()) {
+			stats.AlreadyKnown++
+			continue
+		}
+
+		_, err = mp.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			log.Debugf("Dropping persisted transaction %v: %v",
+				tx.Hash(), err)
+			stats.Failed++
+			continue
+		}
+		stats.Accepted++
+
+		// Restore the time the transaction was originally added.
+		mp.mtx.Lock()
+		if desc, ok := mp.pool[*tx.Hash()]; ok {
+			desc.Added = added
+			mp.evictionQueue.update(desc)
+		}
+		mp.mtx.Unlock()
+	}
+
+	return &stats, nil
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// TestDumpLoad ensures the transactions dumped from a pool are loaded into
+// another one in dependency order along with the time they were added, while
+// expired and no longer valid transactions are dropped.
+func TestDumpLoad(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	// Add a chain of transactions to the pool where the last one was added
+	// before the others so it is dumped first unless the dependencies are
+	// taken into account.  It has also been in the pool for too long.
+	chainedTxns, err := harness.CreateTxChain(spendableOuts[0], 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	now := time.Now()
+	addedTimes := []time.Time{
+		now.Add(-2 * time.Hour),
+		now.Add(-time.Hour),
+		now.Add(-DefaultMempoolExpiry - time.Hour),
+	}
+	for i, tx := range chainedTxns {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: %v", err)
+		}
+		harness.txPool.pool[*tx.Hash()].Added = addedTimes[i]
+	}
+
+	var buf bytes.Buffer
+	n, err := harness.txPool.Dump(&buf)
+	if err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	if n != len(chainedTxns) {
+		t.Fatalf("Dump: wrote %d transactions, want %d", n,
+			len(chainedTxns))
+	}
+	dump := buf.Bytes()
+
+	load := func(desc string, pool *TxPool, want LoadStats) {
+		t.Helper()
+		stats, err := pool.Load(bytes.NewReader(dump),
+			DefaultMempoolExpiry, nil)
+		if err != nil {
+			t.Fatalf("%s: Load: %v", desc, err)
+		}
+		if *stats != want {
+			t.Fatalf("%s: unexpected load stats: got %+v, want %+v",
+				desc, *stats, want)
+		}
+	}
+
+	// Loading the transactions into the same pool must skip them.
+	load("same pool", harness.txPool, LoadStats{Expired: 1,
+		AlreadyKnown: 2})
+
+	// Loading them into an empty pool must add the ones that did not
+	// expire along with the time they were added.
+	harness.txPool = New(&harness.txPool.cfg)
+	load("empty pool", harness.txPool, LoadStats{Accepted: 2, Expired: 1})
+	for i, tx := range chainedTxns[:2] {
+		testPoolMembership(tc, tx, false, true)
+		added := harness.txPool.pool[*tx.Hash()].Added
+		if added.Unix() != addedTimes[i].Unix() {
+			t.Fatalf("transaction %d added at %v, want %v", i, added,
+				addedTimes[i])
+		}
+	}
+	testPoolMembership(tc, chainedTxns[2], false, false)
+
+	// Loading them into a pool with a conflicting transaction must drop
+	// them.
+	harness.txPool = New(&harness.txPool.cfg)
+	conflict, err := harness.CreateSignedTx(spendableOuts[:1], 1, 1000,
+		false)
+	if err != nil {
+		t.Fatalf("unable to create conflicting transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(conflict, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+	load("conflicting pool", harness.txPool, LoadStats{Failed: 2,
+		Expired: 1})
+
+	// Unknown versions and truncated dumps must be rejected.
+	badVersion := append([]byte{0xff}, dump[1:]...)
+	_, err = harness.txPool.Load(bytes.NewReader(badVersion),
+		DefaultMempoolExpiry, nil)
+	if err == nil {
+		t.Fatal("Load: no error for unknown version")
+	}
+	_, err = New(&harness.txPool.cfg).Load(bytes.NewReader(dump[:len(dump)-1]),
+		DefaultMempoolExpiry, nil)
+	if err == nil {
+		t.Fatal("Load: no error for truncated dump")
+	}
+
+	// Loading must stop when interrupted.
+	interrupt := make(chan struct{})
+	close(interrupt)
+	_, err = New(&harness.txPool.cfg).Load(bytes.NewReader(dump),
+		DefaultMempoolExpiry, interrupt)
+	if err != ErrLoadInterrupted {
+		t.Fatalf("Load: unexpected error for interrupt: %v", err)
+	}
+}
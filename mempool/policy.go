@@ -49,6 +49,29 @@ const (
 	// for larger transactions.  This value is in Satoshi/1000 bytes.
 	DefaultMinRelayTxFee = btcutil.Amount(1000)
 
+	// DefaultMaxAncestorCount is the default maximum number of transactions
+	// a transaction may form along with its unconfirmed ancestors in the
+	// mempool.
+	DefaultMaxAncestorCount = 25
+
+	// DefaultMaxAncestorSize is the default maximum virtual size in bytes a
+	// transaction may have along with its unconfirmed ancestors in the
+	// mempool.
+	DefaultMaxAncestorSize = 101000
+
+	// DefaultMaxDescendantCount is the default maximum number of
+	// transactions a transaction in the mempool may form along with its
+	// descendants.
+	DefaultMaxDescendantCount = 25
+
+	// DefaultMaxDescendantSize is the default maximum virtual size in bytes
+	// a transaction in the mempool may have along with its descendants.
+	DefaultMaxDescendantSize = 101000
+
+	// DefaultMaxPoolSize is the default maximum estimated amount of memory
+	// in bytes the transactions in the mempool may use.
+	DefaultMaxPoolSize = 300 * 1000 * 1000
+
 	// maxStandardMultiSigKeys is the maximum number of public keys allowed
 	// in a multi-signature transaction output script for it to be
 	// considered standard.
@@ -5,7 +5,6 @@
 package netsync
 
 import (
-	"container/list"
 	"math/rand"
 	"net"
 	"sync"
@@ -24,10 +23,41 @@ import (
 
 const (
 	// minInFlightBlocks is the minimum number of blocks that should be
-	// in the request queue for headers-first mode before requesting
-	// more.
+	// in the request queue for blocks below a loaded utxo snapshot before
+	// requesting more.
 	minInFlightBlocks = 10
 
+	// blockDownloadWindow is the maximum number of blocks after the point
+	// where the best header chain forks from the main chain that are
+	// requested in headers-first mode.  Blocks are downloaded from several
+	// peers at once, so this limits how far ahead of the slowest peer the
+	// download can get.
+	blockDownloadWindow = 1024
+
+	// maxBlocksInFlightPerPeer is the maximum number of blocks that are
+	// requested from a single peer at a time in headers-first mode.
+	maxBlocksInFlightPerPeer = 16
+
+	// blockStallTimeout is the time after which the peer that was asked
+	// for the block following the current best block is disconnected for
+	// stalling the download when the download window can't move forward
+	// without it.
+	blockStallTimeout = 5 * time.Second
+
+	// blockDownloadTimeout is the time after which a block requested in
+	// headers-first mode is requested from another peer.
+	blockDownloadTimeout = time.Minute
+
+	// downloadSampleInterval is the interval at which the blocks requested
+	// in headers-first mode are checked for peers that are too slow to
+	// deliver them.
+	downloadSampleInterval = 2 * time.Second
+
+	// snapshotBlocksWindow is the number of blocks after the most recent
+	// block validated in the background below a loaded utxo snapshot that
+	// are considered for download at a time.
+	snapshotBlocksWindow = 128
+
 	// maxRejectedTxns is the maximum number of rejected transactions
 	// hashes to store in memory.
 	maxRejectedTxns = 1000
@@ -138,20 +168,28 @@ type pauseMsg struct {
 	unpause <-chan struct{}
 }
 
-// headerNode is used as a node in a list of headers that are linked together
-// between checkpoints.
-type headerNode struct {
-	height int32
-	hash   *chainhash.Hash
+// inFlightBlock describes a block that was requested from a peer in
+// headers-first mode.
+type inFlightBlock struct {
+	peer      *peerpkg.Peer
+	height    int32
+	fastAdd   bool
+	requested time.Time
 }
 
 // peerSyncState stores additional information that the SyncManager tracks
 // about a peer.
+//
+// The expired blocks are blocks requested from the peer in headers-first mode
+// that it either reported as not found or didn't deliver in time.  They are
+// not requested from the peer again, and a copy that still arrives after the
+// block was received from another peer is ignored.
 type peerSyncState struct {
 	syncCandidate   bool
 	requestQueue    []*wire.InvVect
 	requestedTxns   map[chainhash.Hash]struct{}
 	requestedBlocks map[chainhash.Hash]struct{}
+	expiredBlocks   map[chainhash.Hash]struct{}
 }
 
 // limitAdd is a helper function for maps that require a maximum limit by
@@ -200,56 +238,20 @@ type SyncManager struct {
 
 	// The following fields are used for headers-first mode.
 	headersFirstMode bool
-	headerList       *list.List
-	startHeader      *list.Element
-	nextCheckpoint   *chaincfg.Checkpoint
+	headersSynced    bool
+	windowExhausted  bool
+	inFlightBlocks   map[chainhash.Hash]*inFlightBlock
 
 	// An optional fee estimator.
 	feeEstimator *mempool.FeeEstimator
 }
 
 // resetHeaderState sets the headers-first mode state to values appropriate for
-// syncing from a new peer.
-func (sm *SyncManager) resetHeaderState(newestHash *chainhash.Hash, newestHeight int32) {
+// syncing from a new peer.  Blocks that are in flight from other peers are
+// still expected.
+func (sm *SyncManager) resetHeaderState() {
 	sm.headersFirstMode = false
-	sm.headerList.Init()
-	sm.startHeader = nil
-
-	// When there is a next checkpoint, add an entry for the latest known
-	// block into the header pool.  This allows the next downloaded header
-	// to prove it links to the chain properly.
-	if sm.nextCheckpoint != nil {
-		node := headerNode{height: newestHeight, hash: newestHash}
-		sm.headerList.PushBack(&node)
-	}
-}
-
-// findNextHeaderCheckpoint returns the next checkpoint after the passed height.
-// It returns nil when there is not one either because the height is already
-// later than the final checkpoint or some other reason such as disabled
-// checkpoints.
-func (sm *SyncManager) findNextHeaderCheckpoint(height int32) *chaincfg.Checkpoint {
-	checkpoints := sm.chain.Checkpoints()
-	if len(checkpoints) == 0 {
-		return nil
-	}
-
-	// There is no next checkpoint if the height is already after the final
-	// checkpoint.
-	finalCheckpoint := &checkpoints[len(checkpoints)-1]
-	if height >= finalCheckpoint.Height {
-		return nil
-	}
-
-	// Find the next checkpoint.
-	nextCheckpoint := finalCheckpoint
-	for i := len(checkpoints) - 2; i >= 0; i-- {
-		if height >= checkpoints[i].Height {
-			break
-		}
-		nextCheckpoint = &checkpoints[i]
-	}
-	return nextCheckpoint
+	sm.headersSynced = false
 }
 
 // startSync will choose the best peer among the available candidate peers to
@@ -327,8 +329,12 @@ func (sm *SyncManager) startSync() {
 	if bestPeer != nil {
 		// Clear the requestedBlocks if the sync peer changes, otherwise
 		// we may ignore blocks we need that the last sync peer failed
-		// to send.
+		// to send.  The blocks that are in flight from other peers in
+		// headers-first mode are still expected though.
 		sm.requestedBlocks = make(map[chainhash.Hash]struct{})
+		for hash := range sm.inFlightBlocks {
+			sm.requestedBlocks[hash] = struct{}{}
+		}
 
 		locator, err := sm.chain.LatestBlockLocator()
 		if err != nil {
@@ -340,36 +346,43 @@ func (sm *SyncManager) startSync() {
 		log.Infof("Syncing to block height %d from peer %v",
 			bestPeer.LastBlock(), bestPeer.Addr())
 
-		// When the current height is less than a known checkpoint we
-		// can use block headers to learn about which blocks comprise
-		// the chain up to the checkpoint and perform less validation
-		// for them.  This is possible since each header contains the
-		// hash of the previous header and a merkle root.  Therefore if
-		// we validate all of the received headers link together
-		// properly and the checkpoint hashes match, we can be sure the
-		// hashes for the blocks in between are accurate.  Further, once
-		// the full blocks are downloaded, the merkle root is computed
-		// and compared against the value in the header which proves the
-		// full block hasn't been tampered with.
+		// Use block headers to learn about which blocks comprise the
+		// chain with the most work before downloading them.  This is
+		// possible since each header contains the hash of the previous
+		// header and a merkle root.  Therefore if we validate all of
+		// the received headers link together properly and follow the
+		// proof of work rules, we can be sure the hashes for the
+		// blocks are accurate and download them from all of the peers
+		// at once.  Further, once the full blocks are downloaded, the
+		// merkle root is computed and compared against the value in
+		// the header which proves the full block hasn't been tampered
+		// with.  The blocks before the latest checkpoint are eligible
+		// for less validation since the headers are also verified to
+		// match the checkpoints.
 		//
-		// Once we have passed the final checkpoint, or checkpoints are
-		// disabled, use standard inv messages learn about the blocks
-		// and fully validate them.  Finally, regression test mode does
-		// not support the headers-first approach so do normal block
-		// downloads when in regression test mode.
-		if sm.nextCheckpoint != nil &&
-			best.Height < sm.nextCheckpoint.Height &&
-			sm.chainParams != &chaincfg.RegressionNetParams {
-
-			bestPeer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
+		// Once all of the blocks are downloaded, use standard inv
+		// messages to learn about the blocks and fully validate them.
+		// Finally, regression test mode does not support the
+		// headers-first approach so do normal block downloads when in
+		// regression test mode.
+		if sm.chainParams != &chaincfg.RegressionNetParams {
+			err := bestPeer.PushGetHeadersMsg(
+				sm.chain.BestHeaderLocator(), &zeroHash)
+			if err != nil {
+				log.Errorf("Failed to send getheaders message "+
+					"to peer %s: %v", bestPeer.Addr(), err)
+				return
+			}
 			sm.headersFirstMode = true
-			log.Infof("Downloading headers for blocks %d to "+
-				"%d from peer %s", best.Height+1,
-				sm.nextCheckpoint.Height, bestPeer.Addr())
+			sm.headersSynced = false
+			log.Infof("Downloading headers from peer %s",
+				bestPeer.Addr())
 		} else {
 			bestPeer.PushGetBlocksMsg(locator, &zeroHash)
 		}
 		sm.syncPeer = bestPeer
+		sm.fetchSnapshotBlocks()
+		sm.fetchBlocks()
 
 		// Reset the last progress time now that we have a non-nil
 		// syncPeer to avoid instantly detecting it as stalled in the
@@ -434,11 +447,15 @@ func (sm *SyncManager) handleNewPeerMsg(peer *peerpkg.Peer) {
 		syncCandidate:   isSyncCandidate,
 		requestedTxns:   make(map[chainhash.Hash]struct{}),
 		requestedBlocks: make(map[chainhash.Hash]struct{}),
+		expiredBlocks:   make(map[chainhash.Hash]struct{}),
 	}
 
-	// Start syncing by choosing the best candidate if needed.
+	// Start syncing by choosing the best candidate if needed.  Otherwise,
+	// the peer can help download the blocks in headers-first mode.
 	if isSyncCandidate && sm.syncPeer == nil {
 		sm.startSync()
+	} else if isSyncCandidate {
+		sm.fetchBlocks()
 	}
 }
 
@@ -517,6 +534,10 @@ func (sm *SyncManager) handleDonePeerMsg(peer *peerpkg.Peer) {
 		// Update the sync peer. The server has already disconnected the
 		// peer before signaling to the sync manager.
 		sm.updateSyncPeer(false)
+	} else {
+		// Request the blocks that were in flight from the peer in
+		// headers-first mode from the remaining peers.
+		sm.fetchBlocks()
 	}
 }
 
@@ -536,6 +557,7 @@ func (sm *SyncManager) clearRequestedState(state *peerSyncState) {
 	// and request them now to speed things up a little.
 	for blockHash := range state.requestedBlocks {
 		delete(sm.requestedBlocks, blockHash)
+		delete(sm.inFlightBlocks, blockHash)
 	}
 }
 
@@ -554,8 +576,7 @@ func (sm *SyncManager) updateSyncPeer(dcSyncPeer bool) {
 
 	// Reset any header state before we choose our next active sync peer.
 	if sm.headersFirstMode {
-		best := sm.chain.BestSnapshot()
-		sm.resetHeaderState(&best.Hash, best.Height)
+		sm.resetHeaderState()
 	}
 
 	sm.syncPeer = nil
@@ -661,7 +682,20 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 
 	// If we didn't ask for this block then the peer is misbehaving.
 	blockHash := bmsg.block.Hash()
-	if _, exists = state.requestedBlocks[*blockHash]; !exists {
+	_, requested := state.requestedBlocks[*blockHash]
+	if _, expired := state.expiredBlocks[*blockHash]; expired && !requested {
+		// The block was requested from the peer, but it took too long
+		// to deliver it.  Ignore it when it was already received from
+		// another peer in the meantime.
+		delete(state.expiredBlocks, *blockHash)
+		if have, _ := sm.chain.HaveBlock(blockHash); have {
+			log.Debugf("Ignoring late block %v from %s", blockHash,
+				peer)
+			return
+		}
+		requested = true
+	}
+	if !requested {
 		// The regression test intentionally sends some blocks twice
 		// to test duplicate block insertion fails.  Don't disconnect
 		// the peer or ignore the block when we're in regression test
@@ -675,27 +709,21 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		}
 	}
 
-	// When in headers-first mode, if the block matches the hash of the
-	// first header in the list of headers that are being fetched, it's
-	// eligible for less validation since the headers have already been
-	// verified to link together and are valid up to the next checkpoint.
-	// Also, remove the list entry for all blocks except the checkpoint
-	// since it is needed to verify the next round of headers links
-	// properly.
-	isCheckpointBlock := false
+	// When the block was requested in headers-first mode as an ancestor of
+	// the latest checkpoint, it's eligible for less validation since the
+	// headers have already been verified to link together and match the
+	// checkpoint.
 	behaviorFlags := blockchain.BFNone
-	if sm.headersFirstMode {
-		firstNodeEl := sm.headerList.Front()
-		if firstNodeEl != nil {
-			firstNode := firstNodeEl.Value.(*headerNode)
-			if blockHash.IsEqual(firstNode.hash) {
-				behaviorFlags |= blockchain.BFFastAdd
-				if firstNode.hash.IsEqual(sm.nextCheckpoint.Hash) {
-					isCheckpointBlock = true
-				} else {
-					sm.headerList.Remove(firstNodeEl)
-				}
-			}
+	if inFlight, exists := sm.inFlightBlocks[*blockHash]; exists {
+		if inFlight.fastAdd {
+			behaviorFlags |= blockchain.BFFastAdd
+		}
+		delete(sm.inFlightBlocks, *blockHash)
+
+		// Don't expect the block from the peer it was requested from
+		// again when a late copy from another peer arrives first.
+		if inFlight.peer != peer {
+			sm.expireBlock(inFlight.peer, *blockHash)
 		}
 	}
 
@@ -775,7 +803,9 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 			peer.PushGetBlocksMsg(locator, orphanRoot)
 		}
 	} else {
-		if peer == sm.syncPeer {
+		// Blocks are downloaded from all peers in headers-first mode,
+		// so any of them count as progress.
+		if peer == sm.syncPeer || sm.headersFirstMode {
 			sm.lastProgressTime = time.Now()
 		}
 
@@ -805,106 +835,220 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		}
 	}
 
-	// Nothing more to do if we aren't in headers-first mode.
+	// Keep downloading the blocks below a loaded utxo snapshot that are
+	// validated in the background.
+	if !isOrphan && peer == sm.syncPeer {
+		sm.fetchSnapshotBlocks()
+	}
+
+	// This is headers-first mode, so request more blocks now that the
+	// download window may have moved forward and switch to normal mode
+	// once all of them are downloaded.
+	sm.fetchBlocks()
+	sm.maybeFinishHeadersFirst()
+}
+
+// fetchBlocks requests the blocks on the best header chain that are within the
+// download window from the peers in headers-first mode.  Each peer only has a
+// limited number of blocks in flight at a time, so the download is spread
+// across all of the peers that have the blocks instead of being limited by the
+// speed of a single one.
+func (sm *SyncManager) fetchBlocks() {
 	if !sm.headersFirstMode {
 		return
 	}
 
-	// This is headers-first mode, so if the block is not a checkpoint
-	// request more blocks using the header list when the request queue is
-	// getting short.
-	if !isCheckpointBlock {
-		if sm.startHeader != nil &&
-			len(state.requestedBlocks) < minInFlightBlocks {
-			sm.fetchHeaderBlocks()
+	// Determine the peers that can download more blocks.  Only witness
+	// enabled peers are used to ensure the blocks include all of the
+	// witness data.
+	type downloadPeer struct {
+		peer  *peerpkg.Peer
+		state *peerSyncState
+		gdmsg *wire.MsgGetData
+	}
+	var peers []*downloadPeer
+	for peer, state := range sm.peerStates {
+		if !state.syncCandidate || !peer.IsWitnessEnabled() ||
+			len(state.requestedBlocks) >= maxBlocksInFlightPerPeer {
+
+			continue
 		}
-		return
+		peers = append(peers, &downloadPeer{
+			peer:  peer,
+			state: state,
+			gdmsg: wire.NewMsgGetData(),
+		})
+	}
+
+	// Request each block that isn't already in flight from the least busy
+	// peer that has it.  The blocks before the latest checkpoint can be
+	// added with less validation once the best header chain is known to
+	// include the checkpoint.
+	checkpoint := sm.chain.LatestCheckpoint()
+	_, bestHeaderHeight := sm.chain.BestHeader()
+	unassigned := false
+	now := time.Now()
+	for _, block := range sm.chain.NeededBlocks(blockDownloadWindow) {
+		if _, exists := sm.requestedBlocks[block.Hash]; exists {
+			continue
+		}
+
+		var best *downloadPeer
+		for _, p := range peers {
+			numInFlight := len(p.state.requestedBlocks)
+			if numInFlight >= maxBlocksInFlightPerPeer ||
+				p.peer.LastBlock() < block.Height {
+
+				continue
+			}
+			if _, exists := p.state.requestedBlocks[block.Hash]; exists {
+				continue
+			}
+			if _, exists := p.state.expiredBlocks[block.Hash]; exists {
+				continue
+			}
+			if best == nil ||
+				numInFlight < len(best.state.requestedBlocks) {
+
+				best = p
+			}
+		}
+		if best == nil {
+			unassigned = true
+			continue
+		}
+
+		hash := block.Hash
+		sm.requestedBlocks[hash] = struct{}{}
+		best.state.requestedBlocks[hash] = struct{}{}
+		sm.inFlightBlocks[hash] = &inFlightBlock{
+			peer:   best.peer,
+			height: block.Height,
+			fastAdd: checkpoint != nil &&
+				block.Height <= checkpoint.Height &&
+				bestHeaderHeight >= checkpoint.Height,
+			requested: now,
+		}
+		best.gdmsg.AddInvVect(wire.NewInvVect(wire.InvTypeWitnessBlock,
+			&hash))
 	}
 
-	// This is headers-first mode and the block is a checkpoint.  When
-	// there is a next checkpoint, get the next round of headers by asking
-	// for headers starting from the block after this one up to the next
-	// checkpoint.
-	prevHeight := sm.nextCheckpoint.Height
-	prevHash := sm.nextCheckpoint.Hash
-	sm.nextCheckpoint = sm.findNextHeaderCheckpoint(prevHeight)
-	if sm.nextCheckpoint != nil {
-		locator := blockchain.BlockLocator([]*chainhash.Hash{prevHash})
-		err := peer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
-		if err != nil {
-			log.Warnf("Failed to send getheaders message to "+
-				"peer %s: %v", peer.Addr(), err)
-			return
+	// The download window can't move forward when there are peers that
+	// can download more blocks, but all of the blocks in it are in flight.
+	sm.windowExhausted = false
+	for _, p := range peers {
+		if len(p.gdmsg.InvList) > 0 {
+			p.peer.QueueMessage(p.gdmsg, nil)
+		}
+		if !unassigned &&
+			len(p.state.requestedBlocks) < maxBlocksInFlightPerPeer {
+
+			sm.windowExhausted = true
 		}
-		log.Infof("Downloading headers for blocks %d to %d from "+
-			"peer %s", prevHeight+1, sm.nextCheckpoint.Height,
-			sm.syncPeer.Addr())
+	}
+}
+
+// maybeFinishHeadersFirst switches from headers-first mode to normal mode once
+// all of the headers known to the sync peer were downloaded and all of the
+// blocks on the best header chain are connected.  New blocks are then
+// requested as they are announced.
+func (sm *SyncManager) maybeFinishHeadersFirst() {
+	if !sm.headersFirstMode || !sm.headersSynced || sm.syncPeer == nil {
+		return
+	}
+	best := sm.chain.BestSnapshot()
+	if bestHeader, _ := sm.chain.BestHeader(); best.Hash != bestHeader {
 		return
 	}
 
-	// This is headers-first mode, the block is a checkpoint, and there are
-	// no more checkpoints, so switch to normal mode by requesting blocks
-	// from the block after this one up to the end of the chain (zero hash).
+	// Switch to normal mode by requesting blocks from the best block up
+	// to the end of the chain (zero hash) in case any were announced
+	// while switching.
 	sm.headersFirstMode = false
-	sm.headerList.Init()
-	log.Infof("Reached the final checkpoint -- switching to normal mode")
-	locator := blockchain.BlockLocator([]*chainhash.Hash{blockHash})
-	err = peer.PushGetBlocksMsg(locator, &zeroHash)
+	log.Infof("Downloaded all blocks of the best header chain -- " +
+		"switching to normal mode")
+	locator := blockchain.BlockLocator([]*chainhash.Hash{&best.Hash})
+	err := sm.syncPeer.PushGetBlocksMsg(locator, &zeroHash)
 	if err != nil {
 		log.Warnf("Failed to send getblocks message to peer %s: %v",
-			peer.Addr(), err)
-		return
+			sm.syncPeer.Addr(), err)
 	}
 }
 
-// fetchHeaderBlocks creates and sends a request to the syncPeer for the next
-// list of blocks to be downloaded based on the current list of headers.
-func (sm *SyncManager) fetchHeaderBlocks() {
-	// Nothing to do if there is no start header.
-	if sm.startHeader == nil {
-		log.Warnf("fetchHeaderBlocks called with no start header")
+// handleDownloadSample checks the blocks that are in flight in headers-first
+// mode for peers that are too slow to deliver them.  The peer that was asked
+// for the block following the current best block is disconnected when it
+// stalls the download window, which makes its blocks available to the other
+// peers, while blocks that take too long to arrive are requested from another
+// peer.
+func (sm *SyncManager) handleDownloadSample() {
+	if atomic.LoadInt32(&sm.shutdown) != 0 || !sm.headersFirstMode {
 		return
 	}
 
-	// Build up a getdata request for the list of blocks the headers
-	// describe.  The size hint will be limited to wire.MaxInvPerMsg by
-	// the function, so no need to double check it here.
-	gdmsg := wire.NewMsgGetDataSizeHint(uint(sm.headerList.Len()))
-	numRequested := 0
-	for e := sm.startHeader; e != nil; e = e.Next() {
-		node, ok := e.Value.(*headerNode)
-		if !ok {
-			log.Warn("Header list node type is not a headerNode")
-			continue
-		}
-
-		iv := wire.NewInvVect(wire.InvTypeBlock, node.hash)
-		haveInv, err := sm.haveInventory(iv)
-		if err != nil {
-			log.Warnf("Unexpected failure when checking for "+
-				"existing inventory during header block "+
-				"fetch: %v", err)
+	best := sm.chain.BestSnapshot()
+	now := time.Now()
+	retry := false
+	for hash, inFlight := range sm.inFlightBlocks {
+		elapsed := now.Sub(inFlight.requested)
+		switch {
+		case sm.windowExhausted && inFlight.height == best.Height+1 &&
+			elapsed > blockStallTimeout:
+
+			log.Infof("Peer %s is stalling the block download -- "+
+				"disconnecting", inFlight.peer)
+			inFlight.peer.Disconnect()
+
+		case elapsed > blockDownloadTimeout:
+			// Request the block from another peer instead and
+			// free up the slot of the slow peer.
+			log.Debugf("Timed out waiting for block %v from peer "+
+				"%s", hash, inFlight.peer)
+			sm.expireBlock(inFlight.peer, hash)
+			retry = true
 		}
-		if !haveInv {
-			syncPeerState := sm.peerStates[sm.syncPeer]
+	}
+	if retry {
+		sm.fetchBlocks()
+	}
+}
 
-			sm.requestedBlocks[*node.hash] = struct{}{}
-			syncPeerState.requestedBlocks[*node.hash] = struct{}{}
+// expireBlock stops expecting the passed block requested in headers-first mode
+// from the passed peer so that it is requested from another peer instead.
+func (sm *SyncManager) expireBlock(peer *peerpkg.Peer, hash chainhash.Hash) {
+	delete(sm.inFlightBlocks, hash)
+	delete(sm.requestedBlocks, hash)
+	if state, exists := sm.peerStates[peer]; exists {
+		delete(state.requestedBlocks, hash)
+		limitAdd(state.expiredBlocks, hash, maxRequestedBlocks)
+	}
+}
 
-			// If we're fetching from a witness enabled peer
-			// post-fork, then ensure that we receive all the
-			// witness data in the blocks.
-			if sm.syncPeer.IsWitnessEnabled() {
-				iv.Type = wire.InvTypeWitnessBlock
-			}
+// fetchSnapshotBlocks requests the blocks below a loaded utxo snapshot that
+// are needed to continue validating them in the background from the sync peer
+// when the request queue is getting short.
+func (sm *SyncManager) fetchSnapshotBlocks() {
+	syncPeerState, exists := sm.peerStates[sm.syncPeer]
+	if !exists || len(syncPeerState.requestedBlocks) >= minInFlightBlocks {
+		return
+	}
 
-			gdmsg.AddInvVect(iv)
-			numRequested++
+	hashes := sm.chain.SnapshotBlocksNeeded(snapshotBlocksWindow)
+	gdmsg := wire.NewMsgGetDataSizeHint(uint(len(hashes)))
+	for i := range hashes {
+		hash := &hashes[i]
+		if _, exists := sm.requestedBlocks[*hash]; exists {
+			continue
 		}
-		sm.startHeader = e.Next()
-		if numRequested >= wire.MaxInvPerMsg {
-			break
+
+		sm.requestedBlocks[*hash] = struct{}{}
+		syncPeerState.requestedBlocks[*hash] = struct{}{}
+
+		iv := wire.NewInvVect(wire.InvTypeBlock, hash)
+		if sm.syncPeer.IsWitnessEnabled() {
+			iv.Type = wire.InvTypeWitnessBlock
 		}
+		gdmsg.AddInvVect(iv)
 	}
 	if len(gdmsg.InvList) > 0 {
 		sm.syncPeer.QueueMessage(gdmsg, nil)
@@ -931,92 +1075,74 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 		return
 	}
 
-	// Nothing to do for an empty headers message.
-	if numHeaders == 0 {
+	// Headers are only requested from the sync peer, so ignore any that
+	// were requested from a previous one.
+	if peer != sm.syncPeer {
+		log.Debugf("Ignoring %d headers from peer %s that is not the "+
+			"sync peer", numHeaders, peer.Addr())
 		return
 	}
 
-	// Process all of the received headers ensuring each one connects to the
-	// previous and that checkpoints match.
-	receivedCheckpoint := false
+	// Process all of the received headers ensuring each one connects to a
+	// known header, follows the proof of work rules, and matches the
+	// checkpoints.
 	var finalHash *chainhash.Hash
+	var failed bool
 	for _, blockHeader := range msg.Headers {
 		blockHash := blockHeader.BlockHash()
 		finalHash = &blockHash
 
-		// Ensure there is a previous header to compare against.
-		prevNodeEl := sm.headerList.Back()
-		if prevNodeEl == nil {
-			log.Warnf("Header list does not contain a previous" +
-				"element as expected -- disconnecting peer")
-			peer.Disconnect()
-			return
-		}
-
-		// Ensure the header properly connects to the previous one and
-		// add it to the list of headers.
-		node := headerNode{hash: &blockHash}
-		prevNode := prevNodeEl.Value.(*headerNode)
-		if prevNode.hash.IsEqual(&blockHeader.PrevBlock) {
-			node.height = prevNode.height + 1
-			e := sm.headerList.PushBack(&node)
-			if sm.startHeader == nil {
-				sm.startHeader = e
-			}
-		} else {
-			log.Warnf("Received block header that does not "+
-				"properly connect to the chain from peer %s "+
-				"-- disconnecting", peer.Addr())
-			peer.Disconnect()
-			return
-		}
-
-		// Verify the header at the next checkpoint height matches.
-		if node.height == sm.nextCheckpoint.Height {
-			if node.hash.IsEqual(sm.nextCheckpoint.Hash) {
-				receivedCheckpoint = true
-				log.Infof("Verified downloaded block "+
-					"header against checkpoint at height "+
-					"%d/hash %s", node.height, node.hash)
-			} else {
-				log.Warnf("Block header at height %d/hash "+
-					"%s from peer %s does NOT match "+
-					"expected checkpoint hash of %s -- "+
-					"disconnecting", node.height,
-					node.hash, peer.Addr(),
-					sm.nextCheckpoint.Hash)
+		err := sm.chain.ProcessBlockHeader(blockHeader, blockchain.BFNone)
+		if err != nil {
+			if _, ok := err.(blockchain.RuleError); ok {
+				log.Warnf("Rejected block header %v from peer "+
+					"%s: %v -- disconnecting", blockHash,
+					peer.Addr(), err)
 				peer.Disconnect()
-				return
+			} else {
+				log.Errorf("Failed to process block header "+
+					"%v: %v", blockHash, err)
 			}
+			failed = true
 			break
 		}
 	}
 
-	// When this header is a checkpoint, switch to fetching the blocks for
-	// all of the headers since the last checkpoint.
-	if receivedCheckpoint {
-		// Since the first entry of the list is always the final block
-		// that is already in the database and is only used to ensure
-		// the next header links properly, it must be removed before
-		// fetching the blocks.
-		sm.headerList.Remove(sm.headerList.Front())
-		log.Infof("Received %v block headers: Fetching blocks",
-			sm.headerList.Len())
-		sm.progressLogger.SetLastLogTime(time.Now())
-		sm.fetchHeaderBlocks()
+	// Write the headers that were accepted to the database once for the
+	// whole message rather than for every header.
+	if err := sm.chain.FlushBlockIndex(); err != nil {
+		log.Errorf("Failed to store block headers: %v", err)
 		return
 	}
-
-	// This header is not a checkpoint, so request the next batch of
-	// headers starting from the latest known header and ending with the
-	// next checkpoint.
-	locator := blockchain.BlockLocator([]*chainhash.Hash{finalHash})
-	err := peer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
-	if err != nil {
-		log.Warnf("Failed to send getheaders message to "+
-			"peer %s: %v", peer.Addr(), err)
+	if failed {
 		return
 	}
+	sm.lastProgressTime = time.Now()
+
+	// When the peer sent the maximum number of headers, request the next
+	// batch of headers starting from the latest received header.
+	// Otherwise, all of the headers known to the peer were downloaded.
+	_, bestHeaderHeight := sm.chain.BestHeader()
+	if numHeaders == wire.MaxBlockHeadersPerMsg {
+		locator := blockchain.BlockLocator([]*chainhash.Hash{finalHash})
+		err := peer.PushGetHeadersMsg(locator, &zeroHash)
+		if err != nil {
+			log.Warnf("Failed to send getheaders message to "+
+				"peer %s: %v", peer.Addr(), err)
+			return
+		}
+		log.Infof("Downloaded block headers up to height %d from "+
+			"peer %s", bestHeaderHeight, peer.Addr())
+	} else {
+		sm.headersSynced = true
+		log.Infof("Downloaded all block headers from peer %s -- best "+
+			"header height %d", peer.Addr(), bestHeaderHeight)
+	}
+
+	// Download the blocks for the headers.
+	sm.progressLogger.SetLastLogTime(time.Now())
+	sm.fetchBlocks()
+	sm.maybeFinishHeadersFirst()
 }
 
 // handleNotFoundMsg handles notfound messages from all peers.
@@ -1027,6 +1153,7 @@ func (sm *SyncManager) handleNotFoundMsg(nfmsg *notFoundMsg) {
 		log.Warnf("Received notfound message from unknown peer %s", peer)
 		return
 	}
+	refetch := false
 	for _, inv := range nfmsg.notFound.InvList {
 		// verify the hash was actually announced by the peer
 		// before deleting from the global requested maps.
@@ -1034,11 +1161,21 @@ func (sm *SyncManager) handleNotFoundMsg(nfmsg *notFoundMsg) {
 		case wire.InvTypeWitnessBlock:
 			fallthrough
 		case wire.InvTypeBlock:
-			if _, exists := state.requestedBlocks[inv.Hash]; exists {
-				delete(state.requestedBlocks, inv.Hash)
-				delete(sm.requestedBlocks, inv.Hash)
+			if _, exists := state.requestedBlocks[inv.Hash]; !exists {
+				continue
 			}
 
+			// Request the blocks that were in flight from the peer
+			// in headers-first mode from the other peers.
+			inFlight, exists := sm.inFlightBlocks[inv.Hash]
+			if exists && inFlight.peer == peer {
+				sm.expireBlock(peer, inv.Hash)
+				refetch = true
+				continue
+			}
+			delete(state.requestedBlocks, inv.Hash)
+			delete(sm.requestedBlocks, inv.Hash)
+
 		case wire.InvTypeWitnessTx:
 			fallthrough
 		case wire.InvTypeTx:
@@ -1048,6 +1185,9 @@ func (sm *SyncManager) handleNotFoundMsg(nfmsg *notFoundMsg) {
 			}
 		}
 	}
+	if refetch {
+		sm.fetchBlocks()
+	}
 }
 
 // haveInventory returns whether or not the inventory represented by the passed
@@ -1137,6 +1277,24 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 		return
 	}
 
+	// New blocks are learned about by downloading the headers that follow
+	// the best known header in headers-first mode once all of the headers
+	// known to the sync peer were downloaded.
+	if sm.headersFirstMode && sm.headersSynced && lastBlock != -1 &&
+		peer == sm.syncPeer {
+
+		_, err := sm.chain.HeaderByHash(&invVects[lastBlock].Hash)
+		if err != nil {
+			sm.headersSynced = false
+			err := peer.PushGetHeadersMsg(sm.chain.BestHeaderLocator(),
+				&zeroHash)
+			if err != nil {
+				log.Warnf("Failed to send getheaders message "+
+					"to peer %s: %v", peer.Addr(), err)
+			}
+		}
+	}
+
 	// If our chain is current and a peer announces a block we already
 	// know of, then update their current block height.
 	if lastBlock != -1 && sm.current() {
@@ -1308,6 +1466,8 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 func (sm *SyncManager) blockHandler() {
 	stallTicker := time.NewTicker(stallSampleInterval)
 	defer stallTicker.Stop()
+	downloadTicker := time.NewTicker(downloadSampleInterval)
+	defer downloadTicker.Stop()
 
 out:
 	for {
@@ -1374,6 +1534,9 @@ out:
 		case <-stallTicker.C:
 			sm.handleStallSample()
 
+		case <-downloadTicker.C:
+			sm.handleDownloadSample()
+
 		case <-sm.quit:
 			break out
 		}
@@ -1415,6 +1578,13 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 			break
 		}
 
+		// Register block with the fee estimator, if it exists.  This
+		// must happen before the transactions in the block are removed
+		// from the transaction pool so they are recorded as confirmed.
+		if sm.feeEstimator != nil {
+			sm.feeEstimator.RegisterBlock(block)
+		}
+
 		// Remove all of the transactions (except the coinbase) in the
 		// connected block from the transaction pool.  Secondly, remove any
 		// transactions which are now double spends as a result of these
@@ -1431,20 +1601,6 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 			sm.peerNotifier.AnnounceNewTransactions(acceptedTxs)
 		}
 
-		// Register block with the fee estimator, if it exists.
-		if sm.feeEstimator != nil {
-			err := sm.feeEstimator.RegisterBlock(block)
-
-			// If an error is somehow generated then the fee estimator
-			// has entered an invalid state. Since it doesn't know how
-			// to recover, create a new one.
-			if err != nil {
-				sm.feeEstimator = mempool.NewFeeEstimator(
-					mempool.DefaultEstimateFeeMaxRollback,
-					mempool.DefaultEstimateFeeMinRegisteredBlocks)
-			}
-		}
-
 	// A block has been disconnected from the main block chain.
 	case blockchain.NTBlockDisconnected:
 		block, ok := notification.Data.(*btcutil.Block)
@@ -1466,10 +1622,9 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 			}
 		}
 
-		// Rollback previous block recorded by the fee estimator.
-		if sm.feeEstimator != nil {
-			sm.feeEstimator.Rollback(block.Hash())
-		}
+		// Evict transactions if the pool is full now that all of them
+		// are added back.
+		sm.txMemPool.TrimToSize()
 	}
 }
 
@@ -1628,19 +1783,12 @@ func New(config *Config) (*SyncManager, error) {
 		peerStates:      make(map[*peerpkg.Peer]*peerSyncState),
 		progressLogger:  newBlockProgressLogger("Processed", log),
 		msgChan:         make(chan interface{}, config.MaxPeers*3),
-		headerList:      list.New(),
+		inFlightBlocks:  make(map[chainhash.Hash]*inFlightBlock),
 		quit:            make(chan struct{}),
 		feeEstimator:    config.FeeEstimator,
 	}
 
-	best := sm.chain.BestSnapshot()
-	if !config.DisableCheckpoints {
-		// Initialize the next checkpoint based on the current height.
-		sm.nextCheckpoint = sm.findNextHeaderCheckpoint(best.Height)
-		if sm.nextCheckpoint != nil {
-			sm.resetHeaderState(&best.Hash, best.Height)
-		}
-	} else {
+	if config.DisableCheckpoints {
 		log.Info("Checkpoints are disabled")
 	}
 
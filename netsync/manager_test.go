@@ -0,0 +1,443 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	_ "github.com/btcsuite/btcd/database/ffldb"
+	peerpkg "github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testPeer is a peer connected to the sync manager under test along with the
+// getdata messages the remote end of the connection received.
+type testPeer struct {
+	*peerpkg.Peer
+	getData chan *wire.MsgGetData
+}
+
+// syncHarness provides a sync manager in headers-first mode along with a chain
+// that only knows the headers of the blocks to download.
+type syncHarness struct {
+	t      *testing.T
+	params *chaincfg.Params
+	chain  *blockchain.BlockChain
+	sm     *SyncManager
+}
+
+// newSyncHarness returns a sync harness backed by a chain stored in a
+// temporary directory.  A copy of the regression test parameters is used so
+// the sync manager does the headers-first download like on the other
+// networks.
+func newSyncHarness(t *testing.T) *syncHarness {
+	t.Helper()
+
+	// The package logger is nil until it is set.
+	DisableLog()
+
+	params := chaincfg.RegressionNetParams
+	db, err := database.Create("ffldb", t.TempDir(), params.Net)
+	if err != nil {
+		t.Fatalf("Failed to create db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:               db,
+		ChainParams:      &params,
+		TimeSource:       blockchain.NewMedianTime(),
+		UtxoCacheMaxSize: blockchain.DefaultUtxoCacheMaxSize,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+
+	sm, err := New(&Config{
+		Chain:       chain,
+		ChainParams: &params,
+		MaxPeers:    8,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create sync manager: %v", err)
+	}
+
+	return &syncHarness{t: t, params: &params, chain: chain, sm: sm}
+}
+
+// addHeaders extends the best header chain by the passed number of headers.
+func (h *syncHarness) addHeaders(num int) {
+	h.t.Helper()
+
+	tipHash, tipHeight := h.chain.BestHeader()
+	tip, err := h.chain.HeaderByHash(&tipHash)
+	if err != nil {
+		h.t.Fatalf("HeaderByHash: %v", err)
+	}
+	for i := 0; i < num; i++ {
+		header := wire.BlockHeader{
+			Version:    4,
+			PrevBlock:  tip.BlockHash(),
+			MerkleRoot: chainhash.Hash{byte(tipHeight), byte(i)},
+			Timestamp:  tip.Timestamp.Add(time.Minute),
+			Bits:       h.params.PowLimitBits,
+		}
+		err := h.chain.ProcessBlockHeader(&header, blockchain.BFNoPoWCheck)
+		if err != nil {
+			h.t.Fatalf("ProcessBlockHeader: %v", err)
+		}
+		tip = header
+	}
+}
+
+// addPeer connects a new witness enabled full node peer whose best block is at
+// the passed height and informs the sync manager about it.
+func (h *syncHarness) addPeer(height int32) *testPeer {
+	h.t.Helper()
+
+	verAck := make(chan struct{}, 2)
+	onVerAck := func(*peerpkg.Peer, *wire.MsgVerAck) {
+		verAck <- struct{}{}
+	}
+	getData := make(chan *wire.MsgGetData, 10)
+	remote := peerpkg.NewInboundPeer(&peerpkg.Config{
+		Listeners: peerpkg.MessageListeners{
+			OnVerAck: onVerAck,
+			OnGetData: func(_ *peerpkg.Peer, msg *wire.MsgGetData) {
+				getData <- msg
+			},
+		},
+		NewestBlock: func() (*chainhash.Hash, int32, error) {
+			return &zeroHash, height, nil
+		},
+		ChainParams:    h.params,
+		Services:       wire.SFNodeNetwork | wire.SFNodeWitness,
+		AllowSelfConns: true,
+	})
+	local, err := peerpkg.NewOutboundPeer(&peerpkg.Config{
+		Listeners: peerpkg.MessageListeners{
+			OnVerAck: onVerAck,
+		},
+		ChainParams:    h.params,
+		AllowSelfConns: true,
+	}, "127.0.0.1:18444")
+	if err != nil {
+		h.t.Fatalf("NewOutboundPeer: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		h.t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	localConn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		h.t.Fatalf("Failed to connect: %v", err)
+	}
+	remoteConn, err := listener.Accept()
+	if err != nil {
+		h.t.Fatalf("Failed to accept connection: %v", err)
+	}
+	local.AssociateConnection(localConn)
+	remote.AssociateConnection(remoteConn)
+	h.t.Cleanup(func() {
+		local.Disconnect()
+		remote.Disconnect()
+		local.WaitForDisconnect()
+		remote.WaitForDisconnect()
+	})
+	for i := 0; i < 2; i++ {
+		select {
+		case <-verAck:
+		case <-time.After(5 * time.Second):
+			h.t.Fatal("Timed out waiting for peer negotiation")
+		}
+	}
+
+	h.sm.handleNewPeerMsg(local)
+	return &testPeer{Peer: local, getData: getData}
+}
+
+// inFlight returns the hashes of the blocks in flight from the passed peer.
+func (h *syncHarness) inFlight(p *testPeer) map[chainhash.Hash]struct{} {
+	hashes := make(map[chainhash.Hash]struct{})
+	for hash, inFlight := range h.sm.inFlightBlocks {
+		if inFlight.peer == p.Peer {
+			hashes[hash] = struct{}{}
+		}
+	}
+	return hashes
+}
+
+// checkInFlight ensures the blocks in flight from the passed peer are the
+// passed number of blocks it has and that they match the ones it was asked
+// for.
+func (h *syncHarness) checkInFlight(p *testPeer, num int) {
+	h.t.Helper()
+
+	hashes := h.inFlight(p)
+	if len(hashes) != num {
+		h.t.Fatalf("peer %s has %d blocks in flight, want %d", p,
+			len(hashes), num)
+	}
+	state := h.sm.peerStates[p.Peer]
+	if len(state.requestedBlocks) != num {
+		h.t.Fatalf("peer %s has %d requested blocks, want %d", p,
+			len(state.requestedBlocks), num)
+	}
+	for hash := range hashes {
+		if _, ok := state.requestedBlocks[hash]; !ok {
+			h.t.Fatalf("block %v in flight from peer %s is not "+
+				"requested from it", hash, p)
+		}
+		if _, ok := h.sm.requestedBlocks[hash]; !ok {
+			h.t.Fatalf("block %v in flight from peer %s is not "+
+				"requested", hash, p)
+		}
+		if h.sm.inFlightBlocks[hash].height > p.LastBlock() {
+			h.t.Fatalf("block %v at height %d requested from "+
+				"peer %s at height %d", hash,
+				h.sm.inFlightBlocks[hash].height, p,
+				p.LastBlock())
+		}
+	}
+}
+
+// receiveGetData ensures the passed peer receives a getdata message for the
+// passed blocks.
+func (h *syncHarness) receiveGetData(p *testPeer, hashes ...chainhash.Hash) {
+	h.t.Helper()
+
+	var msg *wire.MsgGetData
+	select {
+	case msg = <-p.getData:
+	case <-time.After(5 * time.Second):
+		h.t.Fatalf("Timed out waiting for getdata from peer %s", p)
+	}
+	if len(msg.InvList) != len(hashes) {
+		h.t.Fatalf("peer %s received getdata for %d blocks, want %d", p,
+			len(msg.InvList), len(hashes))
+	}
+	want := make(map[chainhash.Hash]struct{})
+	for _, hash := range hashes {
+		want[hash] = struct{}{}
+	}
+	for _, iv := range msg.InvList {
+		if _, ok := want[iv.Hash]; !ok ||
+			iv.Type != wire.InvTypeWitnessBlock {
+
+			h.t.Fatalf("peer %s received unexpected getdata for %v",
+				p, iv)
+		}
+	}
+}
+
+// inFlightAt returns the hash of the block at the passed height that is in
+// flight from the passed peer.
+func (h *syncHarness) inFlightAt(p *testPeer, height int32) (chainhash.Hash, bool) {
+	for hash, inFlight := range h.sm.inFlightBlocks {
+		if inFlight.peer == p.Peer && inFlight.height == height {
+			return hash, true
+		}
+	}
+	return chainhash.Hash{}, false
+}
+
+// TestFetchBlocks ensures the blocks in the download window are spread across
+// the peers that have them without exceeding the number of blocks in flight
+// per peer.
+func TestFetchBlocks(t *testing.T) {
+	h := newSyncHarness(t)
+	peer1 := h.addPeer(40)
+	peer2 := h.addPeer(40)
+	peer3 := h.addPeer(10)
+	if !h.sm.headersFirstMode {
+		t.Fatal("sync manager is not in headers-first mode")
+	}
+
+	// Every peer can download more blocks, but there are not enough
+	// blocks to keep all of them busy, so the window is exhausted.
+	h.addHeaders(12)
+	h.sm.fetchBlocks()
+	for _, p := range []*testPeer{peer1, peer2, peer3} {
+		n := len(h.inFlight(p))
+		if n == 0 {
+			t.Fatalf("no blocks were requested from peer %s", p)
+		}
+		h.checkInFlight(p, n)
+	}
+	if len(h.sm.inFlightBlocks) != 12 {
+		t.Fatalf("%d blocks in flight, want 12", len(h.sm.inFlightBlocks))
+	}
+	if !h.sm.windowExhausted {
+		t.Fatal("download window is not exhausted")
+	}
+
+	// Only the first two peers have the additional blocks and they can
+	// download no more than the maximum number of blocks at a time.
+	h.addHeaders(28)
+	numPeer3 := len(h.inFlight(peer3))
+	h.sm.fetchBlocks()
+	h.checkInFlight(peer1, maxBlocksInFlightPerPeer)
+	h.checkInFlight(peer2, maxBlocksInFlightPerPeer)
+	h.checkInFlight(peer3, numPeer3)
+	if h.sm.windowExhausted {
+		t.Fatal("download window is exhausted while blocks are not " +
+			"requested")
+	}
+
+	// Each peer is sent the blocks that were assigned to it.
+	for _, p := range []*testPeer{peer1, peer2, peer3} {
+		var hashes []chainhash.Hash
+		for hash := range h.inFlight(p) {
+			hashes = append(hashes, hash)
+		}
+		var received []chainhash.Hash
+		for len(received) < len(hashes) {
+			select {
+			case msg := <-p.getData:
+				for _, iv := range msg.InvList {
+					received = append(received, iv.Hash)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Timed out waiting for getdata from "+
+					"peer %s", p)
+			}
+		}
+		for _, hash := range received {
+			if _, ok := h.inFlight(p)[hash]; !ok {
+				t.Fatalf("peer %s received getdata for block "+
+					"%v that is not in flight from it", p,
+					hash)
+			}
+		}
+	}
+}
+
+// TestDownloadStall ensures the peer that was asked for the block following
+// the best block is disconnected when the download window can't move forward
+// without it.
+func TestDownloadStall(t *testing.T) {
+	h := newSyncHarness(t)
+	peer1 := h.addPeer(10)
+	peer2 := h.addPeer(10)
+	h.addHeaders(10)
+	h.sm.fetchBlocks()
+	if !h.sm.windowExhausted {
+		t.Fatal("download window is not exhausted")
+	}
+
+	stalling, other := peer1, peer2
+	hash, ok := h.inFlightAt(stalling, 1)
+	if !ok {
+		stalling, other = peer2, peer1
+		hash, _ = h.inFlightAt(stalling, 1)
+	}
+
+	// The peer is given some time to deliver the block.
+	h.sm.handleDownloadSample()
+	if !stalling.Connected() {
+		t.Fatal("stalling peer disconnected before the timeout")
+	}
+
+	h.sm.inFlightBlocks[hash].requested = time.Now().Add(-blockStallTimeout -
+		time.Second)
+	h.sm.handleDownloadSample()
+	if stalling.Connected() {
+		t.Fatal("stalling peer is still connected")
+	}
+	if !other.Connected() {
+		t.Fatal("other peer was disconnected")
+	}
+}
+
+// TestDownloadTimeout ensures a block that isn't delivered in time is
+// requested from another peer and no longer counts toward the blocks in flight
+// from the slow peer.
+func TestDownloadTimeout(t *testing.T) {
+	h := newSyncHarness(t)
+	peer1 := h.addPeer(10)
+	peer2 := h.addPeer(10)
+	h.addHeaders(10)
+	h.sm.fetchBlocks()
+	for _, p := range []*testPeer{peer1, peer2} {
+		<-p.getData
+	}
+
+	// Time out a block that is not the next one to connect, so the peer
+	// is not considered to stall the download.
+	slow, other := peer1, peer2
+	hash, ok := h.inFlightAt(slow, 2)
+	if !ok {
+		slow, other = peer2, peer1
+		hash, _ = h.inFlightAt(slow, 2)
+	}
+	numSlow, numOther := len(h.inFlight(slow)), len(h.inFlight(other))
+	h.sm.inFlightBlocks[hash].requested = time.Now().Add(
+		-blockDownloadTimeout - time.Second)
+	h.sm.handleDownloadSample()
+
+	if !slow.Connected() {
+		t.Fatal("slow peer was disconnected")
+	}
+	h.checkInFlight(slow, numSlow-1)
+	h.checkInFlight(other, numOther+1)
+	if _, ok := h.inFlight(other)[hash]; !ok {
+		t.Fatal("timed out block was not requested from the other peer")
+	}
+	if _, ok := h.sm.peerStates[slow.Peer].expiredBlocks[hash]; !ok {
+		t.Fatal("timed out block is not expired for the slow peer")
+	}
+	h.receiveGetData(other, hash)
+
+	// The block is not requested from the slow peer again when the other
+	// peer disconnects before delivering it.
+	h.sm.handleDonePeerMsg(other.Peer)
+	if _, ok := h.sm.inFlightBlocks[hash]; ok {
+		t.Fatal("timed out block was requested from the slow peer again")
+	}
+}
+
+// TestNotFoundBlocks ensures the blocks a peer reports as not found are
+// requested from another peer.
+func TestNotFoundBlocks(t *testing.T) {
+	h := newSyncHarness(t)
+	peer1 := h.addPeer(10)
+	peer2 := h.addPeer(10)
+	h.addHeaders(10)
+	h.sm.fetchBlocks()
+	for _, p := range []*testPeer{peer1, peer2} {
+		<-p.getData
+	}
+
+	// A notfound message for a block that was not requested from the
+	// peer is ignored.
+	var hash chainhash.Hash
+	for hash = range h.inFlight(peer2) {
+		break
+	}
+	numPeer1, numPeer2 := len(h.inFlight(peer1)), len(h.inFlight(peer2))
+	notFound := wire.NewMsgNotFound()
+	notFound.AddInvVect(wire.NewInvVect(wire.InvTypeWitnessBlock, &hash))
+	h.sm.handleNotFoundMsg(&notFoundMsg{notFound: notFound, peer: peer1.Peer})
+	h.checkInFlight(peer1, numPeer1)
+	h.checkInFlight(peer2, numPeer2)
+
+	// The block the peer doesn't have is requested from the other peer.
+	h.sm.handleNotFoundMsg(&notFoundMsg{notFound: notFound, peer: peer2.Peer})
+	h.checkInFlight(peer1, numPeer1+1)
+	h.checkInFlight(peer2, numPeer2-1)
+	if _, ok := h.inFlight(peer1)[hash]; !ok {
+		t.Fatal("block that was not found was not requested from the " +
+			"other peer")
+	}
+	h.receiveGetData(peer1, hash)
+}
@@ -218,9 +218,16 @@ func (cm *rpcConnManager) AddRebroadcastInventory(iv *wire.InvVect, data interfa
 }
 
 // RelayTransactions generates and relays inventory vectors for all of the
-// passed transactions to all connected peers.
+// passed transactions to all connected peers.  Since the transactions were just
+// added to the memory pool, Electrum clients are notified of them as well.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
 func (cm *rpcConnManager) RelayTransactions(txns []*mempool.TxDesc) {
 	cm.server.relayTransactions(txns)
+	if cm.server.electrumServer != nil {
+		cm.server.electrumServer.NotifyMempoolChanged()
+	}
 }
 
 // NodeAddresses returns an array consisting node addresses which can
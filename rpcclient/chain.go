@@ -271,6 +271,54 @@ func (c *Client) GetBlockVerboseTx(blockHash *chainhash.Hash) (*btcjson.GetBlock
 	return c.GetBlockVerboseTxAsync(blockHash).Receive()
 }
 
+// FutureGetBlockVerboseTxPrevOutResult is a future promise to deliver the
+// result of a GetBlockVerboseTxPrevOutAsync RPC invocation (or an applicable
+// error).
+type FutureGetBlockVerboseTxPrevOutResult chan *Response
+
+// Receive waits for the Response promised by the future and returns a verbose
+// version of the block including detailed information about its transactions,
+// their fees and the outputs spent by their inputs.
+func (r FutureGetBlockVerboseTxPrevOutResult) Receive() (*btcjson.GetBlockVerboseTxResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var blockResult btcjson.GetBlockVerboseTxResult
+	err = json.Unmarshal(res, &blockResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return &blockResult, nil
+}
+
+// GetBlockVerboseTxPrevOutAsync returns an instance of a type that can be used
+// to get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetBlockVerboseTxPrevOut for the blocking version and more details.
+func (c *Client) GetBlockVerboseTxPrevOutAsync(blockHash *chainhash.Hash) FutureGetBlockVerboseTxPrevOutResult {
+	hash := ""
+	if blockHash != nil {
+		hash = blockHash.String()
+	}
+
+	cmd := btcjson.NewGetBlockCmd(hash, btcjson.Int(3))
+	return c.SendCmd(cmd)
+}
+
+// GetBlockVerboseTxPrevOut returns a data structure from the server with
+// information about a block and its transactions given its hash.  Unlike
+// GetBlockVerboseTx, the transactions also include their fees and the outputs
+// spent by their inputs.
+//
+// See GetBlockVerboseTx if the spent outputs are not needed.
+func (c *Client) GetBlockVerboseTxPrevOut(blockHash *chainhash.Hash) (*btcjson.GetBlockVerboseTxResult, error) {
+	return c.GetBlockVerboseTxPrevOutAsync(blockHash).Receive()
+}
+
 // FutureGetBlockCountResult is a future promise to deliver the result of a
 // GetBlockCountAsync RPC invocation (or an applicable error).
 type FutureGetBlockCountResult chan *Response
@@ -508,6 +556,43 @@ func (c *Client) GetBlockChainInfo() (*btcjson.GetBlockChainInfoResult, error) {
 	return c.GetBlockChainInfoAsync().Receive()
 }
 
+// FutureGetChainTipsResult is a future promise to deliver the result of a
+// GetChainTipsAsync RPC invocation (or an applicable error).
+type FutureGetChainTipsResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the chain
+// tips known to the server.
+func (r FutureGetChainTipsResult) Receive() ([]btcjson.GetChainTipsResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var chainTips []btcjson.GetChainTipsResult
+	err = json.Unmarshal(res, &chainTips)
+	if err != nil {
+		return nil, err
+	}
+
+	return chainTips, nil
+}
+
+// GetChainTipsAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GetChainTips for the blocking version and more details.
+func (c *Client) GetChainTipsAsync() FutureGetChainTipsResult {
+	cmd := btcjson.NewGetChainTipsCmd()
+	return c.SendCmd(cmd)
+}
+
+// GetChainTips returns information about all known tips in the block tree,
+// including the main chain as well as any side chains.
+func (c *Client) GetChainTips() ([]btcjson.GetChainTipsResult, error) {
+	return c.GetChainTipsAsync().Receive()
+}
+
 // FutureGetBlockFilterResult is a future promise to deliver the result of a
 // GetBlockFilterAsync RPC invocation (or an applicable error).
 type FutureGetBlockFilterResult chan *Response
@@ -724,6 +809,136 @@ func (c *Client) GetMempoolEntry(txHash string) (*btcjson.GetMempoolEntryResult,
 	return c.GetMempoolEntryAsync(txHash).Receive()
 }
 
+// FutureGetMempoolRelativesResult is a future promise to deliver the result of
+// a GetMempoolAncestorsAsync or GetMempoolDescendantsAsync RPC invocation (or
+// an applicable error).
+type FutureGetMempoolRelativesResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the hashes
+// of the related transactions in the memory pool.
+func (r FutureGetMempoolRelativesResult) Receive() ([]*chainhash.Hash, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var txHashStrs []string
+	err = json.Unmarshal(res, &txHashStrs)
+	if err != nil {
+		return nil, err
+	}
+
+	txHashes := make([]*chainhash.Hash, 0, len(txHashStrs))
+	for _, hashStr := range txHashStrs {
+		txHash, err := chainhash.NewHashFromStr(hashStr)
+		if err != nil {
+			return nil, err
+		}
+		txHashes = append(txHashes, txHash)
+	}
+
+	return txHashes, nil
+}
+
+// FutureGetMempoolRelativesVerboseResult is a future promise to deliver the
+// result of a GetMempoolAncestorsVerboseAsync or
+// GetMempoolDescendantsVerboseAsync RPC invocation (or an applicable error).
+type FutureGetMempoolRelativesVerboseResult chan *Response
+
+// Receive waits for the Response promised by the future and returns a map of
+// transaction hashes to an associated data structure with information about the
+// transaction for the related transactions in the memory pool.
+func (r FutureGetMempoolRelativesVerboseResult) Receive() (map[string]btcjson.GetMempoolEntryResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries map[string]btcjson.GetMempoolEntryResult
+	err = json.Unmarshal(res, &entries)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetMempoolAncestorsAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetMempoolAncestors for the blocking version and more details.
+func (c *Client) GetMempoolAncestorsAsync(txHash string) FutureGetMempoolRelativesResult {
+	cmd := btcjson.NewGetMempoolAncestorsCmd(txHash, btcjson.Bool(false))
+	return c.SendCmd(cmd)
+}
+
+// GetMempoolAncestors returns the hashes of all unconfirmed ancestors in the
+// memory pool of the given transaction in the memory pool.
+//
+// See GetMempoolAncestorsVerbose to retrieve data structures with information
+// about the transactions instead.
+func (c *Client) GetMempoolAncestors(txHash string) ([]*chainhash.Hash, error) {
+	return c.GetMempoolAncestorsAsync(txHash).Receive()
+}
+
+// GetMempoolAncestorsVerboseAsync returns an instance of a type that can be
+// used to get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetMempoolAncestorsVerbose for the blocking version and more details.
+func (c *Client) GetMempoolAncestorsVerboseAsync(txHash string) FutureGetMempoolRelativesVerboseResult {
+	cmd := btcjson.NewGetMempoolAncestorsCmd(txHash, btcjson.Bool(true))
+	return c.SendCmd(cmd)
+}
+
+// GetMempoolAncestorsVerbose returns a map of transaction hashes to an
+// associated data structure with information about the transaction for all
+// unconfirmed ancestors in the memory pool of the given transaction in the
+// memory pool.
+//
+// See GetMempoolAncestors to retrieve only the transaction hashes instead.
+func (c *Client) GetMempoolAncestorsVerbose(txHash string) (map[string]btcjson.GetMempoolEntryResult, error) {
+	return c.GetMempoolAncestorsVerboseAsync(txHash).Receive()
+}
+
+// GetMempoolDescendantsAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetMempoolDescendants for the blocking version and more details.
+func (c *Client) GetMempoolDescendantsAsync(txHash string) FutureGetMempoolRelativesResult {
+	cmd := btcjson.NewGetMempoolDescendantsCmd(txHash, btcjson.Bool(false))
+	return c.SendCmd(cmd)
+}
+
+// GetMempoolDescendants returns the hashes of all descendants in the memory
+// pool of the given transaction in the memory pool.
+//
+// See GetMempoolDescendantsVerbose to retrieve data structures with information
+// about the transactions instead.
+func (c *Client) GetMempoolDescendants(txHash string) ([]*chainhash.Hash, error) {
+	return c.GetMempoolDescendantsAsync(txHash).Receive()
+}
+
+// GetMempoolDescendantsVerboseAsync returns an instance of a type that can be
+// used to get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetMempoolDescendantsVerbose for the blocking version and more details.
+func (c *Client) GetMempoolDescendantsVerboseAsync(txHash string) FutureGetMempoolRelativesVerboseResult {
+	cmd := btcjson.NewGetMempoolDescendantsCmd(txHash, btcjson.Bool(true))
+	return c.SendCmd(cmd)
+}
+
+// GetMempoolDescendantsVerbose returns a map of transaction hashes to an
+// associated data structure with information about the transaction for all
+// descendants in the memory pool of the given transaction in the memory pool.
+//
+// See GetMempoolDescendants to retrieve only the transaction hashes instead.
+func (c *Client) GetMempoolDescendantsVerbose(txHash string) (map[string]btcjson.GetMempoolEntryResult, error) {
+	return c.GetMempoolDescendantsVerboseAsync(txHash).Receive()
+}
+
 // FutureGetRawMempoolResult is a future promise to deliver the result of a
 // GetRawMempoolAsync RPC invocation (or an applicable error).
 type FutureGetRawMempoolResult chan *Response
@@ -1026,6 +1241,125 @@ func (c *Client) GetTxOut(txHash *chainhash.Hash, index uint32, mempool bool) (*
 	return c.GetTxOutAsync(txHash, index, mempool).Receive()
 }
 
+// FutureGetTxOutProofResult is a future promise to deliver the result of a
+// GetTxOutProofAsync RPC invocation (or an applicable error).
+type FutureGetTxOutProofResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the merkle
+// block that proves the inclusion of the requested transactions.
+func (r FutureGetTxOutProofResult) Receive() (*wire.MsgMerkleBlock, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a string.
+	var proofHex string
+	err = json.Unmarshal(res, &proofHex)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode the serialized merkle block hex to raw bytes.
+	serializedProof, err := hex.DecodeString(proofHex)
+	if err != nil {
+		return nil, err
+	}
+
+	// Deserialize the merkle block and return it.
+	var mBlock wire.MsgMerkleBlock
+	err = mBlock.BtcDecode(bytes.NewReader(serializedProof),
+		wire.ProtocolVersion, wire.LatestEncoding)
+	if err != nil {
+		return nil, err
+	}
+	return &mBlock, nil
+}
+
+// GetTxOutProofAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetTxOutProof for the blocking version and more details.
+func (c *Client) GetTxOutProofAsync(txHashes []*chainhash.Hash,
+	blockHash *chainhash.Hash) FutureGetTxOutProofResult {
+
+	txIDs := make([]string, 0, len(txHashes))
+	for _, txHash := range txHashes {
+		txIDs = append(txIDs, txHash.String())
+	}
+	var hash *string
+	if blockHash != nil {
+		hash = btcjson.String(blockHash.String())
+	}
+
+	cmd := btcjson.NewGetTxOutProofCmd(txIDs, hash)
+	return c.SendCmd(cmd)
+}
+
+// GetTxOutProof returns a merkle block that proves the inclusion of the passed
+// transactions in a block of the main chain.  The block is located by the
+// server via its transaction index when the passed block hash is nil.
+func (c *Client) GetTxOutProof(txHashes []*chainhash.Hash,
+	blockHash *chainhash.Hash) (*wire.MsgMerkleBlock, error) {
+
+	return c.GetTxOutProofAsync(txHashes, blockHash).Receive()
+}
+
+// FutureVerifyTxOutProofResult is a future promise to deliver the result of a
+// VerifyTxOutProofAsync RPC invocation (or an applicable error).
+type FutureVerifyTxOutProofResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the hashes
+// of the transactions proven by the merkle block.
+func (r FutureVerifyTxOutProofResult) Receive() ([]*chainhash.Hash, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as an array of strings.
+	var txHashStrs []string
+	err = json.Unmarshal(res, &txHashStrs)
+	if err != nil {
+		return nil, err
+	}
+
+	txHashes := make([]*chainhash.Hash, 0, len(txHashStrs))
+	for _, hashStr := range txHashStrs {
+		txHash, err := chainhash.NewHashFromStr(hashStr)
+		if err != nil {
+			return nil, err
+		}
+		txHashes = append(txHashes, txHash)
+	}
+
+	return txHashes, nil
+}
+
+// VerifyTxOutProofAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See VerifyTxOutProof for the blocking version and more details.
+func (c *Client) VerifyTxOutProofAsync(proof *wire.MsgMerkleBlock) FutureVerifyTxOutProofResult {
+	var buf bytes.Buffer
+	err := proof.BtcEncode(&buf, wire.ProtocolVersion, wire.LatestEncoding)
+	if err != nil {
+		return newFutureError(err)
+	}
+
+	cmd := btcjson.NewVerifyTxOutProofCmd(hex.EncodeToString(buf.Bytes()))
+	return c.SendCmd(cmd)
+}
+
+// VerifyTxOutProof verifies the passed merkle block against the main chain of
+// the server and returns the hashes of the transactions it proves.  No hashes
+// are returned when the proof is invalid.
+func (c *Client) VerifyTxOutProof(proof *wire.MsgMerkleBlock) ([]*chainhash.Hash, error) {
+	return c.VerifyTxOutProofAsync(proof).Receive()
+}
+
 // FutureGetTxOutSetInfoResult is a future promise to deliver the result of a
 // GetTxOutSetInfoAsync RPC invocation (or an applicable error).
 type FutureGetTxOutSetInfoResult chan *Response
@@ -1054,7 +1388,7 @@ func (r FutureGetTxOutSetInfoResult) Receive() (*btcjson.GetTxOutSetInfoResult,
 //
 // See GetTxOutSetInfo for the blocking version and more details.
 func (c *Client) GetTxOutSetInfoAsync() FutureGetTxOutSetInfoResult {
-	cmd := btcjson.NewGetTxOutSetInfoCmd()
+	cmd := btcjson.NewGetTxOutSetInfoCmd(nil, nil)
 	return c.SendCmd(cmd)
 }
 
@@ -1064,6 +1398,266 @@ func (c *Client) GetTxOutSetInfo() (*btcjson.GetTxOutSetInfoResult, error) {
 	return c.GetTxOutSetInfoAsync().Receive()
 }
 
+// FutureGetTxSpendingPrevOutResult is a future promise to deliver the result of
+// a GetTxSpendingPrevOutAsync RPC invocation (or an applicable error).
+type FutureGetTxSpendingPrevOutResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the
+// transactions that spend the queried outputs.
+func (r FutureGetTxSpendingPrevOutResult) Receive() ([]btcjson.GetTxSpendingPrevOutResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as an array of gettxspendingprevout result objects.
+	var spenders []btcjson.GetTxSpendingPrevOutResult
+	err = json.Unmarshal(res, &spenders)
+	if err != nil {
+		return nil, err
+	}
+
+	return spenders, nil
+}
+
+// GetTxSpendingPrevOutAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetTxSpendingPrevOut for the blocking version and more details.
+func (c *Client) GetTxSpendingPrevOutAsync(outpoints []wire.OutPoint) FutureGetTxSpendingPrevOutResult {
+	outputs := make([]btcjson.GetTxSpendingPrevOutCmdOutput, 0,
+		len(outpoints))
+	for _, outpoint := range outpoints {
+		outputs = append(outputs, btcjson.GetTxSpendingPrevOutCmdOutput{
+			Txid: outpoint.Hash.String(),
+			Vout: outpoint.Index,
+		})
+	}
+	cmd := btcjson.NewGetTxSpendingPrevOutCmd(outputs)
+	return c.SendCmd(cmd)
+}
+
+// GetTxSpendingPrevOut returns the transactions in the mempool or, when the
+// server maintains a transaction output spender index, in the main chain that
+// spend the passed outpoints.
+func (c *Client) GetTxSpendingPrevOut(outpoints []wire.OutPoint) ([]btcjson.GetTxSpendingPrevOutResult, error) {
+	return c.GetTxSpendingPrevOutAsync(outpoints).Receive()
+}
+
+// FutureDumpTxOutSetResult is a future promise to deliver the result of a
+// DumpTxOutSetAsync RPC invocation (or an applicable error).
+type FutureDumpTxOutSetResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the
+// information about the written utxo snapshot.
+func (r FutureDumpTxOutSetResult) Receive() (*btcjson.DumpTxOutSetResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var result btcjson.DumpTxOutSetResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// DumpTxOutSetAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See DumpTxOutSet for the blocking version and more details.
+func (c *Client) DumpTxOutSetAsync(path string) FutureDumpTxOutSetResult {
+	cmd := btcjson.NewDumpTxOutSetCmd(path)
+	return c.SendCmd(cmd)
+}
+
+// DumpTxOutSet writes a snapshot of the unspent transaction output set as of
+// the current best block to the passed path on the server.
+func (c *Client) DumpTxOutSet(path string) (*btcjson.DumpTxOutSetResult, error) {
+	return c.DumpTxOutSetAsync(path).Receive()
+}
+
+// FutureLoadTxOutSetResult is a future promise to deliver the result of a
+// LoadTxOutSetAsync RPC invocation (or an applicable error).
+type FutureLoadTxOutSetResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the
+// information about the loaded utxo snapshot.
+func (r FutureLoadTxOutSetResult) Receive() (*btcjson.LoadTxOutSetResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var result btcjson.LoadTxOutSetResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// LoadTxOutSetAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See LoadTxOutSet for the blocking version and more details.
+func (c *Client) LoadTxOutSetAsync(path string) FutureLoadTxOutSetResult {
+	cmd := btcjson.NewLoadTxOutSetCmd(path)
+	return c.SendCmd(cmd)
+}
+
+// LoadTxOutSet loads the utxo snapshot at the passed path on the server and
+// makes its block the tip of the main chain.
+func (c *Client) LoadTxOutSet(path string) (*btcjson.LoadTxOutSetResult, error) {
+	return c.LoadTxOutSetAsync(path).Receive()
+}
+
+// FutureSaveMempoolResult is a future promise to deliver the result of a
+// SaveMempoolAsync RPC invocation (or an applicable error).
+type FutureSaveMempoolResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the path
+// of the file the memory pool was saved to.
+func (r FutureSaveMempoolResult) Receive() (*btcjson.SaveMempoolResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var result btcjson.SaveMempoolResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// SaveMempoolAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See SaveMempool for the blocking version and more details.
+func (c *Client) SaveMempoolAsync() FutureSaveMempoolResult {
+	cmd := btcjson.NewSaveMempoolCmd()
+	return c.SendCmd(cmd)
+}
+
+// SaveMempool saves the transactions in the memory pool of the server to disk
+// so they are loaded again when it starts.
+func (c *Client) SaveMempool() (*btcjson.SaveMempoolResult, error) {
+	return c.SaveMempoolAsync().Receive()
+}
+
+// FutureSubmitPackageResult is a future promise to deliver the result of a
+// SubmitPackageAsync RPC invocation (or an applicable error).
+type FutureSubmitPackageResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the
+// outcome of submitting the package.
+func (r FutureSubmitPackageResult) Receive() (*btcjson.SubmitPackageResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var result btcjson.SubmitPackageResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// SubmitPackageAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See SubmitPackage for the blocking version and more details.
+func (c *Client) SubmitPackageAsync(txns []*wire.MsgTx) FutureSubmitPackageResult {
+	rawTxns := make([]string, 0, len(txns))
+	for _, tx := range txns {
+		var buf bytes.Buffer
+		if err := tx.Serialize(&buf); err != nil {
+			return newFutureError(err)
+		}
+		rawTxns = append(rawTxns, hex.EncodeToString(buf.Bytes()))
+	}
+
+	cmd := btcjson.NewSubmitPackageCmd(rawTxns)
+	return c.SendCmd(cmd)
+}
+
+// SubmitPackage submits a package consisting of a child transaction and its
+// parents to the memory pool of the server.  The parents must precede the child
+// and every transaction must follow the ones it spends.  Parents that don't pay
+// the minimum relay fee on their own are accepted when the package pays it in
+// total.
+func (c *Client) SubmitPackage(txns []*wire.MsgTx) (*btcjson.SubmitPackageResult, error) {
+	return c.SubmitPackageAsync(txns).Receive()
+}
+
+// FutureTestMempoolAcceptResult is a future promise to deliver the result of
+// a TestMempoolAcceptAsync RPC invocation (or an applicable error).
+type FutureTestMempoolAcceptResult chan *Response
+
+// Receive waits for the Response promised by the future and returns whether
+// each of the tested transactions would be accepted into the memory pool.
+func (r FutureTestMempoolAcceptResult) Receive() ([]btcjson.TestMempoolAcceptResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []btcjson.TestMempoolAcceptResult
+	err = json.Unmarshal(res, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// TestMempoolAcceptAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See TestMempoolAccept for the blocking version and more details.
+func (c *Client) TestMempoolAcceptAsync(txns []*wire.MsgTx,
+	maxFeeRate float64) FutureTestMempoolAcceptResult {
+
+	rawTxns := make([]string, 0, len(txns))
+	for _, tx := range txns {
+		var buf bytes.Buffer
+		if err := tx.Serialize(&buf); err != nil {
+			return newFutureError(err)
+		}
+		rawTxns = append(rawTxns, hex.EncodeToString(buf.Bytes()))
+	}
+
+	cmd := btcjson.NewTestMempoolAcceptCmd(rawTxns, &maxFeeRate)
+	return c.SendCmd(cmd)
+}
+
+// TestMempoolAccept returns whether the passed transactions would be accepted
+// into the memory pool of the server without adding or relaying them.  The
+// transactions are tested in order, so a transaction may spend the outputs of
+// the transactions before it.  Transactions with a fee rate above the passed
+// maximum in BTC/kvB are rejected, unless it is zero.
+func (c *Client) TestMempoolAccept(txns []*wire.MsgTx,
+	maxFeeRate float64) ([]btcjson.TestMempoolAcceptResult, error) {
+
+	return c.TestMempoolAcceptAsync(txns, maxFeeRate).Receive()
+}
+
 // FutureRescanBlocksResult is a future promise to deliver the result of a
 // RescanBlocksAsync RPC invocation (or an applicable error).
 //
@@ -1151,6 +1745,72 @@ func (c *Client) InvalidateBlock(blockHash *chainhash.Hash) error {
 	return c.InvalidateBlockAsync(blockHash).Receive()
 }
 
+// FuturePreciousBlockResult is a future promise to deliver the result of a
+// PreciousBlockAsync RPC invocation (or an applicable error).
+type FuturePreciousBlockResult chan *Response
+
+// Receive waits for the Response promised by the future and returns an error
+// if any occurred when marking the block as precious.
+func (r FuturePreciousBlockResult) Receive() error {
+	_, err := ReceiveFuture(r)
+
+	return err
+}
+
+// PreciousBlockAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See PreciousBlock for the blocking version and more details.
+func (c *Client) PreciousBlockAsync(blockHash *chainhash.Hash) FuturePreciousBlockResult {
+	hash := ""
+	if blockHash != nil {
+		hash = blockHash.String()
+	}
+
+	cmd := btcjson.NewPreciousBlockCmd(hash)
+	return c.SendCmd(cmd)
+}
+
+// PreciousBlock treats a block as if it were received before others with the
+// same work.
+func (c *Client) PreciousBlock(blockHash *chainhash.Hash) error {
+	return c.PreciousBlockAsync(blockHash).Receive()
+}
+
+// FutureReconsiderBlockResult is a future promise to deliver the result of a
+// ReconsiderBlockAsync RPC invocation (or an applicable error).
+type FutureReconsiderBlockResult chan *Response
+
+// Receive waits for the Response promised by the future and returns an error
+// if any occurred when reconsidering the block.
+func (r FutureReconsiderBlockResult) Receive() error {
+	_, err := ReceiveFuture(r)
+
+	return err
+}
+
+// ReconsiderBlockAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See ReconsiderBlock for the blocking version and more details.
+func (c *Client) ReconsiderBlockAsync(blockHash *chainhash.Hash) FutureReconsiderBlockResult {
+	hash := ""
+	if blockHash != nil {
+		hash = blockHash.String()
+	}
+
+	cmd := btcjson.NewReconsiderBlockCmd(hash)
+	return c.SendCmd(cmd)
+}
+
+// ReconsiderBlock removes the invalid status of a block previously marked
+// invalid with InvalidateBlock.
+func (c *Client) ReconsiderBlock(blockHash *chainhash.Hash) error {
+	return c.ReconsiderBlockAsync(blockHash).Receive()
+}
+
 // FutureGetCFilterResult is a future promise to deliver the result of a
 // GetCFilterAsync RPC invocation (or an applicable error).
 type FutureGetCFilterResult chan *Response
@@ -1382,3 +2042,41 @@ func (c *Client) GetDescriptorInfoAsync(descriptor string) FutureGetDescriptorIn
 func (c *Client) GetDescriptorInfo(descriptor string) (*btcjson.GetDescriptorInfoResult, error) {
 	return c.GetDescriptorInfoAsync(descriptor).Receive()
 }
+
+// FutureGetIndexInfoResult is a future promise to deliver the result of a
+// GetIndexInfoAsync RPC invocation (or an applicable error).
+type FutureGetIndexInfoResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the sync
+// state of the optional indexes keyed by their name.
+func (r FutureGetIndexInfoResult) Receive() (map[string]btcjson.GetIndexInfoResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var indexInfo map[string]btcjson.GetIndexInfoResult
+	err = json.Unmarshal(res, &indexInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return indexInfo, nil
+}
+
+// GetIndexInfoAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GetIndexInfo for the blocking version and more details.
+func (c *Client) GetIndexInfoAsync(indexName *string) FutureGetIndexInfoResult {
+	cmd := btcjson.NewGetIndexInfoCmd(indexName)
+	return c.SendCmd(cmd)
+}
+
+// GetIndexInfo returns whether or not each of the optional indexes has caught
+// up with the main chain along with the height of its tip.  Only the index
+// with the passed name is returned when it is not nil.
+func (c *Client) GetIndexInfo(indexName *string) (map[string]btcjson.GetIndexInfoResult, error) {
+	return c.GetIndexInfoAsync(indexName).Receive()
+}
@@ -471,3 +471,91 @@ func (c *Client) VersionAsync() FutureVersionResult {
 func (c *Client) Version() (map[string]btcjson.VersionResult, error) {
 	return c.VersionAsync().Receive()
 }
+
+// FutureGetAddressBalanceResult is a future promise to deliver the result of a
+// GetAddressBalanceAsync RPC invocation (or an applicable error).
+type FutureGetAddressBalanceResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the
+// balance of the address along with the total amounts it received and sent.
+func (r FutureGetAddressBalanceResult) Receive() (*btcjson.GetAddressBalanceResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a getaddressbalance result object.
+	var balance btcjson.GetAddressBalanceResult
+	err = json.Unmarshal(res, &balance)
+	if err != nil {
+		return nil, err
+	}
+
+	return &balance, nil
+}
+
+// GetAddressBalanceAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetAddressBalance for the blocking version and more details.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) GetAddressBalanceAsync(address btcutil.Address, height *int32) FutureGetAddressBalanceResult {
+	cmd := btcjson.NewGetAddressBalanceCmd(address.EncodeAddress(), height)
+	return c.SendCmd(cmd)
+}
+
+// GetAddressBalance returns the balance of the passed address along with the
+// total amounts it received and sent as of the block at the passed height in
+// the main chain, or as of the best block when the height is nil.
+//
+// NOTE: This is a btcd extension and requires the balance index to be enabled
+// on the server.
+func (c *Client) GetAddressBalance(address btcutil.Address, height *int32) (*btcjson.GetAddressBalanceResult, error) {
+	return c.GetAddressBalanceAsync(address, height).Receive()
+}
+
+// FutureGetAddressUtxosResult is a future promise to deliver the result of a
+// GetAddressUtxosAsync RPC invocation (or an applicable error).
+type FutureGetAddressUtxosResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the
+// unspent outputs that pay to the address.
+func (r FutureGetAddressUtxosResult) Receive() (*btcjson.GetAddressUtxosResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a getaddressutxos result object.
+	var utxos btcjson.GetAddressUtxosResult
+	err = json.Unmarshal(res, &utxos)
+	if err != nil {
+		return nil, err
+	}
+
+	return &utxos, nil
+}
+
+// GetAddressUtxosAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetAddressUtxos for the blocking version and more details.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) GetAddressUtxosAsync(address btcutil.Address, height *int32) FutureGetAddressUtxosResult {
+	cmd := btcjson.NewGetAddressUtxosCmd(address.EncodeAddress(), height)
+	return c.SendCmd(cmd)
+}
+
+// GetAddressUtxos returns the unspent outputs that pay to the passed address as
+// of the block at the passed height in the main chain, or as of the best block
+// when the height is nil.
+//
+// NOTE: This is a btcd extension and requires the balance index to be enabled
+// on the server.
+func (c *Client) GetAddressUtxos(address btcutil.Address, height *int32) (*btcjson.GetAddressUtxosResult, error) {
+	return c.GetAddressUtxosAsync(address, height).Receive()
+}
@@ -121,6 +121,23 @@ type NotificationHandlers struct {
 	// OnBlockDisconnected: it receives the block's height and header.
 	OnFilteredBlockDisconnected func(height int32, header *wire.BlockHeader)
 
+	// OnIndexBlockConnected is invoked when an external indexer has to
+	// connect a block to its index.  It receives the height of the block,
+	// the block itself, and the outputs it spends in the order they are
+	// spent.  It will only be invoked if a preceding call to
+	// NotifyIndexEvents has been made to start the stream and the function
+	// is non-nil.  Each notification has to be acknowledged with
+	// AckIndexEvents eventually to keep the stream going.
+	OnIndexBlockConnected func(height int32, block *wire.MsgBlock,
+		spentOutputs []btcjson.IndexSpentOutput)
+
+	// OnIndexBlockDisconnected is invoked when an external indexer has to
+	// remove a block from its index because it is no longer in the main
+	// chain.  It receives the same parameters as OnIndexBlockConnected and
+	// will only be invoked under the same conditions.
+	OnIndexBlockDisconnected func(height int32, block *wire.MsgBlock,
+		spentOutputs []btcjson.IndexSpentOutput)
+
 	// OnRecvTx is invoked when a transaction that receives funds to a
 	// registered address is received into the memory pool and also
 	// connected to the longest (best) chain.  It will only be invoked if a
@@ -290,6 +307,44 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 		c.ntfnHandlers.OnFilteredBlockDisconnected(blockHeight,
 			blockHeader)
 
+	// OnIndexBlockConnected
+	case btcjson.IndexBlockConnectedNtfnMethod:
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnIndexBlockConnected == nil {
+			return
+		}
+
+		blockHeight, block, spentOutputs, err :=
+			parseIndexBlockParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid index block connected "+
+				"notification: %v", err)
+			return
+		}
+
+		c.ntfnHandlers.OnIndexBlockConnected(blockHeight, block,
+			spentOutputs)
+
+	// OnIndexBlockDisconnected
+	case btcjson.IndexBlockDisconnectedNtfnMethod:
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnIndexBlockDisconnected == nil {
+			return
+		}
+
+		blockHeight, block, spentOutputs, err :=
+			parseIndexBlockParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid index block disconnected "+
+				"notification: %v", err)
+			return
+		}
+
+		c.ntfnHandlers.OnIndexBlockDisconnected(blockHeight, block,
+			spentOutputs)
+
 	// OnRecvTx
 	case btcjson.RecvTxNtfnMethod:
 		// Ignore the notification if the client is not interested in
@@ -615,6 +670,46 @@ func parseFilteredBlockDisconnectedParams(params []json.RawMessage) (int32,
 	return blockHeight, &blockHeader, nil
 }
 
+// parseIndexBlockParams parses out the parameters included in an
+// indexblockconnected or indexblockdisconnected notification.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func parseIndexBlockParams(params []json.RawMessage) (int32, *wire.MsgBlock,
+	[]btcjson.IndexSpentOutput, error) {
+
+	if len(params) != 3 {
+		return 0, nil, nil, wrongNumParams(len(params))
+	}
+
+	// Unmarshal first parameter as an integer.
+	var blockHeight int32
+	err := json.Unmarshal(params[0], &blockHeight)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	// Unmarshal second parameter as a slice of bytes and deserialize the
+	// block from it.
+	blockBytes, err := parseHexParam(params[1])
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	var block wire.MsgBlock
+	err = block.Deserialize(bytes.NewReader(blockBytes))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	// Unmarshal third parameter as a slice of spent outputs.
+	var spentOutputs []btcjson.IndexSpentOutput
+	err = json.Unmarshal(params[2], &spentOutputs)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	return blockHeight, &block, spentOutputs, nil
+}
+
 func parseHexParam(param json.RawMessage) ([]byte, error) {
 	var s string
 	err := json.Unmarshal(param, &s)
@@ -902,6 +997,115 @@ func (c *Client) NotifyBlocks() error {
 	return c.NotifyBlocksAsync().Receive()
 }
 
+// FutureNotifyIndexEventsResult is a future promise to deliver the result of a
+// NotifyIndexEventsAsync, AckIndexEventsAsync or StopNotifyIndexEventsAsync
+// RPC invocation (or an applicable error).
+type FutureNotifyIndexEventsResult chan *Response
+
+// Receive waits for the Response promised by the future and returns an error
+// if the request was not successful.
+func (r FutureNotifyIndexEventsResult) Receive() error {
+	_, err := ReceiveFuture(r)
+	return err
+}
+
+// NotifyIndexEventsAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See NotifyIndexEvents for the blocking version and more details.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func (c *Client) NotifyIndexEventsAsync(height int32,
+	hash *chainhash.Hash) FutureNotifyIndexEventsResult {
+
+	// Not supported in HTTP POST mode.
+	if c.config.HTTPPostMode {
+		return newFutureError(ErrWebsocketsRequired)
+	}
+
+	// Ignore the notification if the client is not interested in
+	// notifications.
+	if c.ntfnHandlers == nil {
+		return newNilFutureResult()
+	}
+
+	var hashStr *string
+	if hash != nil {
+		hashStr = btcjson.String(hash.String())
+	}
+	cmd := btcjson.NewNotifyIndexEventsCmd(height, hashStr)
+	return c.SendCmd(cmd)
+}
+
+// NotifyIndexEvents starts streaming the blocks connected to and disconnected
+// from the main chain along with the outputs they spend, starting after the
+// block with the passed height and hash the caller has processed last.  A nil
+// hash selects the block at the passed height in the main chain and a height
+// of -1 starts with the genesis block.  The server requires the spend journal
+// index to be enabled.
+//
+// The notifications delivered as a result of this call will be via one of
+// OnIndexBlockConnected or OnIndexBlockDisconnected, in the order they have to
+// be applied.  The stream is not re-established automatically on reconnect
+// since only the caller knows which blocks it has processed.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func (c *Client) NotifyIndexEvents(height int32, hash *chainhash.Hash) error {
+	return c.NotifyIndexEventsAsync(height, hash).Receive()
+}
+
+// AckIndexEventsAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See AckIndexEvents for the blocking version and more details.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func (c *Client) AckIndexEventsAsync(height int32) FutureNotifyIndexEventsResult {
+	// Not supported in HTTP POST mode.
+	if c.config.HTTPPostMode {
+		return newFutureError(ErrWebsocketsRequired)
+	}
+
+	cmd := btcjson.NewAckIndexEventsCmd(height)
+	return c.SendCmd(cmd)
+}
+
+// AckIndexEvents acknowledges the index event notifications up to and
+// including the last one that leaves the caller at the passed height, which
+// allows the server to send further notifications.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func (c *Client) AckIndexEvents(height int32) error {
+	return c.AckIndexEventsAsync(height).Receive()
+}
+
+// StopNotifyIndexEventsAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See StopNotifyIndexEvents for the blocking version and more details.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func (c *Client) StopNotifyIndexEventsAsync() FutureNotifyIndexEventsResult {
+	// Not supported in HTTP POST mode.
+	if c.config.HTTPPostMode {
+		return newFutureError(ErrWebsocketsRequired)
+	}
+
+	cmd := btcjson.NewStopNotifyIndexEventsCmd()
+	return c.SendCmd(cmd)
+}
+
+// StopNotifyIndexEvents stops streaming index events started by
+// NotifyIndexEvents.  No further notifications are sent once it returns.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func (c *Client) StopNotifyIndexEvents() error {
+	return c.StopNotifyIndexEventsAsync().Receive()
+}
+
 // FutureNotifySpentResult is a future promise to deliver the result of a
 // NotifySpentAsync RPC invocation (or an applicable error).
 //
@@ -0,0 +1,413 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/blockchain/indexers"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+)
+
+const (
+	// indexEventsWindow is the maximum number of index events that are sent
+	// to a websocket client before it has to acknowledge them.  This bounds
+	// the number of serialized blocks that are queued for a slow client.
+	indexEventsWindow = 8
+)
+
+// indexEventStream streams the blocks connected to and disconnected from the
+// main chain along with the outputs they spend to a websocket client that
+// maintains an external index.
+//
+// The stream keeps track of the block the client is left with once it has
+// processed all events sent so far and walks from there to the current best
+// chain tip, so events are always sent in the order the client has to apply
+// them regardless of how many reorganizations happened in the mean time.
+type indexEventStream struct {
+	wsc *wsClient
+	idx *indexers.SpendJournalIndex
+
+	// tipHash and tipHeight identify the block the client is left with once
+	// it has processed all events sent so far.  They are only accessed by
+	// the stream handler.
+	tipHash   chainhash.Hash
+	tipHeight int32
+
+	// unacked houses the heights the client is left with after processing
+	// each of the events that have been sent but not acknowledged yet.
+	unackedMtx sync.Mutex
+	unacked    []int32
+
+	wake chan struct{}
+	quit chan struct{}
+	done chan struct{}
+}
+
+// signal wakes the stream handler so it reevaluates whether there are events
+// to send.  It never blocks.
+func (s *indexEventStream) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ack removes all events up to and including the last unacknowledged one that
+// leaves the client at the passed height.  It returns false when there is no
+// such event.
+func (s *indexEventStream) ack(height int32) bool {
+	s.unackedMtx.Lock()
+	defer s.unackedMtx.Unlock()
+
+	for i := len(s.unacked) - 1; i >= 0; i-- {
+		if s.unacked[i] == height {
+			s.unacked = s.unacked[i+1:]
+			s.signal()
+			return true
+		}
+	}
+	return false
+}
+
+// windowFull returns whether or not the maximum number of unacknowledged
+// events has been sent.
+func (s *indexEventStream) windowFull() bool {
+	s.unackedMtx.Lock()
+	defer s.unackedMtx.Unlock()
+
+	return len(s.unacked) >= indexEventsWindow
+}
+
+// indexSpentOutputs converts the passed spent outputs to the form used in
+// index event notifications.
+func indexSpentOutputs(stxos []blockchain.SpentTxOut) []btcjson.IndexSpentOutput {
+	spentOutputs := make([]btcjson.IndexSpentOutput, 0, len(stxos))
+	for i := range stxos {
+		stxo := &stxos[i]
+		spentOutputs = append(spentOutputs, btcjson.IndexSpentOutput{
+			Amount:     stxo.Amount,
+			PkScript:   hex.EncodeToString(stxo.PkScript),
+			Height:     stxo.Height,
+			IsCoinBase: stxo.IsCoinBase,
+		})
+	}
+	return spentOutputs
+}
+
+// disconnectTip returns the notification that disconnects the current tip of
+// the client, which is no longer part of the main chain, and moves the tip to
+// its parent.  A nil notification is returned when the tip has been connected
+// to the main chain again in the mean time.
+func (s *indexEventStream) disconnectTip() (interface{}, error) {
+	height, stxos, err := s.idx.DisconnectedBlock(&s.tipHash)
+	if err != nil {
+		return nil, err
+	}
+	if height == -1 {
+		if s.wsc.server.cfg.Chain.MainChainHasBlock(&s.tipHash) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("the outputs spent by block %v are not "+
+			"available", s.tipHash)
+	}
+	if height != s.tipHeight {
+		return nil, fmt.Errorf("block %v is at height %d instead of %d",
+			s.tipHash, height, s.tipHeight)
+	}
+
+	// The block has to be loaded from the database directly since it is no
+	// longer in the main chain.
+	var blockBytes []byte
+	err = s.wsc.server.cfg.DB.View(func(dbTx database.Tx) error {
+		var err error
+		blockBytes, err = dbTx.FetchBlock(&s.tipHash)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	block, err := btcutil.NewBlockFromBytes(blockBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	s.tipHash = block.MsgBlock().Header.PrevBlock
+	s.tipHeight--
+	return btcjson.NewIndexBlockDisconnectedNtfn(height,
+		hex.EncodeToString(blockBytes), indexSpentOutputs(stxos)), nil
+}
+
+// nextEvent returns the next notification to send to the client and updates
+// the tip accordingly.  A nil notification is returned when the client is
+// caught up with the best chain.
+func (s *indexEventStream) nextEvent() (interface{}, error) {
+	chain := s.wsc.server.cfg.Chain
+	for {
+		// Blocks the client processed that are no longer in the main
+		// chain have to be disconnected first.
+		if s.tipHeight >= 0 && !chain.MainChainHasBlock(&s.tipHash) {
+			ntfn, err := s.disconnectTip()
+			if ntfn == nil && err == nil {
+				continue
+			}
+			return ntfn, err
+		}
+		if s.tipHeight >= chain.BestSnapshot().Height {
+			return nil, nil
+		}
+
+		// The main chain may be reorganized at any point, so start
+		// over whenever the next block turns out not to extend the tip
+		// anymore.
+		block, err := chain.BlockByHeight(s.tipHeight + 1)
+		if err != nil {
+			if s.tipHeight >= chain.BestSnapshot().Height ||
+				(s.tipHeight >= 0 && !chain.MainChainHasBlock(&s.tipHash)) {
+
+				continue
+			}
+			return nil, err
+		}
+		if block.MsgBlock().Header.PrevBlock != s.tipHash {
+			continue
+		}
+		stxos, err := chain.FetchSpendJournal(block)
+		if err != nil {
+			if !chain.MainChainHasBlock(block.Hash()) {
+				continue
+			}
+			return nil, err
+		}
+		blockBytes, err := block.Bytes()
+		if err != nil {
+			return nil, err
+		}
+
+		s.tipHash = *block.Hash()
+		s.tipHeight = block.Height()
+		return btcjson.NewIndexBlockConnectedNtfn(block.Height(),
+			hex.EncodeToString(blockBytes), indexSpentOutputs(stxos)), nil
+	}
+}
+
+// handler sends the index events to the client as long as the window of
+// unacknowledged events allows and waits to be woken up otherwise.  The client
+// is disconnected when an event can't be created since it would miss blocks
+// otherwise.  It must be run as a goroutine.
+func (s *indexEventStream) handler() {
+	defer close(s.done)
+
+	for {
+		if !s.windowFull() {
+			ntfn, err := s.nextEvent()
+			if err != nil {
+				rpcsLog.Errorf("Unable to stream index events to "+
+					"websocket client %s: %v", s.wsc.addr, err)
+				s.wsc.Disconnect()
+				return
+			}
+			if ntfn != nil {
+				marshalled, err := btcjson.MarshalCmd(
+					btcjson.RpcVersion1, nil, ntfn)
+				if err != nil {
+					rpcsLog.Errorf("Failed to marshal index "+
+						"event notification: %v", err)
+					s.wsc.Disconnect()
+					return
+				}
+
+				s.unackedMtx.Lock()
+				s.unacked = append(s.unacked, s.tipHeight)
+				s.unackedMtx.Unlock()
+
+				select {
+				case s.wsc.ntfnChan <- marshalled:
+				case <-s.quit:
+					return
+				case <-s.wsc.quit:
+					return
+				}
+				continue
+			}
+		}
+
+		select {
+		case <-s.wake:
+		case <-s.quit:
+			return
+		case <-s.wsc.quit:
+			return
+		}
+	}
+}
+
+// stop stops the stream and waits for its handler to finish so no more events
+// are sent afterwards.
+func (s *indexEventStream) stop() {
+	close(s.quit)
+	<-s.done
+}
+
+// wakeIndexEventStreams signals the index event streams of the passed clients
+// that the main chain has changed.
+func wakeIndexEventStreams(clients map[chan struct{}]*wsClient) {
+	for _, wsc := range clients {
+		wsc.Lock()
+		stream := wsc.indexEvents
+		wsc.Unlock()
+
+		if stream != nil {
+			stream.signal()
+		}
+	}
+}
+
+// handleNotifyIndexEvents implements the notifyindexevents command extension
+// for websocket connections.
+func handleNotifyIndexEvents(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	cmd, ok := icmd.(*btcjson.NotifyIndexEventsCmd)
+	if !ok {
+		return nil, btcjson.ErrRPCInternal
+	}
+
+	// Respond with an error if the spend journal index is not enabled
+	// since disconnected blocks could not be streamed otherwise.
+	idx := wsc.server.cfg.SpendJournalIndex
+	if idx == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Spend journal index must be enabled (--spendjournalindex)",
+		}
+	}
+	if err := wsc.server.checkIndexSynced(idx); err != nil {
+		return nil, err
+	}
+
+	// Determine the block the client has processed last.  Blocks that are
+	// no longer in the main chain are only accepted when their spent
+	// outputs have been retained, so the stream is able to disconnect
+	// them.
+	chain := wsc.server.cfg.Chain
+	var tipHash chainhash.Hash
+	switch {
+	case cmd.Hash == nil && cmd.Height == -1:
+
+	case cmd.Hash == nil:
+		hash, err := chain.BlockHashByHeight(cmd.Height)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCOutOfRange,
+				Message: "Block height out of range",
+			}
+		}
+		tipHash = *hash
+
+	default:
+		hash, err := chainhash.NewHashFromStr(*cmd.Hash)
+		if err != nil {
+			return nil, rpcDecodeHexError(*cmd.Hash)
+		}
+		tipHash = *hash
+
+		var height int32
+		if chain.MainChainHasBlock(hash) {
+			height, err = chain.BlockHeightByHash(hash)
+		} else {
+			height, _, err = idx.DisconnectedBlock(hash)
+		}
+		if err != nil {
+			context := "Failed to look up block"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if height == -1 {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCBlockNotFound,
+				Message: fmt.Sprintf("Block %v is not in the main "+
+					"chain and the outputs it spends are not "+
+					"available", hash),
+			}
+		}
+		if height != cmd.Height {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Block %v is at height %d",
+					hash, height),
+			}
+		}
+	}
+
+	stream := &indexEventStream{
+		wsc:       wsc,
+		idx:       idx,
+		tipHash:   tipHash,
+		tipHeight: cmd.Height,
+		wake:      make(chan struct{}, 1),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	wsc.Lock()
+	if wsc.indexEvents != nil {
+		wsc.Unlock()
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Index events are already being streamed",
+		}
+	}
+	wsc.indexEvents = stream
+	wsc.Unlock()
+
+	go stream.handler()
+	return nil, nil
+}
+
+// handleAckIndexEvents implements the ackindexevents command extension for
+// websocket connections.
+func handleAckIndexEvents(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	cmd, ok := icmd.(*btcjson.AckIndexEventsCmd)
+	if !ok {
+		return nil, btcjson.ErrRPCInternal
+	}
+
+	wsc.Lock()
+	stream := wsc.indexEvents
+	wsc.Unlock()
+	if stream == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Index events are not being streamed",
+		}
+	}
+
+	if !stream.ack(cmd.Height) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("No unacknowledged index event "+
+				"leaves the chain at height %d", cmd.Height),
+		}
+	}
+	return nil, nil
+}
+
+// handleStopNotifyIndexEvents implements the stopnotifyindexevents command
+// extension for websocket connections.
+func handleStopNotifyIndexEvents(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.Lock()
+	stream := wsc.indexEvents
+	wsc.indexEvents = nil
+	wsc.Unlock()
+
+	if stream != nil {
+		stream.stop()
+	}
+	return nil, nil
+}
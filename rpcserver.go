@@ -21,6 +21,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -32,6 +33,7 @@ import (
 	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/bloom"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
@@ -75,6 +77,15 @@ const (
 
 	// maxProtocolVersion is the max protocol version the server supports.
 	maxProtocolVersion = 70002
+
+	// maxTestMempoolAcceptTxns is the maximum number of transactions that
+	// may be tested at once by the testmempoolaccept RPC.
+	maxTestMempoolAcceptTxns = 25
+
+	// defaultTestMempoolAcceptMaxFeeRate is the fee rate in BTC/kvB above
+	// which transactions are rejected by the testmempoolaccept RPC when no
+	// maximum fee rate is provided.
+	defaultTestMempoolAcceptMaxFeeRate = 0.10
 )
 
 var (
@@ -135,26 +146,38 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"debuglevel":             handleDebugLevel,
 	"decoderawtransaction":   handleDecodeRawTransaction,
 	"decodescript":           handleDecodeScript,
+	"dumptxoutset":           handleDumpTxOutSet,
 	"estimatefee":            handleEstimateFee,
+	"estimatesmartfee":       handleEstimateSmartFee,
 	"generate":               handleGenerate,
 	"getaddednodeinfo":       handleGetAddedNodeInfo,
+	"getaddressbalance":      handleGetAddressBalance,
+	"getaddressutxos":        handleGetAddressUtxos,
 	"getbestblock":           handleGetBestBlock,
 	"getbestblockhash":       handleGetBestBlockHash,
 	"getblock":               handleGetBlock,
 	"getblockchaininfo":      handleGetBlockChainInfo,
 	"getblockcount":          handleGetBlockCount,
+	"getblockfilter":         handleGetBlockFilter,
 	"getblockhash":           handleGetBlockHash,
 	"getblockheader":         handleGetBlockHeader,
+	"getblockstats":          handleGetBlockStats,
 	"getblocktemplate":       handleGetBlockTemplate,
 	"getcfilter":             handleGetCFilter,
 	"getcfilterheader":       handleGetCFilterHeader,
+	"getchaintips":           handleGetChainTips,
+	"getchaintxstats":        handleGetChainTxStats,
 	"getconnectioncount":     handleGetConnectionCount,
 	"getcurrentnet":          handleGetCurrentNet,
 	"getdifficulty":          handleGetDifficulty,
 	"getgenerate":            handleGetGenerate,
 	"gethashespersec":        handleGetHashesPerSec,
+	"getindexinfo":           handleGetIndexInfo,
 	"getheaders":             handleGetHeaders,
 	"getinfo":                handleGetInfo,
+	"getmempoolancestors":    handleGetMempoolAncestors,
+	"getmempooldescendants":  handleGetMempoolDescendants,
+	"getmempoolentry":        handleGetMempoolEntry,
 	"getmempoolinfo":         handleGetMempoolInfo,
 	"getmininginfo":          handleGetMiningInfo,
 	"getnettotals":           handleGetNetTotals,
@@ -164,19 +187,30 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"getrawmempool":          handleGetRawMempool,
 	"getrawtransaction":      handleGetRawTransaction,
 	"gettxout":               handleGetTxOut,
+	"gettxoutproof":          handleGetTxOutProof,
+	"gettxoutsetinfo":        handleGetTxOutSetInfo,
+	"gettxspendingprevout":   handleGetTxSpendingPrevOut,
 	"help":                   handleHelp,
+	"invalidateblock":        handleInvalidateBlock,
+	"loadtxoutset":           handleLoadTxOutSet,
 	"node":                   handleNode,
 	"ping":                   handlePing,
+	"preciousblock":          handlePreciousBlock,
+	"reconsiderblock":        handleReconsiderBlock,
+	"savemempool":            handleSaveMempool,
 	"searchrawtransactions":  handleSearchRawTransactions,
 	"sendrawtransaction":     handleSendRawTransaction,
 	"setgenerate":            handleSetGenerate,
 	"signmessagewithprivkey": handleSignMessageWithPrivKey,
 	"stop":                   handleStop,
 	"submitblock":            handleSubmitBlock,
+	"submitpackage":          handleSubmitPackage,
+	"testmempoolaccept":      handleTestMempoolAccept,
 	"uptime":                 handleUptime,
 	"validateaddress":        handleValidateAddress,
 	"verifychain":            handleVerifyChain,
 	"verifymessage":          handleVerifyMessage,
+	"verifytxoutproof":       handleVerifyTxOutProof,
 	"version":                handleVersion,
 }
 
@@ -200,7 +234,6 @@ var rpcAskWallet = map[string]struct{}{
 	"getreceivedbyaccount":   {},
 	"getreceivedbyaddress":   {},
 	"gettransaction":         {},
-	"gettxoutsetinfo":        {},
 	"getunconfirmedbalance":  {},
 	"getwalletinfo":          {},
 	"importprivkey":          {},
@@ -231,26 +264,24 @@ var rpcAskWallet = map[string]struct{}{
 // Commands that are currently unimplemented, but should ultimately be.
 var rpcUnimplemented = map[string]struct{}{
 	"estimatepriority": {},
-	"getchaintips":     {},
-	"getmempoolentry":  {},
 	"getnetworkinfo":   {},
 	"getwork":          {},
-	"invalidateblock":  {},
-	"preciousblock":    {},
-	"reconsiderblock":  {},
 }
 
 // Commands that are available to a limited user
 var rpcLimited = map[string]struct{}{
 	// Websockets commands
+	"ackindexevents":        {},
 	"loadtxfilter":          {},
 	"notifyblocks":          {},
+	"notifyindexevents":     {},
 	"notifynewtransactions": {},
 	"notifyreceived":        {},
 	"notifyspent":           {},
 	"rescan":                {},
 	"rescanblocks":          {},
 	"session":               {},
+	"stopnotifyindexevents": {},
 
 	// Websockets AND HTTP/S commands
 	"help": {},
@@ -260,29 +291,45 @@ var rpcLimited = map[string]struct{}{
 	"decoderawtransaction":  {},
 	"decodescript":          {},
 	"estimatefee":           {},
+	"estimatesmartfee":      {},
+	"getaddressbalance":     {},
+	"getaddressutxos":       {},
 	"getbestblock":          {},
 	"getbestblockhash":      {},
 	"getblock":              {},
 	"getblockcount":         {},
+	"getblockfilter":        {},
 	"getblockhash":          {},
 	"getblockheader":        {},
+	"getblockstats":         {},
 	"getcfilter":            {},
 	"getcfilterheader":      {},
+	"getchaintips":          {},
+	"getchaintxstats":       {},
 	"getcurrentnet":         {},
 	"getdifficulty":         {},
 	"getheaders":            {},
+	"getindexinfo":          {},
 	"getinfo":               {},
+	"getmempoolancestors":   {},
+	"getmempooldescendants": {},
+	"getmempoolentry":       {},
 	"getnettotals":          {},
 	"getnetworkhashps":      {},
 	"getrawmempool":         {},
 	"getrawtransaction":     {},
 	"gettxout":              {},
+	"gettxoutproof":         {},
+	"gettxoutsetinfo":       {},
+	"gettxspendingprevout":  {},
 	"searchrawtransactions": {},
 	"sendrawtransaction":    {},
 	"submitblock":           {},
+	"testmempoolaccept":     {},
 	"uptime":                {},
 	"validateaddress":       {},
 	"verifymessage":         {},
+	"verifytxoutproof":      {},
 	"version":               {},
 }
 
@@ -320,6 +367,40 @@ func rpcDecodeHexError(gotHex string) *btcjson.RPCError {
 			gotHex))
 }
 
+// decodeRawTxns decodes the passed serialized, hex-encoded transactions and
+// returns an appropriate RPC error when any of them fails to decode.
+func decodeRawTxns(rawTxns []string) ([]*btcutil.Tx, error) {
+	txns := make([]*btcutil.Tx, 0, len(rawTxns))
+	for _, hexStr := range rawTxns {
+		if len(hexStr)%2 != 0 {
+			hexStr = "0" + hexStr
+		}
+		serializedTx, err := hex.DecodeString(hexStr)
+		if err != nil {
+			return nil, rpcDecodeHexError(hexStr)
+		}
+		var msgTx wire.MsgTx
+		err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCDeserialization,
+				Message: "TX decode failed: " + err.Error(),
+			}
+		}
+		txns = append(txns, btcutil.NewTx(&msgTx))
+	}
+
+	return txns, nil
+}
+
+// rpcNotInMempoolError is a convenience function for returning a nicely
+// formatted RPC error which indicates the provided transaction is not in the
+// memory pool.
+func rpcNotInMempoolError(txHash *chainhash.Hash) *btcjson.RPCError {
+	return btcjson.NewRPCError(btcjson.ErrRPCNoTxInfo,
+		fmt.Sprintf("Transaction %v not in mempool", txHash))
+}
+
 // rpcNoTxInfoError is a convenience function for returning a nicely formatted
 // RPC error which indicates there is no information available for the provided
 // transaction hash.
@@ -329,6 +410,21 @@ func rpcNoTxInfoError(txHash *chainhash.Hash) *btcjson.RPCError {
 			txHash))
 }
 
+// checkIndexSynced returns an RPC error which indicates the passed index is
+// still catching up with the main chain when it is, or nil otherwise.
+func (s *rpcServer) checkIndexSynced(indexer indexers.Indexer) *btcjson.RPCError {
+	if s.cfg.IndexManager == nil {
+		return nil
+	}
+	synced, height := s.cfg.IndexManager.SyncState(indexer)
+	if synced {
+		return nil
+	}
+	return btcjson.NewRPCError(btcjson.ErrRPCIndexSyncing,
+		fmt.Sprintf("The %s is still syncing, at height %d",
+			indexer.Name(), height))
+}
+
 // gbtWorkState houses state that is used in between multiple RPC invocations to
 // getblocktemplate.
 type gbtWorkState struct {
@@ -779,6 +875,54 @@ func createTxRawResult(chainParams *chaincfg.Params, mtx *wire.MsgTx,
 	return txReply, nil
 }
 
+// addTxRawResultPrevOuts attaches the passed outputs spent by a transaction to
+// the inputs of its raw transaction JSON object along with the fee paid by the
+// transaction.  The spent outputs must be in the same order as the inputs of
+// the transaction.
+func addTxRawResultPrevOuts(txReply *btcjson.TxRawResult, mtx *wire.MsgTx,
+	stxos []blockchain.SpentTxOut, chainParams *chaincfg.Params) {
+
+	var totalIn int64
+	for i := range stxos {
+		stxo := &stxos[i]
+		totalIn += stxo.Amount
+
+		// The disassembled string will contain [error] inline if the
+		// script doesn't fully parse, so ignore the error here.
+		disbuf, _ := txscript.DisasmString(stxo.PkScript)
+
+		// Ignore the error here since an error means the script
+		// couldn't parse and there is no additional information about
+		// it anyways.
+		scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(
+			stxo.PkScript, chainParams)
+		encodedAddrs := make([]string, len(addrs))
+		for j, addr := range addrs {
+			encodedAddrs[j] = addr.EncodeAddress()
+		}
+
+		txReply.Vin[i].PrevOut = &btcjson.PrevOutVerbose{
+			Generated: stxo.IsCoinBase,
+			Height:    stxo.Height,
+			Value:     btcutil.Amount(stxo.Amount).ToBTC(),
+			ScriptPubKey: btcjson.ScriptPubKeyResult{
+				Asm:       disbuf,
+				Hex:       hex.EncodeToString(stxo.PkScript),
+				ReqSigs:   int32(reqSigs),
+				Type:      scriptClass.String(),
+				Addresses: encodedAddrs,
+			},
+		}
+	}
+
+	var totalOut int64
+	for _, txOut := range mtx.TxOut {
+		totalOut += txOut.Value
+	}
+	fee := btcutil.Amount(totalIn - totalOut).ToBTC()
+	txReply.Fee = &fee
+}
+
 // handleDecodeRawTransaction handles decoderawtransaction commands.
 func handleDecodeRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.DecodeRawTransactionCmd)
@@ -860,6 +1004,49 @@ func handleDecodeScript(s *rpcServer, cmd interface{}, closeChan <-chan struct{}
 	return reply, nil
 }
 
+// handleDumpTxOutSet handles dumptxoutset commands.
+func handleDumpTxOutSet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DumpTxOutSetCmd)
+
+	// Refuse to overwrite existing files and write the snapshot to a
+	// temporary file first so an incomplete snapshot is never left at the
+	// requested path.
+	path := cleanAndExpandPath(c.Path)
+	if _, err := os.Stat(path); err == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: path + " already exists",
+		}
+	}
+	tmpPath := path + ".incomplete"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, internalRPCError("Unable to create utxo snapshot "+
+			"file: "+err.Error(), "")
+	}
+	info, err := s.cfg.Chain.DumpUtxoSnapshot(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, internalRPCError("Unable to dump utxo snapshot: "+
+			err.Error(), "")
+	}
+
+	return &btcjson.DumpTxOutSetResult{
+		CoinsWritten: info.NumCoins,
+		BaseHash:     info.BaseHash.String(),
+		BaseHeight:   info.BaseHeight,
+		Path:         path,
+		TxOutSetHash: info.UtxoSetHash.String(),
+		NChainTx:     info.ChainTxCount,
+	}, nil
+}
+
 // handleEstimateFee handles estimatefee commands.
 func handleEstimateFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.EstimateFeeCmd)
@@ -882,6 +1069,62 @@ func handleEstimateFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return float64(feeRate), nil
 }
 
+// handleEstimateSmartFee handles estimatesmartfee commands.
+func handleEstimateSmartFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.EstimateSmartFeeCmd)
+
+	if s.cfg.FeeEstimator == nil {
+		return nil, errors.New("Fee estimation disabled")
+	}
+
+	if c.ConfTarget < 1 || c.ConfTarget > mempool.MaxFeeEstimateTarget {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid conf_target, must be "+
+				"between 1 and %d", mempool.MaxFeeEstimateTarget),
+		}
+	}
+
+	conservative := true
+	if c.EstimateMode != nil {
+		switch *c.EstimateMode {
+		case btcjson.EstimateModeUnset, btcjson.EstimateModeConservative:
+		case btcjson.EstimateModeEconomical:
+			conservative = false
+		default:
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid estimate_mode parameter",
+			}
+		}
+	}
+
+	feeRate, blocks, err := s.cfg.FeeEstimator.EstimateSmartFee(
+		uint32(c.ConfTarget), conservative)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &btcjson.EstimateSmartFeeResult{
+		Blocks: int64(blocks),
+	}
+	if feeRate < 0 {
+		result.Errors = []string{"Insufficient data or no feerate found"}
+		return result, nil
+	}
+
+	// Transactions paying less than the minimum fee rate of the mempool
+	// would not be accepted regardless of when they would be confirmed.
+	estimate := float64(feeRate)
+	minFeeRate := btcutil.Amount(s.cfg.TxMemPool.MinFeeRate()).ToBTC()
+	if estimate < minFeeRate {
+		estimate = minFeeRate
+	}
+	result.FeeRate = &estimate
+
+	return result, nil
+}
+
 // handleGenerate handles generate commands.
 func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if there are no addresses to pay the
@@ -1026,6 +1269,155 @@ func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return results, nil
 }
 
+// addressQuery houses the details of a query of the balance index on behalf of
+// the getaddressbalance and getaddressutxos commands.
+type addressQuery struct {
+	pkScript   []byte
+	scriptHash chainhash.Hash
+	height     int32
+	blockHash  chainhash.Hash
+}
+
+// newAddressQuery returns a query of the balance index for the public key
+// script of the passed address as of the block at the passed height in the main
+// chain, which defaults to the best block when it is nil.
+func newAddressQuery(s *rpcServer, address string, height *int32) (*addressQuery, error) {
+	// Respond with an error if the balance index is not enabled.
+	if s.cfg.BalanceIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Balance index must be enabled (--balanceindex)",
+		}
+	}
+	if err := s.checkIndexSynced(s.cfg.BalanceIndex); err != nil {
+		return nil, err
+	}
+
+	// Attempt to decode the supplied address and derive the public key
+	// script it pays to.
+	addr, err := btcutil.DecodeAddress(address, s.cfg.ChainParams)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
+		}
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
+		}
+	}
+
+	best := s.cfg.Chain.BestSnapshot()
+	query := &addressQuery{
+		pkScript:   pkScript,
+		scriptHash: indexers.ScriptHash(pkScript),
+		height:     best.Height,
+		blockHash:  best.Hash,
+	}
+	if height != nil && *height != best.Height {
+		if *height < 0 || *height > best.Height {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCOutOfRange,
+				Message: "Block number out of range",
+			}
+		}
+		hash, err := s.cfg.Chain.BlockHashByHeight(*height)
+		if err != nil {
+			context := "Failed to fetch block hash"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		query.height = *height
+		query.blockHash = *hash
+	}
+
+	return query, nil
+}
+
+// checkMainChain returns an error when the block the query refers to is no
+// longer in the main chain, in which case the results of the query may belong
+// to a block that replaced it.
+func (q *addressQuery) checkMainChain(s *rpcServer) error {
+	hash, err := s.cfg.Chain.BlockHashByHeight(q.height)
+	if err != nil || *hash != q.blockHash {
+		return &btcjson.RPCError{
+			Code: btcjson.ErrRPCMisc,
+			Message: fmt.Sprintf("Block %v was disconnected from the "+
+				"main chain during the query", q.blockHash),
+		}
+	}
+	return nil
+}
+
+// handleGetAddressBalance implements the getaddressbalance command.
+func handleGetAddressBalance(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddressBalanceCmd)
+	query, err := newAddressQuery(s, c.Address, c.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := s.cfg.BalanceIndex.Balance(&query.scriptHash,
+		query.height)
+	if err != nil {
+		context := "Failed to fetch address balance"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if err := query.checkMainChain(s); err != nil {
+		return nil, err
+	}
+
+	return &btcjson.GetAddressBalanceResult{
+		Address:   c.Address,
+		Height:    query.height,
+		BlockHash: query.blockHash.String(),
+		Balance:   btcutil.Amount(balance.Balance).ToBTC(),
+		Received:  btcutil.Amount(balance.Received).ToBTC(),
+		Sent:      btcutil.Amount(balance.Sent).ToBTC(),
+		UtxoCount: balance.NumUnspent,
+	}, nil
+}
+
+// handleGetAddressUtxos implements the getaddressutxos command.
+func handleGetAddressUtxos(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddressUtxosCmd)
+	query, err := newAddressQuery(s, c.Address, c.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	unspent, err := s.cfg.BalanceIndex.Unspent(&query.scriptHash,
+		query.height)
+	if err != nil {
+		context := "Failed to fetch address unspent outputs"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if err := query.checkMainChain(s); err != nil {
+		return nil, err
+	}
+
+	scriptPubKey := hex.EncodeToString(query.pkScript)
+	utxos := make([]btcjson.GetAddressUtxosResultUtxo, 0, len(unspent))
+	for _, output := range unspent {
+		utxos = append(utxos, btcjson.GetAddressUtxosResultUtxo{
+			Txid:         output.OutPoint.Hash.String(),
+			Vout:         output.OutPoint.Index,
+			Amount:       btcutil.Amount(output.Amount).ToBTC(),
+			Height:       output.Height,
+			ScriptPubKey: scriptPubKey,
+		})
+	}
+
+	return &btcjson.GetAddressUtxosResult{
+		Address:   c.Address,
+		Height:    query.height,
+		BlockHash: query.blockHash.String(),
+		Utxos:     utxos,
+	}, nil
+}
+
 // handleGetBestBlock implements the getbestblock command.
 func handleGetBestBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// All other "get block" commands give either the height, the
@@ -1081,6 +1473,12 @@ func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 		return err
 	})
 	if err != nil {
+		if s.cfg.Chain.BlockPruned(hash) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCMisc,
+				Message: "Block not available (pruned data)",
+			}
+		}
 		return nil, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCBlockNotFound,
 			Message: "Block not found",
@@ -1150,6 +1548,33 @@ func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 		blockReply.Tx = txNames
 	} else {
 		txns := blk.Transactions()
+
+		// Load the outputs spent by the block from the spend journal
+		// when the verbosity also calls for the prevouts of the
+		// inputs.
+		var stxos []blockchain.SpentTxOut
+		withPrevOuts := *c.Verbosity >= 3
+		if withPrevOuts {
+			stxos, err = s.cfg.Chain.FetchSpendJournal(blk)
+			if err != nil {
+				context := "Failed to load spent outputs"
+				return nil, internalRPCError(err.Error(), context)
+			}
+
+			// The spend journal entry no longer matches the block
+			// when it has been disconnected in the meantime.
+			var numInputs int
+			for _, tx := range txns[1:] {
+				numInputs += len(tx.MsgTx().TxIn)
+			}
+			if numInputs != len(stxos) {
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCMisc,
+					Message: "Spent outputs of the block are unavailable",
+				}
+			}
+		}
+
 		rawTxns := make([]btcjson.TxRawResult, len(txns))
 		for i, tx := range txns {
 			rawTxn, err := createTxRawResult(params, tx.MsgTx(),
@@ -1158,6 +1583,15 @@ func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 			if err != nil {
 				return nil, err
 			}
+
+			// Attach the spent outputs and the fee to all but the
+			// coinbase transaction, which doesn't spend any.
+			if withPrevOuts && i > 0 {
+				numIn := len(tx.MsgTx().TxIn)
+				addTxRawResultPrevOuts(rawTxn, tx.MsgTx(),
+					stxos[:numIn], params)
+				stxos = stxos[numIn:]
+			}
 			rawTxns[i] = *rawTxn
 		}
 		blockReply.RawTx = rawTxns
@@ -1192,20 +1626,31 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan str
 	params := s.cfg.ChainParams
 	chain := s.cfg.Chain
 	chainSnapshot := chain.BestSnapshot()
+	pruneHeight, pruned := chain.PruneHeight()
+	_, bestHeaderHeight := chain.BestHeader()
 
 	chainInfo := &btcjson.GetBlockChainInfoResult{
 		Chain:         params.Name,
 		Blocks:        chainSnapshot.Height,
-		Headers:       chainSnapshot.Height,
+		Headers:       bestHeaderHeight,
 		BestBlockHash: chainSnapshot.Hash.String(),
 		Difficulty:    getDifficultyRatio(chainSnapshot.Bits, params),
 		MedianTime:    chainSnapshot.MedianTime.Unix(),
-		Pruned:        false,
+		Pruned:        pruned,
 		SoftForks: &btcjson.SoftForks{
 			Bip9SoftForks: make(map[string]*btcjson.Bip9SoftForkDescription),
 		},
 	}
 
+	if pruned {
+		chainInfo.PruneHeight = pruneHeight
+	}
+
+	if hash, active := chain.AssumeValid(); hash != nil {
+		chainInfo.AssumeValid = hash.String()
+		chainInfo.AssumeValidActive = active
+	}
+
 	// Next, populate the response with information describing the current
 	// status of soft-forks deployed via the super-majority block
 	// signalling mechanism.
@@ -1317,6 +1762,69 @@ func handleGetBlockCount(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 	return int64(best.Height), nil
 }
 
+// handleGetBlockFilter implements the getblockfilter command.
+func handleGetBlockFilter(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if s.cfg.CfIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCNoCFIndex,
+			Message: "The CF index must be enabled for this command",
+		}
+	}
+	if err := s.checkIndexSynced(s.cfg.CfIndex); err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.GetBlockFilterCmd)
+	filterType := btcjson.FilterTypeBasic
+	if c.FilterType != nil {
+		filterType = *c.FilterType
+	}
+	if filterType != btcjson.FilterTypeBasic {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Unknown filtertype",
+		}
+	}
+
+	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+	if _, err := s.cfg.Chain.HeaderByHash(hash); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+
+	// Filters are only available for blocks in the main chain.
+	filterBytes, err := s.cfg.CfIndex.FilterByBlockHash(hash,
+		wire.GCSFilterRegular)
+	if err != nil {
+		context := "Failed to fetch committed filter"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	headerBytes, err := s.cfg.CfIndex.FilterHeaderByBlockHash(hash,
+		wire.GCSFilterRegular)
+	if err != nil {
+		context := "Failed to fetch committed filter header"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if len(filterBytes) == 0 || len(headerBytes) != chainhash.HashSize {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Filter not found",
+		}
+	}
+
+	var header chainhash.Hash
+	copy(header[:], headerBytes)
+	return &btcjson.GetBlockFilterResult{
+		Filter: hex.EncodeToString(filterBytes),
+		Header: header.String(),
+	}, nil
+}
+
 // handleGetBlockHash implements the getblockhash command.
 func handleGetBlockHash(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetBlockHashCmd)
@@ -1368,35 +1876,380 @@ func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct
 		context := "Failed to obtain block height"
 		return nil, internalRPCError(err.Error(), context)
 	}
-	best := s.cfg.Chain.BestSnapshot()
+	best := s.cfg.Chain.BestSnapshot()
+
+	// Get next block hash unless there are none.
+	var nextHashString string
+	if blockHeight < best.Height {
+		nextHash, err := s.cfg.Chain.BlockHashByHeight(blockHeight + 1)
+		if err != nil {
+			context := "No next block"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		nextHashString = nextHash.String()
+	}
+
+	params := s.cfg.ChainParams
+	blockHeaderReply := btcjson.GetBlockHeaderVerboseResult{
+		Hash:          c.Hash,
+		Confirmations: int64(1 + best.Height - blockHeight),
+		Height:        blockHeight,
+		Version:       blockHeader.Version,
+		VersionHex:    fmt.Sprintf("%08x", blockHeader.Version),
+		MerkleRoot:    blockHeader.MerkleRoot.String(),
+		NextHash:      nextHashString,
+		PreviousHash:  blockHeader.PrevBlock.String(),
+		Nonce:         uint64(blockHeader.Nonce),
+		Time:          blockHeader.Timestamp.Unix(),
+		Bits:          strconv.FormatInt(int64(blockHeader.Bits), 16),
+		Difficulty:    getDifficultyRatio(blockHeader.Bits, params),
+	}
+	return blockHeaderReply, nil
+}
+
+// blockStatsInputStats are the getblockstats statistics which require the
+// outputs spent by the block.
+var blockStatsInputStats = map[string]struct{}{
+	"avgfee":               {},
+	"avgfeerate":           {},
+	"feerate_percentiles":  {},
+	"maxfee":               {},
+	"maxfeerate":           {},
+	"medianfee":            {},
+	"minfee":               {},
+	"minfeerate":           {},
+	"totalfee":             {},
+	"utxo_size_inc":        {},
+	"utxo_size_inc_actual": {},
+}
+
+// blockStatsPerUtxoOverhead is the size Bitcoin Core adds to the serialized
+// size of an output to account for the outpoint, height and coinbase flag when
+// calculating the change in size of the utxo set.
+const blockStatsPerUtxoOverhead = chainhash.HashSize + 4 + 4 + 1
+
+// calcTruncatedMedian returns the median of the passed values, which are sorted
+// in place, truncated to an integer or zero when there are no values.
+func calcTruncatedMedian(values []int64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+// feeRateWeight pairs the fee rate of a transaction with its weight.
+type feeRateWeight struct {
+	feeRate int64
+	weight  int64
+}
+
+// calcFeeRatePercentiles returns the fee rates at the 10th, 25th, 50th, 75th
+// and 90th percentile of the total weight of the passed transactions, which are
+// sorted in place.
+func calcFeeRatePercentiles(feeRates []feeRateWeight, totalWeight int64) []int64 {
+	percentiles := make([]int64, 5)
+	if len(feeRates) == 0 {
+		return percentiles
+	}
+
+	sort.Slice(feeRates, func(i, j int) bool {
+		if feeRates[i].feeRate != feeRates[j].feeRate {
+			return feeRates[i].feeRate < feeRates[j].feeRate
+		}
+		return feeRates[i].weight < feeRates[j].weight
+	})
+	weights := []float64{
+		float64(totalWeight) / 10, float64(totalWeight) / 4,
+		float64(totalWeight) / 2, float64(totalWeight) * 3 / 4,
+		float64(totalWeight) * 9 / 10,
+	}
+	var next int
+	var cumulativeWeight int64
+	for _, feeRate := range feeRates {
+		cumulativeWeight += feeRate.weight
+		for next < len(weights) && float64(cumulativeWeight) >= weights[next] {
+			percentiles[next] = feeRate.feeRate
+			next++
+		}
+	}
+
+	// Fill any remaining percentiles with the highest fee rate.
+	for ; next < len(weights); next++ {
+		percentiles[next] = feeRates[len(feeRates)-1].feeRate
+	}
+	return percentiles
+}
+
+// calcBlockStats returns the statistics for the passed block the same way
+// Bitcoin Core calculates them.  The statistics that depend on the outputs spent
+// by the block are only calculated when haveInputs is true, in which case the
+// passed spent outputs must be the ones for the block as loaded from the spend
+// journal.
+func calcBlockStats(block *btcutil.Block, stxos []blockchain.SpentTxOut,
+	haveInputs bool, medianTime time.Time,
+	params *chaincfg.Params) (*btcjson.GetBlockStatsResult, error) {
+
+	header := &block.MsgBlock().Header
+	height := block.Height()
+	txns := block.Transactions()
+	stats := &btcjson.GetBlockStatsResult{
+		Hash:       block.Hash().String(),
+		Height:     int64(height),
+		MedianTime: medianTime.Unix(),
+		Subsidy:    blockchain.CalcBlockSubsidy(height, params),
+		Time:       header.Timestamp.Unix(),
+		Txs:        int64(len(txns)),
+	}
+
+	// The outputs of the genesis block and the duplicate coinbases of the
+	// blocks that violate BIP0030 are not added to the utxo set.
+	isBIP0030Block := blockchain.IsBIP0030Block(block.Hash(), height)
+
+	var fees, txSizes []int64
+	var feeRates []feeRateWeight
+	var utxos int64
+	var stxoIdx int
+	for txIdx, tx := range txns {
+		msgTx := tx.MsgTx()
+		isCoinBase := txIdx == 0
+		stats.Outs += int64(len(msgTx.TxOut))
+
+		var txTotalOut int64
+		for _, txOut := range msgTx.TxOut {
+			txTotalOut += txOut.Value
+			outSize := int64(txOut.SerializeSize() +
+				blockStatsPerUtxoOverhead)
+			stats.UTXOSizeIncrease += outSize
+
+			if height == 0 || (isCoinBase && isBIP0030Block) {
+				continue
+			}
+			if (len(txOut.PkScript) > 0 &&
+				txOut.PkScript[0] == txscript.OP_RETURN) ||
+				len(txOut.PkScript) > txscript.MaxScriptSize {
+
+				continue
+			}
+			utxos++
+			stats.UTXOSizeIncreaseActual += outSize
+		}
+
+		// The remaining statistics do not include the coinbase.
+		if isCoinBase {
+			continue
+		}
+
+		stats.Ins += int64(len(msgTx.TxIn))
+		stats.TotalOut += txTotalOut
+
+		txSize := int64(msgTx.SerializeSize())
+		txSizes = append(txSizes, txSize)
+		if stats.MinTxSize == 0 || txSize < stats.MinTxSize {
+			stats.MinTxSize = txSize
+		}
+		if txSize > stats.MaxTxSize {
+			stats.MaxTxSize = txSize
+		}
+		stats.TotalSize += txSize
+
+		weight := blockchain.GetTransactionWeight(tx)
+		stats.TotalWeight += weight
+
+		if msgTx.HasWitness() {
+			stats.SegWitTxs++
+			stats.SegWitTotalSize += txSize
+			stats.SegWitTotalWeight += weight
+		}
+
+		if !haveInputs {
+			continue
+		}
+
+		var txTotalIn int64
+		for range msgTx.TxIn {
+			if stxoIdx >= len(stxos) {
+				return nil, fmt.Errorf("missing spent outputs for "+
+					"block %v", block.Hash())
+			}
+			stxo := &stxos[stxoIdx]
+			stxoIdx++
+
+			txTotalIn += stxo.Amount
+			prevOut := wire.TxOut{Value: stxo.Amount, PkScript: stxo.PkScript}
+			prevOutSize := int64(prevOut.SerializeSize() +
+				blockStatsPerUtxoOverhead)
+			stats.UTXOSizeIncrease -= prevOutSize
+			stats.UTXOSizeIncreaseActual -= prevOutSize
+		}
+
+		fee := txTotalIn - txTotalOut
+		fees = append(fees, fee)
+		if len(fees) == 1 || fee < stats.MinFee {
+			stats.MinFee = fee
+		}
+		if fee > stats.MaxFee {
+			stats.MaxFee = fee
+		}
+		stats.TotalFee += fee
+
+		// The fee rates are in satoshi per virtual byte.
+		var feeRate int64
+		if weight > 0 {
+			feeRate = fee * blockchain.WitnessScaleFactor / weight
+		}
+		feeRates = append(feeRates, feeRateWeight{feeRate, weight})
+		if len(feeRates) == 1 || feeRate < stats.MinFeeRate {
+			stats.MinFeeRate = feeRate
+		}
+		if feeRate > stats.MaxFeeRate {
+			stats.MaxFeeRate = feeRate
+		}
+	}
+
+	if len(txns) > 1 {
+		stats.AverageFee = stats.TotalFee / int64(len(txns)-1)
+		stats.AverageTxSize = stats.TotalSize / int64(len(txns)-1)
+	}
+	if stats.TotalWeight > 0 {
+		stats.AverageFeeRate = stats.TotalFee *
+			blockchain.WitnessScaleFactor / stats.TotalWeight
+	}
+	stats.FeeratePercentiles = calcFeeRatePercentiles(feeRates,
+		stats.TotalWeight)
+	stats.MedianFee = calcTruncatedMedian(fees)
+	stats.MedianTxSize = calcTruncatedMedian(txSizes)
+	stats.UTXOIncrease = stats.Outs - stats.Ins
+	stats.UTXOIncreaseActual = utxos - stats.Ins
+
+	return stats, nil
+}
+
+// handleGetBlockStats implements the getblockstats command.
+func handleGetBlockStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockStatsCmd)
+
+	// Determine the statistics to return, which are all of them when none
+	// are selected, and whether they require the spent outputs.
+	var all map[string]json.RawMessage
+	marshalled, err := json.Marshal(&btcjson.GetBlockStatsResult{})
+	if err == nil {
+		err = json.Unmarshal(marshalled, &all)
+	}
+	if err != nil {
+		context := "Failed to determine block statistics"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	var selected []string
+	if c.Stats != nil {
+		selected = *c.Stats
+	}
+	needInputs := len(selected) == 0
+	for _, name := range selected {
+		if _, ok := all[name]; !ok {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Invalid selected "+
+					"statistic %s", name),
+			}
+		}
+		if _, ok := blockStatsInputStats[name]; ok {
+			needInputs = true
+		}
+	}
+
+	// Find the block in the main chain.
+	var hash *chainhash.Hash
+	switch v := c.HashOrHeight.Value.(type) {
+	case int:
+		best := s.cfg.Chain.BestSnapshot()
+		if v < 0 || int64(v) > int64(best.Height) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Target block height %d is "+
+					"not between 0 and the current tip %d", v,
+					best.Height),
+			}
+		}
+		hash, err = s.cfg.Chain.BlockHashByHeight(int32(v))
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCOutOfRange,
+				Message: "Block number out of range",
+			}
+		}
+	case string:
+		hash, err = chainhash.NewHashFromStr(v)
+		if err != nil {
+			return nil, rpcDecodeHexError(v)
+		}
+		if !s.cfg.Chain.MainChainHasBlock(hash) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found in the main chain",
+			}
+		}
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid block hash or height",
+		}
+	}
+
+	block, err := s.cfg.Chain.BlockByHash(hash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+	medianTime, err := s.cfg.Chain.MedianTimeByHash(hash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
 
-	// Get next block hash unless there are none.
-	var nextHashString string
-	if blockHeight < best.Height {
-		nextHash, err := s.cfg.Chain.BlockHashByHeight(blockHeight + 1)
+	// Load the outputs spent by the block from the spend journal.
+	var stxos []blockchain.SpentTxOut
+	if needInputs {
+		stxos, err = s.cfg.Chain.FetchSpendJournal(block)
 		if err != nil {
-			context := "No next block"
+			context := "Failed to load spent outputs"
 			return nil, internalRPCError(err.Error(), context)
 		}
-		nextHashString = nextHash.String()
 	}
 
-	params := s.cfg.ChainParams
-	blockHeaderReply := btcjson.GetBlockHeaderVerboseResult{
-		Hash:          c.Hash,
-		Confirmations: int64(1 + best.Height - blockHeight),
-		Height:        blockHeight,
-		Version:       blockHeader.Version,
-		VersionHex:    fmt.Sprintf("%08x", blockHeader.Version),
-		MerkleRoot:    blockHeader.MerkleRoot.String(),
-		NextHash:      nextHashString,
-		PreviousHash:  blockHeader.PrevBlock.String(),
-		Nonce:         uint64(blockHeader.Nonce),
-		Time:          blockHeader.Timestamp.Unix(),
-		Bits:          strconv.FormatInt(int64(blockHeader.Bits), 16),
-		Difficulty:    getDifficultyRatio(blockHeader.Bits, params),
+	stats, err := calcBlockStats(block, stxos, needInputs, medianTime,
+		s.cfg.ChainParams)
+	if err != nil {
+		context := "Failed to calculate block statistics"
+		return nil, internalRPCError(err.Error(), context)
 	}
-	return blockHeaderReply, nil
+	if len(selected) == 0 {
+		return stats, nil
+	}
+
+	// Only return the selected statistics.
+	marshalled, err = json.Marshal(stats)
+	if err == nil {
+		err = json.Unmarshal(marshalled, &all)
+	}
+	if err != nil {
+		context := "Failed to select block statistics"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	result := make(map[string]json.RawMessage, len(selected))
+	for _, name := range selected {
+		result[name] = all[name]
+	}
+	return result, nil
 }
 
 // encodeTemplateID encodes the passed details into an ID that can be used to
@@ -2200,6 +3053,9 @@ func handleGetCFilter(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 			Message: "The CF index must be enabled for this command",
 		}
 	}
+	if err := s.checkIndexSynced(s.cfg.CfIndex); err != nil {
+		return nil, err
+	}
 
 	c := cmd.(*btcjson.GetCFilterCmd)
 	hash, err := chainhash.NewHashFromStr(c.Hash)
@@ -2229,6 +3085,9 @@ func handleGetCFilterHeader(s *rpcServer, cmd interface{}, closeChan <-chan stru
 			Message: "The CF index must be enabled for this command",
 		}
 	}
+	if err := s.checkIndexSynced(s.cfg.CfIndex); err != nil {
+		return nil, err
+	}
 
 	c := cmd.(*btcjson.GetCFilterHeaderCmd)
 	hash, err := chainhash.NewHashFromStr(c.Hash)
@@ -2252,6 +3111,130 @@ func handleGetCFilterHeader(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return hash.String(), nil
 }
 
+// handleGetChainTips implements the getchaintips command.
+func handleGetChainTips(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	tips := s.cfg.Chain.ChainTips()
+	results := make([]btcjson.GetChainTipsResult, 0, len(tips))
+	for _, tip := range tips {
+		results = append(results, btcjson.GetChainTipsResult{
+			Height:    tip.Height,
+			Hash:      tip.Hash.String(),
+			BranchLen: tip.BranchLen,
+			Status:    tip.Status.String(),
+		})
+	}
+	return results, nil
+}
+
+// handleGetChainTxStats implements the getchaintxstats command.
+func handleGetChainTxStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetChainTxStatsCmd)
+
+	// The window ends at the requested block in the main chain, which
+	// defaults to the current best block.
+	best := s.cfg.Chain.BestSnapshot()
+	hash := &best.Hash
+	height := best.Height
+	if c.BlockHash != nil {
+		var err error
+		hash, err = chainhash.NewHashFromStr(*c.BlockHash)
+		if err != nil {
+			return nil, rpcDecodeHexError(*c.BlockHash)
+		}
+		if !s.cfg.Chain.MainChainHasBlock(hash) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found in the main chain",
+			}
+		}
+		height, err = s.cfg.Chain.BlockHeightByHash(hash)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found in the main chain",
+			}
+		}
+	}
+
+	// The window covers roughly one month of blocks by default.
+	var blockCount int32
+	if c.NBlocks == nil {
+		month := 30 * 24 * time.Hour
+		blockCount = int32(month / s.cfg.ChainParams.TargetTimePerBlock)
+		if blockCount > height-1 {
+			blockCount = height - 1
+		}
+		if blockCount < 0 {
+			blockCount = 0
+		}
+	} else {
+		blockCount = *c.NBlocks
+		if blockCount < 0 || (blockCount > 0 && blockCount >= height) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid block count: should be between 0 " +
+					"and the block's height - 1",
+			}
+		}
+	}
+
+	// Look up the details of the blocks at both ends of the window.
+	pastHash, err := s.cfg.Chain.BlockHashByHeight(height - blockCount)
+	if err != nil {
+		context := "Failed to fetch block hash"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	header, err := s.cfg.Chain.HeaderByHash(hash)
+	if err != nil {
+		context := "Failed to fetch block header"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	chainTxns, err := s.cfg.Chain.ChainTxnsByHash(hash)
+	if err != nil {
+		context := "Failed to fetch chain transaction count"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	pastChainTxns, err := s.cfg.Chain.ChainTxnsByHash(pastHash)
+	if err != nil {
+		context := "Failed to fetch chain transaction count"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	medianTime, err := s.cfg.Chain.MedianTimeByHash(hash)
+	if err != nil {
+		context := "Failed to fetch median time"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	pastMedianTime, err := s.cfg.Chain.MedianTimeByHash(pastHash)
+	if err != nil {
+		context := "Failed to fetch median time"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	// The transaction counts are omitted when they are not known, which is
+	// the case for blocks that build on blocks that have not been
+	// downloaded yet.
+	result := &btcjson.GetChainTxStatsResult{
+		Time:                   header.Timestamp.Unix(),
+		TxCount:                int64(chainTxns),
+		WindowFinalBlockHash:   hash.String(),
+		WindowFinalBlockHeight: height,
+		WindowBlockCount:       blockCount,
+	}
+	if blockCount > 0 {
+		interval := int32(medianTime.Unix() - pastMedianTime.Unix())
+		result.WindowInterval = interval
+		if chainTxns != 0 && pastChainTxns != 0 {
+			windowTxCount := chainTxns - pastChainTxns
+			result.WindowTxCount = int32(windowTxCount)
+			if interval > 0 {
+				result.TxRate = float64(windowTxCount) /
+					float64(interval)
+			}
+		}
+	}
+	return result, nil
+}
+
 // handleGetConnectionCount implements the getconnectioncount command.
 func handleGetConnectionCount(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	return s.cfg.ConnMgr.ConnectedCount(), nil
@@ -2278,6 +3261,26 @@ func handleGetHashesPerSec(s *rpcServer, cmd interface{}, closeChan <-chan struc
 	return int64(s.cfg.CPUMiner.HashesPerSecond()), nil
 }
 
+// handleGetIndexInfo implements the getindexinfo command.
+func handleGetIndexInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetIndexInfoCmd)
+
+	result := make(map[string]btcjson.GetIndexInfoResult)
+	if s.cfg.IndexManager == nil {
+		return result, nil
+	}
+	for _, info := range s.cfg.IndexManager.IndexInfo() {
+		if c.IndexName != nil && *c.IndexName != info.Name {
+			continue
+		}
+		result[info.Name] = btcjson.GetIndexInfoResult{
+			Synced:          info.Synced,
+			BestBlockHeight: info.Height,
+		}
+	}
+	return result, nil
+}
+
 // handleGetHeaders implements the getheaders command.
 //
 // NOTE: This is a btcsuite extension originally ported from
@@ -2338,6 +3341,73 @@ func handleGetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (in
 	return ret, nil
 }
 
+// mempoolEntriesReply returns the reply for the passed mempool entries, which
+// is either the entries keyed by their hashes when the verbose flag is set or
+// a sorted array of their hashes otherwise.
+func mempoolEntriesReply(entries map[string]*btcjson.GetMempoolEntryResult,
+	verbose *bool) interface{} {
+
+	if verbose != nil && *verbose {
+		return entries
+	}
+
+	hashStrings := make([]string, 0, len(entries))
+	for hash := range entries {
+		hashStrings = append(hashStrings, hash)
+	}
+	sort.Strings(hashStrings)
+
+	return hashStrings
+}
+
+// handleGetMempoolAncestors implements the getmempoolancestors command.
+func handleGetMempoolAncestors(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolAncestorsCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+	entries, err := s.cfg.TxMemPool.MempoolAncestors(txHash)
+	if err != nil {
+		return nil, rpcNotInMempoolError(txHash)
+	}
+
+	return mempoolEntriesReply(entries, c.Verbose), nil
+}
+
+// handleGetMempoolDescendants implements the getmempooldescendants command.
+func handleGetMempoolDescendants(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolDescendantsCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+	entries, err := s.cfg.TxMemPool.MempoolDescendants(txHash)
+	if err != nil {
+		return nil, rpcNotInMempoolError(txHash)
+	}
+
+	return mempoolEntriesReply(entries, c.Verbose), nil
+}
+
+// handleGetMempoolEntry implements the getmempoolentry command.
+func handleGetMempoolEntry(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolEntryCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+	entry, err := s.cfg.TxMemPool.MempoolEntry(txHash)
+	if err != nil {
+		return nil, rpcNotInMempoolError(txHash)
+	}
+
+	return entry, nil
+}
+
 // handleGetMempoolInfo implements the getmempoolinfo command.
 func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	mempoolTxns := s.cfg.TxMemPool.TxDescs()
@@ -2347,9 +3417,14 @@ func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct
 		numBytes += int64(txD.Tx.MsgTx().SerializeSize())
 	}
 
+	mp := s.cfg.TxMemPool
 	ret := &btcjson.GetMempoolInfoResult{
-		Size:  int64(len(mempoolTxns)),
-		Bytes: numBytes,
+		Size:          int64(len(mempoolTxns)),
+		Bytes:         numBytes,
+		Usage:         mp.Usage(),
+		MaxMempool:    int64(cfg.MaxMempool) * 1000000,
+		MempoolMinFee: btcutil.Amount(mp.MinFeeRate()).ToBTC(),
+		MinRelayTxFee: cfg.minRelayTxFee.ToBTC(),
 	}
 
 	return ret, nil
@@ -2619,6 +3694,9 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 					"(specify --txindex)",
 			}
 		}
+		if err := s.checkIndexSynced(s.cfg.TxIndex); err != nil {
+			return nil, err
+		}
 
 		// Look up the location of the transaction.
 		blockRegion, err := s.cfg.TxIndex.TxBlockRegion(txHash)
@@ -2746,72 +3824,315 @@ func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 			}
 		}
 
-		txOut := mtx.TxOut[c.Vout]
-		if txOut == nil {
-			errStr := fmt.Sprintf("Output index: %d for txid: %s "+
-				"does not exist", c.Vout, txHash)
-			return nil, internalRPCError(errStr, "")
+		txOut := mtx.TxOut[c.Vout]
+		if txOut == nil {
+			errStr := fmt.Sprintf("Output index: %d for txid: %s "+
+				"does not exist", c.Vout, txHash)
+			return nil, internalRPCError(errStr, "")
+		}
+
+		best := s.cfg.Chain.BestSnapshot()
+		bestBlockHash = best.Hash.String()
+		confirmations = 0
+		value = txOut.Value
+		pkScript = txOut.PkScript
+		isCoinbase = blockchain.IsCoinBaseTx(mtx)
+	} else {
+		out := wire.OutPoint{Hash: *txHash, Index: c.Vout}
+		entry, err := s.cfg.Chain.FetchUtxoEntry(out)
+		if err != nil {
+			return nil, rpcNoTxInfoError(txHash)
+		}
+
+		// To match the behavior of the reference client, return nil
+		// (JSON null) if the transaction output is spent by another
+		// transaction already in the main chain.  Mined transactions
+		// that are spent by a mempool transaction are not affected by
+		// this.
+		if entry == nil || entry.IsSpent() {
+			return nil, nil
+		}
+
+		best := s.cfg.Chain.BestSnapshot()
+		bestBlockHash = best.Hash.String()
+		confirmations = 1 + best.Height - entry.BlockHeight()
+		value = entry.Amount()
+		pkScript = entry.PkScript()
+		isCoinbase = entry.IsCoinBase()
+	}
+
+	// Disassemble script into single line printable format.
+	// The disassembled string will contain [error] inline if the script
+	// doesn't fully parse, so ignore the error here.
+	disbuf, _ := txscript.DisasmString(pkScript)
+
+	// Get further info about the script.
+	// Ignore the error here since an error means the script couldn't parse
+	// and there is no additional information about it anyways.
+	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(pkScript,
+		s.cfg.ChainParams)
+	addresses := make([]string, len(addrs))
+	for i, addr := range addrs {
+		addresses[i] = addr.EncodeAddress()
+	}
+
+	txOutReply := &btcjson.GetTxOutResult{
+		BestBlock:     bestBlockHash,
+		Confirmations: int64(confirmations),
+		Value:         btcutil.Amount(value).ToBTC(),
+		ScriptPubKey: btcjson.ScriptPubKeyResult{
+			Asm:       disbuf,
+			Hex:       hex.EncodeToString(pkScript),
+			ReqSigs:   int32(reqSigs),
+			Type:      scriptClass.String(),
+			Addresses: addresses,
+		},
+		Coinbase: isCoinbase,
+	}
+	return txOutReply, nil
+}
+
+// handleGetTxOutProof implements the gettxoutproof command.
+func handleGetTxOutProof(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetTxOutProofCmd)
+
+	// Parse the transaction hashes and reject duplicates since a proof can
+	// only include each transaction once.
+	if len(c.TxIDs) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "At least one transaction hash must be provided",
+		}
+	}
+	txHashes := make([]*chainhash.Hash, 0, len(c.TxIDs))
+	seen := make(map[chainhash.Hash]struct{}, len(c.TxIDs))
+	for _, txID := range c.TxIDs {
+		txHash, err := chainhash.NewHashFromStr(txID)
+		if err != nil {
+			return nil, rpcDecodeHexError(txID)
+		}
+		if _, ok := seen[*txHash]; ok {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Duplicate transaction %v",
+					txHash),
+			}
+		}
+		seen[*txHash] = struct{}{}
+		txHashes = append(txHashes, txHash)
+	}
+
+	// Use the provided block hash or look up the block that contains the
+	// first transaction via the transaction index otherwise.  All of the
+	// transactions have to be in the same block, so this is enough.
+	var blockHash *chainhash.Hash
+	if c.BlockHash != nil {
+		hash, err := chainhash.NewHashFromStr(*c.BlockHash)
+		if err != nil {
+			return nil, rpcDecodeHexError(*c.BlockHash)
+		}
+		blockHash = hash
+	} else {
+		if s.cfg.TxIndex == nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCNoTxInfo,
+				Message: "The transaction index must be " +
+					"enabled to locate transactions without " +
+					"a block hash (specify --txindex)",
+			}
+		}
+		if err := s.checkIndexSynced(s.cfg.TxIndex); err != nil {
+			return nil, err
+		}
+
+		blockRegion, err := s.cfg.TxIndex.TxBlockRegion(txHashes[0])
+		if err != nil {
+			context := "Failed to retrieve transaction location"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if blockRegion == nil {
+			return nil, rpcNoTxInfoError(txHashes[0])
+		}
+		blockHash = blockRegion.Hash
+	}
+
+	// Load the block from the main chain since a proof for any other block
+	// could not be verified.
+	block, err := s.cfg.Chain.BlockByHash(blockHash)
+	if err != nil {
+		if s.cfg.Chain.BlockPruned(blockHash) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCMisc,
+				Message: "Block not available (pruned data)",
+			}
+		}
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+
+	mBlock, matchedIndices := bloom.NewMerkleBlockWithTxHashes(block,
+		txHashes)
+	if len(matchedIndices) != len(txHashes) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Not all transactions found in specified or " +
+				"retrieved block",
+		}
+	}
+
+	var buf bytes.Buffer
+	err = mBlock.BtcEncode(&buf, wire.ProtocolVersion, wire.LatestEncoding)
+	if err != nil {
+		context := "Failed to serialize merkle block"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// handleGetTxOutSetInfo implements the gettxoutsetinfo command.
+func handleGetTxOutSetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if s.cfg.CoinStatsIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCNoCoinStatsIndex,
+			Message: "The coinstats index must be enabled for this " +
+				"command (specify --coinstatsindex)",
+		}
+	}
+	if err := s.checkIndexSynced(s.cfg.CoinStatsIndex); err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.GetTxOutSetInfoCmd)
+	hashType := "muhash"
+	if c.HashType != nil {
+		hashType = *c.HashType
+	}
+	if hashType != "muhash" && hashType != "none" {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Unsupported hash type %q -- "+
+				"supported types are muhash and none", hashType),
 		}
+	}
 
+	// Find the block to return the statistics for, which defaults to the
+	// current best block.
+	var hash *chainhash.Hash
+	var err error
+	if c.HashOrHeight == nil {
 		best := s.cfg.Chain.BestSnapshot()
-		bestBlockHash = best.Hash.String()
-		confirmations = 0
-		value = txOut.Value
-		pkScript = txOut.PkScript
-		isCoinbase = blockchain.IsCoinBaseTx(mtx)
+		hash = &best.Hash
 	} else {
-		out := wire.OutPoint{Hash: *txHash, Index: c.Vout}
-		entry, err := s.cfg.Chain.FetchUtxoEntry(out)
-		if err != nil {
-			return nil, rpcNoTxInfoError(txHash)
+		switch v := c.HashOrHeight.Value.(type) {
+		case int:
+			hash, err = s.cfg.Chain.BlockHashByHeight(int32(v))
+			if err != nil {
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCOutOfRange,
+					Message: "Block number out of range",
+				}
+			}
+		case string:
+			hash, err = chainhash.NewHashFromStr(v)
+			if err != nil {
+				return nil, rpcDecodeHexError(v)
+			}
+		default:
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid block hash or height",
+			}
 		}
+	}
 
-		// To match the behavior of the reference client, return nil
-		// (JSON null) if the transaction output is spent by another
-		// transaction already in the main chain.  Mined transactions
-		// that are spent by a mempool transaction are not affected by
-		// this.
-		if entry == nil || entry.IsSpent() {
-			return nil, nil
+	stats, err := s.cfg.CoinStatsIndex.CoinStats(hash)
+	if err != nil {
+		context := "Failed to retrieve utxo set statistics"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if stats == nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found in the main chain or not " +
+				"indexed yet",
 		}
+	}
 
-		best := s.cfg.Chain.BestSnapshot()
-		bestBlockHash = best.Hash.String()
-		confirmations = 1 + best.Height - entry.BlockHeight()
-		value = entry.Amount()
-		pkScript = entry.PkScript()
-		isCoinbase = entry.IsCoinBase()
+	result := &btcjson.GetTxOutSetInfoResult{
+		Height:      int64(stats.Height),
+		BestBlock:   *hash,
+		TxOuts:      int64(stats.TxOuts),
+		BogoSize:    int64(stats.BogoSize),
+		TotalAmount: btcutil.Amount(stats.TotalAmount),
+	}
+	if hashType == "muhash" {
+		result.MuHash = stats.MuHash
 	}
+	return result, nil
+}
 
-	// Disassemble script into single line printable format.
-	// The disassembled string will contain [error] inline if the script
-	// doesn't fully parse, so ignore the error here.
-	disbuf, _ := txscript.DisasmString(pkScript)
+// handleGetTxSpendingPrevOut implements the gettxspendingprevout command.
+func handleGetTxSpendingPrevOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetTxSpendingPrevOutCmd)
+	if len(c.Outputs) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid parameter, outputs are missing",
+		}
+	}
 
-	// Get further info about the script.
-	// Ignore the error here since an error means the script couldn't parse
-	// and there is no additional information about it anyways.
-	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(pkScript,
-		s.cfg.ChainParams)
-	addresses := make([]string, len(addrs))
-	for i, addr := range addrs {
-		addresses[i] = addr.EncodeAddress()
+	// The spends in the main chain can't be reported reliably while the
+	// spender index is still catching up.
+	if s.cfg.TxoSpenderIndex != nil {
+		err := s.checkIndexSynced(s.cfg.TxoSpenderIndex)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	txOutReply := &btcjson.GetTxOutResult{
-		BestBlock:     bestBlockHash,
-		Confirmations: int64(confirmations),
-		Value:         btcutil.Amount(value).ToBTC(),
-		ScriptPubKey: btcjson.ScriptPubKeyResult{
-			Asm:       disbuf,
-			Hex:       hex.EncodeToString(pkScript),
-			ReqSigs:   int32(reqSigs),
-			Type:      scriptClass.String(),
-			Addresses: addresses,
-		},
-		Coinbase: isCoinbase,
+	results := make([]btcjson.GetTxSpendingPrevOutResult, 0, len(c.Outputs))
+	for _, output := range c.Outputs {
+		txHash, err := chainhash.NewHashFromStr(output.Txid)
+		if err != nil {
+			return nil, rpcDecodeHexError(output.Txid)
+		}
+		outpoint := wire.OutPoint{Hash: *txHash, Index: output.Vout}
+		result := btcjson.GetTxSpendingPrevOutResult{
+			Txid: output.Txid,
+			Vout: output.Vout,
+		}
+
+		// Check the mempool first and then fall back to the spender
+		// index for spends in the main chain when it is enabled.
+		if spend := s.cfg.TxMemPool.CheckSpend(outpoint); spend != nil {
+			result.SpendingTxid = spend.Hash().String()
+			results = append(results, result)
+			continue
+		}
+		if s.cfg.TxoSpenderIndex != nil {
+			spender, err := s.cfg.TxoSpenderIndex.Spender(&outpoint)
+			if err != nil {
+				context := "Failed to fetch spending transaction"
+				return nil, internalRPCError(err.Error(), context)
+			}
+			if spender != nil {
+				blockHash, err := s.cfg.Chain.BlockHashByHeight(
+					spender.BlockHeight)
+				if err != nil {
+					context := "Failed to fetch block hash"
+					return nil, internalRPCError(err.Error(),
+						context)
+				}
+				result.SpendingTxid = spender.SpendingTxHash.String()
+				result.BlockHash = blockHash.String()
+			}
+		}
+		results = append(results, result)
 	}
-	return txOutReply, nil
+
+	return results, nil
 }
 
 // handleHelp implements the help command.
@@ -2853,6 +4174,71 @@ func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return help, nil
 }
 
+// lookupChainBlockHash parses the passed hex-encoded block hash and ensures the
+// block is known to the chain.  It is used by the handlers that change the
+// state of a specific block in the chain.
+func lookupChainBlockHash(s *rpcServer, blockHash string) (*chainhash.Hash, error) {
+	hash, err := chainhash.NewHashFromStr(blockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(blockHash)
+	}
+	if _, err := s.cfg.Chain.HeaderByHash(hash); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found",
+		}
+	}
+	return hash, nil
+}
+
+// handleInvalidateBlock implements the invalidateblock command.
+func handleInvalidateBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.InvalidateBlockCmd)
+
+	hash, err := lookupChainBlockHash(s, c.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cfg.Chain.InvalidateBlock(hash); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDatabase,
+			Message: "Unable to invalidate block: " + err.Error(),
+		}
+	}
+
+	return nil, nil
+}
+
+// handleLoadTxOutSet implements the loadtxoutset command.
+func handleLoadTxOutSet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.LoadTxOutSetCmd)
+
+	path := cleanAndExpandPath(c.Path)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unable to open utxo snapshot: " + err.Error(),
+		}
+	}
+	defer f.Close()
+
+	info, err := s.cfg.Chain.LoadUtxoSnapshot(f)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDatabase,
+			Message: "Unable to load utxo snapshot: " + err.Error(),
+		}
+	}
+
+	return &btcjson.LoadTxOutSetResult{
+		CoinsLoaded: info.NumCoins,
+		TipHash:     info.BaseHash.String(),
+		BaseHeight:  info.BaseHeight,
+		Path:        path,
+	}, nil
+}
+
 // handlePing implements the ping command.
 func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Ask server to ping \o_
@@ -2866,6 +4252,42 @@ func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return nil, nil
 }
 
+// handlePreciousBlock implements the preciousblock command.
+func handlePreciousBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.PreciousBlockCmd)
+
+	hash, err := lookupChainBlockHash(s, c.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cfg.Chain.PreciousBlock(hash); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDatabase,
+			Message: "Unable to prefer block: " + err.Error(),
+		}
+	}
+
+	return nil, nil
+}
+
+// handleReconsiderBlock implements the reconsiderblock command.
+func handleReconsiderBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ReconsiderBlockCmd)
+
+	hash, err := lookupChainBlockHash(s, c.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cfg.Chain.ReconsiderBlock(hash); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDatabase,
+			Message: "Unable to reconsider block: " + err.Error(),
+		}
+	}
+
+	return nil, nil
+}
+
 // retrievedTx represents a transaction that was either loaded from the
 // transaction memory pool or from the database.  When a transaction is loaded
 // from the database, it is loaded with the raw serialized bytes while the
@@ -3087,6 +4509,19 @@ func fetchMempoolTxnsForAddress(s *rpcServer, addr btcutil.Address, numToSkip, n
 	return mpTxns[numToSkip:rangeEnd], numToSkip
 }
 
+// handleSaveMempool implements the savemempool command.
+func handleSaveMempool(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	path, _, err := s.cfg.SaveMempool()
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Unable to save mempool: " + err.Error(),
+		}
+	}
+
+	return &btcjson.SaveMempoolResult{Filename: path}, nil
+}
+
 // handleSearchRawTransactions implements the searchrawtransactions command.
 func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if the address index is not enabled.
@@ -3097,6 +4532,9 @@ func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan
 			Message: "Address index must be enabled (--addrindex)",
 		}
 	}
+	if err := s.checkIndexSynced(addrIndex); err != nil {
+		return nil, err
+	}
 
 	// Override the flag for including extra previous output information in
 	// each input if needed.
@@ -3577,6 +5015,150 @@ func handleSubmitBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return nil, nil
 }
 
+// handleSubmitPackage implements the submitpackage command.
+func handleSubmitPackage(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SubmitPackageCmd)
+
+	txns, err := decodeRawTxns(c.RawTxns)
+	if err != nil {
+		return nil, err
+	}
+
+	// No result is returned when the transactions don't form a package.
+	result, err := s.cfg.TxMemPool.ProcessPackage(txns)
+	if result == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: err.Error(),
+		}
+	}
+	packageMsg := "success"
+	if err != nil {
+		if _, ok := err.(mempool.RuleError); !ok {
+			rpcsLog.Errorf("Failed to process package with child "+
+				"%v: %v", txns[len(txns)-1].Hash(), err)
+		}
+		rpcsLog.Debugf("Rejected package with child %v: %v",
+			txns[len(txns)-1].Hash(), err)
+		packageMsg = err.Error()
+	}
+
+	// Relay and notify clients of the transactions that were accepted,
+	// even when others of the package were rejected.  Peers are sent the
+	// transactions individually, so those that don't pay enough fees on
+	// their own are only relayed to peers that accept them.
+	if len(result.Accepted) > 0 {
+		s.cfg.ConnMgr.RelayTransactions(result.Accepted)
+		s.NotifyNewTransactions(result.Accepted)
+	}
+
+	reply := btcjson.SubmitPackageResult{
+		PackageMsg: packageMsg,
+		TxResults:  make(map[string]btcjson.SubmitPackageTxResult, len(txns)),
+	}
+	for _, res := range result.TxResults {
+		txResult := btcjson.SubmitPackageTxResult{
+			Txid: res.Tx.Hash().String(),
+		}
+		switch {
+		case res.Err != nil:
+			txResult.Error = res.Err.Error()
+
+		case res.AlreadyInPool:
+			txResult.Vsize = mempool.GetTxVirtualSize(res.Tx)
+			txResult.Fees = &btcjson.SubmitPackageFees{
+				Base: btcutil.Amount(res.Desc.Fee).ToBTC(),
+			}
+
+		default:
+			includes := make([]string, 0, len(res.EffectiveIncludes))
+			for _, tx := range res.EffectiveIncludes {
+				includes = append(includes, tx.WitnessHash().String())
+			}
+			txResult.Vsize = mempool.GetTxVirtualSize(res.Tx)
+			txResult.Fees = &btcjson.SubmitPackageFees{
+				Base: btcutil.Amount(res.Desc.Fee).ToBTC(),
+				EffectiveFeeRate: btcutil.Amount(
+					res.EffectiveFeeRate).ToBTC(),
+				EffectiveIncludes: includes,
+			}
+
+			// Keep track of the transactions the package added so
+			// that they can be rebroadcast if they don't make their
+			// way into a block.
+			iv := wire.NewInvVect(wire.InvTypeTx, res.Tx.Hash())
+			s.cfg.ConnMgr.AddRebroadcastInventory(iv, res.Desc)
+		}
+		reply.TxResults[res.Tx.WitnessHash().String()] = txResult
+	}
+	for _, tx := range result.Replaced {
+		reply.ReplacedTransactions = append(reply.ReplacedTransactions,
+			tx.Hash().String())
+	}
+
+	return reply, nil
+}
+
+// handleTestMempoolAccept implements the testmempoolaccept command.
+func handleTestMempoolAccept(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.TestMempoolAcceptCmd)
+
+	if len(c.RawTxns) == 0 || len(c.RawTxns) > maxTestMempoolAcceptTxns {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Between 1 and %d transactions "+
+				"must be provided", maxTestMempoolAcceptTxns),
+		}
+	}
+
+	// Reject transactions paying more than the default maximum fee rate
+	// when none is provided.  A maximum fee rate of zero disables the
+	// check.
+	maxFeeRateBTC := defaultTestMempoolAcceptMaxFeeRate
+	if c.MaxFeeRate != nil {
+		maxFeeRateBTC = *c.MaxFeeRate
+	}
+	maxFeeRate, err := btcutil.NewAmount(maxFeeRateBTC)
+	if err != nil || maxFeeRate < 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid maxfeerate",
+		}
+	}
+
+	txns, err := decodeRawTxns(c.RawTxns)
+	if err != nil {
+		return nil, err
+	}
+
+	results := s.cfg.TxMemPool.TestAccept(txns, maxFeeRate)
+	reply := make([]btcjson.TestMempoolAcceptResult, 0, len(results))
+	for _, res := range results {
+		result := btcjson.TestMempoolAcceptResult{
+			Txid:    res.Tx.Hash().String(),
+			Wtxid:   res.Tx.WitnessHash().String(),
+			Allowed: res.Err == nil,
+		}
+		if res.Err != nil {
+			if _, ok := res.Err.(mempool.RuleError); !ok {
+				rpcsLog.Errorf("Failed to test transaction %v: %v",
+					res.Tx.Hash(), res.Err)
+			}
+			code, reason := mempool.ErrToRejectErr(res.Err)
+			result.RejectCode = uint8(code)
+			result.RejectReason = reason
+		} else {
+			result.Vsize = res.VSize
+			result.Fees = &btcjson.TestMempoolAcceptFees{
+				Base: btcutil.Amount(res.Fee).ToBTC(),
+			}
+		}
+		reply = append(reply, result)
+	}
+
+	return reply, nil
+}
+
 // handleUptime implements the uptime command.
 func handleUptime(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	return time.Now().Unix() - s.cfg.StartupTime, nil
@@ -3744,6 +5326,64 @@ func handleVerifyMessage(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 	return address.EncodeAddress() == c.Address, nil
 }
 
+// handleVerifyTxOutProof implements the verifytxoutproof command.
+func handleVerifyTxOutProof(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.VerifyTxOutProofCmd)
+
+	serializedProof, err := hex.DecodeString(c.Proof)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Proof)
+	}
+	var mBlock wire.MsgMerkleBlock
+	err = mBlock.BtcDecode(bytes.NewReader(serializedProof),
+		wire.ProtocolVersion, wire.LatestEncoding)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "Proof decode failed: " + err.Error(),
+		}
+	}
+
+	// A malformed proof or one that does not commit to the merkle root of
+	// its header does not prove anything, so no transactions are returned
+	// in that case.
+	merkleRoot, matches, _, err := bloom.ExtractMatches(&mBlock)
+	if err != nil || !merkleRoot.IsEqual(&mBlock.Header.MerkleRoot) {
+		return []string{}, nil
+	}
+
+	// Ensure the proof is for a block in the main chain.
+	blockHash := mBlock.Header.BlockHash()
+	if !s.cfg.Chain.MainChainHasBlock(&blockHash) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Block not found in chain",
+		}
+	}
+	numTxns, err := s.cfg.Chain.BlockTxCountByHash(&blockHash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Block not found in chain",
+		}
+	}
+
+	// The merkle tree of a block with an odd number of transactions at any
+	// level duplicates the last hash of that level, so a partial merkle
+	// tree claiming a different number of transactions can commit to the
+	// same merkle root (CVE-2012-2459).  Such a proof does not prove
+	// anything either.
+	if uint64(mBlock.Transactions) != numTxns {
+		return []string{}, nil
+	}
+
+	txIDs := make([]string, 0, len(matches))
+	for _, txHash := range matches {
+		txIDs = append(txIDs, txHash.String())
+	}
+	return txIDs, nil
+}
+
 // handleVersion implements the version command.
 //
 // NOTE: This is a btcsuite extension ported from github.com/decred/dcrd.
@@ -4598,13 +6238,27 @@ type rpcserverConfig struct {
 
 	// These fields define any optional indexes the RPC server can make use
 	// of to provide additional data when queried.
-	TxIndex   *indexers.TxIndex
-	AddrIndex *indexers.AddrIndex
-	CfIndex   *indexers.CfIndex
+	TxIndex           *indexers.TxIndex
+	AddrIndex         *indexers.AddrIndex
+	CfIndex           *indexers.CfIndex
+	CoinStatsIndex    *indexers.CoinStatsIndex
+	TxoSpenderIndex   *indexers.TxoSpenderIndex
+	SpendJournalIndex *indexers.SpendJournalIndex
+	BalanceIndex      *indexers.BalanceIndex
+
+	// IndexManager manages the optional indexes above and reports whether
+	// or not they are still catching up with the main chain.  It is nil
+	// when none of them are enabled.
+	IndexManager *indexers.Manager
 
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
 	FeeEstimator *mempool.FeeEstimator
+
+	// SaveMempool writes the transactions in the memory pool to disk so
+	// they are loaded again on startup.  It returns the path of the file
+	// along with the number of transactions written.
+	SaveMempool func() (string, int, error)
 }
 
 // newRPCServer returns a new instance of the rpcServer struct.
@@ -0,0 +1,317 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/blockchain/indexers"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestCalcFeeRatePercentiles ensures the fee rate percentiles are calculated
+// by weight the same way Bitcoin Core calculates them.
+func TestCalcFeeRatePercentiles(t *testing.T) {
+	t.Parallel()
+
+	// repeat returns the passed fee rate and weight pair count times.
+	repeat := func(count int, feeRate, weight int64) []feeRateWeight {
+		feeRates := make([]feeRateWeight, count)
+		for i := range feeRates {
+			feeRates[i] = feeRateWeight{feeRate, weight}
+		}
+		return feeRates
+	}
+
+	tests := []struct {
+		name        string
+		feeRates    []feeRateWeight
+		totalWeight int64
+		want        []int64
+	}{{
+		name:        "no transactions",
+		totalWeight: 0,
+		want:        []int64{0, 0, 0, 0, 0},
+	}, {
+		name:        "two fee rates",
+		feeRates:    append(repeat(100, 2, 1), repeat(100, 1, 1)...),
+		totalWeight: 200,
+		want:        []int64{1, 1, 1, 2, 2},
+	}, {
+		name: "multiple percentiles per transaction",
+		feeRates: []feeRateWeight{
+			{9, 20}, {1, 10}, {5, 30}, {2, 40},
+		},
+		totalWeight: 100,
+		want:        []int64{1, 2, 2, 5, 9},
+	}, {
+		name: "percentiles at weight boundaries",
+		feeRates: []feeRateWeight{
+			{1, 9}, {2, 11}, {2, 5}, {4, 50}, {5, 10}, {9, 15},
+		},
+		totalWeight: 100,
+		want:        []int64{2, 2, 4, 4, 9},
+	}, {
+		name: "one transaction spans all percentiles",
+		feeRates: []feeRateWeight{
+			{1, 100}, {2, 1}, {3, 1}, {3, 1}, {999999, 1},
+		},
+		totalWeight: 104,
+		want:        []int64{1, 1, 1, 1, 1},
+	}}
+
+	for _, test := range tests {
+		got := calcFeeRatePercentiles(test.feeRates, test.totalWeight)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: unexpected percentiles %v, want %v",
+				test.name, got, test.want)
+		}
+	}
+}
+
+// TestCalcBlockStats ensures the block statistics are calculated from the block
+// and the outputs it spends as expected.
+func TestCalcBlockStats(t *testing.T) {
+	t.Parallel()
+
+	p2pkh := []byte{
+		txscript.OP_DUP, txscript.OP_HASH160, txscript.OP_DATA_20,
+		1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG,
+	}
+	nullData := []byte{txscript.OP_RETURN, txscript.OP_DATA_1, 1}
+
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		SignatureScript:  []byte{txscript.OP_DATA_1, 5},
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000000000, p2pkh))
+
+	// A legacy transaction paying a fee of 1000 and a segwit transaction
+	// paying a fee of 5000 which also creates an unspendable output.
+	legacy := wire.NewMsgTx(1)
+	legacy.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 0}, []byte{1}, nil))
+	legacy.AddTxOut(wire.NewTxOut(9000, p2pkh))
+	segwit := wire.NewMsgTx(2)
+	segwit.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil,
+		wire.TxWitness{make([]byte, 72), make([]byte, 33)}))
+	segwit.AddTxOut(wire.NewTxOut(15000, p2pkh))
+	segwit.AddTxOut(wire.NewTxOut(0, nullData))
+	stxos := []blockchain.SpentTxOut{
+		{Amount: 10000, PkScript: p2pkh, Height: 1},
+		{Amount: 20000, PkScript: p2pkh, Height: 1},
+	}
+
+	timestamp := time.Unix(1600000000, 0)
+	block := btcutil.NewBlock(&wire.MsgBlock{
+		Header:       wire.BlockHeader{Timestamp: timestamp},
+		Transactions: []*wire.MsgTx{coinbase, legacy, segwit},
+	})
+	block.SetHeight(100)
+	medianTime := timestamp.Add(-time.Hour)
+
+	legacySize := int64(legacy.SerializeSize())
+	segwitSize := int64(segwit.SerializeSize())
+	legacyWeight := blockchain.GetTransactionWeight(btcutil.NewTx(legacy))
+	segwitWeight := blockchain.GetTransactionWeight(btcutil.NewTx(segwit))
+	legacyFeeRate := 1000 * blockchain.WitnessScaleFactor / legacyWeight
+	segwitFeeRate := 5000 * blockchain.WitnessScaleFactor / segwitWeight
+	p2pkhUtxoSize := int64(8 + 1 + len(p2pkh) + blockStatsPerUtxoOverhead)
+	nullDataUtxoSize := int64(8 + 1 + len(nullData) +
+		blockStatsPerUtxoOverhead)
+
+	stats, err := calcBlockStats(block, stxos, true, medianTime,
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("calcBlockStats: %v", err)
+	}
+	checkStat := func(name string, got, want int64) {
+		t.Helper()
+		if got != want {
+			t.Errorf("unexpected %s %d, want %d", name, got, want)
+		}
+	}
+	checkStat("avgfee", stats.AverageFee, 3000)
+	checkStat("avgfeerate", stats.AverageFeeRate,
+		6000*blockchain.WitnessScaleFactor/(legacyWeight+segwitWeight))
+	checkStat("avgtxsize", stats.AverageTxSize, (legacySize+segwitSize)/2)
+	checkStat("height", stats.Height, 100)
+	checkStat("ins", stats.Ins, 2)
+	checkStat("maxfee", stats.MaxFee, 5000)
+	checkStat("maxfeerate", stats.MaxFeeRate, segwitFeeRate)
+	checkStat("maxtxsize", stats.MaxTxSize, segwitSize)
+	checkStat("medianfee", stats.MedianFee, 3000)
+	checkStat("mediantime", stats.MedianTime, medianTime.Unix())
+	checkStat("mediantxsize", stats.MedianTxSize,
+		(legacySize+segwitSize)/2)
+	checkStat("minfee", stats.MinFee, 1000)
+	checkStat("minfeerate", stats.MinFeeRate, legacyFeeRate)
+	checkStat("mintxsize", stats.MinTxSize, legacySize)
+	checkStat("outs", stats.Outs, 4)
+	checkStat("swtotal_size", stats.SegWitTotalSize, segwitSize)
+	checkStat("swtotal_weight", stats.SegWitTotalWeight, segwitWeight)
+	checkStat("swtxs", stats.SegWitTxs, 1)
+	checkStat("subsidy", stats.Subsidy, 5000000000)
+	checkStat("time", stats.Time, timestamp.Unix())
+	checkStat("totalfee", stats.TotalFee, 6000)
+	checkStat("total_out", stats.TotalOut, 24000)
+	checkStat("total_size", stats.TotalSize, legacySize+segwitSize)
+	checkStat("total_weight", stats.TotalWeight, legacyWeight+segwitWeight)
+	checkStat("txs", stats.Txs, 3)
+	checkStat("utxo_increase", stats.UTXOIncrease, 2)
+	checkStat("utxo_size_inc", stats.UTXOSizeIncrease,
+		3*p2pkhUtxoSize+nullDataUtxoSize-2*p2pkhUtxoSize)
+	checkStat("utxo_increase_actual", stats.UTXOIncreaseActual, 1)
+	checkStat("utxo_size_inc_actual", stats.UTXOSizeIncreaseActual,
+		p2pkhUtxoSize)
+	if stats.Hash != block.Hash().String() {
+		t.Errorf("unexpected hash %s, want %s", stats.Hash, block.Hash())
+	}
+
+	// The statistics that depend on the spent outputs must not be
+	// calculated without them.
+	stats, err = calcBlockStats(block, nil, false, medianTime,
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("calcBlockStats without inputs: %v", err)
+	}
+	checkStat("totalfee without inputs", stats.TotalFee, 0)
+	checkStat("ins without inputs", stats.Ins, 2)
+
+	// Missing spent outputs must be detected.
+	_, err = calcBlockStats(block, stxos[:1], true, medianTime,
+		&chaincfg.MainNetParams)
+	if err == nil {
+		t.Fatal("calcBlockStats: expected error for missing spent outputs")
+	}
+}
+
+// TestHandleGetBlockFilter ensures getblockfilter returns the committed filter
+// of blocks in the main chain and the expected errors for unknown blocks and
+// blocks without a filter.
+func TestHandleGetBlockFilter(t *testing.T) {
+	// The log rotator is not initialized in tests, so the chain and index
+	// logging must be disabled.
+	setLogLevels("off")
+
+	params := &chaincfg.RegressionNetParams
+	db, err := database.Create("ffldb", filepath.Join(t.TempDir(), "db"),
+		params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+	cfIndex := indexers.NewCfIndex(db, params)
+	indexManager := indexers.NewManager(db, []indexers.Indexer{cfIndex})
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:           db,
+		ChainParams:  params,
+		TimeSource:   blockchain.NewMedianTime(),
+		IndexManager: indexManager,
+	})
+	if err != nil {
+		t.Fatalf("unable to create chain: %v", err)
+	}
+	if err := indexManager.WaitForCatchUp(); err != nil {
+		t.Fatalf("WaitForCatchUp: %v", err)
+	}
+
+	// Add the header of a block after the genesis block whose data, and
+	// therefore filter, is not available.
+	header := wire.BlockHeader{
+		Version:   1,
+		PrevBlock: *params.GenesisHash,
+		Timestamp: params.GenesisBlock.Header.Timestamp.Add(time.Minute),
+		Bits:      params.PowLimitBits,
+	}
+	target := blockchain.CompactToBig(header.Bits)
+	for {
+		hash := header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+			break
+		}
+		header.Nonce++
+	}
+	err = chain.ProcessBlockHeader(&header, blockchain.BFNone)
+	if err != nil {
+		t.Fatalf("ProcessBlockHeader: %v", err)
+	}
+	headerOnlyHash := header.BlockHash()
+
+	wantFilter, err := cfIndex.FilterByBlockHash(params.GenesisHash,
+		wire.GCSFilterRegular)
+	if err != nil {
+		t.Fatalf("FilterByBlockHash: %v", err)
+	}
+	headerBytes, err := cfIndex.FilterHeaderByBlockHash(params.GenesisHash,
+		wire.GCSFilterRegular)
+	if err != nil {
+		t.Fatalf("FilterHeaderByBlockHash: %v", err)
+	}
+	wantHeader, err := chainhash.NewHash(headerBytes)
+	if err != nil {
+		t.Fatalf("unexpected filter header: %v", err)
+	}
+
+	s := &rpcServer{cfg: rpcserverConfig{
+		Chain:        chain,
+		ChainParams:  params,
+		CfIndex:      cfIndex,
+		IndexManager: indexManager,
+	}}
+	tests := []struct {
+		name    string
+		hash    string
+		want    *btcjson.GetBlockFilterResult
+		errCode btcjson.RPCErrorCode
+	}{{
+		name: "genesis block",
+		hash: params.GenesisHash.String(),
+		want: &btcjson.GetBlockFilterResult{
+			Filter: hex.EncodeToString(wantFilter),
+			Header: wantHeader.String(),
+		},
+	}, {
+		name:    "unknown block",
+		hash:    chainhash.Hash{0x01}.String(),
+		errCode: btcjson.ErrRPCBlockNotFound,
+	}, {
+		name:    "missing filter",
+		hash:    headerOnlyHash.String(),
+		errCode: btcjson.ErrRPCMisc,
+	}}
+	for _, test := range tests {
+		cmd := btcjson.NewGetBlockFilterCmd(test.hash, nil)
+		result, err := handleGetBlockFilter(s, cmd, nil)
+		if test.want != nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+				continue
+			}
+			if !reflect.DeepEqual(result, test.want) {
+				t.Errorf("%s: unexpected result %+v, want %+v",
+					test.name, result, test.want)
+			}
+			continue
+		}
+		rpcErr, ok := err.(*btcjson.RPCError)
+		if !ok || rpcErr.Code != test.errCode {
+			t.Errorf("%s: unexpected error %v, want code %d",
+				test.name, err, test.errCode)
+		}
+	}
+}
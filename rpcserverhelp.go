@@ -80,6 +80,13 @@ var helpDescsEnUS = map[string]string{
 	"vin-scriptSig":   "The signature script used to redeem the origin transaction as a JSON object (non-coinbase txns only)",
 	"vin-txinwitness": "The witness used to redeem the input encoded as a string array of its items",
 	"vin-sequence":    "The script sequence number",
+	"vin-prevout":     "The output spent by the input (getblock with verbosity=3 and non-coinbase txns only)",
+
+	// PrevOutVerbose help.
+	"prevoutverbose-generated":    "Whether the spent output was created by a coinbase transaction",
+	"prevoutverbose-height":       "The height of the block that created the spent output",
+	"prevoutverbose-value":        "The amount of the spent output in BTC",
+	"prevoutverbose-scriptPubKey": "The public key script of the spent output as a JSON object",
 
 	// ScriptPubKeyResult help.
 	"scriptpubkeyresult-asm":       "Disassembly of the script",
@@ -115,14 +122,39 @@ var helpDescsEnUS = map[string]string{
 	"decodescript--synopsis": "Returns a JSON object with information about the provided hex-encoded script.",
 	"decodescript-hexscript": "Hex-encoded script",
 
+	// DumpTxOutSetCmd help.
+	"dumptxoutset--synopsis": "Writes a snapshot of the unspent transaction output set as of the current best block to a file.",
+	"dumptxoutset-path":      "The path of the file to write the snapshot to, which must not already exist",
+
+	// DumpTxOutSetResult help.
+	"dumptxoutsetresult-coins_written": "The number of unspent transaction outputs written",
+	"dumptxoutsetresult-base_hash":     "The hash of the block the snapshot is for",
+	"dumptxoutsetresult-base_height":   "The height of the block the snapshot is for",
+	"dumptxoutsetresult-path":          "The path of the written snapshot",
+	"dumptxoutsetresult-txoutset_hash": "The hash of the unspent transaction output set",
+	"dumptxoutsetresult-nchaintx":      "The total number of transactions in the chain up to the block the snapshot is for",
+
 	// EstimateFeeCmd help.
 	"estimatefee--synopsis": "Estimate the fee per kilobyte in satoshis " +
 		"required for a transaction to be mined before a certain number of " +
 		"blocks have been generated.",
 	"estimatefee-numblocks": "The maximum number of blocks which can be " +
 		"generated before the transaction is mined.",
-	"estimatefee--result0": "Estimated fee per kilobyte in satoshis for a block to " +
-		"be mined in the next NumBlocks blocks.",
+	"estimatefee--result0": "Estimated fee per kilobyte in BTC for a block to " +
+		"be mined in the next NumBlocks blocks, or -1 if there is not enough data.",
+
+	// EstimateSmartFeeCmd help.
+	"estimatesmartfee--synopsis": "Estimate the fee per kilobyte required for a transaction to be confirmed " +
+		"within a certain number of blocks, based on how long it took transactions paying similar fees " +
+		"to be confirmed over the short, medium and long term.",
+	"estimatesmartfee-conftarget": "The number of blocks the transaction should be confirmed within (1 to 1008)",
+	"estimatesmartfee-estimatemode": "The fee estimate mode: ECONOMICAL responds quicker to drops in fees, " +
+		"while CONSERVATIVE or UNSET also considers the fees required over the longer term",
+
+	// EstimateSmartFeeResult help.
+	"estimatesmartfeeresult-feerate": "Estimated fee per kilobyte in BTC, which is at least the minimum fee of the memory pool (omitted if there is not enough data)",
+	"estimatesmartfeeresult-errors":  "Errors encountered while estimating the fee (omitted if there were none)",
+	"estimatesmartfeeresult-blocks":  "The number of blocks the estimate is for, which is lower than requested when not enough blocks were observed",
 
 	// GenerateCmd help
 	"generate--synopsis": "Generates a set number of blocks (simnet or regtest only) and returns a JSON\n" +
@@ -147,6 +179,42 @@ var helpDescsEnUS = map[string]string{
 	"getaddednodeinfo--condition1": "dns=true",
 	"getaddednodeinfo--result0":    "List of added peers",
 
+	// GetAddressBalanceCmd help.
+	"getaddressbalance--synopsis": "Returns the balance of an address along with the total amounts it received and sent as of a block in the main chain.\n" +
+		"Only outputs that pay to the public key script of the address are taken into account.\n" +
+		"Requires the balance index to be enabled (--balanceindex).",
+	"getaddressbalance-address": "The address to return the balance of",
+	"getaddressbalance-height":  "The height of the block in the main chain to return the balance as of (default: the best block)",
+
+	// GetAddressBalanceResult help.
+	"getaddressbalanceresult-address":   "The address",
+	"getaddressbalanceresult-height":    "The height of the block the balance is as of",
+	"getaddressbalanceresult-blockhash": "The hash of the block the balance is as of",
+	"getaddressbalanceresult-balance":   "The sum of the unspent outputs that pay to the address in BTC",
+	"getaddressbalanceresult-received":  "The sum of all outputs that pay to the address in BTC",
+	"getaddressbalanceresult-sent":      "The sum of the spent outputs that pay to the address in BTC",
+	"getaddressbalanceresult-utxocount": "The number of unspent outputs that pay to the address",
+
+	// GetAddressUtxosCmd help.
+	"getaddressutxos--synopsis": "Returns the unspent outputs that pay to an address as of a block in the main chain.\n" +
+		"Only outputs that pay to the public key script of the address are taken into account.\n" +
+		"Requires the balance index to be enabled (--balanceindex).",
+	"getaddressutxos-address": "The address to return the unspent outputs of",
+	"getaddressutxos-height":  "The height of the block in the main chain to return the unspent outputs as of (default: the best block)",
+
+	// GetAddressUtxosResult help.
+	"getaddressutxosresult-address":   "The address",
+	"getaddressutxosresult-height":    "The height of the block the unspent outputs are as of",
+	"getaddressutxosresult-blockhash": "The hash of the block the unspent outputs are as of",
+	"getaddressutxosresult-utxos":     "The unspent outputs ordered by the height of the block that created them",
+
+	// GetAddressUtxosResultUtxo help.
+	"getaddressutxosresultutxo-txid":         "The hash of the transaction that created the output",
+	"getaddressutxosresultutxo-vout":         "The index of the output",
+	"getaddressutxosresultutxo-amount":       "The value of the output in BTC",
+	"getaddressutxosresultutxo-height":       "The height of the block that contains the transaction that created the output",
+	"getaddressutxosresultutxo-scriptPubKey": "The hex-encoded public key script of the output",
+
 	// GetBestBlockResult help.
 	"getbestblockresult-hash":   "Hex-encoded bytes of the best block hash",
 	"getbestblockresult-height": "Height of the best block",
@@ -162,7 +230,7 @@ var helpDescsEnUS = map[string]string{
 	// GetBlockCmd help.
 	"getblock--synopsis":   "Returns information about a block given its hash.",
 	"getblock-hash":        "The hash of the block",
-	"getblock-verbosity":   "Specifies whether the block data should be returned as a hex-encoded string (0), as parsed data with a slice of TXIDs (1), or as parsed data with parsed transaction data (2) ",
+	"getblock-verbosity":   "Specifies whether the block data should be returned as a hex-encoded string (0), as parsed data with a slice of TXIDs (1), as parsed data with parsed transaction data (2), or as parsed data with parsed transaction data including fees and the outputs spent by the inputs (3)",
 	"getblock--condition0": "verbosity=0",
 	"getblock--condition1": "verbosity=1",
 	"getblock--result0":    "Hex-encoded bytes of the serialized block",
@@ -183,6 +251,8 @@ var helpDescsEnUS = map[string]string{
 	"getblockchaininforesult-chainwork":            "The total cumulative work in the best chain",
 	"getblockchaininforesult-size_on_disk":         "The estimated size of the block and undo files on disk",
 	"getblockchaininforesult-initialblockdownload": "Estimate of whether this node is in Initial Block Download mode",
+	"getblockchaininforesult-assumevalid":          "The hash of the block whose ancestors are assumed to have valid scripts",
+	"getblockchaininforesult-assumevalidactive":    "Whether script validation is currently being skipped for assumed valid blocks",
 	"getblockchaininforesult-softforks":            "The status of the super-majority soft-forks",
 	"getblockchaininforesult-unifiedsoftforks":     "The status of the super-majority soft-forks used by bitcoind on or after v0.19.0",
 
@@ -220,6 +290,7 @@ var helpDescsEnUS = map[string]string{
 	"txrawresult-vsize":         "The virtual size of the transaction in bytes",
 	"txrawresult-weight":        "The transaction's weight (between vsize*4-3 and vsize*4)",
 	"txrawresult-hash":          "The wtxid of the transaction",
+	"txrawresult-fee":           "The fee paid by the transaction in BTC (getblock with verbosity=3 and non-coinbase txns only)",
 
 	// SearchRawTransactionsResult help.
 	"searchrawtransactionsresult-hex":           "Hex-encoded transaction",
@@ -246,7 +317,7 @@ var helpDescsEnUS = map[string]string{
 	"getblockverboseresult-versionHex":        "The block version in hexadecimal",
 	"getblockverboseresult-merkleroot":        "Root hash of the merkle tree",
 	"getblockverboseresult-tx":                "The transaction hashes (only when verbosity=1)",
-	"getblockverboseresult-rawtx":             "The transactions as JSON objects (only when verbosity=2)",
+	"getblockverboseresult-rawtx":             "The transactions as JSON objects (only when verbosity is 2 or 3)",
 	"getblockverboseresult-time":              "The block time in seconds since 1 Jan 1970 GMT",
 	"getblockverboseresult-nonce":             "The block nonce",
 	"getblockverboseresult-bits":              "The bits which represent the block difficulty",
@@ -256,6 +327,15 @@ var helpDescsEnUS = map[string]string{
 	"getblockverboseresult-strippedsize":      "The size of the block without witness data",
 	"getblockverboseresult-weight":            "The weight of the block",
 
+	// GetBlockFilterCmd help.
+	"getblockfilter--synopsis":  "Returns the BIP0158 content filter of the given type for a block in the main chain.",
+	"getblockfilter-blockhash":  "The hash of the block",
+	"getblockfilter-filtertype": "The type name of the filter (basic)",
+
+	// GetBlockFilterResult help.
+	"getblockfilterresult-filter": "The hex-encoded filter data",
+	"getblockfilterresult-header": "The hex-encoded filter header",
+
 	// GetBlockCountCmd help.
 	"getblockcount--synopsis": "Returns the number of blocks in the longest block chain.",
 	"getblockcount--result0":  "The current block count",
@@ -287,6 +367,44 @@ var helpDescsEnUS = map[string]string{
 	"getblockheaderverboseresult-previousblockhash": "The hash of the previous block",
 	"getblockheaderverboseresult-nextblockhash":     "The hash of the next block (only if there is one)",
 
+	// GetBlockStatsCmd help.
+	"getblockstats--synopsis":    "Returns statistics about a block in the main chain, such as the fees paid by its transactions and the change in size of the unspent transaction output set.\nAll amounts are in satoshi and fee rates in satoshi per virtual byte.",
+	"getblockstats-hashorheight": "The hash or height of the block",
+	"getblockstats-stats":        "The statistics to return (default: all)",
+
+	// GetBlockStatsResult help.
+	"getblockstatsresult-avgfee":               "The average fee of the transactions in the block excluding the coinbase",
+	"getblockstatsresult-avgfeerate":           "The average fee rate of the transactions in the block excluding the coinbase",
+	"getblockstatsresult-avgtxsize":            "The average size of the transactions in the block excluding the coinbase",
+	"getblockstatsresult-feerate_percentiles":  "The fee rates at the 10th, 25th, 50th, 75th and 90th percentile of the weight of the transactions in the block excluding the coinbase",
+	"getblockstatsresult-blockhash":            "The hash of the block",
+	"getblockstatsresult-height":               "The height of the block",
+	"getblockstatsresult-ins":                  "The number of inputs excluding the coinbase",
+	"getblockstatsresult-maxfee":               "The highest fee of a transaction in the block",
+	"getblockstatsresult-maxfeerate":           "The highest fee rate of a transaction in the block",
+	"getblockstatsresult-maxtxsize":            "The size of the largest transaction in the block excluding the coinbase",
+	"getblockstatsresult-medianfee":            "The median fee of the transactions in the block",
+	"getblockstatsresult-mediantime":           "The median time of the block and the blocks before it in seconds since 1 Jan 1970 GMT",
+	"getblockstatsresult-mediantxsize":         "The median size of the transactions in the block excluding the coinbase",
+	"getblockstatsresult-minfee":               "The lowest fee of a transaction in the block",
+	"getblockstatsresult-minfeerate":           "The lowest fee rate of a transaction in the block",
+	"getblockstatsresult-mintxsize":            "The size of the smallest transaction in the block excluding the coinbase",
+	"getblockstatsresult-outs":                 "The number of outputs",
+	"getblockstatsresult-swtotal_size":         "The total size of the segwit transactions in the block",
+	"getblockstatsresult-swtotal_weight":       "The total weight of the segwit transactions in the block",
+	"getblockstatsresult-swtxs":                "The number of segwit transactions in the block",
+	"getblockstatsresult-subsidy":              "The block subsidy",
+	"getblockstatsresult-time":                 "The block time in seconds since 1 Jan 1970 GMT",
+	"getblockstatsresult-totalfee":             "The total fees of the transactions in the block",
+	"getblockstatsresult-total_out":            "The total amount of the outputs excluding the coinbase",
+	"getblockstatsresult-total_size":           "The total size of the transactions in the block excluding the coinbase",
+	"getblockstatsresult-total_weight":         "The total weight of the transactions in the block excluding the coinbase",
+	"getblockstatsresult-txs":                  "The number of transactions in the block including the coinbase",
+	"getblockstatsresult-utxo_increase":        "The change in the number of unspent transaction outputs",
+	"getblockstatsresult-utxo_size_inc":        "The change in the size of the unspent transaction output set",
+	"getblockstatsresult-utxo_increase_actual": "The change in the number of unspent transaction outputs excluding unspendable outputs",
+	"getblockstatsresult-utxo_size_inc_actual": "The change in the size of the unspent transaction output set excluding unspendable outputs",
+
 	// TemplateRequest help.
 	"templaterequest-mode":         "This is 'template', 'proposal', or omitted",
 	"templaterequest-capabilities": "List of capabilities",
@@ -359,6 +477,30 @@ var helpDescsEnUS = map[string]string{
 	"getcfilterheader-hash":       "The hash of the block",
 	"getcfilterheader--result0":   "The block's gcs filter header",
 
+	// GetChainTipsCmd help.
+	"getchaintips--synopsis": "Returns information about all known tips in the block tree, including the main chain as well as orphaned branches.",
+
+	// GetChainTipsResult help.
+	"getchaintipsresult-height":    "The height of the chain tip",
+	"getchaintipsresult-hash":      "The block hash of the chain tip",
+	"getchaintipsresult-branchlen": "The length of the branch connecting the tip to the main chain (zero for the main chain)",
+	"getchaintipsresult-status":    "The status of the chain (active, valid-fork, valid-headers, headers-only, invalid)",
+
+	// GetChainTxStatsCmd help.
+	"getchaintxstats--synopsis": "Returns statistics about the total number and rate of transactions in the chain.",
+	"getchaintxstats-nblocks":   "Size of the window in number of blocks (default: one month)",
+	"getchaintxstats-blockhash": "The hash of the block that ends the window (default: the best block)",
+
+	// GetChainTxStatsResult help.
+	"getchaintxstatsresult-time":                      "The timestamp for the final block in the window in seconds since 1 Jan 1970 GMT",
+	"getchaintxstatsresult-txcount":                   "The total number of transactions in the chain up to that point, if known",
+	"getchaintxstatsresult-window_final_block_hash":   "The hash of the final block in the window",
+	"getchaintxstatsresult-window_final_block_height": "The height of the final block in the window",
+	"getchaintxstatsresult-window_block_count":        "Size of the window in number of blocks",
+	"getchaintxstatsresult-window_tx_count":           "The number of transactions in the window, only returned if window_block_count is greater than 0 and the counts are known",
+	"getchaintxstatsresult-window_interval":           "The elapsed time in the window in seconds",
+	"getchaintxstatsresult-txrate":                    "The average rate of transactions per second in the window, only returned if window_interval is greater than 0 and the counts are known",
+
 	// GetConnectionCountCmd help.
 	"getconnectioncount--synopsis": "Returns the number of active connections to other peers.",
 	"getconnectioncount--result0":  "The number of connections",
@@ -379,6 +521,17 @@ var helpDescsEnUS = map[string]string{
 	"gethashespersec--synopsis": "Returns a recent hashes per second performance measurement while generating coins (mining).",
 	"gethashespersec--result0":  "The number of hashes per second",
 
+	// GetIndexInfoCmd help.
+	"getindexinfo--synopsis":       "Returns the sync state of each of the enabled optional indexes.",
+	"getindexinfo-indexname":       "Only return the sync state of the index with this name",
+	"getindexinfo--result0--desc":  "Sync state objects keyed by the index name",
+	"getindexinfo--result0--key":   "Index name",
+	"getindexinfo--result0--value": "Object containing the sync state of the index",
+
+	// GetIndexInfoResult help.
+	"getindexinforesult-synced":            "Whether or not the index has caught up with the main chain",
+	"getindexinforesult-best_block_height": "The height of the last block connected to the index",
+
 	// InfoChainResult help.
 	"infochainresult-version":         "The version of the server",
 	"infochainresult-protocolversion": "The latest supported protocol version",
@@ -418,12 +571,62 @@ var helpDescsEnUS = map[string]string{
 	// GetInfoCmd help.
 	"getinfo--synopsis": "Returns a JSON object containing various state info.",
 
+	// GetMempoolAncestorsCmd help.
+	"getmempoolancestors--synopsis":   "Returns all of the unconfirmed ancestors in the memory pool of a transaction in the memory pool.",
+	"getmempoolancestors-txid":        "The hash of the transaction",
+	"getmempoolancestors-verbose":     "Returns JSON object when true or an array of transaction hashes when false",
+	"getmempoolancestors--condition0": "verbose=false",
+	"getmempoolancestors--condition1": "verbose=true",
+	"getmempoolancestors--result0":    "Array of the hashes of the ancestors",
+
+	// GetMempoolDescendantsCmd help.
+	"getmempooldescendants--synopsis":   "Returns all of the descendants in the memory pool of a transaction in the memory pool.",
+	"getmempooldescendants-txid":        "The hash of the transaction",
+	"getmempooldescendants-verbose":     "Returns JSON object when true or an array of transaction hashes when false",
+	"getmempooldescendants--condition0": "verbose=false",
+	"getmempooldescendants--condition1": "verbose=true",
+	"getmempooldescendants--result0":    "Array of the hashes of the descendants",
+
+	// GetMempoolEntryCmd help.
+	"getmempoolentry--synopsis": "Returns information about a transaction in the memory pool.",
+	"getmempoolentry-txid":      "The hash of the transaction",
+
+	// GetMempoolEntryResult help.
+	"getmempoolentryresult-vsize":              "The virtual size of the transaction",
+	"getmempoolentryresult-size":               "The size of the transaction in bytes",
+	"getmempoolentryresult-weight":             "The weight of the transaction",
+	"getmempoolentryresult-fee":                "The fee paid by the transaction in bitcoins (deprecated, see fees)",
+	"getmempoolentryresult-modifiedfee":        "The fee paid by the transaction used for mining in bitcoins (deprecated, see fees)",
+	"getmempoolentryresult-time":               "Local time the transaction entered the pool in seconds since 1 Jan 1970 GMT",
+	"getmempoolentryresult-height":             "Block height when the transaction entered the pool",
+	"getmempoolentryresult-descendantcount":    "The number of descendants in the pool, including the transaction itself",
+	"getmempoolentryresult-descendantsize":     "The virtual size of the descendants in the pool, including the transaction itself",
+	"getmempoolentryresult-descendantfees":     "The fees paid by the descendants in the pool, including the transaction itself, in satoshi (deprecated, see fees)",
+	"getmempoolentryresult-ancestorcount":      "The number of unconfirmed ancestors in the pool, including the transaction itself",
+	"getmempoolentryresult-ancestorsize":       "The virtual size of the unconfirmed ancestors in the pool, including the transaction itself",
+	"getmempoolentryresult-ancestorfees":       "The fees paid by the unconfirmed ancestors in the pool, including the transaction itself, in satoshi (deprecated, see fees)",
+	"getmempoolentryresult-wtxid":              "The witness hash of the transaction",
+	"getmempoolentryresult-fees":               "The fees related to the transaction in bitcoins",
+	"getmempoolentryresult-depends":            "Unconfirmed transactions in the pool used as inputs for this transaction",
+	"getmempoolentryresult-spentby":            "Unconfirmed transactions in the pool spending outputs of this transaction",
+	"getmempoolentryresult-bip125-replaceable": "Whether the transaction can be replaced since it or one of its unconfirmed ancestors signals replacement (BIP0125)",
+
+	// MempoolFees help.
+	"mempoolfees-base":       "The fee paid by the transaction",
+	"mempoolfees-modified":   "The fee paid by the transaction used for mining",
+	"mempoolfees-ancestor":   "The fees paid by the unconfirmed ancestors in the pool, including the transaction itself",
+	"mempoolfees-descendant": "The fees paid by the descendants in the pool, including the transaction itself",
+
 	// GetMempoolInfoCmd help.
 	"getmempoolinfo--synopsis": "Returns memory pool information",
 
 	// GetMempoolInfoResult help.
-	"getmempoolinforesult-bytes": "Size in bytes of the mempool",
-	"getmempoolinforesult-size":  "Number of transactions in the mempool",
+	"getmempoolinforesult-bytes":         "Size in bytes of the mempool",
+	"getmempoolinforesult-size":          "Number of transactions in the mempool",
+	"getmempoolinforesult-usage":         "Estimated memory usage in bytes of the mempool",
+	"getmempoolinforesult-maxmempool":    "Maximum estimated memory usage in bytes of the mempool",
+	"getmempoolinforesult-mempoolminfee": "Minimum fee rate in BTC/kB for transactions to be accepted, which is raised above the minimum relay fee while the mempool is full",
+	"getmempoolinforesult-minrelaytxfee": "Minimum fee rate in BTC/kB for transactions to be relayed",
 
 	// GetMiningInfoResult help.
 	"getmininginforesult-blocks":             "Height of the latest best block",
@@ -533,6 +736,47 @@ var helpDescsEnUS = map[string]string{
 	"gettxout-vout":           "The index of the output",
 	"gettxout-includemempool": "Include the mempool when true",
 
+	// GetTxOutProofCmd help.
+	"gettxoutproof--synopsis": "Returns a hex-encoded proof that one or more transactions were included in a block of the main chain.\n" +
+		"The block is located via the transaction index (--txindex) unless its hash is provided.",
+	"gettxoutproof-txids":     "The hashes of the transactions to prove, which must all be in the same block",
+	"gettxoutproof-blockhash": "The hash of the block that contains the transactions (default: looked up via the transaction index)",
+	"gettxoutproof--result0":  "The serialized and hex-encoded merkle block that proves the inclusion of the transactions",
+
+	// GetTxOutSetInfoResult help.
+	"gettxoutsetinforesult-height":            "The height of the block the statistics are for",
+	"gettxoutsetinforesult-bestblock":         "The hash of the block the statistics are for",
+	"gettxoutsetinforesult-transactions":      "The number of transactions with unspent outputs (not available with the coinstats index)",
+	"gettxoutsetinforesult-txouts":            "The number of unspent transaction outputs",
+	"gettxoutsetinforesult-bogosize":          "A database-independent metric for the size of the unspent transaction output set",
+	"gettxoutsetinforesult-hash_serialized_2": "The serialized hash of the unspent transaction output set (not available with the coinstats index)",
+	"gettxoutsetinforesult-muhash":            "The MuHash3072 hash of the unspent transaction output set (only for hash type muhash)",
+	"gettxoutsetinforesult-disk_size":         "The estimated size of the unspent transaction output set on disk (not available with the coinstats index)",
+	"gettxoutsetinforesult-total_amount":      "The total amount of all unspent transaction outputs in BTC",
+
+	// GetTxOutSetInfoCmd help.
+	"gettxoutsetinfo--synopsis":    "Returns statistics about the unspent transaction output set as of a block in the main chain.\nRequires the coinstats index to be enabled (--coinstatsindex).",
+	"gettxoutsetinfo-hashtype":     "The hash of the unspent transaction output set to return (muhash or none)",
+	"gettxoutsetinfo-hashorheight": "The hash or height of the block to return the statistics for (default: the best block)",
+
+	// GetTxSpendingPrevOutCmd help.
+	"gettxspendingprevout--synopsis": "Returns the transactions that spend the given outputs.\n" +
+		"Spends by transactions in the mempool are always returned while spends in the main chain require the transaction output spender index to be enabled (--txospenderindex).",
+	"gettxspendingprevout-outputs": "The outputs to look up",
+
+	// GetTxSpendingPrevOutCmdOutput help.
+	"gettxspendingprevoutcmdoutput-txid": "The hash of the transaction that created the output",
+	"gettxspendingprevoutcmdoutput-vout": "The index of the output",
+
+	// GetTxSpendingPrevOutResult help.
+	"gettxspendingprevoutresult-txid":         "The hash of the transaction that created the output",
+	"gettxspendingprevoutresult-vout":         "The index of the output",
+	"gettxspendingprevoutresult-spendingtxid": "The hash of the transaction that spends the output, if it is spent",
+	"gettxspendingprevoutresult-blockhash":    "The hash of the block that contains the spending transaction, if it is in the main chain",
+
+	// HashOrHeight help.
+	"hashorheight-value": "The block hash as a string or the block height as a number",
+
 	// HelpCmd help.
 	"help--synopsis":   "Returns a list of all commands or help for a specified command.",
 	"help-command":     "The command to retrieve help for",
@@ -541,10 +785,43 @@ var helpDescsEnUS = map[string]string{
 	"help--result0":    "List of commands",
 	"help--result1":    "Help for specified command",
 
+	// InvalidateBlockCmd help.
+	"invalidateblock--synopsis": "Permanently marks a block as invalid, as if it violated a consensus rule.\n" +
+		"The block and all of its descendants are disconnected when they are part of the main chain.",
+	"invalidateblock-blockhash": "The hash of the block to mark as invalid",
+
+	// LoadTxOutSetCmd help.
+	"loadtxoutset--synopsis": "Loads a snapshot of the unspent transaction output set created with dumptxoutset and makes its block the tip of the main chain.\n" +
+		"Only snapshots known to this version are accepted.  The blocks below the snapshot are then downloaded and validated in the background.",
+	"loadtxoutset-path": "The path of the snapshot to load",
+
+	// LoadTxOutSetResult help.
+	"loadtxoutsetresult-coins_loaded": "The number of unspent transaction outputs loaded",
+	"loadtxoutsetresult-tip_hash":     "The hash of the block the snapshot is for, which is the new tip of the main chain",
+	"loadtxoutsetresult-base_height":  "The height of the block the snapshot is for",
+	"loadtxoutsetresult-path":         "The path of the loaded snapshot",
+
 	// PingCmd help.
 	"ping--synopsis": "Queues a ping to be sent to each connected peer.\n" +
 		"Ping times are provided by getpeerinfo via the pingtime and pingwait fields.",
 
+	// PreciousBlockCmd help.
+	"preciousblock--synopsis": "Treats a block as if it were received before others with the same work.\n" +
+		"A later preciousblock call can override the effect of an earlier one.",
+	"preciousblock-blockhash": "The hash of the block to mark as precious",
+
+	// ReconsiderBlockCmd help.
+	"reconsiderblock--synopsis": "Removes invalidity status of a block, its ancestors and its descendants, reconsidering them for activation.\n" +
+		"This can be used to undo the effects of invalidateblock.",
+	"reconsiderblock-blockhash": "The hash of the block to reconsider",
+
+	// SaveMempoolCmd help.
+	"savemempool--synopsis": "Saves the transactions in the memory pool to disk so they are loaded again on startup.\n" +
+		"An error is returned while the transactions saved on the last shutdown are still being loaded.",
+
+	// SaveMempoolResult help.
+	"savemempoolresult-filename": "The path of the file the transactions were saved to",
+
 	// SearchRawTransactionsCmd help.
 	"searchrawtransactions--synopsis": "Returns raw data for transactions involving the passed address.\n" +
 		"Returned transactions are pulled from both the database, and transactions currently in the mempool.\n" +
@@ -595,6 +872,49 @@ var helpDescsEnUS = map[string]string{
 	"submitblock--condition1": "Block rejected",
 	"submitblock--result1":    "The reason the block was rejected",
 
+	// SubmitPackageCmd help.
+	"submitpackage--synopsis": "Submits a package consisting of a child transaction and its parents to the memory pool and relays the transactions that are accepted.\n" +
+		"Parents that don't pay the minimum relay fee on their own are accepted when the package pays it in total, which allows the child to pay for them.\n" +
+		"Peers are sent the transactions individually.",
+	"submitpackage-rawtxns": "Serialized, hex-encoded transactions ordered such that every transaction follows the ones it spends, where the last one is the child and all others are its parents",
+
+	// SubmitPackageResult help.
+	"submitpackageresult-package_msg":           "The string \"success\" when all transactions were accepted, or the reason the package was rejected otherwise",
+	"submitpackageresult-tx-results":            "JSON object describing the outcome for each transaction",
+	"submitpackageresult-tx-results--key":       "wtxid",
+	"submitpackageresult-tx-results--value":     "An object describing the outcome for the transaction with the witness hash",
+	"submitpackageresult-tx-results--desc":      "The outcome for each transaction keyed by its witness hash",
+	"submitpackageresult-replaced-transactions": "The hashes of the transactions that were replaced",
+
+	// SubmitPackageTxResult help.
+	"submitpackagetxresult-txid":  "The hash of the transaction",
+	"submitpackagetxresult-vsize": "The virtual size of the transaction (only when accepted)",
+	"submitpackagetxresult-fees":  "The fees paid by the transaction (only when accepted)",
+	"submitpackagetxresult-error": "The reason the transaction was rejected (only when rejected)",
+
+	// SubmitPackageFees help.
+	"submitpackagefees-base":               "The fee paid by the transaction in BTC",
+	"submitpackagefees-effective-feerate":  "The fee rate in BTC/kvB the transaction was accepted with, which is that of the transactions it was accepted along with as a whole (only when added by the package)",
+	"submitpackagefees-effective-includes": "The witness hashes of the transactions the effective fee rate was computed for (only when added by the package)",
+
+	// TestMempoolAcceptCmd help.
+	"testmempoolaccept--synopsis": "Returns whether the passed transactions would be accepted into the memory pool without adding or relaying them.\n" +
+		"The transactions are tested in the given order, so a transaction may spend the outputs of the transactions before it that would be accepted.",
+	"testmempoolaccept-rawtxns":    "Serialized, hex-encoded transactions ordered such that every transaction follows the ones it spends",
+	"testmempoolaccept-maxfeerate": "Reject transactions with a fee rate higher than this in BTC/kvB, which defaults to 0.10, or 0 to accept any fee rate",
+
+	// TestMempoolAcceptResult help.
+	"testmempoolacceptresult-txid":          "The hash of the transaction",
+	"testmempoolacceptresult-wtxid":         "The witness hash of the transaction",
+	"testmempoolacceptresult-allowed":       "Whether the transaction would be accepted",
+	"testmempoolacceptresult-vsize":         "The virtual size of the transaction (only when allowed)",
+	"testmempoolacceptresult-fees":          "The fees paid by the transaction (only when allowed)",
+	"testmempoolacceptresult-reject-code":   "The reject code describing why the transaction would be rejected (only when not allowed)",
+	"testmempoolacceptresult-reject-reason": "The reason the transaction would be rejected (only when not allowed)",
+
+	// TestMempoolAcceptFees help.
+	"testmempoolacceptfees-base": "The fee paid by the transaction in BTC",
+
 	// ValidateAddressResult help.
 	"validateaddresschainresult-isvalid":         "Whether or not the address is valid",
 	"validateaddresschainresult-address":         "The bitcoin address (only when isvalid is true)",
@@ -624,6 +944,11 @@ var helpDescsEnUS = map[string]string{
 	"verifymessage-message":   "The signed message",
 	"verifymessage--result0":  "Whether or not the signature verified",
 
+	// VerifyTxOutProofCmd help.
+	"verifytxoutproof--synopsis": "Verifies that a proof created by gettxoutproof commits to a block in the main chain and returns the transactions it proves.",
+	"verifytxoutproof-proof":     "The hex-encoded proof created by gettxoutproof",
+	"verifytxoutproof--result0":  "The hashes of the proven transactions, which is empty when the proof is invalid",
+
 	// -------- Websocket-specific help --------
 
 	// Session help.
@@ -685,6 +1010,21 @@ var helpDescsEnUS = map[string]string{
 	"rescanblocks-blockhashes": "List of hashes to rescan.  Each next block must be a child of the previous.",
 	"rescanblocks--result0":    "List of matching blocks.",
 
+	// NotifyIndexEventsCmd help.
+	"notifyindexevents--synopsis": "Stream the blocks connected to and disconnected from the main chain along with the outputs they spend to an external indexer, starting after the passed block.\n" +
+		"Every block is sent as an indexblockconnected or indexblockdisconnected notification in the order the indexer has to apply them, which includes disconnecting blocks it processed that are no longer in the main chain.\n" +
+		"At most 8 notifications are sent before they have to be acknowledged with ackindexevents.\n" +
+		"Requires the spend journal index to be enabled (--spendjournalindex).",
+	"notifyindexevents-height": "The height of the last block the indexer has processed or -1 to start with the genesis block",
+	"notifyindexevents-hash":   "The hash of the last block the indexer has processed, which is required when it is no longer in the main chain (default: the block at height in the main chain)",
+
+	// AckIndexEventsCmd help.
+	"ackindexevents--synopsis": "Acknowledge the notifications sent to an external indexer up to and including the last one that leaves it at the passed height.",
+	"ackindexevents-height":    "The height the indexer is left with after processing the acknowledged notifications",
+
+	// StopNotifyIndexEventsCmd help.
+	"stopnotifyindexevents--synopsis": "Stop streaming blocks to an external indexer.",
+
 	// RescannedBlock help.
 	"rescannedblock-hash":         "Hash of the matching block.",
 	"rescannedblock-transactions": "List of matching transactions, serialized and hex-encoded.",
@@ -717,26 +1057,38 @@ var rpcResultTypes = map[string][]interface{}{
 	"debuglevel":             {(*string)(nil), (*string)(nil)},
 	"decoderawtransaction":   {(*btcjson.TxRawDecodeResult)(nil)},
 	"decodescript":           {(*btcjson.DecodeScriptResult)(nil)},
+	"dumptxoutset":           {(*btcjson.DumpTxOutSetResult)(nil)},
 	"estimatefee":            {(*float64)(nil)},
+	"estimatesmartfee":       {(*btcjson.EstimateSmartFeeResult)(nil)},
 	"generate":               {(*[]string)(nil)},
 	"getaddednodeinfo":       {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
+	"getaddressbalance":      {(*btcjson.GetAddressBalanceResult)(nil)},
+	"getaddressutxos":        {(*btcjson.GetAddressUtxosResult)(nil)},
 	"getbestblock":           {(*btcjson.GetBestBlockResult)(nil)},
 	"getbestblockhash":       {(*string)(nil)},
 	"getblock":               {(*string)(nil), (*btcjson.GetBlockVerboseResult)(nil)},
 	"getblockcount":          {(*int64)(nil)},
+	"getblockfilter":         {(*btcjson.GetBlockFilterResult)(nil)},
 	"getblockhash":           {(*string)(nil)},
 	"getblockheader":         {(*string)(nil), (*btcjson.GetBlockHeaderVerboseResult)(nil)},
+	"getblockstats":          {(*btcjson.GetBlockStatsResult)(nil)},
 	"getblocktemplate":       {(*btcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
 	"getblockchaininfo":      {(*btcjson.GetBlockChainInfoResult)(nil)},
 	"getcfilter":             {(*string)(nil)},
 	"getcfilterheader":       {(*string)(nil)},
+	"getchaintips":           {(*[]btcjson.GetChainTipsResult)(nil)},
+	"getchaintxstats":        {(*btcjson.GetChainTxStatsResult)(nil)},
 	"getconnectioncount":     {(*int32)(nil)},
 	"getcurrentnet":          {(*uint32)(nil)},
 	"getdifficulty":          {(*float64)(nil)},
 	"getgenerate":            {(*bool)(nil)},
 	"gethashespersec":        {(*float64)(nil)},
+	"getindexinfo":           {(*map[string]btcjson.GetIndexInfoResult)(nil)},
 	"getheaders":             {(*[]string)(nil)},
 	"getinfo":                {(*btcjson.InfoChainResult)(nil)},
+	"getmempoolancestors":    {(*[]string)(nil), (*btcjson.GetMempoolEntryResult)(nil)},
+	"getmempooldescendants":  {(*[]string)(nil), (*btcjson.GetMempoolEntryResult)(nil)},
+	"getmempoolentry":        {(*btcjson.GetMempoolEntryResult)(nil)},
 	"getmempoolinfo":         {(*btcjson.GetMempoolInfoResult)(nil)},
 	"getmininginfo":          {(*btcjson.GetMiningInfoResult)(nil)},
 	"getnettotals":           {(*btcjson.GetNetTotalsResult)(nil)},
@@ -746,19 +1098,30 @@ var rpcResultTypes = map[string][]interface{}{
 	"getrawmempool":          {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
 	"getrawtransaction":      {(*string)(nil), (*btcjson.TxRawResult)(nil)},
 	"gettxout":               {(*btcjson.GetTxOutResult)(nil)},
+	"gettxoutproof":          {(*string)(nil)},
+	"gettxoutsetinfo":        {(*btcjson.GetTxOutSetInfoResult)(nil)},
+	"gettxspendingprevout":   {(*[]btcjson.GetTxSpendingPrevOutResult)(nil)},
 	"node":                   nil,
 	"help":                   {(*string)(nil), (*string)(nil)},
+	"invalidateblock":        nil,
+	"loadtxoutset":           {(*btcjson.LoadTxOutSetResult)(nil)},
 	"ping":                   nil,
+	"preciousblock":          nil,
+	"reconsiderblock":        nil,
+	"savemempool":            {(*btcjson.SaveMempoolResult)(nil)},
 	"searchrawtransactions":  {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
 	"sendrawtransaction":     {(*string)(nil)},
 	"setgenerate":            nil,
 	"signmessagewithprivkey": {(*string)(nil)},
 	"stop":                   {(*string)(nil)},
 	"submitblock":            {nil, (*string)(nil)},
+	"submitpackage":          {(*btcjson.SubmitPackageResult)(nil)},
+	"testmempoolaccept":      {(*[]btcjson.TestMempoolAcceptResult)(nil)},
 	"uptime":                 {(*int64)(nil)},
 	"validateaddress":        {(*btcjson.ValidateAddressChainResult)(nil)},
 	"verifychain":            {(*bool)(nil)},
 	"verifymessage":          {(*bool)(nil)},
+	"verifytxoutproof":       {(*[]string)(nil)},
 	"version":                {(*map[string]btcjson.VersionResult)(nil)},
 
 	// Websocket commands.
@@ -774,6 +1137,9 @@ var rpcResultTypes = map[string][]interface{}{
 	"stopnotifyspent":           nil,
 	"rescan":                    nil,
 	"rescanblocks":              {(*[]btcjson.RescannedBlock)(nil)},
+	"notifyindexevents":         nil,
+	"ackindexevents":            nil,
+	"stopnotifyindexevents":     nil,
 }
 
 // helpCacher provides a concurrent safe type that provides help and usage for
@@ -63,14 +63,17 @@ type wsCommandHandler func(*wsClient, interface{}) (interface{}, error)
 // causes a dependency loop.
 var wsHandlers map[string]wsCommandHandler
 var wsHandlersBeforeInit = map[string]wsCommandHandler{
+	"ackindexevents":            handleAckIndexEvents,
 	"loadtxfilter":              handleLoadTxFilter,
 	"help":                      handleWebsocketHelp,
 	"notifyblocks":              handleNotifyBlocks,
+	"notifyindexevents":         handleNotifyIndexEvents,
 	"notifynewtransactions":     handleNotifyNewTransactions,
 	"notifyreceived":            handleNotifyReceived,
 	"notifyspent":               handleNotifySpent,
 	"session":                   handleSession,
 	"stopnotifyblocks":          handleStopNotifyBlocks,
+	"stopnotifyindexevents":     handleStopNotifyIndexEvents,
 	"stopnotifynewtransactions": handleStopNotifyNewTransactions,
 	"stopnotifyspent":           handleStopNotifySpent,
 	"stopnotifyreceived":        handleStopNotifyReceived,
@@ -521,6 +524,7 @@ out:
 					m.notifyFilteredBlockConnected(blockNotifications,
 						block)
 				}
+				wakeIndexEventStreams(clients)
 
 			case *notificationBlockDisconnected:
 				block := (*btcutil.Block)(n)
@@ -531,6 +535,7 @@ out:
 					m.notifyFilteredBlockDisconnected(blockNotifications,
 						block)
 				}
+				wakeIndexEventStreams(clients)
 
 			case *notificationTxAcceptedByMempool:
 				if n.isNew && len(txNotifications) != 0 {
@@ -1292,6 +1297,11 @@ type wsClient struct {
 	// `rescanblocks` methods.
 	filterData *wsClientFilter
 
+	// indexEvents is the stream of block events with their spent outputs
+	// requested by an external indexer via notifyindexevents.  It is nil
+	// when no events are being streamed.
+	indexEvents *indexEventStream
+
 	// Networking infrastructure.
 	serviceRequestSem semaphore
 	ntfnChan          chan []byte
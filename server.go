@@ -6,6 +6,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/rand"
 	"crypto/tls"
@@ -14,6 +15,8 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
@@ -31,6 +34,7 @@ import (
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/connmgr"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/electrum"
 	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/mining"
 	"github.com/btcsuite/btcd/mining/cpuminer"
@@ -58,6 +62,32 @@ const (
 	// retries when connecting to persistent peers.  It is adjusted by the
 	// number of retries such that there is a retry backoff.
 	connectionRetryInterval = time.Second * 5
+
+	// mempoolFileName is the name of the file in the data directory the
+	// transactions in the memory pool are saved to on shutdown so they can
+	// be loaded again on startup.
+	mempoolFileName = "mempool.dat"
+
+	// feeFilterInterval is the average amount of time in between feefilter
+	// messages sent to a peer.
+	feeFilterInterval = time.Minute * 10
+
+	// feeFilterMaxChangeDelay is the maximum amount of time in which a peer
+	// is sent a feefilter message once the minimum fee rate of the memory
+	// pool changed significantly since the last one.
+	feeFilterMaxChangeDelay = time.Minute * 5
+
+	// feeFilterCheckInterval is the amount of time in between checks for
+	// feefilter messages that are due to be sent.
+	feeFilterCheckInterval = time.Second * 10
+
+	// feeFilterMaxRate is the highest bucket in satoshi per kB fee rates
+	// are rounded to before they are sent in feefilter messages.
+	feeFilterMaxRate = 1e7
+
+	// feeFilterSpacing is the factor in between consecutive buckets fee
+	// rates are rounded to before they are sent in feefilter messages.
+	feeFilterSpacing = 1.1
 )
 
 var (
@@ -205,6 +235,7 @@ type server struct {
 	shutdown      int32
 	shutdownSched int32
 	startupTime   int64
+	mempoolLoaded int32 // Set once the saved mempool has been loaded.
 
 	chainParams          *chaincfg.Params
 	addrManager          *addrmgr.AddrManager
@@ -212,6 +243,7 @@ type server struct {
 	sigCache             *txscript.SigCache
 	hashCache            *txscript.HashCache
 	rpcServer            *rpcServer
+	electrumServer       *electrum.Server
 	syncManager          *netsync.SyncManager
 	chain                *blockchain.BlockChain
 	txMemPool            *mempool.TxPool
@@ -235,14 +267,24 @@ type server struct {
 	// if the associated index is not enabled.  These fields are set during
 	// initial creation of the server and never changed afterwards, so they
 	// do not need to be protected for concurrent access.
-	txIndex   *indexers.TxIndex
-	addrIndex *indexers.AddrIndex
-	cfIndex   *indexers.CfIndex
+	txIndex           *indexers.TxIndex
+	addrIndex         *indexers.AddrIndex
+	cfIndex           *indexers.CfIndex
+	coinStatsIndex    *indexers.CoinStatsIndex
+	txoSpenderIndex   *indexers.TxoSpenderIndex
+	scriptHashIndex   *indexers.ScriptHashIndex
+	spendJournalIndex *indexers.SpendJournalIndex
+	balanceIndex      *indexers.BalanceIndex
+	indexManager      *indexers.Manager
 
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
 	feeEstimator *mempool.FeeEstimator
 
+	// mempoolSaveMtx prevents the memory pool from being saved by more
+	// than one caller at the same time.
+	mempoolSaveMtx sync.Mutex
+
 	// cfCheckptCaches stores a cached slice of filter headers for cfcheckpt
 	// messages for each filter type.
 	cfCheckptCaches    map[wire.FilterType][]cfHeaderKV
@@ -778,6 +820,25 @@ func (sp *serverPeer) OnGetBlocks(_ *peer.Peer, msg *wire.MsgGetBlocks) {
 	hashList := chain.LocateBlocks(msg.BlockLocatorHashes, &msg.HashStop,
 		wire.MaxBlocksPerMsg)
 
+	// Pruned nodes and nodes that only signal NODE_NETWORK_LIMITED stop
+	// the inventory at the first block they are unlikely to still serve by
+	// the time it is requested, allowing for an hour worth of blocks to be
+	// connected in the meantime.
+	//
+	// This mirrors the behavior in the reference implementation.
+	if sp.server.services&wire.SFNodeNetwork != wire.SFNodeNetwork {
+		best := chain.BestSnapshot()
+		maxDepth := blockchain.MinBlocksToKeep - int32(time.Hour/
+			sp.server.chainParams.TargetTimePerBlock)
+		for i := range hashList {
+			height, err := chain.BlockHeightByHash(&hashList[i])
+			if err != nil || best.Height-height >= maxDepth {
+				hashList = hashList[:i]
+				break
+			}
+		}
+	}
+
 	// Generate inventory message.
 	invMsg := wire.NewMsgInv()
 	for i := range hashList {
@@ -1499,7 +1560,7 @@ func (s *server) relayTransactions(txns []*mempool.TxDesc) {
 }
 
 // AnnounceNewTransactions generates and relays inventory vectors and notifies
-// both websocket and getblocktemplate long poll clients of the passed
+// websocket, getblocktemplate long poll and Electrum clients of the passed
 // transactions.  This function should be called whenever new transactions
 // are added to the mempool.
 func (s *server) AnnounceNewTransactions(txns []*mempool.TxDesc) {
@@ -1512,6 +1573,12 @@ func (s *server) AnnounceNewTransactions(txns []*mempool.TxDesc) {
 	if s.rpcServer != nil {
 		s.rpcServer.NotifyNewTransactions(txns)
 	}
+
+	// Notify Electrum clients of the changes the transactions make to the
+	// statuses of their script hashes.
+	if s.electrumServer != nil {
+		s.electrumServer.NotifyMempoolChanged()
+	}
 }
 
 // Transaction has one confirmation on the main chain. Now we can mark it as no
@@ -1577,6 +1644,25 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 		return err
 	}
 
+	// Nodes that only signal NODE_NETWORK_LIMITED do not serve blocks
+	// deeper than the most recent ones they are required to keep, even
+	// when they still have them, to avoid revealing how far back their
+	// block storage goes (BIP0159).
+	if s.services&wire.SFNodeNetwork != wire.SFNodeNetwork {
+		height, err := s.chain.BlockHeightByHash(hash)
+		best := s.chain.BestSnapshot()
+		if err == nil && best.Height-height >= blockchain.MinBlocksToKeep {
+			peerLog.Tracef("Not serving block %v at height %d beyond "+
+				"the limited service depth", hash, height)
+
+			if doneChan != nil {
+				doneChan <- struct{}{}
+			}
+			return fmt.Errorf("block %v is beyond the limited "+
+				"service depth", hash)
+		}
+	}
+
 	// Deserialize the block.
 	var msgBlock wire.MsgBlock
 	err = msgBlock.Deserialize(bytes.NewReader(blockBytes))
@@ -2306,6 +2392,12 @@ out:
 	s.syncManager.Stop()
 	s.addrManager.Stop()
 
+	// No more blocks will be connected now that the sync manager has
+	// stopped, so write the utxo cache out to the database.
+	if err := s.chain.FlushUtxoCache(blockchain.FlushRequired); err != nil {
+		srvrLog.Errorf("Unable to flush the utxo cache: %v", err)
+	}
+
 	// Drain channels before exiting so nothing is left waiting around
 	// to send.
 cleanup:
@@ -2454,6 +2546,137 @@ cleanup:
 	s.wg.Done()
 }
 
+// chainFatalError is invoked by the chain when it encounters an error it can't
+// recover from and shuts the process down.
+func (s *server) chainFatalError(err error) {
+	srvrLog.Criticalf("Shutting down since the chain state can no longer "+
+		"be trusted: %v", err)
+	go func() {
+		shutdownRequestChannel <- struct{}{}
+	}()
+}
+
+// feeFilterState tracks the feefilter messages sent to a peer.
+type feeFilterState struct {
+	// sent is the fee rate in satoshi per kB that was last sent.
+	sent int64
+
+	// next is the time the next message is scheduled to be sent at.
+	next time.Time
+}
+
+// randomDelay returns a random duration up to the passed maximum in seconds.
+func randomDelay(max time.Duration) time.Duration {
+	return time.Second * time.Duration(randomUint16Number(
+		uint16(max/time.Second)))
+}
+
+// feeFilterRounder rounds fee rates to a fixed set of buckets before they are
+// sent in feefilter messages so peers can't use the exact minimum fee rate of
+// the memory pool to fingerprint the node.
+type feeFilterRounder struct {
+	buckets []float64
+}
+
+// newFeeFilterRounder returns a fee filter rounder with buckets spaced by
+// feeFilterSpacing from half the passed minimum relay fee up to
+// feeFilterMaxRate, along with a bucket for zero.
+func newFeeFilterRounder(minRelayTxFee btcutil.Amount) *feeFilterRounder {
+	buckets := []float64{0}
+	minBucket := math.Max(1, float64(minRelayTxFee)/2)
+	for b := minBucket; b <= feeFilterMaxRate; b *= feeFilterSpacing {
+		buckets = append(buckets, b)
+	}
+	return &feeFilterRounder{buckets: buckets}
+}
+
+// round returns the lowest bucket that is not below the passed fee rate, or
+// with a probability of 2/3 the bucket below it.  The highest bucket is
+// returned for fee rates above it.
+func (r *feeFilterRounder) round(feeRate int64) int64 {
+	i := sort.SearchFloat64s(r.buckets, float64(feeRate))
+	if i == len(r.buckets) || (i > 0 && randomUint16Number(3) != 0) {
+		i--
+	}
+	return int64(r.buckets[i])
+}
+
+// feeFilterHandler periodically sends feefilter messages with the minimum fee
+// rate of the memory pool to the peers that support them, so they don't
+// announce transactions that would not be accepted.  Each peer is sent the
+// current fee rate every feeFilterInterval on average, and sooner when it
+// changes significantly in the meantime.  The fee rate is rounded with a
+// feeFilterRounder, but never below the minimum relay fee, and messages are
+// only sent when the rounded fee rate differs from the one the peer was sent
+// last.
+//
+// It must be run as a goroutine.
+func (s *server) feeFilterHandler() {
+	ticker := time.NewTicker(feeFilterCheckInterval)
+	states := make(map[*serverPeer]*feeFilterState)
+	rounder := newFeeFilterRounder(cfg.minRelayTxFee)
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.quit:
+			break out
+		}
+
+		replyChan := make(chan []*serverPeer)
+		select {
+		case s.query <- getPeersMsg{reply: replyChan}:
+		case <-s.quit:
+			break out
+		}
+		peers := <-replyChan
+
+		feeRate := s.txMemPool.MinFeeRate()
+		now := time.Now()
+		connected := make(map[*serverPeer]*feeFilterState, len(peers))
+		for _, sp := range peers {
+			if sp.ProtocolVersion() < wire.FeeFilterVersion ||
+				sp.relayTxDisabled() {
+
+				continue
+			}
+			state, ok := states[sp]
+			if !ok {
+				state = &feeFilterState{}
+			}
+			connected[sp] = state
+
+			switch {
+			case !now.Before(state.next):
+				filter := rounder.round(feeRate)
+				if filter < int64(cfg.minRelayTxFee) {
+					filter = int64(cfg.minRelayTxFee)
+				}
+				if filter != state.sent {
+					msg := wire.NewMsgFeeFilter(filter)
+					sp.QueueMessage(msg, nil)
+					state.sent = filter
+				}
+				delay := randomDelay(feeFilterInterval * 2)
+				state.next = now.Add(delay)
+
+			// Send the next message sooner when the fee rate changed
+			// significantly, unless it is due soon anyway.
+			case now.Add(feeFilterMaxChangeDelay).Before(state.next) &&
+				(feeRate < state.sent*3/4 || feeRate > state.sent*4/3):
+
+				delay := randomDelay(feeFilterMaxChangeDelay)
+				state.next = now.Add(delay)
+			}
+		}
+		states = connected
+	}
+
+	ticker.Stop()
+	s.wg.Done()
+}
+
 // Start begins accepting connections from peers.
 func (s *server) Start() {
 	// Already started?
@@ -2471,6 +2694,22 @@ func (s *server) Start() {
 	s.wg.Add(1)
 	go s.peerHandler()
 
+	// Load the transactions saved to the memory pool on the last shutdown
+	// in the background.
+	if !cfg.NoPersistMempool {
+		s.wg.Add(1)
+		go s.mempoolLoader()
+	} else {
+		atomic.StoreInt32(&s.mempoolLoaded, 1)
+	}
+
+	// Let peers know about the minimum fee rate of the memory pool unless
+	// transactions are not relayed at all.
+	if !cfg.BlocksOnly {
+		s.wg.Add(1)
+		go s.feeFilterHandler()
+	}
+
 	if s.nat != nil {
 		s.wg.Add(1)
 		go s.upnpUpdateThread()
@@ -2486,6 +2725,10 @@ func (s *server) Start() {
 		s.rpcServer.Start()
 	}
 
+	if s.electrumServer != nil {
+		s.electrumServer.Start()
+	}
+
 	// Start the CPU miner if generation is enabled.
 	if cfg.Generate {
 		s.cpuMiner.Start()
@@ -2511,6 +2754,29 @@ func (s *server) Stop() error {
 		s.rpcServer.Stop()
 	}
 
+	// Shutdown the Electrum server if it's enabled.
+	if s.electrumServer != nil {
+		s.electrumServer.Stop()
+	}
+
+	// Stop catching up the optional indexes if they are still behind.
+	if s.indexManager != nil {
+		s.indexManager.Stop()
+	}
+
+	// Stop validating the blocks below a loaded utxo snapshot so nothing
+	// modifies the utxo cache after it is flushed.
+	s.chain.StopSnapshotValidation()
+
+	// Save the memory pool so it can be loaded again on startup.
+	if !cfg.NoPersistMempool {
+		if _, n, err := s.saveMempool(); err != nil {
+			srvrLog.Errorf("Unable to save mempool: %v", err)
+		} else {
+			srvrLog.Infof("Saved %d mempool transactions", n)
+		}
+	}
+
 	// Save fee estimator state in the database.
 	s.db.Update(func(tx database.Tx) error {
 		metadata := tx.Metadata()
@@ -2524,6 +2790,92 @@ func (s *server) Stop() error {
 	return nil
 }
 
+// mempoolLoader loads the transactions saved by saveMempool into the memory
+// pool, dropping those that are no longer valid or have been in the pool for
+// too long.  Loading stops early when the server is shutting down.
+//
+// It must be run as a goroutine.
+func (s *server) mempoolLoader() {
+	defer s.wg.Done()
+
+	path := filepath.Join(cfg.DataDir, mempoolFileName)
+	f, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			srvrLog.Errorf("Unable to open saved mempool: %v", err)
+		}
+		atomic.StoreInt32(&s.mempoolLoaded, 1)
+		return
+	}
+	defer f.Close()
+
+	// Allow the mempool to be saved again unless loading was interrupted,
+	// in which case the file is left alone so the transactions that were
+	// not loaded yet are not lost.  A file that can't be loaded is of no
+	// further use, so it is overwritten by the next save.
+	srvrLog.Infof("Loading mempool transactions from %s", path)
+	stats, err := s.txMemPool.Load(bufio.NewReader(f),
+		mempool.DefaultMempoolExpiry, s.quit)
+	if err == mempool.ErrLoadInterrupted {
+		return
+	}
+	atomic.StoreInt32(&s.mempoolLoaded, 1)
+	if err != nil {
+		srvrLog.Errorf("Unable to load saved mempool: %v", err)
+		return
+	}
+
+	srvrLog.Infof("Loaded %d mempool transactions (%d failed, %d expired, "+
+		"%d already known)", stats.Accepted, stats.Failed, stats.Expired,
+		stats.AlreadyKnown)
+}
+
+// saveMempool writes all of the transactions in the memory pool to the mempool
+// file in the data directory and returns its path along with the number of
+// transactions written.  The file is replaced atomically so a failure never
+// leaves a partially written file behind.
+//
+// An error is returned when the transactions saved on the last shutdown have
+// not been loaded yet since they would otherwise be lost.
+//
+// This function is safe for concurrent access.
+func (s *server) saveMempool() (string, int, error) {
+	if atomic.LoadInt32(&s.mempoolLoaded) == 0 {
+		return "", 0, errors.New("the saved mempool has not been " +
+			"loaded yet")
+	}
+
+	s.mempoolSaveMtx.Lock()
+	defer s.mempoolSaveMtx.Unlock()
+
+	path := filepath.Join(cfg.DataDir, mempoolFileName)
+	tmpPath := path + ".new"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return "", 0, err
+	}
+	w := bufio.NewWriter(f)
+	n, err := s.txMemPool.Dump(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", 0, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return "", 0, err
+	}
+
+	return path, n, nil
+}
+
 // WaitForShutdown blocks until the main listener and peer handlers are stopped.
 func (s *server) WaitForShutdown() {
 	s.wg.Wait()
@@ -2716,6 +3068,61 @@ func setupRPCListeners() ([]net.Listener, error) {
 	return listeners, nil
 }
 
+// setupElectrumListeners returns a slice of listeners that are configured for
+// use with the Electrum server depending on the configuration settings for
+// listen addresses.  The TLS listeners use the certificate and key of the RPC
+// server, which are generated if they don't exist yet.
+func setupElectrumListeners() ([]net.Listener, error) {
+	var tlsConfig *tls.Config
+	if len(cfg.ElectrumTLSListeners) > 0 {
+		if !fileExists(cfg.RPCKey) && !fileExists(cfg.RPCCert) {
+			err := genCertPair(cfg.RPCCert, cfg.RPCKey)
+			if err != nil {
+				return nil, err
+			}
+		}
+		keypair, err := tls.LoadX509KeyPair(cfg.RPCCert, cfg.RPCKey)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{keypair},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
+	tcpAddrs, err := parseListeners(cfg.ElectrumListeners)
+	if err != nil {
+		return nil, err
+	}
+	tlsAddrs, err := parseListeners(cfg.ElectrumTLSListeners)
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := make([]net.Listener, 0, len(tcpAddrs)+len(tlsAddrs))
+	for _, addr := range tcpAddrs {
+		listener, err := net.Listen(addr.Network(), addr.String())
+		if err != nil {
+			elecLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+	for _, addr := range tlsAddrs {
+		listener, err := tls.Listen(addr.Network(), addr.String(),
+			tlsConfig)
+		if err != nil {
+			elecLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
+
 // newServer returns a new btcd server configured to listen on addr for the
 // bitcoin network type specified by chainParams.  Use start to begin accepting
 // connections from peers.
@@ -2723,6 +3130,23 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	db database.DB, chainParams *chaincfg.Params,
 	interrupt <-chan struct{}) (*server, error) {
 
+	// The transaction and address indexes require all blocks, so refuse
+	// to use them once blocks have been pruned.
+	var beenPruned bool
+	err := db.View(func(dbTx database.Tx) error {
+		var err error
+		beenPruned, err = dbTx.BeenPruned()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if beenPruned && (cfg.TxIndex || cfg.AddrIndex) {
+		return nil, errors.New("the transaction and address indexes " +
+			"can't be used since blocks have been pruned from the " +
+			"database")
+	}
+
 	services := defaultServices
 	if cfg.NoPeerBloomFilters {
 		services &^= wire.SFNodeBloom
@@ -2731,6 +3155,13 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		services &^= wire.SFNodeCF
 	}
 
+	// Pruned nodes are only able to serve the most recent blocks, so they
+	// signal that instead of being a full node (BIP0159).
+	if cfg.Prune != 0 || beenPruned {
+		services &^= wire.SFNodeNetwork
+		services |= wire.SFNodeNetworkLimited
+	}
+
 	amgr := addrmgr.New(cfg.DataDir, btcdLookup)
 
 	var listeners []net.Listener
@@ -2807,11 +3238,46 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		s.cfIndex = indexers.NewCfIndex(db, chainParams)
 		indexes = append(indexes, s.cfIndex)
 	}
+	if cfg.CoinStatsIndex {
+		indxLog.Info("Coin stats index is enabled")
+		s.coinStatsIndex = indexers.NewCoinStatsIndex(db)
+		indexes = append(indexes, s.coinStatsIndex)
+	}
+	if cfg.TxoSpenderIndex {
+		indxLog.Info("Transaction output spender index is enabled")
+		s.txoSpenderIndex = indexers.NewTxoSpenderIndex(db)
+		indexes = append(indexes, s.txoSpenderIndex)
+	}
+	electrumEnabled := len(cfg.ElectrumListeners) > 0 ||
+		len(cfg.ElectrumTLSListeners) > 0
+	if cfg.ScriptHashIndex || electrumEnabled {
+		if !cfg.ScriptHashIndex {
+			indxLog.Infof("Script hash index enabled because it " +
+				"is required by the Electrum server")
+			cfg.ScriptHashIndex = true
+		} else {
+			indxLog.Info("Script hash index is enabled")
+		}
+
+		s.scriptHashIndex = indexers.NewScriptHashIndex(db)
+		indexes = append(indexes, s.scriptHashIndex)
+	}
+	if cfg.SpendJournalIndex {
+		indxLog.Info("Spend journal index is enabled")
+		s.spendJournalIndex = indexers.NewSpendJournalIndex(db)
+		indexes = append(indexes, s.spendJournalIndex)
+	}
+	if cfg.BalanceIndex {
+		indxLog.Info("Balance index is enabled")
+		s.balanceIndex = indexers.NewBalanceIndex(db)
+		indexes = append(indexes, s.balanceIndex)
+	}
 
 	// Create an index manager if any of the optional indexes are enabled.
 	var indexManager blockchain.IndexManager
 	if len(indexes) > 0 {
-		indexManager = indexers.NewManager(db, indexes)
+		s.indexManager = indexers.NewManager(db, indexes)
+		indexManager = s.indexManager
 	}
 
 	// Merge given checkpoints with the default ones unless they are disabled.
@@ -2821,21 +3287,41 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	}
 
 	// Create a new block chain instance with the appropriate configuration.
-	var err error
 	s.chain, err = blockchain.New(&blockchain.Config{
-		DB:           s.db,
-		Interrupt:    interrupt,
-		ChainParams:  s.chainParams,
-		Checkpoints:  checkpoints,
-		TimeSource:   s.timeSource,
-		SigCache:     s.sigCache,
-		IndexManager: indexManager,
-		HashCache:    s.hashCache,
+		DB:               s.db,
+		Interrupt:        interrupt,
+		FatalError:       s.chainFatalError,
+		ChainParams:      s.chainParams,
+		Checkpoints:      checkpoints,
+		AssumeValid:      cfg.assumeValid,
+		TimeSource:       s.timeSource,
+		SigCache:         s.sigCache,
+		IndexManager:     indexManager,
+		HashCache:        s.hashCache,
+		UtxoCacheMaxSize: uint64(cfg.UtxoCacheMaxSizeMiB) * 1024 * 1024,
+		PruneTarget:      cfg.Prune * 1024 * 1024,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	// Make sure the stored committed filters are consistent with their
+	// headers when requested.
+	if cfg.VerifyCfIndex {
+		indxLog.Info("Verifying committed filter headers")
+		err := s.cfIndex.VerifyFilterHeaders(s.chain,
+			wire.GCSFilterRegular, interrupt)
+		if err != nil {
+			if mismatch, ok := err.(indexers.FilterHeaderMismatchError); ok {
+				indxLog.Errorf("Committed filter index is "+
+					"inconsistent: %v -- Use --dropcfindex to "+
+					"rebuild it", mismatch)
+			}
+			return nil, err
+		}
+		indxLog.Info("Committed filter headers are consistent")
+	}
+
 	// Search for a FeeEstimator state in the database. If none can be found
 	// or if it cannot be loaded, create a new one.
 	db.Update(func(tx database.Tx) error {
@@ -2858,12 +3344,11 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		return nil
 	})
 
-	// If no feeEstimator has been found, or if the one that has been found
-	// is behind somehow, create a new one and start over.
-	if s.feeEstimator == nil || s.feeEstimator.LastKnownHeight() != s.chain.BestSnapshot().Height {
-		s.feeEstimator = mempool.NewFeeEstimator(
-			mempool.DefaultEstimateFeeMaxRollback,
-			mempool.DefaultEstimateFeeMinRegisteredBlocks)
+	// If no feeEstimator has been found, create a new one and start over.
+	// A restored one is kept regardless of the height it was saved at since
+	// it disregards the blocks it covers once they are too far behind.
+	if s.feeEstimator == nil {
+		s.feeEstimator = mempool.NewFeeEstimator()
 	}
 
 	txC := mempool.Config{
@@ -2877,6 +3362,11 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 			MinRelayTxFee:        cfg.minRelayTxFee,
 			MaxTxVersion:         2,
 			RejectReplacement:    cfg.RejectReplacement,
+			MaxAncestorCount:     cfg.LimitAncestorCount,
+			MaxAncestorSize:      cfg.LimitAncestorSize * 1000,
+			MaxDescendantCount:   cfg.LimitDescendantCount,
+			MaxDescendantSize:    cfg.LimitDescendantSize * 1000,
+			MaxPoolSize:          int64(cfg.MaxMempool) * 1000000,
 		},
 		ChainParams:    chainParams,
 		FetchUtxoView:  s.chain.FetchUtxoView,
@@ -2889,6 +3379,7 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		SigCache:           s.sigCache,
 		HashCache:          s.hashCache,
 		AddrIndex:          s.addrIndex,
+		ScriptHashIndex:    s.scriptHashIndex,
 		FeeEstimator:       s.feeEstimator,
 	}
 	s.txMemPool = mempool.New(&txC)
@@ -3028,21 +3519,27 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		}
 
 		s.rpcServer, err = newRPCServer(&rpcserverConfig{
-			Listeners:    rpcListeners,
-			StartupTime:  s.startupTime,
-			ConnMgr:      &rpcConnManager{&s},
-			SyncMgr:      &rpcSyncMgr{&s, s.syncManager},
-			TimeSource:   s.timeSource,
-			Chain:        s.chain,
-			ChainParams:  chainParams,
-			DB:           db,
-			TxMemPool:    s.txMemPool,
-			Generator:    blockTemplateGenerator,
-			CPUMiner:     s.cpuMiner,
-			TxIndex:      s.txIndex,
-			AddrIndex:    s.addrIndex,
-			CfIndex:      s.cfIndex,
-			FeeEstimator: s.feeEstimator,
+			Listeners:         rpcListeners,
+			StartupTime:       s.startupTime,
+			ConnMgr:           &rpcConnManager{&s},
+			SyncMgr:           &rpcSyncMgr{&s, s.syncManager},
+			TimeSource:        s.timeSource,
+			Chain:             s.chain,
+			ChainParams:       chainParams,
+			DB:                db,
+			TxMemPool:         s.txMemPool,
+			Generator:         blockTemplateGenerator,
+			CPUMiner:          s.cpuMiner,
+			TxIndex:           s.txIndex,
+			AddrIndex:         s.addrIndex,
+			CfIndex:           s.cfIndex,
+			CoinStatsIndex:    s.coinStatsIndex,
+			TxoSpenderIndex:   s.txoSpenderIndex,
+			SpendJournalIndex: s.spendJournalIndex,
+			BalanceIndex:      s.balanceIndex,
+			IndexManager:      s.indexManager,
+			FeeEstimator:      s.feeEstimator,
+			SaveMempool:       s.saveMempool,
 		})
 		if err != nil {
 			return nil, err
@@ -3055,6 +3552,37 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		}()
 	}
 
+	if electrumEnabled {
+		// Setup listeners for the configured Electrum listen addresses.
+		electrumListeners, err := setupElectrumListeners()
+		if err != nil {
+			return nil, err
+		}
+		if len(electrumListeners) == 0 {
+			return nil, errors.New("ELEC: No valid listen address")
+		}
+
+		// The Electrum server is created after the sync manager so the
+		// memory pool is already updated when it is notified of blocks.
+		s.electrumServer = electrum.New(&electrum.Config{
+			Listeners:       electrumListeners,
+			MaxClients:      cfg.ElectrumMaxClients,
+			ServerVersion:   fmt.Sprintf("btcd %s", version()),
+			Chain:           s.chain,
+			ScriptHashIndex: s.scriptHashIndex,
+			IndexManager:    s.indexManager,
+			SubmitTx: func(tx *btcutil.Tx) error {
+				acceptedTxs, err := s.txMemPool.ProcessTransaction(
+					tx, false, false, 0)
+				if err != nil {
+					return err
+				}
+				s.AnnounceNewTransactions(acceptedTxs)
+				return nil
+			},
+		})
+	}
+
 	return &s, nil
 }
 
@@ -0,0 +1,74 @@
+// Copyright (c) 2022 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+// TestFeeFilterRounder ensures fee rates are rounded to the lowest bucket that
+// is not below them or randomly to the bucket below that.
+func TestFeeFilterRounder(t *testing.T) {
+	t.Parallel()
+
+	rounder := newFeeFilterRounder(1000)
+	buckets := rounder.buckets
+	if buckets[0] != 0 || buckets[1] != 500 {
+		t.Fatalf("unexpected lowest buckets %v", buckets[:2])
+	}
+	if !sort.Float64sAreSorted(buckets) ||
+		buckets[len(buckets)-1] > feeFilterMaxRate {
+
+		t.Fatalf("unexpected buckets %v", buckets)
+	}
+
+	tests := []struct {
+		name    string
+		feeRate int64
+		want    []int64
+	}{{
+		name:    "zero",
+		feeRate: 0,
+		want:    []int64{0},
+	}, {
+		name:    "below lowest bucket",
+		feeRate: 100,
+		want:    []int64{500, 0},
+	}, {
+		name:    "exact bucket",
+		feeRate: 550,
+		want:    []int64{550, 500},
+	}, {
+		name:    "in between buckets",
+		feeRate: 1000,
+		want:    []int64{1071, 974},
+	}, {
+		name:    "above highest bucket",
+		feeRate: 1e8,
+		want:    []int64{int64(buckets[len(buckets)-1])},
+	}}
+
+	for _, test := range tests {
+		seen := make(map[int64]bool)
+		for i := 0; i < 100; i++ {
+			got := rounder.round(test.feeRate)
+			found := false
+			for _, want := range test.want {
+				found = found || got == want
+			}
+			if !found {
+				t.Fatalf("%s: unexpected rounded fee rate %d, "+
+					"want one of %v", test.name, got,
+					test.want)
+			}
+			seen[got] = true
+		}
+		if len(seen) != len(test.want) {
+			t.Fatalf("%s: fee rate rounded to %v, want all of %v",
+				test.name, seen, test.want)
+		}
+	}
+}